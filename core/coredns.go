@@ -5,21 +5,48 @@ import (
 	_ "github.com/miekg/coredns/core/dnsserver"
 
 	// plug in the standard directives
+	_ "github.com/miekg/coredns/middleware/acl"
+	_ "github.com/miekg/coredns/middleware/admin"
+	_ "github.com/miekg/coredns/middleware/alias"
+	_ "github.com/miekg/coredns/middleware/audit"
 	_ "github.com/miekg/coredns/middleware/bind"
 	_ "github.com/miekg/coredns/middleware/cache"
 	_ "github.com/miekg/coredns/middleware/chaos"
+	_ "github.com/miekg/coredns/middleware/cookie"
+	_ "github.com/miekg/coredns/middleware/delay"
 	_ "github.com/miekg/coredns/middleware/dnssec"
+	_ "github.com/miekg/coredns/middleware/doh"
+	_ "github.com/miekg/coredns/middleware/edns"
 	_ "github.com/miekg/coredns/middleware/errors"
 	_ "github.com/miekg/coredns/middleware/etcd"
+	_ "github.com/miekg/coredns/middleware/fallback"
 	_ "github.com/miekg/coredns/middleware/file"
+	_ "github.com/miekg/coredns/middleware/flags"
+	_ "github.com/miekg/coredns/middleware/forward"
+	_ "github.com/miekg/coredns/middleware/garbage"
 	_ "github.com/miekg/coredns/middleware/health"
 	_ "github.com/miekg/coredns/middleware/kubernetes"
+	_ "github.com/miekg/coredns/middleware/limits"
 	_ "github.com/miekg/coredns/middleware/loadbalance"
+	_ "github.com/miekg/coredns/middleware/local"
 	_ "github.com/miekg/coredns/middleware/log"
 	_ "github.com/miekg/coredns/middleware/metrics"
+	_ "github.com/miekg/coredns/middleware/override"
 	_ "github.com/miekg/coredns/middleware/pprof"
+	_ "github.com/miekg/coredns/middleware/probe"
 	_ "github.com/miekg/coredns/middleware/proxy"
+	_ "github.com/miekg/coredns/middleware/rebind"
+	_ "github.com/miekg/coredns/middleware/reuseport"
 	_ "github.com/miekg/coredns/middleware/rewrite"
+	_ "github.com/miekg/coredns/middleware/route"
+	_ "github.com/miekg/coredns/middleware/rrl"
 	_ "github.com/miekg/coredns/middleware/secondary"
+	_ "github.com/miekg/coredns/middleware/startup"
+	_ "github.com/miekg/coredns/middleware/stub"
+	_ "github.com/miekg/coredns/middleware/timeout"
+	_ "github.com/miekg/coredns/middleware/tls"
+	_ "github.com/miekg/coredns/middleware/trace"
+	_ "github.com/miekg/coredns/middleware/truncate"
+	_ "github.com/miekg/coredns/middleware/unix"
 	_ "github.com/miekg/coredns/middleware/whoami"
 )
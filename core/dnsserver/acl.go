@@ -0,0 +1,73 @@
+package dnsserver
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ACL is an ordered list of rules that allow or block clients based on their IP
+// address. The first rule that matches decides, if no rule matches the client is
+// allowed, unless the ACL has allow rules: then it's blocked.
+type ACL struct {
+	rules  []aclRule
+	allows bool // true if any of the rules is an allow rule
+}
+
+type aclRule struct {
+	net   *net.IPNet
+	allow bool
+}
+
+// Allow adds a rule that allows the clients in cidr. A single IP address is
+// taken to be a /32 (IPv4) or /128 (IPv6).
+func (a *ACL) Allow(cidr string) error { return a.add(cidr, true) }
+
+// Block adds a rule that blocks the clients in cidr. A single IP address is
+// taken to be a /32 (IPv4) or /128 (IPv6).
+func (a *ACL) Block(cidr string) error { return a.add(cidr, false) }
+
+func (a *ACL) add(cidr string, allow bool) error {
+	if !strings.Contains(cidr, "/") {
+		ip := net.ParseIP(cidr)
+		if ip == nil {
+			return fmt.Errorf("not a valid IP address: %s", cidr)
+		}
+		if ip.To4() != nil {
+			cidr += "/32"
+		} else {
+			cidr += "/128"
+		}
+	}
+	_, n, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return err
+	}
+	a.rules = append(a.rules, aclRule{net: n, allow: allow})
+	a.allows = a.allows || allow
+	return nil
+}
+
+// Allowed returns true if ip is allowed to query.
+func (a *ACL) Allowed(ip net.IP) bool {
+	for _, r := range a.rules {
+		if r.net.Contains(ip) {
+			return r.allow
+		}
+	}
+	return !a.allows
+}
+
+// Len returns the number of rules in a.
+func (a *ACL) Len() int { return len(a.rules) }
+
+// remoteIP returns the IP address of a, or nil if it has none.
+func remoteIP(a net.Addr) net.IP {
+	switch a := a.(type) {
+	case *net.UDPAddr:
+		return a.IP
+	case *net.TCPAddr:
+		return a.IP
+	}
+	return net.ParseIP(hostOf(a))
+}
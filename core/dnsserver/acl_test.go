@@ -0,0 +1,50 @@
+package dnsserver
+
+import (
+	"net"
+	"testing"
+)
+
+func TestACL(t *testing.T) {
+	a := &ACL{}
+	if err := a.Block("10.240.0.1"); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if err := a.Allow("10.0.0.0/8"); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if err := a.Allow("2001:db8::/32"); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if err := a.Allow("10.0.0.0/33"); err == nil {
+		t.Errorf("Expected error for invalid CIDR, got none")
+	}
+	if err := a.Block("example.org"); err == nil {
+		t.Errorf("Expected error for invalid address, got none")
+	}
+
+	tests := []struct {
+		ip       string
+		expected bool
+	}{
+		{"10.240.0.1", false}, // first rule matches
+		{"10.240.0.2", true},
+		{"2001:db8::1", true},
+		{"192.168.1.1", false}, // no match, but we have allow rules
+	}
+	for i, tc := range tests {
+		if x := a.Allowed(net.ParseIP(tc.ip)); x != tc.expected {
+			t.Errorf("Test %d: expected %s to be allowed: %t, got %t", i, tc.ip, tc.expected, x)
+		}
+	}
+
+	// Only block rules: everything else is allowed.
+	b := &ACL{}
+	b.Block("192.168.0.0/16")
+	if !b.Allowed(net.ParseIP("10.0.0.1")) {
+		t.Errorf("Expected 10.0.0.1 to be allowed")
+	}
+	if b.Allowed(net.ParseIP("192.168.1.1")) {
+		t.Errorf("Expected 192.168.1.1 to be blocked")
+	}
+}
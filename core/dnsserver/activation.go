@@ -0,0 +1,110 @@
+package dnsserver
+
+import (
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// Systemd socket activation: systemd (or a compatible service manager) opens the
+// sockets and hands them to us starting at file descriptor 3, LISTEN_FDS holds the
+// number of descriptors and LISTEN_PID our PID. This lets CoreDNS use port 53 without
+// running as root. Listen and ListenPacket use an activated socket when one is bound
+// to the address of the server, and only open a new one otherwise.
+
+// listenFdsStart is the first file descriptor passed in by systemd.
+const listenFdsStart = 3
+
+var activation struct {
+	once sync.Once
+
+	sync.Mutex
+	listeners []net.Listener
+	conns     []net.PacketConn
+}
+
+// loadActivated converts the passed in file descriptors to listeners and packet conns.
+// The environment variables are unset, so they are not inherited by child processes.
+func loadActivated() {
+	defer os.Unsetenv("LISTEN_PID")
+	defer os.Unsetenv("LISTEN_FDS")
+	defer os.Unsetenv("LISTEN_FDNAMES")
+
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return
+	}
+
+	for fd := listenFdsStart; fd < listenFdsStart+n; fd++ {
+		f := os.NewFile(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd))
+		// Both calls dup the descriptor, so f can be closed in either case.
+		if l, err := net.FileListener(f); err == nil {
+			activation.listeners = append(activation.listeners, l)
+		} else if p, err := net.FilePacketConn(f); err == nil {
+			activation.conns = append(activation.conns, p)
+		} else {
+			log.Printf("[WARNING] Ignoring activated file descriptor %d: %s", fd, err)
+		}
+		f.Close()
+	}
+}
+
+// activatedListener returns the activated listener for addr and removes it from the
+// list, or nil if there is none.
+func activatedListener(addr string) net.Listener {
+	activation.once.Do(loadActivated)
+	activation.Lock()
+	defer activation.Unlock()
+	for i, l := range activation.listeners {
+		if activatedMatch(addr, l.Addr()) {
+			activation.listeners = append(activation.listeners[:i], activation.listeners[i+1:]...)
+			return l
+		}
+	}
+	return nil
+}
+
+// activatedPacketConn returns the activated packet conn for addr and removes it from
+// the list, or nil if there is none.
+func activatedPacketConn(addr string) net.PacketConn {
+	activation.once.Do(loadActivated)
+	activation.Lock()
+	defer activation.Unlock()
+	for i, p := range activation.conns {
+		if activatedMatch(addr, p.LocalAddr()) {
+			activation.conns = append(activation.conns[:i], activation.conns[i+1:]...)
+			return p
+		}
+	}
+	return nil
+}
+
+// activatedMatch returns true if the socket bound to local can be used for addr. The
+// ports must be equal; an addr without a host matches any local address.
+func activatedMatch(addr string, local net.Addr) bool {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	lhost, lport, err := net.SplitHostPort(local.String())
+	if err != nil || port != lport {
+		return false
+	}
+	if host == "" {
+		return true
+	}
+	ip, lip := net.ParseIP(host), net.ParseIP(lhost)
+	if ip == nil || lip == nil {
+		return false
+	}
+	if ip.IsUnspecified() {
+		return lip.IsUnspecified()
+	}
+	return ip.Equal(lip)
+}
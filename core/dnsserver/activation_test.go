@@ -0,0 +1,35 @@
+package dnsserver
+
+import (
+	"net"
+	"testing"
+)
+
+func TestActivatedMatch(t *testing.T) {
+	tests := []struct {
+		addr     string
+		local    string
+		expected bool
+	}{
+		{":53", "0.0.0.0:53", true},
+		{":53", "127.0.0.1:53", true},
+		{":53", "[::]:53", true},
+		{":53", "0.0.0.0:1053", false},
+		{"127.0.0.1:53", "127.0.0.1:53", true},
+		{"127.0.0.1:53", "127.0.0.2:53", false},
+		{"127.0.0.1:53", "0.0.0.0:53", false},
+		{"0.0.0.0:53", "0.0.0.0:53", true},
+		{"0.0.0.0:53", "127.0.0.1:53", false},
+		{"[::1]:53", "[::1]:53", true},
+		{"localhost:53", "127.0.0.1:53", false},
+	}
+	for i, tc := range tests {
+		local, err := net.ResolveTCPAddr("tcp", tc.local)
+		if err != nil {
+			t.Fatalf("Test %d: failed to resolve %s: %s", i, tc.local, err)
+		}
+		if got := activatedMatch(tc.addr, local); got != tc.expected {
+			t.Errorf("Test %d: expected %t for %s on %s, got %t", i, tc.expected, tc.addr, tc.local, got)
+		}
+	}
+}
@@ -3,18 +3,46 @@ package dnsserver
 import (
 	"fmt"
 	"net"
+	"regexp"
 	"strings"
 
-	"github.com/miekg/dns"
+	"github.com/miekg/coredns/middleware/pkg/dnsutil"
 )
 
 type zoneAddr struct {
-	Zone string
-	Port string
+	Zone      string
+	Port      string
+	Transport string // dns, tls, https or grpc
 }
 
-// String return z.Zone + ":" + z.Port as a string.
-func (z zoneAddr) String() string { return z.Zone + ":" + z.Port }
+// String return z.Zone + ":" + z.Port as a string. For transports other than dns
+// the transport is prefixed as transport://.
+func (z zoneAddr) String() string {
+	s := z.Zone + ":" + z.Port
+	if z.Transport != "" && z.Transport != TransportDNS {
+		s = z.Transport + "://" + s
+	}
+	return s
+}
+
+// Transport returns the transport defined in s and a string where the
+// transport prefix is removed (if there was any). If no transport is defined
+// we default to TransportDNS.
+func Transport(s string) (trans string, addr string) {
+	switch {
+	case strings.HasPrefix(s, TransportTLS+"://"):
+		return TransportTLS, s[len(TransportTLS+"://"):]
+	case strings.HasPrefix(s, TransportHTTPS+"://"):
+		return TransportHTTPS, s[len(TransportHTTPS+"://"):]
+	case strings.HasPrefix(s, TransportGRPC+"://"):
+		return TransportGRPC, s[len(TransportGRPC+"://"):]
+	case strings.HasPrefix(s, TransportUnix+"://"):
+		return TransportUnix, s[len(TransportUnix+"://"):]
+	case strings.HasPrefix(s, TransportDNS+"://"):
+		return TransportDNS, s[len(TransportDNS+"://"):]
+	}
+	return TransportDNS, s
+}
 
 // normalizeZone parses an zone string into a structured format with separate
 // host, and port portions, as well as the original input string.
@@ -23,6 +51,11 @@ func (z zoneAddr) String() string { return z.Zone + ":" + z.Port }
 func normalizeZone(str string) (zoneAddr, error) {
 	var err error
 
+	trans, str := Transport(str)
+	if trans == TransportUnix {
+		return zoneAddr{}, fmt.Errorf("zone can't have the %s transport, use the unix directive: %s", TransportUnix, str)
+	}
+
 	// separate host and port
 	host, port, err := net.SplitHostPort(str)
 	if err != nil {
@@ -33,14 +66,82 @@ func normalizeZone(str string) (zoneAddr, error) {
 	if len(host) > 255 {
 		return zoneAddr{}, fmt.Errorf("specified zone is too long: %d > 255", len(host))
 	}
-	_, d := dns.IsDomainName(host)
-	if !d {
-		return zoneAddr{}, fmt.Errorf("zone is not a valid domain name: %s", host)
+	var zone string
+	switch {
+	case isRegexpZone(host):
+		if _, rerr := regexp.Compile(host[1:]); rerr != nil {
+			return zoneAddr{}, fmt.Errorf("zone is not a valid regular expression: %s: %s", host, rerr)
+		}
+		zone = host
+	case isWildcardZone(host):
+		z, zerr := dnsutil.Zone(host[2:])
+		if zerr != nil {
+			return zoneAddr{}, fmt.Errorf("zone is not a valid domain name: %s", host)
+		}
+		zone = "*." + z
+	default:
+		z, zerr := dnsutil.Zone(host)
+		if zerr != nil {
+			return zoneAddr{}, fmt.Errorf("zone is not a valid domain name: %s", host)
+		}
+		zone = z
 	}
 
 	if port == "" {
-		port = "53"
+		switch trans {
+		case TransportTLS:
+			port = TLSPort
+		case TransportHTTPS:
+			port = HTTPSPort
+		case TransportGRPC:
+			port = GRPCPort
+		default:
+			port = "53"
+		}
 	}
 
-	return zoneAddr{Zone: strings.ToLower(dns.Fqdn(host)), Port: port}, err
+	return zoneAddr{Zone: zone, Port: port, Transport: trans}, err
 }
+
+// expandReverse expands a server block key that holds a CIDR, like 10.0.0.0/15, into
+// keys for the reverse zones that cover that network. Other keys are returned as is.
+func expandReverse(s string) ([]string, error) {
+	trans, addr := Transport(s)
+	cidr, port, ok := dnsutil.SplitCIDR(addr)
+	if !ok {
+		return []string{s}, nil
+	}
+	zones, err := dnsutil.ReverseZones(cidr)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, len(zones))
+	for i, z := range zones {
+		keys[i] = z
+		if port != "" {
+			keys[i] += ":" + port
+		}
+		if trans != TransportDNS {
+			keys[i] = trans + "://" + keys[i]
+		}
+	}
+	return keys, nil
+}
+
+// Supported transports.
+const (
+	TransportDNS   = "dns"
+	TransportTLS   = "tls"
+	TransportHTTPS = "https"
+	TransportGRPC  = "grpc"
+	TransportUnix  = "unix" // DNS over a Unix stream socket, see the unix directive
+)
+
+const (
+	// TLSPort is the default port for DNS-over-TLS.
+	TLSPort = "853"
+	// HTTPSPort is the default port for DNS-over-HTTPS.
+	HTTPSPort = "443"
+	// GRPCPort is the default port for DNS-over-gRPC.
+	GRPCPort = "443"
+)
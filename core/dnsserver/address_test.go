@@ -12,6 +12,22 @@ func TestNormalizeZone(t *testing.T) {
 		{".:54", ".:54", false},
 		{"..", ":", true},
 		{"..", ":", true},
+		{"dns://.", ".:53", false},
+		{"dns://example.org:5353", "example.org.:5353", false},
+		{"https://example.org", "https://example.org.:443", false},
+		{"https://.:8443", "https://.:8443", false},
+		{"grpc://example.org", "grpc://example.org.:443", false},
+		{"grpc://.:5553", "grpc://.:5553", false},
+		{"tls://example.org", "tls://example.org.:853", false},
+		{"tls://.:8853", "tls://.:8853", false},
+		{"unix://example.org", ":", true},
+		{"Example.ORG:1053", "example.org.:1053", false},
+		{"bücher.example", "xn--bcher-kva.example.:53", false},
+		{"*.Example.org", "*.example.org.:53", false},
+		{"*.example.org:1053", "*.example.org.:1053", false},
+		{"*.", ":", true},
+		{`~^cust[0-9]+\.example\.org\.$`, `~^cust[0-9]+\.example\.org\.$:53`, false},
+		{"~(", ":", true},
 	} {
 		addr, err := normalizeZone(test.input)
 		actual := addr.String()
@@ -26,3 +42,35 @@ func TestNormalizeZone(t *testing.T) {
 		}
 	}
 }
+
+func TestExpandReverse(t *testing.T) {
+	for i, test := range []struct {
+		input    string
+		expected []string
+	}{
+		{"example.org", []string{"example.org"}},
+		{"10.0.0.0/8", []string{"10.in-addr.arpa."}},
+		{"10.0.0.0/15:1053", []string{"0.10.in-addr.arpa.:1053", "1.10.in-addr.arpa.:1053"}},
+		{"https://10.0.0.0/24", []string{"https://0.0.10.in-addr.arpa."}},
+		{"dns://2001:db8::/32", []string{"8.b.d.0.1.0.0.2.ip6.arpa."}},
+	} {
+		keys, err := expandReverse(test.input)
+		if err != nil {
+			t.Errorf("Test %d: Expected no error, but there was one: %v", i, err)
+			continue
+		}
+		if len(keys) != len(test.expected) {
+			t.Errorf("Test %d: Expected %v but got %v", i, test.expected, keys)
+			continue
+		}
+		for j := range keys {
+			if keys[j] != test.expected[j] {
+				t.Errorf("Test %d: Expected %v but got %v", i, test.expected, keys)
+			}
+		}
+	}
+
+	if _, err := expandReverse("10.0.0.0/33"); err == nil {
+		t.Errorf("Expected error for invalid CIDR, got none")
+	}
+}
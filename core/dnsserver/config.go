@@ -1,9 +1,19 @@
 package dnsserver
 
 import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+
 	"github.com/miekg/coredns/middleware"
+	"github.com/miekg/coredns/middleware/pkg/metadata"
 
 	"github.com/mholt/caddy"
+	ot "github.com/opentracing/opentracing-go"
 )
 
 // Config configuration for a single server.
@@ -11,17 +21,169 @@ type Config struct {
 	// The zone of the site.
 	Zone string
 
-	// The hostname to bind listener to, defaults to the wildcard address
-	ListenHost string
+	// The addresses to bind the listeners to, defaults to the wildcard address. The
+	// zone is served on each of them.
+	ListenHosts []string
+
+	// UnixSocket is the path of a Unix domain socket the zone is served on, instead of
+	// its address and port. UnixMode are the permissions of the socket, 0 keeps the
+	// default of the process.
+	UnixSocket string
+	UnixMode   os.FileMode
 
-	// The port to listen on.
+	// The port to listen on. With port 0 the kernel picks one, TCP and UDP get the same
+	// port; see Server.LocalAddr and OnListening.
 	Port string
 
+	// Override allows the listener of the zone to overlap with the listeners of other
+	// server blocks on the same port: a listener on a specific address shadows the
+	// wildcard listener for that address.
+	Override bool
+
+	// The transport we implement, normally just "dns" over TCP/UDP, but could be
+	// DNS-over-HTTPS or DNS-over-gRPC as well.
+	Transport string
+
+	// TLSConfig when listening for encrypted connections (DNS-over-HTTPS, gRPC).
+	TLSConfig *tls.Config
+
+	// JSONAPI enables the /resolve JSON API on DNS-over-HTTPS listeners.
+	JSONAPI bool
+
+	// MaxConns is the maximum number of open stream (TCP, TLS) connections, 0 is unlimited.
+	MaxConns int
+
+	// MaxConnsPerIP is the maximum number of open stream connections per client IP, 0 is unlimited.
+	MaxConnsPerIP int
+
+	// ReadTimeout, WriteTimeout and IdleTimeout override the default timeouts of the
+	// listeners. IdleTimeout is how long a stream connection may stay open without a
+	// query. 0 keeps the default.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+
+	// QueryTimeout is the deadline for handling a single query, the context handed to the
+	// middleware is cancelled after it. 0 uses the default of 5 seconds.
+	QueryTimeout time.Duration
+
+	// MaxCNAMEDepth is the number of CNAMEs that middleware follow to build an answer,
+	// chains that are longer, or loop, get a SERVFAIL. It applies to the whole listener,
+	// the first zone that sets it decides. 0 uses the default of 8.
+	MaxCNAMEDepth int
+
+	// StartupTimeout is how long a startup hook registered with OnStartupAfter may take
+	// before the hooks that come after it are called. 0 uses the default of 30 seconds.
+	StartupTimeout time.Duration
+
+	// StartupPolicies holds, per middleware name, what is done when its startup hook
+	// registered with OnStartupAfter fails. Middleware not in it get StartupDegrade.
+	StartupPolicies map[string]StartupPolicy
+
+	// Timeout is the time the middleware of this zone get to answer a query, after it
+	// the client gets a SERVFAIL. 0 disables it.
+	Timeout time.Duration
+
+	// MaxConcurrent is the maximum number of queries that are handled at the same time, 0
+	// is unlimited. Queries over the limit get SERVFAIL, or are dropped if OverloadDrop is set.
+	MaxConcurrent int
+
+	// OverloadDrop drops queries over the MaxConcurrent limit instead of answering them.
+	OverloadDrop bool
+
+	// Workers is the number of workers that handle the queries of a UDP or TCP listener, 0
+	// starts a goroutine for every query. The queries wait for a worker in a queue of
+	// QueueLength, 0 is 10 per worker. When the queue is full new queries are dropped, or
+	// the oldest query in the queue if QueueDropOldest is set.
+	Workers         int
+	QueueLength     int
+	QueueDropOldest bool
+
+	// MaxUDPSize caps the buffer size advertised by clients (EDNS0), UDP responses for this
+	// zone are never larger. 0 is no cap. Clients without EDNS0 get at most 512 bytes.
+	MaxUDPSize int
+
+	// MinimalResponses drops the authority section, together with the additional section,
+	// from UDP responses that are too large, before they are truncated.
+	MinimalResponses bool
+
+	// ReusePort is the number of UDP sockets to open with SO_REUSEPORT, each has its own
+	// read loop. 0 or 1 means a single socket.
+	ReusePort int
+
+	// NoRootFallback disables sending queries that match no zone to the root zone
+	// of this listener, they are REFUSED instead.
+	NoRootFallback bool
+
+	// NoZone is what is done with the queries that match no zone of this listener:
+	// NoZoneRefused, NoZoneNXDomain or NoZoneDrop. The first zone of a listener that sets
+	// it decides.
+	NoZone int
+
+	// AA is the policy for the AA bit in responses for this zone, FlagKeep, FlagSet or FlagClear.
+	AA int
+
+	// RA is the policy for the RA bit in responses, it applies to the whole listener.
+	RA int
+
+	// Recursion is set by middleware that recursively resolve or forward queries, like
+	// proxy. It is used by the FlagAuto policy for the RA bit.
+	Recursion bool
+
+	// Tracer traces the queries for this zone, nil disables tracing. Only one in every
+	// TraceEvery queries is traced, 0 or 1 traces all of them.
+	Tracer     ot.Tracer
+	TraceEvery uint32
+	traceCount uint32
+
+	// ACL restricts which clients may query this zone, nil allows everyone.
+	ACL *ACL
+
+	// Cookie enables DNS cookies for this zone, nil disables them.
+	Cookie *Cookie
+
 	// Middleware stack.
 	Middleware []middleware.Middleware
 
 	// Compiled middleware stack.
 	middlewareChain middleware.Handler
+
+	// The compiled middleware stack from each middleware onwards, keyed by middleware name.
+	handlers map[string]middleware.Handler
+
+	// The server block this config was created for and its key, for error messages.
+	block int
+	key   string
+
+	// The directives of the server block, see Running.
+	directives []DirectiveInfo
+
+	// Hooks registered by the middleware, they run once, even if the config is used by
+	// more than one server.
+	startupHooks  []func() error
+	orderedHooks  []startupHook
+	shutdownHooks []func() error
+	startupOnce   sync.Once
+	shutdownOnce  sync.Once
+	stopRetries   chan struct{} // closed on shutdown, stops the retries of failed startup hooks, see retryStop
+	retriesOnce   sync.Once
+
+	// Handlers for panics of the middleware, see OnPanic.
+	panicHandlers []func(Panic)
+
+	// Hooks for the changes in the data of the backends, see OnChange.
+	changeHooks []func()
+
+	// Hooks that get the addresses of the listeners, see OnListening.
+	listenHooks []func(tcp, udp net.Addr)
+
+	// Providers of the labels of the queries, see AddMetadata.
+	metadata []metadata.Provider
+}
+
+// String returns the server block of c, as used in error messages.
+func (c *Config) String() string {
+	return fmt.Sprintf("server block %d (%s)", c.block+1, c.key)
 }
 
 // GetConfig gets the Config that corresponds to c.
@@ -37,3 +199,97 @@ func GetConfig(c *caddy.Controller) *Config {
 	ctx.saveConfig(c.Key, &Config{})
 	return GetConfig(c)
 }
+
+// Handler returns the compiled middleware chain of c that starts at the middleware name,
+// or nil if c has no such middleware. It can only be used once the servers are created,
+// i.e. from an OnStartup function or when handling queries.
+func (c *Config) Handler(name string) middleware.Handler {
+	return c.handlers[name]
+}
+
+// OnStartupComplete registers fn to be called when the server for this config has
+// started, also when it is started anew on a reload. Middleware should start their
+// background work, like watches, from here. When fn depends on the startup of other
+// middleware, use OnStartupAfter.
+func (c *Config) OnStartupComplete(fn func() error) {
+	c.startupHooks = append(c.startupHooks, fn)
+}
+
+// OnShutdown registers fn to be called when the server for this config is stopped,
+// also when it is replaced on a reload. Middleware should stop their background work
+// and flush their state from here.
+func (c *Config) OnShutdown(fn func() error) {
+	c.shutdownHooks = append(c.shutdownHooks, fn)
+}
+
+// OnPanic registers fn to be called when the middleware of this config panic while
+// handling a query. The client gets a SERVFAIL, fn can report the panic.
+func (c *Config) OnPanic(fn func(Panic)) {
+	c.panicHandlers = append(c.panicHandlers, fn)
+}
+
+// OnChange registers fn to be called when a backend of this config reports, with Changed,
+// that its data changed. Middleware that keep data derived from other middleware use it
+// to recompute that data. It must be called from the setup function.
+func (c *Config) OnChange(fn func()) {
+	c.changeHooks = append(c.changeHooks, fn)
+}
+
+// Changed calls the functions registered with OnChange. Backends call it when their data
+// changes, it may be called at any time after setup.
+func (c *Config) Changed() {
+	for _, fn := range c.changeHooks {
+		fn()
+	}
+}
+
+// AddMetadata registers p to set the labels of every query to this config, before the
+// query is handed to the middleware. The labels are available to all middleware, in the
+// context, see package metadata.
+func (c *Config) AddMetadata(p metadata.Provider) {
+	c.metadata = append(c.metadata, p)
+}
+
+// OnListening registers fn to be called when the listeners of a server for this config
+// are bound, with their addresses. With port 0 these have the ports the kernel picked.
+// udp is nil for transports that only use TCP. For a config that is served on more than
+// one address, fn is called for each of them.
+func (c *Config) OnListening(fn func(tcp, udp net.Addr)) {
+	c.listenHooks = append(c.listenHooks, fn)
+}
+
+func (c *Config) startup() {
+	c.startupOnce.Do(func() {
+		runHooks(c.Zone, "startup", c.startupHooks)
+
+		hooks, err := startupOrder(c.orderedHooks)
+		if err != nil {
+			// Checked when the servers are made, but AddZone doesn't.
+			log.Printf("[ERROR] startup hooks for %s: %s", c.Zone, err)
+			hooks = c.orderedHooks
+		}
+		timeout := c.StartupTimeout
+		if timeout == 0 {
+			timeout = defaultStartupTimeout
+		}
+		c.runOrdered(hooks, timeout)
+	})
+}
+
+func (c *Config) shutdown() {
+	c.shutdownOnce.Do(func() {
+		close(c.retryStop())
+		// Wait for the startup hooks that are running, and don't start them after this.
+		c.startupOnce.Do(func() {})
+		runHooks(c.Zone, "shutdown", c.shutdownHooks)
+	})
+}
+
+// runHooks calls all hooks, errors are logged.
+func runHooks(zone, what string, hooks []func() error) {
+	for _, fn := range hooks {
+		if err := fn(); err != nil {
+			log.Printf("[ERROR] %s hook for %s: %s", what, zone, err)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package dnsserver
+
+import (
+	"fmt"
+	"net"
+	"sort"
+)
+
+// checkListeners checks the listen addresses of the groups, as returned by
+// groupConfigsByListenAddr, for overlaps. The wildcard address can only be bound once
+// per transport and port. When a specific address is bound as well, the operating
+// system hands the queries for it to that listener, the zones on the wildcard listener
+// are not reachable on it. This is an error, unless the zone has Override set, or a zone
+// on the specific address has. Unix sockets don't overlap, each path is a group.
+func checkListeners(groups map[string][]*Config) error {
+	addrs := make([]string, 0, len(groups))
+	for addr := range groups {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+
+	// wildcard listener per transport and port
+	wildcards := map[string]string{}
+	for _, addr := range addrs {
+		trans, host, port := splitListenAddr(addr)
+		if trans == TransportUnix || !isWildcardHost(host) {
+			continue
+		}
+		k := trans + "://" + port
+		if w, ok := wildcards[k]; ok {
+			return fmt.Errorf("%s binds %s and %s binds %s, these are the same address", groups[w][0], w, groups[addr][0], addr)
+		}
+		wildcards[k] = addr
+	}
+
+	for _, addr := range addrs {
+		trans, host, port := splitListenAddr(addr)
+		if trans == TransportUnix || isWildcardHost(host) {
+			continue
+		}
+		w, ok := wildcards[trans+"://"+port]
+		if !ok {
+			continue
+		}
+		for _, c := range groups[w] {
+			if c.Override || overrides(groups[addr]) || served(groups[addr], c) {
+				continue
+			}
+			return fmt.Errorf("zone %s of %s is not reachable on %s, %s binds that address; use override to allow this", c.Zone, c, addr, groups[addr][0])
+		}
+	}
+	return nil
+}
+
+// overrides returns true if any of the configs has Override set.
+func overrides(configs []*Config) bool {
+	for _, c := range configs {
+		if c.Override {
+			return true
+		}
+	}
+	return false
+}
+
+// served returns true if c itself is one of the configs, it is bound to both addresses.
+func served(configs []*Config, c *Config) bool {
+	for _, s := range configs {
+		if s == c {
+			return true
+		}
+	}
+	return false
+}
+
+// splitListenAddr splits a listen address as used by groupConfigsByListenAddr.
+func splitListenAddr(addr string) (trans, host, port string) {
+	trans, addr = Transport(addr)
+	host, port, _ = net.SplitHostPort(addr)
+	return trans, host, port
+}
+
+// isWildcardHost returns true if host is empty or the unspecified address.
+func isWildcardHost(host string) bool {
+	if host == "" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsUnspecified()
+}
@@ -0,0 +1,80 @@
+package dnsserver
+
+import "testing"
+
+func TestCheckListeners(t *testing.T) {
+	tests := []struct {
+		configs   []*Config
+		shouldErr bool
+	}{
+		// different addresses, no overlap
+		{[]*Config{
+			{Zone: "example.org.", Port: "53", ListenHosts: []string{"127.0.0.1"}},
+			{Zone: "example.net.", Port: "53", ListenHosts: []string{"::1"}},
+		}, false},
+		// wildcard and a specific address on different ports
+		{[]*Config{
+			{Zone: "example.org.", Port: "53"},
+			{Zone: "example.net.", Port: "1053", ListenHosts: []string{"127.0.0.1"}},
+		}, false},
+		// the same zone on the wildcard and a specific address
+		{[]*Config{
+			{Zone: "example.org.", Port: "53", ListenHosts: []string{"0.0.0.0", "127.0.0.1"}},
+		}, false},
+		// wildcard zone is shadowed on 127.0.0.1
+		{[]*Config{
+			{Zone: "example.org.", Port: "53"},
+			{Zone: "example.net.", Port: "53", ListenHosts: []string{"127.0.0.1"}},
+		}, true},
+		// but not on another transport
+		{[]*Config{
+			{Zone: "example.org.", Port: "53", Transport: TransportHTTPS},
+			{Zone: "example.net.", Port: "53", ListenHosts: []string{"127.0.0.1"}},
+		}, false},
+		// shadowing is allowed with override
+		{[]*Config{
+			{Zone: "example.org.", Port: "53", Override: true},
+			{Zone: "example.net.", Port: "53", ListenHosts: []string{"127.0.0.1"}},
+		}, false},
+		{[]*Config{
+			{Zone: "example.org.", Port: "53"},
+			{Zone: "example.net.", Port: "53", ListenHosts: []string{"127.0.0.1"}, Override: true},
+		}, false},
+		// override on an unrelated listener doesn't count
+		{[]*Config{
+			{Zone: "example.org.", Port: "53"},
+			{Zone: "example.net.", Port: "53", ListenHosts: []string{"127.0.0.1"}},
+			{Zone: "example.com.", Port: "53", ListenHosts: []string{"::1"}, Override: true},
+		}, true},
+		// Unix sockets next to the wildcard address
+		{[]*Config{
+			{Zone: "example.org.", Port: "53"},
+			{Zone: "example.net.", Port: "53", UnixSocket: "/run/a.sock"},
+			{Zone: "example.com.", Port: "53", UnixSocket: "/run/b.sock"},
+		}, false},
+		// the wildcard addresses are a single listener
+		{[]*Config{
+			{Zone: "example.org.", Port: "53"},
+			{Zone: "example.net.", Port: "53", ListenHosts: []string{"::"}},
+		}, false},
+		// the wildcard twice, on another transport
+		{[]*Config{
+			{Zone: "example.org.", Port: "53", Transport: TransportHTTPS},
+			{Zone: "example.net.", Port: "53", ListenHosts: []string{"0.0.0.0"}, Transport: TransportHTTPS},
+		}, false},
+	}
+
+	for i, tc := range tests {
+		groups, err := groupConfigsByListenAddr(tc.configs)
+		if err != nil {
+			t.Fatalf("Test %d: expected no error grouping, got %s", i, err)
+		}
+		err = checkListeners(groups)
+		if tc.shouldErr && err == nil {
+			t.Errorf("Test %d: expected error, got none", i)
+		}
+		if !tc.shouldErr && err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package dnsserver
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/miekg/coredns/request"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+// Cookie implements server side DNS cookies (RFC 7873). Clients that send a client cookie get
+// a server cookie in the response, which they return in the next queries. A valid server cookie
+// proves the client saw an earlier response, so the source address of the query is not spoofed.
+//
+// The server cookies follow RFC 9018: a version, a timestamp and a hash of the client cookie,
+// the client IP and the timestamp, keyed with a secret. Servers that share the secret accept
+// each others cookies. The hash is a truncated HMAC-SHA256.
+type Cookie struct {
+	secret []byte
+
+	// Require makes a valid server cookie mandatory for UDP queries. Clients with only a client
+	// cookie get BADCOOKIE with a fresh server cookie, clients without any cookie get an empty
+	// truncated response, so they retry over TCP.
+	Require bool
+
+	// MaxUnverifiedSize is the maximum size of UDP responses to clients without a valid server
+	// cookie, larger responses are truncated. 0 is no limit.
+	MaxUnverifiedSize int
+}
+
+// NewCookie returns a Cookie that uses secret, a hex encoded 16 byte string. An empty
+// secret is replaced by a random one.
+func NewCookie(secret string) (*Cookie, error) {
+	if secret == "" {
+		b := make([]byte, cookieSecretLen)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		return &Cookie{secret: b}, nil
+	}
+	b, err := hex.DecodeString(secret)
+	if err != nil || len(b) != cookieSecretLen {
+		return nil, fmt.Errorf("cookie secret must be %d hex encoded bytes: %s", cookieSecretLen, secret)
+	}
+	return &Cookie{secret: b}, nil
+}
+
+// serverCookie returns the server cookie for client, a client cookie, and ip, minted at t.
+func (c *Cookie) serverCookie(client []byte, ip net.IP, t time.Time) []byte {
+	sc := make([]byte, 8, 16)
+	sc[0] = 1 // version, the next 3 bytes are reserved
+	binary.BigEndian.PutUint32(sc[4:], uint32(t.Unix()))
+
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write(client)
+	mac.Write(sc)
+	mac.Write(ip)
+	return mac.Sum(sc)[:16]
+}
+
+// valid returns true if server is a server cookie we minted, not too long ago, for client
+// and ip.
+func (c *Cookie) valid(client, server []byte, ip net.IP, now time.Time) bool {
+	if len(server) != 16 || server[0] != 1 {
+		return false
+	}
+	t := time.Unix(int64(binary.BigEndian.Uint32(server[4:8])), 0)
+	if t.Before(now.Add(-cookieLifetime)) || t.After(now.Add(cookieSkew)) {
+		return false
+	}
+	return hmac.Equal(server, c.serverCookie(client, ip, t))
+}
+
+// parseCookie returns the client and server cookie in r. It returns an error if the cookie
+// option is malformed. Both are nil if r has no cookie.
+func parseCookie(r *dns.Msg) (client, server []byte, err error) {
+	opt := r.IsEdns0()
+	if opt == nil {
+		return nil, nil, nil
+	}
+	for _, o := range opt.Option {
+		e, ok := o.(*dns.EDNS0_COOKIE)
+		if !ok {
+			continue
+		}
+		b, err := hex.DecodeString(e.Cookie)
+		// 8 bytes client cookie, optionally followed by an 8 to 32 byte server cookie
+		if err != nil || len(b) < 8 || (len(b) > 8 && len(b) < 16) || len(b) > 40 {
+			return nil, nil, fmt.Errorf("malformed cookie: %q", e.Cookie)
+		}
+		if len(b) > 8 {
+			server = b[8:]
+		}
+		return b[:8], server, nil
+	}
+	return nil, nil, nil
+}
+
+type cookieKey struct{}
+
+// ValidCookie returns true if the query in ctx carried a valid server cookie. Middleware can
+// use it to only do expensive work for clients whose address is verified.
+func ValidCookie(ctx context.Context) bool {
+	v, _ := ctx.Value(cookieKey{}).(bool)
+	return v
+}
+
+// checkCookie handles the cookie of r for zone h. It returns the context and writer to use
+// for the middleware, or false if a response was already written.
+func checkCookie(ctx context.Context, h *Config, w dns.ResponseWriter, r *dns.Msg) (context.Context, dns.ResponseWriter, bool) {
+	c := h.Cookie
+	client, server, err := parseCookie(r)
+	if err != nil {
+		cookieCount.WithLabelValues(h.Zone, "malformed").Inc()
+		DefaultErrorFunc(w, r, dns.RcodeFormatError)
+		return ctx, w, false
+	}
+
+	ip := remoteIP(w.RemoteAddr())
+	valid := server != nil && c.valid(client, server, ip, time.Now())
+	switch {
+	case valid:
+		cookieCount.WithLabelValues(h.Zone, "valid").Inc()
+	case client == nil:
+		cookieCount.WithLabelValues(h.Zone, "none").Inc()
+	default:
+		cookieCount.WithLabelValues(h.Zone, "invalid").Inc()
+	}
+	ctx = context.WithValue(ctx, cookieKey{}, valid)
+
+	udp := request.Proto(w) == "udp"
+	if udp && !valid && c.MaxUnverifiedSize > 0 {
+		w = &truncateResponseWriter{ResponseWriter: w, req: r, maxSize: c.MaxUnverifiedSize, minimal: h.MinimalResponses}
+	}
+	if client != nil {
+		w = &cookieResponseWriter{ResponseWriter: w, cookie: c, client: client, ip: ip}
+	}
+
+	if !udp || valid || !c.Require {
+		return ctx, w, true
+	}
+	if client != nil {
+		DefaultErrorFunc(w, r, dns.RcodeBadCookie)
+		return ctx, w, false
+	}
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Truncated = true
+	state := request.Request{W: w, Req: r}
+	state.SizeAndDo(m)
+	w.WriteMsg(m)
+	return ctx, w, false
+}
+
+// cookieResponseWriter adds a fresh server cookie to the responses written to it.
+type cookieResponseWriter struct {
+	dns.ResponseWriter
+	cookie *Cookie
+	client []byte
+	ip     net.IP
+}
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (w *cookieResponseWriter) WriteMsg(res *dns.Msg) error {
+	cookie := &dns.EDNS0_COOKIE{
+		Code:   dns.EDNS0COOKIE,
+		Cookie: hex.EncodeToString(w.client) + hex.EncodeToString(w.cookie.serverCookie(w.client, w.ip, time.Now())),
+	}
+
+	// The OPT record may be shared with the request or a cache, add the cookie to a copy.
+	extra := make([]dns.RR, 0, len(res.Extra)+1)
+	var opt *dns.OPT
+	for _, rr := range res.Extra {
+		if o, ok := rr.(*dns.OPT); ok {
+			opt = &dns.OPT{Hdr: o.Hdr}
+			for _, e := range o.Option {
+				if e.Option() != dns.EDNS0COOKIE {
+					opt.Option = append(opt.Option, e)
+				}
+			}
+			rr = opt
+		}
+		extra = append(extra, rr)
+	}
+	if opt == nil {
+		opt = &dns.OPT{Hdr: dns.RR_Header{Name: ".", Rrtype: dns.TypeOPT}}
+		opt.SetUDPSize(dns.MinMsgSize)
+		extra = append(extra, opt)
+	}
+	opt.Option = append(opt.Option, cookie)
+
+	m := *res
+	m.Extra = extra
+	return w.ResponseWriter.WriteMsg(&m)
+}
+
+// Write implements the dns.ResponseWriter interface.
+func (w *cookieResponseWriter) Write(buf []byte) (int, error) {
+	m := new(dns.Msg)
+	if err := m.Unpack(buf); err != nil {
+		return 0, err
+	}
+	return len(buf), w.WriteMsg(m)
+}
+
+const (
+	cookieSecretLen = 16
+	// Server cookies are valid for an hour, and may be minted a little in the future by
+	// another server that shares the secret.
+	cookieLifetime = time.Hour
+	cookieSkew     = 5 * time.Minute
+)
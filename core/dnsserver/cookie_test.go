@@ -0,0 +1,123 @@
+package dnsserver
+
+import (
+	"encoding/hex"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/coredns/middleware"
+	"github.com/miekg/coredns/middleware/pkg/dnsrecorder"
+	"github.com/miekg/coredns/middleware/test"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+func TestCookieValid(t *testing.T) {
+	c, err := NewCookie("000102030405060708090a0b0c0d0e0f")
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	client := []byte("clientck")
+	ip := net.ParseIP("10.240.0.1")
+	now := time.Now()
+	server := c.serverCookie(client, ip, now)
+
+	tests := []struct {
+		client []byte
+		ip     net.IP
+		now    time.Time
+		valid  bool
+	}{
+		{client, ip, now, true},
+		{client, ip.To4(), now, true},
+		{client, ip, now.Add(50 * time.Minute), true},
+		{client, ip, now.Add(2 * time.Hour), false},
+		{client, ip, now.Add(-10 * time.Minute), false},
+		{[]byte("otherone"), ip, now, false},
+		{client, net.ParseIP("10.240.0.2"), now, false},
+	}
+	for i, tc := range tests {
+		if v := c.valid(tc.client, server, tc.ip, tc.now); v != tc.valid {
+			t.Errorf("Test %d: expected valid to be %t, got %t", i, tc.valid, v)
+		}
+	}
+
+	other, _ := NewCookie("")
+	if other.valid(client, server, ip, now) {
+		t.Errorf("Expected cookie to be invalid with another secret")
+	}
+	if _, err := NewCookie("0001"); err == nil {
+		t.Errorf("Expected error for short secret, got none")
+	}
+}
+
+func TestServeCookie(t *testing.T) {
+	cookie, _ := NewCookie("")
+	client := "0102030405060708"
+	server := hex.EncodeToString(cookie.serverCookie([]byte{1, 2, 3, 4, 5, 6, 7, 8}, net.ParseIP("10.240.0.1"), time.Now()))
+
+	tests := []struct {
+		cookie        string // client cookie option, "" is none
+		require       bool
+		expectedRcode int
+		expectedTC    bool
+		expectedValid bool
+	}{
+		{"", false, dns.RcodeSuccess, false, false},
+		{client, false, dns.RcodeSuccess, false, false},
+		{client + server, false, dns.RcodeSuccess, false, true},
+		{client + "0000000000000000", false, dns.RcodeSuccess, false, false},
+		{client + server, true, dns.RcodeSuccess, false, true},
+		{client, true, dns.RcodeBadCookie, false, false},
+		{"", true, dns.RcodeSuccess, true, false},
+		{"0102", false, dns.RcodeFormatError, false, false},
+	}
+
+	for i, tc := range tests {
+		valid := false
+		check := func(next middleware.Handler) middleware.Handler {
+			return middleware.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+				valid = ValidCookie(ctx)
+				return rootHandler(nil).ServeDNS(ctx, w, r)
+			})
+		}
+		cookie.Require = tc.require
+		s, err := NewServer("127.0.0.1:53", []*Config{
+			{Zone: "example.org.", Port: "53", Cookie: cookie, Middleware: []middleware.Middleware{check}},
+		})
+		if err != nil {
+			t.Fatalf("Test %d: failed to create server: %s", i, err)
+		}
+
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		m.SetEdns0(4096, false)
+		if tc.cookie != "" {
+			o := m.IsEdns0()
+			o.Option = append(o.Option, &dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: tc.cookie})
+		}
+		rec := dnsrecorder.New(&test.ResponseWriter{})
+		s.ServeDNS(rec, m)
+
+		if rec.Rcode != tc.expectedRcode {
+			t.Errorf("Test %d: expected rcode %d, got %d", i, tc.expectedRcode, rec.Rcode)
+		}
+		if rec.Msg.Truncated != tc.expectedTC {
+			t.Errorf("Test %d: expected TC to be %t", i, tc.expectedTC)
+		}
+		if valid != tc.expectedValid {
+			t.Errorf("Test %d: expected the cookie to be valid: %t", i, tc.expectedValid)
+		}
+
+		// Every response to a client cookie carries a new server cookie.
+		if len(tc.cookie) < 16 || tc.expectedRcode == dns.RcodeFormatError {
+			continue
+		}
+		got, _, _ := parseCookie(rec.Msg)
+		if hex.EncodeToString(got) != client {
+			t.Errorf("Test %d: expected client cookie %s in the response, got %x", i, client, got)
+		}
+	}
+}
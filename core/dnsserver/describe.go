@@ -0,0 +1,231 @@
+package dnsserver
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mholt/caddy"
+	"github.com/mholt/caddy/caddyfile"
+)
+
+// The configuration that is running, or that a Corefile would run, can be described as
+// JSON, so automation can check it matches what was intended.
+
+// ServerInfo describes a listener and the zones it serves.
+type ServerInfo struct {
+	Address string     `json:"address"`
+	Zones   []ZoneInfo `json:"zones"`
+}
+
+// ZoneInfo describes the configuration of a zone: its address, the settings of the server
+// it ends up with and its directives. Settings that are not set are omitted, they have their
+// default value.
+type ZoneInfo struct {
+	Zone        string          `json:"zone"`
+	Transport   string          `json:"transport"`
+	Port        string          `json:"port"`
+	ListenHosts []string        `json:"listen_hosts,omitempty"`
+	Settings    ZoneSettings    `json:"settings"`
+	Directives  []DirectiveInfo `json:"directives"`
+}
+
+// ZoneSettings are the settings of a zone that directives set in its Config.
+type ZoneSettings struct {
+	Override         bool   `json:"override,omitempty"`
+	MaxConns         int    `json:"max_conns,omitempty"`
+	MaxConnsPerIP    int    `json:"max_conns_per_ip,omitempty"`
+	ReadTimeout      string `json:"read_timeout,omitempty"`
+	WriteTimeout     string `json:"write_timeout,omitempty"`
+	IdleTimeout      string `json:"idle_timeout,omitempty"`
+	QueryTimeout     string `json:"query_timeout,omitempty"`
+	MaxCNAMEDepth    int    `json:"max_cname_depth,omitempty"`
+	StartupTimeout   string `json:"startup_timeout,omitempty"`
+	Timeout          string `json:"timeout,omitempty"`
+	MaxConcurrent    int    `json:"max_concurrent,omitempty"`
+	OverloadDrop     bool   `json:"overload_drop,omitempty"`
+	Workers          int    `json:"workers,omitempty"`
+	QueueLength      int    `json:"queue_length,omitempty"`
+	QueueDropOldest  bool   `json:"queue_drop_oldest,omitempty"`
+	MaxUDPSize       int    `json:"max_udp_size,omitempty"`
+	MinimalResponses bool   `json:"minimal_responses,omitempty"`
+	ReusePort        int    `json:"reuseport,omitempty"`
+	NoRootFallback   bool   `json:"no_root_fallback,omitempty"`
+	NoZone           string `json:"no_zone,omitempty"`
+	TLS              bool   `json:"tls,omitempty"`
+	JSONAPI          bool   `json:"json_api,omitempty"`
+	ACL              bool   `json:"acl,omitempty"`
+	Cookie           bool   `json:"cookie,omitempty"`
+	Tracing          bool   `json:"tracing,omitempty"`
+	Recursion        bool   `json:"recursion,omitempty"`
+}
+
+// DirectiveInfo is a directive of a zone with its lines from the Corefile, in the order
+// they are executed. The lines are normalized: comments and extra whitespace are removed.
+type DirectiveInfo struct {
+	Name  string   `json:"name"`
+	Lines []string `json:"lines"`
+}
+
+// Running returns the description of the servers that are running, sorted by address.
+func Running() []ServerInfo {
+	running.Lock()
+	defer running.Unlock()
+	infos := make([]ServerInfo, 0, len(running.servers))
+	for s := range running.servers {
+		infos = append(infos, describeServer(serverAddress(s), configs(s.zoneMap())))
+	}
+	sort.Sort(byAddress(infos))
+	return infos
+}
+
+// DescribeCorefile returns the description of the servers corefile would run, without
+// starting them. The directives are executed, so errors in corefile are returned.
+func DescribeCorefile(corefile caddy.Input) ([]ServerInfo, error) {
+	if err := caddy.ValidateAndExecuteDirectives(corefile, nil, true); err != nil {
+		return nil, err
+	}
+	lastContext.Lock()
+	ctx := lastContext.ctx
+	lastContext.Unlock()
+
+	groups, err := groupConfigsByListenAddr(ctx.configs)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]ServerInfo, 0, len(groups))
+	for addr, group := range groups {
+		infos = append(infos, describeServer(addr, group))
+	}
+	sort.Sort(byAddress(infos))
+	return infos, nil
+}
+
+func describeServer(addr string, group []*Config) ServerInfo {
+	info := ServerInfo{Address: addr, Zones: make([]ZoneInfo, 0, len(group))}
+	for _, c := range group {
+		info.Zones = append(info.Zones, c.describe())
+	}
+	sort.Sort(byZone(info.Zones))
+	return info
+}
+
+func (c *Config) describe() ZoneInfo {
+	return ZoneInfo{
+		Zone:        c.Zone,
+		Transport:   c.Transport,
+		Port:        c.Port,
+		ListenHosts: c.ListenHosts,
+		Directives:  c.directives,
+		Settings: ZoneSettings{
+			Override:         c.Override,
+			MaxConns:         c.MaxConns,
+			MaxConnsPerIP:    c.MaxConnsPerIP,
+			ReadTimeout:      duration(c.ReadTimeout),
+			WriteTimeout:     duration(c.WriteTimeout),
+			IdleTimeout:      duration(c.IdleTimeout),
+			QueryTimeout:     duration(c.QueryTimeout),
+			MaxCNAMEDepth:    c.MaxCNAMEDepth,
+			StartupTimeout:   duration(c.StartupTimeout),
+			Timeout:          duration(c.Timeout),
+			MaxConcurrent:    c.MaxConcurrent,
+			OverloadDrop:     c.OverloadDrop,
+			Workers:          c.Workers,
+			QueueLength:      c.QueueLength,
+			QueueDropOldest:  c.QueueDropOldest,
+			MaxUDPSize:       c.MaxUDPSize,
+			MinimalResponses: c.MinimalResponses,
+			ReusePort:        c.ReusePort,
+			NoRootFallback:   c.NoRootFallback,
+			NoZone:           noZoneNames[c.NoZone],
+			TLS:              c.TLSConfig != nil,
+			JSONAPI:          c.JSONAPI,
+			ACL:              c.ACL != nil,
+			Cookie:           c.Cookie != nil,
+			Tracing:          c.Tracer != nil,
+			Recursion:        c.Recursion,
+		},
+	}
+}
+
+// describeDirectives returns the directives in tokens, in the order they are executed.
+func describeDirectives(tokens map[string][]caddyfile.Token) []DirectiveInfo {
+	infos := []DirectiveInfo{}
+	for _, d := range directives {
+		toks, ok := tokens[d]
+		if !ok {
+			continue
+		}
+		info := DirectiveInfo{Name: d}
+		line, words := 0, []string{}
+		for _, t := range toks {
+			if t.Line != line && len(words) > 0 {
+				info.Lines = append(info.Lines, strings.Join(words, " "))
+				words = words[:0]
+			}
+			line = t.Line
+			words = append(words, t.Text)
+		}
+		if len(words) > 0 {
+			info.Lines = append(info.Lines, strings.Join(words, " "))
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+func duration(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return d.String()
+}
+
+// configs returns the configs in zones.
+func configs(zones map[string]*Config) []*Config {
+	cs := make([]*Config, 0, len(zones))
+	for _, c := range zones {
+		cs = append(cs, c)
+	}
+	return cs
+}
+
+// serverAddress returns the address of s, prefixed with the transport for transports other
+// than dns, as it is when the servers are made.
+func serverAddress(s *Server) string {
+	// All zones of a server have the same transport.
+	for _, c := range s.zoneMap() {
+		if c.UnixSocket != "" {
+			return TransportUnix + "://" + s.Addr
+		}
+		if c.Transport != "" && c.Transport != TransportDNS {
+			return c.Transport + "://" + s.Addr
+		}
+	}
+	return s.Addr
+}
+
+// running holds the servers that have started and not stopped yet.
+var running = struct {
+	sync.Mutex
+	servers map[*Server]bool
+}{servers: make(map[*Server]bool)}
+
+// lastContext is the context of the last Corefile that was loaded.
+var lastContext struct {
+	sync.Mutex
+	ctx *dnsContext
+}
+
+type byAddress []ServerInfo
+
+func (b byAddress) Len() int           { return len(b) }
+func (b byAddress) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byAddress) Less(i, j int) bool { return b[i].Address < b[j].Address }
+
+type byZone []ZoneInfo
+
+func (b byZone) Len() int           { return len(b) }
+func (b byZone) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byZone) Less(i, j int) bool { return b[i].Zone < b[j].Zone }
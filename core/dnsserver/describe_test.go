@@ -0,0 +1,52 @@
+package dnsserver
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mholt/caddy/caddyfile"
+)
+
+func TestDescribeDirectives(t *testing.T) {
+	tokens := map[string][]caddyfile.Token{
+		"proxy": {
+			{Text: "proxy", Line: 4}, {Text: ".", Line: 4}, {Text: "8.8.8.8:53", Line: 4}, {Text: "{", Line: 4},
+			{Text: "except", Line: 5}, {Text: "miek.nl.", Line: 5},
+			{Text: "}", Line: 6},
+			{Text: "proxy", Line: 7}, {Text: "example.org", Line: 7}, {Text: "10.0.0.1", Line: 7},
+		},
+		"log": {{Text: "log", Line: 3}},
+	}
+	expected := []DirectiveInfo{
+		{Name: "log", Lines: []string{"log"}},
+		{Name: "proxy", Lines: []string{"proxy . 8.8.8.8:53 {", "except miek.nl.", "}", "proxy example.org 10.0.0.1"}},
+	}
+	if x := describeDirectives(tokens); !reflect.DeepEqual(x, expected) {
+		t.Errorf("Expected directives %v, got %v", expected, x)
+	}
+}
+
+func TestRunning(t *testing.T) {
+	c := &Config{Zone: "example.org.", Port: "53", Transport: TransportDNS, QueryTimeout: 3 * time.Second,
+		directives: []DirectiveInfo{{Name: "whoami", Lines: []string{"whoami"}}}}
+	s, err := NewServer("127.0.0.1:53", []*Config{c})
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	s.startupHooks()
+	infos := Running()
+	if len(infos) != 1 || infos[0].Address != "127.0.0.1:53" || len(infos[0].Zones) != 1 {
+		t.Fatalf("Expected the server on 127.0.0.1:53 with one zone, got %v", infos)
+	}
+	z := infos[0].Zones[0]
+	if z.Zone != "example.org." || z.Settings.QueryTimeout != "3s" || len(z.Directives) != 1 {
+		t.Errorf("Expected the description of example.org., got %v", z)
+	}
+
+	s.shutdownHooks()
+	if infos := Running(); len(infos) != 0 {
+		t.Errorf("Expected no running servers after shutdown, got %v", infos)
+	}
+}
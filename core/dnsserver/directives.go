@@ -73,24 +73,51 @@ func RegisterDevDirective(name, before string) {
 // (after) them during a request, but they must not
 // care what middleware above them are doing.
 var directives = []string{
+	"tls",
 	"bind",
+	"unix",
+	"override",
+	"limits",
+	"startup",
+	"reuseport",
+	"doh",
+	"fallback",
+	"acl",
+	"cookie",
+	"edns",
+	"flags",
+	"timeout",
+	"truncate",
+	"trace",
 	"health",
+	"admin",
 	"pprof",
 
 	"prometheus",
 	"errors",
 	"log",
+	"rrl",
+	"garbage",
+	"audit",
+	"delay",
+	"local",
 	"chaos",
 	"cache",
+	"probe",
+	"rebind",
 
 	"rewrite",
+	"route",
 	"loadbalance",
 
 	"dnssec",
+	"alias",
 	"file",
 	"secondary",
 	"etcd",
 	"kubernetes",
+	"stub",
+	"forward",
 	"proxy",
 	"whoami",
 }
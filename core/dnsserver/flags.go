@@ -0,0 +1,48 @@
+package dnsserver
+
+import "github.com/miekg/dns"
+
+// Policies for the AA and RA bits in responses.
+const (
+	// FlagKeep leaves the bit as the middleware set it.
+	FlagKeep = iota
+	// FlagSet always sets the bit.
+	FlagSet
+	// FlagClear always clears the bit.
+	FlagClear
+	// FlagAuto sets the RA bit only if a zone on the listener does recursion or
+	// forwarding (Config.Recursion). Not valid for the AA bit.
+	FlagAuto
+)
+
+// flagResponseWriter enforces the AA and RA bit policies on the responses written to it.
+type flagResponseWriter struct {
+	dns.ResponseWriter
+	aa, ra int
+}
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (w *flagResponseWriter) WriteMsg(res *dns.Msg) error {
+	switch w.aa {
+	case FlagSet:
+		res.Authoritative = true
+	case FlagClear:
+		res.Authoritative = false
+	}
+	switch w.ra {
+	case FlagSet:
+		res.RecursionAvailable = true
+	case FlagClear:
+		res.RecursionAvailable = false
+	}
+	return w.ResponseWriter.WriteMsg(res)
+}
+
+// flagWriter returns w wrapped so the responses follow the AA policy of zone h and the
+// RA policy of s. If there is nothing to enforce w is returned.
+func (s *Server) flagWriter(h *Config, w dns.ResponseWriter) dns.ResponseWriter {
+	if h.AA == FlagKeep && s.ra == FlagKeep {
+		return w
+	}
+	return &flagResponseWriter{ResponseWriter: w, aa: h.AA, ra: s.ra}
+}
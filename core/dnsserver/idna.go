@@ -0,0 +1,21 @@
+package dnsserver
+
+import "github.com/miekg/dns"
+
+// idnaResponseWriter puts the original name back in the question section of the
+// reply, after the query has been handled with the punycode form of the name.
+type idnaResponseWriter struct {
+	dns.ResponseWriter
+	name string
+}
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (w *idnaResponseWriter) WriteMsg(res *dns.Msg) error {
+	if len(res.Question) > 0 {
+		q := res.Question[0]
+		q.Name = w.name
+		// Don't change the question in place, res may be shared.
+		res.Question = []dns.Question{q}
+	}
+	return w.ResponseWriter.WriteMsg(res)
+}
@@ -0,0 +1,204 @@
+package dnsserver
+
+import (
+	"crypto/tls"
+	"net"
+	"sync"
+	"time"
+)
+
+// limitListener wraps a stream listener. It keeps track of the number of open
+// connections, enforces a maximum on them (in total and per client IP) and
+// exports metrics about them.
+type limitListener struct {
+	net.Listener
+	transport string // label used in the metrics
+
+	max      int // maximum number of open connections, 0 is unlimited
+	maxPerIP int // maximum number of open connections per client IP, 0 is unlimited
+
+	sync.Mutex
+	open  int
+	perIP map[string]int
+}
+
+func newLimitListener(l net.Listener, transport string, max, maxPerIP int) *limitListener {
+	return &limitListener{Listener: l, transport: transport, max: max, maxPerIP: maxPerIP, perIP: make(map[string]int)}
+}
+
+// Accept implements the net.Listener interface.
+func (l *limitListener) Accept() (net.Conn, error) {
+	for {
+		c, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		ip := hostOf(c.RemoteAddr())
+		if reason := l.acquire(ip); reason != "" {
+			connRejected.WithLabelValues(l.transport, reason).Inc()
+			c.Close()
+			continue
+		}
+
+		connOpen.WithLabelValues(l.transport).Inc()
+		return &limitConn{Conn: c, l: l, ip: ip}, nil
+	}
+}
+
+// acquire registers a new connection from ip. If that is not allowed the reason is
+// returned, otherwise the empty string.
+func (l *limitListener) acquire(ip string) string {
+	l.Lock()
+	defer l.Unlock()
+	if l.max > 0 && l.open >= l.max {
+		return "max_conns"
+	}
+	if l.maxPerIP > 0 && l.perIP[ip] >= l.maxPerIP {
+		return "max_conns_per_ip"
+	}
+	l.open++
+	l.perIP[ip]++
+	return ""
+}
+
+func (l *limitListener) release(ip string) {
+	l.Lock()
+	l.open--
+	l.perIP[ip]--
+	if l.perIP[ip] <= 0 {
+		delete(l.perIP, ip)
+	}
+	l.Unlock()
+}
+
+// limitConn is a connection accepted by a limitListener. Closing it releases its
+// slot in the listener.
+type limitConn struct {
+	net.Conn
+	l  *limitListener
+	ip string
+
+	once sync.Once
+}
+
+// Close implements the net.Conn interface.
+func (c *limitConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(func() {
+		c.l.release(c.ip)
+		connOpen.WithLabelValues(c.l.transport).Dec()
+	})
+	return err
+}
+
+// tlsListener is a TLS listener that performs the handshake of new connections in
+// the background, so a slow client does not hold up the accept loop. Only
+// connections that completed the handshake are returned from Accept; failed
+// handshakes and resumed sessions are counted. The returned connections are
+// *tls.Conns, so http.Server still recognizes them as such.
+type tlsListener struct {
+	net.Listener
+	config    *tls.Config
+	transport string
+
+	conns chan net.Conn
+	errc  chan error
+	done  chan struct{}
+	once  sync.Once
+}
+
+func newTLSListener(l net.Listener, config *tls.Config, transport string) *tlsListener {
+	t := &tlsListener{
+		Listener:  l,
+		config:    config,
+		transport: transport,
+		conns:     make(chan net.Conn),
+		errc:      make(chan error, 1),
+		done:      make(chan struct{}),
+	}
+	go t.serve()
+	return t
+}
+
+// serve accepts the connections and starts their handshakes. Temporary errors, like
+// running out of file descriptors, are retried with a backoff, as net/http does; the
+// loop ends when the listener is closed or fails.
+func (t *tlsListener) serve() {
+	var delay time.Duration
+	for {
+		c, err := t.Listener.Accept()
+		if err != nil {
+			select {
+			case <-t.done:
+				t.errc <- err
+				return
+			default:
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				if delay == 0 {
+					delay = 5 * time.Millisecond
+				} else if delay *= 2; delay > time.Second {
+					delay = time.Second
+				}
+				select {
+				case <-time.After(delay):
+				case <-t.done:
+				}
+				continue
+			}
+			t.errc <- err
+			return
+		}
+		delay = 0
+		go t.handshake(tls.Server(c, t.config))
+	}
+}
+
+func (t *tlsListener) handshake(c *tls.Conn) {
+	c.SetDeadline(time.Now().Add(handshakeTimeout))
+	if err := c.Handshake(); err != nil {
+		tlsHandshakeFailures.WithLabelValues(t.transport).Inc()
+		c.Close()
+		return
+	}
+	c.SetDeadline(time.Time{})
+	if c.ConnectionState().DidResume {
+		tlsResumed.WithLabelValues(t.transport).Inc()
+	}
+
+	select {
+	case t.conns <- c:
+	case <-t.done:
+		c.Close()
+	}
+}
+
+// Accept implements the net.Listener interface.
+func (t *tlsListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-t.conns:
+		return c, nil
+	case err := <-t.errc:
+		// Put it back for the next caller of Accept.
+		t.errc <- err
+		return nil, err
+	}
+}
+
+// Close implements the net.Listener interface.
+func (t *tlsListener) Close() error {
+	t.once.Do(func() { close(t.done) })
+	return t.Listener.Close()
+}
+
+// hostOf returns the IP address of a, without the port.
+func hostOf(a net.Addr) string {
+	host, _, err := net.SplitHostPort(a.String())
+	if err != nil {
+		return a.String()
+	}
+	return host
+}
+
+const handshakeTimeout = 5 * time.Second
@@ -0,0 +1,134 @@
+package dnsserver
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestLimitListener(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	l := newLimitListener(ln, "tcp", 2, 1)
+	defer l.Close()
+
+	accepted := make(chan net.Conn)
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- c
+		}
+	}()
+
+	// first connection is accepted
+	c1, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %s", err)
+	}
+	defer c1.Close()
+	var s1 net.Conn
+	select {
+	case s1 = <-accepted:
+	case <-time.After(time.Second):
+		t.Fatal("Expected first connection to be accepted")
+	}
+
+	// second one, from the same IP, is over the per IP limit
+	c2, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %s", err)
+	}
+	defer c2.Close()
+	select {
+	case <-accepted:
+		t.Fatal("Expected second connection to be rejected")
+	case <-time.After(100 * time.Millisecond):
+	}
+	c2.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := c2.Read(make([]byte, 1)); err == nil {
+		t.Error("Expected second connection to be closed")
+	}
+
+	// closing the first connection frees up the slot
+	s1.Close()
+	c3, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %s", err)
+	}
+	defer c3.Close()
+	select {
+	case s3 := <-accepted:
+		s3.Close()
+	case <-time.After(time.Second):
+		t.Fatal("Expected third connection to be accepted")
+	}
+}
+
+// tempErr is a temporary net.Error, like EMFILE.
+type tempErr struct{}
+
+func (tempErr) Error() string   { return "too many open files" }
+func (tempErr) Timeout() bool   { return false }
+func (tempErr) Temporary() bool { return true }
+
+// flakyListener fails Accept with a temporary error n times, then blocks until it is closed.
+type flakyListener struct {
+	net.Listener
+	n      int
+	calls  chan struct{}
+	closed chan struct{}
+}
+
+func (l *flakyListener) Accept() (net.Conn, error) {
+	l.calls <- struct{}{}
+	if l.n > 0 {
+		l.n--
+		return nil, tempErr{}
+	}
+	<-l.closed
+	return nil, errors.New("use of closed network connection")
+}
+
+func (l *flakyListener) Close() error { close(l.closed); return nil }
+
+func TestTLSListenerTemporaryError(t *testing.T) {
+	fl := &flakyListener{n: 3, calls: make(chan struct{}, 10), closed: make(chan struct{})}
+	l := newTLSListener(fl, &tls.Config{}, TransportTLS)
+
+	// The temporary errors are retried, so Accept is called once more after them.
+	for i := 0; i < 4; i++ {
+		select {
+		case <-fl.calls:
+		case <-time.After(time.Second):
+			t.Fatalf("Expected the accept loop to retry after %d temporary errors", i)
+		}
+	}
+
+	accepted := make(chan error)
+	go func() {
+		_, err := l.Accept()
+		accepted <- err
+	}()
+	select {
+	case err := <-accepted:
+		t.Fatalf("Expected Accept to block until the listener is closed, got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	l.Close()
+	select {
+	case err := <-accepted:
+		if err == nil {
+			t.Error("Expected an error from Accept on a closed listener")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected Accept to return once the listener is closed")
+	}
+}
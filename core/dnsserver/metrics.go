@@ -0,0 +1,133 @@
+package dnsserver
+
+import (
+	"github.com/miekg/coredns/middleware"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	connOpen = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: middleware.Namespace,
+		Subsystem: "listener",
+		Name:      "connections",
+		Help:      "Number of open connections per transport.",
+	}, []string{"transport"})
+
+	connRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: middleware.Namespace,
+		Subsystem: "listener",
+		Name:      "rejected_connections_total",
+		Help:      "Counter of connections that were closed because a connection limit was reached.",
+	}, []string{"transport", "reason"})
+
+	tlsHandshakeFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: middleware.Namespace,
+		Subsystem: "listener",
+		Name:      "tls_handshake_failures_total",
+		Help:      "Counter of failed TLS handshakes per transport.",
+	}, []string{"transport"})
+
+	tlsResumed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: middleware.Namespace,
+		Subsystem: "listener",
+		Name:      "tls_resumed_sessions_total",
+		Help:      "Counter of TLS connections that resumed a previous session, per transport.",
+	}, []string{"transport"})
+
+	rootFallbackCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: middleware.Namespace,
+		Subsystem: "dns",
+		Name:      "root_fallback_total",
+		Help:      "Counter of queries that matched none of the other zones and were handled by the root zone.",
+	}, []string{"server"})
+
+	startupFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: middleware.Namespace,
+		Subsystem: "startup",
+		Name:      "failures_total",
+		Help:      "Counter of failed calls of the startup hooks, per zone and middleware.",
+	}, []string{"zone", "middleware"})
+
+	noZoneCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: middleware.Namespace,
+		Subsystem: "dns",
+		Name:      "no_zone_total",
+		Help:      "Counter of queries that matched no zone and were refused, answered with NXDOMAIN or dropped.",
+	}, []string{"server"})
+
+	aclBlockedCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: middleware.Namespace,
+		Subsystem: "dns",
+		Name:      "acl_blocked_requests_total",
+		Help:      "Counter of queries that were refused by the ACL of a zone.",
+	}, []string{"zone"})
+
+	overloadCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: middleware.Namespace,
+		Subsystem: "dns",
+		Name:      "overloaded_requests_total",
+		Help:      "Counter of queries that were rejected because too many queries were in flight.",
+	}, []string{"server"})
+
+	queueDropCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: middleware.Namespace,
+		Subsystem: "dns",
+		Name:      "queue_dropped_requests_total",
+		Help:      "Counter of queries that were dropped because the queue of the workers was full.",
+	}, []string{"server", "proto"})
+
+	timeoutCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: middleware.Namespace,
+		Subsystem: "dns",
+		Name:      "timeouts_total",
+		Help:      "Counter of queries that got a SERVFAIL because the middleware did not answer in time.",
+	}, []string{"server", "zone", "middleware"})
+
+	cookieCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: middleware.Namespace,
+		Subsystem: "dns",
+		Name:      "cookies_total",
+		Help:      "Counter of queries per DNS cookie result: valid, invalid, none or malformed.",
+	}, []string{"zone", "result"})
+
+	panicCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: middleware.Namespace,
+		Subsystem: "dns",
+		Name:      "panics_total",
+		Help:      "Counter of panics of the middleware, the client got a SERVFAIL.",
+	}, []string{"server", "zone", "middleware"})
+
+	responseCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: middleware.Namespace,
+		Subsystem: "dns",
+		Name:      "responses_total",
+		Help:      "Counter of responses per zone, client protocol and rcode.",
+	}, []string{"zone", "proto", "rcode"})
+
+	responseSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: middleware.Namespace,
+		Subsystem: "dns",
+		Name:      "response_bytes",
+		Help:      "Size of the responses in bytes, per client protocol.",
+		Buckets:   []float64{0, 100, 200, 300, 400, 511, 1023, 2047, 4095, 8291, 16e3, 32e3, 48e3, 64e3},
+	}, []string{"proto"})
+)
+
+func init() {
+	prometheus.MustRegister(connOpen)
+	prometheus.MustRegister(connRejected)
+	prometheus.MustRegister(tlsHandshakeFailures)
+	prometheus.MustRegister(tlsResumed)
+	prometheus.MustRegister(rootFallbackCount)
+	prometheus.MustRegister(noZoneCount)
+	prometheus.MustRegister(startupFailures)
+	prometheus.MustRegister(aclBlockedCount)
+	prometheus.MustRegister(overloadCount)
+	prometheus.MustRegister(queueDropCount)
+	prometheus.MustRegister(timeoutCount)
+	prometheus.MustRegister(cookieCount)
+	prometheus.MustRegister(panicCount)
+	prometheus.MustRegister(responseCount)
+	prometheus.MustRegister(responseSize)
+}
@@ -0,0 +1,108 @@
+package dnsserver
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// What is done with queries that match no zone of a listener, see Config.NoZone.
+const (
+	NoZoneRefused  = iota // reply with REFUSED, the default
+	NoZoneNXDomain        // reply with NXDOMAIN
+	NoZoneDrop            // don't reply
+)
+
+// noZoneNames are the names of the policies, as in the Corefile. The default has none.
+var noZoneNames = map[int]string{NoZoneNXDomain: "nxdomain", NoZoneDrop: "drop"}
+
+// noZone handles r, which matched no zone, according to the policy of s. The log line is
+// written at most once per noZoneLogInterval per remote host; scanners on the internet send
+// these queries by the thousands.
+func (s *Server) noZone(w dns.ResponseWriter, r *dns.Msg, q string) {
+	noZoneCount.WithLabelValues(s.Addr).Inc()
+	switch s.noZonePolicy {
+	case NoZoneNXDomain:
+		DefaultErrorFunc(w, r, dns.RcodeNameError)
+	case NoZoneDrop:
+	default:
+		DefaultErrorFunc(w, r, dns.RcodeRefused)
+	}
+
+	remote := w.RemoteAddr()
+	host := remote.String()
+	switch a := remote.(type) {
+	case *net.UDPAddr:
+		host = a.IP.String()
+	case *net.TCPAddr:
+		host = a.IP.String()
+	}
+	if suppressed, ok := s.noZoneLog.allow(host, time.Now()); ok {
+		more := ""
+		if suppressed > 0 {
+			more = fmt.Sprintf(", %d more suppressed", suppressed)
+		}
+		log.Printf("[INFO] \"%s %s %s\" - No such zone at %s (Remote: %s%s)", dns.Type(r.Question[0].Qtype), dns.Class(r.Question[0].Qclass), q, s.Addr, remote, more)
+	}
+}
+
+// logLimiter allows a log line per host per interval, and counts the ones it suppresses.
+type logLimiter struct {
+	sync.Mutex
+	interval time.Duration
+	hosts    map[string]*logState
+}
+
+type logState struct {
+	last       time.Time
+	suppressed int
+}
+
+func newLogLimiter(interval time.Duration) *logLimiter {
+	return &logLimiter{interval: interval, hosts: make(map[string]*logState)}
+}
+
+// allow returns true if host may log at now, with the number of lines that were suppressed
+// since its last one.
+func (l *logLimiter) allow(host string, now time.Time) (int, bool) {
+	l.Lock()
+	defer l.Unlock()
+
+	st, ok := l.hosts[host]
+	if ok && now.Sub(st.last) < l.interval {
+		st.suppressed++
+		return 0, false
+	}
+	if !ok {
+		if len(l.hosts) >= maxLogHosts {
+			l.expire(now)
+		}
+		if len(l.hosts) >= maxLogHosts {
+			// Too many hosts at once, don't log at all rather than use unbounded memory.
+			return 0, false
+		}
+		st = &logState{}
+		l.hosts[host] = st
+	}
+	suppressed := st.suppressed
+	st.last, st.suppressed = now, 0
+	return suppressed, true
+}
+
+// expire forgets the hosts that have not logged for an interval.
+func (l *logLimiter) expire(now time.Time) {
+	for h, st := range l.hosts {
+		if now.Sub(st.last) >= l.interval {
+			delete(l.hosts, h)
+		}
+	}
+}
+
+const (
+	noZoneLogInterval = time.Minute
+	maxLogHosts       = 10000
+)
@@ -0,0 +1,56 @@
+package dnsserver
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+
+	"github.com/miekg/dns"
+)
+
+// Panic describes a panic of the middleware while handling a query.
+type Panic struct {
+	// Value is the value passed to panic.
+	Value interface{}
+	// Stack is the stack trace of the goroutine that panicked.
+	Stack []byte
+
+	// Zone is the zone that handled the query, empty if the panic happened before a zone
+	// was selected.
+	Zone string
+	// Middleware is the innermost middleware that was running, empty if unknown.
+	Middleware string
+
+	Qname  string
+	Qtype  uint16
+	Remote string
+}
+
+// String returns a one line description of p, without the stack trace.
+func (p Panic) String() string {
+	return fmt.Sprintf("panic in middleware %q of zone %q for %s %s from %s: %v", p.Middleware, p.Zone, p.Qname, dns.Type(p.Qtype), p.Remote, p.Value)
+}
+
+// panicked handles the panic rec of the middleware of zone h, running middleware name,
+// while handling r. The panic is counted and handed to the panic handlers of h, if there
+// are none it's logged. The client gets a SERVFAIL. h may be nil.
+func (s *Server) panicked(rec interface{}, h *Config, name string, w dns.ResponseWriter, r *dns.Msg) {
+	p := Panic{Value: rec, Stack: debug.Stack(), Middleware: name, Remote: w.RemoteAddr().String()}
+	if len(r.Question) > 0 {
+		p.Qname, p.Qtype = r.Question[0].Name, r.Question[0].Qtype
+	}
+	var handlers []func(Panic)
+	if h != nil {
+		p.Zone = h.Zone
+		handlers = h.panicHandlers
+	}
+	panicCount.WithLabelValues(s.Addr, p.Zone, p.Middleware).Inc()
+
+	if len(handlers) == 0 {
+		log.Printf("[ERROR] Recovered from %s\n%s", p, p.Stack)
+	}
+	for _, fn := range handlers {
+		fn(p)
+	}
+	DefaultErrorFunc(w, r, dns.RcodeServerFailure)
+}
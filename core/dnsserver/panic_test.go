@@ -0,0 +1,59 @@
+package dnsserver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/coredns/middleware"
+	"github.com/miekg/coredns/middleware/pkg/dnsrecorder"
+	"github.com/miekg/coredns/middleware/test"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+func TestServePanic(t *testing.T) {
+	panicky := func(next middleware.Handler) middleware.Handler {
+		return middleware.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+			panic("I'm a panic")
+		})
+	}
+	outer := func(next middleware.Handler) middleware.Handler { return passHandler{next} }
+
+	// With a timeout the chain runs in its own goroutine.
+	for _, timeout := range []time.Duration{0, time.Second} {
+		var p *Panic
+		cfg := &Config{Zone: "example.org.", Port: "53", Timeout: timeout, Middleware: []middleware.Middleware{outer, panicky}}
+		cfg.OnPanic(func(pa Panic) { p = &pa })
+		s, err := NewServer("127.0.0.1:53", []*Config{cfg})
+		if err != nil {
+			t.Fatalf("Failed to create server: %s", err)
+		}
+
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		rec := dnsrecorder.New(&test.ResponseWriter{})
+		s.ServeDNS(rec, m)
+
+		if rec.Rcode != dns.RcodeServerFailure {
+			t.Errorf("Timeout %s: expected SERVFAIL, got %s", timeout, dns.RcodeToString[rec.Rcode])
+		}
+		if p == nil {
+			t.Fatalf("Timeout %s: expected the panic handler to be called", timeout)
+		}
+		if p.Middleware != "middleware" || p.Zone != "example.org." || p.Qname != "example.org." || p.Value != "I'm a panic" {
+			t.Errorf("Timeout %s: unexpected panic details: %s", timeout, p)
+		}
+		if len(p.Stack) == 0 {
+			t.Errorf("Timeout %s: expected a stack trace", timeout)
+		}
+	}
+}
+
+// passHandler calls the next middleware, so the panicking middleware is not the
+// outermost one.
+type passHandler struct{ next middleware.Handler }
+
+func (s passHandler) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	return s.next.ServeDNS(ctx, w, r)
+}
@@ -0,0 +1,69 @@
+package dnsserver
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// A zone can be a pattern that owns a family of zones:
+//
+// *.example.org. is a wildcard zone: each name directly below example.org. is handled
+// as if it was a zone by itself, so a.example.org. and b.c.example.org. both use it.
+//
+// ~REGEXP is a regexp zone, it handles every (lowercased) name that matches REGEXP.
+// Regexp zones are only tried when no other zone matches.
+
+// regexpZone is a zone that matches names with a regular expression.
+type regexpZone struct {
+	re     *regexp.Regexp
+	config *Config
+}
+
+func isWildcardZone(zone string) bool { return strings.HasPrefix(zone, "*.") }
+
+func isRegexpZone(zone string) bool { return strings.HasPrefix(zone, "~") }
+
+// zoneDirectives are the middleware that serve the data of the zones of their server
+// block, they need a zone, not a pattern.
+var zoneDirectives = map[string]bool{"file": true, "secondary": true, "etcd": true, "kubernetes": true}
+
+// checkPattern returns an error if zone is a pattern and one of directives is in
+// zoneDirectives.
+func checkPattern(zone string, directives []DirectiveInfo) error {
+	if !isWildcardZone(zone) && !isRegexpZone(zone) {
+		return nil
+	}
+	for _, d := range directives {
+		if zoneDirectives[d.Name] {
+			return fmt.Errorf("%s can't be used in the pattern zone %s", d.Name, zone)
+		}
+	}
+	return nil
+}
+
+// wildcardKey returns the wildcard zone that would own name, *.example.org. for
+// a.example.org. The root has no wildcard zone, "" is returned for it.
+func wildcardKey(name string) string {
+	off, end := dns.NextLabel(name, 0)
+	if end {
+		return ""
+	}
+	return "*." + name[off:]
+}
+
+// matchRegexp returns the config of the first regexp zone in zones that matches name, or nil.
+func matchRegexp(zones []regexpZone, name string) *Config {
+	if len(zones) == 0 {
+		return nil
+	}
+	name = strings.ToLower(name)
+	for _, z := range zones {
+		if z.re.MatchString(name) {
+			return z.config
+		}
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package dnsserver
+
+import "testing"
+
+func TestCheckPattern(t *testing.T) {
+	tests := []struct {
+		zone      string
+		directive string
+		shouldErr bool
+	}{
+		{"example.org.", "file", false},
+		{"*.example.org.", "proxy", false},
+		{"*.example.org.", "file", true},
+		{"~^a.*\\.example\\.org\\.$", "kubernetes", true},
+		{"~^a.*\\.example\\.org\\.$", "whoami", false},
+	}
+	for i, tc := range tests {
+		err := checkPattern(tc.zone, []DirectiveInfo{{Name: "log"}, {Name: tc.directive}})
+		if (err != nil) != tc.shouldErr {
+			t.Errorf("Test %d: expected error %t, got %v", i, tc.shouldErr, err)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package dnsserver
+
+import (
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// pool is a dns.Handler that hands the queries of a listener to a fixed number of
+// workers, through a queue of bounded length. The dns.Server still starts a goroutine
+// for each query, but it only waits for a worker; when the queue is full a query is
+// dropped, so the amount of queries (and memory) in flight is bounded.
+type pool struct {
+	h      dns.Handler
+	queue  chan *job
+	oldest bool // when the queue is full drop the oldest query, instead of the new one
+
+	server string // for the metrics
+	proto  string
+
+	quit chan struct{}
+	once sync.Once
+}
+
+// job is a query waiting for a worker, done is closed when it is handled or dropped.
+type job struct {
+	w    dns.ResponseWriter
+	r    *dns.Msg
+	done chan struct{}
+}
+
+// newPool returns a pool that hands queries to h and starts its workers.
+func newPool(h dns.Handler, workers, length int, oldest bool, server, proto string) *pool {
+	p := &pool{
+		h:      h,
+		queue:  make(chan *job, length),
+		oldest: oldest,
+		server: server,
+		proto:  proto,
+		quit:   make(chan struct{}),
+	}
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+func (p *pool) work() {
+	for {
+		select {
+		case j := <-p.queue:
+			p.h.ServeDNS(j.w, j.r)
+			close(j.done)
+		case <-p.quit:
+			return
+		}
+	}
+}
+
+// ServeDNS implements the dns.Handler interface. It queues the query and waits until a
+// worker has handled it, or until it is dropped.
+func (p *pool) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	j := &job{w: w, r: r, done: make(chan struct{})}
+	if !p.enqueue(j) {
+		return
+	}
+	select {
+	case <-j.done:
+	case <-p.quit:
+	}
+}
+
+// enqueue adds j to the queue. If the queue is full either j is dropped, and false is
+// returned, or the oldest query in the queue makes room for it.
+func (p *pool) enqueue(j *job) bool {
+	for {
+		select {
+		case p.queue <- j:
+			return true
+		default:
+		}
+		if !p.oldest {
+			queueDropCount.WithLabelValues(p.server, p.proto).Inc()
+			return false
+		}
+		select {
+		case old := <-p.queue:
+			queueDropCount.WithLabelValues(p.server, p.proto).Inc()
+			close(old.done)
+		default:
+			// A worker emptied a slot in the meantime, try again.
+		}
+	}
+}
+
+// stop stops the workers, queries still in the queue are dropped.
+func (p *pool) stop() {
+	p.once.Do(func() { close(p.quit) })
+}
+
+const defaultQueuePerWorker = 10
@@ -0,0 +1,76 @@
+package dnsserver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/coredns/middleware/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestPoolDropNewest(t *testing.T) {
+	block := make(chan struct{})
+	busy := make(chan struct{})
+	h := dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		busy <- struct{}{}
+		<-block
+		m := new(dns.Msg)
+		m.SetReply(r)
+		w.WriteMsg(m)
+	})
+	p := newPool(h, 1, 1, false, "127.0.0.1:53", "udp")
+	defer p.stop()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+
+	// The first query keeps the worker busy, the second waits in the queue.
+	done := make(chan struct{}, 2)
+	go func() { p.ServeDNS(&test.ResponseWriter{}, m); done <- struct{}{} }()
+	<-busy
+	go func() { p.ServeDNS(&test.ResponseWriter{}, m); done <- struct{}{} }()
+	for len(p.queue) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The queue is full, so this one is dropped at once.
+	p.ServeDNS(&test.ResponseWriter{}, m)
+
+	close(block)
+	<-done
+	<-busy
+	<-done
+}
+
+func TestPoolDropOldest(t *testing.T) {
+	block := make(chan struct{})
+	busy := make(chan struct{})
+	h := dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		busy <- struct{}{}
+		<-block
+	})
+	p := newPool(h, 1, 1, true, "127.0.0.1:53", "udp")
+	defer p.stop()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+
+	go p.ServeDNS(&test.ResponseWriter{}, m)
+	<-busy
+
+	queued := make(chan struct{})
+	go func() { p.ServeDNS(&test.ResponseWriter{}, m); close(queued) }()
+	for len(p.queue) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The new query takes the place of the queued one, which is dropped.
+	newest := make(chan struct{})
+	go func() { p.ServeDNS(&test.ResponseWriter{}, m); close(newest) }()
+	<-queued
+
+	close(block)
+	<-busy
+	<-newest
+}
@@ -32,7 +32,13 @@ func init() {
 }
 
 func newContext() caddy.Context {
-	return &dnsContext{keysToConfigs: make(map[string]*Config)}
+	// A new configuration is loaded, its directives make middleware switchable again.
+	resetSwitchable()
+	ctx := &dnsContext{keysToConfigs: make(map[string]*Config)}
+	lastContext.Lock()
+	lastContext.ctx = ctx
+	lastContext.Unlock()
+	return ctx
 }
 
 type dnsContext struct {
@@ -52,24 +58,45 @@ func (h *dnsContext) saveConfig(key string, cfg *Config) {
 // be parsed and executed.
 func (h *dnsContext) InspectServerBlocks(sourceFile string, serverBlocks []caddyfile.ServerBlock) ([]caddyfile.ServerBlock, error) {
 	// Normalize and check all the zone names and check for duplicates
-	dups := map[string]string{}
-	for _, s := range serverBlocks {
+	dups := map[string]*Config{}
+	for j, s := range serverBlocks {
+		// Expand reverse zones given as a CIDR, each zone gets its own key.
+		var keys []string
+		for _, k := range s.Keys {
+			ks, err := expandReverse(k)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, ks...)
+		}
+		s.Keys = keys
+		serverBlocks[j].Keys = keys
+
 		for i, k := range s.Keys {
 			za, err := normalizeZone(k)
 			if err != nil {
 				return nil, err
 			}
 			s.Keys[i] = za.String()
-			if v, ok := dups[za.Zone]; ok {
-				return nil, fmt.Errorf("cannot serve %s - zone already defined for %v", za, v)
-			}
-			dups[za.Zone] = za.String()
-
 			// Save the config to our master list, and key it for lookups
 			cfg := &Config{
-				Zone: za.Zone,
-				Port: za.Port,
+				Zone:       za.Zone,
+				Port:       za.Port,
+				Transport:  za.Transport,
+				block:      j,
+				key:        k,
+				directives: describeDirectives(s.Tokens),
+			}
+			if err := checkPattern(za.Zone, cfg.directives); err != nil {
+				return nil, fmt.Errorf("%s: %s", cfg, err)
+			}
+
+			// The same zone may be served over different transports and on different ports,
+			// but the directives can't tell server blocks with the same key apart.
+			if v, ok := dups[za.String()]; ok {
+				return nil, fmt.Errorf("cannot serve %s in %s - zone already defined in %s", za, cfg, v)
 			}
+			dups[za.String()] = cfg
 			h.saveConfig(za.String(), cfg)
 		}
 	}
@@ -79,19 +106,57 @@ func (h *dnsContext) InspectServerBlocks(sourceFile string, serverBlocks []caddy
 // MakeServers uses the newly-created siteConfigs to create and return a list of server instances.
 func (h *dnsContext) MakeServers() ([]caddy.Server, error) {
 
+	// the startup hooks must not depend on each other
+	for _, c := range h.configs {
+		if _, err := startupOrder(c.orderedHooks); err != nil {
+			return nil, fmt.Errorf("%s: %s", c, err)
+		}
+	}
+
 	// we must map (group) each config to a bind address
 	groups, err := groupConfigsByListenAddr(h.configs)
 	if err != nil {
 		return nil, err
 	}
+	// the binds are known now, check the listeners don't step on each other
+	if err := checkListeners(groups); err != nil {
+		return nil, err
+	}
 	// then we create a server for each group
 	var servers []caddy.Server
 	for addr, group := range groups {
-		s, err := NewServer(addr, group)
-		if err != nil {
-			return nil, err
+		switch trans, addr := Transport(addr); trans {
+		case TransportTLS:
+			s, err := NewServerTLS(addr, group)
+			if err != nil {
+				return nil, err
+			}
+			servers = append(servers, s)
+		case TransportHTTPS:
+			s, err := NewServerHTTPS(addr, group)
+			if err != nil {
+				return nil, err
+			}
+			servers = append(servers, s)
+		case TransportGRPC:
+			s, err := NewServerGRPC(addr, group)
+			if err != nil {
+				return nil, err
+			}
+			servers = append(servers, s)
+		case TransportUnix:
+			s, err := NewServerUnix(addr, group)
+			if err != nil {
+				return nil, err
+			}
+			servers = append(servers, s)
+		default:
+			s, err := NewServer(addr, group)
+			if err != nil {
+				return nil, err
+			}
+			servers = append(servers, s)
 		}
-		servers = append(servers, s)
 	}
 
 	return servers, nil
@@ -106,6 +171,10 @@ func (c *Config) AddMiddleware(m middleware.Middleware) {
 // (bind) address, so sites that use the same listener can be served
 // on the same server instance. The return value maps the listen
 // address (what you pass into net.Listen) to the list of site configs.
+// A config with multiple listen hosts is added to the group of each of them. The
+// wildcard addresses, 0.0.0.0 and ::, are grouped as one, with the empty host.
+// For transports other than dns the address is prefixed with transport://, a config
+// served on a Unix socket is grouped by unix://path.
 // This function does NOT vet the configs to ensure they are compatible.
 func groupConfigsByListenAddr(configs []*Config) (map[string][]*Config, error) {
 	groups := make(map[string][]*Config)
@@ -114,12 +183,35 @@ func groupConfigsByListenAddr(configs []*Config) (map[string][]*Config, error) {
 		if conf.Port == "" {
 			conf.Port = Port
 		}
-		addr, err := net.ResolveTCPAddr("tcp", net.JoinHostPort(conf.ListenHost, conf.Port))
-		if err != nil {
-			return nil, err
+		if conf.UnixSocket != "" {
+			addrstr := TransportUnix + "://" + conf.UnixSocket
+			groups[addrstr] = append(groups[addrstr], conf)
+			continue
+		}
+		hosts := conf.ListenHosts
+		if len(hosts) == 0 {
+			hosts = []string{""}
+		}
+		seen := make(map[string]bool)
+		for _, h := range hosts {
+			addr, err := net.ResolveTCPAddr("tcp", net.JoinHostPort(h, conf.Port))
+			if err != nil {
+				return nil, err
+			}
+			// 0.0.0.0 and :: are the same dual-stack listener, as the empty host.
+			if addr.IP.IsUnspecified() {
+				addr.IP = nil
+			}
+			addrstr := addr.String()
+			if conf.Transport != "" && conf.Transport != TransportDNS {
+				addrstr = conf.Transport + "://" + addrstr
+			}
+			if seen[addrstr] {
+				continue
+			}
+			seen[addrstr] = true
+			groups[addrstr] = append(groups[addrstr], conf)
 		}
-		addrstr := addr.String()
-		groups[addrstr] = append(groups[addrstr], conf)
 	}
 
 	return groups, nil
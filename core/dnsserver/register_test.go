@@ -0,0 +1,73 @@
+package dnsserver
+
+import (
+	"net"
+	"strconv"
+	"testing"
+)
+
+func TestGroupConfigsByListenAddr(t *testing.T) {
+	configs := []*Config{
+		{Zone: "example.org.", Port: "53", ListenHosts: []string{"127.0.0.1", "::1"}},
+		{Zone: "example.net.", Port: "53", ListenHosts: []string{"127.0.0.1"}},
+		{Zone: "example.com.", Port: "53"},
+		{Zone: "example.local.", Port: "53", UnixSocket: "/run/coredns.sock"},
+	}
+
+	groups, err := groupConfigsByListenAddr(configs)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	expected := map[string]int{"127.0.0.1:53": 2, "[::1]:53": 1, ":53": 1, "unix:///run/coredns.sock": 1}
+	if len(groups) != len(expected) {
+		t.Fatalf("Expected %d groups, got %d: %v", len(expected), len(groups), groups)
+	}
+	for addr, n := range expected {
+		if len(groups[addr]) != n {
+			t.Errorf("Expected %d configs for %s, got %d", n, addr, len(groups[addr]))
+		}
+	}
+}
+
+func TestGroupConfigsByListenAddrWildcard(t *testing.T) {
+	configs := []*Config{
+		{Zone: "example.org.", Port: "0", ListenHosts: []string{"0.0.0.0", "::"}},
+		{Zone: "example.net.", Port: "0"},
+	}
+	groups, err := groupConfigsByListenAddr(configs)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if len(groups) != 1 || len(groups[":0"]) != 2 {
+		t.Fatalf("Expected a single wildcard group with both configs, got %v", groups)
+	}
+
+	// And that one listener can be bound.
+	s, err := NewServer(":0", groups[":0"])
+	if err != nil {
+		t.Fatalf("Failed to create server: %s", err)
+	}
+	l, err := s.Listen()
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	defer l.Close()
+	p, err := s.ListenPacket()
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	defer p.Close()
+
+	port := strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+	for _, host := range []string{"127.0.0.1", "::1"} {
+		c, err := net.Dial("tcp", net.JoinHostPort(host, port))
+		if err != nil {
+			if host == "::1" {
+				continue // no IPv6
+			}
+			t.Fatalf("Failed to connect to %s: %s", host, err)
+		}
+		c.Close()
+	}
+}
@@ -0,0 +1,61 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+package dnsserver
+
+import (
+	"net"
+	"os"
+	"syscall"
+)
+
+// listenPacketReusePort opens a UDP socket on addr with SO_REUSEPORT set, so more
+// sockets can be bound to the same address. The kernel spreads the incoming
+// packets over these sockets. The socket is made by hand, as the net package has
+// no way to set socket options before the bind.
+func listenPacketReusePort(addr string) (net.PacketConn, error) {
+	ua, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	family := syscall.AF_INET6
+	var sa syscall.Sockaddr
+	if ip4 := ua.IP.To4(); ip4 != nil {
+		family = syscall.AF_INET
+		sa4 := &syscall.SockaddrInet4{Port: ua.Port}
+		copy(sa4.Addr[:], ip4)
+		sa = sa4
+	} else {
+		// No IP is the wildcard address, an IPv6 socket gets the IPv4 packets as well.
+		sa6 := &syscall.SockaddrInet6{Port: ua.Port}
+		copy(sa6.Addr[:], ua.IP.To16())
+		if ua.Zone != "" {
+			if ifi, err := net.InterfaceByName(ua.Zone); err == nil {
+				sa6.ZoneId = uint32(ifi.Index)
+			}
+		}
+		sa = sa6
+	}
+
+	fd, err := syscall.Socket(family, syscall.SOCK_DGRAM, syscall.IPPROTO_UDP)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	syscall.CloseOnExec(fd)
+	if err := syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, soReusePort, 1); err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("setsockopt", err)
+	}
+	if err := syscall.Bind(fd, sa); err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("bind", err)
+	}
+
+	// FilePacketConn dups the descriptor, the file is closed either way.
+	f := os.NewFile(uintptr(fd), "udp:"+addr)
+	defer f.Close()
+	return net.FilePacketConn(f)
+}
+
+const supportsReusePort = true
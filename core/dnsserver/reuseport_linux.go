@@ -0,0 +1,4 @@
+package dnsserver
+
+// soReusePort is SO_REUSEPORT, which package syscall doesn't define for Linux.
+const soReusePort = 0xf
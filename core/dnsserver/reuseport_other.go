@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package dnsserver
+
+import "net"
+
+// listenPacketReusePort falls back to a normal UDP socket on platforms without SO_REUSEPORT,
+// NewServer makes sure only one socket is opened.
+func listenPacketReusePort(addr string) (net.PacketConn, error) {
+	return net.ListenPacket("udp", addr)
+}
+
+const supportsReusePort = false
@@ -0,0 +1,48 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+package dnsserver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/coredns/middleware"
+
+	"github.com/miekg/dns"
+)
+
+func TestReusePort(t *testing.T) {
+	s, err := NewServer("127.0.0.1:0", []*Config{
+		{Zone: ".", Port: "0", ReusePort: 4, Middleware: []middleware.Middleware{rootHandler}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %s", err)
+	}
+	p, err := s.ListenPacket()
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	go s.ServePacket(p)
+	defer s.Stop()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	c := new(dns.Client)
+	for i := 0; i < 10; i++ {
+		if _, _, err = c.Exchange(m, p.LocalAddr().String()); err == nil {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	s.m.Lock()
+	extra := len(s.extra)
+	s.m.Unlock()
+	if extra != 3 {
+		t.Errorf("Expected 3 extra UDP sockets, got %d", extra)
+	}
+}
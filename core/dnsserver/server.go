@@ -4,35 +4,79 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"regexp"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/miekg/coredns/middleware"
+	"github.com/miekg/coredns/middleware/pkg/cname"
 	"github.com/miekg/coredns/middleware/pkg/edns"
+	"github.com/miekg/coredns/middleware/pkg/metadata"
 	"github.com/miekg/coredns/request"
 
+	"github.com/mholt/caddy"
 	"github.com/miekg/dns"
+	ot "github.com/opentracing/opentracing-go"
 	"golang.org/x/net/context"
 )
 
+// Server must be a caddy.GracefulServer, so it survives a reload.
+var _ caddy.GracefulServer = &Server{}
+
 // Server represents an instance of a server, which serves
 // DNS requests at a particular address (host and port). A
 // server is capable of serving numerous zones on
 // the same address and the listener may be stopped for
 // graceful termination (POSIX only).
 type Server struct {
+	inflight int64 // number of queries being handled, first for the alignment of atomic access
+	stopping int32 // set to 1 when Stop is called, after that new queries are dropped
+
 	Addr   string // Address we listen on
 	mux    *dns.ServeMux
 	server [2]*dns.Server // 0 is a net.Listener, 1 is a net.PacketConn (a *UDPConn) in our case.
 
 	l net.Listener
 	p net.PacketConn
-	m sync.Mutex // protects listener and packetconn
+	m sync.Mutex // protects listener, packetconn and the zones
+
+	listenOnce sync.Once // the listen hooks of the zones run once
 
 	zones       map[string]*Config // zones keyed by their address
-	dnsWg       sync.WaitGroup     // used to wait on outstanding connections
+	set         atomic.Value       // the *zoneSet ServeDNS routes with, see publish
 	connTimeout time.Duration      // the maximum duration of a graceful shutdown
+
+	maxConns      int // maximum number of open stream connections
+	maxConnsPerIP int // maximum number of open stream connections per client IP
+
+	readTimeout  time.Duration // timeouts for the dns.Servers, 0 is the default
+	writeTimeout time.Duration
+	idleTimeout  time.Duration
+
+	queryTimeout  time.Duration // deadline for handling a query
+	maxCNAMEDepth int           // number of CNAMEs the middleware may follow
+
+	concurrent   chan struct{} // semaphore for the queries in flight, nil is unlimited
+	overloadDrop bool          // drop queries over the concurrency limit, instead of SERVFAIL
+
+	workers     int     // number of workers per listener, 0 is a goroutine per query
+	queueLength int     // length of the queue of the workers
+	queueOldest bool    // drop the oldest queued query when the queue is full
+	pools       []*pool // the worker pools of the listeners
+
+	noRootFallback bool        // don't send queries that match no zone to the root zone
+	noZonePolicy   int         // what to do with queries that match no zone, NoZoneRefused and friends
+	noZoneLog      *logLimiter // limits the logging of those queries per remote host
+
+	ra int // policy for the RA bit in responses
+
+	wildcards bool         // there are wildcard zones, like *.example.org.
+	regexps   []regexpZone // zones matched by a regular expression, in configuration order
+
+	reusePort int           // number of UDP sockets to open with SO_REUSEPORT
+	extra     []*dns.Server // servers for the extra UDP sockets
 }
 
 // NewServer returns a new CoreDNS server and compiles all middleware in to it.
@@ -42,99 +86,289 @@ func NewServer(addr string, group []*Config) (*Server, error) {
 		Addr:        addr,
 		zones:       make(map[string]*Config),
 		connTimeout: 5 * time.Second, // TODO(miek): was configurable
+		noZoneLog:   newLogLimiter(noZoneLogInterval),
 	}
 	mux := dns.NewServeMux()
 	mux.Handle(".", s) // wildcard handler, everything will go through here
 	s.mux = mux
 
-	// We have to bound our wg with one increment
-	// to prevent a "race condition" that is hard-coded
-	// into sync.WaitGroup.Wait() - basically, an add
-	// with a positive delta must be guaranteed to
-	// occur before Wait() is called on the wg.
-	// In a way, this kind of acts as a safety barrier.
-	s.dnsWg.Add(1)
-
 	for _, site := range group {
 		// set the config per zone
 		s.zones[site.Zone] = site
-		// compile custom middleware for everything
-		var stack middleware.Handler
-		for i := len(site.Middleware) - 1; i >= 0; i-- {
-			stack = site.Middleware[i](stack)
+		switch {
+		case isWildcardZone(site.Zone):
+			s.wildcards = true
+		case isRegexpZone(site.Zone):
+			re, err := regexp.Compile(site.Zone[1:])
+			if err != nil {
+				return nil, err
+			}
+			s.regexps = append(s.regexps, regexpZone{re: re, config: site})
+		}
+		// connection limits are per listener, the first zone that sets them wins
+		if s.maxConns == 0 {
+			s.maxConns = site.MaxConns
+		}
+		if s.maxConnsPerIP == 0 {
+			s.maxConnsPerIP = site.MaxConnsPerIP
+		}
+		if s.readTimeout == 0 {
+			s.readTimeout = site.ReadTimeout
+		}
+		if s.writeTimeout == 0 {
+			s.writeTimeout = site.WriteTimeout
+		}
+		if s.idleTimeout == 0 {
+			s.idleTimeout = site.IdleTimeout
+		}
+		if s.queryTimeout == 0 {
+			s.queryTimeout = site.QueryTimeout
+		}
+		if s.maxCNAMEDepth == 0 {
+			s.maxCNAMEDepth = site.MaxCNAMEDepth
+		}
+		if s.concurrent == nil && site.MaxConcurrent > 0 {
+			s.concurrent = make(chan struct{}, site.MaxConcurrent)
+			s.overloadDrop = site.OverloadDrop
+		}
+		if s.workers == 0 && site.Workers > 0 {
+			s.workers = site.Workers
+			s.queueLength = site.QueueLength
+			s.queueOldest = site.QueueDropOldest
+		}
+		if s.reusePort == 0 {
+			s.reusePort = site.ReusePort
+		}
+		if s.reusePort > 1 && !supportsReusePort {
+			log.Printf("[WARNING] SO_REUSEPORT is not supported on this platform, using a single UDP socket for %s", addr)
+			s.reusePort = 0
+		}
+		// any zone can disable the root fallback for the whole listener
+		s.noRootFallback = s.noRootFallback || site.NoRootFallback
+		if s.noZonePolicy == NoZoneRefused {
+			s.noZonePolicy = site.NoZone
+		}
+		if s.ra == FlagKeep {
+			s.ra = site.RA
+		}
+		compile(site)
+	}
+
+	if s.queryTimeout == 0 {
+		s.queryTimeout = defaultQueryTimeout
+	}
+	if s.maxCNAMEDepth == 0 {
+		s.maxCNAMEDepth = cname.DefaultMaxDepth
+	}
+	if s.workers > 0 && s.queueLength == 0 {
+		s.queueLength = s.workers * defaultQueuePerWorker
+	}
+
+	if s.ra == FlagAuto {
+		s.ra = FlagClear
+		for _, site := range group {
+			if site.Recursion {
+				s.ra = FlagSet
+				break
+			}
 		}
-		site.middlewareChain = stack
 	}
 
+	s.publish()
 	return s, nil
 }
 
+// compile compiles the middleware of site into its chain. If the zone is served on
+// multiple addresses this is already done by the first server.
+func compile(site *Config) {
+	if site.middlewareChain != nil {
+		return
+	}
+	var stack middleware.Handler
+	site.handlers = make(map[string]middleware.Handler)
+	for i := len(site.Middleware) - 1; i >= 0; i-- {
+		next := stack
+		stack = site.Middleware[i](next)
+		name := handlerName(stack)
+		// The last middleware has nothing to fall through to, so it can't be switched off.
+		if next != nil && switchable(name) {
+			stack = switchHandler{on: stack, next: next, name: name}
+		}
+		stack = traceHandler{Handler: stack, name: name}
+		if site.Tracer != nil {
+			stack = spanHandler{Handler: stack, name: name}
+		}
+		site.handlers[name] = stack
+	}
+	site.middlewareChain = stack
+}
+
 // Serve starts the server with an existing listener. It blocks until the server stops.
+// On a reload (SIGUSR1) l may be a copy of the listener of the previous instance
+// of this server, so all wrapping of l is done here and not in Listen.
 func (s *Server) Serve(l net.Listener) error {
+	l = newLimitListener(l, "tcp", s.maxConns, s.maxConnsPerIP)
 	s.m.Lock()
-	s.server[tcp] = &dns.Server{Listener: l, Net: "tcp", Handler: s.mux}
+	s.l = l
+	s.server[tcp] = s.newDNSServer("tcp")
+	s.server[tcp].Listener = l
 	s.m.Unlock()
+	s.bound()
 
 	return s.server[tcp].ActivateAndServe()
 }
 
 // ServePacket starts the server with an existing packetconn. It blocks until the server stops.
+// When SO_REUSEPORT is enabled, the extra sockets are opened here (and not in
+// ListenPacket) so they are also created when p is handed over on a reload.
 func (s *Server) ServePacket(p net.PacketConn) error {
 	s.m.Lock()
-	s.server[udp] = &dns.Server{PacketConn: p, Net: "udp", Handler: s.mux}
+	s.p = p
+	s.server[udp] = s.newDNSServer("udp")
+	s.server[udp].PacketConn = p
+	for i := 1; i < s.reusePort; i++ {
+		p1, err := listenPacketReusePort(p.LocalAddr().String())
+		if err != nil {
+			s.m.Unlock()
+			return err
+		}
+		s1 := s.newDNSServer("udp")
+		s1.PacketConn = p1
+		s.extra = append(s.extra, s1)
+		go s1.ActivateAndServe()
+	}
 	s.m.Unlock()
+	s.bound()
 
 	return s.server[udp].ActivateAndServe()
 }
 
+// newDNSServer returns a dns.Server for network that hands queries to s and has the
+// configured timeouts. With workers configured, each dns.Server gets its own pool.
+// The caller must hold s.m.
+func (s *Server) newDNSServer(network string) *dns.Server {
+	var h dns.Handler = s.mux
+	if s.workers > 0 {
+		p := newPool(s.mux, s.workers, s.queueLength, s.queueOldest, s.Addr, network)
+		s.pools = append(s.pools, p)
+		h = p
+	}
+	ds := &dns.Server{Net: network, Handler: h, ReadTimeout: s.readTimeout, WriteTimeout: s.writeTimeout}
+	if s.idleTimeout > 0 {
+		idle := s.idleTimeout
+		ds.IdleTimeout = func() time.Duration { return idle }
+	}
+	return ds
+}
+
 // Listen implements caddy.TCPServer interface.
 func (s *Server) Listen() (net.Listener, error) {
-	l, err := net.Listen("tcp", s.Addr)
-	if err != nil {
-		return nil, err
+	l := activatedListener(s.Addr)
+	if l == nil {
+		var err error
+		if l, err = net.Listen("tcp", s.listenAddr(s.LocalAddrPacket())); err != nil {
+			return nil, err
+		}
 	}
 	s.m.Lock()
 	s.l = l
 	s.m.Unlock()
+	s.bound()
 	return l, nil
 }
 
+// Address implements caddy.GracefulServer interface. On a reload caddy hands the
+// listeners of the server with the same address to the new instance, so queries
+// keep being answered while the new middleware stacks take over.
+func (s *Server) Address() string { return s.Addr }
+
 // ListenPacket implements caddy.UDPServer interface.
 func (s *Server) ListenPacket() (net.PacketConn, error) {
-	p, err := net.ListenPacket("udp", s.Addr)
-	if err != nil {
-		return nil, err
+	listen := net.ListenPacket
+	if s.reusePort > 1 {
+		listen = func(_, addr string) (net.PacketConn, error) { return listenPacketReusePort(addr) }
+	}
+	p := activatedPacketConn(s.Addr)
+	if p == nil {
+		var err error
+		if p, err = listen("udp", s.listenAddr(s.LocalAddr())); err != nil {
+			return nil, err
+		}
 	}
 
 	s.m.Lock()
 	s.p = p
 	s.m.Unlock()
+	s.bound()
 	return p, nil
 }
 
-// Stop stops the server. It blocks until the server is
-// totally stopped. On POSIX systems, it will wait for
-// connections to close (up to a max timeout of a few
-// seconds); on Windows it will close the listener
-// immediately.
-func (s *Server) Stop() (err error) {
+// listenAddr returns the address to listen on. If s.Addr has port 0 the kernel picks
+// a port; when the other listener, at other, is already bound its port is used, so
+// TCP and UDP share the port.
+func (s *Server) listenAddr(other net.Addr) string {
+	host, port, err := net.SplitHostPort(s.Addr)
+	if err != nil || port != "0" || other == nil {
+		return s.Addr
+	}
+	_, port, err = net.SplitHostPort(other.String())
+	if err != nil {
+		return s.Addr
+	}
+	return net.JoinHostPort(host, port)
+}
 
-	if runtime.GOOS != "windows" {
-		// force connections to close after timeout
-		done := make(chan struct{})
-		go func() {
-			s.dnsWg.Done() // decrement our initial increment used as a barrier
-			s.dnsWg.Wait()
-			close(done)
-		}()
-
-		// Wait for remaining connections to finish or
-		// force them all to close after timeout
-		select {
-		case <-time.After(s.connTimeout):
-		case <-done:
-		}
+// LocalAddr returns the address of the TCP listener, or nil if it is not bound.
+func (s *Server) LocalAddr() net.Addr {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.l == nil {
+		return nil
+	}
+	return s.l.Addr()
+}
+
+// LocalAddrPacket returns the address of the UDP listener, or nil if it is not bound.
+func (s *Server) LocalAddrPacket() net.Addr {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.p == nil {
+		return nil
 	}
+	return s.p.LocalAddr()
+}
+
+// bound runs the listen hooks of the zones once both listeners are bound.
+func (s *Server) bound() {
+	tcp, udp := s.LocalAddr(), s.LocalAddrPacket()
+	if tcp == nil || udp == nil {
+		return
+	}
+	s.listening(tcp, udp)
+}
+
+// listening runs the listen hooks of the zones of s, once, and starts their startup hooks.
+// It is called when s is bound, also on a reload, when s gets the listeners of the previous
+// instance in Serve: caddy only calls OnStartupComplete on the first start. The startup
+// hooks run in the background, s may not be serving yet.
+func (s *Server) listening(tcp, udp net.Addr) {
+	s.listenOnce.Do(func() {
+		for _, conf := range s.zoneMap() {
+			for _, fn := range conf.listenHooks {
+				fn(tcp, udp)
+			}
+		}
+		go s.startupHooks()
+	})
+}
+
+// Stop stops the server. It blocks until the server is
+// totally stopped. The listeners are closed at once, so
+// no new queries come in; on POSIX systems it then waits
+// for the queries in flight to finish (up to a max timeout
+// of a few seconds), on Windows it doesn't wait.
+func (s *Server) Stop() (err error) {
+	atomic.StoreInt32(&s.stopping, 1)
 
 	// Close the listener now; this stops the server without delay
 	s.m.Lock()
@@ -146,9 +380,33 @@ func (s *Server) Stop() (err error) {
 	}
 
 	for _, s1 := range s.server {
+		// We might not have started and opened all sockets yet.
+		if s1 == nil {
+			continue
+		}
 		err = s1.Shutdown()
 	}
+	for _, s1 := range s.extra {
+		s1.PacketConn.Close()
+		s1.Shutdown()
+	}
+	s.m.Unlock()
+
+	if runtime.GOOS != "windows" {
+		// Wait for the remaining queries to finish, or give up on them after the timeout.
+		deadline := time.Now().Add(s.connTimeout)
+		for atomic.LoadInt64(&s.inflight) > 0 && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	s.m.Lock()
+	for _, p := range s.pools {
+		p.stop()
+	}
 	s.m.Unlock()
+
+	s.shutdownHooks()
 	return
 }
 
@@ -157,12 +415,32 @@ func (s *Server) Stop() (err error) {
 // defined in the request so that the correct zone
 // (configuration and middleware stack) will handle the request.
 func (s *Server) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	// Track the query, so Stop can wait for it to finish. It is counted before the check,
+	// so Stop either sees it in flight or it sees that Stop has been called.
+	atomic.AddInt64(&s.inflight, 1)
+	defer atomic.AddInt64(&s.inflight, -1)
+	if atomic.LoadInt32(&s.stopping) == 1 {
+		return
+	}
+
+	if s.concurrent != nil {
+		select {
+		case s.concurrent <- struct{}{}:
+			defer func() { <-s.concurrent }()
+		default:
+			overloadCount.WithLabelValues(s.Addr).Inc()
+			if !s.overloadDrop {
+				DefaultErrorFunc(w, r, dns.RcodeServerFailure)
+			}
+			return
+		}
+	}
+
 	// TODO(miek): expensive to use defer
 	defer func() {
-		// In case the user doesn't enable error middleware, we still
-		// need to make sure that we stay alive up here
+		// Panics of the middleware are handled in serveZone, this catches the rest.
 		if rec := recover(); rec != nil {
-			DefaultErrorFunc(w, r, dns.RcodeServerFailure)
+			s.panicked(rec, nil, "", w, r)
 		}
 	}()
 
@@ -172,9 +450,24 @@ func (s *Server) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 	}
 
 	q := r.Question[0].Name
+	if a := request.ToASCII(q); a != q {
+		// An internationalized name, handle it in its punycode form, but reply with
+		// the name as it was asked.
+		r.Question[0].Name = a
+		w = &idnaResponseWriter{ResponseWriter: w, name: q}
+		q = a
+	}
+	// Zones can be added and removed while we run, work on the current set.
+	set := s.set.Load().(*zoneSet)
+	zones, wildcards, regexps := set.zones, set.wildcards, set.regexps
+
 	b := make([]byte, len(q))
 	off, end := 0, false
-	ctx := context.Background()
+	// The client will have given up after the deadline, so there is no use in
+	// continuing the work; middleware should check ctx.
+	ctx, cancel := context.WithTimeout(context.Background(), s.queryTimeout)
+	defer cancel()
+	ctx = cname.NewContext(ctx, s.maxCNAMEDepth)
 
 	for {
 		l := len(q[off:])
@@ -186,45 +479,153 @@ func (s *Server) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 			}
 		}
 
-		if h, ok := s.zones[string(b[:l])]; ok {
+		if h, ok := zones[string(b[:l])]; ok {
 			if r.Question[0].Qtype != dns.TypeDS {
-				rcode, _ := h.middlewareChain.ServeDNS(ctx, w, r)
-				if rcodeNoClientWrite(rcode) {
-					DefaultErrorFunc(w, r, rcode)
-				}
+				s.serveZone(ctx, h, w, r)
 				return
 			}
 		}
+		if wildcards {
+			// A wildcard zone, *.example.org., owns the names directly below example.org.
+			// as if each was a zone by itself.
+			if h, ok := zones[wildcardKey(string(b[:l]))]; ok {
+				if r.Question[0].Qtype != dns.TypeDS {
+					s.serveZone(ctx, h, w, r)
+					return
+				}
+			}
+		}
 		off, end = dns.NextLabel(q, off)
 		if end {
 			break
 		}
 	}
-	// Wildcard match, if we have found nothing try the root zone as a last resort.
-	if h, ok := s.zones["."]; ok {
-		rcode, _ := h.middlewareChain.ServeDNS(ctx, w, r)
-		if rcodeNoClientWrite(rcode) {
-			DefaultErrorFunc(w, r, rcode)
+	if h := matchRegexp(regexps, q); h != nil {
+		s.serveZone(ctx, h, w, r)
+		return
+	}
+	// Wildcard match, if we have found nothing try the root zone as a last resort,
+	// unless that is disabled. Queries for the root itself are always allowed.
+	if h, ok := zones["."]; ok && (!s.noRootFallback || q == ".") {
+		// Only count the queries that missed the other zones of the listener, not the
+		// ones for a root zone that is served by itself.
+		if q != "." && len(zones) > 1 {
+			rootFallbackCount.WithLabelValues(s.Addr).Inc()
 		}
+		s.serveZone(ctx, h, w, r)
 		return
 	}
 
-	// Still here? Error out with REFUSED and some logging
-	remoteHost := w.RemoteAddr().String()
-	DefaultErrorFunc(w, r, dns.RcodeRefused)
-	log.Printf("[INFO] \"%s %s %s\" - No such zone at %s (Remote: %s)", dns.Type(r.Question[0].Qtype), dns.Class(r.Question[0].Qclass), q, s.Addr, remoteHost)
+	// Still here? Error out with REFUSED, or what the listener is configured to do.
+	s.noZone(w, r, q)
 }
 
-// OnStartupComplete lists the sites served by this server
-// and any relevant information, assuming Quiet == false.
+// OnStartupComplete runs the startup hooks of the middleware, if listening didn't
+// already, and lists the sites served by this server and any relevant information,
+// assuming Quiet == false.
 func (s *Server) OnStartupComplete() {
+	s.startupHooks()
 	if Quiet {
 		return
 	}
 
-	for zone, config := range s.zones {
-		fmt.Println(zone + ":" + config.Port)
+	for zone, config := range s.zoneMap() {
+		fmt.Println(zone + ":" + s.port(config.Port))
+	}
+}
+
+// port returns port, or the port of the TCP listener if port is 0.
+func (s *Server) port(port string) string {
+	if port != "0" {
+		return port
+	}
+	if a := s.LocalAddr(); a != nil {
+		if _, p, err := net.SplitHostPort(a.String()); err == nil {
+			return p
+		}
+	}
+	return port
+}
+
+// startupHooks runs the startup hooks of all configs of s.
+func (s *Server) startupHooks() {
+	running.Lock()
+	running.servers[s] = true
+	running.Unlock()
+
+	for _, conf := range s.zoneMap() {
+		conf.startup()
+	}
+}
+
+// shutdownHooks runs the shutdown hooks of all configs of s. It is called when no
+// more queries are handled.
+func (s *Server) shutdownHooks() {
+	running.Lock()
+	delete(running.servers, s)
+	running.Unlock()
+
+	for _, conf := range s.zoneMap() {
+		conf.shutdown()
+	}
+}
+
+// serveZone hands r to the middleware chain of zone config h, if the client is allowed.
+func (s *Server) serveZone(ctx context.Context, h *Config, w dns.ResponseWriter, r *dns.Msg) {
+	if !allowed(h, w) {
+		DefaultErrorFunc(w, r, dns.RcodeRefused)
+		return
+	}
+	rec := newRecorder(w)
+	w = rec
+	ctx = context.WithValue(ctx, writerKey{}, ResponseWriter(rec))
+	defer rec.report(h.Zone)
+
+	tr := &trace{}
+	ctx = context.WithValue(ctx, traceKey{}, tr)
+	defer func() {
+		if rec := recover(); rec != nil {
+			s.panicked(rec, h, tr.get(), w, r)
+		}
+	}()
+	w = truncateWriter(h, w, r)
+	if len(h.metadata) > 0 {
+		ctx = metadata.NewContext(ctx)
+		state := request.Request{W: w, Req: r}
+		for _, p := range h.metadata {
+			p(ctx, state)
+		}
 	}
+	if h.Cookie != nil {
+		var ok bool
+		if ctx, w, ok = checkCookie(ctx, h, w, r); !ok {
+			return
+		}
+	}
+	if h.Tracer != nil {
+		var span ot.Span
+		if ctx, span = startSpan(ctx, h, w, r); span != nil {
+			defer span.Finish()
+		}
+	}
+	if h.Timeout > 0 {
+		s.serveTimeout(ctx, h, s.flagWriter(h, w), r)
+		return
+	}
+	rcode, err := h.middlewareChain.ServeDNS(ctx, s.flagWriter(h, w), r)
+	if !middleware.ClientWrite(rcode) {
+		errorFunc(w, r, rcode, err)
+	}
+}
+
+// allowed checks the client of w against the ACL of the zone config h. Clients that
+// are not allowed are counted.
+func allowed(h *Config, w dns.ResponseWriter) bool {
+	if h.ACL == nil || h.ACL.Allowed(remoteIP(w.RemoteAddr())) {
+		return true
+	}
+	aclBlockedCount.WithLabelValues(h.Zone).Inc()
+	return false
 }
 
 // DefaultErrorFunc responds to an DNS request with an error.
@@ -239,21 +640,25 @@ func DefaultErrorFunc(w dns.ResponseWriter, r *dns.Msg, rcode int) {
 	w.WriteMsg(answer)
 }
 
-func rcodeNoClientWrite(rcode int) bool {
-	switch rcode {
-	case dns.RcodeServerFailure:
-		fallthrough
-	case dns.RcodeRefused:
-		fallthrough
-	case dns.RcodeFormatError:
-		fallthrough
-	case dns.RcodeNotImplemented:
-		return true
-	}
-	return false
+// errorFunc is DefaultErrorFunc for the rcode and error returned by the middleware chain:
+// when err is an *edns.ExtendedError it is added to the response.
+func errorFunc(w dns.ResponseWriter, r *dns.Msg, rcode int, err error) {
+	state := request.Request{W: w, Req: r}
+
+	answer := new(dns.Msg)
+	answer.SetRcode(r, rcode)
+
+	state.SizeAndDo(answer)
+	edns.SetExtendedError(answer, err)
+
+	w.WriteMsg(answer)
 }
 
 const (
 	tcp = 0
 	udp = 1
 )
+
+// defaultQueryTimeout is the default deadline for handling a query, most clients
+// have given up after this.
+const defaultQueryTimeout = 5 * time.Second
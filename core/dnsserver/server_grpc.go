@@ -0,0 +1,165 @@
+package dnsserver
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/miekg/coredns/pb"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// ServerGRPC represents an instance of a DNS-over-gRPC server. Just like
+// ServerHTTPS it shares the zone configuration with a normal Server and hands
+// the queries to Server.ServeDNS, so routing to the zones is identical.
+type ServerGRPC struct {
+	*Server
+	grpcServer *grpc.Server
+	tlsConfig  *tls.Config
+}
+
+// NewServerGRPC returns a new CoreDNS gRPC server and compiles all middleware in to it.
+func NewServerGRPC(addr string, group []*Config) (*ServerGRPC, error) {
+	s, err := NewServer(addr, group)
+	if err != nil {
+		return nil, err
+	}
+	var tlsConfig *tls.Config
+	for _, conf := range s.zones {
+		tlsConfig = conf.TLSConfig
+	}
+
+	sg := &ServerGRPC{Server: s, tlsConfig: tlsConfig}
+	if tlsConfig != nil {
+		sg.grpcServer = grpc.NewServer(grpc.Creds(credentials.NewTLS(tlsConfig)))
+	} else {
+		sg.grpcServer = grpc.NewServer()
+	}
+	pb.RegisterDnsServiceServer(sg.grpcServer, sg)
+	return sg, nil
+}
+
+// Serve implements caddy.TCPServer interface. Like Server.Serve it wraps l here, so the
+// connection limits also apply to a listener handed over on a reload.
+func (s *ServerGRPC) Serve(l net.Listener) error {
+	l = newLimitListener(l, TransportGRPC, s.maxConns, s.maxConnsPerIP)
+	s.m.Lock()
+	s.l = l
+	s.m.Unlock()
+	s.listening(l.Addr(), nil)
+	return s.grpcServer.Serve(l)
+}
+
+// ServePacket implements caddy.UDPServer interface.
+func (s *ServerGRPC) ServePacket(p net.PacketConn) error { return nil }
+
+// Listen implements caddy.TCPServer interface.
+func (s *ServerGRPC) Listen() (net.Listener, error) {
+	l := activatedListener(s.Addr)
+	if l == nil {
+		var err error
+		if l, err = net.Listen("tcp", s.Addr); err != nil {
+			return nil, err
+		}
+	}
+	s.m.Lock()
+	s.l = l
+	s.m.Unlock()
+	s.listening(l.Addr(), nil)
+	return l, nil
+}
+
+// ListenPacket implements caddy.UDPServer interface.
+func (s *ServerGRPC) ListenPacket() (net.PacketConn, error) { return nil, nil }
+
+// Stop stops the server. It blocks until all RPCs are finished.
+func (s *ServerGRPC) Stop() (err error) {
+	s.m.Lock()
+	g := s.grpcServer
+	s.m.Unlock()
+
+	// Not under s.m: the RPCs in flight, and shutdownHooks, take it as well.
+	if g != nil {
+		g.GracefulStop()
+	}
+	s.shutdownHooks()
+	return
+}
+
+// OnStartupComplete runs the startup hooks of the middleware and lists the sites
+// served by this server and any relevant information, assuming Quiet == false.
+func (s *ServerGRPC) OnStartupComplete() {
+	s.startupHooks()
+	if Quiet {
+		return
+	}
+
+	for zone, config := range s.zoneMap() {
+		fmt.Println(TransportGRPC + "://" + zone + ":" + s.port(config.Port))
+	}
+}
+
+// Query is the main entry-point into the gRPC server. From here we call ServeDNS like
+// any normal server. We use a custom responseWriter to pick up the bytes we need to write
+// back to the client as a protobuf.
+func (s *ServerGRPC) Query(ctx context.Context, in *pb.DnsPacket) (*pb.DnsPacket, error) {
+	msg := new(dns.Msg)
+	if err := msg.Unpack(in.Msg); err != nil {
+		return nil, err
+	}
+	if len(msg.Question) == 0 {
+		return nil, errors.New("no question section in message")
+	}
+
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, errors.New("no peer in gRPC context")
+	}
+	a, ok := p.Addr.(*net.TCPAddr)
+	if !ok {
+		return nil, fmt.Errorf("no TCP peer in gRPC context: %v", p.Addr)
+	}
+
+	w := &gRPCresponse{localAddr: s.LocalAddr(), remoteAddr: a}
+
+	s.ServeDNS(w, msg)
+
+	if w.Msg == nil {
+		return nil, errors.New("no response written")
+	}
+	packed, err := w.Msg.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.DnsPacket{Msg: packed}, nil
+}
+
+// gRPCresponse is a dns.ResponseWriter that captures the message written to it.
+type gRPCresponse struct {
+	localAddr  net.Addr
+	remoteAddr net.Addr
+	Msg        *dns.Msg
+}
+
+// Write is the hack that makes this work. It does not actually write the message
+// but instead stores it in the gRPCresponse.
+func (r *gRPCresponse) Write(b []byte) (int, error) {
+	r.Msg = new(dns.Msg)
+	return len(b), r.Msg.Unpack(b)
+}
+
+// These methods implement the dns.ResponseWriter interface from Go DNS.
+func (r *gRPCresponse) Close() error              { return nil }
+func (r *gRPCresponse) TsigStatus() error         { return nil }
+func (r *gRPCresponse) TsigTimersOnly(b bool)     { return }
+func (r *gRPCresponse) Hijack()                   { return }
+func (r *gRPCresponse) LocalAddr() net.Addr       { return r.localAddr }
+func (r *gRPCresponse) RemoteAddr() net.Addr      { return r.remoteAddr }
+func (r *gRPCresponse) WriteMsg(m *dns.Msg) error { r.Msg = m; return nil }
@@ -0,0 +1,125 @@
+package dnsserver
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/coredns/middleware"
+	"github.com/miekg/coredns/pb"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/peer"
+)
+
+func TestGRPCQuery(t *testing.T) {
+	answer := func(next middleware.Handler) middleware.Handler {
+		return middleware.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+			m := new(dns.Msg)
+			m.SetReply(r)
+			rr, _ := dns.NewRR(r.Question[0].Name + " 300 IN A 127.0.0.1")
+			m.Answer = []dns.RR{rr}
+			w.WriteMsg(m)
+			return dns.RcodeSuccess, nil
+		})
+	}
+	s, err := NewServerGRPC("127.0.0.1:0", []*Config{{Zone: "example.org.", Port: "0", Middleware: []middleware.Middleware{answer}}})
+	if err != nil {
+		t.Fatalf("Failed to create server: %s", err)
+	}
+	l, err := s.Listen()
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	defer l.Close()
+
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4000}})
+
+	m := new(dns.Msg)
+	m.SetQuestion("www.example.org.", dns.TypeA)
+	buf, _ := m.Pack()
+
+	out, err := s.Query(ctx, &pb.DnsPacket{Msg: buf})
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	reply := new(dns.Msg)
+	if err := reply.Unpack(out.Msg); err != nil {
+		t.Fatalf("Failed to unpack reply: %s", err)
+	}
+	if len(reply.Answer) != 1 || reply.Answer[0].Header().Name != "www.example.org." {
+		t.Errorf("Expected answer for www.example.org., got %v", reply.Answer)
+	}
+
+	// Out of zone: REFUSED, like the UDP and TCP servers.
+	m.SetQuestion("example.net.", dns.TypeA)
+	buf, _ = m.Pack()
+	out, err = s.Query(ctx, &pb.DnsPacket{Msg: buf})
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	reply.Unpack(out.Msg)
+	if reply.Rcode != dns.RcodeRefused {
+		t.Errorf("Expected REFUSED, got %s", dns.RcodeToString[reply.Rcode])
+	}
+
+	if _, err := s.Query(context.Background(), &pb.DnsPacket{Msg: buf}); err == nil {
+		t.Errorf("Expected error for query without peer, got none")
+	}
+}
+
+func TestGRPCStop(t *testing.T) {
+	shutdown := false
+	conf := &Config{Zone: "example.org.", Port: "0"}
+	conf.OnShutdown(func() error { shutdown = true; return nil })
+
+	s, err := NewServerGRPC("127.0.0.1:0", []*Config{conf})
+	if err != nil {
+		t.Fatalf("Failed to create server: %s", err)
+	}
+
+	done := make(chan error)
+	go func() { done <- s.Stop() }()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop did not return")
+	}
+	if !shutdown {
+		t.Errorf("Expected the shutdown hooks to run")
+	}
+}
+
+func TestGRPCMaxConns(t *testing.T) {
+	s, err := NewServerGRPC("127.0.0.1:0", []*Config{{Zone: "example.org.", Port: "0", MaxConnsPerIP: 1}})
+	if err != nil {
+		t.Fatalf("Failed to create server: %s", err)
+	}
+	l, err := s.Listen()
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	go s.Serve(l)
+	defer s.Stop()
+
+	c1, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %s", err)
+	}
+	defer c1.Close()
+	time.Sleep(100 * time.Millisecond)
+
+	// The second connection from this IP is over the limit.
+	c2, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %s", err)
+	}
+	defer c2.Close()
+	c2.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := c2.Read(make([]byte, 1)); err == nil {
+		t.Errorf("Expected the second connection to be closed, got data")
+	} else if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		t.Errorf("Expected the second connection to be closed, got %v", err)
+	}
+}
@@ -0,0 +1,287 @@
+package dnsserver
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/miekg/coredns/middleware/pkg/response"
+
+	"github.com/miekg/dns"
+)
+
+// ServerHTTPS represents an instance of a DNS-over-HTTPS server (RFC 8484). It
+// shares the zone configuration, and thus the middleware stacks, with a normal
+// Server, queries are handed to Server.ServeDNS.
+type ServerHTTPS struct {
+	*Server
+	httpsServer *http.Server
+	tlsConfig   *tls.Config
+	jsonAPI     bool
+}
+
+// NewServerHTTPS returns a new CoreDNS DoH server and compiles all middleware in to it.
+func NewServerHTTPS(addr string, group []*Config) (*ServerHTTPS, error) {
+	s, err := NewServer(addr, group)
+	if err != nil {
+		return nil, err
+	}
+	// The *tls* middleware must make sure that multiple conflicting
+	// TLS configuration return an error: it can only be specified once.
+	var tlsConfig *tls.Config
+	jsonAPI := false
+	for _, conf := range s.zones {
+		// Without TLS the queries would be served in cleartext on the https port.
+		if conf.TLSConfig == nil {
+			return nil, fmt.Errorf("%s: DNS-over-HTTPS needs the tls directive", conf)
+		}
+		tlsConfig = conf.TLSConfig
+		jsonAPI = jsonAPI || conf.JSONAPI
+	}
+	if len(tlsConfig.NextProtos) == 0 {
+		// Allow clients to use HTTP/2. The config is shared with the other servers of
+		// the zones, so change a copy.
+		tlsConfig = cloneTLSConfig(tlsConfig)
+		tlsConfig.NextProtos = []string{"h2", "http/1.1"}
+	}
+
+	sh := &ServerHTTPS{Server: s, tlsConfig: tlsConfig, jsonAPI: jsonAPI}
+	sh.httpsServer = &http.Server{Handler: sh, ReadTimeout: s.readTimeout, WriteTimeout: s.writeTimeout}
+	setIdleTimeout(sh.httpsServer, s.idleTimeout)
+	return sh, nil
+}
+
+// Serve implements caddy.TCPServer interface.
+func (s *ServerHTTPS) Serve(l net.Listener) error {
+	l = newLimitListener(l, TransportHTTPS, s.maxConns, s.maxConnsPerIP)
+	l = newTLSListener(l, s.tlsConfig, TransportHTTPS)
+	s.m.Lock()
+	s.l = l
+	s.m.Unlock()
+	s.listening(l.Addr(), nil)
+	return s.httpsServer.Serve(l)
+}
+
+// ServePacket implements caddy.UDPServer interface.
+func (s *ServerHTTPS) ServePacket(p net.PacketConn) error { return nil }
+
+// Listen implements caddy.TCPServer interface.
+func (s *ServerHTTPS) Listen() (net.Listener, error) {
+	l := activatedListener(s.Addr)
+	if l == nil {
+		var err error
+		if l, err = net.Listen("tcp", s.Addr); err != nil {
+			return nil, err
+		}
+	}
+	s.m.Lock()
+	s.l = l
+	s.m.Unlock()
+	s.listening(l.Addr(), nil)
+	return l, nil
+}
+
+// ListenPacket implements caddy.UDPServer interface.
+func (s *ServerHTTPS) ListenPacket() (net.PacketConn, error) { return nil, nil }
+
+// Stop stops the server. It closes the listener, outstanding requests are not
+// waited for.
+func (s *ServerHTTPS) Stop() (err error) {
+	s.m.Lock()
+	if s.l != nil {
+		err = s.l.Close()
+	}
+	s.m.Unlock()
+	s.shutdownHooks()
+	return
+}
+
+// OnStartupComplete runs the startup hooks of the middleware and lists the sites
+// served by this server and any relevant information, assuming Quiet == false.
+func (s *ServerHTTPS) OnStartupComplete() {
+	s.startupHooks()
+	if Quiet {
+		return
+	}
+
+	for zone, config := range s.zoneMap() {
+		fmt.Println(TransportHTTPS + "://" + zone + ":" + s.port(config.Port))
+	}
+}
+
+// ServeHTTP is the handler that gets the HTTP request and converts to the dns format, calls the
+// middleware chain, converts it back and write it to the client.
+func (s *ServerHTTPS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var (
+		msg *dns.Msg
+		err error
+	)
+	switch {
+	case r.URL.Path == pathDOH:
+		msg, err = requestToMsg(r)
+	case r.URL.Path == pathJSON && s.jsonAPI:
+		msg, err = jsonRequestToMsg(r)
+	default:
+		http.Error(w, "", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Create a DoHWriter with the correct addresses in it.
+	h, p, _ := net.SplitHostPort(r.RemoteAddr)
+	port, _ := strconv.Atoi(p)
+	dw := &DoHWriter{laddr: s.LocalAddr(), raddr: &net.TCPAddr{IP: net.ParseIP(h), Port: port}}
+
+	// We just call the normal chain handler - all error handling must be done
+	// in the middleware, this matches what we do in Server.ServeDNS.
+	s.ServeDNS(dw, msg)
+
+	if dw.Msg == nil {
+		http.Error(w, "No response", http.StatusInternalServerError)
+		return
+	}
+
+	mt, _ := response.Classify(dw.Msg)
+	age := minMsgTTL(dw.Msg, mt)
+
+	if r.URL.Path == pathJSON {
+		buf, err := json.Marshal(msgToJSON(dw.Msg))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", mimeTypeJSON)
+		w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", age))
+		w.WriteHeader(http.StatusOK)
+		w.Write(buf)
+		return
+	}
+
+	buf, err := dw.Msg.Pack()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", mimeTypeDOH)
+	w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", age))
+	w.Header().Set("Content-Length", strconv.Itoa(len(buf)))
+	w.WriteHeader(http.StatusOK)
+
+	w.Write(buf)
+}
+
+// requestToMsg extracts the dns message from the request body (POST) or the
+// dns query parameter (GET).
+func requestToMsg(r *http.Request) (*dns.Msg, error) {
+	var buf []byte
+	switch r.Method {
+	case http.MethodGet:
+		b64 := r.URL.Query().Get("dns")
+		if b64 == "" {
+			return nil, fmt.Errorf("no 'dns' query parameter found")
+		}
+		b, err := base64.RawURLEncoding.DecodeString(b64)
+		if err != nil {
+			return nil, err
+		}
+		buf = b
+	case http.MethodPost:
+		if ct := r.Header.Get("Content-Type"); ct != mimeTypeDOH {
+			return nil, fmt.Errorf("unsupported content type: %s", ct)
+		}
+		b, err := ioutil.ReadAll(io.LimitReader(r.Body, dns.MaxMsgSize))
+		r.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		buf = b
+	default:
+		return nil, fmt.Errorf("method not allowed: %s", r.Method)
+	}
+
+	m := new(dns.Msg)
+	if err := m.Unpack(buf); err != nil {
+		return nil, err
+	}
+	if len(m.Question) == 0 {
+		return nil, fmt.Errorf("no question section in message")
+	}
+	return m, nil
+}
+
+// minMsgTTL returns the minimum TTL of the records in m that should be used
+// as the maximum age of the HTTP response.
+func minMsgTTL(m *dns.Msg, mt response.Type) uint32 {
+	switch mt {
+	case response.Success, response.NameError, response.NoData, response.Delegation:
+	default:
+		return 0
+	}
+
+	min := uint32(0)
+	first := true
+	for _, section := range [][]dns.RR{m.Answer, m.Ns} {
+		for _, r := range section {
+			if first || r.Header().Ttl < min {
+				min = r.Header().Ttl
+				first = false
+			}
+		}
+	}
+	return min
+}
+
+// DoHWriter is a dns.ResponseWriter for DNS-over-HTTPS, it captures the message
+// that is written to it.
+type DoHWriter struct {
+	// Msg is the response written.
+	Msg *dns.Msg
+
+	laddr net.Addr
+	raddr net.Addr
+}
+
+// RemoteAddr returns the remote address.
+func (d *DoHWriter) RemoteAddr() net.Addr { return d.raddr }
+
+// LocalAddr returns the local address.
+func (d *DoHWriter) LocalAddr() net.Addr { return d.laddr }
+
+// WriteMsg implements dns.ResponseWriter.
+func (d *DoHWriter) WriteMsg(m *dns.Msg) error {
+	d.Msg = m
+	return nil
+}
+
+// Write implements dns.ResponseWriter.
+func (d *DoHWriter) Write(b []byte) (int, error) {
+	d.Msg = new(dns.Msg)
+	return len(b), d.Msg.Unpack(b)
+}
+
+// Close implements dns.ResponseWriter.
+func (d *DoHWriter) Close() error { return nil }
+
+// TsigStatus implements dns.ResponseWriter.
+func (d *DoHWriter) TsigStatus() error { return nil }
+
+// TsigTimersOnly implements dns.ResponseWriter.
+func (d *DoHWriter) TsigTimersOnly(bool) {}
+
+// Hijack implements dns.ResponseWriter.
+func (d *DoHWriter) Hijack() {}
+
+const (
+	mimeTypeDOH = "application/dns-message"
+	pathDOH     = "/dns-query"
+)
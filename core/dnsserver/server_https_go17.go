@@ -0,0 +1,41 @@
+//go:build !go1.8
+// +build !go1.8
+
+package dnsserver
+
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+)
+
+// setIdleTimeout does nothing, http.Server has no IdleTimeout before Go 1.8: idle
+// keep-alive connections are closed after the ReadTimeout.
+func setIdleTimeout(s *http.Server, d time.Duration) {}
+
+// cloneTLSConfig returns a copy of c. tls.Config has no Clone before Go 1.8 and it
+// can't be copied as a value, as it holds a mutex, so the fields are copied one by
+// one; the fields added in Go 1.7 are left at their defaults.
+func cloneTLSConfig(c *tls.Config) *tls.Config {
+	return &tls.Config{
+		Rand:                     c.Rand,
+		Time:                     c.Time,
+		Certificates:             c.Certificates,
+		NameToCertificate:        c.NameToCertificate,
+		GetCertificate:           c.GetCertificate,
+		RootCAs:                  c.RootCAs,
+		NextProtos:               c.NextProtos,
+		ServerName:               c.ServerName,
+		ClientAuth:               c.ClientAuth,
+		ClientCAs:                c.ClientCAs,
+		InsecureSkipVerify:       c.InsecureSkipVerify,
+		CipherSuites:             c.CipherSuites,
+		PreferServerCipherSuites: c.PreferServerCipherSuites,
+		SessionTicketsDisabled:   c.SessionTicketsDisabled,
+		SessionTicketKey:         c.SessionTicketKey,
+		ClientSessionCache:       c.ClientSessionCache,
+		MinVersion:               c.MinVersion,
+		MaxVersion:               c.MaxVersion,
+		CurvePreferences:         c.CurvePreferences,
+	}
+}
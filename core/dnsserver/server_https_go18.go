@@ -0,0 +1,16 @@
+//go:build go1.8
+// +build go1.8
+
+package dnsserver
+
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+)
+
+// setIdleTimeout sets the time an idle keep-alive connection of s is kept open.
+func setIdleTimeout(s *http.Server, d time.Duration) { s.IdleTimeout = d }
+
+// cloneTLSConfig returns a copy of c.
+func cloneTLSConfig(c *tls.Config) *tls.Config { return c.Clone() }
@@ -0,0 +1,123 @@
+package dnsserver
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// The JSON API follows the format used by Google's and Cloudflare's public
+// resolvers: /resolve?name=example.org&type=AAAA. Optional parameters are cd
+// (checking disabled) and do (DNSSEC OK), both are true when set to "1" or
+// "true".
+
+// jsonMsg is the JSON representation of a DNS response.
+type jsonMsg struct {
+	Status    int            `json:"Status"`
+	TC        bool           `json:"TC"`
+	RD        bool           `json:"RD"`
+	RA        bool           `json:"RA"`
+	AD        bool           `json:"AD"`
+	CD        bool           `json:"CD"`
+	Question  []jsonQuestion `json:"Question"`
+	Answer    []jsonRR       `json:"Answer,omitempty"`
+	Authority []jsonRR       `json:"Authority,omitempty"`
+}
+
+type jsonQuestion struct {
+	Name string `json:"name"`
+	Type uint16 `json:"type"`
+}
+
+type jsonRR struct {
+	Name string `json:"name"`
+	Type uint16 `json:"type"`
+	TTL  uint32 `json:"TTL"`
+	Data string `json:"data"`
+}
+
+// jsonRequestToMsg creates a dns message from the query parameters in r.
+func jsonRequestToMsg(r *http.Request) (*dns.Msg, error) {
+	if r.Method != http.MethodGet {
+		return nil, fmt.Errorf("method not allowed: %s", r.Method)
+	}
+	q := r.URL.Query()
+
+	name := q.Get("name")
+	if name == "" {
+		return nil, fmt.Errorf("no 'name' query parameter found")
+	}
+	if _, ok := dns.IsDomainName(name); !ok {
+		return nil, fmt.Errorf("invalid name: %s", name)
+	}
+
+	qtype := dns.TypeA
+	if t := q.Get("type"); t != "" {
+		var err error
+		if qtype, err = parseQtype(t); err != nil {
+			return nil, err
+		}
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), qtype)
+	m.CheckingDisabled = isTrue(q.Get("cd"))
+	if isTrue(q.Get("do")) {
+		m.SetEdns0(dns.DefaultMsgSize, true)
+	}
+	return m, nil
+}
+
+// msgToJSON converts m to its JSON representation.
+func msgToJSON(m *dns.Msg) *jsonMsg {
+	j := &jsonMsg{
+		Status: m.Rcode,
+		TC:     m.Truncated,
+		RD:     m.RecursionDesired,
+		RA:     m.RecursionAvailable,
+		AD:     m.AuthenticatedData,
+		CD:     m.CheckingDisabled,
+	}
+	for _, q := range m.Question {
+		j.Question = append(j.Question, jsonQuestion{Name: q.Name, Type: q.Qtype})
+	}
+	j.Answer = rrsToJSON(m.Answer)
+	j.Authority = rrsToJSON(m.Ns)
+	return j
+}
+
+func rrsToJSON(rrs []dns.RR) []jsonRR {
+	var js []jsonRR
+	for _, rr := range rrs {
+		h := rr.Header()
+		js = append(js, jsonRR{
+			Name: h.Name,
+			Type: h.Rrtype,
+			TTL:  h.Ttl,
+			Data: strings.TrimPrefix(rr.String(), h.String()),
+		})
+	}
+	return js
+}
+
+// parseQtype parses a query type given as a mnemonic (AAAA) or as a number (28).
+func parseQtype(s string) (uint16, error) {
+	if t, ok := dns.StringToType[strings.ToUpper(s)]; ok {
+		return t, nil
+	}
+	n, err := strconv.ParseUint(s, 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid type: %s", s)
+	}
+	return uint16(n), nil
+}
+
+func isTrue(s string) bool { return s == "1" || strings.ToLower(s) == "true" }
+
+const (
+	mimeTypeJSON = "application/dns-json"
+	pathJSON     = "/resolve"
+)
@@ -0,0 +1,66 @@
+package dnsserver
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/miekg/coredns/middleware/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestJSONRequestToMsg(t *testing.T) {
+	tests := []struct {
+		url       string
+		shouldErr bool
+		qname     string
+		qtype     uint16
+		do        bool
+	}{
+		{"/resolve?name=example.org", false, "example.org.", dns.TypeA, false},
+		{"/resolve?name=example.org.&type=aaaa", false, "example.org.", dns.TypeAAAA, false},
+		{"/resolve?name=example.org&type=15&do=1", false, "example.org.", dns.TypeMX, true},
+		{"/resolve?type=A", true, "", 0, false},
+		{"/resolve?name=example.org&type=BLAAT", true, "", 0, false},
+	}
+
+	for i, tc := range tests {
+		req, _ := http.NewRequest("GET", tc.url, nil)
+		m, err := jsonRequestToMsg(req)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if m.Question[0].Name != tc.qname || m.Question[0].Qtype != tc.qtype {
+			t.Errorf("Test %d: expected question %s %d, got %s", i, tc.qname, tc.qtype, m.Question[0].String())
+		}
+		if do := m.IsEdns0() != nil && m.IsEdns0().Do(); do != tc.do {
+			t.Errorf("Test %d: expected DO bit %t, got %t", i, tc.do, do)
+		}
+	}
+}
+
+func TestMsgToJSON(t *testing.T) {
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	m.Response = true
+	m.Answer = []dns.RR{test.A("example.org.	300	IN	A	127.0.0.1")}
+
+	j := msgToJSON(m)
+	if len(j.Answer) != 1 {
+		t.Fatalf("Expected 1 answer, got %d", len(j.Answer))
+	}
+	a := j.Answer[0]
+	if a.Name != "example.org." || a.Type != dns.TypeA || a.TTL != 300 || a.Data != "127.0.0.1" {
+		t.Errorf("Expected example.org. A 300 127.0.0.1, got %v", a)
+	}
+	if len(j.Question) != 1 || j.Question[0].Name != "example.org." {
+		t.Errorf("Expected question for example.org., got %v", j.Question)
+	}
+}
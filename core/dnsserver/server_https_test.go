@@ -0,0 +1,84 @@
+package dnsserver
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"net/http"
+	"testing"
+
+	"github.com/miekg/coredns/middleware/pkg/response"
+	"github.com/miekg/coredns/middleware/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestRequestToMsg(t *testing.T) {
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	buf, _ := m.Pack()
+
+	get, _ := http.NewRequest("GET", pathDOH+"?dns="+base64.RawURLEncoding.EncodeToString(buf), nil)
+	post, _ := http.NewRequest("POST", pathDOH, bytes.NewReader(buf))
+	post.Header.Set("Content-Type", mimeTypeDOH)
+	badType, _ := http.NewRequest("POST", pathDOH, bytes.NewReader(buf))
+	badType.Header.Set("Content-Type", "text/plain")
+	noParam, _ := http.NewRequest("GET", pathDOH, nil)
+	put, _ := http.NewRequest("PUT", pathDOH, bytes.NewReader(buf))
+
+	tests := []struct {
+		req       *http.Request
+		shouldErr bool
+	}{
+		{get, false},
+		{post, false},
+		{badType, true},
+		{noParam, true},
+		{put, true},
+	}
+
+	for i, tc := range tests {
+		msg, err := requestToMsg(tc.req)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if msg.Question[0].Name != "example.org." || msg.Question[0].Qtype != dns.TypeA {
+			t.Errorf("Test %d: expected question example.org. A, got %s", i, msg.Question[0].String())
+		}
+	}
+}
+
+func TestNewServerHTTPSNeedsTLS(t *testing.T) {
+	if _, err := NewServerHTTPS("127.0.0.1:443", []*Config{{Zone: "example.org.", Port: "443"}}); err == nil {
+		t.Errorf("Expected error for DNS-over-HTTPS without TLS, got none")
+	}
+	s, err := NewServerHTTPS("127.0.0.1:443", []*Config{{Zone: "example.org.", Port: "443", TLSConfig: &tls.Config{}}})
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if len(s.tlsConfig.NextProtos) == 0 {
+		t.Errorf("Expected HTTP/2 to be offered")
+	}
+}
+
+func TestMinMsgTTL(t *testing.T) {
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	m.Answer = []dns.RR{
+		test.A("example.org.	300	IN	A	127.0.0.1"),
+		test.A("example.org.	60	IN	A	127.0.0.2"),
+	}
+	if x := minMsgTTL(m, response.Success); x != 60 {
+		t.Errorf("Expected minimum TTL of 60, got %d", x)
+	}
+	if x := minMsgTTL(m, response.OtherError); x != 0 {
+		t.Errorf("Expected minimum TTL of 0 for errors, got %d", x)
+	}
+}
@@ -0,0 +1,666 @@
+package dnsserver
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/coredns/middleware"
+	"github.com/miekg/coredns/middleware/pkg/cname"
+	"github.com/miekg/coredns/middleware/pkg/dnsrecorder"
+	"github.com/miekg/coredns/middleware/test"
+
+	"github.com/miekg/dns"
+	dto "github.com/prometheus/client_model/go"
+	"golang.org/x/net/context"
+)
+
+// rootHandler answers every query with NOERROR.
+func rootHandler(next middleware.Handler) middleware.Handler {
+	return middleware.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+}
+
+func TestRootFallback(t *testing.T) {
+	tests := []struct {
+		qname          string
+		noRootFallback bool
+		expectedRcode  int
+	}{
+		{"example.org.", false, dns.RcodeSuccess},
+		{"example.org.", true, dns.RcodeRefused},
+		{".", true, dns.RcodeSuccess},
+	}
+
+	for i, tc := range tests {
+		s, err := NewServer("127.0.0.1:53", []*Config{
+			{Zone: ".", Port: "53", NoRootFallback: tc.noRootFallback, Middleware: []middleware.Middleware{rootHandler}},
+		})
+		if err != nil {
+			t.Fatalf("Test %d: failed to create server: %s", i, err)
+		}
+
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, dns.TypeA)
+		rec := dnsrecorder.New(&test.ResponseWriter{})
+		s.ServeDNS(rec, m)
+
+		if rec.Rcode != tc.expectedRcode {
+			t.Errorf("Test %d: expected rcode %s, got %s", i, dns.RcodeToString[tc.expectedRcode], dns.RcodeToString[rec.Rcode])
+		}
+	}
+}
+
+func TestRootFallbackCount(t *testing.T) {
+	tests := []struct {
+		addr     string
+		zones    []string
+		qname    string
+		expected float64
+	}{
+		{"127.0.0.1:1053", []string{"."}, "example.org.", 0},
+		{"127.0.0.1:1054", []string{".", "example.net."}, "example.org.", 1},
+		{"127.0.0.1:1055", []string{".", "example.net."}, "example.net.", 0},
+		{"127.0.0.1:1056", []string{".", "example.net."}, ".", 0},
+	}
+
+	for i, tc := range tests {
+		var group []*Config
+		for _, z := range tc.zones {
+			group = append(group, &Config{Zone: z, Port: "53", Middleware: []middleware.Middleware{rootHandler}})
+		}
+		s, err := NewServer(tc.addr, group)
+		if err != nil {
+			t.Fatalf("Test %d: failed to create server: %s", i, err)
+		}
+
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, dns.TypeA)
+		s.ServeDNS(dnsrecorder.New(&test.ResponseWriter{}), m)
+
+		metric := new(dto.Metric)
+		rootFallbackCount.WithLabelValues(tc.addr).Write(metric)
+		if x := metric.GetCounter().GetValue(); x != tc.expected {
+			t.Errorf("Test %d: expected %v root fallbacks, got %v", i, tc.expected, x)
+		}
+	}
+}
+
+func TestServeNoZone(t *testing.T) {
+	tests := []struct {
+		noZone        int
+		expectedRcode int
+		expectedReply bool
+	}{
+		{NoZoneRefused, dns.RcodeRefused, true},
+		{NoZoneNXDomain, dns.RcodeNameError, true},
+		{NoZoneDrop, 0, false},
+	}
+
+	for i, tc := range tests {
+		s, err := NewServer("127.0.0.1:53", []*Config{
+			{Zone: "example.org.", Port: "53", NoZone: tc.noZone, Middleware: []middleware.Middleware{rootHandler}},
+		})
+		if err != nil {
+			t.Fatalf("Test %d: failed to create server: %s", i, err)
+		}
+
+		m := new(dns.Msg)
+		m.SetQuestion("example.net.", dns.TypeA)
+		rec := dnsrecorder.New(&test.ResponseWriter{})
+		s.ServeDNS(rec, m)
+
+		if (rec.Msg != nil) != tc.expectedReply {
+			t.Errorf("Test %d: expected a reply to be %t, got %t", i, tc.expectedReply, rec.Msg != nil)
+			continue
+		}
+		if tc.expectedReply && rec.Rcode != tc.expectedRcode {
+			t.Errorf("Test %d: expected rcode %s, got %s", i, dns.RcodeToString[tc.expectedRcode], dns.RcodeToString[rec.Rcode])
+		}
+	}
+}
+
+func TestLogLimiter(t *testing.T) {
+	l := newLogLimiter(time.Minute)
+	now := time.Now()
+
+	if _, ok := l.allow("10.0.0.1", now); !ok {
+		t.Fatal("Expected the first line of a host to be logged")
+	}
+	for i := 0; i < 3; i++ {
+		if _, ok := l.allow("10.0.0.1", now.Add(time.Second)); ok {
+			t.Fatal("Expected the lines within the interval to be suppressed")
+		}
+	}
+	if _, ok := l.allow("10.0.0.2", now.Add(time.Second)); !ok {
+		t.Fatal("Expected another host to be logged")
+	}
+	suppressed, ok := l.allow("10.0.0.1", now.Add(time.Minute))
+	if !ok {
+		t.Fatal("Expected a line after the interval to be logged")
+	}
+	if suppressed != 3 {
+		t.Errorf("Expected 3 suppressed lines, got %d", suppressed)
+	}
+}
+
+func TestServeACL(t *testing.T) {
+	acl := &ACL{}
+	acl.Block("10.240.0.0/16") // test.ResponseWriter's address
+
+	s, err := NewServer("127.0.0.1:53", []*Config{
+		{Zone: "example.org.", Port: "53", ACL: acl, Middleware: []middleware.Middleware{rootHandler}},
+		{Zone: "example.net.", Port: "53", Middleware: []middleware.Middleware{rootHandler}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %s", err)
+	}
+
+	tests := []struct {
+		qname         string
+		expectedRcode int
+	}{
+		{"example.org.", dns.RcodeRefused},
+		{"www.example.org.", dns.RcodeRefused},
+		{"example.net.", dns.RcodeSuccess},
+	}
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, dns.TypeA)
+		rec := dnsrecorder.New(&test.ResponseWriter{})
+		s.ServeDNS(rec, m)
+
+		if rec.Rcode != tc.expectedRcode {
+			t.Errorf("Test %d: expected rcode %s, got %s", i, dns.RcodeToString[tc.expectedRcode], dns.RcodeToString[rec.Rcode])
+		}
+	}
+}
+
+func TestServeIDNA(t *testing.T) {
+	s, err := NewServer("127.0.0.1:53", []*Config{
+		{Zone: "xn--bcher-kva.example.", Port: "53", Middleware: []middleware.Middleware{rootHandler}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %s", err)
+	}
+
+	for i, qname := range []string{"www.xn--bcher-kva.example.", `www.b\195\188cher.example.`} {
+		m := new(dns.Msg)
+		m.SetQuestion(qname, dns.TypeA)
+		rec := dnsrecorder.New(&test.ResponseWriter{})
+		s.ServeDNS(rec, m)
+
+		if rec.Rcode != dns.RcodeSuccess {
+			t.Errorf("Test %d: expected NOERROR, got %s", i, dns.RcodeToString[rec.Rcode])
+		}
+		if rec.Msg.Question[0].Name != qname {
+			t.Errorf("Test %d: expected question %s in the reply, got %s", i, qname, rec.Msg.Question[0].Name)
+		}
+	}
+}
+
+func TestServeStop(t *testing.T) {
+	s, err := NewServer("127.0.0.1:0", []*Config{
+		{Zone: ".", Port: "0", Middleware: []middleware.Middleware{rootHandler}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %s", err)
+	}
+	// Serve must work with a listener that did not come from Listen, as happens on a reload.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	go s.Serve(l)
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	c := &dns.Client{Net: "tcp"}
+
+	var resp *dns.Msg
+	for i := 0; i < 10; i++ { // Serve might not be running yet.
+		if resp, _, err = c.Exchange(m, l.Addr().String()); err == nil {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		t.Errorf("Expected NOERROR, got %s", dns.RcodeToString[resp.Rcode])
+	}
+
+	s.Stop()
+}
+
+func TestStopWaitsForQueries(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	slow := func(next middleware.Handler) middleware.Handler {
+		return middleware.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+			close(entered)
+			<-release
+			m := new(dns.Msg)
+			m.SetReply(r)
+			w.WriteMsg(m)
+			return dns.RcodeSuccess, nil
+		})
+	}
+	s, err := NewServer("127.0.0.1:0", []*Config{
+		{Zone: ".", Port: "0", Middleware: []middleware.Middleware{slow}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %s", err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	addr := l.Addr().String()
+	go s.Serve(l)
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	answered := make(chan error)
+	go func() {
+		c := &dns.Client{Net: "tcp"}
+		var err error
+		for i := 0; i < 10; i++ { // Serve might not be running yet.
+			if _, _, err = c.Exchange(m, addr); err == nil {
+				break
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		answered <- err
+	}()
+
+	select {
+	case <-entered:
+	case <-time.After(time.Second):
+		t.Fatal("The query did not reach the middleware")
+	}
+	stopped := make(chan error)
+	go func() { stopped <- s.Stop() }()
+
+	// The listener is closed before Stop waits for the query in flight.
+	closed := false
+	for i := 0; i < 10; i++ {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			closed = true
+			break
+		}
+		conn.Close()
+		time.Sleep(50 * time.Millisecond)
+	}
+	if !closed {
+		t.Error("Expected the listener to be closed while the query is in flight")
+	}
+	select {
+	case <-stopped:
+		t.Fatal("Expected Stop to wait for the query in flight, it didn't")
+	default:
+	}
+
+	close(release)
+	select {
+	case <-stopped:
+	case <-time.After(s.connTimeout / 2):
+		t.Fatal("Stop did not return after the query finished")
+	}
+	if err := <-answered; err != nil {
+		t.Errorf("Expected the query in flight to be answered, got %s", err)
+	}
+}
+
+func TestServePortZero(t *testing.T) {
+	var tcp, udp net.Addr
+	cfg := &Config{Zone: ".", Port: "0", Middleware: []middleware.Middleware{rootHandler}}
+	cfg.OnListening(func(a, b net.Addr) { tcp, udp = a, b })
+	s, err := NewServer("127.0.0.1:0", []*Config{cfg})
+	if err != nil {
+		t.Fatalf("Failed to create server: %s", err)
+	}
+
+	l, err := s.Listen()
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	defer l.Close()
+	if tcp != nil {
+		t.Errorf("Expected no listen hook call before UDP is bound")
+	}
+	p, err := s.ListenPacket()
+	if err != nil {
+		t.Fatalf("Failed to listen on UDP: %s", err)
+	}
+	defer p.Close()
+
+	_, tcpPort, _ := net.SplitHostPort(s.LocalAddr().String())
+	_, udpPort, _ := net.SplitHostPort(s.LocalAddrPacket().String())
+	if tcpPort == "0" || tcpPort != udpPort {
+		t.Errorf("Expected TCP and UDP to be bound to the same port, got %s and %s", tcpPort, udpPort)
+	}
+	if tcp == nil || tcp.String() != s.LocalAddr().String() || udp.String() != s.LocalAddrPacket().String() {
+		t.Errorf("Expected listen hook to be called with %s and %s, got %v and %v", s.LocalAddr(), s.LocalAddrPacket(), tcp, udp)
+	}
+	if port := s.port(cfg.Port); port != tcpPort {
+		t.Errorf("Expected port %s, got %s", tcpPort, port)
+	}
+}
+
+func TestServeMaxConcurrent(t *testing.T) {
+	block := make(chan struct{})
+	inflight := make(chan struct{})
+	blockHandler := func(next middleware.Handler) middleware.Handler {
+		return middleware.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+			inflight <- struct{}{}
+			<-block
+			return rootHandler(nil).ServeDNS(ctx, w, r)
+		})
+	}
+
+	s, err := NewServer("127.0.0.1:53", []*Config{
+		{Zone: ".", Port: "53", MaxConcurrent: 1, Middleware: []middleware.Middleware{blockHandler}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %s", err)
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+
+	done := make(chan struct{})
+	go func() {
+		s.ServeDNS(dnsrecorder.New(&test.ResponseWriter{}), m)
+		close(done)
+	}()
+	<-inflight
+
+	// The first query is still in flight, so this one is over the limit.
+	rec := dnsrecorder.New(&test.ResponseWriter{})
+	s.ServeDNS(rec, m)
+	if rec.Rcode != dns.RcodeServerFailure {
+		t.Errorf("Expected SERVFAIL, got %s", dns.RcodeToString[rec.Rcode])
+	}
+
+	close(block)
+	<-done
+
+	// With the first query done, there is room again.
+	go func() { <-inflight }()
+	rec = dnsrecorder.New(&test.ResponseWriter{})
+	s.ServeDNS(rec, m)
+	if rec.Rcode != dns.RcodeSuccess {
+		t.Errorf("Expected NOERROR, got %s", dns.RcodeToString[rec.Rcode])
+	}
+}
+
+func TestServerTimeouts(t *testing.T) {
+	s, err := NewServer("127.0.0.1:53", []*Config{
+		{Zone: ".", Port: "53", ReadTimeout: time.Second, IdleTimeout: 5 * time.Second, Middleware: []middleware.Middleware{rootHandler}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %s", err)
+	}
+
+	ds := s.newDNSServer("tcp")
+	if ds.ReadTimeout != time.Second {
+		t.Errorf("Expected read timeout of 1s, got %s", ds.ReadTimeout)
+	}
+	if ds.WriteTimeout != 0 {
+		t.Errorf("Expected default write timeout, got %s", ds.WriteTimeout)
+	}
+	if ds.IdleTimeout == nil || ds.IdleTimeout() != 5*time.Second {
+		t.Errorf("Expected idle timeout of 5s")
+	}
+}
+
+func TestServeFlags(t *testing.T) {
+	// etcdHandler sets the AA and RA bits, like the etcd middleware does.
+	etcdHandler := func(next middleware.Handler) middleware.Handler {
+		return middleware.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+			m := new(dns.Msg)
+			m.SetReply(r)
+			m.Authoritative, m.RecursionAvailable = true, true
+			w.WriteMsg(m)
+			return dns.RcodeSuccess, nil
+		})
+	}
+
+	tests := []struct {
+		aa, ra     int
+		recursion  bool
+		expectedAA bool
+		expectedRA bool
+	}{
+		{FlagKeep, FlagKeep, false, true, true},
+		{FlagClear, FlagClear, false, false, false},
+		{FlagKeep, FlagAuto, false, true, false},
+		{FlagKeep, FlagAuto, true, true, true},
+	}
+
+	for i, tc := range tests {
+		s, err := NewServer("127.0.0.1:53", []*Config{
+			{Zone: "example.org.", Port: "53", AA: tc.aa, RA: tc.ra, Middleware: []middleware.Middleware{etcdHandler}},
+			{Zone: "example.net.", Port: "53", Recursion: tc.recursion, Middleware: []middleware.Middleware{rootHandler}},
+		})
+		if err != nil {
+			t.Fatalf("Test %d: failed to create server: %s", i, err)
+		}
+
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		rec := dnsrecorder.New(&test.ResponseWriter{})
+		s.ServeDNS(rec, m)
+
+		if rec.Msg.Authoritative != tc.expectedAA {
+			t.Errorf("Test %d: expected AA to be %t", i, tc.expectedAA)
+		}
+		if rec.Msg.RecursionAvailable != tc.expectedRA {
+			t.Errorf("Test %d: expected RA to be %t", i, tc.expectedRA)
+		}
+	}
+}
+
+func TestServeDeadline(t *testing.T) {
+	var deadline time.Time
+	deadlineHandler := func(next middleware.Handler) middleware.Handler {
+		return middleware.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+			deadline, _ = ctx.Deadline()
+			return rootHandler(nil).ServeDNS(ctx, w, r)
+		})
+	}
+
+	s, err := NewServer("127.0.0.1:53", []*Config{
+		{Zone: ".", Port: "53", QueryTimeout: 2 * time.Second, Middleware: []middleware.Middleware{deadlineHandler}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %s", err)
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	start := time.Now()
+	s.ServeDNS(dnsrecorder.New(&test.ResponseWriter{}), m)
+
+	if deadline.IsZero() {
+		t.Fatal("Expected the context to have a deadline")
+	}
+	if d := deadline.Sub(start); d > 2*time.Second || d < time.Second {
+		t.Errorf("Expected deadline in about 2s, got %s", d)
+	}
+}
+
+func TestServeMaxCNAMEDepth(t *testing.T) {
+	var max int
+	loopHandler := func(next middleware.Handler) middleware.Handler {
+		return middleware.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+			max = cname.MaxDepth(ctx)
+			return dns.RcodeServerFailure, cname.ErrLoop
+		})
+	}
+
+	s, err := NewServer("127.0.0.1:53", []*Config{
+		{Zone: ".", Port: "53", MaxCNAMEDepth: 3, Middleware: []middleware.Middleware{loopHandler}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %s", err)
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	m.SetEdns0(4096, false)
+	rec := dnsrecorder.New(&test.ResponseWriter{})
+	s.ServeDNS(rec, m)
+
+	if max != 3 {
+		t.Errorf("Expected a maximum CNAME depth of 3, got %d", max)
+	}
+	if rec.Msg == nil || rec.Msg.Rcode != dns.RcodeServerFailure {
+		t.Fatalf("Expected SERVFAIL, got %v", rec.Msg)
+	}
+	opt := rec.Msg.IsEdns0()
+	if opt == nil || len(opt.Option) != 1 {
+		t.Fatalf("Expected an extended error in the OPT RR, got %v", opt)
+	}
+	if e, ok := opt.Option[0].(*dns.EDNS0_LOCAL); !ok || e.Code != 15 || string(e.Data[2:]) != "CNAME loop" {
+		t.Errorf("Expected extended error %q, got %v", "CNAME loop", opt.Option[0])
+	}
+}
+
+func TestServeWildcardZone(t *testing.T) {
+	// rcodeHandler answers every query with rcode, so we can tell the zones apart.
+	rcodeHandler := func(rcode int) middleware.Middleware {
+		return func(next middleware.Handler) middleware.Handler {
+			return middleware.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+				m := new(dns.Msg)
+				m.SetRcode(r, rcode)
+				w.WriteMsg(m)
+				return rcode, nil
+			})
+		}
+	}
+
+	s, err := NewServer("127.0.0.1:53", []*Config{
+		{Zone: "example.org.", Port: "53", Middleware: []middleware.Middleware{rcodeHandler(dns.RcodeSuccess)}},
+		{Zone: "*.example.org.", Port: "53", Middleware: []middleware.Middleware{rcodeHandler(dns.RcodeNameError)}},
+		{Zone: "special.example.org.", Port: "53", Middleware: []middleware.Middleware{rcodeHandler(dns.RcodeNotImplemented)}},
+		{Zone: `~^cust[0-9]+\.example\.net\.$`, Port: "53", Middleware: []middleware.Middleware{rcodeHandler(dns.RcodeFormatError)}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %s", err)
+	}
+
+	tests := []struct {
+		qname         string
+		expectedRcode int
+	}{
+		{"example.org.", dns.RcodeSuccess},
+		{"a.example.org.", dns.RcodeNameError},
+		{"www.a.example.org.", dns.RcodeNameError},
+		{"special.example.org.", dns.RcodeNotImplemented},
+		{"www.special.example.org.", dns.RcodeNotImplemented},
+		{"cust42.example.net.", dns.RcodeFormatError},
+		{"CUST42.example.net.", dns.RcodeFormatError},
+		{"www.cust42.example.net.", dns.RcodeRefused},
+		{"example.net.", dns.RcodeRefused},
+	}
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, dns.TypeA)
+		rec := dnsrecorder.New(&test.ResponseWriter{})
+		s.ServeDNS(rec, m)
+
+		if rec.Rcode != tc.expectedRcode {
+			t.Errorf("Test %d: expected rcode %s, got %s", i, dns.RcodeToString[tc.expectedRcode], dns.RcodeToString[rec.Rcode])
+		}
+	}
+
+	if _, err := NewServer("127.0.0.1:53", []*Config{{Zone: "~(", Port: "53"}}); err == nil {
+		t.Errorf("Expected error for invalid regular expression, got none")
+	}
+}
+
+func TestServeHooks(t *testing.T) {
+	started, stopped := 0, 0
+	conf := &Config{Zone: "example.org.", Port: "0", Middleware: []middleware.Middleware{rootHandler}}
+	conf.OnStartupComplete(func() error { started++; return nil })
+	conf.OnShutdown(func() error { stopped++; return nil })
+
+	// The same config in two servers, as happens when a zone is bound to more than one address.
+	s1, err := NewServer("127.0.0.1:0", []*Config{conf})
+	if err != nil {
+		t.Fatalf("Failed to create server: %s", err)
+	}
+	s2, err := NewServer("127.0.0.2:0", []*Config{conf})
+	if err != nil {
+		t.Fatalf("Failed to create server: %s", err)
+	}
+
+	s1.OnStartupComplete()
+	s2.OnStartupComplete()
+	if started != 1 || stopped != 0 {
+		t.Errorf("Expected the startup hook to run once, got %d startups and %d shutdowns", started, stopped)
+	}
+
+	s1.Stop()
+	s2.Stop()
+	if started != 1 || stopped != 1 {
+		t.Errorf("Expected the shutdown hook to run once, got %d startups and %d shutdowns", started, stopped)
+	}
+}
+
+func TestServeHooksReload(t *testing.T) {
+	var started, stopped int32
+	newConf := func() *Config {
+		conf := &Config{Zone: "example.org.", Port: "0", Middleware: []middleware.Middleware{rootHandler}}
+		conf.OnStartupComplete(func() error { atomic.AddInt32(&started, 1); return nil })
+		conf.OnShutdown(func() error { atomic.AddInt32(&stopped, 1); return nil })
+		return conf
+	}
+
+	s1, err := NewServer("127.0.0.1:0", []*Config{newConf()})
+	if err != nil {
+		t.Fatalf("Failed to create server: %s", err)
+	}
+	l, err := s1.Listen()
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	p, err := s1.ListenPacket()
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	s1.OnStartupComplete()
+
+	// On a reload caddy hands the listeners to the new server, and doesn't call
+	// OnStartupComplete.
+	s2, err := NewServer("127.0.0.1:0", []*Config{newConf()})
+	if err != nil {
+		t.Fatalf("Failed to create server: %s", err)
+	}
+	go s2.Serve(l)
+	go s2.ServePacket(p)
+
+	for i := 0; atomic.LoadInt32(&started) != 2; i++ {
+		if i == 100 {
+			t.Fatalf("Expected the startup hooks of the new server to run, got %d startups", atomic.LoadInt32(&started))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	s1.Stop()
+	if n := atomic.LoadInt32(&stopped); n != 1 {
+		t.Errorf("Expected the shutdown hook of the old server to run, got %d shutdowns", n)
+	}
+	s2.Stop()
+	if n := atomic.LoadInt32(&stopped); n != 2 {
+		t.Errorf("Expected the shutdown hook of the new server to run, got %d shutdowns", n)
+	}
+}
@@ -0,0 +1,80 @@
+package dnsserver
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+)
+
+// ServerTLS represents an instance of a DNS-over-TLS server. The queries are framed as on
+// TCP and handed to Server.ServeDNS, so routing to the zones is identical.
+type ServerTLS struct {
+	*Server
+	tlsConfig *tls.Config
+}
+
+// NewServerTLS returns a new CoreDNS DoT server and compiles all middleware in to it.
+func NewServerTLS(addr string, group []*Config) (*ServerTLS, error) {
+	s, err := NewServer(addr, group)
+	if err != nil {
+		return nil, err
+	}
+	var tlsConfig *tls.Config
+	for _, conf := range s.zones {
+		// Without TLS the queries would be served in cleartext on the DoT port.
+		if conf.TLSConfig == nil {
+			return nil, fmt.Errorf("%s: DNS-over-TLS needs the tls directive", conf)
+		}
+		tlsConfig = conf.TLSConfig
+	}
+	return &ServerTLS{Server: s, tlsConfig: tlsConfig}, nil
+}
+
+// Serve implements caddy.TCPServer interface. It blocks until the server stops.
+func (s *ServerTLS) Serve(l net.Listener) error {
+	l = newLimitListener(l, TransportTLS, s.maxConns, s.maxConnsPerIP)
+	l = newTLSListener(l, s.tlsConfig, TransportTLS)
+	s.m.Lock()
+	s.l = l
+	s.server[tcp] = s.newDNSServer("tcp")
+	s.server[tcp].Listener = l
+	s.m.Unlock()
+	s.listening(l.Addr(), nil)
+
+	return s.server[tcp].ActivateAndServe()
+}
+
+// ServePacket implements caddy.UDPServer interface.
+func (s *ServerTLS) ServePacket(p net.PacketConn) error { return nil }
+
+// Listen implements caddy.TCPServer interface.
+func (s *ServerTLS) Listen() (net.Listener, error) {
+	l := activatedListener(s.Addr)
+	if l == nil {
+		var err error
+		if l, err = net.Listen("tcp", s.Addr); err != nil {
+			return nil, err
+		}
+	}
+	s.m.Lock()
+	s.l = l
+	s.m.Unlock()
+	s.listening(l.Addr(), nil)
+	return l, nil
+}
+
+// ListenPacket implements caddy.UDPServer interface.
+func (s *ServerTLS) ListenPacket() (net.PacketConn, error) { return nil, nil }
+
+// OnStartupComplete runs the startup hooks of the middleware and lists the sites
+// served by this server and any relevant information, assuming Quiet == false.
+func (s *ServerTLS) OnStartupComplete() {
+	s.startupHooks()
+	if Quiet {
+		return
+	}
+
+	for zone, config := range s.zoneMap() {
+		fmt.Println(TransportTLS + "://" + zone + ":" + s.port(config.Port))
+	}
+}
@@ -0,0 +1,83 @@
+package dnsserver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/miekg/coredns/middleware"
+
+	"github.com/miekg/dns"
+)
+
+// selfSigned returns a TLS configuration with a self-signed certificate.
+func selfSigned(t *testing.T) *tls.Config {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "dns.example.org"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+}
+
+func TestNewServerTLSNeedsTLS(t *testing.T) {
+	if _, err := NewServerTLS("127.0.0.1:853", []*Config{{Zone: "example.org.", Port: "853"}}); err == nil {
+		t.Errorf("Expected error for DNS-over-TLS without TLS, got none")
+	}
+}
+
+func TestServeTLS(t *testing.T) {
+	s, err := NewServerTLS("127.0.0.1:0", []*Config{
+		{Zone: ".", Port: "0", TLSConfig: selfSigned(t), Middleware: []middleware.Middleware{rootHandler}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %s", err)
+	}
+	l, err := s.Listen()
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	go s.Serve(l)
+	defer s.Stop()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	c := &dns.Client{Net: "tcp-tls", TLSConfig: &tls.Config{InsecureSkipVerify: true}}
+
+	var resp *dns.Msg
+	for i := 0; i < 10; i++ { // Serve might not be running yet.
+		if resp, _, err = c.Exchange(m, l.Addr().String()); err == nil {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		t.Errorf("Expected NOERROR, got %s", dns.RcodeToString[resp.Rcode])
+	}
+
+	// A client that doesn't speak TLS gets no answer.
+	c = &dns.Client{Net: "tcp", ReadTimeout: time.Second}
+	if _, _, err := c.Exchange(m, l.Addr().String()); err == nil {
+		t.Error("Expected an error for a query without TLS")
+	}
+}
@@ -0,0 +1,118 @@
+package dnsserver
+
+import (
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/miekg/dns"
+)
+
+// ServerUnix represents an instance of a DNS server on a Unix domain socket. The queries
+// are framed as on TCP, with a 2 byte length, and handed to Server.ServeDNS, so routing
+// to the zones is identical.
+type ServerUnix struct {
+	*Server
+	mode os.FileMode
+}
+
+// NewServerUnix returns a new CoreDNS server on the Unix socket at path and compiles all
+// middleware in to it.
+func NewServerUnix(path string, group []*Config) (*ServerUnix, error) {
+	s, err := NewServer(path, group)
+	if err != nil {
+		return nil, err
+	}
+	var mode os.FileMode
+	for _, conf := range s.zones {
+		mode = conf.UnixMode
+	}
+	return &ServerUnix{Server: s, mode: mode}, nil
+}
+
+// Listen implements caddy.TCPServer interface. A socket file that is left behind by a
+// server that is gone is removed, one that a server still accepts connections on is an
+// error.
+func (s *ServerUnix) Listen() (net.Listener, error) {
+	if fi, err := os.Stat(s.Addr); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", s.Addr)
+		}
+		if c, err := net.Dial("unix", s.Addr); err == nil {
+			c.Close()
+			return nil, fmt.Errorf("%s is in use", s.Addr)
+		}
+		if err := os.Remove(s.Addr); err != nil {
+			return nil, err
+		}
+	}
+
+	l, err := net.Listen("unix", s.Addr)
+	if err != nil {
+		return nil, err
+	}
+	// On a reload the listener is handed to the new server and closed here; the socket
+	// file must stay for it.
+	keepSocketFile(l.(*net.UnixListener))
+	if s.mode != 0 {
+		if err := os.Chmod(s.Addr, s.mode); err != nil {
+			l.Close()
+			return nil, err
+		}
+	}
+
+	s.m.Lock()
+	s.l = l
+	s.m.Unlock()
+	s.listening(l.Addr(), nil)
+	return l, nil
+}
+
+// Serve implements caddy.TCPServer interface. It blocks until the server stops.
+func (s *ServerUnix) Serve(l net.Listener) error {
+	s.m.Lock()
+	s.l = l
+	s.server[tcp] = s.newDNSServer("tcp")
+	s.server[tcp].Listener = l
+	h := s.server[tcp].Handler
+	s.server[tcp].Handler = dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		h.ServeDNS(&unixWriter{w}, r)
+	})
+	s.m.Unlock()
+	s.listening(l.Addr(), nil)
+
+	return s.server[tcp].ActivateAndServe()
+}
+
+// ListenPacket implements caddy.UDPServer interface.
+func (s *ServerUnix) ListenPacket() (net.PacketConn, error) { return nil, nil }
+
+// ServePacket implements caddy.UDPServer interface.
+func (s *ServerUnix) ServePacket(p net.PacketConn) error { return nil }
+
+// OnStartupComplete runs the startup hooks of the middleware and lists the sites
+// served by this server and any relevant information, assuming Quiet == false.
+func (s *ServerUnix) OnStartupComplete() {
+	s.startupHooks()
+	if Quiet {
+		return
+	}
+
+	for zone := range s.zoneMap() {
+		fmt.Println(zone + " " + TransportUnix + "://" + s.Addr)
+	}
+}
+
+// unixWriter is a dns.ResponseWriter for a query that came in on a Unix socket. The
+// client has no address, middleware see it as a TCP client on the loopback address,
+// so the reply isn't truncated. All clients on the socket share that address: access
+// lists and rate limits can't tell them apart, the mode of the socket file is the only
+// access control.
+type unixWriter struct {
+	dns.ResponseWriter
+}
+
+// RemoteAddr implements the dns.ResponseWriter interface.
+func (w *unixWriter) RemoteAddr() net.Addr { return unixClientAddr }
+
+var unixClientAddr = &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}
@@ -0,0 +1,10 @@
+//go:build !go1.8
+// +build !go1.8
+
+package dnsserver
+
+import "net"
+
+// keepSocketFile does nothing, before Go 1.8 a Unix listener always removes its socket
+// file when it is closed. A reload then leaves the new server without one.
+func keepSocketFile(l *net.UnixListener) {}
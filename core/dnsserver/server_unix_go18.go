@@ -0,0 +1,9 @@
+//go:build go1.8
+// +build go1.8
+
+package dnsserver
+
+import "net"
+
+// keepSocketFile makes l leave its socket file when it is closed.
+func keepSocketFile(l *net.UnixListener) { l.SetUnlinkOnClose(false) }
@@ -0,0 +1,99 @@
+package dnsserver
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/miekg/coredns/middleware"
+	"github.com/miekg/coredns/request"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+func TestUnixQuery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coredns-unix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "dns.sock")
+
+	answer := func(next middleware.Handler) middleware.Handler {
+		return middleware.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+			state := request.Request{W: w, Req: r}
+			m := new(dns.Msg)
+			m.SetReply(r)
+			rr, _ := dns.NewRR(r.Question[0].Name + " 300 IN TXT " + state.IP() + " " + state.Proto())
+			m.Answer = []dns.RR{rr}
+			w.WriteMsg(m)
+			return dns.RcodeSuccess, nil
+		})
+	}
+	config := &Config{Zone: "example.org.", Port: "53", UnixSocket: path, UnixMode: 0600, Middleware: []middleware.Middleware{answer}}
+
+	// A socket file left behind is removed.
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	s, err := NewServerUnix(path, []*Config{config})
+	if err != nil {
+		t.Fatalf("Failed to create server: %s", err)
+	}
+	l, err := s.Listen()
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	go s.Serve(l)
+	defer s.Stop()
+
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("Expected socket with mode 0600, got %v, %v", fi, err)
+	}
+
+	// A socket that is in use is not taken over.
+	s1, _ := NewServerUnix(path, []*Config{config})
+	if _, err := s1.Listen(); err == nil {
+		t.Errorf("Expected error for socket in use, got none")
+	}
+
+	c, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("Failed to dial: %s", err)
+	}
+	co := &dns.Conn{Conn: c}
+	defer co.Close()
+
+	m := new(dns.Msg)
+	m.SetQuestion("www.example.org.", dns.TypeTXT)
+	if err := co.WriteMsg(m); err != nil {
+		t.Fatalf("Failed to write query: %s", err)
+	}
+	reply, err := co.ReadMsg()
+	if err != nil {
+		t.Fatalf("Failed to read reply: %s", err)
+	}
+	if len(reply.Answer) != 1 {
+		t.Fatalf("Expected 1 answer, got %v", reply.Answer)
+	}
+	if txt := reply.Answer[0].(*dns.TXT).Txt; len(txt) != 2 || txt[0] != "127.0.0.1" || txt[1] != "tcp" {
+		t.Errorf("Expected client 127.0.0.1 over tcp, got %v", txt)
+	}
+
+	// A second query on the same connection, out of zone.
+	m.SetQuestion("example.net.", dns.TypeA)
+	co.WriteMsg(m)
+	if reply, err = co.ReadMsg(); err != nil {
+		t.Fatalf("Failed to read reply: %s", err)
+	}
+	if reply.Rcode != dns.RcodeRefused {
+		t.Errorf("Expected REFUSED, got %s", dns.RcodeToString[reply.Rcode])
+	}
+}
@@ -0,0 +1,71 @@
+package dnsserver
+
+import (
+	"sync/atomic"
+
+	"github.com/miekg/coredns/middleware"
+	"github.com/miekg/coredns/middleware/pkg/rcode"
+
+	"github.com/miekg/dns"
+	ot "github.com/opentracing/opentracing-go"
+	"golang.org/x/net/context"
+)
+
+// When a zone has a Tracer, a sample of its queries is traced: the query gets a span
+// and every middleware that handles it gets a child span. The spans record the latency
+// of the middleware, the rcode it returned and whether it called the next middleware.
+// The span of the running middleware is in the context (see ot.SpanFromContext), so
+// middleware can add their own child spans.
+
+// layer is the span of a middleware that is handling a query.
+type layer struct {
+	span ot.Span
+	next bool // the middleware called the next middleware
+}
+
+type layerKey struct{}
+
+// spanHandler traces the middleware it wraps.
+type spanHandler struct {
+	middleware.Handler
+	name string
+}
+
+// ServeDNS implements the middleware.Handler interface.
+func (s spanHandler) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	parent, ok := ctx.Value(layerKey{}).(*layer)
+	if !ok {
+		// This query is not traced.
+		return s.Handler.ServeDNS(ctx, w, r)
+	}
+	parent.next = true
+
+	l := &layer{span: parent.span.Tracer().StartSpan(s.name, ot.ChildOf(parent.span.Context()))}
+	ctx = ot.ContextWithSpan(context.WithValue(ctx, layerKey{}, l), l.span)
+
+	rc, err := s.Handler.ServeDNS(ctx, w, r)
+
+	l.span.SetTag("rcode", rcode.ToString(rc))
+	l.span.SetTag("next", l.next)
+	if err != nil {
+		l.span.SetTag("error", err.Error())
+	}
+	l.span.Finish()
+	return rc, err
+}
+
+// startSpan returns the context for r with the span for the query in it, if r is
+// sampled. The span must be finished by the caller.
+func startSpan(ctx context.Context, h *Config, w dns.ResponseWriter, r *dns.Msg) (context.Context, ot.Span) {
+	if h.TraceEvery > 1 && atomic.AddUint32(&h.traceCount, 1)%h.TraceEvery != 0 {
+		return ctx, nil
+	}
+	span := h.Tracer.StartSpan("servedns")
+	span.SetTag("zone", h.Zone)
+	span.SetTag("qname", r.Question[0].Name)
+	span.SetTag("qtype", dns.Type(r.Question[0].Qtype).String())
+	span.SetTag("client", w.RemoteAddr().String())
+
+	ctx = ot.ContextWithSpan(context.WithValue(ctx, layerKey{}, &layer{span: span}), span)
+	return ctx, span
+}
@@ -0,0 +1,66 @@
+package dnsserver
+
+import (
+	"testing"
+
+	"github.com/miekg/coredns/middleware"
+	"github.com/miekg/coredns/middleware/test"
+
+	"github.com/miekg/dns"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"golang.org/x/net/context"
+)
+
+func TestServeSpan(t *testing.T) {
+	outer := func(next middleware.Handler) middleware.Handler {
+		return middleware.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+			return next.ServeDNS(ctx, w, r)
+		})
+	}
+	inner := func(next middleware.Handler) middleware.Handler { return slowHandler{} }
+
+	tracer := mocktracer.New()
+	s, err := NewServer("127.0.0.1:53", []*Config{
+		{Zone: ".", Port: "53", Tracer: tracer, TraceEvery: 2, Middleware: []middleware.Middleware{outer, inner}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %s", err)
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	for i := 0; i < 4; i++ {
+		s.ServeDNS(&test.ResponseWriter{}, m)
+	}
+
+	spans := tracer.FinishedSpans()
+	// Every other query is traced, each gives three spans: the query and the two middleware.
+	if len(spans) != 6 {
+		t.Fatalf("Expected 6 spans, got %d", len(spans))
+	}
+	// Spans are finished from the inside out.
+	expected := []struct {
+		name string
+		next bool
+	}{
+		{"dnsserver", false},
+		{"middleware", true},
+	}
+	for i, e := range expected {
+		if spans[i].OperationName != e.name {
+			t.Errorf("Expected span %d to be %s, got %s", i, e.name, spans[i].OperationName)
+		}
+		if next := spans[i].Tag("next"); next != e.next {
+			t.Errorf("Expected span %d to have next %t, got %v", i, e.next, next)
+		}
+		if rc := spans[i].Tag("rcode"); rc != "NOERROR" {
+			t.Errorf("Expected span %d to have rcode NOERROR, got %v", i, rc)
+		}
+	}
+	if spans[0].ParentID != spans[1].SpanContext.SpanID || spans[1].ParentID != spans[2].SpanContext.SpanID {
+		t.Errorf("Expected the middleware spans to be nested")
+	}
+	if spans[2].OperationName != "servedns" || spans[2].Tag("qname") != "example.org." {
+		t.Errorf("Expected servedns span for example.org., got %s for %v", spans[2].OperationName, spans[2].Tag("qname"))
+	}
+}
@@ -0,0 +1,187 @@
+package dnsserver
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// startupHook is the startup hook of a middleware, see OnStartupAfter.
+type startupHook struct {
+	name  string
+	after []string
+	fn    func() error
+}
+
+// OnStartupAfter registers fn as a startup hook of the middleware name. It is called when
+// the server for this config has started, after the hooks registered with
+// OnStartupComplete and after the startup hooks of the middleware in after have returned.
+// Middleware in after that have no startup hook in this config are ignored. If a hook
+// doesn't return within the StartupTimeout of the config, this is logged and the hooks
+// that come after it are called anyway.
+func (c *Config) OnStartupAfter(name string, after []string, fn func() error) {
+	c.orderedHooks = append(c.orderedHooks, startupHook{name: name, after: after, fn: fn})
+}
+
+// startupOrder returns hooks sorted so that every hook comes after the hooks it depends
+// on, other hooks keep the order they were registered in. It returns an error if hooks
+// depend on each other.
+func startupOrder(hooks []startupHook) ([]startupHook, error) {
+	byName := make(map[string][]startupHook)
+	names := []string{}
+	for _, h := range hooks {
+		if _, ok := byName[h.name]; !ok {
+			names = append(names, h.name)
+		}
+		byName[h.name] = append(byName[h.name], h)
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int)
+	order := make([]startupHook, 0, len(hooks))
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			// Only report the middleware that are part of the cycle.
+			for i := range path {
+				if path[i] == name {
+					path = path[i:]
+					break
+				}
+			}
+			return fmt.Errorf("startup hooks depend on each other: %s", strings.Join(append(path, name), " -> "))
+		}
+		state[name] = visiting
+		for _, h := range byName[name] {
+			for _, a := range h.after {
+				if _, ok := byName[a]; !ok {
+					continue
+				}
+				if err := visit(a, append(path, name)); err != nil {
+					return err
+				}
+			}
+		}
+		state[name] = visited
+		order = append(order, byName[name]...)
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// What is done when the startup hook of a middleware returns an error, see StartupPolicy.
+const (
+	StartupDegrade = iota // log the error and serve the zone anyway, the default
+	StartupFail           // exit the process
+	StartupRetry          // log the error, serve the zone anyway and call the hook again until it succeeds
+)
+
+// StartupPolicy is what is done when the startup hook of a middleware fails, because the
+// backend it needs is unreachable for instance.
+type StartupPolicy struct {
+	Action int // StartupDegrade, StartupFail or StartupRetry
+
+	// With StartupRetry, the wait before the first retry. It is doubled after every failed
+	// retry, up to MaxBackoff. 0 uses the defaults of 1 second and 1 minute.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// runOrdered calls the hooks one after the other, failures are handled according to the
+// StartupPolicies of c. A hook that takes longer than timeout, or that is retried, is left
+// running and the next one is called.
+func (c *Config) runOrdered(hooks []startupHook, timeout time.Duration) {
+	for _, h := range hooks {
+		done := make(chan struct{})
+		go c.runHook(h, done)
+
+		select {
+		case <-done:
+		case <-time.After(timeout):
+			log.Printf("[WARNING] startup hook of %s for %s did not return within %s, continuing", h.name, c.Zone, timeout)
+		}
+	}
+}
+
+// runHook calls the hook h and closes done when it returns the first time.
+func (c *Config) runHook(h startupHook, done chan struct{}) {
+	err := h.fn()
+	close(done)
+	if err == nil {
+		return
+	}
+	startupFailures.WithLabelValues(c.Zone, h.name).Inc()
+
+	policy := c.StartupPolicies[h.name]
+	switch policy.Action {
+	case StartupFail:
+		startupFatal("[FATAL] startup hook of %s for %s: %s", h.name, c.Zone, err)
+	case StartupRetry:
+		c.retry(h, policy, err)
+	default:
+		log.Printf("[ERROR] startup hook of %s for %s: %s", h.name, c.Zone, err)
+	}
+}
+
+// retry calls the hook h, which failed with err, again with an exponential backoff until
+// it succeeds or c is shut down.
+func (c *Config) retry(h startupHook, policy StartupPolicy, err error) {
+	wait, max := policy.Backoff, policy.MaxBackoff
+	if wait == 0 {
+		wait = defaultBackoff
+	}
+	if max == 0 {
+		max = defaultMaxBackoff
+	}
+	if wait > max {
+		wait = max
+	}
+	for {
+		log.Printf("[ERROR] startup hook of %s for %s: %s, retrying in %s", h.name, c.Zone, err, wait)
+		t := time.NewTimer(wait)
+		select {
+		case <-c.retryStop():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+		if err = h.fn(); err == nil {
+			log.Printf("[INFO] startup hook of %s for %s succeeded", h.name, c.Zone)
+			return
+		}
+		startupFailures.WithLabelValues(c.Zone, h.name).Inc()
+		if wait *= 2; wait > max {
+			wait = max
+		}
+	}
+}
+
+// retryStop returns the channel that is closed when c is shut down.
+func (c *Config) retryStop() chan struct{} {
+	c.retriesOnce.Do(func() { c.stopRetries = make(chan struct{}) })
+	return c.stopRetries
+}
+
+// startupFatal is called when a hook with the StartupFail policy fails; tests replace it.
+var startupFatal = log.Fatalf
+
+const (
+	defaultStartupTimeout = 30 * time.Second
+
+	defaultBackoff    = time.Second
+	defaultMaxBackoff = time.Minute
+)
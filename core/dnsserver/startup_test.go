@@ -0,0 +1,155 @@
+package dnsserver
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStartupOrder(t *testing.T) {
+	hook := func(name string, after ...string) startupHook {
+		return startupHook{name: name, after: after, fn: func() error { return nil }}
+	}
+	names := func(hooks []startupHook) string {
+		s := []string{}
+		for _, h := range hooks {
+			s = append(s, h.name)
+		}
+		return strings.Join(s, " ")
+	}
+
+	tests := []struct {
+		hooks    []startupHook
+		expected string
+		err      string
+	}{
+		{[]startupHook{hook("file"), hook("etcd")}, "file etcd", ""},
+		{[]startupHook{hook("cache", "file", "etcd"), hook("file"), hook("etcd")}, "file etcd cache", ""},
+		// Hooks of a middleware stay together, missing dependencies are ignored.
+		{[]startupHook{hook("file"), hook("cache", "file", "kubernetes"), hook("file")}, "file file cache", ""},
+		{[]startupHook{hook("a", "c"), hook("b", "a"), hook("c")}, "c a b", ""},
+		{[]startupHook{hook("a", "b"), hook("b", "a")}, "", "a -> b -> a"},
+		{[]startupHook{hook("x", "a"), hook("a", "b"), hook("b", "c"), hook("c", "a")}, "", "a -> b -> c -> a"},
+		{[]startupHook{hook("a", "a")}, "", "a -> a"},
+	}
+	for i, tc := range tests {
+		order, err := startupOrder(tc.hooks)
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("Test %d: expected error containing %q, got %v", i, tc.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if x := names(order); x != tc.expected {
+			t.Errorf("Test %d: expected order %q, got %q", i, tc.expected, x)
+		}
+	}
+}
+
+func TestStartupTimeout(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	done := make(chan struct{})
+
+	c := &Config{Zone: "example.org.", StartupTimeout: 50 * time.Millisecond}
+	c.OnStartupAfter("cache", []string{"secondary"}, func() error {
+		close(done)
+		return nil
+	})
+	c.OnStartupAfter("secondary", nil, func() error {
+		<-block
+		return nil
+	})
+
+	go c.startup()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the startup hook to be called after the timeout of the one it depends on")
+	}
+}
+
+func TestStartupPolicy(t *testing.T) {
+	fatal := startupFatal
+	defer func() { startupFatal = fatal }()
+	failed := make(chan string, 1)
+	startupFatal = func(format string, v ...interface{}) { failed <- v[0].(string) }
+
+	c := &Config{Zone: "example.org.", StartupPolicies: map[string]StartupPolicy{
+		"etcd":       {Action: StartupFail},
+		"kubernetes": {Action: StartupRetry, Backoff: 10 * time.Millisecond},
+	}}
+	var calls struct {
+		sync.Mutex
+		file, kubernetes int
+	}
+	c.OnStartupAfter("file", nil, func() error {
+		calls.Lock()
+		defer calls.Unlock()
+		calls.file++
+		return errors.New("file")
+	})
+	c.OnStartupAfter("etcd", nil, func() error { return errors.New("etcd") })
+	succeeded := make(chan struct{})
+	c.OnStartupAfter("kubernetes", nil, func() error {
+		calls.Lock()
+		defer calls.Unlock()
+		calls.kubernetes++
+		if calls.kubernetes < 3 {
+			return errors.New("kubernetes")
+		}
+		close(succeeded)
+		return nil
+	})
+
+	c.startup()
+	defer c.shutdown()
+
+	select {
+	case name := <-failed:
+		if name != "etcd" {
+			t.Errorf("Expected the failure of etcd to be fatal, got %s", name)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the failure of etcd to be fatal")
+	}
+	select {
+	case <-succeeded:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the startup hook of kubernetes to be retried until it succeeds")
+	}
+
+	calls.Lock()
+	defer calls.Unlock()
+	if calls.file != 1 {
+		t.Errorf("Expected the startup hook of file to be called once, got %d", calls.file)
+	}
+	if calls.kubernetes != 3 {
+		t.Errorf("Expected the startup hook of kubernetes to be called 3 times, got %d", calls.kubernetes)
+	}
+}
+
+func TestStartupRetryStop(t *testing.T) {
+	calls := make(chan struct{}, 10)
+	c := &Config{Zone: "example.org.", StartupPolicies: map[string]StartupPolicy{
+		"etcd": {Action: StartupRetry, Backoff: 10 * time.Millisecond},
+	}}
+	c.OnStartupAfter("etcd", nil, func() error {
+		calls <- struct{}{}
+		return errors.New("etcd")
+	})
+
+	c.startup()
+	<-calls
+	c.shutdown()
+	time.Sleep(50 * time.Millisecond)
+	if n := len(calls); n > 1 {
+		t.Errorf("Expected no retries after the shutdown, got %d", n)
+	}
+}
@@ -0,0 +1,105 @@
+package dnsserver
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/miekg/coredns/middleware"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+// Middleware can be made switchable, it can then be switched off and on at runtime,
+// without a reload. This is meant for heavyweight diagnostics, like query logging,
+// that should only run during an incident. A switchable middleware is wrapped in a
+// switchHandler when the chain is compiled; when it is off, the switchHandler calls
+// the next middleware directly. Middleware are known by their name, this is the name
+// of the package that implements them.
+
+var switches struct {
+	sync.RWMutex
+	names map[string]bool // switchable middleware
+	off   map[string]bool // middleware that are switched off, this survives a reload
+}
+
+// AddSwitchable makes the middleware in names switchable. It must be called before the
+// servers are created, i.e. from the setup function of a directive. On a reload the
+// middleware are not switchable anymore, unless AddSwitchable is called again.
+func AddSwitchable(names []string) {
+	switches.Lock()
+	defer switches.Unlock()
+	if switches.names == nil {
+		switches.names = make(map[string]bool)
+	}
+	for _, n := range names {
+		switches.names[n] = true
+	}
+}
+
+// resetSwitchable makes all middleware not switchable. It is called when a new
+// configuration is loaded.
+func resetSwitchable() {
+	switches.Lock()
+	defer switches.Unlock()
+	switches.names = nil
+}
+
+// SwitchMiddleware switches the middleware name on or off.
+func SwitchMiddleware(name string, on bool) error {
+	switches.Lock()
+	defer switches.Unlock()
+	if !switches.names[name] {
+		return fmt.Errorf("middleware %s is not switchable", name)
+	}
+	if switches.off == nil {
+		switches.off = make(map[string]bool)
+	}
+	switches.off[name] = !on
+	return nil
+}
+
+// SwitchableMiddleware returns the names of the switchable middleware, sorted, and
+// whether they are on.
+func SwitchableMiddleware() ([]string, []bool) {
+	switches.RLock()
+	defer switches.RUnlock()
+	names := make([]string, 0, len(switches.names))
+	for n := range switches.names {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	on := make([]bool, len(names))
+	for i, n := range names {
+		on[i] = !switches.off[n]
+	}
+	return names, on
+}
+
+func switchable(name string) bool {
+	switches.RLock()
+	defer switches.RUnlock()
+	return switches.names[name]
+}
+
+func switchedOff(name string) bool {
+	switches.RLock()
+	defer switches.RUnlock()
+	return switches.off[name]
+}
+
+// switchHandler calls on, or next when the middleware name is switched off.
+type switchHandler struct {
+	on   middleware.Handler
+	next middleware.Handler
+	name string
+}
+
+// ServeDNS implements the middleware.Handler interface.
+func (s switchHandler) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	if switchedOff(s.name) {
+		return s.next.ServeDNS(ctx, w, r)
+	}
+	return s.on.ServeDNS(ctx, w, r)
+}
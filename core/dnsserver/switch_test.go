@@ -0,0 +1,70 @@
+package dnsserver
+
+import (
+	"testing"
+
+	"github.com/miekg/coredns/middleware"
+	"github.com/miekg/coredns/middleware/pkg/dnsrecorder"
+	"github.com/miekg/coredns/middleware/test"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+// countHandler counts the queries it sees and passes them on.
+type countHandler struct {
+	next  middleware.Handler
+	count *int
+}
+
+func (c countHandler) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	*c.count++
+	return c.next.ServeDNS(ctx, w, r)
+}
+
+func TestSwitchMiddleware(t *testing.T) {
+	AddSwitchable([]string{"dnsserver"})
+	defer resetSwitchable()
+
+	count := 0
+	counter := func(next middleware.Handler) middleware.Handler { return countHandler{next: next, count: &count} }
+	s, err := NewServer("127.0.0.1:53", []*Config{
+		{Zone: ".", Port: "53", Middleware: []middleware.Middleware{counter, rootHandler}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %s", err)
+	}
+
+	tests := []struct {
+		on            bool
+		expectedCount int
+	}{
+		{true, 1},
+		{false, 1},
+		{true, 2},
+	}
+	for i, tc := range tests {
+		if err := SwitchMiddleware("dnsserver", tc.on); err != nil {
+			t.Fatalf("Test %d: expected no error, got %s", i, err)
+		}
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		rec := dnsrecorder.New(&test.ResponseWriter{})
+		s.ServeDNS(rec, m)
+
+		if rec.Rcode != dns.RcodeSuccess {
+			t.Errorf("Test %d: expected NOERROR, got %s", i, dns.RcodeToString[rec.Rcode])
+		}
+		if count != tc.expectedCount {
+			t.Errorf("Test %d: expected count %d, got %d", i, tc.expectedCount, count)
+		}
+	}
+
+	if err := SwitchMiddleware("proxy", false); err == nil {
+		t.Errorf("Expected error for middleware that is not switchable, got none")
+	}
+	names, on := SwitchableMiddleware()
+	if len(names) != 1 || names[0] != "dnsserver" || !on[0] {
+		t.Errorf("Expected dnsserver to be switchable and on, got %v %v", names, on)
+	}
+}
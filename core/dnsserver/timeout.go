@@ -0,0 +1,160 @@
+package dnsserver
+
+import (
+	"errors"
+	"log"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/coredns/middleware"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+// When a zone has a Timeout, its middleware chain is run in a goroutine. If it has not
+// answered within the timeout, the client gets a SERVFAIL and anything the chain writes
+// afterwards is discarded. To tell which middleware was slow, or panicked, every layer of
+// the chain is wrapped in a traceHandler that records the innermost middleware that is
+// running.
+
+// trace holds the name of the middleware that is handling a query.
+type trace struct {
+	sync.Mutex
+	name string
+}
+
+type traceKey struct{}
+
+// set sets the running middleware to name and returns the previous one.
+func (t *trace) set(name string) string {
+	t.Lock()
+	defer t.Unlock()
+	prev := t.name
+	t.name = name
+	return prev
+}
+
+func (t *trace) get() string {
+	t.Lock()
+	defer t.Unlock()
+	return t.name
+}
+
+// traceHandler records in the trace of the query that the middleware name is running.
+type traceHandler struct {
+	middleware.Handler
+	name string
+}
+
+// ServeDNS implements the middleware.Handler interface.
+func (t traceHandler) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	tr, ok := ctx.Value(traceKey{}).(*trace)
+	if !ok {
+		return t.Handler.ServeDNS(ctx, w, r)
+	}
+	// Not deferred: after a panic the trace should still name the middleware.
+	prev := tr.set(t.name)
+	rcode, err := t.Handler.ServeDNS(ctx, w, r)
+	tr.set(prev)
+	return rcode, err
+}
+
+// handlerName returns the name of the middleware h, this is the name of the package
+// that implements it.
+func handlerName(h middleware.Handler) string {
+	typ := reflect.TypeOf(h)
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	pkg := typ.PkgPath()
+	if i := strings.LastIndex(pkg, "/"); i >= 0 {
+		pkg = pkg[i+1:]
+	}
+	return pkg
+}
+
+// timeoutResponseWriter discards the writes that happen after the query timed out.
+type timeoutResponseWriter struct {
+	dns.ResponseWriter
+
+	sync.Mutex
+	written  bool
+	timedOut bool
+}
+
+var errTimedOut = errors.New("query timed out")
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (w *timeoutResponseWriter) WriteMsg(res *dns.Msg) error {
+	w.Lock()
+	defer w.Unlock()
+	if w.timedOut {
+		return errTimedOut
+	}
+	w.written = true
+	return w.ResponseWriter.WriteMsg(res)
+}
+
+// Write implements the dns.ResponseWriter interface.
+func (w *timeoutResponseWriter) Write(buf []byte) (int, error) {
+	w.Lock()
+	defer w.Unlock()
+	if w.timedOut {
+		return 0, errTimedOut
+	}
+	w.written = true
+	return w.ResponseWriter.Write(buf)
+}
+
+// timeout marks w as timed out and writes a SERVFAIL for r, unless the chain wrote
+// a response in the meantime.
+func (w *timeoutResponseWriter) timeout(r *dns.Msg) {
+	w.Lock()
+	defer w.Unlock()
+	w.timedOut = true
+	if !w.written {
+		DefaultErrorFunc(w.ResponseWriter, r, dns.RcodeServerFailure)
+	}
+}
+
+// serveTimeout runs the middleware chain of zone h for r, the client gets a SERVFAIL
+// if it takes longer than h.Timeout. The trace of the query must be in ctx.
+func (s *Server) serveTimeout(ctx context.Context, h *Config, w dns.ResponseWriter, r *dns.Msg) {
+	tr := ctx.Value(traceKey{}).(*trace)
+	ctx, cancel := context.WithTimeout(ctx, h.Timeout)
+	defer cancel()
+
+	tw := &timeoutResponseWriter{ResponseWriter: w}
+	done := make(chan result, 1)
+	go func() {
+		defer func() {
+			if rec := recover(); rec != nil {
+				s.panicked(rec, h, tr.get(), tw, r)
+				done <- result{rcode: dns.RcodeSuccess} // the SERVFAIL is written
+			}
+		}()
+		rcode, err := h.middlewareChain.ServeDNS(ctx, tw, r)
+		done <- result{rcode, err}
+	}()
+
+	select {
+	case res := <-done:
+		if !middleware.ClientWrite(res.rcode) {
+			errorFunc(tw, r, res.rcode, res.err)
+		}
+	case <-ctx.Done():
+		name := tr.get()
+		timeoutCount.WithLabelValues(s.Addr, h.Zone, name).Inc()
+		log.Printf("[WARNING] Query for %s timed out after %s in middleware %s", r.Question[0].Name, h.Timeout, name)
+		tw.timeout(r)
+	}
+}
+
+// result is what the middleware chain returned for a query.
+type result struct {
+	rcode int
+	err   error
+}
@@ -0,0 +1,78 @@
+package dnsserver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/coredns/middleware"
+	"github.com/miekg/coredns/middleware/pkg/dnsrecorder"
+	"github.com/miekg/coredns/middleware/test"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+// slowHandler answers after delay, even when the context is cancelled.
+type slowHandler struct{ delay time.Duration }
+
+func (s slowHandler) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	time.Sleep(s.delay)
+	return rootHandler(nil).ServeDNS(ctx, w, r)
+}
+
+func TestServeTimeout(t *testing.T) {
+	tests := []struct {
+		delay         time.Duration
+		expectedRcode int
+	}{
+		{0, dns.RcodeSuccess},
+		{200 * time.Millisecond, dns.RcodeServerFailure},
+	}
+
+	for i, tc := range tests {
+		slow := func(next middleware.Handler) middleware.Handler { return slowHandler{tc.delay} }
+		s, err := NewServer("127.0.0.1:53", []*Config{
+			{Zone: ".", Port: "53", Timeout: 50 * time.Millisecond, Middleware: []middleware.Middleware{slow}},
+		})
+		if err != nil {
+			t.Fatalf("Test %d: failed to create server: %s", i, err)
+		}
+
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		rec := dnsrecorder.New(&test.ResponseWriter{})
+		start := time.Now()
+		s.ServeDNS(rec, m)
+
+		if rec.Rcode != tc.expectedRcode {
+			t.Errorf("Test %d: expected rcode %s, got %s", i, dns.RcodeToString[tc.expectedRcode], dns.RcodeToString[rec.Rcode])
+		}
+		if d := time.Since(start); d > 150*time.Millisecond {
+			t.Errorf("Test %d: expected an answer within the timeout, took %s", i, d)
+		}
+	}
+	// Let the slow handlers finish their (discarded) writes.
+	time.Sleep(200 * time.Millisecond)
+}
+
+func TestTraceHandler(t *testing.T) {
+	tr := &trace{}
+	var running string
+	inner := middleware.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		running = tr.get()
+		return dns.RcodeSuccess, nil
+	})
+	if name := handlerName(slowHandler{}); name != "dnsserver" {
+		t.Errorf("Expected name dnsserver, got %s", name)
+	}
+
+	h := traceHandler{Handler: inner, name: handlerName(inner)}
+	ctx := context.WithValue(context.TODO(), traceKey{}, tr)
+	h.ServeDNS(ctx, &test.ResponseWriter{}, new(dns.Msg))
+	if running != "middleware" {
+		t.Errorf("Expected middleware to be running, got %q", running)
+	}
+	if name := tr.get(); name != "" {
+		t.Errorf("Expected nothing to be running after the handler returned, got %q", name)
+	}
+}
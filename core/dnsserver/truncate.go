@@ -0,0 +1,46 @@
+package dnsserver
+
+import (
+	"github.com/miekg/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// truncateResponseWriter makes sure the responses written to it fit the buffer size of
+// the client. This is done here, for all middleware, so oversized answers are handled
+// the same everywhere. A response that does not fit is scrubbed, see request.ScrubSize.
+type truncateResponseWriter struct {
+	dns.ResponseWriter
+	req     *dns.Msg
+	maxSize int  // cap on the size advertised by the client, 0 is no cap
+	minimal bool // drop the authority section before truncating
+}
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (w *truncateResponseWriter) WriteMsg(res *dns.Msg) error {
+	state := request.Request{W: w.ResponseWriter, Req: w.req}
+	size := state.Size()
+	if w.maxSize > 0 && size > w.maxSize {
+		size = w.maxSize
+	}
+	res, _ = state.ScrubSize(res, size, w.minimal)
+	return w.ResponseWriter.WriteMsg(res)
+}
+
+// Write implements the dns.ResponseWriter interface.
+func (w *truncateResponseWriter) Write(buf []byte) (int, error) {
+	m := new(dns.Msg)
+	if err := m.Unpack(buf); err != nil {
+		return 0, err
+	}
+	return len(buf), w.WriteMsg(m)
+}
+
+// truncateWriter returns w wrapped so the responses for r follow the truncation policy
+// of zone h. Only UDP responses are truncated, for other transports w is returned.
+func truncateWriter(h *Config, w dns.ResponseWriter, r *dns.Msg) dns.ResponseWriter {
+	if request.Proto(w) != "udp" {
+		return w
+	}
+	return &truncateResponseWriter{ResponseWriter: w, req: r, maxSize: h.MaxUDPSize, minimal: h.MinimalResponses}
+}
@@ -0,0 +1,67 @@
+package dnsserver
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/miekg/coredns/middleware"
+	"github.com/miekg/coredns/middleware/pkg/dnsrecorder"
+	"github.com/miekg/coredns/middleware/test"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+// largeHandler answers with 40 A records, about 1100 bytes.
+func largeHandler(next middleware.Handler) middleware.Handler {
+	return middleware.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		for i := 0; i < 40; i++ {
+			m.Answer = append(m.Answer, test.A(fmt.Sprintf("example.org. 3600 IN A 10.0.0.%d", i)))
+		}
+		if o := r.IsEdns0(); o != nil {
+			m.SetEdns0(o.UDPSize(), false)
+		}
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+}
+
+func TestServeTruncate(t *testing.T) {
+	tests := []struct {
+		bufsize     uint16 // 0 is no EDNS0
+		maxUDPSize  int
+		expectedTC  bool
+		expectedAns int
+	}{
+		{0, 0, true, 0},
+		{4096, 0, false, 40},
+		{4096, 1232, false, 40},
+		{4096, 1024, true, 0},
+	}
+
+	for i, tc := range tests {
+		s, err := NewServer("127.0.0.1:53", []*Config{
+			{Zone: "example.org.", Port: "53", MaxUDPSize: tc.maxUDPSize, Middleware: []middleware.Middleware{largeHandler}},
+		})
+		if err != nil {
+			t.Fatalf("Test %d: failed to create server: %s", i, err)
+		}
+
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		if tc.bufsize > 0 {
+			m.SetEdns0(tc.bufsize, false)
+		}
+		rec := dnsrecorder.New(&test.ResponseWriter{})
+		s.ServeDNS(rec, m)
+
+		if rec.Msg.Truncated != tc.expectedTC {
+			t.Errorf("Test %d: expected TC to be %t", i, tc.expectedTC)
+		}
+		if len(rec.Msg.Answer) != tc.expectedAns {
+			t.Errorf("Test %d: expected %d answers, got %d", i, tc.expectedAns, len(rec.Msg.Answer))
+		}
+	}
+}
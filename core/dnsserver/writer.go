@@ -0,0 +1,115 @@
+package dnsserver
+
+import (
+	"net"
+	"time"
+
+	"github.com/miekg/coredns/middleware/pkg/rcode"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+// ResponseWriter is the dns.ResponseWriter the server hands to the middleware chain of a
+// zone. It decorates the writer of the client and records the responses written to it,
+// so middleware that want to know what the client got don't have to wrap the writer
+// themselves. Middleware usually wrap the writer they are given, so get it with
+// Writer(ctx) instead of a type assertion.
+type ResponseWriter interface {
+	dns.ResponseWriter
+
+	// Proto returns the protocol of the client: udp, tcp, https or grpc.
+	Proto() string
+	// Rcode returns the rcode of the last response written.
+	Rcode() int
+	// Size returns the number of bytes written, in total.
+	Size() int
+	// Msg returns the last response written, nil if it was written with Write.
+	Msg() *dns.Msg
+	// Written returns true if a response has been written.
+	Written() bool
+	// Start returns the time the query was handed to the middleware.
+	Start() time.Time
+}
+
+// Writer returns the ResponseWriter of the query that ctx belongs to, or nil if ctx does
+// not come from the server.
+func Writer(ctx context.Context) ResponseWriter {
+	w, _ := ctx.Value(writerKey{}).(ResponseWriter)
+	return w
+}
+
+type writerKey struct{}
+
+// recorder is the standard ResponseWriter: it tags the response with the protocol and
+// records the rcode and size of what is written.
+type recorder struct {
+	dns.ResponseWriter
+	proto   string
+	rcode   int
+	size    int
+	msg     *dns.Msg
+	written bool
+	start   time.Time
+}
+
+func newRecorder(w dns.ResponseWriter) *recorder {
+	return &recorder{ResponseWriter: w, proto: proto(w), start: time.Now()}
+}
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (r *recorder) WriteMsg(m *dns.Msg) error {
+	if err := r.ResponseWriter.WriteMsg(m); err != nil {
+		return err
+	}
+	// Zone transfers write multiple messages, keep the last one and add the sizes.
+	r.rcode, r.msg, r.written = m.Rcode, m, true
+	r.size += m.Len()
+	return nil
+}
+
+// Write implements the dns.ResponseWriter interface.
+func (r *recorder) Write(buf []byte) (int, error) {
+	n, err := r.ResponseWriter.Write(buf)
+	if err != nil {
+		return n, err
+	}
+	r.msg, r.written = nil, true
+	r.size += n
+	if len(buf) > 3 {
+		r.rcode = int(buf[3] & 0xF)
+	}
+	return n, nil
+}
+
+func (r *recorder) Proto() string    { return r.proto }
+func (r *recorder) Rcode() int       { return r.rcode }
+func (r *recorder) Size() int        { return r.size }
+func (r *recorder) Msg() *dns.Msg    { return r.msg }
+func (r *recorder) Written() bool    { return r.written }
+func (r *recorder) Start() time.Time { return r.start }
+
+// report updates the response metrics of zone.
+func (r *recorder) report(zone string) {
+	if !r.written {
+		return
+	}
+	responseCount.WithLabelValues(zone, r.proto, rcode.ToString(r.rcode)).Inc()
+	responseSize.WithLabelValues(r.proto).Observe(float64(r.size))
+}
+
+// proto returns the protocol of the client of w.
+func proto(w dns.ResponseWriter) string {
+	switch x := w.(type) {
+	case *DoHWriter:
+		return TransportHTTPS
+	case *gRPCresponse:
+		return TransportGRPC
+	case *idnaResponseWriter:
+		return proto(x.ResponseWriter)
+	}
+	if _, ok := w.RemoteAddr().(*net.TCPAddr); ok {
+		return "tcp"
+	}
+	return "udp"
+}
@@ -0,0 +1,64 @@
+package dnsserver
+
+import (
+	"net"
+	"testing"
+
+	"github.com/miekg/coredns/middleware"
+	"github.com/miekg/coredns/middleware/pkg/dnsrecorder"
+	"github.com/miekg/coredns/middleware/test"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+func TestWriter(t *testing.T) {
+	var rw ResponseWriter
+	// inspect writes a NXDOMAIN and looks at what the server recorded.
+	inspect := func(next middleware.Handler) middleware.Handler {
+		return middleware.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+			rw = Writer(ctx)
+			m := new(dns.Msg)
+			m.SetRcode(r, dns.RcodeNameError)
+			w.WriteMsg(m)
+			return dns.RcodeNameError, nil
+		})
+	}
+	s, err := NewServer("127.0.0.1:53", []*Config{
+		{Zone: "example.org.", Port: "53", Middleware: []middleware.Middleware{inspect}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %s", err)
+	}
+
+	tests := []struct {
+		w             dns.ResponseWriter
+		expectedProto string
+	}{
+		{dnsrecorder.New(&test.ResponseWriter{}), "udp"},
+		{&DoHWriter{laddr: &net.TCPAddr{}, raddr: &net.TCPAddr{IP: net.ParseIP("10.240.0.1"), Port: 40212}}, "https"},
+	}
+	for i, tc := range tests {
+		rw = nil
+		m := new(dns.Msg)
+		m.SetQuestion("www.example.org.", dns.TypeA)
+		s.ServeDNS(tc.w, m)
+
+		if rw == nil {
+			t.Fatalf("Test %d: expected a ResponseWriter in the context", i)
+		}
+		if rw.Proto() != tc.expectedProto {
+			t.Errorf("Test %d: expected proto %s, got %s", i, tc.expectedProto, rw.Proto())
+		}
+		if !rw.Written() || rw.Rcode() != dns.RcodeNameError {
+			t.Errorf("Test %d: expected NXDOMAIN to be written, got %t, %d", i, rw.Written(), rw.Rcode())
+		}
+		if rw.Msg() == nil || rw.Size() != rw.Msg().Len() {
+			t.Errorf("Test %d: expected the size of the message to be recorded, got %d", i, rw.Size())
+		}
+	}
+
+	if Writer(context.TODO()) != nil {
+		t.Errorf("Expected no ResponseWriter outside the server")
+	}
+}
@@ -0,0 +1,123 @@
+package dnsserver
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// AddZone adds the zone c.Zone to s while it is running, its middleware are compiled
+// and the startup (and listen) hooks of c are run. Queries that are being handled keep
+// using the zones they started with. Only the per zone settings of c are used; the
+// settings of the listener, like limits and timeouts, are those of the zones s was
+// created with.
+func (s *Server) AddZone(c *Config) error {
+	zone := c.Zone
+	if !isRegexpZone(zone) {
+		zone = strings.ToLower(dns.Fqdn(zone))
+	}
+	var re *regexp.Regexp
+	if isRegexpZone(zone) {
+		var err error
+		if re, err = regexp.Compile(zone[1:]); err != nil {
+			return err
+		}
+	}
+	c.Zone = zone
+	compile(c)
+
+	s.m.Lock()
+	if _, ok := s.zones[zone]; ok {
+		s.m.Unlock()
+		return fmt.Errorf("zone %s is already served on %s", zone, s.Addr)
+	}
+	// Copy on write, ServeDNS uses the published maps without holding the lock.
+	zones := make(map[string]*Config, len(s.zones)+1)
+	for z, conf := range s.zones {
+		zones[z] = conf
+	}
+	zones[zone] = c
+	s.zones = zones
+	s.wildcards = s.wildcards || isWildcardZone(zone)
+	if re != nil {
+		regexps := make([]regexpZone, len(s.regexps), len(s.regexps)+1)
+		copy(regexps, s.regexps)
+		s.regexps = append(regexps, regexpZone{re: re, config: c})
+	}
+	s.publish()
+	s.m.Unlock()
+
+	c.startup()
+	if tcp, udp := s.LocalAddr(), s.LocalAddrPacket(); tcp != nil || udp != nil {
+		for _, fn := range c.listenHooks {
+			fn(tcp, udp)
+		}
+	}
+	return nil
+}
+
+// RemoveZone removes zone from s while it is running and runs the shutdown hooks of its
+// config. Queries that are being handled by the zone are finished.
+func (s *Server) RemoveZone(zone string) error {
+	if !isRegexpZone(zone) {
+		zone = strings.ToLower(dns.Fqdn(zone))
+	}
+
+	s.m.Lock()
+	c, ok := s.zones[zone]
+	if !ok {
+		s.m.Unlock()
+		return fmt.Errorf("zone %s is not served on %s", zone, s.Addr)
+	}
+	zones := make(map[string]*Config, len(s.zones))
+	wildcards := false
+	for z, conf := range s.zones {
+		if z == zone {
+			continue
+		}
+		zones[z] = conf
+		wildcards = wildcards || isWildcardZone(z)
+	}
+	regexps := []regexpZone{}
+	for _, r := range s.regexps {
+		if r.config != c {
+			regexps = append(regexps, r)
+		}
+	}
+	s.zones, s.wildcards, s.regexps = zones, wildcards, regexps
+	s.publish()
+	s.m.Unlock()
+
+	c.shutdown()
+	return nil
+}
+
+// Zones returns the zones served by s, in no particular order.
+func (s *Server) Zones() []string {
+	zones := s.zoneMap()
+	names := make([]string, 0, len(zones))
+	for z := range zones {
+		names = append(names, z)
+	}
+	return names
+}
+
+// zoneMap returns the current zones of s, the map must not be modified.
+func (s *Server) zoneMap() map[string]*Config {
+	return s.set.Load().(*zoneSet).zones
+}
+
+// zoneSet is a snapshot of the zones of a server, it is never modified once published.
+type zoneSet struct {
+	zones     map[string]*Config
+	wildcards bool
+	regexps   []regexpZone
+}
+
+// publish swaps the zones ServeDNS routes with for the current ones, in one atomic step.
+// The caller must hold s.m, or be the only one using s.
+func (s *Server) publish() {
+	s.set.Store(&zoneSet{zones: s.zones, wildcards: s.wildcards, regexps: s.regexps})
+}
@@ -0,0 +1,89 @@
+package dnsserver
+
+import (
+	"testing"
+
+	"github.com/miekg/coredns/middleware"
+	"github.com/miekg/coredns/middleware/pkg/dnsrecorder"
+	"github.com/miekg/coredns/middleware/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestAddRemoveZone(t *testing.T) {
+	s, err := NewServer("127.0.0.1:53", []*Config{
+		{Zone: "example.org.", Port: "53", Middleware: []middleware.Middleware{rootHandler}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %s", err)
+	}
+
+	query := func(qname string) int {
+		m := new(dns.Msg)
+		m.SetQuestion(qname, dns.TypeA)
+		rec := dnsrecorder.New(&test.ResponseWriter{})
+		s.ServeDNS(rec, m)
+		return rec.Rcode
+	}
+
+	if rcode := query("a.tenant.example.net."); rcode != dns.RcodeRefused {
+		t.Errorf("Expected REFUSED before the zone is added, got %s", dns.RcodeToString[rcode])
+	}
+
+	started := false
+	c := &Config{Zone: "Tenant.example.net", Middleware: []middleware.Middleware{rootHandler}}
+	c.OnStartupComplete(func() error { started = true; return nil })
+	if err := s.AddZone(c); err != nil {
+		t.Fatalf("Expected no error adding zone, got %s", err)
+	}
+	if !started {
+		t.Errorf("Expected the startup hooks of the zone to run")
+	}
+	if rcode := query("a.tenant.example.net."); rcode != dns.RcodeSuccess {
+		t.Errorf("Expected NOERROR after the zone is added, got %s", dns.RcodeToString[rcode])
+	}
+	if err := s.AddZone(&Config{Zone: "tenant.example.net.", Middleware: []middleware.Middleware{rootHandler}}); err == nil {
+		t.Errorf("Expected error adding the zone twice, got none")
+	}
+
+	stopped := false
+	c.OnShutdown(func() error { stopped = true; return nil })
+	if err := s.RemoveZone("tenant.example.net."); err != nil {
+		t.Fatalf("Expected no error removing zone, got %s", err)
+	}
+	if !stopped {
+		t.Errorf("Expected the shutdown hooks of the zone to run")
+	}
+	if rcode := query("a.tenant.example.net."); rcode != dns.RcodeRefused {
+		t.Errorf("Expected REFUSED after the zone is removed, got %s", dns.RcodeToString[rcode])
+	}
+	if rcode := query("example.org."); rcode != dns.RcodeSuccess {
+		t.Errorf("Expected NOERROR for the other zone, got %s", dns.RcodeToString[rcode])
+	}
+	if err := s.RemoveZone("tenant.example.net."); err == nil {
+		t.Errorf("Expected error removing an unknown zone, got none")
+	}
+}
+
+func TestAddRegexpZone(t *testing.T) {
+	s, err := NewServer("127.0.0.1:53", []*Config{
+		{Zone: "example.org.", Port: "53", Middleware: []middleware.Middleware{rootHandler}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %s", err)
+	}
+	if err := s.AddZone(&Config{Zone: "~(", Middleware: []middleware.Middleware{rootHandler}}); err == nil {
+		t.Errorf("Expected error for invalid regexp zone, got none")
+	}
+	if err := s.AddZone(&Config{Zone: `~^tenant[0-9]+\.example\.net\.$`, Middleware: []middleware.Middleware{rootHandler}}); err != nil {
+		t.Fatalf("Expected no error adding zone, got %s", err)
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion("tenant1.example.net.", dns.TypeA)
+	rec := dnsrecorder.New(&test.ResponseWriter{})
+	s.ServeDNS(rec, m)
+	if rec.Rcode != dns.RcodeSuccess {
+		t.Errorf("Expected NOERROR for a name matching the regexp zone, got %s", dns.RcodeToString[rec.Rcode])
+	}
+}
@@ -0,0 +1,196 @@
+package coremain
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// runBench sends the queries in queryfile to server, at qps queries per second, for
+// duration and prints a report to stdout. The queries are sent round robin.
+func runBench(server, queryfile string, qps int, duration time.Duration) error {
+	if queryfile == "" {
+		return fmt.Errorf("no query file given, use -queryfile")
+	}
+	f, err := os.Open(queryfile)
+	if err != nil {
+		return err
+	}
+	qs, err := parseQueries(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	b, err := newBench(server, qs, qps)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Sending %d qps to %s for %s\n", qps, b.server, duration)
+	b.run(duration)
+	b.report(os.Stdout)
+	return nil
+}
+
+// parseQueries parses a query file in the format dnsperf uses: one query per line, a name
+// followed by an optional type (default A). Empty lines and lines starting with # are skipped.
+func parseQueries(r io.Reader) ([]dns.Question, error) {
+	qs := []dns.Question{}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) > 2 {
+			return nil, fmt.Errorf("line %d: expected a name and a type, got %q", line, scanner.Text())
+		}
+		qtype := dns.TypeA
+		if len(fields) == 2 {
+			t, ok := dns.StringToType[strings.ToUpper(fields[1])]
+			if !ok {
+				return nil, fmt.Errorf("line %d: unknown type %q", line, fields[1])
+			}
+			qtype = t
+		}
+		qs = append(qs, dns.Question{Name: dns.Fqdn(fields[0]), Qtype: qtype, Qclass: dns.ClassINET})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(qs) == 0 {
+		return nil, fmt.Errorf("no queries found")
+	}
+	return qs, nil
+}
+
+// bench is a load test against a single server.
+type bench struct {
+	server string
+	qs     []dns.Question
+	qps    int
+	client *dns.Client
+
+	sync.Mutex
+	sent    int
+	elapsed time.Duration
+	rtts    []time.Duration
+	rcodes  map[int]int
+	errors  int
+}
+
+// newBench returns a bench for server, that is given as [@]HOST[:PORT].
+func newBench(server string, qs []dns.Question, qps int) (*bench, error) {
+	if qps <= 0 {
+		return nil, fmt.Errorf("qps must be positive, got %d", qps)
+	}
+	server = strings.TrimPrefix(server, "@")
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+	return &bench{
+		server: server,
+		qs:     qs,
+		qps:    qps,
+		client: &dns.Client{Net: "udp", Timeout: benchTimeout},
+		rcodes: make(map[int]int),
+	}, nil
+}
+
+// run sends queries for duration and waits for the outstanding ones. The queries are
+// scheduled at fixed intervals, a slow server does not lower the rate.
+func (b *bench) run(duration time.Duration) {
+	interval := time.Second / time.Duration(b.qps)
+	start := time.Now()
+	wg := sync.WaitGroup{}
+	for i := 0; ; i++ {
+		next := start.Add(time.Duration(i) * interval)
+		if next.Sub(start) >= duration {
+			break
+		}
+		time.Sleep(next.Sub(time.Now()))
+
+		m := new(dns.Msg)
+		m.Id = dns.Id()
+		m.RecursionDesired = true
+		m.Question = []dns.Question{b.qs[i%len(b.qs)]}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.query(m)
+		}()
+	}
+	wg.Wait()
+	b.Lock()
+	b.elapsed = time.Since(start)
+	b.Unlock()
+}
+
+func (b *bench) query(m *dns.Msg) {
+	r, rtt, err := b.client.Exchange(m, b.server)
+
+	b.Lock()
+	defer b.Unlock()
+	b.sent++
+	if err != nil {
+		b.errors++
+		return
+	}
+	b.rtts = append(b.rtts, rtt)
+	b.rcodes[r.Rcode]++
+}
+
+// report writes the latency percentiles and the rcode distribution to w.
+func (b *bench) report(w io.Writer) {
+	b.Lock()
+	defer b.Unlock()
+
+	fmt.Fprintf(w, "Queries sent: %d, answered: %d, failed: %d (in %s, %.1f qps)\n",
+		b.sent, len(b.rtts), b.errors, b.elapsed-b.elapsed%time.Millisecond, float64(b.sent)/b.elapsed.Seconds())
+	if len(b.rtts) == 0 {
+		return
+	}
+
+	sort.Sort(durations(b.rtts))
+	fmt.Fprintf(w, "Latency: min %s, p50 %s, p90 %s, p99 %s, max %s\n",
+		b.rtts[0], percentile(b.rtts, 50), percentile(b.rtts, 90), percentile(b.rtts, 99), b.rtts[len(b.rtts)-1])
+
+	rcodes := make([]int, 0, len(b.rcodes))
+	for rc := range b.rcodes {
+		rcodes = append(rcodes, rc)
+	}
+	sort.Ints(rcodes)
+	fmt.Fprintf(w, "Rcodes:")
+	for _, rc := range rcodes {
+		fmt.Fprintf(w, " %s %d (%.1f%%)", dns.RcodeToString[rc], b.rcodes[rc], 100*float64(b.rcodes[rc])/float64(len(b.rtts)))
+	}
+	fmt.Fprintln(w)
+}
+
+// percentile returns the p-th percentile of the sorted durations d.
+func percentile(d []time.Duration, p int) time.Duration {
+	i := (len(d)*p+99)/100 - 1
+	if i < 0 {
+		i = 0
+	}
+	return d[i]
+}
+
+type durations []time.Duration
+
+func (d durations) Len() int           { return len(d) }
+func (d durations) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+func (d durations) Less(i, j int) bool { return d[i] < d[j] }
+
+const benchTimeout = 2 * time.Second
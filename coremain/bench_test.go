@@ -0,0 +1,100 @@
+package coremain
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/coredns/middleware/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestParseQueries(t *testing.T) {
+	qs, err := parseQueries(strings.NewReader(`# queries
+example.org
+example.org MX
+
+www.example.org aaaa
+`))
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	expected := []dns.Question{
+		{Name: "example.org.", Qtype: dns.TypeA, Qclass: dns.ClassINET},
+		{Name: "example.org.", Qtype: dns.TypeMX, Qclass: dns.ClassINET},
+		{Name: "www.example.org.", Qtype: dns.TypeAAAA, Qclass: dns.ClassINET},
+	}
+	if len(qs) != len(expected) {
+		t.Fatalf("Expected %d queries, got %d", len(expected), len(qs))
+	}
+	for i := range qs {
+		if qs[i] != expected[i] {
+			t.Errorf("Expected query %d to be %s, got %s", i, expected[i].String(), qs[i].String())
+		}
+	}
+
+	for _, input := range []string{"", "# nothing", "example.org BLAAT", "example.org A A"} {
+		if _, err := parseQueries(strings.NewReader(input)); err == nil {
+			t.Errorf("Expected error for %q, got none", input)
+		}
+	}
+}
+
+func TestBench(t *testing.T) {
+	dns.HandleFunc("example.org.", func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		if r.Question[0].Qtype == dns.TypeMX {
+			m.Rcode = dns.RcodeNameError
+		}
+		w.WriteMsg(m)
+	})
+	defer dns.HandleRemove("example.org.")
+
+	s, addr, err := test.UDPServer(t, "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to start server: %s", err)
+	}
+	defer s.Shutdown()
+
+	qs := []dns.Question{
+		{Name: "example.org.", Qtype: dns.TypeA, Qclass: dns.ClassINET},
+		{Name: "example.org.", Qtype: dns.TypeMX, Qclass: dns.ClassINET},
+	}
+	b, err := newBench("@"+addr, qs, 100)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	b.run(200 * time.Millisecond)
+
+	if b.sent != 20 {
+		t.Errorf("Expected 20 queries to be sent, got %d", b.sent)
+	}
+	if b.rcodes[dns.RcodeSuccess] != 10 || b.rcodes[dns.RcodeNameError] != 10 {
+		t.Errorf("Expected 10 NOERROR and 10 NXDOMAIN replies, got %v", b.rcodes)
+	}
+
+	buf := &bytes.Buffer{}
+	b.report(buf)
+	for _, s := range []string{"Queries sent: 20, answered: 20, failed: 0", "p99", "NOERROR 10 (50.0%)", "NXDOMAIN 10 (50.0%)"} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("Expected report to contain %q, got %q", s, buf.String())
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	d := []time.Duration{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	for _, tc := range []struct {
+		p        int
+		expected time.Duration
+	}{
+		{50, 5}, {90, 9}, {99, 10}, {100, 10},
+	} {
+		if got := percentile(d, tc.p); got != tc.expected {
+			t.Errorf("Expected p%d to be %d, got %d", tc.p, tc.expected, got)
+		}
+	}
+}
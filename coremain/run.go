@@ -1,6 +1,7 @@
 package coremain
 
 import (
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
@@ -10,6 +11,7 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/mholt/caddy"
 
@@ -30,6 +32,12 @@ func init() {
 	flag.StringVar(&logfile, "log", "", "Process log file")
 	flag.StringVar(&caddy.PidFile, "pidfile", "", "Path to write pid file")
 	flag.BoolVar(&version, "version", false, "Show version")
+	flag.BoolVar(&printConfig, "print-config", false, "Print the configuration of the Corefile as JSON, instead of starting")
+
+	flag.StringVar(&benchServer, "bench", "", "Send queries to this `@server` and report the latencies, instead of starting")
+	flag.IntVar(&benchQPS, "qps", 100, "Queries per second to send with -bench")
+	flag.DurationVar(&benchDuration, "duration", 30*time.Second, "How long to send queries with -bench")
+	flag.StringVar(&benchQueryFile, "queryfile", "", "File with the queries to send with -bench, one \"name [type]\" per line")
 
 	caddy.RegisterCaddyfileLoader("flag", caddy.LoaderFunc(confLoader))
 	caddy.SetDefaultCaddyfileLoader("default", caddy.LoaderFunc(defaultLoader))
@@ -62,6 +70,14 @@ func Run() {
 		os.Exit(0)
 	}
 
+	if benchServer != "" {
+		if err := runBench(benchServer, benchQueryFile, benchQPS, benchDuration); err != nil {
+			log.SetOutput(os.Stderr)
+			log.Fatal(err)
+		}
+		os.Exit(0)
+	}
+
 	// Set CPU cap
 	if err := setCPU(cpu); err != nil {
 		mustLogFatal(err)
@@ -73,6 +89,19 @@ func Run() {
 		mustLogFatal(err)
 	}
 
+	if printConfig {
+		servers, err := dnsserver.DescribeCorefile(corefile)
+		if err != nil {
+			mustLogFatal(err)
+		}
+		buf, err := json.MarshalIndent(servers, "", "  ")
+		if err != nil {
+			mustLogFatal(err)
+		}
+		fmt.Println(string(buf))
+		os.Exit(0)
+	}
+
 	// Start your engines
 	instance, err := caddy.Start(corefile)
 	if err != nil {
@@ -214,6 +243,13 @@ var (
 	logfile string
 	version bool
 	plugins bool
+
+	printConfig bool
+
+	benchServer    string
+	benchQPS       int
+	benchDuration  time.Duration
+	benchQueryFile string
 )
 
 // Build information obtained with the help of -ldflags
@@ -0,0 +1,51 @@
+// Package acl implements the acl directive that restricts which clients may query a zone.
+package acl
+
+import (
+	"github.com/miekg/coredns/core/dnsserver"
+	"github.com/miekg/coredns/middleware"
+
+	"github.com/mholt/caddy"
+)
+
+func init() {
+	caddy.RegisterPlugin("acl", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setupACL,
+	})
+}
+
+func setupACL(c *caddy.Controller) error {
+	config := dnsserver.GetConfig(c)
+	acl := &dnsserver.ACL{}
+
+	for c.Next() {
+		if len(c.RemainingArgs()) != 0 {
+			return middleware.Error("acl", c.ArgErr())
+		}
+		for c.NextBlock() {
+			add := acl.Allow
+			switch c.Val() {
+			case "allow":
+			case "block":
+				add = acl.Block
+			default:
+				return middleware.Error("acl", c.Errf("unknown property '%s'", c.Val()))
+			}
+			args := c.RemainingArgs()
+			if len(args) == 0 {
+				return middleware.Error("acl", c.ArgErr())
+			}
+			for _, a := range args {
+				if err := add(a); err != nil {
+					return middleware.Error("acl", err)
+				}
+			}
+		}
+	}
+	if acl.Len() == 0 {
+		return middleware.Error("acl", c.Err("no allow or block rules"))
+	}
+	config.ACL = acl
+	return nil
+}
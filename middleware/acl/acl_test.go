@@ -0,0 +1,69 @@
+package acl
+
+import (
+	"net"
+	"testing"
+
+	"github.com/miekg/coredns/core/dnsserver"
+
+	"github.com/mholt/caddy"
+)
+
+func TestSetupACL(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		allowed   []string
+		blocked   []string
+	}{
+		{`acl {
+			allow 10.0.0.0/8 192.168.1.1
+		}`, false, []string{"10.1.2.3", "192.168.1.1"}, []string{"192.168.1.2"}},
+		{`acl {
+			block 10.1.0.0/16
+			allow 10.0.0.0/8
+		}`, false, []string{"10.2.0.1"}, []string{"10.1.0.1", "172.16.0.1"}},
+		{`acl {
+			block 2001:db8::/32
+		}`, false, []string{"10.0.0.1", "2001:db9::1"}, []string{"2001:db8::1"}},
+		// fails
+		{`acl`, true, nil, nil},
+		{`acl 10.0.0.0/8`, true, nil, nil},
+		{`acl {
+			allow
+		}`, true, nil, nil},
+		{`acl {
+			allow example.org
+		}`, true, nil, nil},
+		{`acl {
+			permit 10.0.0.0/8
+		}`, true, nil, nil},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		err := setupACL(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %d: Expected error but found nil", i)
+			continue
+		}
+		if !test.shouldErr && err != nil {
+			t.Errorf("Test %d: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr {
+			continue
+		}
+		acl := dnsserver.GetConfig(c).ACL
+		for _, ip := range test.allowed {
+			if !acl.Allowed(net.ParseIP(ip)) {
+				t.Errorf("Test %d: Expected %s to be allowed", i, ip)
+			}
+		}
+		for _, ip := range test.blocked {
+			if acl.Allowed(net.ParseIP(ip)) {
+				t.Errorf("Test %d: Expected %s to be blocked", i, ip)
+			}
+		}
+	}
+}
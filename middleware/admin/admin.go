@@ -0,0 +1,130 @@
+// Package admin implements an HTTP API to manage a running CoreDNS.
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/miekg/coredns/core/dnsserver"
+)
+
+type admin struct {
+	Addr string
+
+	ln net.Listener
+}
+
+// owners holds, per address, the admin that owns the listener on it. On a reload the
+// admin of the new instance starts before the old one is shut down, it takes over the
+// listener, so the old one doesn't close it.
+var (
+	ownersMu sync.Mutex
+	owners   = make(map[string]*admin)
+)
+
+func (a *admin) Startup() error {
+	ownersMu.Lock()
+	defer ownersMu.Unlock()
+
+	if old, ok := owners[a.Addr]; ok && old.ln != nil {
+		a.ln, old.ln = old.ln, nil
+		owners[a.Addr] = a
+		return nil
+	}
+
+	ln, err := net.Listen("tcp", a.Addr)
+	if err != nil {
+		log.Printf("[ERROR] Failed to start admin handler: %s", err)
+		return nil
+	}
+	a.ln = ln
+	owners[a.Addr] = a
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, serveMiddleware)
+	mux.HandleFunc(path+"/", serveMiddleware)
+	mux.HandleFunc(configPath, serveConfig)
+
+	go http.Serve(ln, mux)
+	return nil
+}
+
+func (a *admin) Shutdown() error {
+	ownersMu.Lock()
+	defer ownersMu.Unlock()
+
+	if a.ln == nil {
+		// Never started, or handed over to a new instance.
+		return nil
+	}
+	delete(owners, a.Addr)
+	ln := a.ln
+	a.ln = nil
+	return ln.Close()
+}
+
+// serveMiddleware lists the switchable middleware on GET /middleware, and switches
+// a middleware on or off on POST /middleware/NAME/on and POST /middleware/NAME/off.
+func serveMiddleware(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == path {
+		if r.Method != http.MethodGet {
+			http.Error(w, "", http.StatusMethodNotAllowed)
+			return
+		}
+		names, on := dnsserver.SwitchableMiddleware()
+		for i := range names {
+			fmt.Fprintf(w, "%s %s\n", names[i], state(on[i]))
+		}
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, path+"/"), "/")
+	if len(parts) != 2 || (parts[1] != "on" && parts[1] != "off") {
+		http.Error(w, "", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "", http.StatusMethodNotAllowed)
+		return
+	}
+	name, on := parts[0], parts[1] == "on"
+	if err := dnsserver.SwitchMiddleware(name, on); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	log.Printf("[INFO] Middleware %s switched %s", name, state(on))
+	fmt.Fprintf(w, "%s %s\n", name, state(on))
+}
+
+// serveConfig returns the configuration of the running servers as JSON on GET /config.
+func serveConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "", http.StatusMethodNotAllowed)
+		return
+	}
+	buf, err := json.MarshalIndent(dnsserver.Running(), "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(buf)
+}
+
+func state(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
+
+const (
+	defAddr    = "localhost:8054"
+	path       = "/middleware"
+	configPath = "/config"
+)
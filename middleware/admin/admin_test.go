@@ -0,0 +1,98 @@
+package admin
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/miekg/coredns/core/dnsserver"
+)
+
+func TestServeMiddleware(t *testing.T) {
+	dnsserver.AddSwitchable([]string{"log"})
+
+	tests := []struct {
+		method         string
+		path           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{"GET", "/middleware", http.StatusOK, "log on\n"},
+		{"POST", "/middleware/log/off", http.StatusOK, "log off\n"},
+		{"GET", "/middleware", http.StatusOK, "log off\n"},
+		{"POST", "/middleware/log/on", http.StatusOK, "log on\n"},
+		{"GET", "/middleware/log/on", http.StatusMethodNotAllowed, ""},
+		{"POST", "/middleware", http.StatusMethodNotAllowed, ""},
+		{"POST", "/middleware/proxy/off", http.StatusNotFound, ""},
+		{"POST", "/middleware/log/blaat", http.StatusNotFound, ""},
+		{"POST", "/middleware/log", http.StatusNotFound, ""},
+	}
+
+	for i, tc := range tests {
+		w := httptest.NewRecorder()
+		serveMiddleware(w, httptest.NewRequest(tc.method, tc.path, nil))
+
+		if w.Code != tc.expectedStatus {
+			t.Errorf("Test %d: expected status %d, got %d", i, tc.expectedStatus, w.Code)
+			continue
+		}
+		if tc.expectedBody == "" {
+			continue
+		}
+		body, _ := ioutil.ReadAll(w.Body)
+		if string(body) != tc.expectedBody {
+			t.Errorf("Test %d: expected body %q, got %q", i, tc.expectedBody, body)
+		}
+	}
+}
+
+func TestServeConfig(t *testing.T) {
+	w := httptest.NewRecorder()
+	serveConfig(w, httptest.NewRequest("GET", "/config", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	var servers []dnsserver.ServerInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &servers); err != nil {
+		t.Errorf("Expected JSON, got %q: %s", w.Body.String(), err)
+	}
+
+	w = httptest.NewRecorder()
+	serveConfig(w, httptest.NewRequest("POST", "/config", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
+
+func TestStartupReload(t *testing.T) {
+	a1 := &admin{Addr: "127.0.0.1:0"}
+	a1.Startup()
+	if a1.ln == nil {
+		t.Fatal("Expected the admin handler to listen")
+	}
+	addr := a1.ln.Addr().String()
+
+	// A reload: the new instance starts before the old one is shut down.
+	a2 := &admin{Addr: a1.Addr}
+	a2.Startup()
+	if err := a1.Shutdown(); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	resp, err := http.Get("http://" + addr + "/middleware")
+	if err != nil {
+		t.Fatalf("Expected the admin handler to be up after the reload: %s", err)
+	}
+	resp.Body.Close()
+
+	if err := a2.Shutdown(); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if c, err := net.Dial("tcp", addr); err == nil {
+		c.Close()
+		t.Error("Expected the admin handler to be down after the shutdown")
+	}
+}
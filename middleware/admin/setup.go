@@ -0,0 +1,63 @@
+package admin
+
+import (
+	"github.com/miekg/coredns/core/dnsserver"
+	"github.com/miekg/coredns/middleware"
+
+	"github.com/mholt/caddy"
+)
+
+func init() {
+	caddy.RegisterPlugin("admin", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	a, names, err := adminParse(c)
+	if err != nil {
+		return middleware.Error("admin", err)
+	}
+
+	// This must be done now, before the middleware chains are compiled.
+	dnsserver.AddSwitchable(names)
+
+	c.OnStartup(a.Startup)
+	c.OnShutdown(a.Shutdown)
+
+	// Like health, admin is not a middleware, just a separate webserver.
+
+	return nil
+}
+
+func adminParse(c *caddy.Controller) (*admin, []string, error) {
+	a := &admin{Addr: defAddr}
+	var names []string
+
+	for c.Next() {
+		args := c.RemainingArgs()
+
+		switch len(args) {
+		case 0:
+		case 1:
+			a.Addr = args[0]
+		default:
+			return nil, nil, c.ArgErr()
+		}
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "switch":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return nil, nil, c.ArgErr()
+				}
+				names = append(names, args...)
+			default:
+				return nil, nil, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+	}
+	return a, names, nil
+}
@@ -0,0 +1,59 @@
+package admin
+
+import (
+	"testing"
+
+	"github.com/mholt/caddy"
+)
+
+func TestAdminParse(t *testing.T) {
+	tests := []struct {
+		input         string
+		shouldErr     bool
+		expectedAddr  string
+		expectedNames []string
+	}{
+		{`admin`, false, defAddr, nil},
+		{`admin localhost:9000`, false, "localhost:9000", nil},
+		{`admin {
+			switch log delay
+			switch audit
+		}`, false, defAddr, []string{"log", "delay", "audit"}},
+		// fails
+		{`admin a b`, true, "", nil},
+		{`admin {
+			switch
+		}`, true, "", nil},
+		{`admin {
+			blaat
+		}`, true, "", nil},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		a, names, err := adminParse(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %d: Expected error but found nil", i)
+			continue
+		}
+		if !test.shouldErr && err != nil {
+			t.Errorf("Test %d: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr {
+			continue
+		}
+		if a.Addr != test.expectedAddr {
+			t.Errorf("Test %d: Expected address %s, got %s", i, test.expectedAddr, a.Addr)
+		}
+		if len(names) != len(test.expectedNames) {
+			t.Errorf("Test %d: Expected names %v, got %v", i, test.expectedNames, names)
+			continue
+		}
+		for j := range names {
+			if names[j] != test.expectedNames[j] {
+				t.Errorf("Test %d: Expected names %v, got %v", i, test.expectedNames, names)
+			}
+		}
+	}
+}
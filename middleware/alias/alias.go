@@ -0,0 +1,157 @@
+// Package alias implements a middleware that answers the address queries for a name, like
+// the apex of a zone, with the addresses of another name, which may be served by another
+// backend.
+package alias
+
+import (
+	"sync"
+	"time"
+
+	"github.com/miekg/coredns/middleware"
+	"github.com/miekg/coredns/request"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+// Alias answers the A and AAAA queries for the names in Targets with the A and AAAA
+// records of their target, looked up in the rest of the chain. The records are kept until
+// their TTL expires or a backend reports a change. Other queries are handed to Next.
+type Alias struct {
+	Next    middleware.Handler
+	Targets map[string]string // name -> target
+
+	cache *cache
+}
+
+// New returns an Alias for targets.
+func New(targets map[string]string) Alias {
+	return Alias{Targets: targets, cache: &cache{entries: make(map[string]entry)}}
+}
+
+// ServeDNS implements the middleware.Handler interface.
+func (a Alias) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: r}
+	qname, qtype := state.Name(), state.QType()
+
+	target, ok := a.Targets[qname]
+	if !ok || (qtype != dns.TypeA && qtype != dns.TypeAAAA) {
+		return a.Next.ServeDNS(ctx, w, r)
+	}
+
+	rrs, err := a.lookup(ctx, w, qname, target, qtype)
+	if err != nil {
+		return dns.RcodeServerFailure, middleware.Error("alias", err)
+	}
+	// Without addresses the next middleware gives the NODATA reply, with the SOA of its zone.
+	if len(rrs) == 0 {
+		return a.Next.ServeDNS(ctx, w, r)
+	}
+
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Authoritative, m.RecursionAvailable, m.Compress = true, true, true
+	m.Answer = rrs
+
+	state.SizeAndDo(m)
+	m, _ = state.Scrub(m)
+	w.WriteMsg(m)
+	return dns.RcodeSuccess, nil
+}
+
+// lookup returns the records of type qtype of target, with the owner name set to name.
+func (a Alias) lookup(ctx context.Context, w dns.ResponseWriter, name, target string, qtype uint16) ([]dns.RR, error) {
+	key := name + "/" + dns.TypeToString[qtype]
+	if rrs, ok := a.cache.get(key); ok {
+		return rrs, nil
+	}
+
+	req := new(dns.Msg)
+	req.SetQuestion(target, qtype)
+	rec := &recorder{ResponseWriter: w}
+	if _, err := a.Next.ServeDNS(ctx, rec, req); err != nil {
+		return nil, err
+	}
+
+	rrs := []dns.RR{}
+	ttl := uint32(0)
+	if rec.msg != nil {
+		for _, rr := range rec.msg.Answer {
+			if rr.Header().Rrtype != qtype {
+				continue // the CNAMEs leading to the addresses
+			}
+			rr = dns.Copy(rr)
+			rr.Header().Name = name
+			if len(rrs) == 0 || rr.Header().Ttl < ttl {
+				ttl = rr.Header().Ttl
+			}
+			rrs = append(rrs, rr)
+		}
+	}
+	if ttl == 0 {
+		ttl = negativeTTL
+	}
+	a.cache.set(key, rrs, time.Duration(ttl)*time.Second)
+	return rrs, nil
+}
+
+// Flush forgets all looked up records, the next queries look them up again.
+func (a Alias) Flush() { a.cache.flush() }
+
+// cache holds the records looked up for the names.
+type cache struct {
+	sync.Mutex
+	entries map[string]entry
+}
+
+type entry struct {
+	rrs     []dns.RR
+	expires time.Time
+}
+
+func (c *cache) get(key string) ([]dns.RR, bool) {
+	c.Lock()
+	defer c.Unlock()
+	e, ok := c.entries[key]
+	if !ok || time.Now().After(e.expires) {
+		return nil, false
+	}
+	return e.rrs, true
+}
+
+func (c *cache) set(key string, rrs []dns.RR, ttl time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+	c.entries[key] = entry{rrs: rrs, expires: time.Now().Add(ttl)}
+}
+
+func (c *cache) flush() {
+	c.Lock()
+	defer c.Unlock()
+	c.entries = make(map[string]entry)
+}
+
+// recorder is a dns.ResponseWriter that keeps the reply to the lookup of a target,
+// instead of writing it to the client.
+type recorder struct {
+	dns.ResponseWriter
+	msg *dns.Msg
+}
+
+func (r *recorder) WriteMsg(m *dns.Msg) error {
+	r.msg = m
+	return nil
+}
+
+func (r *recorder) Write(buf []byte) (int, error) {
+	m := new(dns.Msg)
+	if err := m.Unpack(buf); err != nil {
+		return 0, err
+	}
+	r.msg = m
+	return len(buf), nil
+}
+
+// negativeTTL is how long it is remembered that a target has no addresses, or how long
+// addresses with a TTL of 0 are used.
+const negativeTTL = 5
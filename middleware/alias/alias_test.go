@@ -0,0 +1,63 @@
+package alias
+
+import (
+	"testing"
+
+	"github.com/miekg/coredns/middleware/pkg/dnsrecorder"
+	"github.com/miekg/coredns/middleware/test"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+func TestAlias(t *testing.T) {
+	ingress := "10.0.0.80"
+	lookups := 0
+	// The backend of the target, the apex itself has no addresses.
+	backend := test.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		if r.Question[0].Name == "ingress.default.svc.cluster.local." && r.Question[0].Qtype == dns.TypeA {
+			lookups++
+			m.Answer = append(m.Answer, test.A("ingress.default.svc.cluster.local. 30 IN A "+ingress))
+		}
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+
+	a := New(map[string]string{"example.com.": "ingress.default.svc.cluster.local."})
+	a.Next = backend
+
+	query := func(qtype uint16) *dns.Msg {
+		m := new(dns.Msg)
+		m.SetQuestion("example.com.", qtype)
+		rec := dnsrecorder.New(&test.ResponseWriter{})
+		if _, err := a.ServeDNS(context.TODO(), rec, m); err != nil {
+			t.Fatalf("Expected no error, got %s", err)
+		}
+		return rec.Msg
+	}
+
+	m := query(dns.TypeA)
+	if len(m.Answer) != 1 || m.Answer[0].Header().Name != "example.com." || m.Answer[0].(*dns.A).A.String() != ingress {
+		t.Fatalf("Expected the address of the ingress for example.com., got %v", m.Answer)
+	}
+
+	// The address is kept, until the backend changes.
+	ingress = "10.0.0.81"
+	query(dns.TypeA)
+	if lookups != 1 {
+		t.Errorf("Expected 1 lookup, got %d", lookups)
+	}
+	a.Flush()
+	m = query(dns.TypeA)
+	if lookups != 2 || len(m.Answer) != 1 || m.Answer[0].(*dns.A).A.String() != ingress {
+		t.Errorf("Expected the new address of the ingress after a flush, got %v", m.Answer)
+	}
+
+	// Without AAAA records for the target, the next middleware answers.
+	m = query(dns.TypeAAAA)
+	if len(m.Answer) != 0 {
+		t.Errorf("Expected no AAAA records, got %v", m.Answer)
+	}
+}
@@ -0,0 +1,58 @@
+package alias
+
+import (
+	"fmt"
+
+	"github.com/miekg/coredns/core/dnsserver"
+	"github.com/miekg/coredns/middleware"
+
+	"github.com/mholt/caddy"
+)
+
+func init() {
+	caddy.RegisterPlugin("alias", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	targets, err := aliasParse(c)
+	if err != nil {
+		return middleware.Error("alias", err)
+	}
+
+	a := New(targets)
+	config := dnsserver.GetConfig(c)
+	// Look the targets up again when a backend, like kubernetes, changes.
+	config.OnChange(a.Flush)
+
+	config.AddMiddleware(func(next middleware.Handler) middleware.Handler {
+		a.Next = next
+		return a
+	})
+
+	return nil
+}
+
+// aliasParse parses 'alias NAME TARGET' lines and returns the target per name.
+func aliasParse(c *caddy.Controller) (map[string]string, error) {
+	targets := make(map[string]string)
+
+	for c.Next() {
+		args := c.RemainingArgs()
+		if len(args) != 2 {
+			return nil, c.ArgErr()
+		}
+		name := middleware.Host(args[0]).Normalize()
+		target := middleware.Host(args[1]).Normalize()
+		if _, ok := targets[name]; ok {
+			return nil, fmt.Errorf("name %s has already been given", name)
+		}
+		if name == target {
+			return nil, fmt.Errorf("name %s can not be an alias of itself", name)
+		}
+		targets[name] = target
+	}
+	return targets, nil
+}
@@ -0,0 +1,55 @@
+package alias
+
+import (
+	"testing"
+
+	"github.com/mholt/caddy"
+)
+
+func TestSetupAlias(t *testing.T) {
+	tests := []struct {
+		input           string
+		shouldErr       bool
+		expectedTargets map[string]string
+	}{
+		{`alias example.com ingress.default.svc.cluster.local`, false, map[string]string{
+			"example.com.": "ingress.default.svc.cluster.local.",
+		}},
+		{`alias example.com ingress.default.svc.cluster.local
+		alias EXAMPLE.net. web.example.com.`, false, map[string]string{
+			"example.com.": "ingress.default.svc.cluster.local.",
+			"example.net.": "web.example.com.",
+		}},
+		// fails
+		{`alias`, true, nil},
+		{`alias example.com`, true, nil},
+		{`alias example.com web.example.com web2.example.com`, true, nil},
+		{`alias example.com example.com`, true, nil},
+		{`alias example.com web.example.com
+		alias example.com web2.example.com`, true, nil},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		targets, err := aliasParse(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %d: Expected error but found nil", i)
+			continue
+		}
+		if !test.shouldErr && err != nil {
+			t.Errorf("Test %d: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr {
+			continue
+		}
+		if len(targets) != len(test.expectedTargets) {
+			t.Errorf("Test %d: Expected %d names, got %d", i, len(test.expectedTargets), len(targets))
+		}
+		for name, target := range test.expectedTargets {
+			if targets[name] != target {
+				t.Errorf("Test %d: Expected target %q for %s, got %q", i, target, name, targets[name])
+			}
+		}
+	}
+}
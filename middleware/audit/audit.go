@@ -0,0 +1,142 @@
+// Package audit implements a middleware that adds an audit trail of how a query was
+// handled to the response, as TXT records in the additional section.
+package audit
+
+import (
+	"log"
+	"strings"
+
+	"github.com/miekg/coredns/middleware"
+	"github.com/miekg/coredns/middleware/pkg/audit"
+	"github.com/miekg/coredns/middleware/pkg/dnsutil"
+	"github.com/miekg/coredns/request"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+// Audit is the audit middleware. A query is audited when its name is below Suffix
+// (the suffix is removed before the query is handled) or when it carries an EDNS0
+// local option with code Option.
+type Audit struct {
+	Next middleware.Handler
+
+	Suffix string // "" disables auditing by name
+	Option uint16 // 0 disables auditing by EDNS0 option
+}
+
+// ServeDNS implements the middleware.Handler interface.
+func (a Audit) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	qname := r.Question[0].Name
+
+	name, byName := a.strip(qname)
+	byOption := a.option(r)
+	if !byName && !byOption {
+		return a.Next.ServeDNS(ctx, w, r)
+	}
+	r.Question[0].Name = name
+
+	state := request.Request{W: w, Req: r}
+	t := audit.New()
+	ctx = audit.NewContext(ctx, t)
+	t.Add("audit", "query %s %s from %s over %s", name, state.Type(), state.IP(), state.Proto())
+
+	aw := &ResponseWriter{ResponseWriter: w, trail: t, qname: qname}
+	rcode, err := a.Next.ServeDNS(ctx, aw, r)
+	if err != nil {
+		t.Add("audit", "error: %s", err)
+	}
+	if !aw.written && !middleware.ClientWrite(rcode) {
+		// Write the error ourselves, otherwise the trail is lost.
+		m := new(dns.Msg)
+		m.SetRcode(r, rcode)
+		state.SizeAndDo(m)
+		aw.WriteMsg(m)
+		return 0, err
+	}
+	return rcode, err
+}
+
+// strip returns qname without the audit suffix and true, or qname and false if
+// qname is not below the suffix.
+func (a Audit) strip(qname string) (string, bool) {
+	if a.Suffix == "" || len(qname) <= len(a.Suffix) {
+		return qname, false
+	}
+	if !strings.HasSuffix(strings.ToLower(qname), "."+a.Suffix) {
+		return qname, false
+	}
+	return qname[:len(qname)-len(a.Suffix)], true
+}
+
+// option reports whether r carries the audit option, if so the option is removed
+// from r so it is not forwarded.
+func (a Audit) option(r *dns.Msg) bool {
+	if a.Option == 0 {
+		return false
+	}
+	o := r.IsEdns0()
+	if o == nil {
+		return false
+	}
+	for i, e := range o.Option {
+		if l, ok := e.(*dns.EDNS0_LOCAL); ok && l.Code == a.Option {
+			o.Option = append(o.Option[:i], o.Option[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// ResponseWriter adds the audit trail to the response before writing it.
+type ResponseWriter struct {
+	dns.ResponseWriter
+	trail   *audit.Trail
+	qname   string
+	written bool
+}
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (a *ResponseWriter) WriteMsg(res *dns.Msg) error {
+	a.written = true
+
+	// res may be shared, i.e. with the cache, don't change it.
+	res = res.Copy()
+	if len(res.Question) > 0 {
+		res.Question[0].Name = a.qname
+	}
+	a.trail.Add("audit", "rcode %s, %d answers", dns.RcodeToString[res.Rcode], len(res.Answer))
+
+	txt := trailToTXT(a.qname, a.trail)
+	// Keep the OPT record last.
+	if l := len(res.Extra); l > 0 && res.Extra[l-1].Header().Rrtype == dns.TypeOPT {
+		opt := res.Extra[l-1]
+		res.Extra = append(append(res.Extra[:l-1], txt...), opt)
+	} else {
+		res.Extra = append(res.Extra, txt...)
+	}
+	return a.ResponseWriter.WriteMsg(res)
+}
+
+// Write implements the dns.ResponseWriter interface.
+func (a *ResponseWriter) Write(buf []byte) (int, error) {
+	log.Printf("[WARNING] Audit called with Write: not adding audit trail")
+	a.written = true
+	return a.ResponseWriter.Write(buf)
+}
+
+// Hijack implements the dns.ResponseWriter interface.
+func (a *ResponseWriter) Hijack() { a.ResponseWriter.Hijack() }
+
+// trailToTXT returns the entries of t as TXT records, in the CH class, owned by name.
+func trailToTXT(name string, t *audit.Trail) []dns.RR {
+	entries := t.Entries()
+	rrs := make([]dns.RR, len(entries))
+	for i, e := range entries {
+		rrs[i] = &dns.TXT{
+			Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeTXT, Class: dns.ClassCHAOS, Ttl: 0},
+			Txt: dnsutil.SplitTXT(e.String()),
+		}
+	}
+	return rrs
+}
@@ -0,0 +1,89 @@
+package audit
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/miekg/coredns/middleware"
+	"github.com/miekg/coredns/middleware/pkg/audit"
+	"github.com/miekg/coredns/middleware/pkg/dnsrecorder"
+	"github.com/miekg/coredns/middleware/test"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+func TestAudit(t *testing.T) {
+	next := middleware.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		if r.Question[0].Name != "example.org." {
+			t.Errorf("Expected next middleware to see example.org., got %s", r.Question[0].Name)
+		}
+		if o := r.IsEdns0(); o != nil && len(o.Option) != 0 {
+			t.Errorf("Expected audit option to be removed, got %v", o.Option)
+		}
+		audit.Add(ctx, "test", "answered")
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = []dns.RR{test.A("example.org.	300	IN	A	127.0.0.1")}
+		if r.IsEdns0() != nil {
+			m.SetEdns0(4096, false)
+		}
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+	a := Audit{Next: next, Suffix: defaultSuffix, Option: defaultOption}
+
+	withOption := new(dns.Msg)
+	withOption.SetQuestion("example.org.", dns.TypeA)
+	withOption.SetEdns0(4096, false)
+	o := withOption.IsEdns0()
+	o.Option = append(o.Option, &dns.EDNS0_LOCAL{Code: defaultOption, Data: []byte{}})
+
+	tests := []struct {
+		qname   string
+		msg     *dns.Msg
+		audited bool
+	}{
+		{"example.org.", nil, false},
+		{"example.org._audit.", nil, true},
+		{"example.org._AUDIT.", nil, true},
+		{"example.org.", withOption, true},
+	}
+
+	for i, tc := range tests {
+		m := tc.msg
+		if m == nil {
+			m = new(dns.Msg)
+			m.SetQuestion(tc.qname, dns.TypeA)
+		}
+		rec := dnsrecorder.New(&test.ResponseWriter{})
+		a.ServeDNS(context.TODO(), rec, m)
+
+		if rec.Msg.Question[0].Name != tc.qname {
+			t.Errorf("Test %d: Expected question %s in the reply, got %s", i, tc.qname, rec.Msg.Question[0].Name)
+		}
+
+		var txts []string
+		for _, rr := range rec.Msg.Extra {
+			if txt, ok := rr.(*dns.TXT); ok {
+				txts = append(txts, strings.Join(txt.Txt, ""))
+			}
+		}
+		if !tc.audited {
+			if len(txts) != 0 {
+				t.Errorf("Test %d: Expected no audit trail, got %v", i, txts)
+			}
+			continue
+		}
+		if len(txts) != 3 {
+			t.Errorf("Test %d: Expected 3 audit records, got %d: %v", i, len(txts), txts)
+			continue
+		}
+		if !strings.HasPrefix(txts[1], "test: answered") {
+			t.Errorf("Test %d: Expected second record to be from the test middleware, got %s", i, txts[1])
+		}
+		if l := len(rec.Msg.Extra); tc.msg != nil && rec.Msg.Extra[l-1].Header().Rrtype != dns.TypeOPT {
+			t.Errorf("Test %d: Expected OPT record to be last", i)
+		}
+	}
+}
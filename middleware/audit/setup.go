@@ -0,0 +1,84 @@
+package audit
+
+import (
+	"strconv"
+
+	"github.com/miekg/coredns/core/dnsserver"
+	"github.com/miekg/coredns/middleware"
+
+	"github.com/mholt/caddy"
+	"github.com/miekg/dns"
+)
+
+func init() {
+	caddy.RegisterPlugin("audit", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	a, err := auditParse(c)
+	if err != nil {
+		return middleware.Error("audit", err)
+	}
+
+	dnsserver.GetConfig(c).AddMiddleware(func(next middleware.Handler) middleware.Handler {
+		a.Next = next
+		return a
+	})
+
+	return nil
+}
+
+func auditParse(c *caddy.Controller) (Audit, error) {
+	a := Audit{Suffix: defaultSuffix, Option: defaultOption}
+
+	for c.Next() {
+		if len(c.RemainingArgs()) != 0 {
+			return a, c.ArgErr()
+		}
+		for c.NextBlock() {
+			switch c.Val() {
+			case "suffix":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return a, c.ArgErr()
+				}
+				if args[0] == "off" {
+					a.Suffix = ""
+					continue
+				}
+				if _, ok := dns.IsDomainName(args[0]); !ok {
+					return a, c.Errf("not a valid domain name: %s", args[0])
+				}
+				a.Suffix = middleware.Name(args[0]).Normalize()
+			case "option":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return a, c.ArgErr()
+				}
+				if args[0] == "off" {
+					a.Option = 0
+					continue
+				}
+				n, err := strconv.ParseUint(args[0], 10, 16)
+				if err != nil {
+					return a, err
+				}
+				if n < dns.EDNS0LOCALSTART || n > dns.EDNS0LOCALEND {
+					return a, c.Errf("option code must be in the local range %d-%d: %d", dns.EDNS0LOCALSTART, dns.EDNS0LOCALEND, n)
+				}
+				a.Option = uint16(n)
+			default:
+				return a, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+	}
+	return a, nil
+}
+
+const (
+	defaultSuffix = "_audit."
+	defaultOption = 65400
+)
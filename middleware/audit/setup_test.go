@@ -0,0 +1,64 @@
+package audit
+
+import (
+	"testing"
+
+	"github.com/mholt/caddy"
+)
+
+func TestAuditParse(t *testing.T) {
+	tests := []struct {
+		input          string
+		shouldErr      bool
+		expectedSuffix string
+		expectedOption uint16
+	}{
+		{`audit`, false, defaultSuffix, defaultOption},
+		{`audit {
+			suffix debug.example.org
+		}`, false, "debug.example.org.", defaultOption},
+		{`audit {
+			suffix off
+			option 65002
+		}`, false, "", 65002},
+		{`audit {
+			option off
+		}`, false, defaultSuffix, 0},
+		// fails
+		{`audit example.org`, true, "", 0},
+		{`audit {
+			option 53
+		}`, true, "", 0},
+		{`audit {
+			option blaat
+		}`, true, "", 0},
+		{`audit {
+			suffix
+		}`, true, "", 0},
+		{`audit {
+			blaat
+		}`, true, "", 0},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		a, err := auditParse(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %d: Expected error but found nil", i)
+			continue
+		}
+		if !test.shouldErr && err != nil {
+			t.Errorf("Test %d: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr {
+			continue
+		}
+		if a.Suffix != test.expectedSuffix {
+			t.Errorf("Test %d: Expected suffix %q, got %q", i, test.expectedSuffix, a.Suffix)
+		}
+		if a.Option != test.expectedOption {
+			t.Errorf("Test %d: Expected option %d, got %d", i, test.expectedOption, a.Option)
+		}
+	}
+}
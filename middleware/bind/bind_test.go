@@ -1,6 +1,7 @@
 package bind
 
 import (
+	"net"
 	"testing"
 
 	"github.com/miekg/coredns/core/dnsserver"
@@ -9,22 +10,67 @@ import (
 )
 
 func TestSetupBind(t *testing.T) {
-	c := caddy.NewTestController("dns", `bind 1.2.3.4`)
-	err := setupBind(c)
-	if err != nil {
-		t.Fatalf("Expected no errors, but got: %v", err)
+	tests := []struct {
+		input     string
+		shouldErr bool
+		expected  []string
+	}{
+		{`bind 1.2.3.4`, false, []string{"1.2.3.4"}},
+		{`bind 127.0.0.1 ::1`, false, []string{"127.0.0.1", "::1"}},
+		{`bind 0.0.0.0
+		bind ::`, false, []string{"0.0.0.0", "::"}},
+		// fails
+		{`bind`, true, nil},
+		{`bind 1.2.3.bla`, true, nil},
+		{`bind 127.0.0.1 nosuchinterface0`, true, nil},
 	}
 
-	cfg := dnsserver.GetConfig(c)
-	if got, want := cfg.ListenHost, "1.2.3.4"; got != want {
-		t.Errorf("Expected the config's ListenHost to be %s, was %s", want, got)
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		err := setupBind(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %d: Expected error but found nil", i)
+			continue
+		}
+		if !test.shouldErr && err != nil {
+			t.Errorf("Test %d: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr {
+			continue
+		}
+		cfg := dnsserver.GetConfig(c)
+		if len(cfg.ListenHosts) != len(test.expected) {
+			t.Errorf("Test %d: Expected ListenHosts to be %v, got %v", i, test.expected, cfg.ListenHosts)
+			continue
+		}
+		for j := range test.expected {
+			if cfg.ListenHosts[j] != test.expected[j] {
+				t.Errorf("Test %d: Expected ListenHosts to be %v, got %v", i, test.expected, cfg.ListenHosts)
+			}
+		}
 	}
 }
 
-func TestBindAddress(t *testing.T) {
-	c := caddy.NewTestController("dns", `bind 1.2.3.bla`)
-	err := setupBind(c)
-	if err == nil {
-		t.Fatalf("Expected errors, but got none")
+func TestListenHostsInterface(t *testing.T) {
+	ifs, err := net.Interfaces()
+	if err != nil {
+		t.Skipf("Can not list interfaces: %s", err)
+	}
+	for _, ifi := range ifs {
+		if ifi.Flags&net.FlagLoopback == 0 {
+			continue
+		}
+		hosts, err := listenHosts(ifi.Name)
+		if err != nil {
+			t.Fatalf("Expected no error for interface %s, got %s", ifi.Name, err)
+		}
+		for _, h := range hosts {
+			if ip := net.ParseIP(h); ip == nil || !ip.IsLoopback() {
+				t.Errorf("Expected loopback address for interface %s, got %s", ifi.Name, h)
+			}
+		}
+		return
 	}
+	t.Skip("No loopback interface found")
 }
@@ -13,12 +13,46 @@ import (
 func setupBind(c *caddy.Controller) error {
 	config := dnsserver.GetConfig(c)
 	for c.Next() {
-		if !c.Args(&config.ListenHost) {
+		args := c.RemainingArgs()
+		if len(args) == 0 {
 			return middleware.Error("bind", c.ArgErr())
 		}
-	}
-	if net.ParseIP(config.ListenHost) == nil {
-		return middleware.Error("bind", fmt.Errorf("not a valid IP address: %s", config.ListenHost))
+		for _, a := range args {
+			hosts, err := listenHosts(a)
+			if err != nil {
+				return middleware.Error("bind", err)
+			}
+			config.ListenHosts = append(config.ListenHosts, hosts...)
+		}
 	}
 	return nil
 }
+
+// listenHosts returns the addresses to listen on for a, which is an IP address or the
+// name of a network interface. For an interface all its addresses are used, except the
+// link-local ones.
+func listenHosts(a string) ([]string, error) {
+	if net.ParseIP(a) != nil {
+		return []string{a}, nil
+	}
+	ifi, err := net.InterfaceByName(a)
+	if err != nil {
+		return nil, fmt.Errorf("not a valid IP address or interface name: %s", a)
+	}
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	var hosts []string
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok || ipnet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		hosts = append(hosts, ipnet.IP.String())
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("interface %s has no usable addresses", a)
+	}
+	return hosts, nil
+}
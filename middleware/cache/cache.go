@@ -2,10 +2,10 @@ package cache
 
 import (
 	"log"
-	"strings"
 	"time"
 
 	"github.com/miekg/coredns/middleware"
+	"github.com/miekg/coredns/middleware/pkg/edns"
 	"github.com/miekg/coredns/middleware/pkg/response"
 
 	"github.com/miekg/dns"
@@ -18,6 +18,12 @@ type Cache struct {
 	Zones []string
 	cache *gcache.Cache
 	cap   time.Duration
+	ttl   ttlPolicy
+
+	counter *counter // counts questions for the snapshot, nil when not enabled
+
+	stale   time.Duration // how long expired entries may be served, 0 disables serving them
+	refresh *refresher    // refreshes of stale entries that are in progress
 }
 
 // NewCache returns a new cache.
@@ -31,7 +37,7 @@ func cacheKey(m *dns.Msg, t response.Type, do bool) string {
 	}
 
 	qtype := m.Question[0].Qtype
-	qname := strings.ToLower(m.Question[0].Name)
+	qname := m.Question[0].Name
 	switch t {
 	case response.Success:
 		fallthrough
@@ -52,11 +58,13 @@ type ResponseWriter struct {
 	dns.ResponseWriter
 	cache *gcache.Cache
 	cap   time.Duration
+	ttl   ttlPolicy
+	stale time.Duration
 }
 
 // NewCachingResponseWriter returns a new ResponseWriter.
 func NewCachingResponseWriter(w dns.ResponseWriter, cache *gcache.Cache, cap time.Duration) *ResponseWriter {
-	return &ResponseWriter{w, cache, cap}
+	return &ResponseWriter{ResponseWriter: w, cache: cache, cap: cap}
 }
 
 // WriteMsg implements the dns.ResponseWriter interface.
@@ -67,13 +75,17 @@ func (c *ResponseWriter) WriteMsg(res *dns.Msg) error {
 		do = opt.Do()
 	}
 
-	key := cacheKey(res, mt, do)
-	c.set(res, key, mt)
-
+	// The TTLs are capped or clamped first, so the cached records have the TTLs this
+	// response is sent with.
 	if c.cap != 0 {
 		setCap(res, uint32(c.cap.Seconds()))
+	} else if len(res.Question) > 0 {
+		clampTTL(res, c.ttl.bounds(mt, res.Question[0].Qtype))
 	}
 
+	key := cacheKey(res, mt, do)
+	c.set(res, key, mt)
+
 	return c.ResponseWriter.WriteMsg(res)
 }
 
@@ -82,23 +94,31 @@ func (c *ResponseWriter) set(m *dns.Msg, key string, mt response.Type) {
 		log.Printf("[ERROR] Caching called with empty cache key")
 		return
 	}
+	if s := edns.Subnet(m); s != nil && s.SourceScope > 0 {
+		// The answer is tailored to the client's subnet, don't hand it to everybody.
+		return
+	}
 
 	duration := c.cap
+	b := bounds{}
+	if len(m.Question) > 0 {
+		b = c.ttl.bounds(mt, m.Question[0].Qtype)
+	}
 	switch mt {
 	case response.Success, response.Delegation:
 		if c.cap == 0 {
-			duration = minTTL(m.Answer, mt)
+			duration = b.clamp(minTTL(m.Answer, mt))
 		}
 		i := newItem(m, duration)
 
-		c.cache.Set(key, i, duration)
+		c.cache.Set(key, i, duration+c.stale)
 	case response.NameError, response.NoData:
 		if c.cap == 0 {
-			duration = minTTL(m.Ns, mt)
+			duration = b.clamp(minTTL(m.Ns, mt))
 		}
 		i := newItem(m, duration)
 
-		c.cache.Set(key, i, duration)
+		c.cache.Set(key, i, duration+c.stale)
 	case response.OtherError:
 		// don't cache these
 	default:
@@ -143,6 +163,6 @@ func minTTL(rrs []dns.RR, mt response.Type) time.Duration {
 const (
 	purgeDuration          = 1 * time.Minute
 	defaultDuration        = 20 * time.Minute
-	baseTTL                = 5 // minimum TTL that we will allow
+	baseTTL                = 5 // TTL of the records in stale answers
 	maxTTL          uint32 = 2 * 3600
 )
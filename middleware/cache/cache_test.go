@@ -9,6 +9,7 @@ import (
 	"github.com/miekg/coredns/middleware/test"
 
 	"github.com/miekg/dns"
+	"golang.org/x/net/context"
 )
 
 type cacheTestCase struct {
@@ -91,7 +92,7 @@ func TestCache(t *testing.T) {
 		}
 
 		if ok {
-			resp := i.toMsg(m)
+			resp := i.toMsg(m, time.Now().UTC())
 
 			if !test.Header(t, tc.Case, resp) {
 				t.Logf("%v\n", resp)
@@ -111,3 +112,25 @@ func TestCache(t *testing.T) {
 		}
 	}
 }
+
+func BenchmarkCacheHit(b *testing.B) {
+	next := middleware.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = []dns.RR{test.A(r.Question[0].Name + "	300	IN	A	127.0.0.53")}
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+	zones := []string{"example.net.", "example.com.", "miek.nl.", "in-addr.arpa.", "example.org."}
+	c := NewCache(0, zones, next)
+
+	req := new(dns.Msg)
+	req.SetQuestion("www.Example.org.", dns.TypeA)
+	c.ServeDNS(context.TODO(), &test.ResponseWriter{}, req)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.ServeDNS(context.TODO(), &test.ResponseWriter{}, req)
+	}
+}
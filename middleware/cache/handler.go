@@ -1,7 +1,10 @@
 package cache
 
 import (
+	"time"
+
 	"github.com/miekg/coredns/middleware"
+	"github.com/miekg/coredns/middleware/pkg/audit"
 	"github.com/miekg/coredns/request"
 
 	"github.com/miekg/dns"
@@ -22,30 +25,51 @@ func (c Cache) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (
 
 	do := state.Do() // might need more from OPT record?
 
+	if c.counter != nil {
+		c.counter.inc(question{name: qname, qtype: qtype})
+	}
+
 	if i, ok := c.get(qname, qtype, do); ok {
-		resp := i.toMsg(r)
+		now := time.Now().UTC()
+		resp := i.toMsg(r, now)
 		state.SizeAndDo(resp)
 		w.WriteMsg(resp)
 
+		if c.stale > 0 && i.expired(now) {
+			// Serve the expired entry, and have a single query update it.
+			c.refreshStale(r)
+			cacheStaleCount.WithLabelValues(zone).Inc()
+			audit.Add(ctx, "cache", "stale hit in zone %s", zone)
+			return dns.RcodeSuccess, nil
+		}
+
 		cacheHitCount.WithLabelValues(zone).Inc()
+		audit.Add(ctx, "cache", "hit in zone %s", zone)
 		return dns.RcodeSuccess, nil
 	}
 	cacheMissCount.WithLabelValues(zone).Inc()
+	audit.Add(ctx, "cache", "miss in zone %s", zone)
 
 	crr := NewCachingResponseWriter(w, c.cache, c.cap)
+	crr.ttl = c.ttl
+	crr.stale = c.stale
 	return c.Next.ServeDNS(ctx, crr, r)
 }
 
 func (c Cache) get(qname string, qtype uint16, do bool) (*item, bool) {
 	nxdomain := nameErrorKey(qname, do)
 	if i, ok := c.cache.Get(nxdomain); ok {
-		return i.(*item), true
+		if i := i.(*item); i.isFor(qname, do) {
+			return i, true
+		}
 	}
 
 	// TODO(miek): delegation was added double check
 	successOrNoData := successKey(qname, qtype, do)
 	if i, ok := c.cache.Get(successOrNoData); ok {
-		return i.(*item), true
+		if i := i.(*item); i.isFor(qname, do) && i.qtype == qtype {
+			return i, true
+		}
 	}
 	return nil, false
 }
@@ -64,6 +88,13 @@ var (
 		Name:      "miss_count_total",
 		Help:      "Counter of DNS requests that were not found in the cache.",
 	}, []string{"zone"})
+
+	cacheStaleCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: middleware.Namespace,
+		Subsystem: subsystem,
+		Name:      "stale_count_total",
+		Help:      "Counter of DNS requests that were answered with an expired cache entry.",
+	}, []string{"zone"})
 )
 
 const subsystem = "cache"
@@ -71,4 +102,5 @@ const subsystem = "cache"
 func init() {
 	prometheus.MustRegister(cacheHitCount)
 	prometheus.MustRegister(cacheMissCount)
+	prometheus.MustRegister(cacheStaleCount)
 }
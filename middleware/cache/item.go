@@ -1,7 +1,8 @@
 package cache
 
 import (
-	"strconv"
+	"encoding/binary"
+	"strings"
 	"time"
 
 	"github.com/miekg/dns"
@@ -17,6 +18,11 @@ type item struct {
 
 	origTTL uint32
 	stored  time.Time
+
+	// The question and DO bit the item was cached for, the cache keys are hashes.
+	qname string
+	qtype uint16
+	do    bool
 }
 
 func newItem(m *dns.Msg, d time.Duration) *item {
@@ -24,8 +30,9 @@ func newItem(m *dns.Msg, d time.Duration) *item {
 	i.Authoritative = m.Authoritative
 	i.AuthenticatedData = m.AuthenticatedData
 	i.RecursionAvailable = m.RecursionAvailable
-	i.Answer = m.Answer
-	i.Ns = m.Ns
+	// The records are copied, the TTLs of the copies handed out are changed.
+	i.Answer = copyRRs(m.Answer)
+	i.Ns = copyRRs(m.Ns)
 	i.Extra = make([]dns.RR, len(m.Extra))
 	// Don't copy OPT record as these are hop-by-hop.
 	j := 0
@@ -33,7 +40,7 @@ func newItem(m *dns.Msg, d time.Duration) *item {
 		if e.Header().Rrtype == dns.TypeOPT {
 			continue
 		}
-		i.Extra[j] = e
+		i.Extra[j] = dns.Copy(e)
 		j++
 	}
 	i.Extra = i.Extra[:j]
@@ -41,11 +48,22 @@ func newItem(m *dns.Msg, d time.Duration) *item {
 	i.origTTL = uint32(d.Seconds())
 	i.stored = time.Now().UTC()
 
+	if len(m.Question) > 0 {
+		i.qname = m.Question[0].Name
+		i.qtype = m.Question[0].Qtype
+	}
+	if opt := m.IsEdns0(); opt != nil {
+		i.do = opt.Do()
+	}
+
 	return i
 }
 
-// toMsg turns i into a message, it tailers to reply to m.
-func (i *item) toMsg(m *dns.Msg) *dns.Msg {
+// toMsg turns i into a message, it tailers to reply to m. The TTL of every record is
+// decremented by the time i has been in the cache at now, so caches downstream don't
+// keep it longer than the authority intended. An expired item, served when stale, has
+// TTLs of baseTTL.
+func (i *item) toMsg(m *dns.Msg, now time.Time) *dns.Msg {
 	m1 := new(dns.Msg)
 	m1.SetReply(m)
 	m1.Authoritative = i.Authoritative
@@ -53,18 +71,54 @@ func (i *item) toMsg(m *dns.Msg) *dns.Msg {
 	m1.RecursionAvailable = i.RecursionAvailable
 	m1.Compress = true
 
-	m1.Answer = i.Answer
-	m1.Ns = i.Ns
-	m1.Extra = i.Extra
+	age := uint32(now.Sub(i.stored).Seconds())
+	m1.Answer = decay(i.Answer, age)
+	m1.Ns = decay(i.Ns, age)
+	m1.Extra = decay(i.Extra, age)
 
-	ttl := int(i.origTTL) - int(time.Now().UTC().Sub(i.stored).Seconds())
-	if ttl < baseTTL {
-		ttl = baseTTL
+	if i.expired(now) {
+		setCap(m1, baseTTL)
 	}
-	setCap(m1, uint32(ttl))
 	return m1
 }
 
+// decay returns copies of rrs with their TTLs decremented by age, down to 0.
+func decay(rrs []dns.RR, age uint32) []dns.RR {
+	rrs = copyRRs(rrs)
+	for _, r := range rrs {
+		if h := r.Header(); h.Ttl > age {
+			h.Ttl -= age
+		} else {
+			h.Ttl = 0
+		}
+	}
+	return rrs
+}
+
+// copyRRs returns deep copies of rrs.
+func copyRRs(rrs []dns.RR) []dns.RR {
+	if rrs == nil {
+		return nil
+	}
+	c := make([]dns.RR, len(rrs))
+	for j, r := range rrs {
+		c[j] = dns.Copy(r)
+	}
+	return c
+}
+
+// isFor returns true if i was cached for qname and do. The qtype must be checked
+// separately, it doesn't matter for NXDOMAIN responses.
+func (i *item) isFor(qname string, do bool) bool {
+	return i.do == do && strings.EqualFold(i.qname, qname)
+}
+
+// expired returns true if the TTL of i has run out at now. An expired item can only
+// be found in the cache when serving stale entries is enabled.
+func (i *item) expired(now time.Time) bool {
+	return now.Sub(i.stored) > time.Duration(i.origTTL)*time.Second
+}
+
 // setCap sets the ttl on all RRs in all sections.
 func setCap(m *dns.Msg, ttl uint32) {
 	for _, r := range m.Answer {
@@ -81,21 +135,41 @@ func setCap(m *dns.Msg, ttl uint32) {
 	}
 }
 
-// nodataKey returns a caching key for NODATA responses.
-func noDataKey(qname string, qtype uint16, do bool) string {
-	if do {
-		return "1" + qname + ".." + strconv.Itoa(int(qtype))
-	}
-	return "0" + qname + ".." + strconv.Itoa(int(qtype))
-}
+// noDataKey returns a caching key for NODATA responses.
+func noDataKey(qname string, qtype uint16, do bool) string { return hashKey('d', qname, qtype, do) }
 
 // nameErrorKey returns a caching key for NXDOMAIN responses.
-func nameErrorKey(qname string, do bool) string {
+func nameErrorKey(qname string, do bool) string { return hashKey('n', qname, 0, do) }
+
+// successKey returns a caching key for successfull answers.
+func successKey(qname string, qtype uint16, do bool) string { return noDataKey(qname, qtype, do) }
+
+// hashKey returns the 64 bit FNV-1a hash of kind, qname, qtype and do as a string.
+// qname is lowercased while it is hashed, so this doesn't allocate, except for the
+// returned string. Different questions can have the same key, but the items record
+// what they were cached for.
+func hashKey(kind byte, qname string, qtype uint16, do bool) string {
+	h := uint64(offset64)
+	h = (h ^ uint64(kind)) * prime64
+	for i := 0; i < len(qname); i++ {
+		c := qname[i]
+		if c >= 'A' && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		h = (h ^ uint64(c)) * prime64
+	}
+	h = (h ^ uint64(qtype>>8)) * prime64
+	h = (h ^ uint64(qtype&0xFF)) * prime64
 	if do {
-		return "1" + qname
+		h = (h ^ 1) * prime64
 	}
-	return "0" + qname
+
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], h)
+	return string(b[:])
 }
 
-// successKey returns a caching key for successfull answers.
-func successKey(qname string, qtype uint16, do bool) string { return noDataKey(qname, qtype, do) }
+const (
+	offset64 = 14695981039346656037
+	prime64  = 1099511628211
+)
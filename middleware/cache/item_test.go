@@ -2,24 +2,91 @@ package cache
 
 import (
 	"testing"
+	"time"
+
+	"github.com/miekg/coredns/middleware/test"
 
 	"github.com/miekg/dns"
 )
 
 func TestKey(t *testing.T) {
-	if noDataKey("miek.nl.", dns.TypeMX, false) != "0miek.nl...15" {
-		t.Errorf("failed to create correct key")
+	if noDataKey("miek.nl.", dns.TypeMX, false) == noDataKey("miek.nl.", dns.TypeMX, true) {
+		t.Errorf("keys should differ in the DO bit")
+	}
+	if noDataKey("miek.nl.", dns.TypeMX, false) == noDataKey("miek.nl.", dns.TypeA, false) {
+		t.Errorf("keys should differ in the type")
 	}
-	if noDataKey("miek.nl.", dns.TypeMX, true) != "1miek.nl...15" {
-		t.Errorf("failed to create correct key")
+	if noDataKey("miek.nl.", dns.TypeMX, false) != noDataKey("MiEk.NL.", dns.TypeMX, false) {
+		t.Errorf("keys should be case insensitive")
 	}
-	if nameErrorKey("miek.nl.", false) != "0miek.nl." {
-		t.Errorf("failed to create correct key")
+	if nameErrorKey("miek.nl.", false) == nameErrorKey("miek.nl.", true) {
+		t.Errorf("keys should differ in the DO bit")
 	}
-	if nameErrorKey("miek.nl.", true) != "1miek.nl." {
-		t.Errorf("failed to create correct key")
+	if nameErrorKey("miek.nl.", false) == noDataKey("miek.nl.", 0, false) {
+		t.Errorf("nameErrorKey and noDataKey should differ")
 	}
 	if noDataKey("miek.nl.", dns.TypeMX, false) != successKey("miek.nl.", dns.TypeMX, false) {
-		t.Errorf("nameErrorKey and successKey should be the same")
+		t.Errorf("noDataKey and successKey should be the same")
+	}
+}
+
+func TestItemIsFor(t *testing.T) {
+	m := new(dns.Msg)
+	m.SetQuestion("miek.nl.", dns.TypeMX)
+	m.SetEdns0(4096, true)
+	i := newItem(m, 0)
+
+	if !i.isFor("MIEK.nl.", true) {
+		t.Errorf("item should be for miek.nl. with DO")
+	}
+	if i.isFor("miek.nl.", false) {
+		t.Errorf("item should not be for miek.nl. without DO")
+	}
+	if i.isFor("example.org.", true) {
+		t.Errorf("item should not be for example.org.")
+	}
+}
+
+func TestItemTTLDecay(t *testing.T) {
+	m := new(dns.Msg)
+	m.SetQuestion("miek.nl.", dns.TypeMX)
+	m.Answer = []dns.RR{
+		test.MX("miek.nl.	60	IN	MX	1 aspmx.l.google.com."),
+		test.MX("miek.nl.	1800	IN	MX	10 aspmx2.googlemail.com."),
+	}
+	m.Extra = []dns.RR{test.A("aspmx.l.google.com.	10	IN	A	127.0.0.1")}
+	i := newItem(m, 60*time.Second)
+	now := i.stored.Add(20 * time.Second)
+
+	resp := i.toMsg(m, now)
+	for j, ttl := range []uint32{40, 1780} {
+		if x := resp.Answer[j].Header().Ttl; x != ttl {
+			t.Errorf("expected TTL of %d for answer %d, got %d", ttl, j, x)
+		}
+	}
+	if x := resp.Extra[0].Header().Ttl; x != 0 {
+		t.Errorf("expected TTL of 0 for extra, got %d", x)
+	}
+
+	// The cached records are not changed, later replies decay from the original TTLs.
+	if x := i.Answer[1].Header().Ttl; x != 1800 {
+		t.Errorf("expected cached TTL of 1800, got %d", x)
+	}
+	resp = i.toMsg(m, now.Add(10*time.Second))
+	if x := resp.Answer[1].Header().Ttl; x != 1770 {
+		t.Errorf("expected TTL of 1770, got %d", x)
+	}
+
+	// Expired, as when served stale.
+	resp = i.toMsg(m, now.Add(time.Minute))
+	if x := resp.Answer[1].Header().Ttl; x != baseTTL {
+		t.Errorf("expected TTL of %d, got %d", baseTTL, x)
+	}
+}
+
+func BenchmarkKey(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		successKey("www.Example.org.", dns.TypeAAAA, true)
 	}
 }
@@ -1,12 +1,16 @@
 package cache
 
 import (
+	"fmt"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/miekg/coredns/core/dnsserver"
 	"github.com/miekg/coredns/middleware"
 
 	"github.com/mholt/caddy"
+	"github.com/miekg/dns"
 )
 
 func init() {
@@ -18,35 +22,92 @@ func init() {
 
 // Cache sets up the root file path of the server.
 func setup(c *caddy.Controller) error {
-	ttl, zones, err := cacheParse(c)
+	cfg, err := cacheParse(c)
 	if err != nil {
 		return middleware.Error("cache", err)
 	}
-	dnsserver.GetConfig(c).AddMiddleware(func(next middleware.Handler) middleware.Handler {
-		return NewCache(ttl, zones, next)
+
+	var ca Cache
+	config := dnsserver.GetConfig(c)
+	config.AddMiddleware(func(next middleware.Handler) middleware.Handler {
+		ca = NewCache(cfg.ttl, cfg.zones, next)
+		ca.ttl = cfg.policy
+		if cfg.stale > 0 {
+			ca.stale = cfg.stale
+			ca.refresh = newRefresher()
+		}
+		if cfg.snapshot != "" {
+			ca.counter = newCounter(cfg.top * counterFactor)
+		}
+		return ca
+	})
+
+	if cfg.seed == "" && cfg.snapshot == "" {
+		return nil
+	}
+
+	// Warm the cache once the backends have loaded their data.
+	config.OnStartupAfter("cache", []string{"file", "secondary", "etcd", "kubernetes"}, func() error {
+		var qs []question
+		for _, file := range []string{cfg.seed, cfg.snapshot} {
+			if file == "" {
+				continue
+			}
+			q, err := readQuestions(file)
+			if err != nil {
+				return middleware.Error("cache", err)
+			}
+			qs = append(qs, q...)
+		}
+		go ca.warm(qs)
+		return nil
 	})
 
+	if cfg.snapshot != "" {
+		c.OnShutdown(func() error {
+			if ca.counter == nil {
+				return nil
+			}
+			if err := writeQuestions(cfg.snapshot, ca.counter.top(cfg.top)); err != nil {
+				return middleware.Error("cache", err)
+			}
+			return nil
+		})
+	}
+
 	return nil
 }
 
-func cacheParse(c *caddy.Controller) (int, []string, error) {
+// config holds the parsed configuration of a cache directive.
+type config struct {
+	ttl    int
+	zones  []string
+	policy ttlPolicy
+
+	seed     string // seed list to warm the cache with
+	snapshot string // file to save the most popular questions to on shutdown
+	top      int    // number of questions to save in snapshot
+
+	stale time.Duration // how long expired entries may be served
+}
+
+func cacheParse(c *caddy.Controller) (config, error) {
 	var (
-		err     error
-		ttl     int
-		origins []string
+		err error
+		cfg config
 	)
 
 	for c.Next() {
 		if c.Val() == "cache" {
 			// cache [ttl] [zones..]
-			origins = make([]string, len(c.ServerBlockKeys))
+			origins := make([]string, len(c.ServerBlockKeys))
 			copy(origins, c.ServerBlockKeys)
 			args := c.RemainingArgs()
 			if len(args) > 0 {
 				origins = args
 				// first args may be just a number, then it is the ttl, if not it is a zone
 				t := origins[0]
-				ttl, err = strconv.Atoi(t)
+				cfg.ttl, err = strconv.Atoi(t)
 				if err == nil {
 					origins = origins[1:]
 					if len(origins) == 0 {
@@ -56,11 +117,153 @@ func cacheParse(c *caddy.Controller) (int, []string, error) {
 				}
 			}
 
-			for i := range origins {
-				origins[i] = middleware.Host(origins[i]).Normalize()
+			origins = middleware.Zones(origins).NormalizeExact()
+			cfg.zones = origins
+
+			for c.NextBlock() {
+				switch c.Val() {
+				case "warm":
+					if !c.NextArg() {
+						return cfg, c.ArgErr()
+					}
+					cfg.seed = c.Val()
+				case "serve_stale":
+					args := c.RemainingArgs()
+					if len(args) > 1 {
+						return cfg, c.ArgErr()
+					}
+					cfg.stale = defaultStale
+					if len(args) == 1 {
+						d, err := parseSeconds(args[0])
+						if err != nil {
+							return cfg, err
+						}
+						if d == 0 {
+							return cfg, c.Errf("serve_stale must be larger than zero")
+						}
+						cfg.stale = d
+					}
+				case "snapshot":
+					args := c.RemainingArgs()
+					if len(args) == 0 || len(args) > 2 {
+						return cfg, c.ArgErr()
+					}
+					cfg.snapshot = args[0]
+					cfg.top = defaultSnapshotSize
+					if len(args) == 2 {
+						n, err := strconv.Atoi(args[1])
+						if err != nil {
+							return cfg, err
+						}
+						if n <= 0 {
+							return cfg, c.Errf("snapshot size must be positive: %d", n)
+						}
+						cfg.top = n
+					}
+				default:
+					if err := ttlParse(c, &cfg.policy); err != nil {
+						return cfg, err
+					}
+				}
 			}
-			return ttl, origins, nil
+			return cfg, nil
 		}
 	}
-	return 0, nil, nil
+	return cfg, nil
 }
+
+// ttlParse parses the TTL clamping properties:
+//
+//	min_ttl [success|denial] SECONDS
+//	max_ttl [success|denial] SECONDS
+//	qtype TYPE MIN MAX
+func ttlParse(c *caddy.Controller, p *ttlPolicy) error {
+	switch what := c.Val(); what {
+	case "min_ttl", "max_ttl":
+		args := c.RemainingArgs()
+		var targets []*bounds
+		switch len(args) {
+		case 1:
+			targets = []*bounds{&p.success, &p.denial}
+		case 2:
+			switch args[0] {
+			case "success":
+				targets = []*bounds{&p.success}
+			case "denial":
+				targets = []*bounds{&p.denial}
+			default:
+				return c.Errf("unknown response type '%s'", args[0])
+			}
+			args = args[1:]
+		default:
+			return c.ArgErr()
+		}
+		d, err := parseSeconds(args[0])
+		if err != nil {
+			return err
+		}
+		for _, b := range targets {
+			if what == "min_ttl" {
+				b.min = d
+			} else {
+				b.max = d
+			}
+		}
+	case "qtype":
+		args := c.RemainingArgs()
+		if len(args) != 3 {
+			return c.ArgErr()
+		}
+		qtype, ok := dns.StringToType[strings.ToUpper(args[0])]
+		if !ok {
+			return c.Errf("unknown type '%s'", args[0])
+		}
+		min, err := parseSeconds(args[1])
+		if err != nil {
+			return err
+		}
+		max, err := parseSeconds(args[2])
+		if err != nil {
+			return err
+		}
+		if p.qtype == nil {
+			p.qtype = make(map[uint16]bounds)
+		}
+		p.qtype[qtype] = bounds{min: min, max: max}
+	default:
+		return c.Errf("unknown property '%s'", what)
+	}
+
+	for _, b := range append([]bounds{p.success, p.denial}, qtypeBounds(p)...) {
+		if b.min > 0 && b.max > 0 && b.min > b.max {
+			return c.Errf("min_ttl (%s) is larger than max_ttl (%s)", b.min, b.max)
+		}
+	}
+	return nil
+}
+
+func qtypeBounds(p *ttlPolicy) []bounds {
+	bx := make([]bounds, 0, len(p.qtype))
+	for _, b := range p.qtype {
+		bx = append(bx, b)
+	}
+	return bx
+}
+
+func parseSeconds(s string) (time.Duration, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("TTL can not be negative: %d", n)
+	}
+	return time.Duration(n) * time.Second, nil
+}
+
+const (
+	defaultSnapshotSize = 1000
+	defaultStale        = time.Hour
+	// counterFactor times the snapshot size is the number of questions that are counted.
+	counterFactor = 10
+)
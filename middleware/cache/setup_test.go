@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mholt/caddy"
+	"github.com/miekg/dns"
+)
+
+func TestSetupTTL(t *testing.T) {
+	tests := []struct {
+		input           string
+		shouldErr       bool
+		expectedSuccess bounds
+		expectedDenial  bounds
+		expectedA       bounds
+	}{
+		{`cache`, false, bounds{}, bounds{}, bounds{}},
+		{`cache {
+			min_ttl 10
+			max_ttl 3600
+		}`, false, bounds{10 * time.Second, time.Hour}, bounds{10 * time.Second, time.Hour}, bounds{}},
+		{`cache {
+			min_ttl success 30
+			max_ttl denial 60
+		}`, false, bounds{min: 30 * time.Second}, bounds{max: 60 * time.Second}, bounds{}},
+		{`cache 10 example.org {
+			qtype A 5 300
+		}`, false, bounds{}, bounds{}, bounds{5 * time.Second, 300 * time.Second}},
+		// fails
+		{`cache {
+			min_ttl 60
+			max_ttl 10
+		}`, true, bounds{}, bounds{}, bounds{}},
+		{`cache {
+			min_ttl blaat 10
+		}`, true, bounds{}, bounds{}, bounds{}},
+		{`cache {
+			min_ttl -10
+		}`, true, bounds{}, bounds{}, bounds{}},
+		{`cache {
+			qtype BLAAT 10 20
+		}`, true, bounds{}, bounds{}, bounds{}},
+		{`cache {
+			blaat
+		}`, true, bounds{}, bounds{}, bounds{}},
+	}
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		cfg, err := cacheParse(c)
+		policy := cfg.policy
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %d: Expected error but found nil", i)
+			continue
+		}
+		if !test.shouldErr && err != nil {
+			t.Errorf("Test %d: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr {
+			continue
+		}
+		if policy.success != test.expectedSuccess {
+			t.Errorf("Test %d: Expected success bounds %v, got %v", i, test.expectedSuccess, policy.success)
+		}
+		if policy.denial != test.expectedDenial {
+			t.Errorf("Test %d: Expected denial bounds %v, got %v", i, test.expectedDenial, policy.denial)
+		}
+		if policy.qtype[dns.TypeA] != test.expectedA {
+			t.Errorf("Test %d: Expected A bounds %v, got %v", i, test.expectedA, policy.qtype[dns.TypeA])
+		}
+	}
+}
+
+func TestSetupServeStale(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		expected  time.Duration
+	}{
+		{`cache`, false, 0},
+		{`cache {
+			serve_stale
+		}`, false, time.Hour},
+		{`cache {
+			serve_stale 300
+		}`, false, 5 * time.Minute},
+		// fails
+		{`cache {
+			serve_stale 0
+		}`, true, 0},
+		{`cache {
+			serve_stale 10 20
+		}`, true, 0},
+	}
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		cfg, err := cacheParse(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %d: Expected error but found nil", i)
+			continue
+		}
+		if !test.shouldErr && err != nil {
+			t.Errorf("Test %d: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr {
+			continue
+		}
+		if cfg.stale != test.expected {
+			t.Errorf("Test %d: Expected serve_stale %s, got %s", i, test.expected, cfg.stale)
+		}
+	}
+}
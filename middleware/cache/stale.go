@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"log"
+	"sync"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+// refresher keeps track of the stale entries that are being refreshed, so each is
+// only queried once, no matter how many clients ask for it in the meantime.
+type refresher struct {
+	sync.Mutex
+	busy map[string]bool
+}
+
+func newRefresher() *refresher { return &refresher{busy: make(map[string]bool)} }
+
+// start returns true if no refresh for key is in progress, it then marks key as busy.
+func (r *refresher) start(key string) bool {
+	r.Lock()
+	defer r.Unlock()
+	if r.busy[key] {
+		return false
+	}
+	r.busy[key] = true
+	return true
+}
+
+func (r *refresher) done(key string) {
+	r.Lock()
+	delete(r.busy, key)
+	r.Unlock()
+}
+
+// refreshStale queries the middleware chain below c for r in the background, the
+// response replaces the stale entry in the cache. If a refresh for r is already
+// running, this is a noop. When the refresh fails the stale entry is kept until it
+// is too old to be served.
+func (c Cache) refreshStale(r *dns.Msg) {
+	do := false
+	if opt := r.IsEdns0(); opt != nil {
+		do = opt.Do()
+	}
+	key := successKey(r.Question[0].Name, r.Question[0].Qtype, do)
+	if !c.refresh.start(key) {
+		return
+	}
+
+	m := r.Copy()
+	go func() {
+		defer c.refresh.done(key)
+
+		crr := NewCachingResponseWriter(&warmWriter{}, c.cache, c.cap)
+		crr.ttl = c.ttl
+		crr.stale = c.stale
+		if _, err := c.Next.ServeDNS(context.Background(), crr, m); err != nil {
+			log.Printf("[WARNING] Failed to refresh stale cache entry for %s: %s", m.Question[0].Name, err)
+		}
+	}()
+}
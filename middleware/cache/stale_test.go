@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/coredns/middleware"
+	"github.com/miekg/coredns/middleware/pkg/dnsrecorder"
+	"github.com/miekg/coredns/middleware/test"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+func TestServeStale(t *testing.T) {
+	asked := make(chan struct{}, 10)
+	release := make(chan struct{})
+	next := middleware.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		asked <- struct{}{}
+		<-release
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = []dns.RR{test.A(r.Question[0].Name + "	300	IN	A	127.0.0.2")}
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+	c := NewCache(0, []string{"."}, next)
+	c.stale = time.Hour
+	c.refresh = newRefresher()
+
+	// An entry that expired a minute ago.
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	m.Answer = []dns.RR{test.A("example.org.	10	IN	A	127.0.0.1")}
+	i := newItem(m, 10*time.Second)
+	i.stored = time.Now().UTC().Add(-70 * time.Second)
+	c.cache.Set(successKey("example.org.", dns.TypeA, false), i, time.Hour)
+
+	for n := 0; n < 3; n++ {
+		req := new(dns.Msg)
+		req.SetQuestion("example.org.", dns.TypeA)
+		rec := dnsrecorder.New(&test.ResponseWriter{})
+		c.ServeDNS(context.TODO(), rec, req)
+
+		if rec.Msg == nil || len(rec.Msg.Answer) != 1 {
+			t.Fatalf("Query %d: expected the stale answer, got %v", n, rec.Msg)
+		}
+		if a := rec.Msg.Answer[0].(*dns.A).A.String(); a != "127.0.0.1" {
+			t.Errorf("Query %d: expected stale address 127.0.0.1, got %s", n, a)
+		}
+		if ttl := rec.Msg.Answer[0].Header().Ttl; ttl != baseTTL {
+			t.Errorf("Query %d: expected TTL %d, got %d", n, baseTTL, ttl)
+		}
+	}
+
+	<-asked
+	close(release)
+	// Wait for the refresh to be stored.
+	for n := 0; n < 20; n++ {
+		if i, ok := c.get("example.org.", dns.TypeA, false); ok && !i.expired(time.Now().UTC()) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	select {
+	case <-asked:
+		t.Errorf("Expected a single refresh query")
+	default:
+	}
+
+	i, ok := c.get("example.org.", dns.TypeA, false)
+	if !ok || i.expired(time.Now().UTC()) {
+		t.Fatalf("Expected the refreshed entry in the cache")
+	}
+	if a := i.Answer[0].(*dns.A).A.String(); a != "127.0.0.2" {
+		t.Errorf("Expected refreshed address 127.0.0.2, got %s", a)
+	}
+}
@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/miekg/coredns/middleware/pkg/response"
+
+	"github.com/miekg/dns"
+)
+
+// bounds holds a minimum and maximum TTL, a zero value means no bound.
+type bounds struct {
+	min time.Duration
+	max time.Duration
+}
+
+// clamp returns d clamped to b.
+func (b bounds) clamp(d time.Duration) time.Duration {
+	if b.min > 0 && d < b.min {
+		d = b.min
+	}
+	if b.max > 0 && d > b.max {
+		d = b.max
+	}
+	return d
+}
+
+func (b bounds) zero() bool { return b.min == 0 && b.max == 0 }
+
+// ttlPolicy holds the TTL bounds for positive (success) and negative (denial)
+// responses, with optional per qtype overrides for positive responses.
+type ttlPolicy struct {
+	success bounds
+	denial  bounds
+	qtype   map[uint16]bounds
+}
+
+// bounds returns the bounds that should be applied to a response of type mt
+// for a question with type qtype.
+func (p ttlPolicy) bounds(mt response.Type, qtype uint16) bounds {
+	switch mt {
+	case response.NameError, response.NoData:
+		return p.denial
+	case response.Success, response.Delegation:
+		if b, ok := p.qtype[qtype]; ok {
+			return b
+		}
+		return p.success
+	}
+	return bounds{}
+}
+
+// clampTTL clamps the TTLs of all RRs in m to b.
+func clampTTL(m *dns.Msg, b bounds) {
+	if b.zero() {
+		return
+	}
+	clamp := func(rrs []dns.RR) {
+		for _, r := range rrs {
+			if r.Header().Rrtype == dns.TypeOPT {
+				continue
+			}
+			ttl := b.clamp(time.Duration(r.Header().Ttl) * time.Second)
+			r.Header().Ttl = uint32(ttl.Seconds())
+		}
+	}
+	clamp(m.Answer)
+	clamp(m.Ns)
+	clamp(m.Extra)
+}
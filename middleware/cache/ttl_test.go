@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/coredns/middleware/pkg/response"
+	"github.com/miekg/coredns/middleware/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestTTLPolicy(t *testing.T) {
+	p := ttlPolicy{
+		success: bounds{min: 30 * time.Second, max: time.Hour},
+		denial:  bounds{max: 60 * time.Second},
+		qtype:   map[uint16]bounds{dns.TypeMX: {min: 300 * time.Second}},
+	}
+
+	tests := []struct {
+		mt       response.Type
+		qtype    uint16
+		in       time.Duration
+		expected time.Duration
+	}{
+		{response.Success, dns.TypeA, 0, 30 * time.Second},
+		{response.Success, dns.TypeA, 7 * 24 * time.Hour, time.Hour},
+		{response.Success, dns.TypeA, 600 * time.Second, 600 * time.Second},
+		{response.Success, dns.TypeMX, 10 * time.Second, 300 * time.Second},
+		{response.Success, dns.TypeMX, 7 * 24 * time.Hour, 7 * 24 * time.Hour},
+		{response.NameError, dns.TypeA, 3600 * time.Second, 60 * time.Second},
+		{response.NoData, dns.TypeMX, 3600 * time.Second, 60 * time.Second},
+		{response.OtherError, dns.TypeA, 3600 * time.Second, 3600 * time.Second},
+	}
+	for i, tc := range tests {
+		if x := p.bounds(tc.mt, tc.qtype).clamp(tc.in); x != tc.expected {
+			t.Errorf("Test %d: expected %s, got %s", i, tc.expected, x)
+		}
+	}
+}
+
+func TestClampTTL(t *testing.T) {
+	m := new(dns.Msg)
+	m.SetQuestion("miek.nl.", dns.TypeMX)
+	m.Answer = []dns.RR{
+		test.MX("miek.nl.	0	IN	MX	1 aspmx.l.google.com."),
+		test.MX("miek.nl.	604800	IN	MX	10 aspmx2.googlemail.com."),
+	}
+	clampTTL(m, bounds{min: 5 * time.Second, max: time.Hour})
+
+	if x := m.Answer[0].Header().Ttl; x != 5 {
+		t.Errorf("expected TTL of 5, got %d", x)
+	}
+	if x := m.Answer[1].Header().Ttl; x != 3600 {
+		t.Errorf("expected TTL of 3600, got %d", x)
+	}
+}
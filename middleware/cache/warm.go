@@ -0,0 +1,214 @@
+package cache
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+// question is a name and type tuple that we use to warm the cache.
+type question struct {
+	name  string
+	qtype uint16
+}
+
+func (q question) String() string { return q.name + " " + dns.Type(q.qtype).String() }
+
+// warm resolves all questions in qs through the middleware chain below c, the
+// responses end up in the cache. It is meant to be called in a goroutine right
+// after startup.
+func (c Cache) warm(qs []question) {
+	if c.Next == nil {
+		return
+	}
+	ok := 0
+	for _, q := range qs {
+		if _, cached := c.get(q.name, q.qtype, false); cached {
+			continue
+		}
+		m := new(dns.Msg)
+		m.SetQuestion(q.name, q.qtype)
+
+		crr := NewCachingResponseWriter(&warmWriter{}, c.cache, c.cap)
+		crr.ttl = c.ttl
+		crr.stale = c.stale
+		if _, err := c.Next.ServeDNS(context.Background(), crr, m); err != nil {
+			log.Printf("[WARNING] Failed to warm cache for %s: %s", q, err)
+			continue
+		}
+		ok++
+	}
+	log.Printf("[INFO] Warmed cache with %d out of %d names", ok, len(qs))
+}
+
+// parseQuestions parses a seed list in r. Each line contains a name and optionally
+// a type, which defaults to A. Empty lines and lines starting with '#' are ignored.
+func parseQuestions(r io.Reader) ([]question, error) {
+	var qs []question
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		q := question{name: strings.ToLower(dns.Fqdn(fields[0])), qtype: dns.TypeA}
+		switch len(fields) {
+		case 1:
+		case 2:
+			qtype, ok := dns.StringToType[strings.ToUpper(fields[1])]
+			if !ok {
+				return nil, fmt.Errorf("line %d: unknown type '%s'", line, fields[1])
+			}
+			q.qtype = qtype
+		default:
+			return nil, fmt.Errorf("line %d: expected name and type, got %d fields", line, len(fields))
+		}
+		qs = append(qs, q)
+	}
+	return qs, scanner.Err()
+}
+
+// readQuestions reads the seed list from file. A non existent file is not an error,
+// it just results in no questions.
+func readQuestions(file string) ([]question, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	qs, err := parseQuestions(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+	return qs, nil
+}
+
+// counter counts how often a question is asked, so we can save the most
+// popular ones on shutdown and use those to warm the cache on the next start.
+// It counts at most max questions: when it is full, the least asked half is
+// dropped, so a flood of random names can't make it grow without bound.
+type counter struct {
+	sync.Mutex
+	count map[question]uint64
+	max   int
+}
+
+func newCounter(max int) *counter {
+	return &counter{count: make(map[question]uint64), max: max}
+}
+
+func (c *counter) inc(q question) {
+	c.Lock()
+	if _, ok := c.count[q]; !ok && len(c.count) >= c.max {
+		c.prune()
+	}
+	c.count[q]++
+	c.Unlock()
+}
+
+// prune keeps the max/2 most asked questions. It is called with the lock held.
+func (c *counter) prune() {
+	qs := c.sorted()
+	count := make(map[question]uint64, c.max)
+	for _, q := range qs[:c.max/2] {
+		count[q] = c.count[q]
+	}
+	c.count = count
+}
+
+// sorted returns the questions, the most asked first. It is called with the lock held.
+func (c *counter) sorted() []question {
+	qs := make([]question, 0, len(c.count))
+	for q := range c.count {
+		qs = append(qs, q)
+	}
+	sort.Sort(byCount{qs, c.count})
+	return qs
+}
+
+// top returns the n most asked questions.
+func (c *counter) top(n int) []question {
+	c.Lock()
+	qs := c.sorted()
+	c.Unlock()
+
+	if len(qs) > n {
+		qs = qs[:n]
+	}
+	return qs
+}
+
+type byCount struct {
+	qs    []question
+	count map[question]uint64
+}
+
+func (b byCount) Len() int      { return len(b.qs) }
+func (b byCount) Swap(i, j int) { b.qs[i], b.qs[j] = b.qs[j], b.qs[i] }
+func (b byCount) Less(i, j int) bool {
+	ci, cj := b.count[b.qs[i]], b.count[b.qs[j]]
+	if ci == cj {
+		return b.qs[i].String() < b.qs[j].String()
+	}
+	return ci > cj
+}
+
+// writeQuestions writes qs to file, in the format parseQuestions understands. The
+// questions are written to a temporary file that is renamed to file, so a crash
+// doesn't leave a truncated file behind.
+func writeQuestions(file string, qs []question) error {
+	f, err := ioutil.TempFile(filepath.Dir(file), filepath.Base(file)+".")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, q := range qs {
+		fmt.Fprintln(w, q)
+	}
+	err = w.Flush()
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), file)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// warmWriter is a dns.ResponseWriter that discards everything written to it, it
+// is used for queries originated by the cache itself.
+type warmWriter struct{}
+
+func (w *warmWriter) LocalAddr() net.Addr {
+	return &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 53}
+}
+func (w *warmWriter) RemoteAddr() net.Addr {
+	return &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 0}
+}
+func (w *warmWriter) WriteMsg(m *dns.Msg) error     { return nil }
+func (w *warmWriter) Write(buf []byte) (int, error) { return len(buf), nil }
+func (w *warmWriter) Close() error                  { return nil }
+func (w *warmWriter) TsigStatus() error             { return nil }
+func (w *warmWriter) TsigTimersOnly(bool)           { return }
+func (w *warmWriter) Hijack()                       { return }
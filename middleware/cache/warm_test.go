@@ -0,0 +1,134 @@
+package cache
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/miekg/coredns/middleware"
+	"github.com/miekg/coredns/middleware/test"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+func TestParseQuestions(t *testing.T) {
+	qs, err := parseQuestions(strings.NewReader(`# seed list
+miek.nl
+example.org. MX
+
+www.example.org AAAA
+`))
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	expected := []question{
+		{"miek.nl.", dns.TypeA},
+		{"example.org.", dns.TypeMX},
+		{"www.example.org.", dns.TypeAAAA},
+	}
+	if len(qs) != len(expected) {
+		t.Fatalf("Expected %d questions, got %d", len(expected), len(qs))
+	}
+	for i := range qs {
+		if qs[i] != expected[i] {
+			t.Errorf("Expected question %d to be %s, got %s", i, expected[i], qs[i])
+		}
+	}
+
+	if _, err := parseQuestions(strings.NewReader("miek.nl BLAAT")); err == nil {
+		t.Errorf("Expected error for unknown type, got none")
+	}
+	if _, err := parseQuestions(strings.NewReader("miek.nl A A")); err == nil {
+		t.Errorf("Expected error for too many fields, got none")
+	}
+}
+
+func TestCounterTop(t *testing.T) {
+	c := newCounter(10)
+	for i := 0; i < 3; i++ {
+		c.inc(question{"a.miek.nl.", dns.TypeA})
+	}
+	c.inc(question{"b.miek.nl.", dns.TypeA})
+	c.inc(question{"c.miek.nl.", dns.TypeA})
+	c.inc(question{"c.miek.nl.", dns.TypeA})
+
+	top := c.top(2)
+	if len(top) != 2 {
+		t.Fatalf("Expected 2 questions, got %d", len(top))
+	}
+	if top[0].name != "a.miek.nl." || top[1].name != "c.miek.nl." {
+		t.Errorf("Expected a.miek.nl. and c.miek.nl., got %s and %s", top[0], top[1])
+	}
+}
+
+func TestCounterMax(t *testing.T) {
+	c := newCounter(10)
+	for i := 0; i < 5; i++ {
+		c.inc(question{"popular.miek.nl.", dns.TypeA})
+	}
+	for i := 0; i < 1000; i++ {
+		c.inc(question{fmt.Sprintf("r%d.miek.nl.", i), dns.TypeA})
+	}
+	if n := len(c.count); n > 10 {
+		t.Errorf("Expected at most 10 questions to be counted, got %d", n)
+	}
+	if top := c.top(1); top[0].name != "popular.miek.nl." {
+		t.Errorf("Expected popular.miek.nl. to survive, got %s", top[0])
+	}
+}
+
+func TestWriteQuestions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coredns-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "snapshot")
+	qs := []question{{"a.miek.nl.", dns.TypeA}, {"b.miek.nl.", dns.TypeAAAA}}
+	if err := writeQuestions(file, qs); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	read, err := readQuestions(file)
+	if err != nil || len(read) != 2 || read[1] != qs[1] {
+		t.Errorf("Expected to read back %v, got %v (%v)", qs, read, err)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Errorf("Expected only the snapshot in the directory, got %d files", len(files))
+	}
+}
+
+func TestWarm(t *testing.T) {
+	asked := 0
+	next := middleware.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		asked++
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = []dns.RR{test.A(r.Question[0].Name + "	300	IN	A	127.0.0.53")}
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+	c := NewCache(0, []string{"."}, next)
+
+	qs := []question{{"miek.nl.", dns.TypeA}, {"example.org.", dns.TypeA}}
+	c.warm(qs)
+
+	if asked != 2 {
+		t.Errorf("Expected 2 queries to be sent to the next middleware, got %d", asked)
+	}
+	for _, q := range qs {
+		if _, ok := c.get(q.name, q.qtype, false); !ok {
+			t.Errorf("Expected %s to be cached", q)
+		}
+	}
+
+	// Warming again should not query anything, everything is cached.
+	c.warm(qs)
+	if asked != 2 {
+		t.Errorf("Expected no new queries, got %d", asked-2)
+	}
+}
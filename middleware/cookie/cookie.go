@@ -0,0 +1,72 @@
+// Package cookie implements the cookie directive that enables DNS cookies (RFC 7873) for a
+// server block.
+package cookie
+
+import (
+	"strconv"
+
+	"github.com/miekg/coredns/core/dnsserver"
+	"github.com/miekg/coredns/middleware"
+
+	"github.com/mholt/caddy"
+)
+
+func init() {
+	caddy.RegisterPlugin("cookie", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setupCookie,
+	})
+}
+
+func setupCookie(c *caddy.Controller) error {
+	cookie, err := cookieParse(c)
+	if err != nil {
+		return middleware.Error("cookie", err)
+	}
+	dnsserver.GetConfig(c).Cookie = cookie
+	return nil
+}
+
+func cookieParse(c *caddy.Controller) (*dnsserver.Cookie, error) {
+	var cookie *dnsserver.Cookie
+	for c.Next() {
+		if cookie != nil {
+			return nil, c.Err("cookie can only be specified once")
+		}
+		args := c.RemainingArgs()
+		if len(args) > 1 {
+			return nil, c.ArgErr()
+		}
+		secret := ""
+		if len(args) == 1 {
+			secret = args[0]
+		}
+		var err error
+		if cookie, err = dnsserver.NewCookie(secret); err != nil {
+			return nil, err
+		}
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "require":
+				if len(c.RemainingArgs()) != 0 {
+					return nil, c.ArgErr()
+				}
+				cookie.Require = true
+			case "max_unverified_size":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				n, err := strconv.Atoi(args[0])
+				if err != nil || n < 512 {
+					return nil, c.Errf("max_unverified_size must be a number of at least 512: %s", args[0])
+				}
+				cookie.MaxUnverifiedSize = n
+			default:
+				return nil, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+	}
+	return cookie, nil
+}
@@ -0,0 +1,59 @@
+package cookie
+
+import (
+	"testing"
+
+	"github.com/mholt/caddy"
+)
+
+func TestCookieParse(t *testing.T) {
+	tests := []struct {
+		input           string
+		shouldErr       bool
+		expectedRequire bool
+		expectedMaxSize int
+	}{
+		{`cookie`, false, false, 0},
+		{`cookie 000102030405060708090a0b0c0d0e0f`, false, false, 0},
+		{`cookie {
+			require
+			max_unverified_size 1232
+		}`, false, true, 1232},
+		// fails
+		{`cookie 0001`, true, false, 0},
+		{`cookie 000102030405060708090a0b0c0d0e0f extra`, true, false, 0},
+		{`cookie {
+			require yes
+		}`, true, false, 0},
+		{`cookie {
+			max_unverified_size 100
+		}`, true, false, 0},
+		{`cookie {
+			blaat
+		}`, true, false, 0},
+		{`cookie
+		cookie`, true, false, 0},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		cookie, err := cookieParse(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %d: Expected error but found nil", i)
+			continue
+		}
+		if !test.shouldErr && err != nil {
+			t.Errorf("Test %d: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr {
+			continue
+		}
+		if cookie.Require != test.expectedRequire {
+			t.Errorf("Test %d: Expected require to be %t, got %t", i, test.expectedRequire, cookie.Require)
+		}
+		if cookie.MaxUnverifiedSize != test.expectedMaxSize {
+			t.Errorf("Test %d: Expected max_unverified_size %d, got %d", i, test.expectedMaxSize, cookie.MaxUnverifiedSize)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+// Package delay implements a middleware that delays, drops or truncates responses, to
+// rehearse how applications deal with a slow or unreliable DNS.
+package delay
+
+import (
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/miekg/coredns/middleware"
+	"github.com/miekg/coredns/request"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+// Delay delays queries for Zones from clients in From by Latency, plus a random
+// duration up to Jitter. Of these queries it drops Drop percent, and answers Truncate
+// percent with an empty truncated response.
+type Delay struct {
+	Next  middleware.Handler
+	Zones []string
+	From  []*net.IPNet // client networks to delay, empty is all clients
+
+	Latency time.Duration
+	Jitter  time.Duration
+
+	Drop     float64 // percentage of the queries that get no response
+	Truncate float64 // percentage of the UDP queries that get a truncated response
+}
+
+// ServeDNS implements the middleware.Handler interface.
+func (d Delay) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: r}
+
+	if middleware.Zones(d.Zones).Matches(state.Name()) == "" || !d.match(state) {
+		return d.Next.ServeDNS(ctx, w, r)
+	}
+
+	n := rand.Float64() * 100
+	if n < d.Drop {
+		return dns.RcodeSuccess, nil
+	}
+
+	select {
+	case <-time.After(d.duration()):
+	case <-ctx.Done():
+		return dns.RcodeServerFailure, ctx.Err()
+	}
+
+	// Over TCP the client can't retry with a larger buffer, it would just fail.
+	if n < d.Drop+d.Truncate && state.Proto() == "udp" {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Truncated = true
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	}
+	return d.Next.ServeDNS(ctx, w, r)
+}
+
+// match returns true if the client of state is in one of the networks of d.
+func (d Delay) match(state request.Request) bool {
+	if len(d.From) == 0 {
+		return true
+	}
+	ip := net.ParseIP(state.IP())
+	for _, n := range d.From {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d Delay) duration() time.Duration {
+	if d.Jitter <= 0 {
+		return d.Latency
+	}
+	return d.Latency + time.Duration(rand.Int63n(int64(d.Jitter)))
+}
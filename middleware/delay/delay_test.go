@@ -0,0 +1,93 @@
+package delay
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/coredns/middleware/pkg/dnsrecorder"
+	"github.com/miekg/coredns/middleware/test"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+func TestDelay(t *testing.T) {
+	_, local, _ := net.ParseCIDR("10.240.0.0/16") // test.ResponseWriter's address
+	_, other, _ := net.ParseCIDR("192.168.0.0/16")
+
+	tests := []struct {
+		qname   string
+		from    []*net.IPNet
+		delayed bool
+	}{
+		{"example.org.", nil, true},
+		{"www.example.org.", []*net.IPNet{local}, true},
+		{"example.org.", []*net.IPNet{other}, false},
+		{"example.net.", nil, false},
+	}
+
+	for i, tc := range tests {
+		d := Delay{Next: test.ErrorHandler(), Zones: []string{"example.org."}, From: tc.from, Latency: 50 * time.Millisecond}
+
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, dns.TypeA)
+		start := time.Now()
+		d.ServeDNS(context.TODO(), dnsrecorder.New(&test.ResponseWriter{}), m)
+		delayed := time.Since(start) >= d.Latency
+
+		if delayed != tc.delayed {
+			t.Errorf("Test %d: expected delayed to be %t", i, tc.delayed)
+		}
+	}
+}
+
+func TestDelayCancel(t *testing.T) {
+	d := Delay{Next: test.ErrorHandler(), Zones: []string{"."}, Latency: time.Hour}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	rcode, err := d.ServeDNS(ctx, dnsrecorder.New(&test.ResponseWriter{}), m)
+	if err == nil || rcode != dns.RcodeServerFailure {
+		t.Errorf("Expected SERVFAIL and an error after the deadline, got %s and %v", dns.RcodeToString[rcode], err)
+	}
+}
+
+func TestDelayFaults(t *testing.T) {
+	tests := []struct {
+		drop, truncate float64
+		expectedReply  bool
+		expectedTC     bool
+	}{
+		{100, 0, false, false},
+		{0, 100, true, true},
+		{50, 50, false, false}, // either of them
+		{0, 0, true, false},
+	}
+
+	for i, tc := range tests {
+		d := Delay{Next: test.ErrorHandler(), Zones: []string{"example.org."}, Drop: tc.drop, Truncate: tc.truncate}
+
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		rec := dnsrecorder.New(&test.ResponseWriter{})
+		d.ServeDNS(context.TODO(), rec, m)
+
+		if tc.drop > 0 && tc.truncate > 0 {
+			if rec.Msg != nil && !rec.Msg.Truncated {
+				t.Errorf("Test %d: expected no reply or a truncated one", i)
+			}
+			continue
+		}
+		if (rec.Msg != nil) != tc.expectedReply {
+			t.Errorf("Test %d: expected a reply to be %t", i, tc.expectedReply)
+			continue
+		}
+		if rec.Msg != nil && rec.Msg.Truncated != tc.expectedTC {
+			t.Errorf("Test %d: expected TC to be %t", i, tc.expectedTC)
+		}
+	}
+}
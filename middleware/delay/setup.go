@@ -0,0 +1,119 @@
+package delay
+
+import (
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/miekg/coredns/core/dnsserver"
+	"github.com/miekg/coredns/middleware"
+
+	"github.com/mholt/caddy"
+)
+
+func init() {
+	caddy.RegisterPlugin("delay", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	d, err := delayParse(c)
+	if err != nil {
+		return middleware.Error("delay", err)
+	}
+
+	dnsserver.GetConfig(c).AddMiddleware(func(next middleware.Handler) middleware.Handler {
+		d.Next = next
+		return d
+	})
+
+	return nil
+}
+
+func delayParse(c *caddy.Controller) (Delay, error) {
+	d := Delay{}
+
+	for c.Next() {
+		origins := make([]string, len(c.ServerBlockKeys))
+		copy(origins, c.ServerBlockKeys)
+		if args := c.RemainingArgs(); len(args) > 0 {
+			origins = args
+		}
+		d.Zones = middleware.Zones(origins).NormalizeExact()
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "latency", "jitter":
+				what := c.Val()
+				if !c.NextArg() {
+					return d, c.ArgErr()
+				}
+				dur, err := time.ParseDuration(c.Val())
+				if err != nil {
+					return d, err
+				}
+				if dur < 0 {
+					return d, c.Errf("%s can not be negative: %s", what, dur)
+				}
+				if what == "latency" {
+					d.Latency = dur
+				} else {
+					d.Jitter = dur
+				}
+				if c.NextArg() {
+					return d, c.ArgErr()
+				}
+			case "drop", "truncate":
+				what := c.Val()
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return d, c.ArgErr()
+				}
+				p, err := strconv.ParseFloat(args[0], 64)
+				if err != nil {
+					return d, err
+				}
+				if p <= 0 || p > 100 {
+					return d, c.Errf("%s must be a percentage larger than 0: %s", what, args[0])
+				}
+				if what == "drop" {
+					d.Drop = p
+				} else {
+					d.Truncate = p
+				}
+			case "from":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return d, c.ArgErr()
+				}
+				for _, a := range args {
+					_, n, err := net.ParseCIDR(a)
+					if err != nil {
+						ip := net.ParseIP(a)
+						if ip == nil {
+							return d, c.Errf("not a network or IP address: %s", a)
+						}
+						bits := 128
+						if ip.To4() != nil {
+							ip, bits = ip.To4(), 32
+						}
+						n = &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+					}
+					d.From = append(d.From, n)
+				}
+			default:
+				return d, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+	}
+
+	if d.Latency == 0 && d.Jitter == 0 && d.Drop == 0 && d.Truncate == 0 {
+		return d, c.Err("no latency, jitter, drop or truncate set")
+	}
+	if d.Drop+d.Truncate > 100 {
+		return d, c.Errf("drop and truncate add up to more than 100 percent: %g", d.Drop+d.Truncate)
+	}
+	return d, nil
+}
@@ -0,0 +1,125 @@
+package delay
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mholt/caddy"
+)
+
+func TestSetupDelay(t *testing.T) {
+	tests := []struct {
+		input           string
+		shouldErr       bool
+		expectedLatency time.Duration
+		expectedJitter  time.Duration
+		expectedFrom    int
+	}{
+		{`delay {
+			latency 100ms
+		}`, false, 100 * time.Millisecond, 0, 0},
+		{`delay example.org {
+			latency 1s
+			jitter 500ms
+			from 10.0.0.0/8 192.168.1.1 2001:db8::/32
+		}`, false, time.Second, 500 * time.Millisecond, 3},
+		// fails
+		{`delay`, true, 0, 0, 0},
+		{`delay {
+			latency
+		}`, true, 0, 0, 0},
+		{`delay {
+			latency -1s
+		}`, true, 0, 0, 0},
+		{`delay {
+			latency 1s
+			from example.org
+		}`, true, 0, 0, 0},
+		{`delay {
+			blaat 1s
+		}`, true, 0, 0, 0},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		d, err := delayParse(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %d: Expected error but found nil", i)
+			continue
+		}
+		if !test.shouldErr && err != nil {
+			t.Errorf("Test %d: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr {
+			continue
+		}
+		if d.Latency != test.expectedLatency {
+			t.Errorf("Test %d: Expected latency %s, got %s", i, test.expectedLatency, d.Latency)
+		}
+		if d.Jitter != test.expectedJitter {
+			t.Errorf("Test %d: Expected jitter %s, got %s", i, test.expectedJitter, d.Jitter)
+		}
+		if len(d.From) != test.expectedFrom {
+			t.Errorf("Test %d: Expected %d networks, got %d", i, test.expectedFrom, len(d.From))
+		}
+	}
+}
+
+func TestSetupDelayFaults(t *testing.T) {
+	tests := []struct {
+		input            string
+		shouldErr        bool
+		expectedDrop     float64
+		expectedTruncate float64
+	}{
+		{`delay {
+			drop 10
+		}`, false, 10, 0},
+		{`delay example.org {
+			latency 100ms
+			drop 0.5
+			truncate 25
+			from 10.0.0.0/8
+		}`, false, 0.5, 25},
+		// fails
+		{`delay {
+			drop
+		}`, true, 0, 0},
+		{`delay {
+			drop 0
+		}`, true, 0, 0},
+		{`delay {
+			truncate 101
+		}`, true, 0, 0},
+		{`delay {
+			drop lots
+		}`, true, 0, 0},
+		{`delay {
+			drop 60
+			truncate 60
+		}`, true, 0, 0},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		d, err := delayParse(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %d: Expected error but found nil", i)
+			continue
+		}
+		if !test.shouldErr && err != nil {
+			t.Errorf("Test %d: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr {
+			continue
+		}
+		if d.Drop != test.expectedDrop {
+			t.Errorf("Test %d: Expected drop %g, got %g", i, test.expectedDrop, d.Drop)
+		}
+		if d.Truncate != test.expectedTruncate {
+			t.Errorf("Test %d: Expected truncate %g, got %g", i, test.expectedTruncate, d.Truncate)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+// Package doh implements the doh directive that configures the DNS-over-HTTPS
+// listeners of a server.
+package doh
+
+import "github.com/mholt/caddy"
+
+func init() {
+	caddy.RegisterPlugin("doh", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setupDoH,
+	})
+}
@@ -0,0 +1,29 @@
+package doh
+
+import (
+	"github.com/miekg/coredns/core/dnsserver"
+	"github.com/miekg/coredns/middleware"
+
+	"github.com/mholt/caddy"
+)
+
+func setupDoH(c *caddy.Controller) error {
+	config := dnsserver.GetConfig(c)
+	for c.Next() {
+		if len(c.RemainingArgs()) != 0 {
+			return middleware.Error("doh", c.ArgErr())
+		}
+		for c.NextBlock() {
+			switch c.Val() {
+			case "json":
+				if len(c.RemainingArgs()) != 0 {
+					return middleware.Error("doh", c.ArgErr())
+				}
+				config.JSONAPI = true
+			default:
+				return middleware.Error("doh", c.Errf("unknown property '%s'", c.Val()))
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package doh
+
+import (
+	"testing"
+
+	"github.com/miekg/coredns/core/dnsserver"
+
+	"github.com/mholt/caddy"
+)
+
+func TestSetupDoH(t *testing.T) {
+	tests := []struct {
+		input        string
+		shouldErr    bool
+		expectedJSON bool
+	}{
+		{`doh`, false, false},
+		{`doh {
+			json
+		}`, false, true},
+		// fails
+		{`doh json`, true, false},
+		{`doh {
+			json yes
+		}`, true, false},
+		{`doh {
+			blaat
+		}`, true, false},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		err := setupDoH(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %d: Expected error but found nil", i)
+			continue
+		}
+		if !test.shouldErr && err != nil {
+			t.Errorf("Test %d: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr {
+			continue
+		}
+		if cfg := dnsserver.GetConfig(c); cfg.JSONAPI != test.expectedJSON {
+			t.Errorf("Test %d: Expected JSONAPI to be %t, got %t", i, test.expectedJSON, cfg.JSONAPI)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+// Package edns implements a middleware that sets what is done with the unknown EDNS0
+// options of queries.
+package edns
+
+import (
+	"github.com/miekg/coredns/middleware"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+// Policy is what is done with the unknown EDNS0 options of a query.
+type Policy int
+
+const (
+	// Strip removes the unknown options from the query and from the reply.
+	Strip Policy = iota
+	// Echo removes the unknown options from the query and copies them to the reply.
+	Echo
+	// Forward leaves the unknown options in the query, so proxy sends them upstream, and
+	// returns the reply as the upstream gave it.
+	Forward
+)
+
+// EDNS applies Policy to the EDNS0 options of queries that this server does not know. The
+// known options are the ones miekg/dns has a type for, the others it parses as
+// EDNS0_LOCAL. Without it, the middleware that build replies from the query's OPT record
+// echo all options.
+type EDNS struct {
+	Next   middleware.Handler
+	Policy Policy
+}
+
+// ServeDNS implements the middleware.Handler interface.
+func (e EDNS) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	opt := r.IsEdns0()
+	if opt == nil {
+		return e.Next.ServeDNS(ctx, w, r)
+	}
+
+	var unknown []dns.EDNS0
+	if e.Policy != Forward {
+		opt.Option, unknown = split(opt.Option)
+	}
+	ew := &ResponseWriter{ResponseWriter: w, policy: e.Policy, query: opt, unknown: unknown}
+	return e.Next.ServeDNS(ctx, ew, r)
+}
+
+// ResponseWriter applies the policy to the OPT record of the reply.
+type ResponseWriter struct {
+	dns.ResponseWriter
+	policy  Policy
+	query   *dns.OPT
+	unknown []dns.EDNS0
+}
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (w *ResponseWriter) WriteMsg(res *dns.Msg) error {
+	opt := res.IsEdns0()
+	if opt == nil {
+		return w.ResponseWriter.WriteMsg(res)
+	}
+	switch w.policy {
+	case Strip:
+		opt.Option, _ = split(opt.Option)
+	case Echo:
+		opt.Option, _ = split(opt.Option)
+		opt.Option = append(opt.Option, w.unknown...)
+	case Forward:
+		// Middleware that answer themselves, instead of the upstream, put the OPT record
+		// of the query in the reply; its unknown options are not ours to echo.
+		if opt == w.query {
+			known, _ := split(opt.Option)
+			res.Extra = replaceOPT(res.Extra, opt, known)
+		}
+	}
+	return w.ResponseWriter.WriteMsg(res)
+}
+
+// split splits options in the known and unknown ones.
+func split(options []dns.EDNS0) (known, unknown []dns.EDNS0) {
+	for _, o := range options {
+		if _, ok := o.(*dns.EDNS0_LOCAL); ok {
+			unknown = append(unknown, o)
+			continue
+		}
+		known = append(known, o)
+	}
+	return known, unknown
+}
+
+// replaceOPT returns extra with opt replaced by a copy of it that has options. The query
+// keeps its options, so it is still forwarded with them.
+func replaceOPT(extra []dns.RR, opt *dns.OPT, options []dns.EDNS0) []dns.RR {
+	o := &dns.OPT{Hdr: opt.Hdr, Option: options}
+	out := make([]dns.RR, len(extra))
+	for i, rr := range extra {
+		if rr == dns.RR(opt) {
+			out[i] = o
+			continue
+		}
+		out[i] = rr
+	}
+	return out
+}
@@ -0,0 +1,79 @@
+package edns
+
+import (
+	"testing"
+
+	"github.com/miekg/coredns/middleware/pkg/dnsrecorder"
+	"github.com/miekg/coredns/middleware/test"
+	"github.com/miekg/coredns/request"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+func TestEDNS(t *testing.T) {
+	// local answers from the query's OPT record, upstream like proxy with an OPT record of
+	// its own. Both record the options of the query they get.
+	var queryOptions int
+	local := test.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		queryOptions = len(r.IsEdns0().Option)
+		m := new(dns.Msg)
+		m.SetReply(r)
+		state := request.Request{W: w, Req: r}
+		state.SizeAndDo(m)
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+	upstream := test.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		queryOptions = len(r.IsEdns0().Option)
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.SetEdns0(4096, false)
+		o := m.IsEdns0()
+		o.Option = append(o.Option, &dns.EDNS0_LOCAL{Code: 65002, Data: []byte{2}})
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+
+	tests := []struct {
+		policy               Policy
+		next                 test.HandlerFunc
+		expectedQueryOptions int
+		expectedReplyOptions []uint16
+	}{
+		{Strip, local, 1, []uint16{dns.EDNS0NSID}},
+		{Strip, upstream, 1, nil},
+		{Echo, local, 1, []uint16{dns.EDNS0NSID, 65001}},
+		{Forward, local, 2, []uint16{dns.EDNS0NSID}},
+		{Forward, upstream, 2, []uint16{65002}},
+	}
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		m.SetEdns0(4096, false)
+		o := m.IsEdns0()
+		o.Option = append(o.Option, &dns.EDNS0_NSID{Code: dns.EDNS0NSID}, &dns.EDNS0_LOCAL{Code: 65001, Data: []byte{1}})
+
+		e := EDNS{Next: tc.next, Policy: tc.policy}
+		rec := dnsrecorder.New(&test.ResponseWriter{})
+		if _, err := e.ServeDNS(context.TODO(), rec, m); err != nil {
+			t.Fatalf("Test %d: expected no error, got %s", i, err)
+		}
+		if queryOptions != tc.expectedQueryOptions {
+			t.Errorf("Test %d: expected %d options in the query, got %d", i, tc.expectedQueryOptions, queryOptions)
+		}
+		got := []uint16{}
+		for _, opt := range rec.Msg.IsEdns0().Option {
+			got = append(got, opt.Option())
+		}
+		if len(got) != len(tc.expectedReplyOptions) {
+			t.Errorf("Test %d: expected options %v in the reply, got %v", i, tc.expectedReplyOptions, got)
+			continue
+		}
+		for j := range got {
+			if got[j] != tc.expectedReplyOptions[j] {
+				t.Errorf("Test %d: expected options %v in the reply, got %v", i, tc.expectedReplyOptions, got)
+			}
+		}
+	}
+}
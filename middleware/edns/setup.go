@@ -0,0 +1,58 @@
+package edns
+
+import (
+	"github.com/miekg/coredns/core/dnsserver"
+	"github.com/miekg/coredns/middleware"
+
+	"github.com/mholt/caddy"
+)
+
+func init() {
+	caddy.RegisterPlugin("edns", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	policy, err := ednsParse(c)
+	if err != nil {
+		return middleware.Error("edns", err)
+	}
+
+	dnsserver.GetConfig(c).AddMiddleware(func(next middleware.Handler) middleware.Handler {
+		return EDNS{Next: next, Policy: policy}
+	})
+
+	return nil
+}
+
+// ednsParse parses 'edns unknown strip|echo|forward'.
+func ednsParse(c *caddy.Controller) (Policy, error) {
+	policy := Strip
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return policy, c.Err("edns can only be given once")
+		}
+		i++
+		args := c.RemainingArgs()
+		if len(args) != 2 {
+			return policy, c.ArgErr()
+		}
+		if args[0] != "unknown" {
+			return policy, c.Errf("unknown property '%s'", args[0])
+		}
+		switch args[1] {
+		case "strip":
+			policy = Strip
+		case "echo":
+			policy = Echo
+		case "forward":
+			policy = Forward
+		default:
+			return policy, c.Errf("unknown policy '%s'", args[1])
+		}
+	}
+	return policy, nil
+}
@@ -0,0 +1,42 @@
+package edns
+
+import (
+	"testing"
+
+	"github.com/mholt/caddy"
+)
+
+func TestSetupEDNS(t *testing.T) {
+	tests := []struct {
+		input          string
+		shouldErr      bool
+		expectedPolicy Policy
+	}{
+		{`edns unknown strip`, false, Strip},
+		{`edns unknown echo`, false, Echo},
+		{`edns unknown forward`, false, Forward},
+		// fails
+		{`edns`, true, Strip},
+		{`edns unknown`, true, Strip},
+		{`edns known strip`, true, Strip},
+		{`edns unknown drop`, true, Strip},
+		{`edns unknown strip
+		edns unknown echo`, true, Strip},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		policy, err := ednsParse(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %d: Expected error but found nil", i)
+			continue
+		}
+		if !test.shouldErr && err != nil {
+			t.Errorf("Test %d: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if !test.shouldErr && policy != test.expectedPolicy {
+			t.Errorf("Test %d: Expected policy %d, got %d", i, test.expectedPolicy, policy)
+		}
+	}
+}
@@ -5,9 +5,9 @@ import (
 	"fmt"
 	"log"
 	"runtime"
-	"strings"
 	"time"
 
+	"github.com/miekg/coredns/core/dnsserver"
 	"github.com/miekg/coredns/middleware"
 	"github.com/miekg/coredns/request"
 
@@ -25,7 +25,9 @@ type errorHandler struct {
 
 // ServeDNS implements the middleware.Handler interface.
 func (h errorHandler) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
-	defer h.recovery(ctx, w, r)
+	if h.Debug {
+		defer h.recovery(ctx, w, r)
+	}
 
 	rcode, err := h.Next.ServeDNS(ctx, w, r)
 
@@ -48,6 +50,8 @@ func (h errorHandler) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns
 	return rcode, err
 }
 
+// recovery writes the error and stack trace of a panic to the response, it is only used
+// in debug mode. Otherwise panics are recovered by the server and logged by logPanic.
 func (h errorHandler) recovery(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) {
 	rec := recover()
 	if rec == nil {
@@ -55,46 +59,22 @@ func (h errorHandler) recovery(ctx context.Context, w dns.ResponseWriter, r *dns
 	}
 
 	state := request.Request{W: w, Req: r}
-	// Obtain source of panic
-	// From: https://gist.github.com/swdunlop/9629168
-	var name, file string // function name, file name
-	var line int
-	var pc [16]uintptr
-	n := runtime.Callers(3, pc[:])
-	for _, pc := range pc[:n] {
-		fn := runtime.FuncForPC(pc)
-		if fn == nil {
-			continue
-		}
-		file, line = fn.FileLine(pc)
-		name = fn.Name()
-		if !strings.HasPrefix(name, "runtime.") {
-			break
-		}
-	}
-
-	// Trim file path
-	delim := "/coredns/"
-	pkgPathPos := strings.Index(file, delim)
-	if pkgPathPos > -1 && len(file) > pkgPathPos+len(delim) {
-		file = file[pkgPathPos+len(delim):]
+	var stackBuf [4096]byte
+	stack := stackBuf[:runtime.Stack(stackBuf[:], false)]
+	answer := debugMsg(dns.RcodeServerFailure, r)
+	// add stack buf in TXT, limited to 255 chars for now.
+	if len(stack) > 255 {
+		stack = stack[:255]
 	}
+	txt, _ := dns.NewRR(". IN 0 TXT " + string(stack))
+	answer.Answer = append(answer.Answer, txt)
+	state.SizeAndDo(answer)
+	w.WriteMsg(answer)
+}
 
-	panicMsg := fmt.Sprintf("%s [PANIC %s %s] %s:%d - %v", time.Now().Format(timeFormat), r.Question[0].Name, dns.Type(r.Question[0].Qtype), file, line, rec)
-	if h.Debug {
-		// Write error and stack trace to the response rather than to a log
-		var stackBuf [4096]byte
-		stack := stackBuf[:runtime.Stack(stackBuf[:], false)]
-		answer := debugMsg(dns.RcodeServerFailure, r)
-		// add stack buf in TXT, limited to 255 chars for now.
-		txt, _ := dns.NewRR(". IN 0 TXT " + string(stack[:255]))
-		answer.Answer = append(answer.Answer, txt)
-		state.SizeAndDo(answer)
-		w.WriteMsg(answer)
-	} else {
-		// Currently we don't use the function name, since file:line is more conventional
-		h.Log.Printf(panicMsg)
-	}
+// logPanic logs the panic p, including its stack trace.
+func (h errorHandler) logPanic(p dnsserver.Panic) {
+	h.Log.Printf("%s [PANIC %s %s] %s - %v\n%s", time.Now().Format(timeFormat), p.Qname, dns.Type(p.Qtype), p.Middleware, p.Value, p.Stack)
 }
 
 // debugMsg creates a debug message that gets send back to the client.
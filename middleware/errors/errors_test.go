@@ -8,6 +8,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/miekg/coredns/core/dnsserver"
 	"github.com/miekg/coredns/middleware"
 	"github.com/miekg/coredns/middleware/pkg/dnsrecorder"
 	"github.com/miekg/coredns/middleware/test"
@@ -90,6 +91,16 @@ func TestVisibleErrorWithPanic(t *testing.T) {
 	}
 }
 
+func TestLogPanic(t *testing.T) {
+	buf := bytes.Buffer{}
+	eh := errorHandler{Log: log.New(&buf, "", 0)}
+
+	eh.logPanic(dnsserver.Panic{Value: "I'm a panic", Stack: []byte("goroutine 1"), Middleware: "file", Qname: "example.org.", Qtype: dns.TypeA})
+	if log := buf.String(); !strings.Contains(log, "[PANIC example.org. A] file - I'm a panic") || !strings.Contains(log, "goroutine 1") {
+		t.Errorf("Expected panic and stack to be logged, got %q", log)
+	}
+}
+
 func genErrorHandler(rcode int, err error) middleware.Handler {
 	return middleware.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
 		return rcode, err
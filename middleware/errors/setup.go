@@ -54,7 +54,12 @@ func setup(c *caddy.Controller) error {
 	}
 	handler.Log = log.New(writer, "", 0)
 
-	dnsserver.GetConfig(c).AddMiddleware(func(next middleware.Handler) middleware.Handler {
+	config := dnsserver.GetConfig(c)
+	if !handler.Debug {
+		// Panics are recovered by the server, it hands them to us for logging.
+		config.OnPanic(handler.logPanic)
+	}
+	config.AddMiddleware(func(next middleware.Handler) middleware.Handler {
 		handler.Next = next
 		return handler
 	})
@@ -1,13 +1,10 @@
-// +build etcd
-
 package etcd
 
-// etcd needs to be running on http://localhost:2379
-
 import (
 	"testing"
 
 	"github.com/miekg/coredns/middleware/etcd/msg"
+	"github.com/miekg/coredns/middleware/pkg/cname"
 	"github.com/miekg/coredns/middleware/pkg/dnsrecorder"
 	"github.com/miekg/coredns/middleware/test"
 
@@ -20,7 +17,7 @@ func TestCnameLookup(t *testing.T) {
 
 	for _, serv := range servicesCname {
 		set(t, etc, serv.Key, 0, serv)
-		defer delete(t, etc, serv.Key)
+		defer del(t, etc, serv.Key)
 	}
 	for _, tc := range dnsTestCasesCname {
 		m := tc.Msg()
@@ -49,6 +46,36 @@ func TestCnameLookup(t *testing.T) {
 	}
 }
 
+func TestCnameMaxDepth(t *testing.T) {
+	etc := newEtcdMiddleware()
+
+	for _, serv := range servicesCname {
+		set(t, etc, serv.Key, 0, serv)
+		defer del(t, etc, serv.Key)
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion("cname1.region2.skydns.test.", dns.TypeA)
+	m.SetEdns0(4096, false)
+
+	rec := dnsrecorder.New(&test.ResponseWriter{})
+	if _, err := etc.ServeDNS(cname.NewContext(ctxt, 3), rec, m); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if rec.Msg.Rcode != dns.RcodeServerFailure {
+		t.Fatalf("expected SERVFAIL for a chain of 6 CNAMEs, got %s", dns.RcodeToString[rec.Msg.Rcode])
+	}
+	if opt := rec.Msg.IsEdns0(); opt == nil || len(opt.Option) != 1 {
+		t.Errorf("expected an extended error, got %v", opt)
+	}
+
+	rec = dnsrecorder.New(&test.ResponseWriter{})
+	etc.ServeDNS(ctxt, rec, m)
+	if rec.Msg.Rcode != dns.RcodeSuccess || len(rec.Msg.Answer) != 7 {
+		t.Errorf("expected the chain of 6 CNAMEs and the A record, got %v", rec.Msg)
+	}
+}
+
 var servicesCname = []*msg.Service{
 	{Host: "cname1.region2.skydns.test", Key: "a.server1.dev.region1.skydns.test."},
 	{Host: "cname2.region2.skydns.test", Key: "cname1.region2.skydns.test."},
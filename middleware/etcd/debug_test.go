@@ -1,5 +1,3 @@
-// +build etcd
-
 package etcd
 
 import (
@@ -35,7 +33,7 @@ func TestDebugLookup(t *testing.T) {
 
 	for _, serv := range servicesDebug {
 		set(t, etc, serv.Key, 0, serv)
-		defer delete(t, etc, serv.Key)
+		defer del(t, etc, serv.Key)
 	}
 
 	for _, tc := range dnsTestCasesDebug {
@@ -74,7 +72,7 @@ func TestDebugLookupFalse(t *testing.T) {
 
 	for _, serv := range servicesDebug {
 		set(t, etc, serv.Key, 0, serv)
-		defer delete(t, etc, serv.Key)
+		defer del(t, etc, serv.Key)
 	}
 	for _, tc := range dnsTestCasesDebugFalse {
 		m := tc.Msg()
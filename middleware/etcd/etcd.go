@@ -143,6 +143,18 @@ func (e *Etcd) TTL(node *etcdc.Node, serv *msg.Service) uint32 {
 	return serv.TTL
 }
 
+// Ping returns an error if etcd can't be reached. A missing path is fine, the records may
+// not have been added yet.
+func (e *Etcd) Ping() error {
+	ctx, cancel := context.WithTimeout(e.Ctx, etcdTimeout)
+	defer cancel()
+	_, err := e.Client.Get(ctx, "/"+e.PathPrefix, nil)
+	if err != nil && !isEtcdNameError(err) {
+		return err
+	}
+	return nil
+}
+
 // etcNameError checks if the error is ErrorCodeKeyNotFound from etcd.
 func isEtcdNameError(err error) bool {
 	if e, ok := err.(etcdc.Error); ok && e.Code == etcdc.ErrorCodeKeyNotFound {
@@ -0,0 +1,161 @@
+package etcd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	etcdc "github.com/coreos/etcd/client"
+	"golang.org/x/net/context"
+)
+
+// fakeKeysAPI is an in-memory etcdc.KeysAPI, it implements enough of the etcd v2 semantics
+// (directories, recursive gets and TTLs) to test the middleware without a running etcd.
+type fakeKeysAPI struct {
+	sync.RWMutex
+	keys map[string]*etcdc.Node // leaf nodes, keyed on their full path
+}
+
+func newFakeKeysAPI() *fakeKeysAPI { return &fakeKeysAPI{keys: make(map[string]*etcdc.Node)} }
+
+// Get implements etcdc.KeysAPI. A key that holds a value returns that node, a key that is a
+// prefix of other keys returns a directory.
+func (f *fakeKeysAPI) Get(ctx context.Context, key string, opts *etcdc.GetOptions) (*etcdc.Response, error) {
+	f.RLock()
+	defer f.RUnlock()
+
+	key = clean(key)
+	if n, ok := f.keys[key]; ok {
+		c := *n
+		return &etcdc.Response{Action: "get", Node: &c}, nil
+	}
+	recursive := opts != nil && opts.Recursive
+	if n := f.dir(key, recursive); n != nil {
+		return &etcdc.Response{Action: "get", Node: n}, nil
+	}
+	return nil, etcdc.Error{Code: etcdc.ErrorCodeKeyNotFound, Message: "Key not found", Cause: key}
+}
+
+// dir returns the directory node for key, or nil when there are no keys below it. The
+// children of sub directories are only filled in when recursive is true.
+func (f *fakeKeysAPI) dir(key string, recursive bool) *etcdc.Node {
+	prefix := key + "/"
+	if key == "/" {
+		prefix = key
+	}
+
+	children := map[string]bool{}
+	for k := range f.keys {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		child := k[len(prefix):]
+		if i := strings.Index(child, "/"); i > 0 {
+			child = child[:i]
+		}
+		children[prefix+child] = true
+	}
+	if len(children) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(children))
+	for c := range children {
+		names = append(names, c)
+	}
+	sort.Strings(names)
+
+	n := &etcdc.Node{Key: key, Dir: true}
+	for _, c := range names {
+		if leaf, ok := f.keys[c]; ok {
+			l := *leaf
+			n.Nodes = append(n.Nodes, &l)
+			continue
+		}
+		if recursive {
+			n.Nodes = append(n.Nodes, f.dir(c, true))
+			continue
+		}
+		n.Nodes = append(n.Nodes, &etcdc.Node{Key: c, Dir: true})
+	}
+	return n
+}
+
+// Set implements etcdc.KeysAPI.
+func (f *fakeKeysAPI) Set(ctx context.Context, key, value string, opts *etcdc.SetOptions) (*etcdc.Response, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	key = clean(key)
+	n := &etcdc.Node{Key: key, Value: value}
+	if opts != nil {
+		n.TTL = int64(opts.TTL.Seconds())
+	}
+	f.keys[key] = n
+	return &etcdc.Response{Action: "set", Node: n}, nil
+}
+
+// Delete implements etcdc.KeysAPI.
+func (f *fakeKeysAPI) Delete(ctx context.Context, key string, opts *etcdc.DeleteOptions) (*etcdc.Response, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	key = clean(key)
+	if n, ok := f.keys[key]; ok {
+		delete(f.keys, key)
+		return &etcdc.Response{Action: "delete", Node: n}, nil
+	}
+	if opts != nil && opts.Recursive {
+		found := false
+		for k := range f.keys {
+			if strings.HasPrefix(k, key+"/") {
+				delete(f.keys, k)
+				found = true
+			}
+		}
+		if found {
+			return &etcdc.Response{Action: "delete", Node: &etcdc.Node{Key: key, Dir: true}}, nil
+		}
+	}
+	return nil, etcdc.Error{Code: etcdc.ErrorCodeKeyNotFound, Message: "Key not found", Cause: key}
+}
+
+// Create implements etcdc.KeysAPI.
+func (f *fakeKeysAPI) Create(ctx context.Context, key, value string) (*etcdc.Response, error) {
+	return f.Set(ctx, key, value, nil)
+}
+
+// CreateInOrder implements etcdc.KeysAPI.
+func (f *fakeKeysAPI) CreateInOrder(ctx context.Context, dir, value string, opts *etcdc.CreateInOrderOptions) (*etcdc.Response, error) {
+	f.RLock()
+	key := fmt.Sprintf("%s/%020d", clean(dir), len(f.keys))
+	f.RUnlock()
+	return f.Set(ctx, key, value, nil)
+}
+
+// Update implements etcdc.KeysAPI.
+func (f *fakeKeysAPI) Update(ctx context.Context, key, value string) (*etcdc.Response, error) {
+	return f.Set(ctx, key, value, nil)
+}
+
+// Watcher implements etcdc.KeysAPI. The returned watcher never sees any changes.
+func (f *fakeKeysAPI) Watcher(key string, opts *etcdc.WatcherOptions) etcdc.Watcher {
+	return fakeWatcher{}
+}
+
+type fakeWatcher struct{}
+
+func (fakeWatcher) Next(ctx context.Context) (*etcdc.Response, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// clean removes any trailing slashes from key and makes sure it starts with one.
+func clean(key string) string {
+	key = strings.TrimRight(key, "/")
+	if !strings.HasPrefix(key, "/") {
+		key = "/" + key
+	}
+	return key
+}
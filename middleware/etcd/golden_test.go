@@ -0,0 +1,27 @@
+package etcd
+
+import (
+	"testing"
+
+	"github.com/miekg/coredns/middleware/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestGolden(t *testing.T) {
+	etc := newEtcdMiddleware()
+	for _, serv := range services {
+		set(t, etc, serv.Key, 0, serv)
+		defer del(t, etc, serv.Key)
+	}
+
+	srv := test.Case{Qname: "a.server1.prod.region1.skydns.test.", Qtype: dns.TypeSRV}.Msg()
+	srv.SetEdns0(4096, false)
+
+	test.Golden(t, "testdata/skydns.test.golden", etc, []*dns.Msg{
+		test.Case{Qname: "a.server1.prod.region1.skydns.test.", Qtype: dns.TypeA}.Msg(),
+		srv,
+		test.Case{Qname: "server1.prod.region1.skydns.test.", Qtype: dns.TypeA}.Msg(),
+		test.Case{Qname: "b.server6.prod.region1.skydns.test.", Qtype: dns.TypeAAAA}.Msg(),
+	})
+}
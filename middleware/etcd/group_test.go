@@ -1,5 +1,3 @@
-// +build etcd
-
 package etcd
 
 import (
@@ -18,7 +16,7 @@ func TestGroupLookup(t *testing.T) {
 
 	for _, serv := range servicesGroup {
 		set(t, etc, serv.Key, 0, serv)
-		defer delete(t, etc, serv.Key)
+		defer del(t, etc, serv.Key)
 	}
 	for _, tc := range dnsTestCasesGroup {
 		m := tc.Msg()
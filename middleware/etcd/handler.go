@@ -5,7 +5,9 @@ import (
 
 	"github.com/miekg/coredns/middleware"
 	"github.com/miekg/coredns/middleware/etcd/msg"
+	"github.com/miekg/coredns/middleware/pkg/cname"
 	"github.com/miekg/coredns/middleware/pkg/dnsutil"
+	"github.com/miekg/coredns/middleware/pkg/edns"
 	"github.com/miekg/coredns/request"
 
 	"github.com/miekg/dns"
@@ -14,7 +16,7 @@ import (
 
 // ServeDNS implements the middleware.Handler interface.
 func (e *Etcd) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
-	opt := Options{}
+	opt := Options{MaxCNAMEDepth: cname.MaxDepth(ctx)}
 	state := request.Request{W: w, Req: r}
 	if state.QClass() != dns.ClassINET {
 		return dns.RcodeServerFailure, fmt.Errorf("can only deal with ClassINET")
@@ -51,6 +53,11 @@ func (e *Etcd) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (
 		return e.Next.ServeDNS(ctx, w, r)
 	}
 
+	if err := ctx.Err(); err != nil {
+		// The client has given up, don't bother etcd.
+		return dns.RcodeServerFailure, err
+	}
+
 	var (
 		records, extra []dns.RR
 		debug          []msg.Service
@@ -112,7 +119,6 @@ func (e *Etcd) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (
 
 	m = dnsutil.Dedup(m)
 	state.SizeAndDo(m)
-	m, _ = state.Scrub(m)
 	w.WriteMsg(m)
 	return dns.RcodeSuccess, nil
 }
@@ -131,6 +137,7 @@ func (e *Etcd) Err(zone string, rcode int, state request.Request, debug []msg.Se
 		}
 	}
 	state.SizeAndDo(m)
+	edns.SetExtendedError(m, err)
 	state.W.WriteMsg(m)
 	// Return success as the rcode to signal we have written to the client.
 	return dns.RcodeSuccess, nil
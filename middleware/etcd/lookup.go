@@ -8,7 +8,7 @@ import (
 
 	"github.com/miekg/coredns/middleware"
 	"github.com/miekg/coredns/middleware/etcd/msg"
-	"github.com/miekg/coredns/middleware/pkg/dnsutil"
+	"github.com/miekg/coredns/middleware/pkg/cname"
 	"github.com/miekg/coredns/request"
 
 	"github.com/miekg/dns"
@@ -16,7 +16,8 @@ import (
 
 // Options are extra options that can be specified for a lookup.
 type Options struct {
-	Debug string // This is a debug query. A query prefixed with debug.o-o
+	Debug         string // This is a debug query. A query prefixed with debug.o-o
+	MaxCNAMEDepth int    // The number of CNAMEs that may be followed, 0 is the default.
 }
 
 func (e Etcd) records(state request.Request, exact bool, opt Options) (services, debug []msg.Service, err error) {
@@ -43,23 +44,20 @@ func (e Etcd) A(zone string, state request.Request, previousRecords []dns.RR, op
 		switch {
 		case ip == nil:
 			// TODO(miek): lowercasing? Should lowercase in everything see #85
-			if middleware.Name(state.Name()).Matches(dns.Fqdn(serv.Host)) {
-				// x CNAME x is a direct loop, don't add those
-				continue
-			}
-
 			newRecord := serv.NewCNAME(state.QName(), serv.Host)
-			if len(previousRecords) > 7 {
-				// don't add it, and just continue
-				continue
+			if err := cname.Follow(previousRecords, newRecord, opt.MaxCNAMEDepth); err != nil {
+				return nil, debug, err
 			}
-			if dnsutil.DuplicateCNAME(newRecord, previousRecords) {
+			if middleware.Name(state.Name()).Matches(dns.Fqdn(serv.Host)) {
+				// The names below this one are already found by this lookup.
 				continue
 			}
 
 			state1 := state.NewWithQuestion(serv.Host, state.QType())
 			nextRecords, nextDebug, err := e.A(zone, state1, append(previousRecords, newRecord), opt)
-
+			if cname.IsError(err) {
+				return nil, debug, err
+			}
 			if err == nil {
 				// Not only have we found something we should add the CNAME and the IP addresses.
 				if len(nextRecords) > 0 {
@@ -106,23 +104,20 @@ func (e Etcd) AAAA(zone string, state request.Request, previousRecords []dns.RR,
 		switch {
 		case ip == nil:
 			// Try to resolve as CNAME if it's not an IP, but only if we don't create loops.
-			if middleware.Name(state.Name()).Matches(dns.Fqdn(serv.Host)) {
-				// x CNAME x is a direct loop, don't add those
-				continue
-			}
-
 			newRecord := serv.NewCNAME(state.QName(), serv.Host)
-			if len(previousRecords) > 7 {
-				// don't add it, and just continue
-				continue
+			if err := cname.Follow(previousRecords, newRecord, opt.MaxCNAMEDepth); err != nil {
+				return nil, debug, err
 			}
-			if dnsutil.DuplicateCNAME(newRecord, previousRecords) {
+			if middleware.Name(state.Name()).Matches(dns.Fqdn(serv.Host)) {
+				// The names below this one are already found by this lookup.
 				continue
 			}
 
 			state1 := state.NewWithQuestion(serv.Host, state.QType())
 			nextRecords, nextDebug, err := e.AAAA(zone, state1, append(previousRecords, newRecord), opt)
-
+			if cname.IsError(err) {
+				return nil, debug, err
+			}
 			if err == nil {
 				// Not only have we found something we should add the CNAME and the IP addresses.
 				if len(nextRecords) > 0 {
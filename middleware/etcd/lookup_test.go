@@ -1,5 +1,3 @@
-// +build etcd
-
 package etcd
 
 import (
@@ -149,7 +147,7 @@ var dnsTestCases = []test.Case{
 	},
 	// CNAME loop detection
 	{
-		Qname: "a.cname.skydns.test.", Qtype: dns.TypeA,
+		Qname: "a.cname.skydns.test.", Qtype: dns.TypeA, Rcode: dns.RcodeServerFailure,
 		Ns: []dns.RR{test.SOA("skydns.test. 300 SOA ns.dns.skydns.test. hostmaster.skydns.test. 1407441600 28800 7200 604800 60")},
 	},
 	// NODATA Test
@@ -5,6 +5,8 @@ import (
 	"net"
 	"strings"
 
+	"github.com/miekg/coredns/middleware/pkg/dnsutil"
+
 	"github.com/miekg/dns"
 )
 
@@ -97,9 +99,10 @@ func (s *Service) NewCNAME(name string, target string) *dns.CNAME {
 	return &dns.CNAME{Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeCNAME, Class: dns.ClassINET, Ttl: s.TTL}, Target: dns.Fqdn(target)}
 }
 
-// NewTXT returns a new TXT record based on the Service.
+// NewTXT returns a new TXT record based on the Service. Text longer than 255 bytes
+// is split into multiple character-strings.
 func (s *Service) NewTXT(name string) *dns.TXT {
-	return &dns.TXT{Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: s.TTL}, Txt: split255(s.Text)}
+	return &dns.TXT{Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: s.TTL}, Txt: dnsutil.SplitTXT(s.Text)}
 }
 
 // NewPTR returns a new PTR record based on the Service.
@@ -162,27 +165,6 @@ func Group(sx []Service) []Service {
 	return ret
 }
 
-// Split255 splits a string into 255 byte chunks.
-func split255(s string) []string {
-	if len(s) < 255 {
-		return []string{s}
-	}
-	sx := []string{}
-	p, i := 0, 255
-	for {
-		if i <= len(s) {
-			sx = append(sx, s[p:i])
-		} else {
-			sx = append(sx, s[p:])
-			break
-
-		}
-		p, i = p+255, i+255
-	}
-
-	return sx
-}
-
 // targetStrip strips "targetstrip" labels from the left side of the fully qualified name.
 func targetStrip(name string, targetStrip int) string {
 	if targetStrip == 0 {
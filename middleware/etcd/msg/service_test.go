@@ -2,6 +2,9 @@ package msg
 
 import "testing"
 
+// split255 splits s as NewTXT does.
+func split255(s string) []string { return (&Service{Text: s}).NewTXT("a.example.org.").Txt }
+
 func TestSplit255(t *testing.T) {
 	xs := split255("abc")
 	if len(xs) != 1 && xs[0] != "abc" {
@@ -27,6 +30,15 @@ func TestSplit255(t *testing.T) {
 	if len(xs) != 3 || xs[2] != "a" {
 		t.Errorf("failure to split 510 char long string: %d", len(xs))
 	}
+	// 510 bytes are exactly two character-strings, without an empty one after them.
+	xs = split255(s[:510])
+	if len(xs) != 2 {
+		t.Errorf("failure to split 510 char long string: %d", len(xs))
+	}
+	xs = split255("")
+	if len(xs) != 1 || xs[0] != "" {
+		t.Errorf("failure to split the empty string: %d", len(xs))
+	}
 }
 
 func TestGroup(t *testing.T) {
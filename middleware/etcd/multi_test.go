@@ -1,5 +1,3 @@
-// +build etcd
-
 package etcd
 
 import (
@@ -20,7 +18,7 @@ func TestMultiLookup(t *testing.T) {
 
 	for _, serv := range servicesMulti {
 		set(t, etc, serv.Key, 0, serv)
-		defer delete(t, etc, serv.Key)
+		defer del(t, etc, serv.Key)
 	}
 	for _, tc := range dnsTestCasesMulti {
 		m := tc.Msg()
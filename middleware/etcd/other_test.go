@@ -1,5 +1,3 @@
-// +build etcd
-
 // tests mx and txt records
 
 package etcd
@@ -22,7 +20,7 @@ func TestOtherLookup(t *testing.T) {
 
 	for _, serv := range servicesOther {
 		set(t, etc, serv.Key, 0, serv)
-		defer delete(t, etc, serv.Key)
+		defer del(t, etc, serv.Key)
 	}
 	for _, tc := range dnsTestCasesOther {
 		m := tc.Msg()
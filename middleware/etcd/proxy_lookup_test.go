@@ -1,5 +1,3 @@
-// +build etcd
-
 package etcd
 
 import (
@@ -21,7 +19,7 @@ func TestProxyLookupFailDebug(t *testing.T) {
 
 	for _, serv := range servicesProxy {
 		set(t, etc, serv.Key, 0, serv)
-		defer delete(t, etc, serv.Key)
+		defer del(t, etc, serv.Key)
 	}
 
 	for _, tc := range dnsTestCasesProxy {
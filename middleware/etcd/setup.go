@@ -30,14 +30,22 @@ func setup(c *caddy.Controller) error {
 	if err != nil {
 		return middleware.Error("etcd", err)
 	}
+	config := dnsserver.GetConfig(c)
+	// Report an unreachable etcd at startup, the startup directive sets what is done then.
+	config.OnStartupAfter("etcd", nil, e.Ping)
 	if stubzones {
-		c.OnStartup(func() error {
-			e.UpdateStubZones()
+		stop := make(chan struct{})
+		config.OnStartupAfter("etcd", nil, func() error {
+			e.UpdateStubZones(stop)
+			return nil
+		})
+		config.OnShutdown(func() error {
+			close(stop)
 			return nil
 		})
 	}
 
-	dnsserver.GetConfig(c).AddMiddleware(func(next middleware.Handler) middleware.Handler {
+	config.AddMiddleware(func(next middleware.Handler) middleware.Handler {
 		e.Next = next
 		return e
 	})
@@ -70,7 +78,7 @@ func etcdParse(c *caddy.Controller) (*Etcd, bool, error) {
 				etc.Zones = make([]string, len(c.ServerBlockKeys))
 				copy(etc.Zones, c.ServerBlockKeys)
 			}
-			middleware.Zones(etc.Zones).Normalize()
+			etc.Zones = middleware.Zones(etc.Zones).NormalizeExact()
 			if c.NextBlock() {
 				// TODO(miek): 2 switches?
 				switch c.Val() {
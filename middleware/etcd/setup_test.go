@@ -1,5 +1,3 @@
-// +build etcd
-
 package etcd
 
 import (
@@ -23,21 +21,18 @@ func init() {
 	ctxt, _ = context.WithTimeout(context.Background(), etcdTimeout)
 }
 
-//	etc    *Etcd
+// newEtcdMiddleware returns an Etcd that is backed by an in-memory fake of the etcd
+// keys API, see fake_test.go.
 func newEtcdMiddleware() *Etcd {
 	ctxt, _ = context.WithTimeout(context.Background(), etcdTimeout)
 
-	etcdCfg := etcdc.Config{
-		Endpoints: []string{"http://localhost:2379"},
-	}
-	cli, _ := etcdc.New(etcdCfg)
 	return &Etcd{
 		Proxy:      proxy.New([]string{"8.8.8.8:53"}),
 		PathPrefix: "skydns",
 		Ctx:        context.Background(),
 		Inflight:   &singleflight.Group{},
 		Zones:      []string{"skydns.test.", "skydns_extra.test.", "in-addr.arpa."},
-		Client:     etcdc.NewKeysAPI(cli),
+		Client:     newFakeKeysAPI(),
 	}
 }
 
@@ -50,7 +45,7 @@ func set(t *testing.T, e *Etcd, k string, ttl time.Duration, m *msg.Service) {
 	e.Client.Set(ctxt, path, string(b), &etcdc.SetOptions{TTL: ttl})
 }
 
-func delete(t *testing.T, e *Etcd, k string) {
+func del(t *testing.T, e *Etcd, k string) {
 	path, _ := msg.PathWithWildcard(k, e.PathPrefix)
 	e.Client.Delete(ctxt, path, &etcdc.DeleteOptions{Recursive: false})
 }
@@ -59,7 +54,7 @@ func TestLookup(t *testing.T) {
 	etc := newEtcdMiddleware()
 	for _, serv := range services {
 		set(t, etc, serv.Key, 0, serv)
-		defer delete(t, etc, serv.Key)
+		defer del(t, etc, serv.Key)
 	}
 
 	for _, tc := range dnsTestCases {
@@ -13,12 +13,19 @@ import (
 	"github.com/miekg/dns"
 )
 
-// UpdateStubZones checks etcd for an update on the stubzones.
-func (e *Etcd) UpdateStubZones() {
+// UpdateStubZones checks etcd for an update on the stubzones, every 15 seconds until
+// stop is closed.
+func (e *Etcd) UpdateStubZones(stop <-chan struct{}) {
 	go func() {
+		tick := time.NewTicker(15 * time.Second)
+		defer tick.Stop()
 		for {
 			e.updateStubZones()
-			time.Sleep(15 * time.Second)
+			select {
+			case <-tick.C:
+			case <-stop:
+				return
+			}
 		}
 	}()
 }
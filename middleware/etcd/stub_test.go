@@ -1,5 +1,3 @@
-// +build etcd
-
 package etcd
 
 import (
@@ -45,7 +43,7 @@ func TestStubLookup(t *testing.T) {
 
 	for _, serv := range servicesStub {
 		set(t, etc, serv.Key, 0, serv)
-		defer delete(t, etc, serv.Key)
+		defer del(t, etc, serv.Key)
 	}
 
 	etc.updateStubZones()
@@ -0,0 +1,49 @@
+// Package fallback implements the fallback directive that controls if queries that
+// match no zone are handled by the root zone, and how they are answered when not.
+package fallback
+
+import (
+	"github.com/miekg/coredns/core/dnsserver"
+	"github.com/miekg/coredns/middleware"
+
+	"github.com/mholt/caddy"
+)
+
+func init() {
+	caddy.RegisterPlugin("fallback", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setupFallback,
+	})
+}
+
+func setupFallback(c *caddy.Controller) error {
+	config := dnsserver.GetConfig(c)
+	for c.Next() {
+		args := c.RemainingArgs()
+		if len(args) != 1 && len(args) != 2 {
+			return middleware.Error("fallback", c.ArgErr())
+		}
+		switch args[0] {
+		case "on":
+			config.NoRootFallback = false
+		case "off":
+			config.NoRootFallback = true
+		default:
+			return middleware.Error("fallback", c.Errf("expected 'on' or 'off', got '%s'", args[0]))
+		}
+		if len(args) == 1 {
+			continue
+		}
+		switch args[1] {
+		case "refused":
+			config.NoZone = dnsserver.NoZoneRefused
+		case "nxdomain":
+			config.NoZone = dnsserver.NoZoneNXDomain
+		case "drop":
+			config.NoZone = dnsserver.NoZoneDrop
+		default:
+			return middleware.Error("fallback", c.Errf("expected 'refused', 'nxdomain' or 'drop', got '%s'", args[1]))
+		}
+	}
+	return nil
+}
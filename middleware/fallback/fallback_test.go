@@ -0,0 +1,51 @@
+package fallback
+
+import (
+	"testing"
+
+	"github.com/miekg/coredns/core/dnsserver"
+
+	"github.com/mholt/caddy"
+)
+
+func TestSetupFallback(t *testing.T) {
+	tests := []struct {
+		input              string
+		shouldErr          bool
+		expectedNoFallback bool
+		expectedNoZone     int
+	}{
+		{`fallback off`, false, true, dnsserver.NoZoneRefused},
+		{`fallback on`, false, false, dnsserver.NoZoneRefused},
+		{`fallback off nxdomain`, false, true, dnsserver.NoZoneNXDomain},
+		{`fallback off drop`, false, true, dnsserver.NoZoneDrop},
+		{`fallback on refused`, false, false, dnsserver.NoZoneRefused},
+		// fails
+		{`fallback`, true, false, 0},
+		{`fallback blaat`, true, false, 0},
+		{`fallback off on`, true, false, 0},
+		{`fallback off drop nxdomain`, true, false, 0},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		err := setupFallback(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %d: Expected error but found nil", i)
+			continue
+		}
+		if !test.shouldErr && err != nil {
+			t.Errorf("Test %d: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr {
+			continue
+		}
+		if cfg := dnsserver.GetConfig(c); cfg.NoRootFallback != test.expectedNoFallback {
+			t.Errorf("Test %d: Expected NoRootFallback to be %t, got %t", i, test.expectedNoFallback, cfg.NoRootFallback)
+		}
+		if cfg := dnsserver.GetConfig(c); cfg.NoZone != test.expectedNoZone {
+			t.Errorf("Test %d: Expected NoZone to be %d, got %d", i, test.expectedNoZone, cfg.NoZone)
+		}
+	}
+}
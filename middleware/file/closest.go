@@ -1,6 +1,10 @@
 package file
 
-import "github.com/miekg/dns"
+import (
+	"github.com/miekg/coredns/middleware/file/tree"
+
+	"github.com/miekg/dns"
+)
 
 // ClosestEncloser returns the closest encloser for rr.
 func (z *Zone) ClosestEncloser(qname string, qtype uint16) string {
@@ -8,13 +12,17 @@ func (z *Zone) ClosestEncloser(qname string, qtype uint16) string {
 	// just follow up the tree. TODO(miek): fix.
 	offset, end := dns.NextLabel(qname, 0)
 	for !end {
-		elem, _ := z.Tree.Search(qname, qtype)
+		elem, res := z.Tree.Search(qname, qtype)
 		if elem != nil {
 			return elem.Name()
 		}
+		if res == tree.EmptyNonTerminal {
+			// An empty non-terminal exists, so it can be the closest encloser.
+			return qname
+		}
 		qname = qname[offset:]
 
-		offset, end = dns.NextLabel(qname, offset)
+		offset, end = dns.NextLabel(qname, 0)
 	}
 
 	return z.Apex.SOA.Header().Name
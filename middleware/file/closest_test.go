@@ -32,3 +32,26 @@ func TestClosestEncloser(t *testing.T) {
 		}
 	}
 }
+
+func TestClosestEncloserENT(t *testing.T) {
+	z, err := Parse(strings.NewReader(dbMiekENTNL), testzone, "stdin")
+	if err != nil {
+		t.Fatalf("expect no error when reading zone, got %q", err)
+	}
+
+	tests := []struct {
+		in, out string
+	}{
+		{"blaat.b.c.miek.nl.", "b.c.miek.nl."},
+		{"blaat.c.miek.nl.", "c.miek.nl."},
+		{"x.y.a.b.c.miek.nl.", "a.b.c.miek.nl."},
+		{"blaat.d.miek.nl.", "miek.nl."},
+	}
+
+	for _, tc := range tests {
+		ce := z.ClosestEncloser(tc.in, dns.TypeA)
+		if ce != tc.out {
+			t.Errorf("expected ce to be %s for %s, got %s", tc.out, tc.in, ce)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 
 	"github.com/miekg/coredns/middleware"
+	"github.com/miekg/coredns/middleware/pkg/audit"
 	"github.com/miekg/coredns/request"
 
 	"github.com/miekg/dns"
@@ -83,11 +84,12 @@ func (f File) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (i
 	}
 
 	answer, ns, extra, result := z.Lookup(qname, state.QType(), state.Do())
+	audit.Add(ctx, "file", "lookup in zone %s: %s", zone, result)
 
 	m := new(dns.Msg)
 	m.SetReply(r)
 	m.Authoritative, m.RecursionAvailable, m.Compress = true, true, true
-	m.Answer, m.Ns, m.Extra = answer, ns, extra
+	m.Answer, m.Ns, m.Extra = z.healthy(answer), ns, extra
 
 	switch result {
 	case Success:
@@ -101,7 +103,6 @@ func (f File) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (i
 	}
 
 	state.SizeAndDo(m)
-	m, _ = state.Scrub(m)
 	w.WriteMsg(m)
 	return dns.RcodeSuccess, nil
 }
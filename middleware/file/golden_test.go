@@ -0,0 +1,28 @@
+package file
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/miekg/coredns/middleware/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestGolden(t *testing.T) {
+	zone, err := Parse(strings.NewReader(dbMiekNL), testzone, "stdin")
+	if err != nil {
+		t.Fatalf("Expected no error when reading zone, got %q", err)
+	}
+	fm := File{Next: test.ErrorHandler(), Zones: Zones{Z: map[string]*Zone{testzone: zone}, Names: []string{testzone}}}
+
+	mx := test.Case{Qname: "miek.nl.", Qtype: dns.TypeMX}.Msg()
+	mx.SetEdns0(4096, false)
+
+	test.Golden(t, "testdata/miek.nl.golden", fm, []*dns.Msg{
+		test.Case{Qname: "www.miek.nl.", Qtype: dns.TypeA}.Msg(),
+		mx,
+		test.Case{Qname: "a.miek.nl.", Qtype: dns.TypeSRV}.Msg(),
+		test.Case{Qname: "b.miek.nl.", Qtype: dns.TypeA}.Msg(),
+	})
+}
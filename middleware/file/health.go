@@ -0,0 +1,303 @@
+package file
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// HealthCheck is a health check of the addresses in the A and AAAA records of Name.
+// Addresses that fail it are left out of the answers.
+type HealthCheck struct {
+	Name     string
+	Probe    string // tcp or http
+	Port     string
+	Path     string // the path of an http probe
+	Interval time.Duration
+}
+
+// health holds the results of the health checks of a zone.
+type health struct {
+	sync.RWMutex
+	down map[string]bool // name/address pairs that failed their last check
+	stop chan struct{}
+}
+
+func newHealth() *health {
+	return &health{down: make(map[string]bool)}
+}
+
+// StartHealthChecks starts the health checks of z, they run until StopHealthChecks is
+// called.
+func (z *Zone) StartHealthChecks() {
+	if len(z.HealthChecks) == 0 {
+		return
+	}
+	z.health.stop = make(chan struct{})
+	for _, hc := range z.HealthChecks {
+		go z.runHealthCheck(hc, z.health.stop)
+	}
+}
+
+// StopHealthChecks stops the health checks of z.
+func (z *Zone) StopHealthChecks() {
+	if z.health.stop != nil {
+		close(z.health.stop)
+		z.health.stop = nil
+	}
+}
+
+func (z *Zone) runHealthCheck(hc HealthCheck, stop chan struct{}) {
+	ticker := time.NewTicker(hc.Interval)
+	defer ticker.Stop()
+	for {
+		z.healthCheck(hc)
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// healthCheck checks the addresses of hc.Name once.
+func (z *Zone) healthCheck(hc HealthCheck) {
+	for _, addr := range z.addrs(hc.Name) {
+		err := probe(hc, addr)
+		key := hc.Name + "/" + addr
+
+		z.health.Lock()
+		wasDown := z.health.down[key]
+		if err != nil {
+			z.health.down[key] = true
+		} else {
+			delete(z.health.down, key)
+		}
+		z.health.Unlock()
+
+		switch {
+		case err != nil && !wasDown:
+			log.Printf("[WARNING] Health check of %s for %s failed, leaving it out of the answers: %s", addr, hc.Name, err)
+		case err == nil && wasDown:
+			log.Printf("[INFO] Health check of %s for %s succeeded, adding it to the answers again", addr, hc.Name)
+		}
+	}
+}
+
+// addrs returns the addresses in the A and AAAA records of name.
+func (z *Zone) addrs(name string) []string {
+	z.reloadMu.RLock()
+	defer z.reloadMu.RUnlock()
+
+	addrs := []string{}
+	elem, _ := z.Tree.Search(name, dns.TypeA)
+	if elem == nil {
+		return addrs
+	}
+	for _, rr := range elem.Types(dns.TypeA) {
+		addrs = append(addrs, rr.(*dns.A).A.String())
+	}
+	for _, rr := range elem.Types(dns.TypeAAAA) {
+		addrs = append(addrs, rr.(*dns.AAAA).AAAA.String())
+	}
+	return addrs
+}
+
+// probe checks addr with the probe of hc.
+func probe(hc HealthCheck, addr string) error {
+	hostport := net.JoinHostPort(addr, hc.Port)
+	if hc.Probe == "tcp" {
+		conn, err := net.DialTimeout("tcp", hostport, probeTimeout)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	client := &http.Client{Timeout: probeTimeout}
+	resp, err := client.Get("http://" + hostport + hc.Path)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("got HTTP status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// healthy returns rrs without the A and AAAA records that failed their health check, and
+// without the backup records while a primary record of the RRset is up. When all the
+// primary records failed, the backups that are up are returned. When those failed as
+// well, the primaries are all returned; an answer with dead addresses beats no answer.
+func (z *Zone) healthy(rrs []dns.RR) []dns.RR {
+	if len(z.HealthChecks) == 0 && len(z.Backups) == 0 {
+		return rrs
+	}
+	z.health.RLock()
+	defer z.health.RUnlock()
+
+	addr := func(rr dns.RR) string {
+		switch x := rr.(type) {
+		case *dns.A:
+			return x.A.String()
+		case *dns.AAAA:
+			return x.AAAA.String()
+		}
+		return ""
+	}
+	isDown := func(rr dns.RR) bool {
+		a := addr(rr)
+		return a != "" && z.health.down[rr.Header().Name+"/"+a]
+	}
+	isBackup := func(rr dns.RR) bool {
+		a := addr(rr)
+		return a != "" && z.Backups[rr.Header().Name][a]
+	}
+
+	// Count per RRset the primaries and backups that are up, to see which ones to keep.
+	primaries, backups := make(map[string]int), make(map[string]int)
+	for _, rr := range rrs {
+		if isDown(rr) {
+			continue
+		}
+		if isBackup(rr) {
+			backups[rrsetKey(rr)]++
+		} else {
+			primaries[rrsetKey(rr)]++
+		}
+	}
+	healthy := make([]dns.RR, 0, len(rrs))
+	for _, rr := range rrs {
+		key := rrsetKey(rr)
+		var keep bool
+		switch {
+		case primaries[key] > 0:
+			keep = !isBackup(rr) && !isDown(rr)
+		case backups[key] > 0:
+			keep = isBackup(rr) && !isDown(rr)
+		default:
+			keep = !isBackup(rr)
+		}
+		if keep {
+			healthy = append(healthy, rr)
+		}
+	}
+	return healthy
+}
+
+func rrsetKey(rr dns.RR) string {
+	return rr.Header().Name + "/" + dns.TypeToString[rr.Header().Rrtype]
+}
+
+// HealthCheckParse parses the health check statement:
+// 'health_check NAME tcp:PORT|http://:PORT/PATH [INTERVAL]'. Relative names are taken
+// to be in origin.
+func HealthCheckParse(origin string, args []string) (HealthCheck, error) {
+	if len(args) < 2 || len(args) > 3 {
+		return HealthCheck{}, fmt.Errorf("health_check needs a name, a probe and an optional interval")
+	}
+	name, err := zoneName(origin, args[0])
+	if err != nil {
+		return HealthCheck{}, err
+	}
+	hc := HealthCheck{Name: name, Interval: defaultHealthInterval}
+
+	switch {
+	case strings.HasPrefix(args[1], "tcp:"):
+		hc.Probe, hc.Port = "tcp", args[1][len("tcp:"):]
+		if hc.Port == "" {
+			return HealthCheck{}, fmt.Errorf("tcp probe needs a port")
+		}
+	case strings.HasPrefix(args[1], "http://"):
+		u, err := url.Parse(args[1])
+		if err != nil {
+			return HealthCheck{}, err
+		}
+		hc.Probe, hc.Port, hc.Path = "http", "80", u.RequestURI()
+		if _, port, err := net.SplitHostPort(u.Host); err == nil && port != "" {
+			hc.Port = port
+		}
+	default:
+		return HealthCheck{}, fmt.Errorf("unknown probe '%s'", args[1])
+	}
+
+	if len(args) == 3 {
+		d, err := time.ParseDuration(args[2])
+		if err != nil {
+			return HealthCheck{}, err
+		}
+		if d <= 0 {
+			return HealthCheck{}, fmt.Errorf("interval must be larger than zero: %s", d)
+		}
+		hc.Interval = d
+	}
+	return hc, nil
+}
+
+// BackupParse parses the backup statement: 'backup NAME ADDRESS...'. It returns the name
+// and the addresses.
+func BackupParse(origin string, args []string) (string, []string, error) {
+	if len(args) < 2 {
+		return "", nil, fmt.Errorf("backup needs a name and at least one address")
+	}
+	name, err := zoneName(origin, args[0])
+	if err != nil {
+		return "", nil, err
+	}
+	addrs := make([]string, len(args)-1)
+	for i, a := range args[1:] {
+		ip := net.ParseIP(a)
+		if ip == nil {
+			return "", nil, fmt.Errorf("not an IP address: `%s'", a)
+		}
+		addrs[i] = ip.String()
+	}
+	return name, addrs, nil
+}
+
+// checkBackups returns an error when a name with backups is not health checked, its
+// backups would never be returned.
+func (z *Zone) checkBackups() error {
+	for name := range z.Backups {
+		checked := false
+		for _, hc := range z.HealthChecks {
+			if hc.Name == name {
+				checked = true
+				break
+			}
+		}
+		if !checked {
+			return fmt.Errorf("backup for %s needs a health_check of %s", name, name)
+		}
+	}
+	return nil
+}
+
+// zoneName returns the lower cased, fully qualified, name. Relative names are taken to be
+// in origin, names outside of origin are an error.
+func zoneName(origin, name string) (string, error) {
+	name = strings.ToLower(name)
+	if !dns.IsFqdn(name) {
+		name += "."
+		if origin != "." {
+			name += origin
+		}
+	}
+	if !dns.IsSubDomain(origin, name) {
+		return "", fmt.Errorf("%s is not in zone %s", name, origin)
+	}
+	return name, nil
+}
+
+const (
+	defaultHealthInterval = 10 * time.Second
+	probeTimeout          = 2 * time.Second
+)
@@ -0,0 +1,144 @@
+package file
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/coredns/middleware/pkg/dnsrecorder"
+	"github.com/miekg/coredns/middleware/test"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+const dbHealth = `
+$ORIGIN example.org.
+@	3600 IN	SOA	ns1.example.org. hostmaster.example.org. 2017042745 7200 3600 1209600 3600
+	3600 IN	NS	ns1.example.org.
+www	3600 IN	A	127.0.0.1
+www	3600 IN	A	127.0.0.2
+dead	3600 IN	A	127.0.0.2
+web	3600 IN	A	127.0.0.1
+web	3600 IN	A	127.0.0.3
+standby	3600 IN	A	127.0.0.2
+standby	3600 IN	A	127.0.0.1
+`
+
+func TestHealthCheck(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen: %s", err)
+	}
+	defer l.Close()
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+
+	z, err := Parse(strings.NewReader(dbHealth), "example.org.", "stdin")
+	if err != nil {
+		t.Fatalf("Expected no error when reading zone, got %q", err)
+	}
+	// The primary of web is up, the one of standby is down.
+	z.Backups = map[string]map[string]bool{
+		"web.example.org.":     {"127.0.0.3": true},
+		"standby.example.org.": {"127.0.0.1": true},
+	}
+	for _, name := range []string{"www.example.org.", "dead.example.org.", "web.example.org.", "standby.example.org."} {
+		hc := HealthCheck{Name: name, Probe: "tcp", Port: port, Interval: time.Second}
+		z.HealthChecks = append(z.HealthChecks, hc)
+		z.healthCheck(hc)
+	}
+	fm := File{Next: test.ErrorHandler(), Zones: Zones{Z: map[string]*Zone{"example.org.": z}, Names: []string{"example.org."}}}
+
+	tests := []struct {
+		qname    string
+		expected []string
+	}{
+		// Only 127.0.0.1 listens on the port.
+		{"www.example.org.", []string{"127.0.0.1"}},
+		// All addresses are down, they are returned anyway.
+		{"dead.example.org.", []string{"127.0.0.2"}},
+		// The backup is only returned when the primaries are down.
+		{"web.example.org.", []string{"127.0.0.1"}},
+		{"standby.example.org.", []string{"127.0.0.1"}},
+	}
+	for _, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, dns.TypeA)
+		rec := dnsrecorder.New(&test.ResponseWriter{})
+		if _, err := fm.ServeDNS(context.TODO(), rec, m); err != nil {
+			t.Fatalf("Expected no error, got %s", err)
+		}
+		got := []string{}
+		for _, rr := range rec.Msg.Answer {
+			got = append(got, rr.(*dns.A).A.String())
+		}
+		if strings.Join(got, " ") != strings.Join(tc.expected, " ") {
+			t.Errorf("Expected %v for %s, got %v", tc.expected, tc.qname, got)
+		}
+	}
+}
+
+func TestHealthCheckParse(t *testing.T) {
+	tests := []struct {
+		args      string
+		shouldErr bool
+		expected  HealthCheck
+	}{
+		{"www tcp:80", false, HealthCheck{Name: "www.example.org.", Probe: "tcp", Port: "80", Interval: defaultHealthInterval}},
+		{"www.example.org. http://:8080/healthz 5s", false, HealthCheck{Name: "www.example.org.", Probe: "http", Port: "8080", Path: "/healthz", Interval: 5 * time.Second}},
+		{"WWW http://", false, HealthCheck{Name: "www.example.org.", Probe: "http", Port: "80", Path: "/", Interval: defaultHealthInterval}},
+		{"www.example.net. tcp:80", true, HealthCheck{}},
+		{"www tcp:", true, HealthCheck{}},
+		{"www udp:53", true, HealthCheck{}},
+		{"www tcp:80 0s", true, HealthCheck{}},
+		{"www", true, HealthCheck{}},
+	}
+	for i, tc := range tests {
+		hc, err := HealthCheckParse("example.org.", strings.Fields(tc.args))
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if hc != tc.expected {
+			t.Errorf("Test %d: expected %v, got %v", i, tc.expected, hc)
+		}
+	}
+}
+
+func TestBackupParse(t *testing.T) {
+	tests := []struct {
+		args      string
+		shouldErr bool
+		name      string
+		addrs     []string
+	}{
+		{"www 192.0.2.10", false, "www.example.org.", []string{"192.0.2.10"}},
+		{"www.example.org. 192.0.2.10 2001:DB8::10", false, "www.example.org.", []string{"192.0.2.10", "2001:db8::10"}},
+		{"www", true, "", nil},
+		{"www backup.example.net", true, "", nil},
+		{"www.example.net. 192.0.2.10", true, "", nil},
+	}
+	for i, tc := range tests {
+		name, addrs, err := BackupParse("example.org.", strings.Fields(tc.args))
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if name != tc.name || strings.Join(addrs, " ") != strings.Join(tc.addrs, " ") {
+			t.Errorf("Test %d: expected %s %v, got %s %v", i, tc.name, tc.addrs, name, addrs)
+		}
+	}
+}
@@ -22,6 +22,23 @@ const (
 	ServerFailure
 )
 
+// String returns a string representation of r.
+func (r Result) String() string {
+	switch r {
+	case Success:
+		return "success"
+	case NameError:
+		return "name error"
+	case Delegation:
+		return "delegation"
+	case NoData:
+		return "no data"
+	case ServerFailure:
+		return "server failure"
+	}
+	return "unknown"
+}
+
 // Lookup looks up qname and qtype in the zone. When do is true DNSSEC records are included.
 // Three sets of records are returned, one for the answer, one for authority  and one for the additional section.
 func (z *Zone) Lookup(qname string, qtype uint16, do bool) ([]dns.RR, []dns.RR, []dns.RR, Result) {
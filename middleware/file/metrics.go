@@ -0,0 +1,28 @@
+package file
+
+import (
+	"github.com/miekg/coredns/middleware"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	notifyCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: middleware.Namespace,
+		Subsystem: "file",
+		Name:      "notifies_total",
+		Help:      "Counter of notifies sent per zone and remote, by result: success or failed.",
+	}, []string{"zone", "to", "result"})
+
+	notifyRetryCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: middleware.Namespace,
+		Subsystem: "file",
+		Name:      "notify_retries_total",
+		Help:      "Counter of notifies that were sent again because the remote did not reply.",
+	}, []string{"zone", "to"})
+)
+
+func init() {
+	prometheus.MustRegister(notifyCount)
+	prometheus.MustRegister(notifyRetryCount)
+}
@@ -3,8 +3,12 @@ package file
 import (
 	"fmt"
 	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/miekg/coredns/middleware"
+	"github.com/miekg/coredns/middleware/file/tree"
 	"github.com/miekg/coredns/request"
 
 	"github.com/miekg/dns"
@@ -20,43 +24,152 @@ func (z *Zone) isNotify(state request.Request) bool {
 	if len(z.TransferFrom) == 0 {
 		return false
 	}
-	remote := middleware.Addr(state.IP()).Normalize()
+	remote := state.IP()
 	for _, from := range z.TransferFrom {
-		if from == remote {
+		// The notify comes from the address of the primary, but not from its port.
+		host, _, err := net.SplitHostPort(strings.TrimPrefix(from, TLSPrefix))
+		if err == nil && host == remote {
 			return true
 		}
 	}
 	return false
 }
 
-// Notify will send notifies to all configured TransferTo IP addresses.
+// Notify sends notifies for the zone to the addresses in TransferTo and, if NotifyNS is
+// set, to the name servers of the zone. It returns at once, the name servers outside of
+// the zone are looked up and the notifies are sent in the background. When the zone has
+// a lease, they are only sent when this instance holds it.
 func (z *Zone) Notify() {
-	go notify(z.origin, z.TransferTo)
+	to, names := z.notifyTargets()
+	if len(to) == 0 && len(names) == 0 {
+		return
+	}
+	if z.Lease != nil && !z.Lease.Held() {
+		log.Printf("[INFO] Not sending notify for zone %s, another instance holds the lease", z.origin)
+		return
+	}
+	go func() {
+		notify(z.origin, lookupNS(z.origin, to, names))
+	}()
+}
+
+// notifyTargets returns the addresses the notifies for z are sent to, and the names of
+// the name servers outside of the zone, whose addresses still need to be looked up.
+func (z *Zone) notifyTargets() (to, names []string) {
+	to = []string{}
+	seen := make(map[string]bool)
+	add := func(addr string) {
+		if !seen[addr] {
+			seen[addr] = true
+			to = append(to, addr)
+		}
+	}
+	for _, t := range z.TransferTo {
+		if t != "*" {
+			add(t)
+		}
+	}
+	if !z.NotifyNS {
+		return to, nil
+	}
+
+	z.reloadMu.RLock()
+	defer z.reloadMu.RUnlock()
+	primary := ""
+	if z.Apex.SOA != nil {
+		primary = z.Apex.SOA.Ns
+	}
+	for _, rr := range z.Apex.NS {
+		ns := rr.(*dns.NS).Ns
+		// The primary, that is us, doesn't need to be told.
+		if ns == primary {
+			continue
+		}
+		// Not looked up here, that would hold up the reloads of the zone.
+		if !dns.IsSubDomain(z.origin, ns) {
+			names = append(names, ns)
+			continue
+		}
+		for _, ip := range z.glue(ns) {
+			add(net.JoinHostPort(ip, "53"))
+		}
+	}
+	return to, names
 }
 
-// notify sends notifies to the configured remote servers. It will try up to three times
-// before giving up on a specific remote. We will sequentially loop through "to"
-// until they all have replied (or have 3 failed attempts).
-func notify(zone string, to []string) error {
-	m := new(dns.Msg)
-	m.SetNotify(zone)
-	c := new(dns.Client)
+// glue returns the addresses of the name server ns in the zone. The caller must hold
+// z.reloadMu.
+func (z *Zone) glue(ns string) []string {
+	addrs := []string{}
+	elem, res := z.Tree.SearchGlue(ns)
+	if res != tree.Found {
+		log.Printf("[ERROR] No glue for name server %s of zone %s", ns, z.origin)
+		return addrs
+	}
+	for _, rr := range elem.Types(dns.TypeA) {
+		addrs = append(addrs, rr.(*dns.A).A.String())
+	}
+	for _, rr := range elem.Types(dns.TypeAAAA) {
+		addrs = append(addrs, rr.(*dns.AAAA).AAAA.String())
+	}
+	return addrs
+}
 
+// lookupNS adds the addresses of the name servers names of zone, returned by the resolver
+// of the system, to the addresses in to, skipping the ones that are already there.
+func lookupNS(zone string, to, names []string) []string {
+	seen := make(map[string]bool)
 	for _, t := range to {
-		if t == "*" {
+		seen[t] = true
+	}
+	for _, ns := range names {
+		addrs, err := net.LookupHost(ns)
+		if err != nil {
+			log.Printf("[ERROR] Failed to find address of name server %s of zone %s: %s", ns, zone, err)
 			continue
 		}
-		if err := notifyAddr(c, m, t); err != nil {
-			log.Printf("[ERROR] " + err.Error())
-		} else {
-			log.Printf("[INFO] Sent notify for zone %s to %s", zone, t)
+		for _, ip := range addrs {
+			if addr := net.JoinHostPort(ip, "53"); !seen[addr] {
+				seen[addr] = true
+				to = append(to, addr)
+			}
 		}
 	}
-	return nil
+	return to
+}
+
+// notify sends notifies for zone to all addresses in to, at the same time. A remote that
+// does not reply is retried with an exponential backoff, up to notifyAttempts times. It
+// returns once all remotes have replied or have been given up on.
+func notify(zone string, to []string) {
+	var wg sync.WaitGroup
+	for _, t := range to {
+		wg.Add(1)
+		go func(t string) {
+			defer wg.Done()
+			m := new(dns.Msg)
+			m.SetNotify(zone)
+			if err := notifyAddr(new(dns.Client), m, t); err != nil {
+				notifyCount.WithLabelValues(zone, t, "failed").Inc()
+				log.Printf("[ERROR] " + err.Error())
+				return
+			}
+			notifyCount.WithLabelValues(zone, t, "success").Inc()
+			log.Printf("[INFO] Sent notify for zone %s to %s", zone, t)
+		}(t)
+	}
+	wg.Wait()
 }
 
 func notifyAddr(c *dns.Client, m *dns.Msg, s string) error {
-	for i := 0; i < 3; i++ {
+	zone := m.Question[0].Name
+	backoff := notifyBackoff
+	for i := 0; i < notifyAttempts; i++ {
+		if i > 0 {
+			notifyRetryCount.WithLabelValues(zone, s).Inc()
+			time.Sleep(backoff)
+			backoff *= 2
+		}
 		ret, _, err := c.Exchange(m, s)
 		if err != nil {
 			continue
@@ -65,5 +178,12 @@ func notifyAddr(c *dns.Client, m *dns.Msg, s string) error {
 			return nil
 		}
 	}
-	return fmt.Errorf("Failed to send notify for zone '%s' to '%s'", m.Question[0].Name, s)
+	return fmt.Errorf("Failed to send notify for zone '%s' to '%s'", zone, s)
 }
+
+var (
+	// notifyAttempts is the number of times a notify is sent to a remote that does not reply.
+	notifyAttempts = 5
+	// notifyBackoff is the time to wait before the first retry, it doubles for every retry.
+	notifyBackoff = time.Second
+)
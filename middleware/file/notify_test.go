@@ -0,0 +1,101 @@
+package file
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/coredns/middleware/test"
+
+	"github.com/miekg/dns"
+)
+
+const dbNotify = `
+$ORIGIN example.org.
+@	3600 IN	SOA	ns1.example.org. hostmaster.example.org. 2017042745 7200 3600 1209600 3600
+	3600 IN	NS	ns1.example.org.
+	3600 IN	NS	ns2.example.org.
+ns1	3600 IN	A	192.0.2.1
+ns2	3600 IN	A	192.0.2.2
+ns2	3600 IN	AAAA	2001:db8::2
+`
+
+func TestNotifyTargets(t *testing.T) {
+	z, err := Parse(strings.NewReader(dbNotify), "example.org.", "stdin")
+	if err != nil {
+		t.Fatalf("Expected no error when reading zone, got %q", err)
+	}
+	z.TransferTo = []string{"*", "10.0.0.1:53", "192.0.2.2:53"}
+
+	expected := []string{"10.0.0.1:53", "192.0.2.2:53"}
+	if to, _ := z.notifyTargets(); strings.Join(to, " ") != strings.Join(expected, " ") {
+		t.Errorf("Expected targets %v, got %v", expected, to)
+	}
+
+	// ns1 is the primary, ns2 is already in TransferTo with its IPv4 address.
+	z.NotifyNS = true
+	expected = append(expected, "[2001:db8::2]:53")
+	if to, _ := z.notifyTargets(); strings.Join(to, " ") != strings.Join(expected, " ") {
+		t.Errorf("Expected targets %v, got %v", expected, to)
+	}
+
+	// A name server outside of the zone is returned to be looked up later.
+	z.Insert(test.NS("example.org.	3600	IN	NS	ns.example.net."))
+	if _, names := z.notifyTargets(); len(names) != 1 || names[0] != "ns.example.net." {
+		t.Errorf("Expected name server ns.example.net. to be looked up, got %v", names)
+	}
+}
+
+func TestLookupNS(t *testing.T) {
+	// Only the addresses that are not there yet are added.
+	to := lookupNS("example.org.", []string{"127.0.0.1:53"}, []string{"localhost."})
+	if len(to) == 0 || to[0] != "127.0.0.1:53" {
+		t.Fatalf("Expected the targets to start with 127.0.0.1:53, got %v", to)
+	}
+	for _, t1 := range to[1:] {
+		if t1 == "127.0.0.1:53" {
+			t.Errorf("Expected 127.0.0.1:53 once, got %v", to)
+		}
+	}
+}
+
+func TestNotifyRetry(t *testing.T) {
+	defer func(b time.Duration) { notifyBackoff = b }(notifyBackoff)
+	notifyBackoff = time.Millisecond
+
+	var mu sync.Mutex
+	received := 0
+	dns.HandleFunc("example.org.", func(w dns.ResponseWriter, r *dns.Msg) {
+		mu.Lock()
+		received++
+		n := received
+		mu.Unlock()
+
+		m := new(dns.Msg)
+		m.SetReply(r)
+		// Fail the first two notifies.
+		if n < 3 {
+			m.Rcode = dns.RcodeServerFailure
+		}
+		w.WriteMsg(m)
+	})
+	defer dns.HandleRemove("example.org.")
+
+	s, addr, err := test.UDPServer(t, "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not start UDP server: %s", err)
+	}
+	defer s.Shutdown()
+
+	m := new(dns.Msg)
+	m.SetNotify("example.org.")
+	if err := notifyAddr(new(dns.Client), m, addr); err != nil {
+		t.Fatalf("Expected notify to succeed, got %s", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if received != 3 {
+		t.Errorf("Expected 3 notifies, got %d", received)
+	}
+}
@@ -1,7 +1,10 @@
 package file
 
 import (
+	"crypto/tls"
 	"log"
+	"net"
+	"strings"
 	"time"
 
 	"github.com/miekg/dns"
@@ -24,7 +27,18 @@ func (z *Zone) TransferIn() error {
 Transfer:
 	for _, tr = range z.TransferFrom {
 		t := new(dns.Transfer)
-		c, err := t.In(m, tr)
+		addr := tr
+		if strings.HasPrefix(tr, TLSPrefix) {
+			conn, err := z.dialTLS(tr)
+			if err != nil {
+				log.Printf("[ERROR] Failed to setup transfer `%s' with `%s': %v", z.origin, tr, err)
+				Err = err
+				continue Transfer
+			}
+			t.Conn = conn
+			addr = tr[len(TLSPrefix):]
+		}
+		c, err := t.In(m, addr)
 		if err != nil {
 			log.Printf("[ERROR] Failed to setup transfer `%s' with `%s': %v", z.origin, tr, err)
 			Err = err
@@ -56,6 +70,7 @@ Transfer:
 	z.Apex = z1.Apex
 	*z.Expired = false
 	log.Printf("[INFO] Transferred: %s from %s", z.origin, tr)
+	z.Notify()
 	return nil
 }
 
@@ -73,7 +88,7 @@ func (z *Zone) shouldTransfer() (bool, error) {
 Transfer:
 	for _, tr := range z.TransferFrom {
 		Err = nil
-		ret, _, err := c.Exchange(m, tr)
+		ret, err := z.exchange(c, m, tr)
 		if err != nil || ret.Rcode != dns.RcodeSuccess {
 			Err = err
 			continue
@@ -91,6 +106,39 @@ Transfer:
 	return less(z.Apex.SOA.Serial, uint32(serial)), Err
 }
 
+// exchange sends m to the primary tr with c, or over TLS if tr starts with tls://.
+func (z *Zone) exchange(c *dns.Client, m *dns.Msg, tr string) (*dns.Msg, error) {
+	if !strings.HasPrefix(tr, TLSPrefix) {
+		ret, _, err := c.Exchange(m, tr)
+		return ret, err
+	}
+	co, err := z.dialTLS(tr)
+	if err != nil {
+		return nil, err
+	}
+	defer co.Close()
+	co.SetDeadline(time.Now().Add(tlsTimeout))
+	if err := co.WriteMsg(m); err != nil {
+		return nil, err
+	}
+	return co.ReadMsg()
+}
+
+// dialTLS sets up a TLS connection to the primary tr, tls://ADDRESS. The certificate of
+// the primary is verified against its address, unless TransferTLS sets a ServerName.
+func (z *Zone) dialTLS(tr string) (*dns.Conn, error) {
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: tlsTimeout}, "tcp", tr[len(TLSPrefix):], z.TransferTLS)
+	if err != nil {
+		return nil, err
+	}
+	return &dns.Conn{Conn: conn}, nil
+}
+
+// TLSPrefix marks a primary that is transferred from over TLS.
+const TLSPrefix = "tls://"
+
+const tlsTimeout = 5 * time.Second
+
 // less return true of a is smaller than b when taking RFC 1982 serial arithmetic into account.
 func less(a, b uint32) bool {
 	if a < b {
@@ -1,7 +1,11 @@
 package file
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/miekg/coredns/middleware/test"
@@ -130,6 +134,61 @@ func TestTransferIn(t *testing.T) {
 	}
 }
 
+func TestTransferInTLS(t *testing.T) {
+	soa := soa{250}
+
+	dns.HandleFunc(testZone, soa.Handler)
+	defer dns.HandleRemove(testZone)
+
+	// Borrow the certificate of httptest, it is valid for 127.0.0.1.
+	hs := httptest.NewTLSServer(http.NotFoundHandler())
+	defer hs.Close()
+	cert := hs.TLS.Certificates[0]
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatalf("unable to parse test certificate: %v", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(leaf)
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	started := make(chan struct{})
+	s := &dns.Server{Listener: l, NotifyStartedFunc: func() { close(started) }}
+	go s.ActivateAndServe()
+	<-started
+	defer s.Shutdown()
+
+	z := new(Zone)
+	z.Expired = new(bool)
+	z.origin = testZone
+	z.TransferFrom = []string{TLSPrefix + l.Addr().String()}
+
+	// Without our roots the certificate can't be verified.
+	if err := z.TransferIn(); err == nil {
+		t.Fatal("expected TransferIn to fail with an unknown certificate")
+	}
+
+	z.TransferTLS = &tls.Config{RootCAs: roots}
+	if err := z.TransferIn(); err != nil {
+		t.Fatalf("unable to run TransferIn: %v", err)
+	}
+	if z.Apex.SOA.String() != fmt.Sprintf("%s	3600	IN	SOA	bla. bla. 250 0 0 0 0", testZone) {
+		t.Fatalf("unknown SOA transferred")
+	}
+
+	soa.serial = 251
+	should, err := z.shouldTransfer()
+	if err != nil {
+		t.Fatalf("unable to run shouldTransfer: %v", err)
+	}
+	if !should {
+		t.Fatalf("shouldTransfer should return true for serial: %d", soa.serial)
+	}
+}
+
 func TestIsNotify(t *testing.T) {
 	z := new(Zone)
 	z.Expired = new(bool)
@@ -146,6 +205,10 @@ func TestIsNotify(t *testing.T) {
 	if z.isNotify(state) {
 		t.Fatal("should have been invalid notify")
 	}
+	z.TransferFrom = []string{TLSPrefix + "10.240.0.1:853"}
+	if !z.isNotify(state) {
+		t.Fatal("should have been valid notify from a TLS primary")
+	}
 }
 
 func newRequest(zone string, qtype uint16) request.Request {
@@ -4,9 +4,12 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/miekg/coredns/core/dnsserver"
 	"github.com/miekg/coredns/middleware"
+	"github.com/miekg/coredns/middleware/pkg/lease"
 
 	"github.com/mholt/caddy"
 )
@@ -24,20 +27,32 @@ func setup(c *caddy.Controller) error {
 		return middleware.Error("file", err)
 	}
 
+	config := dnsserver.GetConfig(c)
+
 	// Add startup functions to notify the master(s).
 	for _, n := range zones.Names {
-		c.OnStartup(func() error {
+		n := n
+		config.OnStartupAfter("file", nil, func() error {
 			zones.Z[n].StartupOnce.Do(func() {
-				if len(zones.Z[n].TransferTo) > 0 {
-					zones.Z[n].Notify()
+				if zones.Z[n].Lease != nil {
+					zones.Z[n].Lease.Start()
 				}
+				zones.Z[n].Notify()
 				zones.Z[n].Reload(nil)
+				zones.Z[n].StartHealthChecks()
 			})
 			return nil
 		})
+		c.OnShutdown(func() error {
+			zones.Z[n].StopHealthChecks()
+			if zones.Z[n].Lease != nil {
+				zones.Z[n].Lease.Stop()
+			}
+			return nil
+		})
 	}
 
-	dnsserver.GetConfig(c).AddMiddleware(func(next middleware.Handler) middleware.Handler {
+	config.AddMiddleware(func(next middleware.Handler) middleware.Handler {
 		return File{Next: next, Zones: zones}
 	})
 
@@ -83,6 +98,59 @@ func fileParse(c *caddy.Controller) (Zones, error) {
 
 			noReload := false
 			for c.NextBlock() {
+				if c.Val() == "notify" {
+					if err := NotifyParse(c); err != nil {
+						return Zones{}, err
+					}
+					for _, origin := range origins {
+						z[origin].NotifyNS = true
+					}
+					continue
+				}
+				if c.Val() == "coordinate" {
+					endpoints := c.RemainingArgs()
+					if len(endpoints) == 0 {
+						return Zones{}, c.ArgErr()
+					}
+					client, err := lease.Client(endpoints)
+					if err != nil {
+						return Zones{}, err
+					}
+					for _, origin := range origins {
+						z[origin].Lease = lease.New(client, leasePrefix+origin, leaseTTL)
+					}
+					continue
+				}
+				if c.Val() == "health_check" {
+					args := c.RemainingArgs()
+					for _, origin := range origins {
+						hc, err := HealthCheckParse(origin, args)
+						if err != nil {
+							return Zones{}, err
+						}
+						z[origin].HealthChecks = append(z[origin].HealthChecks, hc)
+					}
+					continue
+				}
+				if c.Val() == "backup" {
+					args := c.RemainingArgs()
+					for _, origin := range origins {
+						name, addrs, err := BackupParse(origin, args)
+						if err != nil {
+							return Zones{}, err
+						}
+						if z[origin].Backups == nil {
+							z[origin].Backups = make(map[string]map[string]bool)
+						}
+						if z[origin].Backups[name] == nil {
+							z[origin].Backups[name] = make(map[string]bool)
+						}
+						for _, a := range addrs {
+							z[origin].Backups[name][a] = true
+						}
+					}
+					continue
+				}
 				t, _, e := TransferParse(c)
 				if e != nil {
 					return Zones{}, e
@@ -99,11 +167,36 @@ func fileParse(c *caddy.Controller) (Zones, error) {
 					z[origin].NoReload = noReload
 				}
 			}
+			for _, origin := range origins {
+				if err := z[origin].checkBackups(); err != nil {
+					return Zones{}, err
+				}
+			}
 		}
 	}
 	return Zones{Z: z, Names: names}, nil
 }
 
+const (
+	// leasePrefix is the prefix of the keys in etcd that instances that coordinate take a
+	// lease on, the name of the zone follows it.
+	leasePrefix = "/coredns/lease/"
+	leaseTTL    = 15 * time.Second
+)
+
+// NotifyParse parses the notify statement: 'notify ns'. Exported so secondary can use
+// this as well.
+func NotifyParse(c *caddy.Controller) error {
+	args := c.RemainingArgs()
+	if len(args) != 1 {
+		return c.ArgErr()
+	}
+	if args[0] != "ns" {
+		return c.Errf("unknown notify target '%s'", args[0])
+	}
+	return nil
+}
+
 // TransferParse parses transfer statements: 'transfer to [address...]'.
 // Exported so secondary can use this as well.
 func TransferParse(c *caddy.Controller) (tos, froms []string, err error) {
@@ -128,14 +221,26 @@ func TransferParse(c *caddy.Controller) (tos, froms []string, err error) {
 		if value == "from" {
 			froms = c.RemainingArgs()
 			for i := range froms {
-				if froms[i] != "*" {
-					if x := net.ParseIP(froms[i]); x == nil {
+				if froms[i] == "*" {
+					return nil, nil, fmt.Errorf("can't use '*' in transfer from")
+				}
+				if strings.HasPrefix(froms[i], TLSPrefix) {
+					// tls://address[:port], the port defaults to 853
+					addr := froms[i][len(TLSPrefix):]
+					host, port, err := net.SplitHostPort(addr)
+					if err != nil {
+						host, port = addr, "853"
+					}
+					if x := net.ParseIP(host); x == nil {
 						return nil, nil, fmt.Errorf("must specify an IP addres: `%s'", froms[i])
 					}
-					froms[i] = middleware.Addr(froms[i]).Normalize()
-				} else {
-					return nil, nil, fmt.Errorf("can't use '*' in transfer from")
+					froms[i] = TLSPrefix + net.JoinHostPort(host, port)
+					continue
+				}
+				if x := net.ParseIP(froms[i]); x == nil {
+					return nil, nil, fmt.Errorf("must specify an IP addres: `%s'", froms[i])
 				}
+				froms[i] = middleware.Addr(froms[i]).Normalize()
 			}
 		}
 	}
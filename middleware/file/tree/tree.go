@@ -158,11 +158,21 @@ func (t *Tree) Search(qname string, qtype uint16) (*Elem, Result) {
 	}
 	n, res := t.Root.search(qname, qtype, false)
 	if n == nil {
+		if res == NameError && t.emptyNonTerminal(qname) {
+			res = EmptyNonTerminal
+		}
 		return nil, res
 	}
 	return n.Elem, res
 }
 
+// emptyNonTerminal returns true if names below qname exist in the tree. In canonical
+// order those directly follow qname, so only the next name needs to be checked.
+func (t *Tree) emptyNonTerminal(qname string) bool {
+	next := t.Next(qname)
+	return next != nil && dns.IsSubDomain(qname, next.Name())
+}
+
 // SearchGlue returns the first match of qname/(A/AAAA) in the Tree.
 func (t *Tree) SearchGlue(qname string) (*Elem, Result) {
 	// TODO(miek): shouldn't need this, because when we *find* the delegation, we
@@ -182,28 +192,21 @@ func (t *Tree) SearchGlue(qname string) (*Elem, Result) {
 // spot when hitting NS records, but descends in search of glue. The qtype for this
 // kind of search can only be AAAA or A.
 func (n *Node) search(qname string, qtype uint16, glue bool) (*Node, Result) {
-	old := n
 	for n != nil {
 
 		switch c := Less(n.Elem, qname); {
 		case c == 0:
 			return n, Found
 		case c < 0:
-			old = n
 			n = n.Left
 		default:
 			if !glue && n.Elem.Types(dns.TypeNS) != nil {
 				return n, Delegation
 
 			}
-			old = n
 			n = n.Right
 		}
 	}
-	if dns.CountLabel(qname) < dns.CountLabel(old.Elem.Name()) {
-		return n, EmptyNonTerminal
-	}
-
 	return n, NameError
 }
 
@@ -1,6 +1,7 @@
 package file
 
 import (
+	"crypto/tls"
 	"fmt"
 	"log"
 	"os"
@@ -9,9 +10,10 @@ import (
 	"sync"
 
 	"github.com/miekg/coredns/middleware/file/tree"
+	"github.com/miekg/coredns/middleware/pkg/lease"
+	"github.com/miekg/coredns/middleware/pkg/watch"
 	"github.com/miekg/coredns/request"
 
-	"github.com/fsnotify/fsnotify"
 	"github.com/miekg/dns"
 )
 
@@ -26,6 +28,12 @@ type Zone struct {
 	StartupOnce  sync.Once
 	TransferFrom []string
 	Expired      *bool
+	NotifyNS     bool        // also send notifies to the name servers of the zone
+	TransferTLS  *tls.Config // for the primaries in TransferFrom that use TLS
+	HealthChecks []HealthCheck
+	Backups      map[string]map[string]bool // per name, the addresses only returned when the others are down
+	health       *health
+	Lease        *lease.Lease // when set, only the instance that holds it sends notifies
 
 	NoReload bool
 	reloadMu sync.RWMutex
@@ -42,7 +50,7 @@ type Apex struct {
 
 // NewZone returns a new zone.
 func NewZone(name, file string) *Zone {
-	z := &Zone{origin: dns.Fqdn(name), file: path.Clean(file), Tree: &tree.Tree{}, Expired: new(bool)}
+	z := &Zone{origin: dns.Fqdn(name), file: path.Clean(file), Tree: &tree.Tree{}, Expired: new(bool), health: newHealth()}
 	*z.Expired = false
 	return z
 }
@@ -52,6 +60,11 @@ func (z *Zone) Copy() *Zone {
 	z1 := NewZone(z.origin, z.file)
 	z1.TransferTo = z.TransferTo
 	z1.TransferFrom = z.TransferFrom
+	z1.NotifyNS = z.NotifyNS
+	z1.TransferTLS = z.TransferTLS
+	z1.HealthChecks = z.HealthChecks
+	z1.Backups = z.Backups
+	z1.Lease = z.Lease
 	z1.Expired = z.Expired
 	z1.Apex = z.Apex
 	return z1
@@ -138,49 +151,44 @@ func (z *Zone) All() []dns.RR {
 }
 
 // Reload reloads a zone when it is changed on disk. If z.NoRoload is true, no reloading will be done.
+// Closing shutdown stops watching the file.
 func (z *Zone) Reload(shutdown chan bool) error {
 	if z.NoReload {
 		return nil
 	}
-	watcher, err := fsnotify.NewWatcher()
+	stop, err := watch.File(z.file, z.reload)
 	if err != nil {
 		return err
 	}
-	err = watcher.Add(path.Dir(z.file))
+	if shutdown != nil {
+		go func() {
+			<-shutdown
+			stop()
+		}()
+	}
+	return nil
+}
+
+// reload reads the zone from disk again.
+func (z *Zone) reload() error {
+	reader, err := os.Open(z.file)
 	if err != nil {
+		log.Printf("[ERROR] Failed to open `%s' for `%s': %v", z.file, z.origin, err)
 		return err
 	}
+	defer reader.Close()
 
-	go func() {
-		// TODO(miek): needs to be killed on reload.
-		for {
-			select {
-			case event := <-watcher.Events:
-				if path.Clean(event.Name) == z.file {
-					reader, err := os.Open(z.file)
-					if err != nil {
-						log.Printf("[ERROR] Failed to open `%s' for `%s': %v", z.file, z.origin, err)
-						continue
-					}
-					z.reloadMu.Lock()
-					zone, err := Parse(reader, z.origin, z.file)
-					if err != nil {
-						log.Printf("[ERROR] Failed to parse `%s': %v", z.origin, err)
-						z.reloadMu.Unlock()
-						continue
-					}
-					// copy elements we need
-					z.Apex = zone.Apex
-					z.Tree = zone.Tree
-					z.reloadMu.Unlock()
-					log.Printf("[INFO] Successfully reloaded zone `%s'", z.origin)
-					z.Notify()
-				}
-			case <-shutdown:
-				watcher.Close()
-				return
-			}
-		}
-	}()
+	zone, err := Parse(reader, z.origin, z.file)
+	if err != nil {
+		log.Printf("[ERROR] Failed to parse `%s': %v", z.origin, err)
+		return err
+	}
+	// copy elements we need
+	z.reloadMu.Lock()
+	z.Apex = zone.Apex
+	z.Tree = zone.Tree
+	z.reloadMu.Unlock()
+	log.Printf("[INFO] Successfully reloaded zone `%s'", z.origin)
+	z.Notify()
 	return nil
 }
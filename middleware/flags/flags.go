@@ -0,0 +1,57 @@
+// Package flags implements the flags directive that controls the AA and RA bits in responses.
+package flags
+
+import (
+	"github.com/miekg/coredns/core/dnsserver"
+	"github.com/miekg/coredns/middleware"
+
+	"github.com/mholt/caddy"
+)
+
+func init() {
+	caddy.RegisterPlugin("flags", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setupFlags,
+	})
+}
+
+func setupFlags(c *caddy.Controller) error {
+	config := dnsserver.GetConfig(c)
+	for c.Next() {
+		if len(c.RemainingArgs()) != 0 {
+			return middleware.Error("flags", c.ArgErr())
+		}
+		for c.NextBlock() {
+			what := c.Val()
+			args := c.RemainingArgs()
+			if len(args) != 1 {
+				return middleware.Error("flags", c.ArgErr())
+			}
+			switch what {
+			case "aa":
+				switch args[0] {
+				case "on":
+					config.AA = dnsserver.FlagSet
+				case "off":
+					config.AA = dnsserver.FlagClear
+				default:
+					return middleware.Error("flags", c.Errf("expected 'on' or 'off', got '%s'", args[0]))
+				}
+			case "ra":
+				switch args[0] {
+				case "on":
+					config.RA = dnsserver.FlagSet
+				case "off":
+					config.RA = dnsserver.FlagClear
+				case "auto":
+					config.RA = dnsserver.FlagAuto
+				default:
+					return middleware.Error("flags", c.Errf("expected 'on', 'off' or 'auto', got '%s'", args[0]))
+				}
+			default:
+				return middleware.Error("flags", c.Errf("unknown property '%s'", what))
+			}
+		}
+	}
+	return nil
+}
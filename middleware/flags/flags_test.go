@@ -0,0 +1,64 @@
+package flags
+
+import (
+	"testing"
+
+	"github.com/miekg/coredns/core/dnsserver"
+
+	"github.com/mholt/caddy"
+)
+
+func TestSetupFlags(t *testing.T) {
+	tests := []struct {
+		input      string
+		shouldErr  bool
+		expectedAA int
+		expectedRA int
+	}{
+		{`flags`, false, dnsserver.FlagKeep, dnsserver.FlagKeep},
+		{`flags {
+			aa off
+		}`, false, dnsserver.FlagClear, dnsserver.FlagKeep},
+		{`flags {
+			aa on
+			ra auto
+		}`, false, dnsserver.FlagSet, dnsserver.FlagAuto},
+		{`flags {
+			ra off
+		}`, false, dnsserver.FlagKeep, dnsserver.FlagClear},
+		// fails
+		{`flags on`, true, 0, 0},
+		{`flags {
+			aa auto
+		}`, true, 0, 0},
+		{`flags {
+			ra
+		}`, true, 0, 0},
+		{`flags {
+			tc on
+		}`, true, 0, 0},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		err := setupFlags(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %d: Expected error but found nil", i)
+			continue
+		}
+		if !test.shouldErr && err != nil {
+			t.Errorf("Test %d: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr {
+			continue
+		}
+		cfg := dnsserver.GetConfig(c)
+		if cfg.AA != test.expectedAA {
+			t.Errorf("Test %d: Expected AA policy %d, got %d", i, test.expectedAA, cfg.AA)
+		}
+		if cfg.RA != test.expectedRA {
+			t.Errorf("Test %d: Expected RA policy %d, got %d", i, test.expectedRA, cfg.RA)
+		}
+	}
+}
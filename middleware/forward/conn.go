@@ -0,0 +1,222 @@
+package forward
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+var (
+	errClosed   = errors.New("connection closed")
+	errTimeout  = errors.New("no reply in time")
+	errBusy     = errors.New("too many queries in flight on the connection")
+	errQuestion = errors.New("reply is for another question")
+)
+
+// mux is a connection to a host that many queries are sent over at the same time. The
+// queries get an ID that is unique on the connection, the replies are matched to them by
+// that ID, in whatever order they arrive. The connection is dialed when a query needs it:
+// the first time, after it broke, and after it was idle for longer than expire.
+type mux struct {
+	dial   func() (net.Conn, error)
+	expire time.Duration
+	dials  func() // called for every connection that is dialed
+
+	sync.Mutex
+	c *conn
+}
+
+// conn is a single connection of a mux, with the queries that wait for a reply on it.
+type conn struct {
+	co *dns.Conn
+
+	sync.Mutex
+	pending map[uint16]chan *dns.Msg
+	closed  bool
+	used    time.Time
+
+	writeMu sync.Mutex
+}
+
+// exchange sends m and returns the reply. When the connection turns out to be closed, which
+// the host does with connections it considers idle, m is sent once more over a new one.
+func (x *mux) exchange(m *dns.Msg) (*dns.Msg, error) {
+	reply, err := x.exchangeOnce(m)
+	if err == errClosed {
+		reply, err = x.exchangeOnce(m)
+	}
+	return reply, err
+}
+
+func (x *mux) exchangeOnce(m *dns.Msg) (*dns.Msg, error) {
+	c, err := x.conn()
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan *dns.Msg, 1)
+	id, err := c.register(ch)
+	if err != nil {
+		return nil, err
+	}
+	defer c.unregister(id)
+
+	// Only the ID changes, a shallow copy leaves m as it is.
+	q := *m
+	q.Id = id
+	c.writeMu.Lock()
+	c.co.SetWriteDeadline(time.Now().Add(timeout))
+	err = c.co.WriteMsg(&q)
+	c.writeMu.Unlock()
+	if err != nil {
+		c.close()
+		return nil, errClosed
+	}
+
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case reply, ok := <-ch:
+		if !ok {
+			return nil, errClosed
+		}
+		if !sameQuestion(m, reply) {
+			return nil, errQuestion
+		}
+		reply.Id = m.Id
+		return reply, nil
+	case <-t.C:
+		return nil, errTimeout
+	}
+}
+
+// conn returns the connection of x, it dials a new one when there is none that can be used.
+func (x *mux) conn() (*conn, error) {
+	x.Lock()
+	defer x.Unlock()
+	if x.c != nil && x.c.usable(x.expire) {
+		return x.c, nil
+	}
+	if x.c != nil {
+		x.c.close()
+		x.c = nil
+	}
+
+	nc, err := x.dial()
+	if err != nil {
+		return nil, err
+	}
+	if x.dials != nil {
+		x.dials()
+	}
+	x.c = &conn{co: &dns.Conn{Conn: nc}, pending: make(map[uint16]chan *dns.Msg), used: time.Now()}
+	go x.c.read()
+	return x.c, nil
+}
+
+// close closes the connection of x.
+func (x *mux) close() {
+	x.Lock()
+	defer x.Unlock()
+	if x.c != nil {
+		x.c.close()
+		x.c = nil
+	}
+}
+
+// usable returns true if c is open and, when it has no queries in flight, was used less
+// than expire ago. The host may have closed a connection that was idle longer.
+func (c *conn) usable(expire time.Duration) bool {
+	c.Lock()
+	defer c.Unlock()
+	if c.closed {
+		return false
+	}
+	return len(c.pending) > 0 || expire == 0 || time.Since(c.used) < expire
+}
+
+// register returns an ID that no other query on c has, and sends the reply to that ID to ch.
+func (c *conn) register(ch chan *dns.Msg) (uint16, error) {
+	c.Lock()
+	defer c.Unlock()
+	if c.closed {
+		return 0, errClosed
+	}
+	if len(c.pending) >= maxPending {
+		return 0, errBusy
+	}
+	id := dns.Id()
+	for _, ok := c.pending[id]; ok; _, ok = c.pending[id] {
+		id = dns.Id()
+	}
+	c.pending[id] = ch
+	c.used = time.Now()
+	return id, nil
+}
+
+func (c *conn) unregister(id uint16) {
+	c.Lock()
+	defer c.Unlock()
+	delete(c.pending, id)
+}
+
+// read hands the replies that arrive on c to the queries they belong to, until c breaks.
+// Replies to queries that have given up are dropped.
+func (c *conn) read() {
+	for {
+		m, err := c.co.ReadMsg()
+		if err != nil {
+			c.close()
+			return
+		}
+		c.Lock()
+		ch, ok := c.pending[m.Id]
+		delete(c.pending, m.Id)
+		c.Unlock()
+		if ok {
+			ch <- m
+		}
+	}
+}
+
+// close closes c, the queries that wait for a reply on it get errClosed.
+func (c *conn) close() {
+	c.Lock()
+	if c.closed {
+		c.Unlock()
+		return
+	}
+	c.closed = true
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
+	}
+	c.Unlock()
+	c.co.Close()
+}
+
+// sameQuestion returns true if reply answers the question of m.
+func sameQuestion(m, reply *dns.Msg) bool {
+	if len(m.Question) != len(reply.Question) {
+		return false
+	}
+	for i, q := range m.Question {
+		r := reply.Question[i]
+		if q.Qtype != r.Qtype || q.Qclass != r.Qclass || !strings.EqualFold(q.Name, r.Name) {
+			return false
+		}
+	}
+	return true
+}
+
+const (
+	dialTimeout = 2 * time.Second
+	timeout     = 2 * time.Second // for writing a query and waiting for its reply
+
+	// maxPending is the maximum number of queries in flight on a connection, well below
+	// the number of IDs, so a free one is found fast.
+	maxPending = 16384
+)
@@ -0,0 +1,130 @@
+// Package forward is middleware that forwards queries to upstream resolvers over long-lived
+// TCP or TLS connections. Many queries are in flight on a connection at the same time, the
+// replies are matched to them by their ID (RFC 7766, section 6.2.1.1), so a burst of queries
+// doesn't set up a connection for each.
+package forward
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/coredns/middleware"
+	"github.com/miekg/coredns/request"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+var errNoHost = errors.New("no upstream replied")
+
+// Forward forwards the queries for the names below from, except the ones below ignored,
+// to its hosts. The other queries are handed to Next.
+type Forward struct {
+	Next middleware.Handler
+
+	from    string
+	ignored []string
+	hosts   []*host
+
+	maxFails    int           // failed queries in a row after which a host is down, 0 never takes it down
+	failTimeout time.Duration // how long a host stays down before it is tried again
+}
+
+// ServeDNS implements the middleware.Handler interface.
+func (f *Forward) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: r}
+	if !f.match(state.Name()) {
+		return f.Next.ServeDNS(ctx, w, r)
+	}
+
+	err := errNoHost
+	for _, h := range f.list() {
+		if e := ctx.Err(); e != nil {
+			// The client has given up.
+			return dns.RcodeServerFailure, e
+		}
+		var reply *dns.Msg
+		reply, err = h.exchange(r)
+		if err != nil {
+			h.failed(f.maxFails, f.failTimeout)
+			continue
+		}
+		h.succeeded()
+
+		// A reply we got over TCP may not fit the buffer of a client that asked over UDP.
+		reply, _ = state.Scrub(reply)
+		reply.Compress = true
+		w.WriteMsg(reply)
+		return 0, nil
+	}
+	return dns.RcodeServerFailure, middleware.Error("forward", err)
+}
+
+// match returns true if the queries for name are forwarded.
+func (f *Forward) match(name string) bool {
+	if !middleware.Name(f.from).Matches(name) {
+		return false
+	}
+	for _, ignored := range f.ignored {
+		if middleware.Name(ignored).Matches(name) {
+			return false
+		}
+	}
+	return true
+}
+
+// list returns the hosts in the order they are tried: the healthy ones first, in a random
+// order, then the ones that are down, in case all hosts are.
+func (f *Forward) list() []*host {
+	healthy := make([]*host, 0, len(f.hosts))
+	down := []*host{}
+	for _, i := range rand.Perm(len(f.hosts)) {
+		if h := f.hosts[i]; h.down(f.maxFails) {
+			down = append(down, h)
+		} else {
+			healthy = append(healthy, h)
+		}
+	}
+	return append(healthy, down...)
+}
+
+// close closes the connections to the hosts.
+func (f *Forward) close() {
+	for _, h := range f.hosts {
+		h.close()
+	}
+}
+
+// splitAddr returns the address of the host name and whether it is reached over TLS. name
+// has a port.
+func splitAddr(name string) (string, bool) {
+	if strings.HasPrefix(name, tlsPrefix) {
+		return name[len(tlsPrefix):], true
+	}
+	return name, false
+}
+
+// hostPort returns the name of the host s, with the default port of its protocol added
+// when it has none. It returns an error if the address of s isn't an IP address.
+func hostPort(s string) (string, error) {
+	prefix, port := "", "53"
+	if strings.HasPrefix(s, tlsPrefix) {
+		prefix, port = tlsPrefix, "853"
+		s = s[len(tlsPrefix):]
+	}
+	addr, p, err := net.SplitHostPort(s)
+	if err != nil {
+		addr, p = strings.Trim(s, "[]"), port
+	}
+	if net.ParseIP(addr) == nil {
+		return "", fmt.Errorf("not an IP address: `%s'", addr)
+	}
+	return prefix + net.JoinHostPort(addr, p), nil
+}
+
+// tlsPrefix marks a host that is reached over TLS.
+const tlsPrefix = "tls://"
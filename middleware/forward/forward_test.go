@@ -0,0 +1,149 @@
+package forward
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/coredns/middleware/pkg/dnsrecorder"
+	"github.com/miekg/coredns/middleware/test"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+// tcpServer starts a server on a random TCP port of localhost that reads the queries on a
+// connection as they come in and answers each after delay(query), so the replies may be out of
+// order. It counts the connections made to it.
+func tcpServer(t *testing.T, delay func(*dns.Msg) time.Duration) (addr string, conns *int32, stop func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen: %s", err)
+	}
+	conns = new(int32)
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(conns, 1)
+			go func() {
+				co := &dns.Conn{Conn: c}
+				defer co.Close()
+				var mu sync.Mutex
+				for {
+					r, err := co.ReadMsg()
+					if err != nil {
+						return
+					}
+					go func() {
+						time.Sleep(delay(r))
+						m := new(dns.Msg)
+						m.SetReply(r)
+						m.Answer = append(m.Answer, test.A(r.Question[0].Name+" 3600 IN A 127.0.0.53"))
+						mu.Lock()
+						co.WriteMsg(m)
+						mu.Unlock()
+					}()
+				}
+			}()
+		}
+	}()
+	return l.Addr().String(), conns, func() { l.Close() }
+}
+
+func TestForwardMultiplex(t *testing.T) {
+	// The first query is answered last.
+	addr, conns, stop := tcpServer(t, func(r *dns.Msg) time.Duration {
+		if r.Question[0].Name == "slow.example.org." {
+			return 200 * time.Millisecond
+		}
+		return 0
+	})
+	defer stop()
+
+	f := &Forward{from: ".", hosts: []*host{newHost(addr, nil, 1, defaultExpire)}, maxFails: defaultMaxFails, failTimeout: defaultFailTimeout}
+	defer f.close()
+
+	names := []string{"slow.example.org."}
+	for i := 0; i < 50; i++ {
+		names = append(names, "fast.example.org.")
+	}
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			m := new(dns.Msg)
+			m.SetQuestion(name, dns.TypeA)
+			rec := dnsrecorder.New(&test.ResponseWriter{})
+			if _, err := f.ServeDNS(context.TODO(), rec, m); err != nil {
+				t.Errorf("Expected no error for %s, got %s", name, err)
+				return
+			}
+			if rec.Msg.Id != m.Id || len(rec.Msg.Answer) != 1 || rec.Msg.Answer[0].Header().Name != name {
+				t.Errorf("Expected the reply for %s, got %v", name, rec.Msg)
+			}
+		}(name)
+		if i == 0 {
+			// Let the slow query go first.
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	wg.Wait()
+
+	if x := atomic.LoadInt32(conns); x != 1 {
+		t.Errorf("Expected all queries over 1 connection, got %d", x)
+	}
+}
+
+func TestForwardFailover(t *testing.T) {
+	addr, _, stop := tcpServer(t, func(*dns.Msg) time.Duration { return 0 })
+	defer stop()
+
+	// Nothing listens on the port of a closed listener.
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	dead := l.Addr().String()
+	l.Close()
+
+	f := &Forward{from: ".", maxFails: 1, failTimeout: time.Minute,
+		hosts: []*host{newHost(dead, nil, 1, defaultExpire), newHost(addr, nil, 1, defaultExpire)}}
+	defer f.close()
+
+	// The dead host is tried first half of the time, until it is down.
+	for i := 0; i < 20; i++ {
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		if _, err := f.ServeDNS(context.TODO(), dnsrecorder.New(&test.ResponseWriter{}), m); err != nil {
+			t.Fatalf("Expected no error, got %s", err)
+		}
+	}
+	if !f.hosts[0].down(f.maxFails) {
+		t.Errorf("Expected %s to be down", dead)
+	}
+	if f.list()[0] != f.hosts[1] {
+		t.Errorf("Expected the healthy host to be tried first")
+	}
+}
+
+func TestForwardMatch(t *testing.T) {
+	f := &Forward{from: "example.org.", ignored: []string{"corp.example.org."}}
+	tests := []struct {
+		name     string
+		expected bool
+	}{
+		{"example.org.", true},
+		{"www.example.org.", true},
+		{"corp.example.org.", false},
+		{"www.corp.example.org.", false},
+		{"example.net.", false},
+	}
+	for i, tc := range tests {
+		if x := f.match(tc.name); x != tc.expected {
+			t.Errorf("Test %d: expected match of %s to be %t, got %t", i, tc.name, tc.expected, x)
+		}
+	}
+}
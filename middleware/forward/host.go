@@ -0,0 +1,89 @@
+package forward
+
+import (
+	"crypto/tls"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// host is an upstream the queries are forwarded to, over a fixed number of connections.
+type host struct {
+	addr string // host:port, without the tls:// prefix
+	name string // as given in the Corefile, with its port
+	tls  bool
+	muxs []*mux
+	next uint32 // the connection the next query is sent over
+
+	sync.Mutex
+	fails     int       // failed queries in a row
+	downUntil time.Time // when fails reached max_fails, the host is only tried again after this
+}
+
+func newHost(name string, config *tls.Config, conns int, expire time.Duration) *host {
+	h := &host{name: name}
+	h.addr, h.tls = splitAddr(name)
+	dial := func() (net.Conn, error) {
+		if h.tls {
+			return tls.DialWithDialer(&net.Dialer{Timeout: dialTimeout}, "tcp", h.addr, config)
+		}
+		return net.DialTimeout("tcp", h.addr, dialTimeout)
+	}
+	for i := 0; i < conns; i++ {
+		h.muxs = append(h.muxs, &mux{dial: dial, expire: expire, dials: func() {
+			dialCount.WithLabelValues(h.name).Inc()
+		}})
+	}
+	return h
+}
+
+// exchange sends m to h, the connections of h take turns.
+func (h *host) exchange(m *dns.Msg) (*dns.Msg, error) {
+	x := h.muxs[atomic.AddUint32(&h.next, 1)%uint32(len(h.muxs))]
+	start := time.Now()
+	reply, err := x.exchange(m)
+	requestCount.WithLabelValues(h.name).Inc()
+	requestDuration.WithLabelValues(h.name).Observe(float64(time.Since(start) / time.Millisecond))
+	if err != nil {
+		failureCount.WithLabelValues(h.name).Inc()
+	}
+	return reply, err
+}
+
+// down returns true if h failed maxFails queries in a row, and failTimeout has not passed
+// since. After that, h is tried again; a single success makes it healthy.
+func (h *host) down(maxFails int) bool {
+	if maxFails == 0 {
+		return false
+	}
+	h.Lock()
+	defer h.Unlock()
+	return h.fails >= maxFails && time.Now().Before(h.downUntil)
+}
+
+// failed records a failed query to h.
+func (h *host) failed(maxFails int, failTimeout time.Duration) {
+	h.Lock()
+	defer h.Unlock()
+	h.fails++
+	if maxFails > 0 && h.fails >= maxFails {
+		h.downUntil = time.Now().Add(failTimeout)
+	}
+}
+
+// succeeded records a query to h that got a reply.
+func (h *host) succeeded() {
+	h.Lock()
+	defer h.Unlock()
+	h.fails = 0
+}
+
+// close closes the connections to h.
+func (h *host) close() {
+	for _, x := range h.muxs {
+		x.close()
+	}
+}
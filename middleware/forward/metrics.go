@@ -0,0 +1,45 @@
+package forward
+
+import (
+	"github.com/miekg/coredns/middleware"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	requestCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: middleware.Namespace,
+		Subsystem: "forward",
+		Name:      "request_count_total",
+		Help:      "Counter of queries sent per upstream.",
+	}, []string{"to"})
+
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: middleware.Namespace,
+		Subsystem: "forward",
+		Name:      "request_duration_milliseconds",
+		Buckets:   append(prometheus.DefBuckets, []float64{50, 100, 200, 500, 1000, 2000, 3000, 4000, 5000}...),
+		Help:      "Histogram of the time (in milliseconds) each query to an upstream took.",
+	}, []string{"to"})
+
+	failureCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: middleware.Namespace,
+		Subsystem: "forward",
+		Name:      "failures_total",
+		Help:      "Counter of queries to an upstream that got no reply.",
+	}, []string{"to"})
+
+	dialCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: middleware.Namespace,
+		Subsystem: "forward",
+		Name:      "connections_total",
+		Help:      "Counter of connections made to an upstream.",
+	}, []string{"to"})
+)
+
+func init() {
+	prometheus.MustRegister(requestCount)
+	prometheus.MustRegister(requestDuration)
+	prometheus.MustRegister(failureCount)
+	prometheus.MustRegister(dialCount)
+}
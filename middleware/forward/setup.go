@@ -0,0 +1,206 @@
+package forward
+
+import (
+	"crypto/tls"
+	"strconv"
+	"time"
+
+	"github.com/miekg/coredns/core/dnsserver"
+	"github.com/miekg/coredns/middleware"
+	mwtls "github.com/miekg/coredns/middleware/pkg/tls"
+
+	"github.com/mholt/caddy"
+)
+
+func init() {
+	caddy.RegisterPlugin("forward", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	fs, err := forwardParse(c)
+	if err != nil {
+		return middleware.Error("forward", err)
+	}
+
+	config := dnsserver.GetConfig(c)
+	config.Recursion = true
+	for _, f := range fs {
+		f := f
+		config.AddMiddleware(func(next middleware.Handler) middleware.Handler {
+			f.Next = next
+			return f
+		})
+	}
+
+	c.OnShutdown(func() error {
+		for _, f := range fs {
+			f.close()
+		}
+		return nil
+	})
+
+	return nil
+}
+
+// forwardParse parses 'forward FROM TO... { ... }' statements, each gives a Forward.
+func forwardParse(c *caddy.Controller) ([]*Forward, error) {
+	var fs []*Forward
+
+	for c.Next() {
+		f := &Forward{maxFails: defaultMaxFails, failTimeout: defaultFailTimeout}
+
+		args := c.RemainingArgs()
+		if len(args) < 2 {
+			return nil, c.ArgErr()
+		}
+		f.from = middleware.Host(args[0]).Normalize()
+
+		var (
+			names     []string
+			tlsConfig *tls.Config
+			verify    mwtls.Verification
+			conns     = defaultConns
+			expire    = defaultExpire
+		)
+		for _, to := range args[1:] {
+			name, err := hostPort(to)
+			if err != nil {
+				return nil, err
+			}
+			names = append(names, name)
+		}
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "except":
+				ignored := c.RemainingArgs()
+				if len(ignored) == 0 {
+					return nil, c.ArgErr()
+				}
+				for _, i := range ignored {
+					f.ignored = append(f.ignored, middleware.Host(i).Normalize())
+				}
+			case "tls": // [cert key] [cacertfile]
+				config, err := mwtls.NewTLSConfigFromArgs(c.RemainingArgs()...)
+				if err != nil {
+					return nil, err
+				}
+				if tlsConfig != nil {
+					config.ServerName = tlsConfig.ServerName
+				}
+				tlsConfig = config
+			case "tls_servername":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				if tlsConfig == nil {
+					tlsConfig = &tls.Config{}
+				}
+				tlsConfig.ServerName = c.Val()
+			case "tls_ca":
+				cas := c.RemainingArgs()
+				if len(cas) == 0 {
+					return nil, c.ArgErr()
+				}
+				verify.CAs = append(verify.CAs, cas...)
+			case "tls_pin":
+				pins := c.RemainingArgs()
+				if len(pins) == 0 {
+					return nil, c.ArgErr()
+				}
+				verify.Pins = append(verify.Pins, pins...)
+			case "tls_insecure":
+				if c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				verify.Insecure = true
+			case "max_fails":
+				n, err := parseInt(c)
+				if err != nil {
+					return nil, err
+				}
+				f.maxFails = n
+			case "fail_timeout":
+				dur, err := parseDuration(c)
+				if err != nil {
+					return nil, err
+				}
+				f.failTimeout = dur
+			case "conns":
+				n, err := parseInt(c)
+				if err != nil {
+					return nil, err
+				}
+				if n == 0 {
+					return nil, c.Errf("conns must be larger than zero")
+				}
+				conns = n
+			case "expire":
+				dur, err := parseDuration(c)
+				if err != nil {
+					return nil, err
+				}
+				expire = dur
+			default:
+				return nil, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+
+		if verify.IsSet() {
+			if tlsConfig == nil {
+				tlsConfig = &tls.Config{}
+			}
+			if err := verify.Apply(tlsConfig); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, name := range names {
+			f.hosts = append(f.hosts, newHost(name, tlsConfig, conns, expire))
+		}
+		fs = append(fs, f)
+	}
+	return fs, nil
+}
+
+// parseInt parses the argument of the current property, a number that is not negative.
+func parseInt(c *caddy.Controller) (int, error) {
+	what := c.Val()
+	if !c.NextArg() {
+		return 0, c.ArgErr()
+	}
+	n, err := strconv.Atoi(c.Val())
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, c.Errf("%s can't be negative: %d", what, n)
+	}
+	return n, nil
+}
+
+// parseDuration parses the argument of the current property, a duration that is not negative.
+func parseDuration(c *caddy.Controller) (time.Duration, error) {
+	what := c.Val()
+	if !c.NextArg() {
+		return 0, c.ArgErr()
+	}
+	dur, err := time.ParseDuration(c.Val())
+	if err != nil {
+		return 0, err
+	}
+	if dur < 0 {
+		return 0, c.Errf("%s can't be negative: %s", what, dur)
+	}
+	return dur, nil
+}
+
+const (
+	defaultMaxFails    = 2
+	defaultFailTimeout = 10 * time.Second
+	defaultConns       = 2
+	defaultExpire      = 10 * time.Second
+)
@@ -0,0 +1,110 @@
+package forward
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mholt/caddy"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input         string
+		shouldErr     bool
+		expectedFrom  string
+		expectedHosts []string
+		expectedErr   string
+	}{
+		{`forward . 127.0.0.1`, false, ".", []string{"127.0.0.1:53"}, ""},
+		{`forward example.org 127.0.0.1:1053 [::1] tls://9.9.9.9`, false, "example.org.", []string{"127.0.0.1:1053", "[::1]:53", "tls://9.9.9.9:853"}, ""},
+		{`forward . 127.0.0.1 {
+			except miek.nl
+			max_fails 3
+			fail_timeout 30s
+			conns 4
+			expire 5s
+		}`, false, ".", []string{"127.0.0.1:53"}, ""},
+		{`forward . tls://9.9.9.9 {
+			tls_servername dns.quad9.net
+		}`, false, ".", []string{"tls://9.9.9.9:853"}, ""},
+		{`forward . tls://10.0.0.53 {
+			tls_pin sha256/AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA= BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBA=
+			tls_insecure
+		}`, true, "", nil, "can't be combined"},
+		{`forward . tls://10.0.0.53 {
+			tls_pin sha256/AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA= BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBA=
+		}`, false, ".", []string{"tls://10.0.0.53:853"}, ""},
+		{`forward . tls://10.0.0.53 {
+			tls_insecure
+		}`, false, ".", []string{"tls://10.0.0.53:853"}, ""},
+		{`forward . tls://10.0.0.53 {
+			tls_pin c2hvcnQ=
+		}`, true, "", nil, "not a base64 encoded SHA-256 hash"},
+		{`forward . tls://10.0.0.53 {
+			tls_ca /does/not/exist.pem
+		}`, true, "", nil, "error reading"},
+		{`forward .`, true, "", nil, "Wrong argument count"},
+		{`forward . dns.google`, true, "", nil, "not an IP address"},
+		{`forward . 127.0.0.1 {
+			conns 0
+		}`, true, "", nil, "larger than zero"},
+		{`forward . 127.0.0.1 {
+			max_fails -1
+		}`, true, "", nil, "can't be negative"},
+		{`forward . 127.0.0.1 {
+			blaat
+		}`, true, "", nil, "unknown property"},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		fs, err := forwardParse(c)
+
+		if test.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error but found none for input %s", i, test.input)
+			} else if !strings.Contains(err.Error(), test.expectedErr) {
+				t.Errorf("Test %d: expected error to contain %q, got %q", i, test.expectedErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error but found one for input %s, got: %v", i, test.input, err)
+			continue
+		}
+		f := fs[0]
+		if f.from != test.expectedFrom {
+			t.Errorf("Test %d: expected from %s, got %s", i, test.expectedFrom, f.from)
+		}
+		hosts := []string{}
+		for _, h := range f.hosts {
+			hosts = append(hosts, h.name)
+		}
+		if strings.Join(hosts, " ") != strings.Join(test.expectedHosts, " ") {
+			t.Errorf("Test %d: expected hosts %v, got %v", i, test.expectedHosts, hosts)
+		}
+	}
+}
+
+func TestSetupOptions(t *testing.T) {
+	c := caddy.NewTestController("dns", `forward . 127.0.0.1 {
+		except miek.nl
+		max_fails 3
+		fail_timeout 30s
+		conns 4
+		expire 5s
+	}`)
+	fs, err := forwardParse(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	f := fs[0]
+	if f.maxFails != 3 || f.failTimeout != 30*time.Second || len(f.ignored) != 1 || f.ignored[0] != "miek.nl." {
+		t.Errorf("Expected the options to be set, got %+v", f)
+	}
+	h := f.hosts[0]
+	if len(h.muxs) != 4 || h.muxs[0].expire != 5*time.Second {
+		t.Errorf("Expected 4 connections that expire after 5s, got %d", len(h.muxs))
+	}
+}
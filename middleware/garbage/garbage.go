@@ -0,0 +1,144 @@
+// Package garbage detects clients that send garbage queries, floods of random
+// subdomains or queries that only get NXDOMAIN, and blocks them for a while.
+package garbage
+
+import (
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/coredns/middleware"
+	"github.com/miekg/coredns/middleware/pkg/response"
+	"github.com/miekg/coredns/request"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/context"
+)
+
+// Garbage counts, per client network, the NXDOMAIN responses and the distinct names
+// asked over a sliding window. A client that goes over one of the limits is blocked:
+// its queries are dropped, refused or rate limited until the block expires.
+type Garbage struct {
+	Next  middleware.Handler
+	Zones []string
+
+	action action
+	rate   float64 // queries per second allowed to a blocked client, for the limit action
+
+	ipv4Prefix int
+	ipv6Prefix int
+
+	table *table
+}
+
+// action is what is done with the queries of a blocked client.
+type action int
+
+const (
+	actionDrop action = iota
+	actionRefuse
+	actionLimit
+)
+
+// ServeDNS implements the middleware.Handler interface.
+func (g Garbage) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: r}
+
+	zone := middleware.Zones(g.Zones).Matches(state.Name())
+	if zone == "" {
+		return g.Next.ServeDNS(ctx, w, r)
+	}
+
+	key := g.prefix(state)
+	now := time.Now()
+	if g.table.blocked(key, now) {
+		switch g.action {
+		case actionRefuse:
+			blockedCount.WithLabelValues(zone).Inc()
+			return dns.RcodeRefused, nil
+		case actionLimit:
+			if g.table.allow(key, g.rate, now) {
+				return g.Next.ServeDNS(ctx, w, r)
+			}
+		}
+		blockedCount.WithLabelValues(zone).Inc()
+		return dns.RcodeSuccess, nil
+	}
+
+	g.blocked(key, zone, g.table.name(key, strings.ToLower(state.Name()), now))
+
+	gw := &ResponseWriter{ResponseWriter: w, garbage: g, zone: zone, key: key}
+	return g.Next.ServeDNS(ctx, gw, r)
+}
+
+// blocked logs and counts a client that is blocked for reason, if any.
+func (g Garbage) blocked(key, zone string, r reason) {
+	if r == none {
+		return
+	}
+	blockCount.WithLabelValues(zone, string(r)).Inc()
+	switch r {
+	case nxdomain:
+		log.Printf("[INFO] Blocking %s for %s: more than %d NXDOMAIN responses in %s for %s", key, g.table.block, g.table.maxNX, g.table.window, zone)
+	case names:
+		log.Printf("[INFO] Blocking %s for %s: more than %d distinct names in %s for %s", key, g.table.block, g.table.maxNames, g.table.window, zone)
+	}
+}
+
+// prefix returns the network of the client, using the configured prefix lengths.
+func (g Garbage) prefix(state request.Request) string {
+	ip := net.ParseIP(state.IP())
+	if ip == nil {
+		return state.IP()
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(g.ipv4Prefix, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(g.ipv6Prefix, 128)).String()
+}
+
+// ResponseWriter counts the NXDOMAIN responses written to it.
+type ResponseWriter struct {
+	dns.ResponseWriter
+	garbage Garbage
+	zone    string
+	key     string
+}
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (w *ResponseWriter) WriteMsg(res *dns.Msg) error {
+	if t, _ := response.Classify(res); t == response.NameError {
+		w.garbage.blocked(w.key, w.zone, w.garbage.table.nxdomain(w.key, time.Now()))
+	}
+	return w.ResponseWriter.WriteMsg(res)
+}
+
+// Write implements the dns.ResponseWriter interface.
+func (w *ResponseWriter) Write(buf []byte) (int, error) {
+	return w.ResponseWriter.Write(buf)
+}
+
+var (
+	blockCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: middleware.Namespace,
+		Subsystem: subsystem,
+		Name:      "blocks_total",
+		Help:      "Counter of clients that were blocked, by the reason they were blocked for.",
+	}, []string{"zone", "reason"})
+
+	blockedCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: middleware.Namespace,
+		Subsystem: subsystem,
+		Name:      "blocked_queries_total",
+		Help:      "Counter of queries of blocked clients that were dropped or refused.",
+	}, []string{"zone"})
+)
+
+const subsystem = "garbage"
+
+func init() {
+	prometheus.MustRegister(blockCount)
+	prometheus.MustRegister(blockedCount)
+}
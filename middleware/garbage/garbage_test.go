@@ -0,0 +1,183 @@
+package garbage
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/miekg/coredns/middleware"
+	"github.com/miekg/coredns/middleware/pkg/dnsrecorder"
+	"github.com/miekg/coredns/middleware/test"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+func TestTableNXDomain(t *testing.T) {
+	tb := newTable(10, 10*time.Second, time.Minute, 3, 0)
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if r := tb.nxdomain("a", now); r != none {
+			t.Fatalf("Expected NXDOMAIN %d to be allowed, got %q", i, r)
+		}
+	}
+	if r := tb.nxdomain("a", now); r != nxdomain {
+		t.Fatalf("Expected client to be blocked for %q, got %q", nxdomain, r)
+	}
+	if !tb.blocked("a", now) || tb.blocked("b", now) {
+		t.Errorf("Expected only a to be blocked")
+	}
+	if tb.blocked("a", now.Add(time.Minute)) {
+		t.Errorf("Expected block to expire")
+	}
+}
+
+func TestTableSlidingWindow(t *testing.T) {
+	tb := newTable(10, 10*time.Second, time.Minute, 4, 0)
+	now := time.Now()
+
+	for i := 0; i < 4; i++ {
+		tb.nxdomain("a", now)
+	}
+	// Halfway the next window, half of the previous one still counts: 2 + 2 is
+	// within the limit, the third is not.
+	later := now.Add(15 * time.Second)
+	for i := 0; i < 2; i++ {
+		if r := tb.nxdomain("a", later); r != none {
+			t.Fatalf("Expected NXDOMAIN %d to be allowed, got %q", i, r)
+		}
+	}
+	if r := tb.nxdomain("a", later); r != nxdomain {
+		t.Errorf("Expected client to be blocked, got %q", r)
+	}
+
+	// After two windows nothing of the past counts.
+	tb = newTable(10, 10*time.Second, time.Minute, 4, 0)
+	for i := 0; i < 4; i++ {
+		tb.nxdomain("a", now)
+	}
+	for i := 0; i < 4; i++ {
+		if r := tb.nxdomain("a", now.Add(20*time.Second)); r != none {
+			t.Fatalf("Expected NXDOMAIN %d to be allowed, got %q", i, r)
+		}
+	}
+}
+
+func TestTableNames(t *testing.T) {
+	tb := newTable(10, 10*time.Second, time.Minute, 0, 3)
+	now := time.Now()
+
+	// The same name over and over is fine.
+	for i := 0; i < 10; i++ {
+		if r := tb.name("a", "www.example.org.", now); r != none {
+			t.Fatalf("Expected query %d to be allowed, got %q", i, r)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		tb.name("a", strconv.Itoa(i)+".example.org.", now)
+	}
+	if r := tb.name("a", "x.example.org.", now); r != names {
+		t.Errorf("Expected client to be blocked for %q, got %q", names, r)
+	}
+}
+
+func TestTableEvict(t *testing.T) {
+	tb := newTable(2, time.Second, time.Minute, 1, 0)
+	now := time.Now()
+
+	tb.nxdomain("a", now)
+	tb.nxdomain("b", now)
+	tb.nxdomain("b", now) // blocked
+	// Table is full, "c" is not tracked.
+	tb.nxdomain("c", now)
+	if tb.len() != 2 {
+		t.Errorf("Expected 2 clients, got %d", tb.len())
+	}
+	// After two windows "a" is evicted, the blocked "b" is kept.
+	tb.nxdomain("c", now.Add(2*time.Second))
+	if tb.len() != 2 || !tb.blocked("b", now.Add(2*time.Second)) {
+		t.Errorf("Expected 2 clients with b blocked, got %d", tb.len())
+	}
+}
+
+func TestTableEvictBlocked(t *testing.T) {
+	tb := newTable(2, time.Second, time.Minute, 1, 0)
+	now := time.Now()
+
+	tb.nxdomain("a", now)
+	tb.nxdomain("a", now) // blocked
+	tb.nxdomain("b", now)
+	// The blocked "a" is at the back, it is kept and "b" behind it is evicted.
+	later := now.Add(2 * time.Second)
+	tb.nxdomain("c", later)
+	if tb.len() != 2 || !tb.blocked("a", later) {
+		t.Errorf("Expected 2 clients with a blocked, got %d", tb.len())
+	}
+	if r := tb.nxdomain("c", later); r != nxdomain {
+		t.Errorf("Expected c to be tracked and blocked, got %q", r)
+	}
+}
+
+func TestGarbage(t *testing.T) {
+	tests := []struct {
+		action  action
+		rcode   int  // returned for the queries of the blocked client
+		written bool // a reply is written for the queries of the blocked client
+	}{
+		{actionDrop, dns.RcodeSuccess, false},
+		{actionRefuse, dns.RcodeRefused, false},
+		{actionLimit, dns.RcodeNameError, true},
+	}
+	ctx := context.TODO()
+
+	for i, tc := range tests {
+		g := Garbage{
+			Next:       answerHandler(dns.RcodeNameError),
+			Zones:      []string{"example.org."},
+			action:     tc.action,
+			rate:       1000,
+			ipv4Prefix: 32,
+			ipv6Prefix: 64,
+			table:      newTable(100, time.Minute, time.Minute, 2, 0),
+		}
+
+		for j := 0; j < 3; j++ {
+			m := new(dns.Msg)
+			m.SetQuestion(strconv.Itoa(j)+".example.org.", dns.TypeA)
+			rec := dnsrecorder.New(&test.ResponseWriter{})
+			if rcode, _ := g.ServeDNS(ctx, rec, m); rcode != dns.RcodeNameError || rec.Msg == nil {
+				t.Fatalf("Test %d: Expected NXDOMAIN for query %d, got %d", i, j, rcode)
+			}
+		}
+
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		rec := dnsrecorder.New(&test.ResponseWriter{})
+		time.Sleep(2 * time.Millisecond) // let the limit bucket fill up
+		rcode, _ := g.ServeDNS(ctx, rec, m)
+		if rcode != tc.rcode {
+			t.Errorf("Test %d: Expected rcode %d for blocked client, got %d", i, tc.rcode, rcode)
+		}
+		if tc.written != (rec.Msg != nil) {
+			t.Errorf("Test %d: Expected written to be %t", i, tc.written)
+		}
+
+		// Names outside of the zones are not blocked.
+		m.SetQuestion("example.net.", dns.TypeA)
+		rec = dnsrecorder.New(&test.ResponseWriter{})
+		g.ServeDNS(ctx, rec, m)
+		if rec.Msg == nil {
+			t.Errorf("Test %d: Expected response for example.net. to be written", i)
+		}
+	}
+}
+
+func answerHandler(rcode int) middleware.Handler {
+	return middleware.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := new(dns.Msg)
+		m.SetRcode(r, rcode)
+		w.WriteMsg(m)
+		return rcode, nil
+	})
+}
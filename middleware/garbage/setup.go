@@ -0,0 +1,142 @@
+package garbage
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/miekg/coredns/core/dnsserver"
+	"github.com/miekg/coredns/middleware"
+
+	"github.com/mholt/caddy"
+)
+
+func init() {
+	caddy.RegisterPlugin("garbage", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	g, err := garbageParse(c)
+	if err != nil {
+		return middleware.Error("garbage", err)
+	}
+
+	dnsserver.GetConfig(c).AddMiddleware(func(next middleware.Handler) middleware.Handler {
+		g.Next = next
+		return g
+	})
+
+	return nil
+}
+
+func garbageParse(c *caddy.Controller) (Garbage, error) {
+	g := Garbage{
+		ipv4Prefix: defaultIPv4Prefix,
+		ipv6Prefix: defaultIPv6Prefix,
+	}
+	window, block := defaultWindow, defaultBlock
+	max, maxNX, maxNames := defaultMaxTableSize, 0, 0
+
+	for c.Next() {
+		origins := make([]string, len(c.ServerBlockKeys))
+		copy(origins, c.ServerBlockKeys)
+		if args := c.RemainingArgs(); len(args) > 0 {
+			origins = args
+		}
+		g.Zones = middleware.Zones(origins).NormalizeExact()
+
+		for c.NextBlock() {
+			what := c.Val()
+			args := c.RemainingArgs()
+
+			if what == "action" {
+				if len(args) == 0 {
+					return g, c.ArgErr()
+				}
+				switch args[0] {
+				case "drop":
+					g.action = actionDrop
+				case "refuse":
+					g.action = actionRefuse
+				case "limit":
+					if len(args) != 2 {
+						return g, c.ArgErr()
+					}
+					n, err := strconv.Atoi(args[1])
+					if err != nil || n <= 0 {
+						return g, c.Errf("limit needs a positive number: %s", args[1])
+					}
+					g.action, g.rate = actionLimit, float64(n)
+					continue
+				default:
+					return g, c.Errf("unknown action '%s'", args[0])
+				}
+				if len(args) != 1 {
+					return g, c.ArgErr()
+				}
+				continue
+			}
+
+			if len(args) != 1 {
+				return g, c.ArgErr()
+			}
+			n, err := strconv.Atoi(args[0])
+			if err != nil {
+				return g, c.Errf("%s needs a number: %s", what, args[0])
+			}
+			if n < 0 {
+				return g, c.Errf("%s can not be negative: %d", what, n)
+			}
+			switch what {
+			case "nxdomains":
+				maxNX = n
+			case "names":
+				maxNames = n
+			case "window":
+				if n == 0 {
+					return g, c.Errf("window must be positive: %d", n)
+				}
+				window = time.Duration(n) * time.Second
+			case "block":
+				if n == 0 {
+					return g, c.Errf("block must be positive: %d", n)
+				}
+				block = time.Duration(n) * time.Second
+			case "ipv4-prefix-length":
+				if n == 0 || n > 32 {
+					return g, c.Errf("invalid ipv4-prefix-length: %d", n)
+				}
+				g.ipv4Prefix = n
+			case "ipv6-prefix-length":
+				if n == 0 || n > 128 {
+					return g, c.Errf("invalid ipv6-prefix-length: %d", n)
+				}
+				g.ipv6Prefix = n
+			case "max-table-size":
+				if n == 0 {
+					return g, c.Errf("max-table-size must be positive: %d", n)
+				}
+				max = n
+			default:
+				return g, c.Errf("unknown property '%s'", what)
+			}
+		}
+	}
+
+	if maxNX == 0 && maxNames == 0 {
+		return g, c.Err("no nxdomains or names limit set")
+	}
+
+	g.table = newTable(max, window, block, maxNX, maxNames)
+	return g, nil
+}
+
+const (
+	defaultWindow       = 10 * time.Second
+	defaultBlock        = 5 * time.Minute
+	defaultIPv4Prefix   = 32
+	defaultIPv6Prefix   = 64
+	defaultMaxTableSize = 100000
+)
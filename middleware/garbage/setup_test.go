@@ -0,0 +1,106 @@
+package garbage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mholt/caddy"
+)
+
+func TestSetupGarbage(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		maxNX     int
+		maxNames  int
+		window    time.Duration
+		block     time.Duration
+		action    action
+		rate      float64
+		zones     []string
+	}{
+		{`garbage {
+			nxdomains 50
+		}`, false, 50, 0, defaultWindow, defaultBlock, actionDrop, 0, []string{"."}},
+		{`garbage example.org {
+			names 100
+			window 5
+			block 60
+			action refuse
+		}`, false, 0, 100, 5 * time.Second, time.Minute, actionRefuse, 0, []string{"example.org."}},
+		{`garbage {
+			nxdomains 50
+			names 100
+			action limit 2
+		}`, false, 50, 100, defaultWindow, defaultBlock, actionLimit, 2, []string{"."}},
+		// fails
+		{`garbage`, true, 0, 0, 0, 0, 0, 0, nil},
+		{`garbage {
+			nxdomains
+		}`, true, 0, 0, 0, 0, 0, 0, nil},
+		{`garbage {
+			nxdomains -1
+		}`, true, 0, 0, 0, 0, 0, 0, nil},
+		{`garbage {
+			nxdomains 50 60
+		}`, true, 0, 0, 0, 0, 0, 0, nil},
+		{`garbage {
+			nxdomains 50
+			block 0
+		}`, true, 0, 0, 0, 0, 0, 0, nil},
+		{`garbage {
+			nxdomains 50
+			action limit
+		}`, true, 0, 0, 0, 0, 0, 0, nil},
+		{`garbage {
+			nxdomains 50
+			action drop 2
+		}`, true, 0, 0, 0, 0, 0, 0, nil},
+		{`garbage {
+			nxdomains 50
+			action tarpit
+		}`, true, 0, 0, 0, 0, 0, 0, nil},
+		{`garbage {
+			nxdomains 50
+			ipv6-prefix-length 129
+		}`, true, 0, 0, 0, 0, 0, 0, nil},
+		{`garbage {
+			unknown 1
+		}`, true, 0, 0, 0, 0, 0, 0, nil},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		g, err := garbageParse(c)
+
+		if test.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: Expected error but found none for input %s", i, test.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: Expected no error but found one for input %s. Error was: %v", i, test.input, err)
+			continue
+		}
+
+		if g.table.maxNX != test.maxNX {
+			t.Errorf("Test %d: Expected nxdomains %d, got %d", i, test.maxNX, g.table.maxNX)
+		}
+		if g.table.maxNames != test.maxNames {
+			t.Errorf("Test %d: Expected names %d, got %d", i, test.maxNames, g.table.maxNames)
+		}
+		if g.table.window != test.window {
+			t.Errorf("Test %d: Expected window %s, got %s", i, test.window, g.table.window)
+		}
+		if g.table.block != test.block {
+			t.Errorf("Test %d: Expected block %s, got %s", i, test.block, g.table.block)
+		}
+		if g.action != test.action || g.rate != test.rate {
+			t.Errorf("Test %d: Expected action %d with rate %f, got %d with %f", i, test.action, test.rate, g.action, g.rate)
+		}
+		if len(g.Zones) != len(test.zones) || g.Zones[0] != test.zones[0] {
+			t.Errorf("Test %d: Expected zones %v, got %v", i, test.zones, g.Zones)
+		}
+	}
+}
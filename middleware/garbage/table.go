@@ -0,0 +1,219 @@
+package garbage
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// client holds the counters of a client network. The counters are kept for two
+// consecutive windows, the rate over the last window is estimated by weighing the
+// previous window by how much of it still overlaps: a sliding window that needs no
+// timestamp per query.
+type client struct {
+	key   string
+	start time.Time // start of the current window
+
+	nx     int // NXDOMAIN responses in the current window
+	prevNX int
+
+	names     map[string]struct{} // distinct names asked in the current window
+	prevNames int
+
+	until  time.Time // blocked until
+	tokens float64   // for the limit action, a token bucket of queries
+	last   time.Time
+}
+
+// roll moves the window of c forward, if the current one has passed.
+func (c *client) roll(window time.Duration, now time.Time) {
+	elapsed := now.Sub(c.start)
+	if elapsed < window {
+		return
+	}
+	if elapsed >= 2*window {
+		c.prevNX, c.prevNames = 0, 0
+		c.start = now
+	} else {
+		c.prevNX, c.prevNames = c.nx, len(c.names)
+		c.start = c.start.Add(window)
+	}
+	c.nx = 0
+	c.names = nil
+}
+
+// estimate returns the count over the last window, given the count in the previous
+// and the current window.
+func (c *client) estimate(prev, cur int, window time.Duration, now time.Time) float64 {
+	weight := 1 - float64(now.Sub(c.start))/float64(window)
+	return float64(prev)*weight + float64(cur)
+}
+
+// reason is why a client is blocked.
+type reason string
+
+const (
+	none     reason = ""
+	nxdomain reason = "nxdomain"
+	names    reason = "names"
+)
+
+// table keeps the counters and the blocks of the clients. The clients are also kept
+// in a list, the one counted last at the front, so the clients to evict are found at
+// the back without going over all of them.
+type table struct {
+	sync.Mutex
+	clients map[string]*list.Element
+	lru     *list.List
+	max     int
+
+	window   time.Duration
+	block    time.Duration
+	maxNX    int // NXDOMAIN responses per window before a client is blocked, 0 is unlimited
+	maxNames int // distinct names per window before a client is blocked, 0 is unlimited
+}
+
+func newTable(max int, window, block time.Duration, maxNX, maxNames int) *table {
+	return &table{clients: make(map[string]*list.Element), lru: list.New(), max: max, window: window, block: block, maxNX: maxNX, maxNames: maxNames}
+}
+
+// blocked returns true if key is blocked at now.
+func (t *table) blocked(key string, now time.Time) bool {
+	t.Lock()
+	defer t.Unlock()
+	e, ok := t.clients[key]
+	return ok && now.Before(e.Value.(*client).until)
+}
+
+// allow debits a query from the token bucket of key, which is credited with rate
+// queries per second. It returns false if the bucket is empty.
+func (t *table) allow(key string, rate float64, now time.Time) bool {
+	t.Lock()
+	defer t.Unlock()
+	e, ok := t.clients[key]
+	if !ok {
+		return true
+	}
+	c := e.Value.(*client)
+	c.tokens += now.Sub(c.last).Seconds() * rate
+	if c.tokens > rate {
+		c.tokens = rate
+	}
+	c.last = now
+	if c.tokens < 1 {
+		return false
+	}
+	c.tokens--
+	return true
+}
+
+// name accounts a query for name by key. It returns the reason when key is blocked
+// because of it.
+func (t *table) name(key, name string, now time.Time) reason {
+	if t.maxNames == 0 {
+		return none
+	}
+	t.Lock()
+	defer t.Unlock()
+	c := t.client(key, now)
+	if c == nil {
+		return none
+	}
+	if c.names == nil {
+		c.names = make(map[string]struct{})
+	}
+	// Past the limit the names don't matter anymore, this bounds the map.
+	if len(c.names) <= t.maxNames {
+		c.names[name] = struct{}{}
+	}
+	if c.estimate(c.prevNames, len(c.names), t.window, now) > float64(t.maxNames) {
+		t.blockClient(c, now)
+		return names
+	}
+	return none
+}
+
+// nxdomain accounts an NXDOMAIN response to key. It returns the reason when key is
+// blocked because of it.
+func (t *table) nxdomain(key string, now time.Time) reason {
+	if t.maxNX == 0 {
+		return none
+	}
+	t.Lock()
+	defer t.Unlock()
+	c := t.client(key, now)
+	if c == nil {
+		return none
+	}
+	c.nx++
+	if c.estimate(c.prevNX, c.nx, t.window, now) > float64(t.maxNX) {
+		t.blockClient(c, now)
+		return nxdomain
+	}
+	return none
+}
+
+// client returns the client for key, with its window rolled forward to now. It
+// returns nil when the table is full. The lock must be held.
+func (t *table) client(key string, now time.Time) *client {
+	e, ok := t.clients[key]
+	if !ok {
+		if len(t.clients) >= t.max {
+			t.evict(now)
+			if len(t.clients) >= t.max {
+				// Still full, don't track this client.
+				return nil
+			}
+		}
+		e = t.lru.PushFront(&client{key: key, start: now})
+		t.clients[key] = e
+	}
+	t.lru.MoveToFront(e)
+	c := e.Value.(*client)
+	c.roll(t.window, now)
+	return c
+}
+
+// blockClient blocks c from now on and starts counting afresh, so it isn't blocked
+// again right after. The lock must be held.
+func (t *table) blockClient(c *client, now time.Time) {
+	c.until = now.Add(t.block)
+	c.start = now
+	c.nx, c.prevNX = 0, 0
+	c.names, c.prevNames = nil, 0
+	c.tokens, c.last = 0, now
+}
+
+// evict removes the clients that aren't blocked and haven't been counted for two
+// windows, it looks at no more than evictScan clients from the back of the list.
+// Blocked clients are moved to the front, to be looked at again later. The lock must
+// be held.
+func (t *table) evict(now time.Time) {
+	for i := 0; i < evictScan; i++ {
+		e := t.lru.Back()
+		if e == nil {
+			return
+		}
+		c := e.Value.(*client)
+		if now.Sub(c.start) < 2*t.window {
+			// This and the clients in front of it were counted recently.
+			return
+		}
+		if now.Before(c.until) {
+			t.lru.MoveToFront(e)
+			continue
+		}
+		t.lru.Remove(e)
+		delete(t.clients, c.key)
+	}
+}
+
+// evictScan is the number of clients evict looks at.
+const evictScan = 16
+
+// len returns the number of clients in t.
+func (t *table) len() int {
+	t.Lock()
+	defer t.Unlock()
+	return len(t.clients)
+}
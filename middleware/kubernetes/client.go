@@ -0,0 +1,39 @@
+package kubernetes
+
+import (
+	"github.com/miekg/coredns/middleware/pkg/metadata"
+	"github.com/miekg/coredns/request"
+
+	"golang.org/x/net/context"
+	"k8s.io/kubernetes/pkg/api"
+)
+
+// The labels set on the queries of the pods, with ClientLabels.
+const (
+	labelClientNamespace = "kubernetes/client-namespace"
+	labelClientPod       = "kubernetes/client-pod-name"
+)
+
+// clientMetadata is a metadata.Provider that labels the query in state with the namespace
+// and name of the pod that sent it, found by the IP of the client. Queries from clients
+// that aren't a pod, or share their IP with other pods, like the pods on the host network,
+// get no labels; they are counted in the namespace "".
+func (k *Kubernetes) clientMetadata(ctx context.Context, state request.Request) {
+	p := k.clientPod(state.IP())
+	if p == nil {
+		clientRequestCount.WithLabelValues("").Inc()
+		return
+	}
+	metadata.Set(ctx, labelClientNamespace, p.Namespace)
+	metadata.Set(ctx, labelClientPod, p.Name)
+	clientRequestCount.WithLabelValues(p.Namespace).Inc()
+}
+
+// clientPod returns the only pod with ip, or nil.
+func (k *Kubernetes) clientPod(ip string) *api.Pod {
+	pods := k.APIConn.GetPodsByIP(ip)
+	if len(pods) != 1 {
+		return nil
+	}
+	return pods[0]
+}
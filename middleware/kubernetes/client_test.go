@@ -0,0 +1,45 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/miekg/coredns/middleware/pkg/metadata"
+	"github.com/miekg/coredns/middleware/test"
+	"github.com/miekg/coredns/request"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+	"k8s.io/kubernetes/pkg/api"
+)
+
+func TestClientMetadata(t *testing.T) {
+	// test.ResponseWriter has the client 10.240.0.1.
+	tests := []struct {
+		pods              []*api.Pod
+		expectedNamespace string
+		expectedPod       string
+	}{
+		{[]*api.Pod{pod("web-0", "testns", "10.240.0.1"), pod("web-1", "testns", "10.240.0.2")}, "testns", "web-0"},
+		{[]*api.Pod{pod("web-1", "testns", "10.240.0.2")}, "", ""},
+		// On the host network, the IP doesn't tell which pod it is.
+		{[]*api.Pod{pod("agent", "kube-system", "10.240.0.1"), pod("proxy", "kube-system", "10.240.0.1")}, "", ""},
+	}
+
+	for i, tc := range tests {
+		f := newFakeAPI()
+		f.pods = tc.pods
+		k := newTestKubernetes(f)
+
+		ctx := metadata.NewContext(context.TODO())
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		k.clientMetadata(ctx, request.Request{W: &test.ResponseWriter{}, Req: m})
+
+		if ns, _ := metadata.Value(ctx, labelClientNamespace); ns != tc.expectedNamespace {
+			t.Errorf("Test %d: expected namespace %q, got %q", i, tc.expectedNamespace, ns)
+		}
+		if p, _ := metadata.Value(ctx, labelClientPod); p != tc.expectedPod {
+			t.Errorf("Test %d: expected pod %q, got %q", i, tc.expectedPod, p)
+		}
+	}
+}
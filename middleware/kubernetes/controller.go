@@ -30,6 +30,19 @@ func (s *storeToNamespaceLister) List() (ns api.NamespaceList, err error) {
 	return ns, nil
 }
 
+// dnsControl gives access to the Kubernetes objects the middleware answers from.
+// dnsController implements it with caches that watch the API, the tests use a fake.
+type dnsControl interface {
+	GetServiceList() []*api.Service
+	GetEndpointsList() []*api.Endpoints
+	GetEndpoints(namespace, name string) *api.Endpoints
+	GetPodsByIP(ip string) []*api.Pod
+	GetNamespaceList() *api.NamespaceList
+
+	Run()
+	Stop() error
+}
+
 type dnsController struct {
 	client *client.Client
 
@@ -38,10 +51,12 @@ type dnsController struct {
 	endpController *cache.Controller
 	svcController  *cache.Controller
 	nsController   *cache.Controller
+	podController  *cache.Controller // nil when the pods are not watched
 
 	svcLister  cache.StoreToServiceLister
 	endpLister cache.StoreToEndpointsLister
 	nsLister   storeToNamespaceLister
+	podLister  cache.StoreToPodLister
 
 	// stopLock is used to enforce only a single call to Stop is active.
 	// Needed because we allow stopping through an http endpoint and
@@ -51,20 +66,31 @@ type dnsController struct {
 	stopCh   chan struct{}
 }
 
-// newDNSController creates a controller for coredns
-func newdnsController(kubeClient *client.Client, resyncPeriod time.Duration, lselector *labels.Selector) *dnsController {
+// newDNSController creates a controller for coredns. If changed is not nil, it is called
+// whenever a service or endpoints object is added, updated or deleted. The pods are only
+// watched with watchPods, there can be many of them.
+func newdnsController(kubeClient *client.Client, resyncPeriod time.Duration, lselector *labels.Selector, changed func(), watchPods bool) *dnsController {
 	dns := dnsController{
 		client:   kubeClient,
 		selector: lselector,
 		stopCh:   make(chan struct{}),
 	}
 
+	handler := cache.ResourceEventHandlerFuncs{}
+	if changed != nil {
+		handler = cache.ResourceEventHandlerFuncs{
+			AddFunc:    func(obj interface{}) { changed() },
+			UpdateFunc: func(oldObj, newObj interface{}) { changed() },
+			DeleteFunc: func(obj interface{}) { changed() },
+		}
+	}
+
 	dns.endpLister.Store, dns.endpController = cache.NewInformer(
 		&cache.ListWatch{
 			ListFunc:  endpointsListFunc(dns.client, namespace, dns.selector),
 			WatchFunc: endpointsWatchFunc(dns.client, namespace, dns.selector),
 		},
-		&api.Endpoints{}, resyncPeriod, cache.ResourceEventHandlerFuncs{})
+		&api.Endpoints{}, resyncPeriod, handler)
 
 	dns.svcLister.Indexer, dns.svcController = cache.NewIndexerInformer(
 		&cache.ListWatch{
@@ -73,7 +99,7 @@ func newdnsController(kubeClient *client.Client, resyncPeriod time.Duration, lse
 		},
 		&api.Service{},
 		resyncPeriod,
-		cache.ResourceEventHandlerFuncs{},
+		handler,
 		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
 
 	dns.nsLister.Store, dns.nsController = cache.NewInformer(
@@ -83,9 +109,32 @@ func newdnsController(kubeClient *client.Client, resyncPeriod time.Duration, lse
 		},
 		&api.Namespace{}, resyncPeriod, cache.ResourceEventHandlerFuncs{})
 
+	if watchPods {
+		dns.podLister.Indexer, dns.podController = cache.NewIndexerInformer(
+			&cache.ListWatch{
+				ListFunc:  podListFunc(dns.client, namespace, dns.selector),
+				WatchFunc: podWatchFunc(dns.client, namespace, dns.selector),
+			},
+			&api.Pod{},
+			resyncPeriod,
+			cache.ResourceEventHandlerFuncs{},
+			cache.Indexers{podIPIndex: podIPIndexFunc})
+	}
+
 	return &dns
 }
 
+// podIPIndex is the index of the pods by their IP.
+const podIPIndex = "podIP"
+
+func podIPIndexFunc(obj interface{}) ([]string, error) {
+	p, ok := obj.(*api.Pod)
+	if !ok {
+		return nil, fmt.Errorf("not a pod: %T", obj)
+	}
+	return []string{p.Status.PodIP}, nil
+}
+
 func serviceListFunc(c *client.Client, ns string, s *labels.Selector) func(api.ListOptions) (runtime.Object, error) {
 	return func(opts api.ListOptions) (runtime.Object, error) {
 		if s != nil {
@@ -122,6 +171,24 @@ func endpointsWatchFunc(c *client.Client, ns string, s *labels.Selector) func(op
 	}
 }
 
+func podListFunc(c *client.Client, ns string, s *labels.Selector) func(api.ListOptions) (runtime.Object, error) {
+	return func(opts api.ListOptions) (runtime.Object, error) {
+		if s != nil {
+			opts.LabelSelector = *s
+		}
+		return c.Pods(ns).List(opts)
+	}
+}
+
+func podWatchFunc(c *client.Client, ns string, s *labels.Selector) func(options api.ListOptions) (watch.Interface, error) {
+	return func(options api.ListOptions) (watch.Interface, error) {
+		if s != nil {
+			options.LabelSelector = *s
+		}
+		return c.Pods(ns).Watch(options)
+	}
+}
+
 func namespaceListFunc(c *client.Client, s *labels.Selector) func(api.ListOptions) (runtime.Object, error) {
 	return func(opts api.ListOptions) (runtime.Object, error) {
 		if s != nil {
@@ -141,7 +208,8 @@ func namespaceWatchFunc(c *client.Client, s *labels.Selector) func(options api.L
 }
 
 func (dns *dnsController) controllersInSync() bool {
-	return dns.svcController.HasSynced() && dns.endpController.HasSynced()
+	return dns.svcController.HasSynced() && dns.endpController.HasSynced() &&
+		(dns.podController == nil || dns.podController.HasSynced())
 }
 
 // Stop stops the  controller.
@@ -165,6 +233,9 @@ func (dns *dnsController) Run() {
 	go dns.endpController.Run(dns.stopCh)
 	go dns.svcController.Run(dns.stopCh)
 	go dns.nsController.Run(dns.stopCh)
+	if dns.podController != nil {
+		go dns.podController.Run(dns.stopCh)
+	}
 	<-dns.stopCh
 }
 
@@ -186,6 +257,41 @@ func (dns *dnsController) GetServiceList() []*api.Service {
 	return svcs
 }
 
+// GetEndpointsList returns the endpoints of all services.
+func (dns *dnsController) GetEndpointsList() []*api.Endpoints {
+	eps := []*api.Endpoints{}
+	for _, m := range dns.endpLister.Store.List() {
+		eps = append(eps, m.(*api.Endpoints))
+	}
+	return eps
+}
+
+// GetEndpoints returns the endpoints of the service name in namespace, or nil when the
+// service has none.
+func (dns *dnsController) GetEndpoints(namespace, name string) *api.Endpoints {
+	m, exists, err := dns.endpLister.Store.GetByKey(namespace + "/" + name)
+	if err != nil || !exists {
+		return nil
+	}
+	return m.(*api.Endpoints)
+}
+
+// GetPodsByIP returns the pods with ip. It returns nil when the pods are not watched.
+func (dns *dnsController) GetPodsByIP(ip string) []*api.Pod {
+	if dns.podController == nil {
+		return nil
+	}
+	objs, err := dns.podLister.Indexer.ByIndex(podIPIndex, ip)
+	if err != nil {
+		return nil
+	}
+	pods := make([]*api.Pod, 0, len(objs))
+	for _, o := range objs {
+		pods = append(pods, o.(*api.Pod))
+	}
+	return pods
+}
+
 // GetServicesByNamespace returns a map of
 // namespacename :: [ kubernetesService ]
 func (dns *dnsController) GetServicesByNamespace() map[string][]api.Service {
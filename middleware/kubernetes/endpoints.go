@@ -0,0 +1,52 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"k8s.io/kubernetes/pkg/api"
+)
+
+// hostnamesAnnotation is the annotation of Endpoints that holds the hostnames of the pods,
+// by IP, as set for PetSets before EndpointAddress had a Hostname. Its value looks like
+// {"10.244.0.10":{"HostName":"web-0"}}.
+const hostnamesAnnotation = "endpoints.beta.kubernetes.io/hostnames-map"
+
+// annotatedHostnames returns the hostnames of the hostnames annotation of ep, by IP. It
+// returns nil if ep has none, or if the annotation can't be parsed.
+func annotatedHostnames(ep *api.Endpoints) map[string]string {
+	s, ok := ep.Annotations[hostnamesAnnotation]
+	if !ok {
+		return nil
+	}
+	var records map[string]struct{ HostName string }
+	if err := json.Unmarshal([]byte(s), &records); err != nil {
+		return nil
+	}
+	hostnames := make(map[string]string, len(records))
+	for ip, r := range records {
+		hostnames[ip] = r.HostName
+	}
+	return hostnames
+}
+
+// endpointHostname returns the name of the endpoint addr in its service, as kube-dns does:
+// the hostname of the pod when it has one, from addr or else from hostnames, and its IP
+// with dashes when not. A hostname that isn't a valid DNS label is not used.
+func endpointHostname(addr api.EndpointAddress, hostnames map[string]string) string {
+	if isLabel(addr.Hostname) {
+		return addr.Hostname
+	}
+	if h := hostnames[addr.IP]; isLabel(h) {
+		return h
+	}
+	return strings.NewReplacer(".", "-", ":", "-").Replace(addr.IP)
+}
+
+// isLabel returns true if s is a valid DNS label, as Kubernetes validates them (RFC 1123).
+func isLabel(s string) bool {
+	return len(s) <= 63 && labelRegexp.MatchString(s)
+}
+
+var labelRegexp = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
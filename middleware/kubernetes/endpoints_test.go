@@ -0,0 +1,35 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"k8s.io/kubernetes/pkg/api"
+)
+
+func TestEndpointHostname(t *testing.T) {
+	ep := endpoints("web", "testns")
+	ep.Annotations = map[string]string{hostnamesAnnotation: `{"10.0.0.2":{"HostName":"web-1"},"10.0.0.3":{"HostName":"-web"}}`}
+	hostnames := annotatedHostnames(ep)
+
+	tests := []struct {
+		addr     api.EndpointAddress
+		expected string
+	}{
+		{api.EndpointAddress{IP: "10.0.0.1", Hostname: "web-0"}, "web-0"},
+		{api.EndpointAddress{IP: "10.0.0.2"}, "web-1"},
+		{api.EndpointAddress{IP: "10.0.0.2", Hostname: "web-2"}, "web-2"},
+		{api.EndpointAddress{IP: "10.0.0.3"}, "10-0-0-3"},
+		{api.EndpointAddress{IP: "10.0.0.4", Hostname: "Web.0"}, "10-0-0-4"},
+		{api.EndpointAddress{IP: "fd00::1"}, "fd00--1"},
+	}
+	for i, tc := range tests {
+		if h := endpointHostname(tc.addr, hostnames); h != tc.expected {
+			t.Errorf("Test %d: expected %s, got %s", i, tc.expected, h)
+		}
+	}
+
+	ep.Annotations[hostnamesAnnotation] = "not json"
+	if hostnames := annotatedHostnames(ep); hostnames != nil {
+		t.Errorf("Expected no hostnames for a broken annotation, got %v", hostnames)
+	}
+}
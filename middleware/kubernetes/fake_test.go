@@ -0,0 +1,111 @@
+package kubernetes
+
+import (
+	"github.com/miekg/coredns/middleware/kubernetes/nametemplate"
+
+	"k8s.io/kubernetes/pkg/api"
+)
+
+// fakeAPI is a dnsControl that serves a fixed set of Kubernetes objects, so the lookups can
+// be tested without a cluster.
+type fakeAPI struct {
+	services   []*api.Service
+	endpoints  []*api.Endpoints
+	namespaces []api.Namespace
+	pods       []*api.Pod
+}
+
+func (f *fakeAPI) GetServiceList() []*api.Service     { return f.services }
+func (f *fakeAPI) GetEndpointsList() []*api.Endpoints { return f.endpoints }
+
+func (f *fakeAPI) GetEndpoints(namespace, name string) *api.Endpoints {
+	for _, ep := range f.endpoints {
+		if ep.Namespace == namespace && ep.Name == name {
+			return ep
+		}
+	}
+	return nil
+}
+
+func (f *fakeAPI) GetPodsByIP(ip string) []*api.Pod {
+	var pods []*api.Pod
+	for _, p := range f.pods {
+		if p.Status.PodIP == ip {
+			pods = append(pods, p)
+		}
+	}
+	return pods
+}
+
+func (f *fakeAPI) GetNamespaceList() *api.NamespaceList {
+	return &api.NamespaceList{Items: f.namespaces}
+}
+
+func (f *fakeAPI) Run()        {}
+func (f *fakeAPI) Stop() error { return nil }
+
+// newFakeAPI returns a fakeAPI that serves services, their namespaces are created as well.
+func newFakeAPI(services ...*api.Service) *fakeAPI {
+	f := &fakeAPI{services: services}
+	seen := map[string]bool{}
+	for _, s := range services {
+		if !seen[s.Namespace] {
+			f.namespaces = append(f.namespaces, api.Namespace{ObjectMeta: api.ObjectMeta{Name: s.Namespace}})
+			seen[s.Namespace] = true
+		}
+	}
+	return f
+}
+
+// service returns the Service name in namespace ns, with clusterIP and the TCP ports.
+func service(name, ns, clusterIP string, ports ...int32) *api.Service {
+	s := &api.Service{
+		ObjectMeta: api.ObjectMeta{Name: name, Namespace: ns},
+		Spec:       api.ServiceSpec{ClusterIP: clusterIP},
+	}
+	for _, p := range ports {
+		s.Spec.Ports = append(s.Spec.Ports, api.ServicePort{Port: p, Protocol: api.ProtocolTCP})
+	}
+	return s
+}
+
+// serviceWithPorts returns the Service name in namespace ns, with clusterIP and ports.
+func serviceWithPorts(name, ns, clusterIP string, ports ...api.ServicePort) *api.Service {
+	s := service(name, ns, clusterIP)
+	s.Spec.Ports = ports
+	return s
+}
+
+// externalService returns the ExternalName Service name in namespace ns, an alias for
+// externalName.
+func externalService(name, ns, externalName string) *api.Service {
+	return &api.Service{
+		ObjectMeta: api.ObjectMeta{Name: name, Namespace: ns},
+		Spec:       api.ServiceSpec{Type: api.ServiceTypeExternalName, ExternalName: externalName},
+	}
+}
+
+// endpoints returns the Endpoints of the service name in namespace ns, with the pods at addrs.
+func endpoints(name, ns string, addrs ...api.EndpointAddress) *api.Endpoints {
+	return &api.Endpoints{
+		ObjectMeta: api.ObjectMeta{Name: name, Namespace: ns},
+		Subsets:    []api.EndpointSubset{{Addresses: addrs}},
+	}
+}
+
+// pod returns the Pod name in namespace ns, with ip.
+func pod(name, ns, ip string) *api.Pod {
+	return &api.Pod{
+		ObjectMeta: api.ObjectMeta{Name: name, Namespace: ns},
+		Status:     api.PodStatus{PodIP: ip},
+	}
+}
+
+// newTestKubernetes returns a Kubernetes for zone cluster.local. that uses conn and only
+// exposes namespaces, if given.
+func newTestKubernetes(conn dnsControl, namespaces ...string) Kubernetes {
+	k := Kubernetes{Zones: []string{"cluster.local."}, APIConn: conn, Namespaces: namespaces, TTL: defaultTTL}
+	k.NameTemplate = new(nametemplate.NameTemplate)
+	k.NameTemplate.SetTemplate(defaultNameTemplate)
+	return k
+}
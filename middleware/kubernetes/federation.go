@@ -0,0 +1,87 @@
+package kubernetes
+
+import (
+	"strings"
+
+	"github.com/miekg/coredns/middleware/pkg/cname"
+	"github.com/miekg/coredns/middleware/pkg/dnsutil"
+	"github.com/miekg/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// federated returns, for a name that has a federation label right above zone, like
+// myservice.mynamespace.east.coredns.local., the name of the service in this cluster,
+// myservice.mynamespace.coredns.local., and the name in the zone of the federation,
+// myservice.mynamespace.east.example.org. when east is federated to east.example.org.
+func (k Kubernetes) federated(name, zone string) (local, remote string, ok bool) {
+	if len(k.Federations) == 0 {
+		return "", "", false
+	}
+	labels := dns.SplitDomainName(name)
+	n := len(labels) - dns.CountLabel(zone)
+	if n < 2 {
+		return "", "", false
+	}
+	domain, ok := k.Federations[strings.ToLower(labels[n-1])]
+	if !ok {
+		return "", "", false
+	}
+	prefix := strings.Join(labels[:n-1], ".") + "."
+	return prefix + zone, prefix + domain, true
+}
+
+// Federation answers a query for a federated name with a CNAME: to the service in this
+// cluster if it exists, followed to its records, or else to the name in the zone of the
+// federation, which is only followed with an upstream.
+func (k Kubernetes) Federation(zone, local, remote string, state request.Request) (int, error) {
+	target := remote
+	_, err := k.Records(local, false)
+	if err == nil {
+		target = local
+	} else if !isKubernetesNameError(err) {
+		return dns.RcodeServerFailure, err
+	}
+
+	c := &dns.CNAME{
+		Hdr:    dns.RR_Header{Name: state.QName(), Rrtype: dns.TypeCNAME, Class: dns.ClassINET, Ttl: k.TTL},
+		Target: target,
+	}
+	m := new(dns.Msg)
+	m.SetReply(state.Req)
+	m.Authoritative, m.RecursionAvailable, m.Compress = true, true, true
+	m.Answer = []dns.RR{c}
+
+	qtype := state.QType()
+	switch {
+	case qtype == dns.TypeCNAME:
+	case target == local:
+		req := state.Req.Copy()
+		req.Question[0].Name = local
+		state1 := request.Request{W: state.W, Req: req}
+
+		var records, extra []dns.RR
+		switch qtype {
+		case dns.TypeA:
+			records, err = k.A(zone, state1, []dns.RR{c})
+		case dns.TypeAAAA:
+			records, err = k.AAAA(zone, state1, []dns.RR{c})
+		case dns.TypeSRV:
+			records, extra, err = k.SRV(zone, state1)
+		}
+		if cname.IsError(err) {
+			return dns.RcodeServerFailure, err
+		}
+		m.Answer = append(m.Answer, records...)
+		m.Extra = append(m.Extra, extra...)
+	case (qtype == dns.TypeA || qtype == dns.TypeAAAA) && k.hasUpstream():
+		if m1, err := k.Proxy.Lookup(state, target, qtype); err == nil {
+			m.Answer = append(m.Answer, m1.Answer...)
+		}
+	}
+
+	m = dnsutil.Dedup(m)
+	state.SizeAndDo(m)
+	state.W.WriteMsg(m)
+	return dns.RcodeSuccess, nil
+}
@@ -2,8 +2,10 @@ package kubernetes
 
 import (
 	"fmt"
+	"net"
 
 	"github.com/miekg/coredns/middleware"
+	"github.com/miekg/coredns/middleware/pkg/cname"
 	"github.com/miekg/coredns/middleware/pkg/dnsutil"
 	"github.com/miekg/coredns/request"
 
@@ -18,29 +20,38 @@ func (k Kubernetes) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.M
 		return dns.RcodeServerFailure, fmt.Errorf("can only deal with ClassINET")
 	}
 
+	k.maxCNAMEDepth = cname.MaxDepth(ctx)
+
 	m := new(dns.Msg)
 	m.SetReply(r)
 	m.Authoritative, m.RecursionAvailable, m.Compress = true, true, true
 
-	// TODO: find an alternative to this block
-	ip := dnsutil.ExtractAddressFromReverse(state.Name())
-	if ip != "" {
-		records := k.getServiceRecordForIP(ip, state.Name())
-		if len(records) > 0 {
-			srvPTR := &records[0]
-			m.Answer = append(m.Answer, srvPTR.NewPTR(state.QName(), ip))
+	zone := middleware.Zones(k.Zones).Matches(state.Name())
 
+	// Reverse lookups for the IPs of services and pods are answered, even when the reverse
+	// zone isn't one of ours; those for other IPs only in our reverse zones.
+	if ip := dnsutil.ExtractAddressFromReverse(state.Name()); net.ParseIP(ip) != nil {
+		records := k.PTR(state)
+		if len(records) == 0 && zone != "" {
+			return k.Err(zone, dns.RcodeNameError, state)
+		}
+		if len(records) > 0 {
+			if state.QType() == dns.TypePTR {
+				m.Answer = append(m.Answer, records...)
+			}
 			m = dnsutil.Dedup(m)
 			state.SizeAndDo(m)
-			m, _ = state.Scrub(m)
 			w.WriteMsg(m)
 			return dns.RcodeSuccess, nil
 		}
 	}
+	// The other names in our reverse zones have no records, the SOA of the zone aside.
+	if isReverseZone(zone) && (state.QType() != dns.TypeSOA || state.Name() != zone) {
+		return k.Err(zone, dns.RcodeSuccess, state)
+	}
 
 	// Check that query matches one of the zones served by this middleware,
 	// otherwise delegate to the next in the pipeline.
-	zone := middleware.Zones(k.Zones).Matches(state.Name())
 	if zone == "" {
 		if k.Next == nil {
 			return dns.RcodeServerFailure, nil
@@ -48,6 +59,10 @@ func (k Kubernetes) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.M
 		return k.Next.ServeDNS(ctx, w, r)
 	}
 
+	if local, remote, ok := k.federated(state.Name(), zone); ok {
+		return k.Federation(zone, local, remote, state)
+	}
+
 	var (
 		records, extra []dns.RR
 		err            error
@@ -80,10 +95,25 @@ func (k Kubernetes) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.M
 		// Do a fake A lookup, so we can distinguish between NODATA and NXDOMAIN
 		_, err = k.A(zone, state, nil)
 	}
+	// The types we have no records for still need to tell NODATA from NXDOMAIN.
+	if err == nil && len(records) == 0 {
+		switch state.QType() {
+		case dns.TypeTXT, dns.TypeMX:
+			_, err = k.A(zone, state, nil)
+		}
+	}
 	if isKubernetesNameError(err) {
+		// The zone itself always exists, even without any services.
+		if state.Name() == zone {
+			return k.Err(zone, dns.RcodeSuccess, state)
+		}
+		if k.Fallthrough && k.Next != nil {
+			return k.Next.ServeDNS(ctx, w, r)
+		}
 		return k.Err(zone, dns.RcodeNameError, state)
 	}
 	if err != nil {
+		// The server writes the SERVFAIL, with the error if it's a CNAME loop.
 		return dns.RcodeServerFailure, err
 	}
 
@@ -96,7 +126,6 @@ func (k Kubernetes) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.M
 
 	m = dnsutil.Dedup(m)
 	state.SizeAndDo(m)
-	m, _ = state.Scrub(m)
 	w.WriteMsg(m)
 	return dns.RcodeSuccess, nil
 }
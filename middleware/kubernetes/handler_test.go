@@ -0,0 +1,549 @@
+package kubernetes
+
+import (
+	"io/ioutil"
+	"log"
+	"sort"
+	"testing"
+
+	"github.com/miekg/coredns/middleware/pkg/cname"
+	"github.com/miekg/coredns/middleware/pkg/dnsrecorder"
+	"github.com/miekg/coredns/middleware/proxy"
+	"github.com/miekg/coredns/middleware/test"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+	"k8s.io/kubernetes/pkg/api"
+)
+
+var fakeServices = newFakeAPI(
+	service("svc1", "testns", "10.0.0.1", 80, 443),
+	service("svc2", "testns", "10.0.0.2", 53),
+	service("svc6", "testns", "1234:abcd::1", 80),
+	service("svc1", "otherns", "10.0.1.1", 80),
+)
+
+var dnsTestCases = []test.Case{
+	{
+		Qname: "svc1.testns.cluster.local.", Qtype: dns.TypeA,
+		Answer: []dns.RR{test.A("svc1.testns.cluster.local. 303 IN A 10.0.0.1")},
+	},
+	{
+		Qname: "svc6.testns.cluster.local.", Qtype: dns.TypeAAAA,
+		Answer: []dns.RR{test.AAAA("svc6.testns.cluster.local. 303 IN AAAA 1234:abcd::1")},
+	},
+	// An IPv6 service has no A records.
+	{
+		Qname: "svc6.testns.cluster.local.", Qtype: dns.TypeA,
+		Ns: []dns.RR{test.SOA("cluster.local. 5 IN SOA ns.dns.cluster.local. hostmaster.cluster.local. 1 7200 1800 86400 5")},
+	},
+	{
+		Qname: "nosvc.testns.cluster.local.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeNameError,
+		Ns:    []dns.RR{test.SOA("cluster.local. 5 IN SOA ns.dns.cluster.local. hostmaster.cluster.local. 1 7200 1800 86400 5")},
+	},
+	// Types without records tell NODATA from NXDOMAIN as well.
+	{
+		Qname: "svc1.testns.cluster.local.", Qtype: dns.TypeTXT,
+		Ns: []dns.RR{test.SOA("cluster.local. 5 IN SOA ns.dns.cluster.local. hostmaster.cluster.local. 1 7200 1800 86400 5")},
+	},
+	{
+		Qname: "nosvc.testns.cluster.local.", Qtype: dns.TypeTXT,
+		Rcode: dns.RcodeNameError,
+		Ns:    []dns.RR{test.SOA("cluster.local. 5 IN SOA ns.dns.cluster.local. hostmaster.cluster.local. 1 7200 1800 86400 5")},
+	},
+	// The zone exists, whatever is in it.
+	{
+		Qname: "cluster.local.", Qtype: dns.TypeNS,
+		Ns: []dns.RR{test.SOA("cluster.local. 5 IN SOA ns.dns.cluster.local. hostmaster.cluster.local. 1 7200 1800 86400 5")},
+	},
+	// Wildcards
+	{
+		Qname: "*.testns.cluster.local.", Qtype: dns.TypeA,
+		Answer: []dns.RR{
+			test.A("*.testns.cluster.local. 303 IN A 10.0.0.1"),
+			test.A("*.testns.cluster.local. 303 IN A 10.0.0.2"),
+		},
+	},
+	{
+		Qname: "svc1.*.cluster.local.", Qtype: dns.TypeA,
+		Answer: []dns.RR{
+			test.A("svc1.*.cluster.local. 303 IN A 10.0.0.1"),
+			test.A("svc1.*.cluster.local. 303 IN A 10.0.1.1"),
+		},
+	},
+	// Reverse lookup of a cluster IP
+	{
+		Qname: "1.0.0.10.in-addr.arpa.", Qtype: dns.TypePTR,
+		Answer: []dns.RR{test.PTR("1.0.0.10.in-addr.arpa. 303 IN PTR svc1.testns.cluster.local.")},
+	},
+	{
+		Qname: "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.d.c.b.a.4.3.2.1.ip6.arpa.", Qtype: dns.TypePTR,
+		Answer: []dns.RR{test.PTR("1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.d.c.b.a.4.3.2.1.ip6.arpa. 303 IN PTR svc6.testns.cluster.local.")},
+	},
+}
+
+func TestServeDNS(t *testing.T) {
+	runTestCases(t, newTestKubernetes(fakeServices), dnsTestCases)
+}
+
+func TestServeDNSTTL(t *testing.T) {
+	k := newTestKubernetes(fakeServices)
+	k.TTL = 30
+	runTestCases(t, k, []test.Case{
+		{
+			Qname: "svc1.testns.cluster.local.", Qtype: dns.TypeA,
+			Answer: []dns.RR{test.A("svc1.testns.cluster.local. 30 IN A 10.0.0.1")},
+		},
+		{
+			Qname: "nosvc.testns.cluster.local.", Qtype: dns.TypeA,
+			Rcode: dns.RcodeNameError,
+			Ns:    []dns.RR{test.SOA("cluster.local. 30 IN SOA ns.dns.cluster.local. hostmaster.cluster.local. 1 7200 1800 86400 30")},
+		},
+	})
+}
+
+var namespaceTestCases = []test.Case{
+	{
+		Qname: "svc1.testns.cluster.local.", Qtype: dns.TypeA,
+		Answer: []dns.RR{test.A("svc1.testns.cluster.local. 303 IN A 10.0.0.1")},
+	},
+	{
+		Qname: "svc1.otherns.cluster.local.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeNameError,
+		Ns:    []dns.RR{test.SOA("cluster.local. 5 IN SOA ns.dns.cluster.local. hostmaster.cluster.local. 1 7200 1800 86400 5")},
+	},
+	{
+		Qname: "svc1.*.cluster.local.", Qtype: dns.TypeA,
+		Answer: []dns.RR{test.A("svc1.*.cluster.local. 303 IN A 10.0.0.1")},
+	},
+}
+
+func TestServeDNSNamespaces(t *testing.T) {
+	runTestCases(t, newTestKubernetes(fakeServices, "testns"), namespaceTestCases)
+}
+
+var fakePods = newFakeAPI(
+	service("svc1", "testns", "10.0.0.1", 80),
+	service("headless", "testns", "None", 80),
+)
+
+func init() {
+	fakePods.endpoints = []*api.Endpoints{
+		endpoints("svc1", "testns", api.EndpointAddress{IP: "10.244.0.5"}),
+		endpoints("headless", "testns", api.EndpointAddress{IP: "10.244.0.6", Hostname: "web-0"}),
+	}
+}
+
+var reverseTestCases = []test.Case{
+	{
+		Qname: "1.0.0.10.in-addr.arpa.", Qtype: dns.TypePTR,
+		Answer: []dns.RR{test.PTR("1.0.0.10.in-addr.arpa. 303 IN PTR svc1.testns.cluster.local.")},
+	},
+	{
+		Qname: "5.0.244.10.in-addr.arpa.", Qtype: dns.TypePTR,
+		Answer: []dns.RR{test.PTR("5.0.244.10.in-addr.arpa. 303 IN PTR 10-244-0-5.svc1.testns.cluster.local.")},
+	},
+	{
+		Qname: "6.0.244.10.in-addr.arpa.", Qtype: dns.TypePTR,
+		Answer: []dns.RR{test.PTR("6.0.244.10.in-addr.arpa. 303 IN PTR web-0.headless.testns.cluster.local.")},
+	},
+	// A name in our reverse zone without an answer, and another type.
+	{
+		Qname: "7.0.244.10.in-addr.arpa.", Qtype: dns.TypePTR,
+		Rcode: dns.RcodeNameError,
+		Ns:    []dns.RR{test.SOA("10.in-addr.arpa. 5 IN SOA ns.dns.10.in-addr.arpa. hostmaster.10.in-addr.arpa. 1 7200 1800 86400 5")},
+	},
+	{
+		Qname: "5.0.244.10.in-addr.arpa.", Qtype: dns.TypeA,
+	},
+}
+
+func TestServeDNSReverse(t *testing.T) {
+	k := newTestKubernetes(fakePods)
+	k.Zones = append(k.Zones, "10.in-addr.arpa.")
+	k.ReversePods = true
+	runTestCases(t, k, reverseTestCases)
+}
+
+var fakePorts = newFakeAPI(
+	serviceWithPorts("web", "testns", "10.0.0.10",
+		api.ServicePort{Name: "http", Port: 80, Protocol: api.ProtocolTCP},
+		api.ServicePort{Name: "dns", Port: 53, Protocol: api.ProtocolUDP}),
+	serviceWithPorts("nginx", "testns", api.ClusterIPNone,
+		api.ServicePort{Name: "http", Port: 80, Protocol: api.ProtocolTCP}),
+)
+
+func init() {
+	ep := endpoints("nginx", "testns",
+		api.EndpointAddress{IP: "10.244.0.10", Hostname: "web-0"},
+		api.EndpointAddress{IP: "10.244.0.11"})
+	ep.Subsets[0].Ports = []api.EndpointPort{{Name: "http", Port: 8080, Protocol: api.ProtocolTCP}}
+	fakePorts.endpoints = []*api.Endpoints{ep}
+}
+
+var srvTestCases = []test.Case{
+	{
+		Qname: "_http._tcp.web.testns.cluster.local.", Qtype: dns.TypeSRV,
+		Answer: []dns.RR{test.SRV("_http._tcp.web.testns.cluster.local. 303 IN SRV 0 100 80 web.testns.cluster.local.")},
+		Extra:  []dns.RR{test.A("web.testns.cluster.local. 303 IN A 10.0.0.10")},
+	},
+	{
+		Qname: "_dns._udp.web.testns.cluster.local.", Qtype: dns.TypeSRV,
+		Answer: []dns.RR{test.SRV("_dns._udp.web.testns.cluster.local. 303 IN SRV 0 100 53 web.testns.cluster.local.")},
+		Extra:  []dns.RR{test.A("web.testns.cluster.local. 303 IN A 10.0.0.10")},
+	},
+	{
+		Qname: "_http._udp.web.testns.cluster.local.", Qtype: dns.TypeSRV,
+		Rcode: dns.RcodeNameError,
+		Ns:    []dns.RR{test.SOA("cluster.local. 5 IN SOA ns.dns.cluster.local. hostmaster.cluster.local. 1 7200 1800 86400 5")},
+	},
+	// A headless service has the ports of its endpoints, and a name for each of them.
+	{
+		Qname: "_http._tcp.nginx.testns.cluster.local.", Qtype: dns.TypeSRV,
+		Answer: []dns.RR{
+			test.SRV("_http._tcp.nginx.testns.cluster.local. 303 IN SRV 0 50 8080 10-244-0-11.nginx.testns.cluster.local."),
+			test.SRV("_http._tcp.nginx.testns.cluster.local. 303 IN SRV 0 50 8080 web-0.nginx.testns.cluster.local."),
+		},
+		Extra: []dns.RR{
+			test.A("10-244-0-11.nginx.testns.cluster.local. 303 IN A 10.244.0.11"),
+			test.A("web-0.nginx.testns.cluster.local. 303 IN A 10.244.0.10"),
+		},
+	},
+	{
+		Qname: "web-0.nginx.testns.cluster.local.", Qtype: dns.TypeA,
+		Answer: []dns.RR{test.A("web-0.nginx.testns.cluster.local. 303 IN A 10.244.0.10")},
+	},
+	{
+		Qname: "nginx.testns.cluster.local.", Qtype: dns.TypeA,
+		Answer: []dns.RR{
+			test.A("nginx.testns.cluster.local. 303 IN A 10.244.0.10"),
+			test.A("nginx.testns.cluster.local. 303 IN A 10.244.0.11"),
+		},
+	},
+	{
+		Qname: "web-1.nginx.testns.cluster.local.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeNameError,
+		Ns:    []dns.RR{test.SOA("cluster.local. 5 IN SOA ns.dns.cluster.local. hostmaster.cluster.local. 1 7200 1800 86400 5")},
+	},
+}
+
+func TestServeDNSSRV(t *testing.T) {
+	runTestCases(t, newTestKubernetes(fakePorts), srvTestCases)
+}
+
+var fakeStatefulSet = newFakeAPI(
+	service("web", "testns", api.ClusterIPNone),
+	service("db", "testns", api.ClusterIPNone),
+)
+
+func init() {
+	web := endpoints("web", "testns",
+		api.EndpointAddress{IP: "10.244.1.10", Hostname: "web-0"},
+		api.EndpointAddress{IP: "10.244.1.11", Hostname: "web-1"})
+	// Not ready pods have no records.
+	web.Subsets[0].NotReadyAddresses = []api.EndpointAddress{{IP: "10.244.1.12", Hostname: "web-2"}}
+	// Hostnames from the annotation of older clusters.
+	db := endpoints("db", "testns", api.EndpointAddress{IP: "10.244.2.10"}, api.EndpointAddress{IP: "10.244.2.11"})
+	db.Annotations = map[string]string{hostnamesAnnotation: `{"10.244.2.10":{"HostName":"db-0"},"10.244.2.11":{"HostName":"Not_A_Label"}}`}
+	fakeStatefulSet.endpoints = []*api.Endpoints{web, db}
+}
+
+var statefulSetTestCases = []test.Case{
+	{
+		Qname: "web.testns.svc.cluster.local.", Qtype: dns.TypeA,
+		Answer: []dns.RR{
+			test.A("web.testns.svc.cluster.local. 303 IN A 10.244.1.10"),
+			test.A("web.testns.svc.cluster.local. 303 IN A 10.244.1.11"),
+		},
+	},
+	{
+		Qname: "web-1.web.testns.svc.cluster.local.", Qtype: dns.TypeA,
+		Answer: []dns.RR{test.A("web-1.web.testns.svc.cluster.local. 303 IN A 10.244.1.11")},
+	},
+	{
+		Qname: "web-2.web.testns.svc.cluster.local.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeNameError,
+		Ns:    []dns.RR{test.SOA("cluster.local. 5 IN SOA ns.dns.cluster.local. hostmaster.cluster.local. 1 7200 1800 86400 5")},
+	},
+	{
+		Qname: "db-0.db.testns.svc.cluster.local.", Qtype: dns.TypeA,
+		Answer: []dns.RR{test.A("db-0.db.testns.svc.cluster.local. 303 IN A 10.244.2.10")},
+	},
+	{
+		Qname: "10-244-2-11.db.testns.svc.cluster.local.", Qtype: dns.TypeA,
+		Answer: []dns.RR{test.A("10-244-2-11.db.testns.svc.cluster.local. 303 IN A 10.244.2.11")},
+	},
+	{
+		Qname: "11.2.244.10.in-addr.arpa.", Qtype: dns.TypePTR,
+		Answer: []dns.RR{test.PTR("11.2.244.10.in-addr.arpa. 303 IN PTR 10-244-2-11.db.testns.svc.cluster.local.")},
+	},
+}
+
+func TestServeDNSStatefulSet(t *testing.T) {
+	k := newTestKubernetes(fakeStatefulSet)
+	k.NameTemplate.SetTemplate("{service}.{namespace}.{type}.{zone}")
+	k.ReversePods = true
+	runTestCases(t, k, statefulSetTestCases)
+}
+
+var fakeNamespacePods = newFakeAPI(service("svc1", "testns", "10.0.0.1", 80))
+
+func init() {
+	fakeNamespacePods.pods = []*api.Pod{
+		pod("web-0", "testns", "10.244.0.5"),
+		pod("web-1", "testns", "fd00::5"),
+	}
+}
+
+var podInsecureTestCases = []test.Case{
+	{
+		Qname: "10-244-0-5.testns.pod.cluster.local.", Qtype: dns.TypeA,
+		Answer: []dns.RR{test.A("10-244-0-5.testns.pod.cluster.local. 303 IN A 10.244.0.5")},
+	},
+	{
+		Qname: "10-244-0-6.testns.pod.cluster.local.", Qtype: dns.TypeA,
+		Answer: []dns.RR{test.A("10-244-0-6.testns.pod.cluster.local. 303 IN A 10.244.0.6")},
+	},
+	{
+		Qname: "fd00--5.testns.pod.cluster.local.", Qtype: dns.TypeAAAA,
+		Answer: []dns.RR{test.AAAA("fd00--5.testns.pod.cluster.local. 303 IN AAAA fd00::5")},
+	},
+	{
+		Qname: "web-0.testns.pod.cluster.local.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeNameError,
+		Ns:    []dns.RR{test.SOA("cluster.local. 5 IN SOA ns.dns.cluster.local. hostmaster.cluster.local. 1 7200 1800 86400 5")},
+	},
+	{
+		Qname: "10-244-0-5.*.pod.cluster.local.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeNameError,
+		Ns:    []dns.RR{test.SOA("cluster.local. 5 IN SOA ns.dns.cluster.local. hostmaster.cluster.local. 1 7200 1800 86400 5")},
+	},
+}
+
+var podVerifiedTestCases = []test.Case{
+	{
+		Qname: "10-244-0-5.testns.pod.cluster.local.", Qtype: dns.TypeA,
+		Answer: []dns.RR{test.A("10-244-0-5.testns.pod.cluster.local. 303 IN A 10.244.0.5")},
+	},
+	// No pod with this IP, or not in this namespace.
+	{
+		Qname: "10-244-0-6.testns.pod.cluster.local.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeNameError,
+		Ns:    []dns.RR{test.SOA("cluster.local. 5 IN SOA ns.dns.cluster.local. hostmaster.cluster.local. 1 7200 1800 86400 5")},
+	},
+	{
+		Qname: "10-244-0-5.otherns.pod.cluster.local.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeNameError,
+		Ns:    []dns.RR{test.SOA("cluster.local. 5 IN SOA ns.dns.cluster.local. hostmaster.cluster.local. 1 7200 1800 86400 5")},
+	},
+	{
+		Qname: "fd00--5.testns.pod.cluster.local.", Qtype: dns.TypeAAAA,
+		Answer: []dns.RR{test.AAAA("fd00--5.testns.pod.cluster.local. 303 IN AAAA fd00::5")},
+	},
+}
+
+func TestServeDNSPods(t *testing.T) {
+	k := newTestKubernetes(fakeNamespacePods)
+	k.PodMode = PodModeInsecure
+	runTestCases(t, k, podInsecureTestCases)
+
+	k.PodMode = PodModeVerified
+	runTestCases(t, k, podVerifiedTestCases)
+
+	// Disabled, the names are not pod names.
+	k.PodMode = PodModeDisabled
+	runTestCases(t, k, []test.Case{{
+		Qname: "10-244-0-5.testns.pod.cluster.local.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeNameError,
+		Ns:    []dns.RR{test.SOA("cluster.local. 5 IN SOA ns.dns.cluster.local. hostmaster.cluster.local. 1 7200 1800 86400 5")},
+	}})
+}
+
+var fakeExternal = newFakeAPI(
+	service("svc1", "testns", "10.0.0.1", 80),
+	externalService("ext", "testns", "www.example.com"),
+	externalService("alias", "testns", "svc1.testns.cluster.local"),
+)
+
+var externalTestCases = []test.Case{
+	// Without upstream the client follows the CNAME.
+	{
+		Qname: "ext.testns.cluster.local.", Qtype: dns.TypeA,
+		Answer: []dns.RR{test.CNAME("ext.testns.cluster.local. 303 IN CNAME www.example.com.")},
+	},
+	{
+		Qname: "ext.testns.cluster.local.", Qtype: dns.TypeCNAME,
+		Answer: []dns.RR{test.CNAME("ext.testns.cluster.local. 303 IN CNAME www.example.com.")},
+	},
+	// A target in the cluster is followed.
+	{
+		Qname: "alias.testns.cluster.local.", Qtype: dns.TypeA,
+		Answer: []dns.RR{
+			test.CNAME("alias.testns.cluster.local. 303 IN CNAME svc1.testns.cluster.local."),
+			test.A("svc1.testns.cluster.local. 303 IN A 10.0.0.1"),
+		},
+	},
+	{
+		Qname: "svc1.testns.cluster.local.", Qtype: dns.TypeCNAME,
+		Ns: []dns.RR{test.SOA("cluster.local. 5 IN SOA ns.dns.cluster.local. hostmaster.cluster.local. 1 7200 1800 86400 5")},
+	},
+	{
+		Qname: "nosvc.testns.cluster.local.", Qtype: dns.TypeCNAME,
+		Rcode: dns.RcodeNameError,
+		Ns:    []dns.RR{test.SOA("cluster.local. 5 IN SOA ns.dns.cluster.local. hostmaster.cluster.local. 1 7200 1800 86400 5")},
+	},
+	// No ports.
+	{
+		Qname: "_http._tcp.ext.testns.cluster.local.", Qtype: dns.TypeSRV,
+		Rcode: dns.RcodeNameError,
+		Ns:    []dns.RR{test.SOA("cluster.local. 5 IN SOA ns.dns.cluster.local. hostmaster.cluster.local. 1 7200 1800 86400 5")},
+	},
+}
+
+func TestServeDNSExternalName(t *testing.T) {
+	runTestCases(t, newTestKubernetes(fakeExternal), externalTestCases)
+
+	// With upstream the CNAME is followed to the addresses.
+	dns.HandleFunc("www.example.com.", func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = []dns.RR{test.A("www.example.com. 300 IN A 192.0.2.1")}
+		w.WriteMsg(m)
+	})
+	defer dns.HandleRemove("www.example.com.")
+
+	udp, addr, err := test.UDPServer(t, "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not start UDP server: %s", err)
+	}
+	defer udp.Shutdown()
+
+	k := newTestKubernetes(fakeExternal)
+	k.Proxy = proxy.New([]string{addr})
+	runTestCases(t, k, []test.Case{{
+		Qname: "ext.testns.cluster.local.", Qtype: dns.TypeA,
+		Answer: []dns.RR{
+			test.CNAME("ext.testns.cluster.local. 303 IN CNAME www.example.com."),
+			test.A("www.example.com. 300 IN A 192.0.2.1"),
+		},
+	}})
+}
+
+var federationTestCases = []test.Case{
+	// The service exists in this cluster.
+	{
+		Qname: "svc1.testns.east.cluster.local.", Qtype: dns.TypeA,
+		Answer: []dns.RR{
+			test.CNAME("svc1.testns.east.cluster.local. 303 IN CNAME svc1.testns.cluster.local."),
+			test.A("svc1.testns.cluster.local. 303 IN A 10.0.0.1"),
+		},
+	},
+	{
+		Qname: "svc1.testns.east.cluster.local.", Qtype: dns.TypeCNAME,
+		Answer: []dns.RR{test.CNAME("svc1.testns.east.cluster.local. 303 IN CNAME svc1.testns.cluster.local.")},
+	},
+	// It doesn't, the client is sent to the federation.
+	{
+		Qname: "svc9.testns.east.cluster.local.", Qtype: dns.TypeA,
+		Answer: []dns.RR{test.CNAME("svc9.testns.east.cluster.local. 303 IN CNAME svc9.testns.east.example.org.")},
+	},
+	{
+		Qname: "_http._tcp.svc9.testns.east.cluster.local.", Qtype: dns.TypeSRV,
+		Answer: []dns.RR{test.CNAME("_http._tcp.svc9.testns.east.cluster.local. 303 IN CNAME _http._tcp.svc9.testns.east.example.org.")},
+	},
+	// Not a federation.
+	{
+		Qname: "svc9.testns.west.cluster.local.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeNameError,
+		Ns:    []dns.RR{test.SOA("cluster.local. 5 IN SOA ns.dns.cluster.local. hostmaster.cluster.local. 1 7200 1800 86400 5")},
+	},
+}
+
+func TestServeDNSFederation(t *testing.T) {
+	k := newTestKubernetes(fakeServices)
+	k.Federations = map[string]string{"east": "east.example.org."}
+	runTestCases(t, k, federationTestCases)
+}
+
+func TestServeDNSFallthrough(t *testing.T) {
+	k := newTestKubernetes(fakeServices)
+	k.Fallthrough = true
+	k.Next = test.NextHandler(dns.RcodeRefused, nil)
+
+	tests := []struct {
+		qname    string
+		qtype    uint16
+		expected int
+	}{
+		{"svc9.testns.cluster.local.", dns.TypeA, dns.RcodeRefused},
+		{"svc1.testns.cluster.local.", dns.TypeA, dns.RcodeSuccess},
+		{"svc1.testns.cluster.local.", dns.TypeTXT, dns.RcodeSuccess},
+		{"cluster.local.", dns.TypeA, dns.RcodeSuccess},
+	}
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, tc.qtype)
+		rcode, _ := k.ServeDNS(context.TODO(), dnsrecorder.New(&test.ResponseWriter{}), m)
+		if rcode != tc.expected {
+			t.Errorf("Test %d: expected rcode %s, got %s", i, dns.RcodeToString[tc.expected], dns.RcodeToString[rcode])
+		}
+	}
+}
+
+func TestServeDNSCNAMELoop(t *testing.T) {
+	k := newTestKubernetes(newFakeAPI(
+		externalService("loop1", "testns", "loop2.testns.cluster.local"),
+		externalService("loop2", "testns", "loop1.testns.cluster.local"),
+	))
+
+	m := new(dns.Msg)
+	m.SetQuestion("loop1.testns.cluster.local.", dns.TypeA)
+	rec := dnsrecorder.New(&test.ResponseWriter{})
+	rcode, err := k.ServeDNS(context.TODO(), rec, m)
+	if rcode != dns.RcodeServerFailure || err != cname.ErrLoop {
+		t.Errorf("Expected SERVFAIL and %q, got %s and %v", cname.ErrLoop, dns.RcodeToString[rcode], err)
+	}
+	if rec.Msg != nil {
+		t.Errorf("Expected the server to write the SERVFAIL, got %v", rec.Msg)
+	}
+}
+
+func runTestCases(t *testing.T, k Kubernetes, cases []test.Case) {
+	log.SetOutput(ioutil.Discard)
+	ctx := context.TODO()
+
+	for _, tc := range cases {
+		m := tc.Msg()
+
+		rec := dnsrecorder.New(&test.ResponseWriter{})
+		_, err := k.ServeDNS(ctx, rec, m)
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+			continue
+		}
+
+		resp := rec.Msg
+		if len(resp.Question) != 1 || resp.Question[0].Name != tc.Qname {
+			t.Errorf("Expected question %s in the reply, got %v", tc.Qname, resp.Question)
+			continue
+		}
+		sort.Sort(test.RRSet(resp.Answer))
+		sort.Sort(test.RRSet(resp.Ns))
+		sort.Sort(test.RRSet(resp.Extra))
+
+		if !test.Header(t, tc, resp) {
+			t.Logf("%v\n", resp)
+			continue
+		}
+		if !test.Section(t, tc, test.Answer, resp.Answer) {
+			t.Logf("%v\n", resp)
+		}
+		if !test.Section(t, tc, test.Ns, resp.Ns) {
+			t.Logf("%v\n", resp)
+		}
+		if !test.Section(t, tc, test.Extra, resp.Extra) {
+			t.Logf("%v\n", resp)
+		}
+	}
+}
@@ -10,7 +10,6 @@ import (
 	"github.com/miekg/coredns/middleware"
 	"github.com/miekg/coredns/middleware/etcd/msg"
 	"github.com/miekg/coredns/middleware/kubernetes/nametemplate"
-	"github.com/miekg/coredns/middleware/pkg/dnsutil"
 	dns_strings "github.com/miekg/coredns/middleware/pkg/strings"
 	"github.com/miekg/coredns/middleware/proxy"
 
@@ -33,19 +32,46 @@ type Kubernetes struct {
 	APICertAuth   string
 	APIClientCert string
 	APIClientKey  string
-	APIConn       *dnsController
+	Kubeconfig    string // path of a kubeconfig file, used instead of the default loading rules
+	KubeContext   string // context in Kubeconfig, empty is its current context
+	APIConn       dnsControl
 	ResyncPeriod  time.Duration
 	NameTemplate  *nametemplate.NameTemplate
 	Namespaces    []string
 	LabelSelector *unversionedapi.LabelSelector
 	Selector      *labels.Selector
+	Changed       func() // called when the services or endpoints change, may be nil
+	ReversePods   bool   // answer reverse lookups for the IPs of the pods behind services as well
+	PodMode       int    // how <ip>.<namespace>.pod.<zone> names are answered, see PodModeDisabled and friends
+	ClientLabels  bool   // label the queries with the namespace and name of the pod that sent them
+	TTL           uint32 // TTL of the records, and of the negative answers
+
+	// Federations maps the federation labels to the zones of the federations, see federated.
+	// Fallthrough hands the queries for names that don't exist to the next middleware.
+	Federations map[string]string
+	Fallthrough bool
+
+	maxCNAMEDepth int // the number of CNAMEs followed for a query, set by ServeDNS from its context
 }
 
+// getClientConfig returns the config to connect to the API server with. Inside a cluster,
+// without an endpoint or kubeconfig, the token and CA of the service account of the pod
+// are used. Otherwise the kubeconfig given, or the default one, is used, with the endpoint
+// and TLS files given overriding it.
 func (k *Kubernetes) getClientConfig() (*restclient.Config, error) {
+	if k.APIEndpoint == "" && k.Kubeconfig == "" {
+		if config, err := restclient.InClusterConfig(); err == nil {
+			return config, nil
+		}
+	}
+
 	// For a custom api server or running outside a k8s cluster
 	// set URL in env.KUBERNETES_MASTER or set endpoint in Corefile
 	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
-	overrides := &clientcmd.ConfigOverrides{}
+	if k.Kubeconfig != "" {
+		loadingRules = &clientcmd.ClientConfigLoadingRules{ExplicitPath: k.Kubeconfig}
+	}
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: k.KubeContext}
 	clusterinfo := clientcmdapi.Cluster{}
 	authinfo := clientcmdapi.AuthInfo{}
 	if len(k.APIEndpoint) > 0 {
@@ -92,7 +118,7 @@ func (k *Kubernetes) InitKubeCache() error {
 		}
 		log.Printf("[INFO] Kubernetes middleware configured with the label selector '%s'. Only kubernetes objects matching this label selector will be exposed.", unversionedapi.FormatLabelSelector(k.LabelSelector))
 	}
-	k.APIConn = newdnsController(kubeClient, k.ResyncPeriod, k.Selector)
+	k.APIConn = newdnsController(kubeClient, k.ResyncPeriod, k.Selector, k.Changed, k.PodMode == PodModeVerified || k.ClientLabels)
 
 	return err
 }
@@ -124,27 +150,42 @@ func (k *Kubernetes) getZoneForName(name string) (string, []string) {
 // this name. This is used when find matches when completing SRV lookups
 // for instance.
 func (k *Kubernetes) Records(name string, exact bool) ([]msg.Service, error) {
-	// TODO: refector this.
-	// Right now GetNamespaceFromSegmentArray do not supports PRE queries
-	ip := dnsutil.ExtractAddressFromReverse(name)
-	if ip != "" {
-		records := k.getServiceRecordForIP(ip, name)
-		return records, nil
-	}
 	var (
 		serviceName string
 		namespace   string
-		typeName    string
 	)
 
 	zone, serviceSegments := k.getZoneForName(name)
+	if zone == "" {
+		// Not one of our names, like the target of an ExternalName service.
+		return nil, errNoItems
+	}
+
+	if k.PodMode != PodModeDisabled && len(serviceSegments) == 3 && serviceSegments[2] == "pod" {
+		records := k.podRecords(serviceSegments[0], serviceSegments[1], name)
+		if len(records) == 0 {
+			return nil, errNoItems
+		}
+		return records, nil
+	}
+
+	// SRV queries put _port._protocol in front of the service name.
+	var q portQuery
+	if len(serviceSegments) > 2 && strings.HasPrefix(serviceSegments[0], "_") && strings.HasPrefix(serviceSegments[1], "_") {
+		q.port, q.protocol = serviceSegments[0][1:], serviceSegments[1][1:]
+		serviceSegments = serviceSegments[2:]
+	}
+	// An extra label in front of the service name is an endpoint of a headless service.
+	if len(serviceSegments) == k.NameTemplate.SegmentCount()+1 {
+		q.endpoint = serviceSegments[0]
+		serviceSegments = serviceSegments[1:]
+	}
 
 	// TODO: Implementation above globbed together segments for the serviceName if
 	//       multiple segments remained. Determine how to do similar globbing using
 	//		 the template-based implementation.
 	namespace = k.NameTemplate.GetNamespaceFromSegmentArray(serviceSegments)
 	serviceName = k.NameTemplate.GetServiceFromSegmentArray(serviceSegments)
-	typeName = k.NameTemplate.GetTypeFromSegmentArray(serviceSegments)
 
 	if namespace == "" {
 		err := errors.New("Parsing query string did not produce a namespace value. Assuming wildcard namespace.")
@@ -164,7 +205,7 @@ func (k *Kubernetes) Records(name string, exact bool) ([]msg.Service, error) {
 	// Abort if the namespace does not contain a wildcard, and namespace is not published per CoreFile
 	// Case where namespace contains a wildcard is handled in Get(...) method.
 	if (!nsWildcard) && (len(k.Namespaces) > 0) && (!dns_strings.StringInSlice(namespace, k.Namespaces)) {
-		return nil, nil
+		return nil, errNoItems
 	}
 
 	k8sItems, err := k.Get(namespace, nsWildcard, serviceName, serviceWildcard)
@@ -173,30 +214,99 @@ func (k *Kubernetes) Records(name string, exact bool) ([]msg.Service, error) {
 	}
 	if k8sItems == nil {
 		// Did not find item in k8s
-		return nil, nil
+		return nil, errNoItems
 	}
 
-	records := k.getRecordsForServiceItems(k8sItems, nametemplate.NameValues{TypeName: typeName, ServiceName: serviceName, Namespace: namespace, Zone: zone})
+	records := k.getRecordsForServiceItems(k8sItems, zone, q)
+	// The services exist, but not the port or endpoint that was asked for.
+	if len(records) == 0 && q != (portQuery{}) {
+		return nil, errNoItems
+	}
 	return records, nil
 }
 
-// TODO: assemble name from parts found in k8s data based on name template rather than reusing query string
-func (k *Kubernetes) getRecordsForServiceItems(serviceItems []*api.Service, values nametemplate.NameValues) []msg.Service {
+// portQuery is what a query asks for beyond a service: the ports with a name and protocol,
+// as in _http._tcp.myservice.mynamespace.coredns.local., or a single endpoint of a headless
+// service, as in web-0.myservice.mynamespace.coredns.local.
+type portQuery struct {
+	port, protocol string
+	endpoint       string
+}
+
+// matches returns true if the port name with protocol is asked for.
+func (q portQuery) matches(name string, protocol api.Protocol) bool {
+	if q.port == "" {
+		return true
+	}
+	return symbolMatches(q.port, strings.ToLower(name), symbolContainsWildcard(q.port)) &&
+		symbolMatches(q.protocol, strings.ToLower(string(protocol)), symbolContainsWildcard(q.protocol))
+}
+
+// getRecordsForServiceItems returns the records for the services in zone: a record per
+// port, with the cluster IP and the name of the service in it. Headless services get a
+// record per port of each of their endpoints instead, with its IP and name.
+func (k *Kubernetes) getRecordsForServiceItems(serviceItems []*api.Service, zone string, q portQuery) []msg.Service {
 	var records []msg.Service
 
 	for _, item := range serviceItems {
-		clusterIP := item.Spec.ClusterIP
+		name := k.recordName(item.Name, item.Namespace, zone)
 
-		// Create records by constructing record name from template...
-		//values.Namespace = item.Metadata.Namespace
-		//values.ServiceName = item.Metadata.Name
-		//s := msg.Service{Host: g.NameTemplate.GetRecordNameFromNameValues(values)}
-		//records = append(records, s)
-
-		// Create records for each exposed port...
+		// An ExternalName service is an alias for another name, it has no ports or endpoints.
+		if item.Spec.Type == api.ServiceTypeExternalName {
+			if q == (portQuery{}) {
+				records = append(records, msg.Service{Host: item.Spec.ExternalName, Key: name, TTL: k.TTL})
+			}
+			continue
+		}
+		if item.Spec.ClusterIP == api.ClusterIPNone {
+			records = append(records, k.getRecordsForEndpoints(item, name, q)...)
+			continue
+		}
+		if q.endpoint != "" {
+			continue
+		}
+		if len(item.Spec.Ports) == 0 && q.port == "" {
+			records = append(records, msg.Service{Host: item.Spec.ClusterIP, Key: name, TTL: k.TTL})
+			continue
+		}
 		for _, p := range item.Spec.Ports {
-			s := msg.Service{Host: clusterIP, Port: int(p.Port)}
-			records = append(records, s)
+			if !q.matches(p.Name, p.Protocol) {
+				continue
+			}
+			records = append(records, msg.Service{Host: item.Spec.ClusterIP, Port: int(p.Port), Key: name, TTL: k.TTL})
+		}
+	}
+
+	return records
+}
+
+// getRecordsForEndpoints returns the records for the endpoints of the headless service svc,
+// which has the name name. Only the addresses that are ready are used.
+func (k *Kubernetes) getRecordsForEndpoints(svc *api.Service, name string, q portQuery) []msg.Service {
+	ep := k.APIConn.GetEndpoints(svc.Namespace, svc.Name)
+	if ep == nil {
+		return nil
+	}
+
+	var records []msg.Service
+	hostnames := annotatedHostnames(ep)
+	for _, subset := range ep.Subsets {
+		for _, addr := range subset.Addresses {
+			host := endpointHostname(addr, hostnames)
+			if q.endpoint != "" && q.endpoint != host {
+				continue
+			}
+			key := host + "." + name
+			if len(subset.Ports) == 0 && q.port == "" {
+				records = append(records, msg.Service{Host: addr.IP, Key: key, TTL: k.TTL})
+				continue
+			}
+			for _, p := range subset.Ports {
+				if !q.matches(p.Name, p.Protocol) {
+					continue
+				}
+				records = append(records, msg.Service{Host: addr.IP, Port: int(p.Port), Key: key, TTL: k.TTL})
+			}
 		}
 	}
 
@@ -236,23 +346,16 @@ func symbolMatches(queryString string, candidateString string, wildcard bool) bo
 	return result
 }
 
-// kubernetesNameError checks if the error is ErrorCodeKeyNotFound from kubernetes.
-func isKubernetesNameError(err error) bool {
-	return false
-}
+// hasUpstream returns true if names outside of the cluster, the targets of ExternalName
+// services, are resolved with the upstream.
+func (k Kubernetes) hasUpstream() bool { return len(k.Proxy.Upstreams) > 0 }
 
-func (k *Kubernetes) getServiceRecordForIP(ip, name string) []msg.Service {
-	svcList, err := k.APIConn.svcLister.List(labels.Everything())
-	if err != nil {
-		return nil
-	}
-	for _, service := range svcList {
-		if service.Spec.ClusterIP == ip {
-			return []msg.Service{msg.Service{Host: ip}}
-		}
-	}
+// errNoItems is returned by Records when the name doesn't exist.
+var errNoItems = errors.New("no items found")
 
-	return nil
+// isKubernetesNameError returns true if err means that the name doesn't exist.
+func isKubernetesNameError(err error) bool {
+	return err == errNoItems
 }
 
 // symbolContainsWildcard checks whether symbol contains a wildcard value
@@ -1,6 +1,10 @@
 package kubernetes
 
-import "testing"
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
 
 // Test data for TestSymbolContainsWildcard cases.
 var testdataSymbolContainsWildcard = []struct {
@@ -23,3 +27,52 @@ func TestSymbolContainsWildcard(t *testing.T) {
 		}
 	}
 }
+
+func TestGetClientConfigKubeconfig(t *testing.T) {
+	f, err := ioutil.TempFile("", "kubeconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(`apiVersion: v1
+kind: Config
+clusters:
+- name: prod
+  cluster:
+    server: https://10.0.0.1:6443
+- name: staging
+  cluster:
+    server: https://10.0.1.1:6443
+contexts:
+- name: prod
+  context:
+    cluster: prod
+- name: staging
+  context:
+    cluster: staging
+current-context: prod
+`)
+	f.Close()
+
+	tests := []struct {
+		context      string
+		endpoint     string
+		expectedHost string
+	}{
+		{"", "", "https://10.0.0.1:6443"},
+		{"staging", "", "https://10.0.1.1:6443"},
+		// The endpoint in the Corefile wins.
+		{"", "https://10.0.2.1:6443", "https://10.0.2.1:6443"},
+	}
+	for i, tc := range tests {
+		k := &Kubernetes{Kubeconfig: f.Name(), KubeContext: tc.context, APIEndpoint: tc.endpoint}
+		config, err := k.getClientConfig()
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if config.Host != tc.expectedHost {
+			t.Errorf("Test %d: expected host %s, got %s", i, tc.expectedHost, config.Host)
+		}
+	}
+}
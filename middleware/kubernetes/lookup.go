@@ -8,6 +8,7 @@ import (
 
 	"github.com/miekg/coredns/middleware"
 	"github.com/miekg/coredns/middleware/etcd/msg"
+	"github.com/miekg/coredns/middleware/pkg/cname"
 	"github.com/miekg/coredns/middleware/pkg/dnsutil"
 	"github.com/miekg/coredns/request"
 
@@ -36,23 +37,20 @@ func (k Kubernetes) A(zone string, state request.Request, previousRecords []dns.
 		switch {
 		case ip == nil:
 			// TODO(miek): lowercasing? Should lowercase in everything see #85
-			if middleware.Name(state.Name()).Matches(dns.Fqdn(serv.Host)) {
-				// x CNAME x is a direct loop, don't add those
-				continue
-			}
-
 			newRecord := serv.NewCNAME(state.QName(), serv.Host)
-			if len(previousRecords) > 7 {
-				// don't add it, and just continue
-				continue
+			if err := cname.Follow(previousRecords, newRecord, k.maxCNAMEDepth); err != nil {
+				return nil, err
 			}
-			if dnsutil.DuplicateCNAME(newRecord, previousRecords) {
+			if middleware.Name(state.Name()).Matches(dns.Fqdn(serv.Host)) {
+				// The names below this one are already found by this lookup.
 				continue
 			}
 
 			state1 := state.NewWithQuestion(serv.Host, state.QType())
 			nextRecords, err := k.A(zone, state1, append(previousRecords, newRecord))
-
+			if cname.IsError(err) {
+				return nil, err
+			}
 			if err == nil {
 				// Not only have we found something we should add the CNAME and the IP addresses.
 				if len(nextRecords) > 0 {
@@ -67,6 +65,11 @@ func (k Kubernetes) A(zone string, state request.Request, previousRecords []dns.
 				// We should already have found it
 				continue
 			}
+			if !k.hasUpstream() {
+				// The client has to follow the CNAME itself.
+				records = append(records, newRecord)
+				continue
+			}
 			mes, err := k.Proxy.Lookup(state, target, state.QType())
 			if err != nil {
 				continue
@@ -96,23 +99,20 @@ func (k Kubernetes) AAAA(zone string, state request.Request, previousRecords []d
 		switch {
 		case ip == nil:
 			// Try to resolve as CNAME if it's not an IP, but only if we don't create loops.
-			if middleware.Name(state.Name()).Matches(dns.Fqdn(serv.Host)) {
-				// x CNAME x is a direct loop, don't add those
-				continue
-			}
-
 			newRecord := serv.NewCNAME(state.QName(), serv.Host)
-			if len(previousRecords) > 7 {
-				// don't add it, and just continue
-				continue
+			if err := cname.Follow(previousRecords, newRecord, k.maxCNAMEDepth); err != nil {
+				return nil, err
 			}
-			if dnsutil.DuplicateCNAME(newRecord, previousRecords) {
+			if middleware.Name(state.Name()).Matches(dns.Fqdn(serv.Host)) {
+				// The names below this one are already found by this lookup.
 				continue
 			}
 
 			state1 := state.NewWithQuestion(serv.Host, state.QType())
 			nextRecords, err := k.AAAA(zone, state1, append(previousRecords, newRecord))
-
+			if cname.IsError(err) {
+				return nil, err
+			}
 			if err == nil {
 				// Not only have we found something we should add the CNAME and the IP addresses.
 				if len(nextRecords) > 0 {
@@ -127,6 +127,11 @@ func (k Kubernetes) AAAA(zone string, state request.Request, previousRecords []d
 				// We should already have found it
 				continue
 			}
+			if !k.hasUpstream() {
+				// The client has to follow the CNAME itself.
+				records = append(records, newRecord)
+				continue
+			}
 			m1, e1 := k.Proxy.Lookup(state, target, state.QType())
 			if e1 != nil {
 				continue
@@ -189,6 +194,9 @@ func (k Kubernetes) SRV(zone string, state request.Request) (records []dns.RR, e
 			lookup[srv.Target] = true
 
 			if !dns.IsSubDomain(zone, srv.Target) {
+				if !k.hasUpstream() {
+					break
+				}
 				m1, e1 := k.Proxy.Lookup(state, srv.Target, dns.TypeA)
 				if e1 == nil {
 					extra = append(extra, m1.Answer...)
@@ -234,9 +242,19 @@ func (k Kubernetes) MX(zone string, state request.Request) (records []dns.RR, ex
 	return nil, nil, err
 }
 
-// CNAME returns CNAME records from kubernetes. Not implemented!
+// CNAME returns the CNAME records from kubernetes, of the ExternalName services.
 func (k Kubernetes) CNAME(zone string, state request.Request) (records []dns.RR, err error) {
-	return nil, err
+	services, err := k.records(state, true)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, serv := range services {
+		if ip := net.ParseIP(serv.Host); ip == nil {
+			records = append(records, serv.NewCNAME(state.QName(), serv.Host))
+		}
+	}
+	return records, nil
 }
 
 // TXT returns TXT records from kubernetes. Not implemented!
@@ -252,12 +270,15 @@ func (k Kubernetes) NS(zone string, state request.Request) (records, extra []dns
 
 	state.Clear()
 	state.Req.Question[0].Name = "ns.dns." + zone
+	// ... and reset, also when we return an error: the request is used for the reply.
+	defer func() {
+		state.Clear()
+		state.Req.Question[0].Name = old
+	}()
 	services, err := k.records(state, false)
 	if err != nil {
 		return nil, nil, err
 	}
-	// ... and reset
-	state.Req.Question[0].Name = old
 
 	for _, serv := range services {
 		ip := net.ParseIP(serv.Host)
@@ -266,20 +287,21 @@ func (k Kubernetes) NS(zone string, state request.Request) (records, extra []dns
 			return nil, nil, fmt.Errorf("NS record must be an IP address: %s", serv.Host)
 		case ip.To4() != nil:
 			serv.Host = serv.Key
-			records = append(records, serv.NewNS(state.QName()))
+			records = append(records, serv.NewNS(old))
 			extra = append(extra, serv.NewA(serv.Host, ip.To4()))
 		case ip.To4() == nil:
 			serv.Host = serv.Key
-			records = append(records, serv.NewNS(state.QName()))
+			records = append(records, serv.NewNS(old))
 			extra = append(extra, serv.NewAAAA(serv.Host, ip.To16()))
 		}
 	}
 	return records, extra, nil
 }
 
-// SOA Record returns a SOA record from kubernetes.
+// SOA Record returns a SOA record from kubernetes. Its TTL and minimum TTL are the TTL of
+// the records, so negative answers are cached as long as positive ones (RFC 2308).
 func (k Kubernetes) SOA(zone string, state request.Request) *dns.SOA {
-	header := dns.RR_Header{Name: zone, Rrtype: dns.TypeSOA, Ttl: 300, Class: dns.ClassINET}
+	header := dns.RR_Header{Name: zone, Rrtype: dns.TypeSOA, Ttl: k.TTL, Class: dns.ClassINET}
 	return &dns.SOA{Hdr: header,
 		Mbox:    "hostmaster." + zone,
 		Ns:      "ns.dns." + zone,
@@ -287,35 +309,20 @@ func (k Kubernetes) SOA(zone string, state request.Request) *dns.SOA {
 		Refresh: 7200,
 		Retry:   1800,
 		Expire:  86400,
-		Minttl:  60,
+		Minttl:  k.TTL,
 	}
 }
 
-// PTR Record returns PTR records from kubernetes.
-func (k Kubernetes) PTR(zone string, state request.Request) ([]dns.RR, error) {
-	reverseIP := dnsutil.ExtractAddressFromReverse(state.Name())
-	if reverseIP == "" {
-		return nil, fmt.Errorf("does not support reverse lookup for %s", state.QName())
+// PTR returns the PTR records for the reverse name of state, see reverse.
+func (k Kubernetes) PTR(state request.Request) []dns.RR {
+	ip := dnsutil.ExtractAddressFromReverse(state.Name())
+	if ip == "" {
+		return nil
 	}
-
-	records := make([]dns.RR, 1)
-	services, err := k.records(state, false)
-	if err != nil {
-		return nil, err
+	serv := msg.Service{TTL: k.TTL}
+	records := []dns.RR{}
+	for _, name := range k.reverse(ip) {
+		records = append(records, serv.NewPTR(state.QName(), name))
 	}
-
-	for _, serv := range services {
-		ip := net.ParseIP(serv.Host)
-		if reverseIP != serv.Host {
-			continue
-		}
-		switch {
-		case ip.To4() != nil:
-			records = append(records, serv.NewPTR(state.QName(), ip.To4().String()))
-			break
-		case ip.To4() == nil:
-			// nodata?
-		}
-	}
-	return records, nil
+	return records
 }
@@ -0,0 +1,18 @@
+package kubernetes
+
+import (
+	"github.com/miekg/coredns/middleware"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var clientRequestCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: middleware.Namespace,
+	Subsystem: "kubernetes",
+	Name:      "client_requests_total",
+	Help:      "Counter of queries by the namespace of the pod that sent them.",
+}, []string{"namespace"})
+
+func init() {
+	prometheus.MustRegister(clientRequestCount)
+}
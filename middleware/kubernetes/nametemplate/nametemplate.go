@@ -164,6 +164,14 @@ func (t *NameTemplate) GetRecordNameFromNameValues(values NameValues) string {
 	return strings.Join(recordName, ".")
 }
 
+// SegmentCount returns the number of labels of a record name in front of the zone.
+func (t *NameTemplate) SegmentCount() int {
+	if index, ok := t.Element["zone"]; ok {
+		return index
+	}
+	return len(t.splitFormat)
+}
+
 func (t *NameTemplate) IsValid() bool {
 	// This is *only* used in a test, for the test this should be a private method.
 	result := true
@@ -0,0 +1,60 @@
+package kubernetes
+
+import (
+	"net"
+	"strings"
+
+	"github.com/miekg/coredns/middleware/etcd/msg"
+)
+
+// How the names of pods, like 10-244-0-5.mynamespace.pod.cluster.local, are answered.
+const (
+	// PodModeDisabled doesn't answer them, this is the default.
+	PodModeDisabled = iota
+	// PodModeInsecure answers them with the IP in the name, whether a pod has that IP or not.
+	PodModeInsecure
+	// PodModeVerified only answers them when a pod in the namespace has the IP.
+	PodModeVerified
+)
+
+var podModes = map[string]int{
+	"disabled": PodModeDisabled,
+	"insecure": PodModeInsecure,
+	"verified": PodModeVerified,
+}
+
+// podRecords returns the record for the pod with the IP in label in namespace, as named
+// name, according to the PodMode of k.
+func (k *Kubernetes) podRecords(label, namespace, name string) []msg.Service {
+	ip := podIP(label)
+	if ip == "" || symbolContainsWildcard(namespace) || !k.exposed(namespace) {
+		return nil
+	}
+	if k.PodMode == PodModeVerified && !k.podExists(ip, namespace) {
+		return nil
+	}
+	return []msg.Service{{Host: ip, Key: name, TTL: k.TTL}}
+}
+
+// podExists returns true if a pod in namespace has ip.
+func (k *Kubernetes) podExists(ip, namespace string) bool {
+	for _, p := range k.APIConn.GetPodsByIP(ip) {
+		if p.Namespace == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// podIP returns the IP in label, the IP with its dots, or for IPv6 its colons, replaced by
+// dashes, as in the names of endpoints. It returns the empty string if label has no IP.
+func podIP(label string) string {
+	ip := net.ParseIP(strings.Replace(label, "-", ".", -1)).To4()
+	if ip == nil {
+		ip = net.ParseIP(strings.Replace(label, "-", ":", -1))
+	}
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
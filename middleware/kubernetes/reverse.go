@@ -0,0 +1,74 @@
+package kubernetes
+
+import (
+	"net"
+
+	"github.com/miekg/coredns/middleware/kubernetes/nametemplate"
+	dns_strings "github.com/miekg/coredns/middleware/pkg/strings"
+
+	"github.com/miekg/dns"
+)
+
+// reverse returns the names ip resolves to in the reverse zones: the names of the services
+// with ip as their cluster IP and, with ReversePods, the names of the pods with ip behind
+// a service. The names are in the first zone of k that isn't a reverse zone.
+func (k *Kubernetes) reverse(ip string) []string {
+	addr := net.ParseIP(ip)
+	zone := k.forwardZone()
+	if addr == nil || zone == "" {
+		return nil
+	}
+
+	names := []string{}
+	for _, svc := range k.APIConn.GetServiceList() {
+		if !addr.Equal(net.ParseIP(svc.Spec.ClusterIP)) || !k.exposed(svc.Namespace) {
+			continue
+		}
+		names = append(names, k.recordName(svc.Name, svc.Namespace, zone))
+	}
+	if !k.ReversePods {
+		return names
+	}
+
+	for _, ep := range k.APIConn.GetEndpointsList() {
+		if !k.exposed(ep.Namespace) {
+			continue
+		}
+		hostnames := annotatedHostnames(ep)
+		for _, subset := range ep.Subsets {
+			for _, a := range subset.Addresses {
+				if !addr.Equal(net.ParseIP(a.IP)) {
+					continue
+				}
+				names = append(names, endpointHostname(a, hostnames)+"."+k.recordName(ep.Name, ep.Namespace, zone))
+			}
+		}
+	}
+	return names
+}
+
+// recordName returns the name of service in namespace in zone, according to the name
+// template of k.
+func (k *Kubernetes) recordName(service, namespace, zone string) string {
+	name := k.NameTemplate.GetRecordNameFromNameValues(nametemplate.NameValues{ServiceName: service, Namespace: namespace, TypeName: "svc", Zone: zone})
+	return dns.Fqdn(name)
+}
+
+// exposed returns true if the objects in namespace are served.
+func (k *Kubernetes) exposed(namespace string) bool {
+	return len(k.Namespaces) == 0 || dns_strings.StringInSlice(namespace, k.Namespaces)
+}
+
+// forwardZone returns the first zone of k that isn't a reverse zone, or the empty string.
+func (k *Kubernetes) forwardZone() string {
+	for _, z := range k.Zones {
+		if !isReverseZone(z) {
+			return z
+		}
+	}
+	return ""
+}
+
+func isReverseZone(zone string) bool {
+	return dns.IsSubDomain("in-addr.arpa.", zone) || dns.IsSubDomain("ip6.arpa.", zone)
+}
@@ -3,14 +3,18 @@ package kubernetes
 import (
 	"errors"
 	"fmt"
+	"net"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/miekg/coredns/core/dnsserver"
 	"github.com/miekg/coredns/middleware"
 	"github.com/miekg/coredns/middleware/kubernetes/nametemplate"
+	"github.com/miekg/coredns/middleware/proxy"
 
 	"github.com/mholt/caddy"
+	"github.com/miekg/dns"
 	unversionedapi "k8s.io/kubernetes/pkg/api/unversioned"
 )
 
@@ -27,22 +31,30 @@ func setup(c *caddy.Controller) error {
 		return middleware.Error("kubernetes", err)
 	}
 
+	// Tell the other middleware in this server block, like alias, when the services change.
+	config := dnsserver.GetConfig(c)
+	kubernetes.Changed = config.Changed
+
 	err = kubernetes.InitKubeCache()
 	if err != nil {
 		return middleware.Error("kubernetes", err)
 	}
 
-	// Register KubeCache start and stop functions with Caddy
-	c.OnStartup(func() error {
+	// Start the KubeCache when the server is up and stop it when the server stops.
+	config.OnStartupAfter("kubernetes", nil, func() error {
 		go kubernetes.APIConn.Run()
 		return nil
 	})
 
-	c.OnShutdown(func() error {
+	config.OnShutdown(func() error {
 		return kubernetes.APIConn.Stop()
 	})
 
-	dnsserver.GetConfig(c).AddMiddleware(func(next middleware.Handler) middleware.Handler {
+	if kubernetes.ClientLabels {
+		config.AddMetadata(kubernetes.clientMetadata)
+	}
+
+	config.AddMiddleware(func(next middleware.Handler) middleware.Handler {
 		kubernetes.Next = next
 		return kubernetes
 	})
@@ -51,7 +63,7 @@ func setup(c *caddy.Controller) error {
 }
 
 func kubernetesParse(c *caddy.Controller) (*Kubernetes, error) {
-	k8s := &Kubernetes{ResyncPeriod: defaultResyncPeriod}
+	k8s := &Kubernetes{ResyncPeriod: defaultResyncPeriod, TTL: defaultTTL}
 	k8s.NameTemplate = new(nametemplate.NameTemplate)
 	k8s.NameTemplate.SetTemplate(defaultNameTemplate)
 
@@ -64,8 +76,7 @@ func kubernetesParse(c *caddy.Controller) (*Kubernetes, error) {
 				copy(k8s.Zones, c.ServerBlockKeys)
 			}
 
-			k8s.Zones = NormalizeZoneList(zones)
-			middleware.Zones(k8s.Zones).Normalize()
+			k8s.Zones = NormalizeZoneList(middleware.Zones(zones).NormalizeExact())
 
 			if k8s.Zones == nil || len(k8s.Zones) < 1 {
 				return nil, errors.New("Zone name must be provided for kubernetes middleware.")
@@ -98,13 +109,29 @@ func kubernetesParse(c *caddy.Controller) (*Kubernetes, error) {
 						continue
 					}
 					return nil, c.ArgErr()
-				case "tls": // cert key cacertfile
+				case "tls": // [cert key] [cacertfile]
 					args := c.RemainingArgs()
-					if len(args) == 3 {
+					switch len(args) {
+					case 1:
+						k8s.APICertAuth = args[0]
+					case 2:
+						k8s.APIClientCert, k8s.APIClientKey = args[0], args[1]
+					case 3:
 						k8s.APIClientCert, k8s.APIClientKey, k8s.APICertAuth = args[0], args[1], args[2]
-						continue
+					default:
+						return nil, c.ArgErr()
 					}
-					return nil, c.ArgErr()
+					continue
+				case "kubeconfig":
+					args := c.RemainingArgs()
+					if len(args) == 0 || len(args) > 2 {
+						return nil, c.ArgErr()
+					}
+					k8s.Kubeconfig = args[0]
+					if len(args) == 2 {
+						k8s.KubeContext = args[1]
+					}
+					continue
 				case "resyncperiod":
 					args := c.RemainingArgs()
 					if len(args) > 0 {
@@ -116,6 +143,72 @@ func kubernetesParse(c *caddy.Controller) (*Kubernetes, error) {
 						continue
 					}
 					return nil, c.ArgErr()
+				case "ttl":
+					args := c.RemainingArgs()
+					if len(args) != 1 {
+						return nil, c.ArgErr()
+					}
+					ttl, err := strconv.Atoi(args[0])
+					if err != nil || ttl < 0 || ttl > maxTTL {
+						return nil, c.Errf("ttl must be a number of seconds between 0 and %d, not '%s'", maxTTL, args[0])
+					}
+					k8s.TTL = uint32(ttl)
+					continue
+				case "upstream":
+					args := c.RemainingArgs()
+					if len(args) == 0 {
+						return nil, c.ArgErr()
+					}
+					for i := range args {
+						if _, _, err := net.SplitHostPort(args[i]); err != nil {
+							args[i] = net.JoinHostPort(args[i], "53")
+						}
+					}
+					k8s.Proxy = proxy.New(args)
+					continue
+				case "federation":
+					args := c.RemainingArgs()
+					if len(args) != 2 {
+						return nil, c.ArgErr()
+					}
+					name := strings.ToLower(args[0])
+					if strings.Contains(name, ".") {
+						return nil, c.Errf("federation name must be a single label, not '%s'", args[0])
+					}
+					if k8s.Federations == nil {
+						k8s.Federations = make(map[string]string)
+					}
+					k8s.Federations[name] = dns.Fqdn(strings.ToLower(args[1]))
+					continue
+				case "fallthrough":
+					if len(c.RemainingArgs()) != 0 {
+						return nil, c.ArgErr()
+					}
+					k8s.Fallthrough = true
+					continue
+				case "reversepods":
+					if len(c.RemainingArgs()) != 0 {
+						return nil, c.ArgErr()
+					}
+					k8s.ReversePods = true
+					continue
+				case "pods":
+					args := c.RemainingArgs()
+					if len(args) != 1 {
+						return nil, c.ArgErr()
+					}
+					mode, ok := podModes[args[0]]
+					if !ok {
+						return nil, c.Errf("pods must be disabled, insecure or verified, not '%s'", args[0])
+					}
+					k8s.PodMode = mode
+					continue
+				case "clientlabels":
+					if len(c.RemainingArgs()) != 0 {
+						return nil, c.ArgErr()
+					}
+					k8s.ClientLabels = true
+					continue
 				case "labels":
 					args := c.RemainingArgs()
 					if len(args) > 0 {
@@ -139,4 +232,6 @@ func kubernetesParse(c *caddy.Controller) (*Kubernetes, error) {
 const (
 	defaultNameTemplate = "{service}.{namespace}.{zone}"
 	defaultResyncPeriod = 5 * time.Minute
+	defaultTTL          = 5
+	maxTTL              = 3600
 )
@@ -291,6 +291,32 @@ func TestKubernetesParse(t *testing.T) {
 			0 * time.Second,
 			"",
 		},
+		{
+			"reverse zone",
+			`kubernetes coredns.local 10.0.0.0/24 {
+    reversepods
+}`,
+			false,
+			"",
+			2,
+			true,
+			0,
+			defaultResyncPeriod,
+			"",
+		},
+		{
+			"reversepods with an argument",
+			`kubernetes coredns.local {
+    reversepods yes
+}`,
+			true,
+			"Wrong argument count or unexpected line ending after 'yes'",
+			-1,
+			true,
+			0,
+			0 * time.Second,
+			"",
+		},
 		{
 			"labels with no selector value",
 			`kubernetes coredns.local {
@@ -388,3 +414,225 @@ func TestKubernetesParse(t *testing.T) {
 		}
 	}
 }
+
+func TestKubernetesParsePods(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		expected  int
+	}{
+		{`kubernetes coredns.local`, false, PodModeDisabled},
+		{`kubernetes coredns.local {
+    pods disabled
+}`, false, PodModeDisabled},
+		{`kubernetes coredns.local {
+    pods insecure
+}`, false, PodModeInsecure},
+		{`kubernetes coredns.local {
+    pods verified
+}`, false, PodModeVerified},
+		// fails
+		{`kubernetes coredns.local {
+    pods
+}`, true, 0},
+		{`kubernetes coredns.local {
+    pods secure
+}`, true, 0},
+		{`kubernetes coredns.local {
+    clientlabels yes
+}`, true, 0},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		k, err := kubernetesParse(c)
+		if test.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: Expected error, got none for input '%s'", i, test.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: Expected no error, got '%v' for input '%s'", i, err, test.input)
+			continue
+		}
+		if k.PodMode != test.expected {
+			t.Errorf("Test %d: Expected pod mode %d, got %d", i, test.expected, k.PodMode)
+		}
+	}
+}
+
+func TestKubernetesParseTTL(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		expected  uint32
+	}{
+		{`kubernetes coredns.local`, false, defaultTTL},
+		{`kubernetes coredns.local {
+    ttl 30
+}`, false, 30},
+		{`kubernetes coredns.local {
+    ttl 0
+}`, false, 0},
+		// fails
+		{`kubernetes coredns.local {
+    ttl
+}`, true, 0},
+		{`kubernetes coredns.local {
+    ttl -1
+}`, true, 0},
+		{`kubernetes coredns.local {
+    ttl 3601
+}`, true, 0},
+		{`kubernetes coredns.local {
+    ttl 5m
+}`, true, 0},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		k, err := kubernetesParse(c)
+		if test.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: Expected error, got none for input '%s'", i, test.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: Expected no error, got '%v' for input '%s'", i, err, test.input)
+			continue
+		}
+		if k.TTL != test.expected {
+			t.Errorf("Test %d: Expected ttl %d, got %d", i, test.expected, k.TTL)
+		}
+	}
+}
+
+func TestKubernetesParseUpstream(t *testing.T) {
+	c := caddy.NewTestController("dns", `kubernetes coredns.local {
+    upstream 10.0.0.10 10.0.0.11:5353
+}`)
+	k, err := kubernetesParse(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if !k.hasUpstream() {
+		t.Fatalf("Expected an upstream, got none")
+	}
+
+	c = caddy.NewTestController("dns", `kubernetes coredns.local {
+    upstream
+}`)
+	if _, err := kubernetesParse(c); err == nil {
+		t.Errorf("Expected error for upstream without addresses, got none")
+	}
+
+	c = caddy.NewTestController("dns", `kubernetes coredns.local`)
+	if k, _ := kubernetesParse(c); k.hasUpstream() {
+		t.Errorf("Expected no upstream by default")
+	}
+}
+
+func TestKubernetesParseFederation(t *testing.T) {
+	c := caddy.NewTestController("dns", `kubernetes coredns.local {
+    federation east east.example.org
+    federation West west.example.org.
+    fallthrough
+}`)
+	k, err := kubernetesParse(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if len(k.Federations) != 2 || k.Federations["east"] != "east.example.org." || k.Federations["west"] != "west.example.org." {
+		t.Errorf("Expected federations east and west, got %v", k.Federations)
+	}
+	if !k.Fallthrough {
+		t.Errorf("Expected fallthrough to be enabled")
+	}
+
+	for _, input := range []string{
+		`kubernetes coredns.local {
+    federation east
+}`,
+		`kubernetes coredns.local {
+    federation east.west example.org
+}`,
+		`kubernetes coredns.local {
+    fallthrough example.org
+}`,
+	} {
+		c = caddy.NewTestController("dns", input)
+		if _, err := kubernetesParse(c); err == nil {
+			t.Errorf("Expected error, got none for input '%s'", input)
+		}
+	}
+}
+
+func TestKubernetesParseClientLabels(t *testing.T) {
+	c := caddy.NewTestController("dns", `kubernetes coredns.local {
+    clientlabels
+}`)
+	k, err := kubernetesParse(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got '%v'", err)
+	}
+	if !k.ClientLabels {
+		t.Errorf("Expected client labels to be enabled")
+	}
+}
+
+func TestKubernetesParseAPI(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		expected  Kubernetes
+	}{
+		{`kubernetes coredns.local {
+    tls /etc/k8s/ca.crt
+}`, false, Kubernetes{APICertAuth: "/etc/k8s/ca.crt"}},
+		{`kubernetes coredns.local {
+    tls /etc/k8s/client.crt /etc/k8s/client.key
+}`, false, Kubernetes{APIClientCert: "/etc/k8s/client.crt", APIClientKey: "/etc/k8s/client.key"}},
+		{`kubernetes coredns.local {
+    endpoint https://10.0.0.1:6443
+    tls /etc/k8s/client.crt /etc/k8s/client.key /etc/k8s/ca.crt
+}`, false, Kubernetes{APIEndpoint: "https://10.0.0.1:6443", APIClientCert: "/etc/k8s/client.crt", APIClientKey: "/etc/k8s/client.key", APICertAuth: "/etc/k8s/ca.crt"}},
+		{`kubernetes coredns.local {
+    kubeconfig /home/dns/.kube/config
+}`, false, Kubernetes{Kubeconfig: "/home/dns/.kube/config"}},
+		{`kubernetes coredns.local {
+    kubeconfig /home/dns/.kube/config staging
+}`, false, Kubernetes{Kubeconfig: "/home/dns/.kube/config", KubeContext: "staging"}},
+		// fails
+		{`kubernetes coredns.local {
+    tls a b c d
+}`, true, Kubernetes{}},
+		{`kubernetes coredns.local {
+    kubeconfig
+}`, true, Kubernetes{}},
+		{`kubernetes coredns.local {
+    kubeconfig /home/dns/.kube/config staging extra
+}`, true, Kubernetes{}},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		k, err := kubernetesParse(c)
+		if test.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: Expected error, got none for input '%s'", i, test.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: Expected no error, got '%v' for input '%s'", i, err, test.input)
+			continue
+		}
+		if k.APIEndpoint != test.expected.APIEndpoint || k.APICertAuth != test.expected.APICertAuth ||
+			k.APIClientCert != test.expected.APIClientCert || k.APIClientKey != test.expected.APIClientKey ||
+			k.Kubeconfig != test.expected.Kubeconfig || k.KubeContext != test.expected.KubeContext {
+			t.Errorf("Test %d: Expected %+v, got %+v", i, test.expected, *k)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+// Package limits implements the limits directive that sets connection limits on the
+// stream listeners of a server and limits the number of queries in flight.
+package limits
+
+import "github.com/mholt/caddy"
+
+func init() {
+	caddy.RegisterPlugin("limits", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setupLimits,
+	})
+}
@@ -0,0 +1,151 @@
+package limits
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/miekg/coredns/core/dnsserver"
+	"github.com/miekg/coredns/middleware"
+
+	"github.com/mholt/caddy"
+)
+
+func setupLimits(c *caddy.Controller) error {
+	config := dnsserver.GetConfig(c)
+	for c.Next() {
+		if len(c.RemainingArgs()) != 0 {
+			return middleware.Error("limits", c.ArgErr())
+		}
+		for c.NextBlock() {
+			switch c.Val() {
+			case "max_conns":
+				n, err := parsePositive(c)
+				if err != nil {
+					return middleware.Error("limits", err)
+				}
+				config.MaxConns = n
+			case "max_conns_per_ip":
+				n, err := parsePositive(c)
+				if err != nil {
+					return middleware.Error("limits", err)
+				}
+				config.MaxConnsPerIP = n
+			case "max_cname_depth":
+				n, err := parsePositive(c)
+				if err != nil {
+					return middleware.Error("limits", err)
+				}
+				config.MaxCNAMEDepth = n
+			case "read_timeout", "write_timeout", "idle_timeout", "query_timeout", "startup_timeout":
+				what := c.Val()
+				d, err := parseTimeout(c)
+				if err != nil {
+					return middleware.Error("limits", err)
+				}
+				switch what {
+				case "read_timeout":
+					config.ReadTimeout = d
+				case "write_timeout":
+					config.WriteTimeout = d
+				case "idle_timeout":
+					config.IdleTimeout = d
+				case "query_timeout":
+					config.QueryTimeout = d
+				case "startup_timeout":
+					config.StartupTimeout = d
+				}
+			case "max_concurrent":
+				args := c.RemainingArgs()
+				if len(args) == 0 || len(args) > 2 {
+					return middleware.Error("limits", c.ArgErr())
+				}
+				n, err := strconv.Atoi(args[0])
+				if err != nil {
+					return middleware.Error("limits", err)
+				}
+				if n <= 0 {
+					return middleware.Error("limits", c.Errf("max_concurrent must be larger than zero: %d", n))
+				}
+				config.MaxConcurrent = n
+				if len(args) == 2 {
+					switch args[1] {
+					case "servfail":
+						config.OverloadDrop = false
+					case "drop":
+						config.OverloadDrop = true
+					default:
+						return middleware.Error("limits", c.Errf("unknown overload action '%s'", args[1]))
+					}
+				}
+			case "workers":
+				n, err := parsePositive(c)
+				if err != nil {
+					return middleware.Error("limits", err)
+				}
+				config.Workers = n
+			case "queue":
+				args := c.RemainingArgs()
+				if len(args) == 0 || len(args) > 2 {
+					return middleware.Error("limits", c.ArgErr())
+				}
+				n, err := strconv.Atoi(args[0])
+				if err != nil {
+					return middleware.Error("limits", err)
+				}
+				if n <= 0 {
+					return middleware.Error("limits", c.Errf("queue must be larger than zero: %d", n))
+				}
+				config.QueueLength = n
+				if len(args) == 2 {
+					switch args[1] {
+					case "newest":
+						config.QueueDropOldest = false
+					case "oldest":
+						config.QueueDropOldest = true
+					default:
+						return middleware.Error("limits", c.Errf("unknown drop policy '%s'", args[1]))
+					}
+				}
+			default:
+				return middleware.Error("limits", c.Errf("unknown property '%s'", c.Val()))
+			}
+		}
+	}
+	if config.QueueLength > 0 && config.Workers == 0 {
+		return middleware.Error("limits", c.Err("queue needs workers"))
+	}
+	return nil
+}
+
+// parsePositive parses the single argument of a property as an integer larger than zero.
+func parsePositive(c *caddy.Controller) (int, error) {
+	args := c.RemainingArgs()
+	if len(args) != 1 {
+		return 0, c.ArgErr()
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil {
+		return 0, err
+	}
+	if n <= 0 {
+		return 0, c.Errf("%s must be larger than zero: %d", c.Val(), n)
+	}
+	return n, nil
+}
+
+// parseTimeout parses the single argument of a property as a duration larger than zero.
+func parseTimeout(c *caddy.Controller) (time.Duration, error) {
+	what := c.Val()
+	args := c.RemainingArgs()
+	if len(args) != 1 {
+		return 0, c.ArgErr()
+	}
+	d, err := time.ParseDuration(args[0])
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, c.Errf("%s must be larger than zero: %s", what, d)
+	}
+	return d, nil
+}
@@ -0,0 +1,215 @@
+package limits
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/coredns/core/dnsserver"
+
+	"github.com/mholt/caddy"
+)
+
+func TestSetupLimitsTimeouts(t *testing.T) {
+	c := caddy.NewTestController("dns", `limits {
+		read_timeout 2s
+		write_timeout 3s
+		idle_timeout 1m
+		query_timeout 3s
+		startup_timeout 1m
+	}`)
+	if err := setupLimits(c); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	cfg := dnsserver.GetConfig(c)
+	if cfg.ReadTimeout != 2*time.Second {
+		t.Errorf("Expected ReadTimeout to be 2s, got %s", cfg.ReadTimeout)
+	}
+	if cfg.WriteTimeout != 3*time.Second {
+		t.Errorf("Expected WriteTimeout to be 3s, got %s", cfg.WriteTimeout)
+	}
+	if cfg.IdleTimeout != time.Minute {
+		t.Errorf("Expected IdleTimeout to be 1m, got %s", cfg.IdleTimeout)
+	}
+	if cfg.QueryTimeout != 3*time.Second {
+		t.Errorf("Expected QueryTimeout to be 3s, got %s", cfg.QueryTimeout)
+	}
+	if cfg.StartupTimeout != time.Minute {
+		t.Errorf("Expected StartupTimeout to be 1m, got %s", cfg.StartupTimeout)
+	}
+}
+
+func TestSetupLimits(t *testing.T) {
+	tests := []struct {
+		input            string
+		shouldErr        bool
+		expectedMax      int
+		expectedMaxPerIP int
+		expectedMaxConc  int
+		expectedDrop     bool
+	}{
+		{`limits`, false, 0, 0, 0, false},
+		{`limits {
+			max_conns 1000
+		}`, false, 1000, 0, 0, false},
+		{`limits {
+			max_conns 1000
+			max_conns_per_ip 10
+		}`, false, 1000, 10, 0, false},
+		{`limits {
+			max_concurrent 500
+		}`, false, 0, 0, 500, false},
+		{`limits {
+			max_concurrent 500 drop
+		}`, false, 0, 0, 500, true},
+		// fails
+		{`limits 10`, true, 0, 0, 0, false},
+		{`limits {
+			max_conns 0
+		}`, true, 0, 0, 0, false},
+		{`limits {
+			max_conns_per_ip blaat
+		}`, true, 0, 0, 0, false},
+		{`limits {
+			max_concurrent 500 ignore
+		}`, true, 0, 0, 0, false},
+		{`limits {
+			idle_timeout 10
+		}`, true, 0, 0, 0, false},
+		{`limits {
+			read_timeout -2s
+		}`, true, 0, 0, 0, false},
+		{`limits {
+			max_concurrent
+		}`, true, 0, 0, 0, false},
+		{`limits {
+			blaat 10
+		}`, true, 0, 0, 0, false},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		err := setupLimits(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %d: Expected error but found nil", i)
+			continue
+		}
+		if !test.shouldErr && err != nil {
+			t.Errorf("Test %d: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr {
+			continue
+		}
+		cfg := dnsserver.GetConfig(c)
+		if cfg.MaxConns != test.expectedMax {
+			t.Errorf("Test %d: Expected MaxConns to be %d, got %d", i, test.expectedMax, cfg.MaxConns)
+		}
+		if cfg.MaxConnsPerIP != test.expectedMaxPerIP {
+			t.Errorf("Test %d: Expected MaxConnsPerIP to be %d, got %d", i, test.expectedMaxPerIP, cfg.MaxConnsPerIP)
+		}
+		if cfg.MaxConcurrent != test.expectedMaxConc {
+			t.Errorf("Test %d: Expected MaxConcurrent to be %d, got %d", i, test.expectedMaxConc, cfg.MaxConcurrent)
+		}
+		if cfg.OverloadDrop != test.expectedDrop {
+			t.Errorf("Test %d: Expected OverloadDrop to be %t, got %t", i, test.expectedDrop, cfg.OverloadDrop)
+		}
+	}
+}
+
+func TestSetupLimitsWorkers(t *testing.T) {
+	tests := []struct {
+		input          string
+		shouldErr      bool
+		expectedWorker int
+		expectedQueue  int
+		expectedOldest bool
+	}{
+		{`limits {
+			workers 8
+		}`, false, 8, 0, false},
+		{`limits {
+			workers 8
+			queue 100
+		}`, false, 8, 100, false},
+		{`limits {
+			workers 8
+			queue 100 oldest
+		}`, false, 8, 100, true},
+		// fails
+		{`limits {
+			workers 0
+		}`, true, 0, 0, false},
+		{`limits {
+			queue 100
+		}`, true, 0, 0, false},
+		{`limits {
+			workers 8
+			queue 100 random
+		}`, true, 0, 0, false},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		err := setupLimits(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %d: Expected error but found nil", i)
+			continue
+		}
+		if !test.shouldErr && err != nil {
+			t.Errorf("Test %d: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr {
+			continue
+		}
+		cfg := dnsserver.GetConfig(c)
+		if cfg.Workers != test.expectedWorker {
+			t.Errorf("Test %d: Expected Workers to be %d, got %d", i, test.expectedWorker, cfg.Workers)
+		}
+		if cfg.QueueLength != test.expectedQueue {
+			t.Errorf("Test %d: Expected QueueLength to be %d, got %d", i, test.expectedQueue, cfg.QueueLength)
+		}
+		if cfg.QueueDropOldest != test.expectedOldest {
+			t.Errorf("Test %d: Expected QueueDropOldest to be %t, got %t", i, test.expectedOldest, cfg.QueueDropOldest)
+		}
+	}
+}
+
+func TestSetupLimitsMaxCNAMEDepth(t *testing.T) {
+	tests := []struct {
+		input         string
+		shouldErr     bool
+		expectedDepth int
+	}{
+		{`limits {
+			max_cname_depth 4
+		}`, false, 4},
+		// fails
+		{`limits {
+			max_cname_depth 0
+		}`, true, 0},
+		{`limits {
+			max_cname_depth
+		}`, true, 0},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		err := setupLimits(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %d: Expected error but found nil", i)
+			continue
+		}
+		if !test.shouldErr && err != nil {
+			t.Errorf("Test %d: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr {
+			continue
+		}
+		cfg := dnsserver.GetConfig(c)
+		if cfg.MaxCNAMEDepth != test.expectedDepth {
+			t.Errorf("Test %d: Expected MaxCNAMEDepth to be %d, got %d", i, test.expectedDepth, cfg.MaxCNAMEDepth)
+		}
+	}
+}
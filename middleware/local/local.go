@@ -0,0 +1,94 @@
+// Package local implements a middleware that answers queries for special use
+// names, like localhost., locally, so they are never forwarded upstream.
+package local
+
+import (
+	"net"
+
+	"github.com/miekg/coredns/middleware"
+	"github.com/miekg/coredns/request"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+// Local answers queries for the special use domains in Domains and the reverse
+// names in Reverse.
+type Local struct {
+	Next    middleware.Handler
+	Domains []string          // special use domains to answer, see the domains below
+	Reverse map[string]string // reverse names of our own addresses and the name to return for them
+}
+
+// Special use domains (RFC 6761, RFC 7686) we know how to answer.
+const (
+	localhost = "localhost."
+	invalid   = "invalid."
+	onion     = "onion."
+)
+
+// ServeDNS implements the middleware.Handler interface.
+func (l Local) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: r}
+	qname := state.Name()
+
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Authoritative = true
+
+	if target, ok := l.Reverse[qname]; ok {
+		if state.QType() == dns.TypePTR || state.QType() == dns.TypeANY {
+			m.Answer = []dns.RR{&dns.PTR{Hdr: dns.RR_Header{Name: state.QName(), Rrtype: dns.TypePTR, Class: dns.ClassINET, Ttl: ttl}, Ptr: target}}
+		} else {
+			m.Ns = []dns.RR{soa(qname)}
+		}
+		return l.write(state, m)
+	}
+
+	zone := middleware.Zones(l.Domains).Matches(qname)
+	if zone == "" {
+		return l.Next.ServeDNS(ctx, w, r)
+	}
+
+	switch zone {
+	case localhost:
+		// Every name in localhost. is a loopback address.
+		hdr := dns.RR_Header{Name: state.QName(), Rrtype: state.QType(), Class: dns.ClassINET, Ttl: ttl}
+		switch state.QType() {
+		case dns.TypeA:
+			m.Answer = []dns.RR{&dns.A{Hdr: hdr, A: net.IPv4(127, 0, 0, 1)}}
+		case dns.TypeAAAA:
+			m.Answer = []dns.RR{&dns.AAAA{Hdr: hdr, AAAA: net.IPv6loopback}}
+		default:
+			m.Ns = []dns.RR{soa(zone)}
+		}
+	default:
+		m.Rcode = dns.RcodeNameError
+		m.Ns = []dns.RR{soa(zone)}
+	}
+	return l.write(state, m)
+}
+
+// write writes m to the client. The reply has been written, also when it is an NXDOMAIN,
+// so RcodeSuccess is returned and the middleware before us don't write another one.
+func (l Local) write(state request.Request, m *dns.Msg) (int, error) {
+	state.SizeAndDo(m)
+	state.W.WriteMsg(m)
+	return dns.RcodeSuccess, nil
+}
+
+// soa returns the SOA record used in negative answers for zone.
+func soa(zone string) dns.RR {
+	return &dns.SOA{
+		Hdr:     dns.RR_Header{Name: zone, Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: ttl},
+		Ns:      localhost,
+		Mbox:    "nobody.invalid.",
+		Serial:  1,
+		Refresh: 3600,
+		Retry:   600,
+		Expire:  86400,
+		Minttl:  ttl,
+	}
+}
+
+const ttl = 3600
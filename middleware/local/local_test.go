@@ -0,0 +1,124 @@
+package local
+
+import (
+	"bytes"
+	"log"
+	"net"
+	"testing"
+
+	corelog "github.com/miekg/coredns/middleware/log"
+	"github.com/miekg/coredns/middleware/pkg/dnsrecorder"
+	"github.com/miekg/coredns/middleware/test"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+func TestLocal(t *testing.T) {
+	lo := &net.IPNet{IP: net.ParseIP("127.0.0.1"), Mask: net.CIDRMask(8, 32)}
+	eth := &net.IPNet{IP: net.ParseIP("192.0.2.1"), Mask: net.CIDRMask(24, 32)}
+
+	l := Local{
+		Next:    test.ErrorHandler(),
+		Domains: []string{localhost, invalid, onion},
+		Reverse: reverseNames([]net.Addr{lo, eth}, "ns.example.org."),
+	}
+
+	tests := []struct {
+		qname         string
+		qtype         uint16
+		expectedRcode int
+		expectedAns   string
+	}{
+		{"localhost.", dns.TypeA, dns.RcodeSuccess, "127.0.0.1"},
+		{"www.LocalHost.", dns.TypeAAAA, dns.RcodeSuccess, "::1"},
+		{"localhost.", dns.TypeMX, dns.RcodeSuccess, ""},
+		{"example.invalid.", dns.TypeA, dns.RcodeNameError, ""},
+		{"duckduckgogg42xjoc72x3sjasowoarfbgcmvfimaftt6twagswzczad.onion.", dns.TypeA, dns.RcodeNameError, ""},
+		{"1.0.0.127.in-addr.arpa.", dns.TypePTR, dns.RcodeSuccess, "localhost."},
+		{"1.2.0.192.in-addr.arpa.", dns.TypePTR, dns.RcodeSuccess, "ns.example.org."},
+		{"1.2.0.192.in-addr.arpa.", dns.TypeA, dns.RcodeSuccess, ""},
+		// not ours, handled by the next middleware
+		{"2.2.0.192.in-addr.arpa.", dns.TypePTR, dns.RcodeServerFailure, ""},
+		{"example.org.", dns.TypeA, dns.RcodeServerFailure, ""},
+	}
+
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, tc.qtype)
+		rec := dnsrecorder.New(&test.ResponseWriter{})
+		rcode, _ := l.ServeDNS(context.TODO(), rec, m)
+
+		if tc.expectedRcode == dns.RcodeServerFailure {
+			if rcode != tc.expectedRcode {
+				t.Errorf("Test %d: expected rcode %s, got %s", i, dns.RcodeToString[tc.expectedRcode], dns.RcodeToString[rcode])
+			}
+			continue
+		}
+		if rcode != dns.RcodeSuccess {
+			t.Errorf("Test %d: expected rcode %s after writing the reply, got %s", i, dns.RcodeToString[dns.RcodeSuccess], dns.RcodeToString[rcode])
+			continue
+		}
+		if rec.Msg.Rcode != tc.expectedRcode {
+			t.Errorf("Test %d: expected reply with rcode %s, got %s", i, dns.RcodeToString[tc.expectedRcode], dns.RcodeToString[rec.Msg.Rcode])
+			continue
+		}
+		if tc.expectedAns == "" {
+			if len(rec.Msg.Answer) != 0 || len(rec.Msg.Ns) != 1 {
+				t.Errorf("Test %d: expected negative answer with SOA, got %v", i, rec.Msg)
+			}
+			continue
+		}
+		if len(rec.Msg.Answer) != 1 {
+			t.Errorf("Test %d: expected 1 answer, got %d", i, len(rec.Msg.Answer))
+			continue
+		}
+		var ans string
+		switch x := rec.Msg.Answer[0].(type) {
+		case *dns.A:
+			ans = x.A.String()
+		case *dns.AAAA:
+			ans = x.AAAA.String()
+		case *dns.PTR:
+			ans = x.Ptr
+		}
+		if ans != tc.expectedAns {
+			t.Errorf("Test %d: expected answer %s, got %s", i, tc.expectedAns, ans)
+		}
+	}
+}
+
+// countWriter counts the replies written to it.
+type countWriter struct {
+	dns.ResponseWriter
+	n int
+}
+
+func (w *countWriter) WriteMsg(m *dns.Msg) error {
+	w.n++
+	return w.ResponseWriter.WriteMsg(m)
+}
+
+func TestLocalBehindLog(t *testing.T) {
+	var f bytes.Buffer
+	logger := corelog.Logger{
+		Rules: []corelog.Rule{{NameScope: ".", Format: "{rcode}", Log: log.New(&f, "", 0)}},
+		Next:  Local{Next: test.ErrorHandler(), Domains: []string{invalid}},
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.invalid.", dns.TypeA)
+	w := &countWriter{ResponseWriter: &test.ResponseWriter{}}
+	rec := dnsrecorder.New(w)
+	logger.ServeDNS(context.TODO(), rec, m)
+
+	if w.n != 1 {
+		t.Fatalf("Expected 1 reply to be written, got %d", w.n)
+	}
+	if rec.Msg.Rcode != dns.RcodeNameError {
+		t.Errorf("Expected NXDOMAIN, got %s", dns.RcodeToString[rec.Msg.Rcode])
+	}
+	if x := f.String(); x != "NXDOMAIN\n" {
+		t.Errorf("Expected NXDOMAIN to be logged, got %q", x)
+	}
+}
@@ -0,0 +1,121 @@
+package local
+
+import (
+	"net"
+	"os"
+
+	"github.com/miekg/coredns/core/dnsserver"
+	"github.com/miekg/coredns/middleware"
+
+	"github.com/mholt/caddy"
+	"github.com/miekg/dns"
+)
+
+func init() {
+	caddy.RegisterPlugin("local", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	l, err := localParse(c)
+	if err != nil {
+		return middleware.Error("local", err)
+	}
+
+	dnsserver.GetConfig(c).AddMiddleware(func(next middleware.Handler) middleware.Handler {
+		l.Next = next
+		return l
+	})
+
+	return nil
+}
+
+func localParse(c *caddy.Controller) (Local, error) {
+	l := Local{}
+	disabled := map[string]bool{}
+	hostname := ""
+
+	for c.Next() {
+		if c.NextArg() {
+			return l, c.ArgErr()
+		}
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "disable":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return l, c.ArgErr()
+				}
+				for _, a := range args {
+					switch a {
+					case "localhost", "invalid", "onion", "reverse":
+						disabled[a] = true
+					default:
+						return l, c.Errf("unknown name to disable: %s", a)
+					}
+				}
+			case "hostname":
+				if !c.NextArg() {
+					return l, c.ArgErr()
+				}
+				if _, ok := dns.IsDomainName(c.Val()); !ok {
+					return l, c.Errf("not a valid domain name: %s", c.Val())
+				}
+				hostname = dns.Fqdn(c.Val())
+				if c.NextArg() {
+					return l, c.ArgErr()
+				}
+			default:
+				return l, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+	}
+
+	for _, d := range []string{localhost, invalid, onion} {
+		if !disabled[d[:len(d)-1]] {
+			l.Domains = append(l.Domains, d)
+		}
+	}
+
+	if disabled["reverse"] {
+		return l, nil
+	}
+	if hostname == "" {
+		h, err := os.Hostname()
+		if err != nil {
+			h = "localhost"
+		}
+		hostname = dns.Fqdn(h)
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return l, err
+	}
+	l.Reverse = reverseNames(addrs, hostname)
+	return l, nil
+}
+
+// reverseNames returns the reverse names of the addresses in addrs. Loopback addresses
+// point to localhost., the others to hostname.
+func reverseNames(addrs []net.Addr, hostname string) map[string]string {
+	names := make(map[string]string)
+	for _, a := range addrs {
+		n, ok := a.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		rev, err := dns.ReverseAddr(n.IP.String())
+		if err != nil {
+			continue
+		}
+		if n.IP.IsLoopback() {
+			names[rev] = localhost
+			continue
+		}
+		names[rev] = hostname
+	}
+	return names
+}
@@ -0,0 +1,66 @@
+package local
+
+import (
+	"testing"
+
+	"github.com/mholt/caddy"
+)
+
+func TestSetupLocal(t *testing.T) {
+	tests := []struct {
+		input           string
+		shouldErr       bool
+		expectedDomains []string
+		expectedReverse bool
+	}{
+		{`local`, false, []string{"localhost.", "invalid.", "onion."}, true},
+		{`local {
+			disable onion reverse
+		}`, false, []string{"localhost.", "invalid."}, false},
+		{`local {
+			hostname ns1.example.org
+		}`, false, []string{"localhost.", "invalid.", "onion."}, true},
+		// fails
+		{`local example.org`, true, nil, false},
+		{`local {
+			disable
+		}`, true, nil, false},
+		{`local {
+			disable home.arpa
+		}`, true, nil, false},
+		{`local {
+			hostname
+		}`, true, nil, false},
+		{`local {
+			blaat
+		}`, true, nil, false},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		l, err := localParse(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %d: Expected error but found nil", i)
+			continue
+		}
+		if !test.shouldErr && err != nil {
+			t.Errorf("Test %d: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr {
+			continue
+		}
+		if len(l.Domains) != len(test.expectedDomains) {
+			t.Errorf("Test %d: Expected domains %v, got %v", i, test.expectedDomains, l.Domains)
+			continue
+		}
+		for j := range l.Domains {
+			if l.Domains[j] != test.expectedDomains[j] {
+				t.Errorf("Test %d: Expected domains %v, got %v", i, test.expectedDomains, l.Domains)
+			}
+		}
+		if (l.Reverse != nil) != test.expectedReverse {
+			t.Errorf("Test %d: Expected reverse names to be set: %t", i, test.expectedReverse)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/miekg/coredns/middleware"
 	"github.com/miekg/coredns/middleware/metrics"
 	"github.com/miekg/coredns/middleware/pkg/dnsrecorder"
+	"github.com/miekg/coredns/middleware/pkg/metadata"
 	"github.com/miekg/coredns/middleware/pkg/rcode"
 	"github.com/miekg/coredns/middleware/pkg/replacer"
 	"github.com/miekg/coredns/request"
@@ -26,40 +27,59 @@ type Logger struct {
 // ServeDNS implements the middleware.Handler interface.
 func (l Logger) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
 	state := request.Request{W: w, Req: r}
-	for _, rule := range l.Rules {
-		if middleware.Name(rule.NameScope).Matches(state.Name()) {
-			responseRecorder := dnsrecorder.New(w)
-			rc, err := l.Next.ServeDNS(ctx, responseRecorder, r)
+	if rule := l.match(state.Name()); rule != nil {
+		responseRecorder := dnsrecorder.New(w)
+		rc, err := l.Next.ServeDNS(ctx, responseRecorder, r)
 
-			if rc > 0 {
-				// There was an error up the chain, but no response has been written yet.
-				// The error must be handled here so the log entry will record the response size.
-				if l.ErrorFunc != nil {
-					l.ErrorFunc(responseRecorder, r, rc)
-				} else {
-					answer := new(dns.Msg)
-					answer.SetRcode(r, rc)
-					state.SizeAndDo(answer)
+		if rc > 0 {
+			// There was an error up the chain, but no response has been written yet.
+			// The error must be handled here so the log entry will record the response size.
+			if l.ErrorFunc != nil {
+				l.ErrorFunc(responseRecorder, r, rc)
+			} else {
+				answer := new(dns.Msg)
+				answer.SetRcode(r, rc)
+				state.SizeAndDo(answer)
 
-					metrics.Report(state, metrics.Dropped, rcode.ToString(rc), answer.Len(), time.Now())
-					w.WriteMsg(answer)
-				}
-				rc = 0
+				metrics.Report(state, metrics.Dropped, rcode.ToString(rc), answer.Len(), time.Now())
+				w.WriteMsg(answer)
 			}
-			rep := replacer.New(r, responseRecorder, CommonLogEmptyValue)
-			rule.Log.Println(rep.Replace(rule.Format))
-			return rc, err
-
+			rc = 0
+		}
+		rep := replacer.New(r, responseRecorder, CommonLogEmptyValue)
+		for _, label := range metadata.Labels(ctx) {
+			v, _ := metadata.Value(ctx, label)
+			rep.Set("/"+label, v)
 		}
+		rule.Log.Println(rep.Replace(rule.Format))
+		return rc, err
 	}
 	return l.Next.ServeDNS(ctx, w, r)
 }
 
+// match returns the rule with the longest name scope that contains name, so every zone
+// can have a log of its own. Of rules with the same scope the first is used. It returns
+// nil if no rule matches.
+func (l Logger) match(name string) *Rule {
+	var best *Rule
+	for i := range l.Rules {
+		rule := &l.Rules[i]
+		if !middleware.Name(rule.NameScope).Matches(name) {
+			continue
+		}
+		if best == nil || len(rule.NameScope) > len(best.NameScope) {
+			best = rule
+		}
+	}
+	return best
+}
+
 // Rule configures the logging middleware.
 type Rule struct {
 	NameScope  string
 	OutputFile string
 	Format     string
+	Rotate     *Rotate // rotation of OutputFile, nil never rotates it
 	Log        *log.Logger
 }
 
@@ -72,6 +92,11 @@ const (
 	CommonLogEmptyValue = "-"
 	// CombinedLogFormat is the combined log format.
 	CombinedLogFormat = CommonLogFormat + ` "{>opcode}"`
+	// W3CLogFormat is the W3C extended log format, with the fields in W3CLogHeader.
+	W3CLogFormat = `{date} {time} {remote} {port} {proto} {type} {class} {name} {rcode} {size} {duration}`
+	// W3CLogHeader starts every file written in the W3C extended log format. The fields
+	// that have no W3C name have the x- prefix.
+	W3CLogHeader = "#Version: 1.0\n#Fields: date time c-ip c-port cs-protocol x-qtype x-qclass x-qname x-rcode sc-bytes x-duration\n"
 	// DefaultLogFormat is the default log format.
 	DefaultLogFormat = CommonLogFormat
 )
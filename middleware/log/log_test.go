@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/miekg/coredns/middleware/pkg/dnsrecorder"
+	"github.com/miekg/coredns/middleware/pkg/metadata"
 	"github.com/miekg/coredns/middleware/test"
 
 	"github.com/miekg/dns"
@@ -49,3 +50,45 @@ func TestLoggedStatus(t *testing.T) {
 		t.Error("Expected it to be logged. Logged string -", logged)
 	}
 }
+
+func TestLoggedZone(t *testing.T) {
+	var all, org bytes.Buffer
+	logger := Logger{
+		Rules: []Rule{
+			{NameScope: ".", Format: "{name}", Log: log.New(&all, "", 0)},
+			{NameScope: "example.org.", Format: "{name}", Log: log.New(&org, "", 0)},
+		},
+		Next: erroringMiddleware{},
+	}
+
+	for _, name := range []string{"www.example.org.", "example.net."} {
+		r := new(dns.Msg)
+		r.SetQuestion(name, dns.TypeA)
+		logger.ServeDNS(context.TODO(), dnsrecorder.New(&test.ResponseWriter{}), r)
+	}
+
+	if x := org.String(); x != "www.example.org.\n" {
+		t.Errorf("Expected only www.example.org. in the log of example.org., got %q", x)
+	}
+	if x := all.String(); x != "example.net.\n" {
+		t.Errorf("Expected only example.net. in the log of ., got %q", x)
+	}
+}
+
+func TestLoggedLabels(t *testing.T) {
+	var f bytes.Buffer
+	logger := Logger{
+		Rules: []Rule{{NameScope: ".", Format: "{name} {/kubernetes/client-namespace} {/kubernetes/client-pod-name}", Log: log.New(&f, "", 0)}},
+		Next:  erroringMiddleware{},
+	}
+
+	ctx := metadata.NewContext(context.TODO())
+	metadata.Set(ctx, "kubernetes/client-namespace", "default")
+	r := new(dns.Msg)
+	r.SetQuestion("example.org.", dns.TypeA)
+	logger.ServeDNS(ctx, dnsrecorder.New(&test.ResponseWriter{}), r)
+
+	if x := f.String(); x != "example.org. default -\n" {
+		t.Errorf("Expected the labels to be logged, got %q", x)
+	}
+}
@@ -0,0 +1,143 @@
+package log
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Rotate holds the rotation settings of a log file.
+type Rotate struct {
+	Size int // rotate the file when it grows over this many megabytes, 0 never rotates
+	Age  int // remove rotated files older than this many days, 0 keeps them
+	Keep int // the number of rotated files kept, 0 keeps them all
+}
+
+// rotator is an io.Writer that appends to a file. When the file grows over its size, it is
+// renamed with the time appended to the name, and a new one is started. Every new file
+// starts with header.
+type rotator struct {
+	sync.Mutex
+	name   string
+	rotate Rotate
+	header string
+
+	file *os.File
+	size int64
+}
+
+// newRotator opens the file name for appending. rotate may be nil.
+func newRotator(name string, rotate *Rotate, header string) (*rotator, error) {
+	r := &rotator{name: name, header: header}
+	if rotate != nil {
+		r.rotate = *rotate
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Write implements io.Writer.
+func (r *rotator) Write(p []byte) (int, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.file == nil {
+		return 0, errClosed
+	}
+	max := int64(r.rotate.Size) * megabyte
+	if max > 0 && r.size > 0 && r.size+int64(len(p)) > max {
+		if err := r.roll(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Close closes the file.
+func (r *rotator) Close() error {
+	r.Lock()
+	defer r.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+func (r *rotator) open() error {
+	file, err := os.OpenFile(r.name, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	r.file, r.size = file, fi.Size()
+	if r.size == 0 && r.header != "" {
+		n, err := file.WriteString(r.header)
+		r.size += int64(n)
+		return err
+	}
+	return nil
+}
+
+// roll moves the file aside, starts a new one and removes the rotated files that are too
+// old, or too many.
+func (r *rotator) roll() error {
+	r.file.Close()
+	r.file = nil
+	if err := os.Rename(r.name, r.name+"."+time.Now().Format(rotateFormat)); err != nil {
+		return err
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+	r.prune()
+	return nil
+}
+
+func (r *rotator) prune() {
+	if r.rotate.Age == 0 && r.rotate.Keep == 0 {
+		return
+	}
+	rotated, err := filepath.Glob(r.name + ".*")
+	if err != nil {
+		return
+	}
+	// The times in the names sort in the order the files were rotated in.
+	sort.Sort(sort.Reverse(sort.StringSlice(rotated)))
+
+	cutoff := time.Now().Add(-time.Duration(r.rotate.Age) * 24 * time.Hour)
+	kept := 0
+	for _, name := range rotated {
+		if _, err := time.Parse(rotateFormat, name[len(r.name)+1:]); err != nil {
+			// Not one of ours.
+			continue
+		}
+		kept++
+		if r.rotate.Keep > 0 && kept > r.rotate.Keep {
+			os.Remove(name)
+			continue
+		}
+		if fi, err := os.Stat(name); err == nil && r.rotate.Age > 0 && fi.ModTime().Before(cutoff) {
+			os.Remove(name)
+		}
+	}
+}
+
+var errClosed = errors.New("log file is closed")
+
+const (
+	megabyte     = 1024 * 1024
+	rotateFormat = "20060102-150405.000"
+)
@@ -0,0 +1,51 @@
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotator(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log-rotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "query.log")
+	r, err := newRotator(name, &Rotate{Size: 1, Keep: 2}, W3CLogHeader)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	defer r.Close()
+
+	line := []byte(strings.Repeat("x", megabyte/2) + "\n")
+	for i := 0; i < 8; i++ {
+		if _, err := r.Write(line); err != nil {
+			t.Fatalf("Expected no error, got %s", err)
+		}
+		// The rotated files are named after the time, in milliseconds.
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	rotated, _ := filepath.Glob(name + ".*")
+	if len(rotated) != 2 {
+		t.Errorf("Expected 2 rotated files, got %v", rotated)
+	}
+	for _, f := range append(rotated, name) {
+		buf, err := ioutil.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(buf), W3CLogHeader) {
+			t.Errorf("Expected %s to start with the header", f)
+		}
+		if len(buf) > megabyte {
+			t.Errorf("Expected %s to be at most 1MB, got %d bytes", f, len(buf))
+		}
+	}
+}
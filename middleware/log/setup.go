@@ -4,6 +4,7 @@ import (
 	"io"
 	"log"
 	"os"
+	"strconv"
 
 	"github.com/miekg/coredns/core/dnsserver"
 	"github.com/miekg/coredns/middleware"
@@ -27,6 +28,7 @@ func setup(c *caddy.Controller) error {
 	}
 
 	// Open the log files for writing when the server starts
+	var files []*rotator
 	c.OnStartup(func() error {
 		for i := 0; i < len(rules); i++ {
 			var err error
@@ -42,11 +44,15 @@ func setup(c *caddy.Controller) error {
 					return middleware.Error("log", err)
 				}
 			} else {
-				var file *os.File
-				file, err = os.OpenFile(rules[i].OutputFile, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+				header := ""
+				if rules[i].Format == W3CLogFormat {
+					header = W3CLogHeader
+				}
+				file, err := newRotator(rules[i].OutputFile, rules[i].Rotate, header)
 				if err != nil {
 					return middleware.Error("log", err)
 				}
+				files = append(files, file)
 				writer = file
 			}
 
@@ -56,6 +62,13 @@ func setup(c *caddy.Controller) error {
 		return nil
 	})
 
+	c.OnShutdown(func() error {
+		for _, f := range files {
+			f.Close()
+		}
+		return nil
+	})
+
 	dnsserver.GetConfig(c).AddMiddleware(func(next middleware.Handler) middleware.Handler {
 		return Logger{Next: next, Rules: rules, ErrorFunc: dnsserver.DefaultErrorFunc}
 	})
@@ -69,20 +82,21 @@ func logParse(c *caddy.Controller) ([]Rule, error) {
 	for c.Next() {
 		args := c.RemainingArgs()
 
+		var rule Rule
 		if len(args) == 0 {
 			// Nothing specified; use defaults
-			rules = append(rules, Rule{
+			rule = Rule{
 				NameScope:  ".",
 				OutputFile: DefaultLogFilename,
 				Format:     DefaultLogFormat,
-			})
+			}
 		} else if len(args) == 1 {
 			// Only an output file specified
-			rules = append(rules, Rule{
+			rule = Rule{
 				NameScope:  ".",
 				OutputFile: args[0],
 				Format:     DefaultLogFormat,
-			})
+			}
 		} else {
 			// Name scope, output file, and maybe a format specified
 
@@ -94,18 +108,72 @@ func logParse(c *caddy.Controller) ([]Rule, error) {
 					format = CommonLogFormat
 				case "{combined}":
 					format = CombinedLogFormat
+				case "{w3c}":
+					format = W3CLogFormat
 				default:
 					format = args[2]
 				}
 			}
 
-			rules = append(rules, Rule{
+			rule = Rule{
 				NameScope:  dns.Fqdn(args[0]),
 				OutputFile: args[1],
 				Format:     format,
-			})
+			}
 		}
+
+		for c.NextBlock() {
+			if rule.Rotate == nil {
+				rule.Rotate = &Rotate{}
+			}
+			switch c.Val() {
+			case "rotate_size":
+				n, err := parseRotate(c)
+				if err != nil {
+					return nil, err
+				}
+				rule.Rotate.Size = n
+			case "rotate_age":
+				n, err := parseRotate(c)
+				if err != nil {
+					return nil, err
+				}
+				rule.Rotate.Age = n
+			case "rotate_keep":
+				n, err := parseRotate(c)
+				if err != nil {
+					return nil, err
+				}
+				rule.Rotate.Keep = n
+			default:
+				return nil, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+		if rule.Rotate != nil {
+			switch rule.OutputFile {
+			case "stdout", "stderr", "syslog":
+				return nil, c.Errf("can't rotate %s", rule.OutputFile)
+			}
+		}
+
+		rules = append(rules, rule)
 	}
 
 	return rules, nil
 }
+
+// parseRotate parses the argument of a rotate property, a number that is not negative.
+func parseRotate(c *caddy.Controller) (int, error) {
+	what := c.Val()
+	if !c.NextArg() {
+		return 0, c.ArgErr()
+	}
+	n, err := strconv.Atoi(c.Val())
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, c.Errf("%s can't be negative: %d", what, n)
+	}
+	return n, nil
+}
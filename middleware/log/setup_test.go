@@ -1,6 +1,7 @@
 package log
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/mholt/caddy"
@@ -62,6 +63,24 @@ func TestLogParse(t *testing.T) {
 			OutputFile: "log.txt",
 			Format:     "{when}",
 		}}},
+		{`log example.org log.txt {w3c} {
+			rotate_size 100
+			rotate_keep 10
+		  }`, false, []Rule{{
+			NameScope:  "example.org.",
+			OutputFile: "log.txt",
+			Format:     W3CLogFormat,
+			Rotate:     &Rotate{Size: 100, Keep: 10},
+		}}},
+		{`log example.org stdout {
+			rotate_size 100
+		  }`, true, []Rule{}},
+		{`log example.org log.txt {
+			rotate_age -1
+		  }`, true, []Rule{}},
+		{`log example.org log.txt {
+			rotate_what 1
+		  }`, true, []Rule{}},
 	}
 	for i, test := range tests {
 		c := caddy.NewTestController("dns", test.inputLogRules)
@@ -92,6 +111,11 @@ func TestLogParse(t *testing.T) {
 				t.Errorf("Test %d expected %dth LogRule Format to be  %s  , but got %s",
 					i, j, test.expectedLogRules[j].Format, actualLogRule.Format)
 			}
+
+			if !reflect.DeepEqual(actualLogRule.Rotate, test.expectedLogRules[j].Rotate) {
+				t.Errorf("Test %d expected %dth LogRule Rotate to be %v, but got %v",
+					i, j, test.expectedLogRules[j].Rotate, actualLogRule.Rotate)
+			}
 		}
 	}
 
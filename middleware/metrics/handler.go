@@ -3,6 +3,7 @@ package metrics
 import (
 	"time"
 
+	"github.com/miekg/coredns/core/dnsserver"
 	"github.com/miekg/coredns/middleware"
 	"github.com/miekg/coredns/middleware/pkg/dnsrecorder"
 	"github.com/miekg/coredns/middleware/pkg/rcode"
@@ -22,7 +23,15 @@ func (m Metrics) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 		zone = "."
 	}
 
-	// Record response to get status code and size of the reply.
+	// The server records the response, only when we are called outside of it (in tests)
+	// we need to record it ourselves.
+	if rw := dnsserver.Writer(ctx); rw != nil {
+		start := time.Now()
+		status, err := m.Next.ServeDNS(ctx, w, r)
+		Report(state, zone, rcode.ToString(rw.Rcode()), rw.Size(), start)
+		return status, err
+	}
+
 	rw := dnsrecorder.New(w)
 	status, err := m.Next.ServeDNS(ctx, rw, r)
 
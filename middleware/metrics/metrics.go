@@ -1,6 +1,8 @@
 package metrics
 
 import (
+	"crypto/subtle"
+	"crypto/tls"
 	"log"
 	"net"
 	"net/http"
@@ -27,26 +29,32 @@ var (
 // Metrics holds the prometheus configuration. The metrics' path is fixed to be /metrics
 type Metrics struct {
 	Next      middleware.Handler
-	Addr      string
-	ln        net.Listener
+	Addrs     []string    // addresses the metrics are served on
+	TLSConfig *tls.Config // when set the metrics are served over HTTPS
+	User      string      // when set HTTP basic authentication is required
+	Password  string
+	lns       []net.Listener
 	mux       *http.ServeMux
-	Once      *sync.Once
 	ZoneNames []string
 }
 
 // OnStartup sets up the metrics on startup.
 func (m *Metrics) OnStartup() error {
-	m.Once.Do(func() {
+	registerOnce.Do(func() {
 		define()
 
-		ln, err := net.Listen("tcp", m.Addr)
-		if err != nil {
-			log.Printf("[ERROR] Failed to start metrics handler: %s", err)
-			return
+		for _, addr := range m.Addrs {
+			ln, err := net.Listen("tcp", addr)
+			if err != nil {
+				log.Printf("[ERROR] Failed to start metrics handler: %s", err)
+				continue
+			}
+			if m.TLSConfig != nil {
+				ln = tls.NewListener(ln, m.TLSConfig)
+			}
+			m.lns = append(m.lns, ln)
 		}
 
-		m.ln = ln
-
 		m.mux = http.NewServeMux()
 
 		prometheus.MustRegister(requestCount)
@@ -60,23 +68,47 @@ func (m *Metrics) OnStartup() error {
 		prometheus.MustRegister(responseTransferSize)
 		prometheus.MustRegister(responseRcode)
 
-		m.mux.Handle(path, prometheus.Handler())
+		m.mux.Handle(path, m.auth(prometheus.Handler()))
 
-		go func() {
-			http.Serve(m.ln, m.mux)
-		}()
+		for _, ln := range m.lns {
+			go http.Serve(ln, m.mux)
+		}
 	})
 	return nil
 }
 
 // OnShutdown tears down the metrics on shutdown.
 func (m *Metrics) OnShutdown() error {
-	if m.ln != nil {
-		return m.ln.Close()
+	var err error
+	for _, ln := range m.lns {
+		if err1 := ln.Close(); err1 != nil {
+			err = err1
+		}
 	}
-	return nil
+	return err
+}
+
+// auth wraps h so it requires HTTP basic authentication, if a user is configured.
+func (m *Metrics) auth(h http.Handler) http.Handler {
+	if m.User == "" {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if !ok ||
+			subtle.ConstantTimeCompare([]byte(user), []byte(m.User)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(password), []byte(m.Password)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="metrics"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
 }
 
+// registerOnce makes sure the metrics are defined and registered only once.
+var registerOnce sync.Once
+
 func define() {
 	requestCount = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Namespace: middleware.Namespace,
@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuth(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	m := &Metrics{User: "prom", Password: "secret"}
+	h := m.auth(ok)
+
+	tests := []struct {
+		user, password string
+		expectedStatus int
+	}{
+		{"prom", "secret", http.StatusOK},
+		{"prom", "wrong", http.StatusUnauthorized},
+		{"other", "secret", http.StatusUnauthorized},
+		{"", "", http.StatusUnauthorized},
+	}
+	for i, tc := range tests {
+		r := httptest.NewRequest("GET", path, nil)
+		if tc.user != "" {
+			r.SetBasicAuth(tc.user, tc.password)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tc.expectedStatus {
+			t.Errorf("Test %d: expected status %d, got %d", i, tc.expectedStatus, w.Code)
+		}
+	}
+
+	// Without a user, no authentication is needed.
+	w := httptest.NewRecorder()
+	(&Metrics{}).auth(ok).ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status %d without authentication, got %d", http.StatusOK, w.Code)
+	}
+}
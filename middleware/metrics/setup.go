@@ -1,10 +1,13 @@
 package metrics
 
 import (
+	"crypto/tls"
+	"log"
 	"sync"
 
 	"github.com/miekg/coredns/core/dnsserver"
 	"github.com/miekg/coredns/middleware"
+	mwtls "github.com/miekg/coredns/middleware/pkg/tls"
 
 	"github.com/mholt/caddy"
 )
@@ -36,10 +39,7 @@ func setup(c *caddy.Controller) error {
 }
 
 func prometheusParse(c *caddy.Controller) (Metrics, error) {
-	var (
-		met Metrics
-		err error
-	)
+	var met Metrics
 
 	for c.Next() {
 		if len(met.ZoneNames) > 0 {
@@ -55,7 +55,7 @@ func prometheusParse(c *caddy.Controller) (Metrics, error) {
 		switch len(args) {
 		case 0:
 		case 1:
-			met.Addr = args[0]
+			met.Addrs = []string{args[0]}
 		default:
 			return Metrics{}, c.ArgErr()
 		}
@@ -63,22 +63,45 @@ func prometheusParse(c *caddy.Controller) (Metrics, error) {
 			switch c.Val() {
 			case "address":
 				args = c.RemainingArgs()
-				if len(args) != 1 {
+				if len(args) == 0 {
 					return Metrics{}, c.ArgErr()
 				}
-				met.Addr = args[0]
+				met.Addrs = args
+			case "tls":
+				args = c.RemainingArgs()
+				if len(args) < 2 || len(args) > 3 {
+					return Metrics{}, c.ArgErr()
+				}
+				tlsConfig, err := mwtls.NewTLSConfigFromArgs(args...)
+				if err != nil {
+					return Metrics{}, err
+				}
+				if len(args) == 3 {
+					// With a CA, clients must present a certificate signed by it.
+					tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+				}
+				met.TLSConfig = tlsConfig
+			case "basicauth":
+				args = c.RemainingArgs()
+				if len(args) != 2 {
+					return Metrics{}, c.ArgErr()
+				}
+				met.User, met.Password = args[0], args[1]
 			default:
 				return Metrics{}, c.Errf("metrics: unknown item: %s", c.Val())
 			}
 
 		}
 	}
-	if met.Addr == "" {
-		met.Addr = addr
+	if len(met.Addrs) == 0 {
+		met.Addrs = []string{addr}
+	}
+	if met.User != "" && met.TLSConfig == nil {
+		log.Printf("[WARNING] Metrics use basic authentication without TLS, the password is sent in the clear")
 	}
-	return met, err
+	return met, nil
 }
 
-var metricsOnce *sync.Once
+var metricsOnce sync.Once
 
 const addr = "localhost:9153"
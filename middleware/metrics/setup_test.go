@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/mholt/caddy"
+)
+
+func TestPrometheusParse(t *testing.T) {
+	tests := []struct {
+		input         string
+		shouldErr     bool
+		expectedAddrs []string
+		expectedUser  string
+	}{
+		{`prometheus`, false, []string{"localhost:9153"}, ""},
+		{`prometheus 10.0.0.1:9153`, false, []string{"10.0.0.1:9153"}, ""},
+		{`prometheus {
+			address 10.0.0.1:9153 [fd00::1]:9153
+			basicauth prom secret
+		}`, false, []string{"10.0.0.1:9153", "[fd00::1]:9153"}, "prom"},
+		// fails
+		{`prometheus a b`, true, nil, ""},
+		{`prometheus {
+			address
+		}`, true, nil, ""},
+		{`prometheus {
+			basicauth prom
+		}`, true, nil, ""},
+		{`prometheus {
+			tls cert.pem
+		}`, true, nil, ""},
+		{`prometheus {
+			tls /does/not/exist.pem /does/not/exist.key
+		}`, true, nil, ""},
+		{`prometheus {
+			blaat
+		}`, true, nil, ""},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		m, err := prometheusParse(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %d: Expected error but found nil", i)
+			continue
+		}
+		if !test.shouldErr && err != nil {
+			t.Errorf("Test %d: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr {
+			continue
+		}
+		if len(m.Addrs) != len(test.expectedAddrs) {
+			t.Errorf("Test %d: Expected addresses %v, got %v", i, test.expectedAddrs, m.Addrs)
+			continue
+		}
+		for j := range m.Addrs {
+			if m.Addrs[j] != test.expectedAddrs[j] {
+				t.Errorf("Test %d: Expected addresses %v, got %v", i, test.expectedAddrs, m.Addrs)
+			}
+		}
+		if m.User != test.expectedUser {
+			t.Errorf("Test %d: Expected user %q, got %q", i, test.expectedUser, m.User)
+		}
+	}
+}
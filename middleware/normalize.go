@@ -4,6 +4,8 @@ import (
 	"net"
 	"strings"
 
+	"github.com/miekg/coredns/middleware/pkg/dnsutil"
+
 	"github.com/miekg/dns"
 )
 
@@ -33,6 +35,16 @@ func (z Zones) Normalize() {
 	}
 }
 
+// NormalizeExact returns the zones in z fully qualified, where CIDRs are expanded
+// in to reverse zones, see Host.NormalizeExact.
+func (z Zones) NormalizeExact() Zones {
+	var zones Zones
+	for i := range z {
+		zones = append(zones, Host(z[i]).NormalizeExact()...)
+	}
+	return zones
+}
+
 // Name represents a domain name.
 type Name string
 
@@ -45,8 +57,14 @@ func (n Name) Matches(child string) bool {
 	return dns.IsSubDomain(string(n), child)
 }
 
-// Normalize lowercases and makes n fully qualified.
-func (n Name) Normalize() string { return strings.ToLower(dns.Fqdn(string(n))) }
+// Normalize lowercases and makes n fully qualified. Internationalized names are
+// converted to their ASCII (punycode) form, see dnsutil.Zone.
+func (n Name) Normalize() string {
+	if z, err := dnsutil.Zone(string(n)); err == nil {
+		return z
+	}
+	return strings.ToLower(dns.Fqdn(string(n)))
+}
 
 type (
 	// Host represents a host from the Corefile, may contain port.
@@ -56,16 +74,35 @@ type (
 )
 
 // Normalize will return the host portion of host, stripping
-// of any port. The host will also be fully qualified and lowercased.
+// of any port or transport. The host will also be fully qualified and lowercased.
 func (h Host) Normalize() string {
+	s := string(h)
+	if i := strings.Index(s, "://"); i >= 0 {
+		s = s[i+len("://"):]
+	}
 	// separate host and port
-	host, _, err := net.SplitHostPort(string(h))
+	host, _, err := net.SplitHostPort(s)
 	if err != nil {
-		host, _, _ = net.SplitHostPort(string(h) + ":")
+		host, _, _ = net.SplitHostPort(s + ":")
 	}
 	return Name(host).Normalize()
 }
 
+// NormalizeExact is like Normalize, but when h holds a CIDR, like 10.0.0.0/15, the
+// reverse zones that cover that network are returned.
+func (h Host) NormalizeExact() []string {
+	s := string(h)
+	if i := strings.Index(s, "://"); i >= 0 {
+		s = s[i+len("://"):]
+	}
+	if cidr, _, ok := dnsutil.SplitCIDR(s); ok {
+		if zones, err := dnsutil.ReverseZones(cidr); err == nil {
+			return zones
+		}
+	}
+	return []string{h.Normalize()}
+}
+
 // Normalize will return a normalized address, if not port is specified
 // port 53 is added, otherwise the port will be left as is.
 func (a Addr) Normalize() string {
@@ -24,6 +24,15 @@ func TestZoneNormalize(t *testing.T) {
 	expected := "example.org."
 	zones.Normalize()
 
+	for _, actual := range zones {
+		if actual != expected {
+			t.Errorf("Expected %v, got %v\n", expected, actual)
+		}
+	}
+	zones = Zones([]string{"bücher.example", "BÜCHER.example.", "xn--bcher-kva.example"})
+	expected = "xn--bcher-kva.example."
+	zones.Normalize()
+
 	for _, actual := range zones {
 		if actual != expected {
 			t.Errorf("Expected %v, got %v\n", expected, actual)
@@ -94,3 +103,29 @@ func TestAddrNormalize(t *testing.T) {
 	}
 
 }
+
+func TestHostNormalizeExact(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected []string
+	}{
+		{"example.org", []string{"example.org."}},
+		{"example.org.:53", []string{"example.org."}},
+		{"https://example.org.:443", []string{"example.org."}},
+		{"10.0.0.0/15", []string{"0.10.in-addr.arpa.", "1.10.in-addr.arpa."}},
+		{"10.0.0.0/8:53", []string{"10.in-addr.arpa."}},
+	}
+
+	for i, tc := range tests {
+		actual := Host(tc.in).NormalizeExact()
+		if len(actual) != len(tc.expected) {
+			t.Errorf("Test %d: expected %v, got %v", i, tc.expected, actual)
+			continue
+		}
+		for j := range actual {
+			if actual[j] != tc.expected[j] {
+				t.Errorf("Test %d: expected %v, got %v", i, tc.expected, actual)
+			}
+		}
+	}
+}
@@ -0,0 +1,28 @@
+// Package override implements the override directive that allows the zones of a server
+// block to overlap with those of other server blocks.
+package override
+
+import (
+	"github.com/miekg/coredns/core/dnsserver"
+	"github.com/miekg/coredns/middleware"
+
+	"github.com/mholt/caddy"
+)
+
+func init() {
+	caddy.RegisterPlugin("override", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setupOverride,
+	})
+}
+
+func setupOverride(c *caddy.Controller) error {
+	config := dnsserver.GetConfig(c)
+	for c.Next() {
+		if len(c.RemainingArgs()) != 0 {
+			return middleware.Error("override", c.ArgErr())
+		}
+		config.Override = true
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+// Package audit keeps a trail of what happened while a query was being handled.
+//
+// The trail is carried in the context. Middleware add entries to it with Add, which
+// is a no-op when there is no trail in the context, so the common case costs next
+// to nothing.
+package audit
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Entry is a single event in a Trail.
+type Entry struct {
+	Middleware string        // name of the middleware that added the entry
+	Msg        string        // what happened
+	Elapsed    time.Duration // time since the start of the trail
+}
+
+// String returns the entry as "middleware: msg (+elapsed)".
+func (e Entry) String() string {
+	return fmt.Sprintf("%s: %s (+%s)", e.Middleware, e.Msg, e.Elapsed)
+}
+
+// Trail is an ordered list of entries. It is safe for concurrent use.
+type Trail struct {
+	start time.Time
+
+	sync.Mutex
+	entries []Entry
+}
+
+// New returns a new, empty Trail that starts now.
+func New() *Trail { return &Trail{start: time.Now()} }
+
+// Add adds an entry to the trail.
+func (t *Trail) Add(middleware, format string, a ...interface{}) {
+	e := Entry{Middleware: middleware, Msg: fmt.Sprintf(format, a...), Elapsed: time.Since(t.start)}
+	t.Lock()
+	t.entries = append(t.entries, e)
+	t.Unlock()
+}
+
+// Entries returns a copy of the entries in the trail.
+func (t *Trail) Entries() []Entry {
+	t.Lock()
+	defer t.Unlock()
+	e := make([]Entry, len(t.entries))
+	copy(e, t.entries)
+	return e
+}
+
+// Elapsed returns the time since the start of the trail.
+func (t *Trail) Elapsed() time.Duration { return time.Since(t.start) }
+
+type key struct{}
+
+// NewContext returns a new context that carries t.
+func NewContext(ctx context.Context, t *Trail) context.Context {
+	return context.WithValue(ctx, key{}, t)
+}
+
+// FromContext returns the Trail in ctx, or nil if there is none.
+func FromContext(ctx context.Context) *Trail {
+	t, _ := ctx.Value(key{}).(*Trail)
+	return t
+}
+
+// Add adds an entry to the trail in ctx. If ctx does not carry a trail it returns at
+// once, without formatting the entry.
+func Add(ctx context.Context, middleware, format string, a ...interface{}) {
+	t := FromContext(ctx)
+	if t == nil {
+		return
+	}
+	t.Add(middleware, format, a...)
+}
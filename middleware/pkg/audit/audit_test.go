@@ -0,0 +1,36 @@
+package audit
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestAdd(t *testing.T) {
+	// Without a trail this must not panic.
+	Add(context.Background(), "cache", "miss")
+
+	tr := New()
+	ctx := NewContext(context.Background(), tr)
+	Add(ctx, "cache", "miss")
+	Add(ctx, "proxy", "answered by %s", "8.8.8.8:53")
+
+	e := tr.Entries()
+	if len(e) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(e))
+	}
+	if e[1].Middleware != "proxy" || e[1].Msg != "answered by 8.8.8.8:53" {
+		t.Errorf("Expected proxy entry, got %s", e[1])
+	}
+	if !strings.HasPrefix(e[0].String(), "cache: miss (+") {
+		t.Errorf("Expected entry to start with %q, got %q", "cache: miss (+", e[0].String())
+	}
+}
+
+func TestAddNoTrail(t *testing.T) {
+	ctx := context.Background()
+	if n := testing.AllocsPerRun(100, func() { Add(ctx, "cache", "miss") }); n != 0 {
+		t.Errorf("Expected no allocations without a trail, got %v", n)
+	}
+}
@@ -0,0 +1,71 @@
+// Package cname limits the CNAME chains that middleware follow to build an answer, so
+// CNAME loops in a backend don't make them recurse forever.
+package cname
+
+import (
+	"strings"
+
+	"github.com/miekg/coredns/middleware/pkg/edns"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+var (
+	// ErrLoop is returned when following a CNAME leads back to a name in the chain.
+	ErrLoop error = &edns.ExtendedError{InfoCode: edns.ExtendedErrorOther, ExtraText: "CNAME loop"}
+	// ErrDepth is returned when following a CNAME makes the chain longer than allowed.
+	ErrDepth error = &edns.ExtendedError{InfoCode: edns.ExtendedErrorOther, ExtraText: "CNAME chain too long"}
+)
+
+// DefaultMaxDepth is the number of CNAMEs a chain may have if the server sets no limit.
+const DefaultMaxDepth = 8
+
+// NewContext returns a context with max as the number of CNAMEs a chain may have.
+func NewContext(ctx context.Context, max int) context.Context {
+	return context.WithValue(ctx, maxDepthKey{}, max)
+}
+
+// MaxDepth returns the number of CNAMEs a chain may have for the query of ctx, or
+// DefaultMaxDepth if ctx doesn't say.
+func MaxDepth(ctx context.Context) int {
+	if max, ok := ctx.Value(maxDepthKey{}).(int); ok && max > 0 {
+		return max
+	}
+	return DefaultMaxDepth
+}
+
+type maxDepthKey struct{}
+
+// Follow returns an error if next can't be added to the CNAMEs in chain: ErrLoop if its
+// target is already in the chain, or is its own name, and ErrDepth if the chain would
+// have more than max CNAMEs; 0 is DefaultMaxDepth. The records in chain that aren't
+// CNAMEs are ignored.
+func Follow(chain []dns.RR, next *dns.CNAME, max int) error {
+	if max <= 0 {
+		max = DefaultMaxDepth
+	}
+	if strings.EqualFold(next.Hdr.Name, next.Target) {
+		return ErrLoop
+	}
+	n := 0
+	for _, r := range chain {
+		c, ok := r.(*dns.CNAME)
+		if !ok {
+			continue
+		}
+		if strings.EqualFold(c.Hdr.Name, next.Target) || strings.EqualFold(c.Target, next.Target) {
+			return ErrLoop
+		}
+		n++
+	}
+	if n >= max {
+		return ErrDepth
+	}
+	return nil
+}
+
+// IsError returns true if err is ErrLoop or ErrDepth.
+func IsError(err error) bool {
+	return err == ErrLoop || err == ErrDepth
+}
@@ -0,0 +1,46 @@
+package cname
+
+import (
+	"testing"
+
+	"github.com/miekg/coredns/middleware/test"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+func TestFollow(t *testing.T) {
+	chain := []dns.RR{
+		test.CNAME("a.example.org. 300 IN CNAME b.example.org."),
+		test.CNAME("b.example.org. 300 IN CNAME c.example.org."),
+		test.A("d.example.org. 300 IN A 127.0.0.1"),
+	}
+
+	tests := []struct {
+		next     string
+		max      int
+		expected error
+	}{
+		{"c.example.org. 300 IN CNAME d.example.org.", 8, nil},
+		{"c.example.org. 300 IN CNAME d.example.org.", 3, nil},
+		{"c.example.org. 300 IN CNAME d.example.org.", 2, ErrDepth},
+		{"c.example.org. 300 IN CNAME a.example.org.", 8, ErrLoop},
+		{"c.example.org. 300 IN CNAME B.example.org.", 8, ErrLoop},
+		{"c.example.org. 300 IN CNAME c.example.org.", 8, ErrLoop},
+		{"c.example.org. 300 IN CNAME d.example.org.", 0, nil},
+	}
+	for i, tc := range tests {
+		if err := Follow(chain, test.CNAME(tc.next), tc.max); err != tc.expected {
+			t.Errorf("Test %d: expected %v, got %v", i, tc.expected, err)
+		}
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	if max := MaxDepth(context.TODO()); max != DefaultMaxDepth {
+		t.Errorf("Expected default of %d, got %d", DefaultMaxDepth, max)
+	}
+	if max := MaxDepth(NewContext(context.TODO(), 3)); max != 3 {
+		t.Errorf("Expected 3, got %d", max)
+	}
+}
@@ -5,23 +5,31 @@ import "strings"
 // ExtractAddressFromReverse turns a standard PTR reverse record name
 // into an IP address. This works for ipv4 or ipv6.
 //
-// 54.119.58.176.in-addr.arpa. becomes 176.58.119.54. If the conversion
-// failes the empty string is returned.
+// 54.119.58.176.in-addr.arpa. becomes 176.58.119.54, the name of a full IPv6 address in
+// ip6.arpa. becomes the address with its groups written out, like
+// 2001:0db8:0000:0000:0000:0000:0000:0001. If the conversion fails the empty string is
+// returned.
 func ExtractAddressFromReverse(reverseName string) string {
-	search := ""
-
 	switch {
 	case strings.HasSuffix(reverseName, v4arpaSuffix):
-		search = strings.TrimSuffix(reverseName, v4arpaSuffix)
+		search := strings.TrimSuffix(reverseName, v4arpaSuffix)
+		// Reverse the segments and then combine them.
+		segments := reverse(strings.Split(search, "."))
+		return strings.Join(segments, ".")
 	case strings.HasSuffix(reverseName, v6arpaSuffix):
-		search = strings.TrimSuffix(reverseName, v6arpaSuffix)
-	default:
-		return ""
+		search := strings.TrimSuffix(reverseName, v6arpaSuffix)
+		// The nibbles of the address, in groups of four.
+		nibbles := reverse(strings.Split(search, "."))
+		if len(nibbles) != 32 {
+			return ""
+		}
+		groups := make([]string, 0, 8)
+		for i := 0; i < len(nibbles); i += 4 {
+			groups = append(groups, strings.Join(nibbles[i:i+4], ""))
+		}
+		return strings.Join(groups, ":")
 	}
-
-	// Reverse the segments and then combine them.
-	segments := reverse(strings.Split(search, "."))
-	return strings.Join(segments, ".")
+	return ""
 }
 
 func reverse(slice []string) []string {
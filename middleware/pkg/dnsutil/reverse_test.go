@@ -0,0 +1,21 @@
+package dnsutil
+
+import "testing"
+
+func TestExtractAddressFromReverse(t *testing.T) {
+	tests := []struct {
+		reverseName     string
+		expectedAddress string
+	}{
+		{"54.119.58.176.in-addr.arpa.", "176.58.119.54"},
+		{"1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.", "2001:0db8:0000:0000:0000:0000:0000:0001"},
+		// A part of an IPv6 address.
+		{"8.b.d.0.1.0.0.2.ip6.arpa.", ""},
+		{"example.org.", ""},
+	}
+	for i, tc := range tests {
+		if got := ExtractAddressFromReverse(tc.reverseName); got != tc.expectedAddress {
+			t.Errorf("Test %d: expected %q, got %q", i, tc.expectedAddress, got)
+		}
+	}
+}
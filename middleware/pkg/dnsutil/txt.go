@@ -0,0 +1,23 @@
+package dnsutil
+
+// SplitTXT splits s into character-strings of at most 255 bytes, suitable for
+// use as the Txt field of a TXT record. Long values (SPF records, DKIM keys)
+// are cut on 255 byte boundaries, no empty trailing character-string is ever
+// returned. The empty string yields a single empty character-string.
+func SplitTXT(s string) []string {
+	if len(s) <= maxTXT {
+		return []string{s}
+	}
+	sx := make([]string, 0, len(s)/maxTXT+1)
+	for len(s) > maxTXT {
+		sx = append(sx, s[:maxTXT])
+		s = s[maxTXT:]
+	}
+	if s != "" {
+		sx = append(sx, s)
+	}
+	return sx
+}
+
+// maxTXT is the maximum length of a single character-string in a TXT record.
+const maxTXT = 255
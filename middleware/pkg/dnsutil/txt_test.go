@@ -0,0 +1,38 @@
+package dnsutil
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitTXT(t *testing.T) {
+	a255 := strings.Repeat("a", 255)
+
+	tests := []struct {
+		in       string
+		expected []string
+	}{
+		{"", []string{""}},
+		{"abc", []string{"abc"}},
+		{a255, []string{a255}},
+		{a255 + "b", []string{a255, "b"}},
+		{a255 + a255, []string{a255, a255}},
+		{a255 + "b" + a255, []string{a255, "b" + a255[:254], "a"}},
+	}
+
+	for i, tc := range tests {
+		xs := SplitTXT(tc.in)
+		if len(xs) != len(tc.expected) {
+			t.Errorf("Test %d: expected %d character-strings, got %d", i, len(tc.expected), len(xs))
+			continue
+		}
+		for j := range xs {
+			if xs[j] != tc.expected[j] {
+				t.Errorf("Test %d: expected character-string %d to be %q, got %q", i, j, tc.expected[j], xs[j])
+			}
+		}
+		if strings.Join(xs, "") != tc.in {
+			t.Errorf("Test %d: joined character-strings do not match input", i)
+		}
+	}
+}
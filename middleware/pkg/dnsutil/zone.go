@@ -0,0 +1,118 @@
+package dnsutil
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/idna"
+)
+
+// Zone normalizes s to a zone name. Internationalized names are converted to their
+// ASCII (punycode) form, the result is lowercased and fully qualified. An error is
+// returned when s is not a valid domain name.
+//
+// bücher.EXAMPLE becomes xn--bcher-kva.example.
+func Zone(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", errors.New("empty zone name")
+	}
+	s = strings.ToLower(s)
+	a, err := idna.ToASCII(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid internationalized zone name %s: %s", s, err)
+	}
+	a = dns.Fqdn(a)
+	if _, ok := dns.IsDomainName(a); !ok {
+		return "", fmt.Errorf("not a valid domain name: %s", s)
+	}
+	return a, nil
+}
+
+// ReverseZones returns the reverse zones (in in-addr.arpa. or ip6.arpa.) that
+// cover the network cidr. Reverse zones can only be cut on an octet (IPv4) or a
+// nibble (IPv6) boundary, for other prefix lengths all the zones of the next
+// boundary are returned.
+//
+// 10.0.0.0/8 becomes 10.in-addr.arpa. and 10.0.0.0/15 becomes 0.10.in-addr.arpa. and
+// 1.10.in-addr.arpa.
+//
+// IPv4 networks smaller than a /24 get a single RFC 2317 style zone, named after the
+// first address and the prefix length: 192.168.1.64/26 becomes 64-26.1.168.192.in-addr.arpa.
+// Queries only end up in such a zone when the parent zone delegates to it with CNAMEs.
+func ReverseZones(cidr string) ([]string, error) {
+	_, n, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+	ones, bits := n.Mask.Size()
+	if ip4 := n.IP.To4(); ip4 != nil && bits == 32 {
+		if ones > 24 && ones < 32 {
+			classless := fmt.Sprintf("%d-%d.%d.%d.%d%s", ip4[3], ones, ip4[2], ip4[1], ip4[0], v4arpaSuffix)
+			return []string{classless}, nil
+		}
+		return reverseZones(ip4, ones, 8, v4arpaSuffix), nil
+	}
+	return reverseZones(n.IP.To16(), ones, 4, v6arpaSuffix), nil
+}
+
+// SplitCIDR splits s into a CIDR and an (optional) port, 10.0.0.0/8:1053 returns
+// 10.0.0.0/8 and 1053. If s does not contain a CIDR, ok is false.
+func SplitCIDR(s string) (cidr, port string, ok bool) {
+	i := strings.Index(s, "/")
+	if i < 0 {
+		return "", "", false
+	}
+	cidr = s
+	if j := strings.Index(s[i:], ":"); j >= 0 {
+		cidr, port = s[:i+j], s[i+j+1:]
+	}
+	return cidr, port, true
+}
+
+// reverseZones returns the zones for the first ones bits of ip, where each label in the
+// reverse tree holds step bits of the address.
+func reverseZones(ip net.IP, ones, step int, suffix string) []string {
+	labels := (ones + step - 1) / step
+	if labels == 0 {
+		return []string{suffix[1:]}
+	}
+
+	vals := make([]int, labels)
+	for i := range vals {
+		if step == 8 {
+			vals[i] = int(ip[i])
+			continue
+		}
+		b := ip[i/2]
+		if i%2 == 0 {
+			vals[i] = int(b >> 4)
+		} else {
+			vals[i] = int(b & 0xf)
+		}
+	}
+
+	// The bits in the last label that are not covered by the prefix are free, every
+	// value of those gets its own zone.
+	free := uint(labels*step - ones)
+	zones := make([]string, 0, 1<<free)
+	for i := 0; i < 1<<free; i++ {
+		parts := make([]string, labels)
+		for j, v := range vals {
+			if j == labels-1 {
+				v |= i
+			}
+			if step == 8 {
+				parts[labels-1-j] = strconv.Itoa(v)
+			} else {
+				parts[labels-1-j] = strconv.FormatInt(int64(v), 16)
+			}
+		}
+		zones = append(zones, strings.Join(parts, ".")+suffix)
+	}
+	return zones
+}
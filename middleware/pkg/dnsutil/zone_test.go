@@ -0,0 +1,104 @@
+package dnsutil
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestZone(t *testing.T) {
+	tests := []struct {
+		in        string
+		expected  string
+		shouldErr bool
+	}{
+		{"example.org", "example.org.", false},
+		{"Example.ORG.", "example.org.", false},
+		{" example.org ", "example.org.", false},
+		{".", ".", false},
+		{"bücher.example", "xn--bcher-kva.example.", false},
+		{"BÜCHER.example.", "xn--bcher-kva.example.", false},
+		{"xn--bcher-kva.example", "xn--bcher-kva.example.", false},
+		{"", "", true},
+		{"a..example.org", "", true},
+	}
+
+	for i, tc := range tests {
+		z, err := Zone(tc.in)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error for %q, got %s", i, tc.in, z)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error for %q, got %s", i, tc.in, err)
+			continue
+		}
+		if z != tc.expected {
+			t.Errorf("Test %d: expected %s, got %s", i, tc.expected, z)
+		}
+	}
+}
+
+func TestReverseZones(t *testing.T) {
+	tests := []struct {
+		cidr      string
+		expected  []string
+		shouldErr bool
+	}{
+		{"10.0.0.0/8", []string{"10.in-addr.arpa."}, false},
+		{"192.168.1.0/24", []string{"1.168.192.in-addr.arpa."}, false},
+		{"10.0.0.0/15", []string{"0.10.in-addr.arpa.", "1.10.in-addr.arpa."}, false},
+		{"172.16.0.0/12", []string{
+			"16.172.in-addr.arpa.", "17.172.in-addr.arpa.", "18.172.in-addr.arpa.", "19.172.in-addr.arpa.",
+			"20.172.in-addr.arpa.", "21.172.in-addr.arpa.", "22.172.in-addr.arpa.", "23.172.in-addr.arpa.",
+			"24.172.in-addr.arpa.", "25.172.in-addr.arpa.", "26.172.in-addr.arpa.", "27.172.in-addr.arpa.",
+			"28.172.in-addr.arpa.", "29.172.in-addr.arpa.", "30.172.in-addr.arpa.", "31.172.in-addr.arpa.",
+		}, false},
+		{"0.0.0.0/0", []string{"in-addr.arpa."}, false},
+		{"192.168.1.64/26", []string{"64-26.1.168.192.in-addr.arpa."}, false},
+		{"192.168.1.1/32", []string{"1.1.168.192.in-addr.arpa."}, false},
+		{"2001:db8::/32", []string{"8.b.d.0.1.0.0.2.ip6.arpa."}, false},
+		{"2001:db8::/30", []string{"8.b.d.0.1.0.0.2.ip6.arpa.", "9.b.d.0.1.0.0.2.ip6.arpa.", "a.b.d.0.1.0.0.2.ip6.arpa.", "b.b.d.0.1.0.0.2.ip6.arpa."}, false},
+		{"10.0.0.0", nil, true},
+		{"example.org", nil, true},
+	}
+
+	for i, tc := range tests {
+		zones, err := ReverseZones(tc.cidr)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error for %s, got %v", i, tc.cidr, zones)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error for %s, got %s", i, tc.cidr, err)
+			continue
+		}
+		if !reflect.DeepEqual(zones, tc.expected) {
+			t.Errorf("Test %d: expected %v, got %v", i, tc.expected, zones)
+		}
+	}
+}
+
+func TestSplitCIDR(t *testing.T) {
+	tests := []struct {
+		in           string
+		expectedCIDR string
+		expectedPort string
+		expectedOk   bool
+	}{
+		{"10.0.0.0/8", "10.0.0.0/8", "", true},
+		{"10.0.0.0/8:1053", "10.0.0.0/8", "1053", true},
+		{"2001:db8::/32:1053", "2001:db8::/32", "1053", true},
+		{"example.org:1053", "", "", false},
+	}
+
+	for i, tc := range tests {
+		cidr, port, ok := SplitCIDR(tc.in)
+		if cidr != tc.expectedCIDR || port != tc.expectedPort || ok != tc.expectedOk {
+			t.Errorf("Test %d: expected %s %s %t, got %s %s %t", i, tc.expectedCIDR, tc.expectedPort, tc.expectedOk, cidr, port, ok)
+		}
+	}
+}
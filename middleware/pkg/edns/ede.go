@@ -0,0 +1,49 @@
+package edns
+
+import (
+	"encoding/binary"
+
+	"github.com/miekg/dns"
+)
+
+// ExtendedError is an error that is reported to the client as an Extended DNS Error
+// (RFC 8914) in the OPT RR of the response. Middleware return it with the rcode of the
+// response, SERVFAIL mostly, the server adds it when it writes that response.
+type ExtendedError struct {
+	InfoCode  uint16
+	ExtraText string
+}
+
+// Error implements the error interface.
+func (e *ExtendedError) Error() string { return e.ExtraText }
+
+// Extended DNS Error info codes, RFC 8914 section 4.
+const (
+	ExtendedErrorOther uint16 = 0
+)
+
+// optionCodeEDE is the EDNS0 option code of the Extended DNS Error.
+const optionCodeEDE = 15
+
+// SetExtendedError adds err, if it is an *ExtendedError, to the OPT RR of m. Nothing is
+// added if m has no OPT RR: the client doesn't do EDNS0.
+func SetExtendedError(m *dns.Msg, err error) {
+	e, ok := err.(*ExtendedError)
+	if !ok {
+		return
+	}
+	data := make([]byte, 2+len(e.ExtraText))
+	binary.BigEndian.PutUint16(data, e.InfoCode)
+	copy(data[2:], e.ExtraText)
+
+	for i, rr := range m.Extra {
+		if _, ok := rr.(*dns.OPT); !ok {
+			continue
+		}
+		// The OPT RR may be the one of the request, see request.SizeAndDo, don't change that.
+		opt := dns.Copy(rr).(*dns.OPT)
+		opt.Option = append(opt.Option, &dns.EDNS0_LOCAL{Code: optionCodeEDE, Data: data})
+		m.Extra[i] = opt
+		return
+	}
+}
@@ -0,0 +1,39 @@
+package edns
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestSetExtendedError(t *testing.T) {
+	req := ednsMsg()
+	m := new(dns.Msg)
+	m.SetRcode(req, dns.RcodeServerFailure)
+	m.Extra = req.Extra // as request.SizeAndDo does
+
+	SetExtendedError(m, errors.New("not extended"))
+	if n := len(m.IsEdns0().Option); n != 0 {
+		t.Fatalf("Expected no options, got %d", n)
+	}
+
+	SetExtendedError(m, &ExtendedError{InfoCode: ExtendedErrorOther, ExtraText: "CNAME loop"})
+	opt := m.IsEdns0()
+	if len(opt.Option) != 1 {
+		t.Fatalf("Expected 1 option, got %d", len(opt.Option))
+	}
+	e := opt.Option[0].(*dns.EDNS0_LOCAL)
+	if e.Code != 15 || e.Data[0] != 0 || e.Data[1] != 0 || string(e.Data[2:]) != "CNAME loop" {
+		t.Errorf("Expected extended error %q, got %v", "CNAME loop", e)
+	}
+	if n := len(req.IsEdns0().Option); n != 0 {
+		t.Errorf("Expected the OPT RR of the request to be unchanged, got %d options", n)
+	}
+
+	m.Extra = nil
+	SetExtendedError(m, &ExtendedError{ExtraText: "CNAME loop"})
+	if m.IsEdns0() != nil {
+		t.Error("Expected no OPT RR")
+	}
+}
@@ -0,0 +1,66 @@
+package edns
+
+import (
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+// Subnet returns the EDNS0 CLIENT SUBNET option (RFC 7871) in m, or nil if m has none.
+func Subnet(m *dns.Msg) *dns.EDNS0_SUBNET {
+	o := m.IsEdns0()
+	if o == nil {
+		return nil
+	}
+	for _, e := range o.Option {
+		if s, ok := e.(*dns.EDNS0_SUBNET); ok {
+			return s
+		}
+	}
+	return nil
+}
+
+// SubnetNet returns the network in the client subnet option s, using its source netmask.
+func SubnetNet(s *dns.EDNS0_SUBNET) *net.IPNet {
+	bits := 32
+	if s.Family == 2 {
+		bits = 128
+	}
+	mask := net.CIDRMask(int(s.SourceNetmask), bits)
+	return &net.IPNet{IP: s.Address.Mask(mask), Mask: mask}
+}
+
+// SetSubnet sets the client subnet option in m to n, replacing any existing option. If m
+// has no OPT record one is added.
+func SetSubnet(m *dns.Msg, n *net.IPNet) {
+	s := &dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: 1, Address: n.IP.To4()}
+	if s.Address == nil {
+		s.Family = 2
+		s.Address = n.IP.To16()
+	}
+	ones, _ := n.Mask.Size()
+	s.SourceNetmask = uint8(ones)
+
+	o := m.IsEdns0()
+	if o == nil {
+		m.SetEdns0(dns.MinMsgSize, false)
+		o = m.IsEdns0()
+	}
+	StripSubnet(m)
+	o.Option = append(o.Option, s)
+}
+
+// StripSubnet removes the client subnet option from m.
+func StripSubnet(m *dns.Msg) {
+	o := m.IsEdns0()
+	if o == nil {
+		return
+	}
+	opts := o.Option[:0]
+	for _, e := range o.Option {
+		if _, ok := e.(*dns.EDNS0_SUBNET); !ok {
+			opts = append(opts, e)
+		}
+	}
+	o.Option = opts
+}
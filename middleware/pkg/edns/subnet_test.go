@@ -0,0 +1,44 @@
+package edns
+
+import (
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestSubnet(t *testing.T) {
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	if s := Subnet(m); s != nil {
+		t.Fatalf("Expected no subnet, got %s", s)
+	}
+
+	_, n, _ := net.ParseCIDR("192.0.2.0/24")
+	SetSubnet(m, n)
+	s := Subnet(m)
+	if s == nil {
+		t.Fatal("Expected subnet, got none")
+	}
+	if s.Family != 1 || s.SourceNetmask != 24 {
+		t.Errorf("Expected family 1 and netmask 24, got %d and %d", s.Family, s.SourceNetmask)
+	}
+	if got := SubnetNet(s).String(); got != "192.0.2.0/24" {
+		t.Errorf("Expected 192.0.2.0/24, got %s", got)
+	}
+
+	// Setting it again replaces the option.
+	_, n, _ = net.ParseCIDR("2001:db8::/56")
+	SetSubnet(m, n)
+	if l := len(m.IsEdns0().Option); l != 1 {
+		t.Fatalf("Expected 1 option, got %d", l)
+	}
+	if got := SubnetNet(Subnet(m)).String(); got != "2001:db8::/56" {
+		t.Errorf("Expected 2001:db8::/56, got %s", got)
+	}
+
+	StripSubnet(m)
+	if s := Subnet(m); s != nil {
+		t.Errorf("Expected no subnet after strip, got %s", s)
+	}
+}
@@ -0,0 +1,177 @@
+// Package lease lets one of the CoreDNS instances that serve the same zones do the work
+// that must be done once, like sending the notifies for a zone. The instances compete for a
+// key in etcd that is set with a TTL: the instance that set it holds the lease for as long
+// as it keeps refreshing the key. When it stops, or can't reach etcd anymore, the key
+// expires and another instance takes over.
+package lease
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/miekg/coredns/middleware"
+
+	etcdc "github.com/coreos/etcd/client"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/context"
+)
+
+// Lease is a lease on a key in etcd.
+type Lease struct {
+	client etcdc.KeysAPI
+	key    string
+	id     string // the value of the key when we hold the lease
+	ttl    time.Duration
+
+	sync.Mutex
+	until   time.Time // when the lease expires, if it isn't refreshed
+	stop    chan struct{}
+	stopped bool
+}
+
+// New returns a lease on key, that expires ttl after it was last refreshed. The TTL is
+// rounded to seconds by etcd, it should be at least a few seconds.
+func New(client etcdc.KeysAPI, key string, ttl time.Duration) *Lease {
+	host, _ := os.Hostname()
+	id := fmt.Sprintf("%s-%d-%d", host, os.Getpid(), rand.Int63())
+	return &Lease{client: client, key: key, id: id, ttl: ttl}
+}
+
+// Client returns a client for the etcd cluster at endpoints.
+func Client(endpoints []string) (etcdc.KeysAPI, error) {
+	cli, err := etcdc.New(etcdc.Config{Endpoints: endpoints, Transport: etcdc.DefaultTransport})
+	if err != nil {
+		return nil, err
+	}
+	return etcdc.NewKeysAPI(cli), nil
+}
+
+// Start tries to get the lease, and returns when it knows whether it has it. It then keeps
+// refreshing it, or trying to get it when another instance has it, every third of the TTL
+// until Stop is called. A lease can't be started again after it is stopped.
+func (l *Lease) Start() {
+	l.renew()
+
+	l.Lock()
+	if l.stop != nil || l.stopped {
+		l.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	l.stop = stop
+	l.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(l.ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				l.renew()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops refreshing the lease and gives it up, so another instance can take over
+// right away.
+func (l *Lease) Stop() {
+	l.Lock()
+	if l.stop != nil {
+		close(l.stop)
+		l.stop = nil
+	}
+	l.stopped = true
+	held := time.Now().Before(l.until)
+	l.until = time.Time{}
+	l.Unlock()
+	leaseHeld.WithLabelValues(l.key).Set(0)
+
+	if !held {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), l.ttl/3)
+	defer cancel()
+	if _, err := l.client.Delete(ctx, l.key, &etcdc.DeleteOptions{PrevValue: l.id}); err != nil {
+		log.Printf("[WARNING] Failed to give up lease %s: %s", l.key, err)
+		return
+	}
+	log.Printf("[INFO] Gave up lease %s", l.key)
+}
+
+// Held returns true if this instance holds the lease.
+func (l *Lease) Held() bool {
+	l.Lock()
+	defer l.Unlock()
+	return time.Now().Before(l.until)
+}
+
+// renew refreshes the lease when we hold it and tries to get it when we don't. The lease
+// is only held until the TTL of the last successful refresh runs out: when etcd can't be
+// reached the other instances take over once the key expires, and so must we stop.
+func (l *Lease) renew() {
+	start := time.Now()
+	held := l.Held()
+
+	ctx, cancel := context.WithTimeout(context.Background(), l.ttl/3)
+	defer cancel()
+	var err error
+	if held {
+		_, err = l.client.Set(ctx, l.key, l.id, &etcdc.SetOptions{PrevValue: l.id, TTL: l.ttl})
+	}
+	if !held || isCode(err, etcdc.ErrorCodeKeyNotFound) {
+		_, err = l.client.Set(ctx, l.key, l.id, &etcdc.SetOptions{PrevExist: etcdc.PrevNoExist, TTL: l.ttl})
+	}
+
+	l.Lock()
+	if l.stopped {
+		// Stop was called while we were talking to etcd. If we just got the key, it expires.
+		l.Unlock()
+		return
+	}
+	switch {
+	case err == nil:
+		l.until = start.Add(l.ttl)
+	case isCode(err, etcdc.ErrorCodeNodeExist) || isCode(err, etcdc.ErrorCodeTestFailed):
+		// Another instance has it.
+		l.until = time.Time{}
+	default:
+		log.Printf("[WARNING] Failed to refresh lease %s: %s", l.key, err)
+	}
+	now := time.Now().Before(l.until)
+	l.Unlock()
+
+	switch {
+	case now && !held:
+		log.Printf("[INFO] Got lease %s", l.key)
+	case !now && held:
+		log.Printf("[INFO] Lost lease %s", l.key)
+	}
+	if now {
+		leaseHeld.WithLabelValues(l.key).Set(1)
+	} else {
+		leaseHeld.WithLabelValues(l.key).Set(0)
+	}
+}
+
+func isCode(err error, code int) bool {
+	e, ok := err.(etcdc.Error)
+	return ok && e.Code == code
+}
+
+var leaseHeld = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: middleware.Namespace,
+	Subsystem: "lease",
+	Name:      "held",
+	Help:      "Gauge that is 1 while this instance holds the lease on a key.",
+}, []string{"key"})
+
+func init() {
+	prometheus.MustRegister(leaseHeld)
+}
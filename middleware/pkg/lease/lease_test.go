@@ -0,0 +1,113 @@
+package lease
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	etcdc "github.com/coreos/etcd/client"
+	"golang.org/x/net/context"
+)
+
+// fakeKeys implements the compare-and-swap part of etcdc.KeysAPI that a lease uses.
+type fakeKeys struct {
+	etcdc.KeysAPI
+
+	sync.Mutex
+	keys map[string]string
+	down bool // etcd can't be reached
+}
+
+func (f *fakeKeys) Set(ctx context.Context, key, value string, opts *etcdc.SetOptions) (*etcdc.Response, error) {
+	f.Lock()
+	defer f.Unlock()
+	if f.down {
+		return nil, context.DeadlineExceeded
+	}
+	v, ok := f.keys[key]
+	if opts.PrevExist == etcdc.PrevNoExist && ok {
+		return nil, etcdc.Error{Code: etcdc.ErrorCodeNodeExist}
+	}
+	if opts.PrevValue != "" {
+		if !ok {
+			return nil, etcdc.Error{Code: etcdc.ErrorCodeKeyNotFound}
+		}
+		if v != opts.PrevValue {
+			return nil, etcdc.Error{Code: etcdc.ErrorCodeTestFailed}
+		}
+	}
+	f.keys[key] = value
+	return &etcdc.Response{Action: "set"}, nil
+}
+
+func (f *fakeKeys) Delete(ctx context.Context, key string, opts *etcdc.DeleteOptions) (*etcdc.Response, error) {
+	f.Lock()
+	defer f.Unlock()
+	if f.down {
+		return nil, context.DeadlineExceeded
+	}
+	if v, ok := f.keys[key]; !ok || v != opts.PrevValue {
+		return nil, etcdc.Error{Code: etcdc.ErrorCodeTestFailed}
+	}
+	delete(f.keys, key)
+	return &etcdc.Response{Action: "delete"}, nil
+}
+
+func TestLease(t *testing.T) {
+	keys := &fakeKeys{keys: make(map[string]string)}
+	a := New(keys, "/coredns/lease/example.org.", time.Hour)
+	b := New(keys, "/coredns/lease/example.org.", time.Hour)
+
+	a.Start()
+	b.Start()
+	if !a.Held() || b.Held() {
+		t.Fatalf("Expected only the first lease to be held, got %t and %t", a.Held(), b.Held())
+	}
+
+	// Refreshing keeps the lease where it is.
+	a.renew()
+	b.renew()
+	if !a.Held() || b.Held() {
+		t.Errorf("Expected only the first lease to be held after a refresh, got %t and %t", a.Held(), b.Held())
+	}
+
+	// When etcd is down, the holder keeps the lease until its TTL runs out.
+	keys.Lock()
+	keys.down = true
+	keys.Unlock()
+	a.renew()
+	if !a.Held() {
+		t.Errorf("Expected the lease to be held while etcd is down")
+	}
+	keys.Lock()
+	keys.down = false
+	keys.Unlock()
+
+	a.Stop()
+	if a.Held() {
+		t.Errorf("Expected a stopped lease not to be held")
+	}
+	b.renew()
+	if !b.Held() {
+		t.Errorf("Expected the second lease to be held after the first was given up")
+	}
+	b.Stop()
+}
+
+func TestLeaseExpires(t *testing.T) {
+	keys := &fakeKeys{keys: make(map[string]string)}
+	l := New(keys, "/coredns/lease/example.org.", time.Hour)
+	l.renew()
+	if !l.Held() {
+		t.Fatalf("Expected the lease to be held")
+	}
+
+	// The key expired in etcd, and another instance took it.
+	keys.Lock()
+	keys.keys["/coredns/lease/example.org."] = "other"
+	keys.Unlock()
+	l.renew()
+	if l.Held() {
+		t.Errorf("Expected the lease to be lost")
+	}
+}
@@ -0,0 +1,72 @@
+// Package metadata carries labels about a query, like the Kubernetes namespace of the
+// client, from the middleware that know them to the ones that use them, like log.
+//
+// The labels are carried in the context. The server adds a set of labels to it when
+// middleware registered a Provider (see dnsserver.Config.AddMetadata); Set is a no-op
+// otherwise.
+package metadata
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/miekg/coredns/request"
+
+	"golang.org/x/net/context"
+)
+
+// Provider sets the labels it knows for the query in state, with Set. Labels are named
+// after the middleware, like "kubernetes/client-namespace".
+type Provider func(ctx context.Context, state request.Request)
+
+// labels is the set of labels of a query. It is safe for concurrent use.
+type labels struct {
+	sync.Mutex
+	m map[string]string
+}
+
+type key struct{}
+
+// NewContext returns a new context that carries an empty set of labels.
+func NewContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, key{}, &labels{m: make(map[string]string)})
+}
+
+// Set sets label to value in ctx. If ctx does not carry labels, nothing is done.
+func Set(ctx context.Context, label, value string) {
+	l, ok := ctx.Value(key{}).(*labels)
+	if !ok {
+		return
+	}
+	l.Lock()
+	l.m[label] = value
+	l.Unlock()
+}
+
+// Value returns the value of label in ctx, and whether it is set.
+func Value(ctx context.Context, label string) (string, bool) {
+	l, ok := ctx.Value(key{}).(*labels)
+	if !ok {
+		return "", false
+	}
+	l.Lock()
+	defer l.Unlock()
+	v, ok := l.m[label]
+	return v, ok
+}
+
+// Labels returns the names of the labels set in ctx, sorted.
+func Labels(ctx context.Context) []string {
+	l, ok := ctx.Value(key{}).(*labels)
+	if !ok {
+		return nil
+	}
+	l.Lock()
+	names := make([]string, 0, len(l.m))
+	for n := range l.m {
+		names = append(names, n)
+	}
+	l.Unlock()
+	sort.Strings(names)
+	return names
+}
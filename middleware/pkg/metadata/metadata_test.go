@@ -0,0 +1,44 @@
+package metadata
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestMetadata(t *testing.T) {
+	// Without labels in the context nothing is set.
+	ctx := context.TODO()
+	Set(ctx, "kubernetes/client-namespace", "default")
+	if _, ok := Value(ctx, "kubernetes/client-namespace"); ok {
+		t.Errorf("Expected no label in a context without labels")
+	}
+
+	ctx = NewContext(ctx)
+	Set(ctx, "kubernetes/client-pod-name", "web-0")
+	Set(ctx, "kubernetes/client-namespace", "default")
+	if v, ok := Value(ctx, "kubernetes/client-namespace"); !ok || v != "default" {
+		t.Errorf("Expected label default, got %q, %t", v, ok)
+	}
+	if _, ok := Value(ctx, "kubernetes/other"); ok {
+		t.Errorf("Expected label that isn't set to be missing")
+	}
+	// A context derived from ctx shares its labels.
+	child, cancel := context.WithCancel(ctx)
+	defer cancel()
+	Set(child, "log/extra", "yes")
+	if v, _ := Value(ctx, "log/extra"); v != "yes" {
+		t.Errorf("Expected label set in a derived context, got %q", v)
+	}
+
+	expected := []string{"kubernetes/client-namespace", "kubernetes/client-pod-name", "log/extra"}
+	labels := Labels(ctx)
+	if len(labels) != len(expected) {
+		t.Fatalf("Expected labels %v, got %v", expected, labels)
+	}
+	for i := range expected {
+		if labels[i] != expected[i] {
+			t.Errorf("Expected labels %v, got %v", expected, labels)
+		}
+	}
+}
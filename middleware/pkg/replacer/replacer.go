@@ -33,15 +33,16 @@ type replacer struct {
 // in place of empty string (can still be empty string).
 func New(r *dns.Msg, rr *dnsrecorder.Recorder, emptyValue string) Replacer {
 	req := request.Request{W: rr, Req: r}
+	now := time.Now()
 	rep := replacer{
 		replacements: map[string]string{
-			"{type}":  req.Type(),
-			"{name}":  req.Name(),
-			"{class}": req.Class(),
-			"{proto}": req.Proto(),
-			"{when}": func() string {
-				return time.Now().Format(timeFormat)
-			}(),
+			"{type}":   req.Type(),
+			"{name}":   req.Name(),
+			"{class}":  req.Class(),
+			"{proto}":  req.Proto(),
+			"{when}":   now.Format(timeFormat),
+			"{date}":   now.UTC().Format(dateFormat),
+			"{time}":   now.UTC().Format(clockFormat),
 			"{remote}": req.IP(),
 			"{port}":   req.Port(),
 		},
@@ -86,6 +87,8 @@ func (r replacer) Replace(s string) string {
 		}
 	}
 
+	s = r.replaceLabels(s)
+
 	// Regular replacements - these are easier because they're case-sensitive
 	for placeholder, replacement := range r.replacements {
 		if replacement == "" {
@@ -97,6 +100,30 @@ func (r replacer) Replace(s string) string {
 	return s
 }
 
+// replaceLabels replaces the placeholders of the labels of the query, like
+// {/kubernetes/client-namespace}, see package metadata. The labels that are not set are
+// replaced with the empty value.
+func (r replacer) replaceLabels(s string) string {
+	out := ""
+	for {
+		i := strings.Index(s, labelReplacer)
+		if i < 0 {
+			break
+		}
+		j := strings.Index(s[i:], "}")
+		if j < 0 {
+			break
+		}
+		replacement := r.replacements[s[i:i+j+1]]
+		if replacement == "" {
+			replacement = r.emptyValue
+		}
+		out += s[:i] + replacement
+		s = s[i+j+1:]
+	}
+	return out + s
+}
+
 // Set sets key to value in the replacements map.
 func (r replacer) Set(key, value string) {
 	r.replacements["{"+key+"}"] = value
@@ -111,5 +138,8 @@ func boolToString(b bool) string {
 
 const (
 	timeFormat     = "02/Jan/2006:15:04:05 -0700"
+	dateFormat     = "2006-01-02" // date and time are in UTC, as in the W3C extended log format
+	clockFormat    = "15:04:05"
 	headerReplacer = "{>"
+	labelReplacer  = "{/"
 )
@@ -1,5 +1,35 @@
 package replacer
 
+import (
+	"testing"
+
+	"github.com/miekg/coredns/middleware/pkg/dnsrecorder"
+	"github.com/miekg/coredns/middleware/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestReplaceLabels(t *testing.T) {
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	repl := New(m, dnsrecorder.New(&test.ResponseWriter{}), "-")
+	repl.Set("/kubernetes/client-namespace", "default")
+
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{"{name} {/kubernetes/client-namespace}", "example.org. default"},
+		{"{/kubernetes/client-pod-name} {/kubernetes/client-namespace}", "- default"},
+		{"{/kubernetes/client-namespace", "{/kubernetes/client-namespace"},
+	}
+	for i, tc := range tests {
+		if actual := repl.Replace(tc.format); actual != tc.expected {
+			t.Errorf("Test %d: expected %q, got %q", i, tc.expected, actual)
+		}
+	}
+}
+
 /*
 func TestNewReplacer(t *testing.T) {
 	w := httptest.NewRecorder()
@@ -0,0 +1,81 @@
+// Package tls contains helper functions to create *tls.Configs for servers and clients.
+package tls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+)
+
+// NewTLSConfig returns a TLS config that includes a certificate. Use for server TLS
+// config or when using a client certificate. If caPath is empty, system CAs will
+// be used; otherwise the CA(s) in caPath are used to verify the peer.
+func NewTLSConfig(certPath, keyPath, caPath string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not load TLS cert: %s", err)
+	}
+
+	roots, err := loadRoots(caPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{Certificates: []tls.Certificate{cert}, RootCAs: roots, ClientCAs: roots}, nil
+}
+
+// NewTLSClientConfig returns a TLS config for a client connection. If caPath is
+// empty, system CAs will be used.
+func NewTLSClientConfig(caPath string) (*tls.Config, error) {
+	roots, err := loadRoots(caPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{RootCAs: roots}, nil
+}
+
+// NewTLSConfigFromArgs returns a TLS config based upon the number of args passed:
+//
+//	no args: a client config using the system CAs,
+//	1 arg:   a client config using the CA(s) in args[0],
+//	2 args:  a config with the cert and key in args[0] and args[1], using the system CAs,
+//	3 args:  as for 2 args, but with the CA(s) in args[2].
+func NewTLSConfigFromArgs(args ...string) (*tls.Config, error) {
+	switch len(args) {
+	case 0:
+		return NewTLSClientConfig("")
+	case 1:
+		return NewTLSClientConfig(args[0])
+	case 2:
+		return NewTLSConfig(args[0], args[1], "")
+	case 3:
+		return NewTLSConfig(args[0], args[1], args[2])
+	}
+	return nil, fmt.Errorf("maximum of three arguments allowed for TLS config, found %d", len(args))
+}
+
+func loadRoots(caPath string) (*x509.CertPool, error) {
+	if caPath == "" {
+		return nil, nil
+	}
+
+	roots := x509.NewCertPool()
+	if err := appendRoots(roots, caPath); err != nil {
+		return nil, err
+	}
+	return roots, nil
+}
+
+// appendRoots adds the certificates in the PEM file caPath to roots.
+func appendRoots(roots *x509.CertPool, caPath string) error {
+	pem, err := ioutil.ReadFile(caPath)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %s", caPath, err)
+	}
+	if ok := roots.AppendCertsFromPEM(pem); !ok {
+		return fmt.Errorf("could not read root certs: %s", caPath)
+	}
+	return nil
+}
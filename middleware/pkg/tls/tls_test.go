@@ -0,0 +1,47 @@
+package tls
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewTLSConfigFromArgs(t *testing.T) {
+	c, err := NewTLSConfigFromArgs()
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if c.RootCAs != nil {
+		t.Errorf("Expected system CAs to be used")
+	}
+
+	if _, err := NewTLSConfigFromArgs("a", "b", "c", "d"); err == nil {
+		t.Errorf("Expected error for four arguments, got none")
+	}
+	if _, err := NewTLSConfigFromArgs("/does/not/exist"); err == nil {
+		t.Errorf("Expected error for non existent CA file, got none")
+	}
+	if _, err := NewTLSConfigFromArgs("/does/not/exist.crt", "/does/not/exist.key"); err == nil {
+		t.Errorf("Expected error for non existent cert and key, got none")
+	}
+}
+
+func TestLoadRoots(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coredns-tls")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	bad := filepath.Join(dir, "bad.pem")
+	if err := ioutil.WriteFile(bad, []byte("not a certificate"), 0644); err != nil {
+		t.Fatalf("Could not write file: %s", err)
+	}
+	if _, err := loadRoots(bad); err == nil {
+		t.Errorf("Expected error for invalid PEM data, got none")
+	}
+	if r, err := loadRoots(""); err != nil || r != nil {
+		t.Errorf("Expected nil pool and no error for empty path, got %v, %v", r, err)
+	}
+}
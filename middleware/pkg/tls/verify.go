@@ -0,0 +1,112 @@
+package tls
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Verification sets how a client verifies the certificate of a TLS server, for servers
+// that don't have a publicly trusted certificate. The zero value verifies it against the
+// system CAs.
+type Verification struct {
+	CAs      []string // files with the CA certificates that are trusted, instead of the system ones
+	Pins     []string // SPKI pins, see ParsePin; the server must have a certificate that matches one
+	Insecure bool     // don't verify the certificate at all
+}
+
+// IsSet returns true if v verifies otherwise than against the system CAs.
+func (v Verification) IsSet() bool {
+	return len(v.CAs) > 0 || len(v.Pins) > 0 || v.Insecure
+}
+
+// Apply sets the verification of config according to v. With CAs, the certificate chain
+// is verified against them. With Pins alone, the chain isn't verified, so self-signed
+// certificates can be pinned; with both, the chain must verify and match a pin.
+func (v Verification) Apply(config *tls.Config) error {
+	if v.Insecure && (len(v.CAs) > 0 || len(v.Pins) > 0) {
+		return errors.New("insecure verification can't be combined with CAs or pins")
+	}
+	if v.Insecure {
+		config.InsecureSkipVerify = true
+		return nil
+	}
+
+	if len(v.CAs) > 0 {
+		roots := x509.NewCertPool()
+		for _, path := range v.CAs {
+			if err := appendRoots(roots, path); err != nil {
+				return err
+			}
+		}
+		config.RootCAs = roots
+	}
+
+	if len(v.Pins) == 0 {
+		return nil
+	}
+	pins := make([][]byte, len(v.Pins))
+	for i, p := range v.Pins {
+		pin, err := ParsePin(p)
+		if err != nil {
+			return err
+		}
+		pins[i] = pin
+	}
+	if err := setPins(config, pins); err != nil {
+		return err
+	}
+	// Without CAs the chain is not verified, the pins take the place of the CAs.
+	config.InsecureSkipVerify = len(v.CAs) == 0
+	return nil
+}
+
+// ParsePin parses an SPKI pin: the base64 encoded SHA-256 hash of the SubjectPublicKeyInfo
+// of a certificate, as in RFC 7469 and RFC 7858, optionally prefixed with "sha256/". The
+// pin of a certificate in cert.pem is printed by:
+//
+//	openssl x509 -in cert.pem -pubkey -noout | openssl pkey -pubin -outform der |
+//		openssl dgst -sha256 -binary | openssl enc -base64
+func ParsePin(s string) ([]byte, error) {
+	pin, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(s, "sha256/"))
+	if err != nil || len(pin) != sha256.Size {
+		return nil, fmt.Errorf("not a base64 encoded SHA-256 hash: %s", s)
+	}
+	return pin, nil
+}
+
+// verifyPins returns an error if the certificate of the server does not have a public key
+// that matches one of pins. Without verified chains, that is the leaf certificate, the
+// first in rawCerts: the others are not checked, anyone can send them along. With
+// verified chains, it is any certificate in them, the leaf or a CA.
+func verifyPins(rawCerts [][]byte, chains [][]*x509.Certificate, pins [][]byte) error {
+	var certs []*x509.Certificate
+	if len(chains) > 0 {
+		for _, chain := range chains {
+			certs = append(certs, chain...)
+		}
+	} else {
+		if len(rawCerts) == 0 {
+			return errors.New("server sent no certificate")
+		}
+		cert, err := x509.ParseCertificate(rawCerts[0])
+		if err != nil {
+			return err
+		}
+		certs = []*x509.Certificate{cert}
+	}
+	for _, cert := range certs {
+		sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+		for _, pin := range pins {
+			if bytes.Equal(sum[:], pin) {
+				return nil
+			}
+		}
+	}
+	return errors.New("the certificate of the server matches no pin")
+}
@@ -0,0 +1,15 @@
+//go:build !go1.8
+// +build !go1.8
+
+package tls
+
+import (
+	"crypto/tls"
+	"errors"
+)
+
+// setPins returns an error, tls.Config can't check the certificate of the server before
+// Go 1.8.
+func setPins(config *tls.Config, pins [][]byte) error {
+	return errors.New("pinning needs CoreDNS to be built with Go 1.8 or later")
+}
@@ -0,0 +1,17 @@
+//go:build go1.8
+// +build go1.8
+
+package tls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+)
+
+// setPins makes config check the certificate of the server against pins.
+func setPins(config *tls.Config, pins [][]byte) error {
+	config.VerifyPeerCertificate = func(rawCerts [][]byte, chains [][]*x509.Certificate) error {
+		return verifyPins(rawCerts, chains, pins)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+//go:build go1.8
+// +build go1.8
+
+package tls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// selfSigned returns a self-signed certificate for dns.example.org.
+func selfSigned(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "dns.example.org"},
+		DNSNames:              []string{"dns.example.org"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// pin returns the SPKI pin of cert.
+func pin(t *testing.T, cert tls.Certificate) string {
+	c, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(c.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// handshake returns the error of a TLS handshake with a server that has cert, by a client
+// with config.
+func handshake(cert tls.Certificate, config *tls.Config) error {
+	c, s := net.Pipe()
+	defer c.Close()
+	defer s.Close()
+	go tls.Server(s, &tls.Config{Certificates: []tls.Certificate{cert}}).Handshake()
+	return tls.Client(c, config).Handshake()
+}
+
+func TestVerification(t *testing.T) {
+	cert, other := selfSigned(t), selfSigned(t)
+
+	dir, err := ioutil.TempDir("", "coredns-tls")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	ca := filepath.Join(dir, "ca.pem")
+	if err := ioutil.WriteFile(ca, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0644); err != nil {
+		t.Fatalf("Could not write file: %s", err)
+	}
+
+	tests := []struct {
+		v          Verification
+		serverName string
+		shouldErr  bool
+	}{
+		{Verification{}, "dns.example.org", true},
+		{Verification{Insecure: true}, "", false},
+		{Verification{Pins: []string{pin(t, cert)}}, "", false},
+		{Verification{Pins: []string{"sha256/" + pin(t, other), "sha256/" + pin(t, cert)}}, "", false},
+		{Verification{Pins: []string{pin(t, other)}}, "", true},
+		{Verification{CAs: []string{ca}}, "dns.example.org", false},
+		{Verification{CAs: []string{ca}}, "other.example.org", true},
+		{Verification{CAs: []string{ca}, Pins: []string{pin(t, cert)}}, "dns.example.org", false},
+		{Verification{CAs: []string{ca}, Pins: []string{pin(t, other)}}, "dns.example.org", true},
+	}
+	// A server can't get past a pin by sending the pinned certificate after its own.
+	chain := tls.Certificate{Certificate: [][]byte{other.Certificate[0], cert.Certificate[0]}, PrivateKey: other.PrivateKey}
+	config := &tls.Config{}
+	if err := (Verification{Pins: []string{pin(t, cert)}}).Apply(config); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if err := handshake(chain, config); err == nil {
+		t.Error("Expected handshake with the pinned certificate after another leaf to fail, it didn't")
+	}
+
+	for i, tc := range tests {
+		config := &tls.Config{ServerName: tc.serverName}
+		if err := tc.v.Apply(config); err != nil {
+			t.Fatalf("Test %d: expected no error, got %s", i, err)
+		}
+		err := handshake(cert, config)
+		if tc.shouldErr && err == nil {
+			t.Errorf("Test %d: expected handshake to fail, it didn't", i)
+		}
+		if !tc.shouldErr && err != nil {
+			t.Errorf("Test %d: expected handshake to succeed, got %s", i, err)
+		}
+	}
+
+	for i, v := range []Verification{
+		{Insecure: true, Pins: []string{pin(t, cert)}},
+		{Pins: []string{"not base64"}},
+		{Pins: []string{base64.StdEncoding.EncodeToString([]byte("too short"))}},
+		{CAs: []string{"/does/not/exist"}},
+	} {
+		if err := v.Apply(&tls.Config{}); err == nil {
+			t.Errorf("Test %d: expected error, got none", i)
+		}
+	}
+}
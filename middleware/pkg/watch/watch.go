@@ -0,0 +1,235 @@
+// Package watch watches the files middleware read their data from, like zone files,
+// rewrite maps and certificates, and calls a reload function when one changes on disk.
+// All files are watched by a single watcher, so the middleware don't each bring their own.
+package watch
+
+import (
+	"log"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/miekg/coredns/middleware"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// File calls reload when name is written or (re)created. Changes that follow each other
+// within the debounce interval are reported once, when they have settled, so a file that
+// is written in several steps is read once. reload is never called for the same watch
+// concurrently; it should log its errors itself, they are only counted here. The directory
+// of name must exist. The returned function stops the watch.
+func File(name string, reload func() error) (stop func(), err error) {
+	return files.add(filepath.Clean(name), reload)
+}
+
+// Debounce is how long the changes of a file have to settle before it is reloaded.
+var Debounce = 100 * time.Millisecond
+
+// watcher watches the directories of the files rather than the files themselves, so it
+// keeps working when a file is replaced instead of written.
+type watcher struct {
+	sync.Mutex
+	w       *fsnotify.Watcher
+	dirs    map[string]int // number of watches per directory
+	watches map[string][]*watch
+}
+
+// watch is a single call of File.
+type watch struct {
+	file   string
+	reload func() error
+
+	sync.Mutex
+	timer *time.Timer
+	gen   int // incremented for every change, so a timer that fired late can tell it is stale
+
+	reloading sync.Mutex
+}
+
+var files = &watcher{dirs: make(map[string]int), watches: make(map[string][]*watch)}
+
+func (wr *watcher) add(name string, reload func() error) (func(), error) {
+	wr.Lock()
+	defer wr.Unlock()
+
+	if wr.w == nil {
+		w, err := fsnotify.NewWatcher()
+		if err != nil {
+			return nil, err
+		}
+		wr.w = w
+		go wr.run(w)
+	}
+	dir := filepath.Dir(name)
+	if wr.dirs[dir] == 0 {
+		if err := wr.w.Add(dir); err != nil {
+			if w := wr.idle(); w != nil {
+				// Close can wait for the event loop, that takes the lock.
+				go w.Close()
+			}
+			return nil, err
+		}
+	}
+	wr.dirs[dir]++
+
+	wa := &watch{file: name, reload: reload}
+	wr.watches[name] = append(wr.watches[name], wa)
+
+	var once sync.Once
+	return func() { once.Do(func() { wr.remove(wa) }) }, nil
+}
+
+func (wr *watcher) remove(wa *watch) {
+	wa.stop()
+
+	wr.Lock()
+	watches := wr.watches[wa.file]
+	for i := range watches {
+		if watches[i] == wa {
+			watches = append(watches[:i], watches[i+1:]...)
+			break
+		}
+	}
+	if len(watches) == 0 {
+		delete(wr.watches, wa.file)
+	} else {
+		wr.watches[wa.file] = watches
+	}
+
+	dir := filepath.Dir(wa.file)
+	if wr.dirs[dir]--; wr.dirs[dir] == 0 {
+		delete(wr.dirs, dir)
+		wr.w.Remove(dir)
+	}
+	w := wr.idle()
+	wr.Unlock()
+
+	// Close can wait for the event loop, that takes the lock.
+	if w != nil {
+		w.Close()
+	}
+}
+
+// idle returns the fsnotify watcher, that should be closed, when nothing is watched
+// anymore. The lock must be held.
+func (wr *watcher) idle() *fsnotify.Watcher {
+	if len(wr.dirs) != 0 || wr.w == nil {
+		return nil
+	}
+	w := wr.w
+	wr.w = nil
+	return w
+}
+
+func (wr *watcher) run(w *fsnotify.Watcher) {
+	for {
+		select {
+		case event, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+				continue
+			}
+			name := filepath.Clean(event.Name)
+
+			wr.Lock()
+			watches := wr.watches[name]
+			wr.Unlock()
+			if len(watches) == 0 {
+				continue
+			}
+			eventCount.WithLabelValues(name).Inc()
+			for _, wa := range watches {
+				wa.changed()
+			}
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("[ERROR] Failed to watch files: %s", err)
+		}
+	}
+}
+
+// changed schedules a reload of wa after Debounce, postponing one that is already scheduled.
+func (wa *watch) changed() {
+	wa.Lock()
+	defer wa.Unlock()
+	if wa.timer != nil {
+		wa.timer.Stop()
+	}
+	wa.gen++
+	gen := wa.gen
+	wa.timer = time.AfterFunc(Debounce, func() { wa.run(gen) })
+}
+
+func (wa *watch) run(gen int) {
+	wa.Lock()
+	if gen != wa.gen {
+		// Postponed or stopped in the meantime.
+		wa.Unlock()
+		return
+	}
+	wa.timer = nil
+	wa.Unlock()
+
+	wa.reloading.Lock()
+	defer wa.reloading.Unlock()
+
+	reloadCount.WithLabelValues(wa.file).Inc()
+	if err := wa.reload(); err != nil {
+		reloadFailureCount.WithLabelValues(wa.file).Inc()
+		return
+	}
+	lastReload.WithLabelValues(wa.file).Set(float64(time.Now().Unix()))
+}
+
+func (wa *watch) stop() {
+	wa.Lock()
+	defer wa.Unlock()
+	if wa.timer != nil {
+		wa.timer.Stop()
+		wa.timer = nil
+	}
+	wa.gen++
+}
+
+var (
+	eventCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: middleware.Namespace,
+		Subsystem: "watch",
+		Name:      "events_total",
+		Help:      "Counter of changes seen to a watched file.",
+	}, []string{"file"})
+
+	reloadCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: middleware.Namespace,
+		Subsystem: "watch",
+		Name:      "reloads_total",
+		Help:      "Counter of reloads of a watched file.",
+	}, []string{"file"})
+
+	reloadFailureCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: middleware.Namespace,
+		Subsystem: "watch",
+		Name:      "reload_failures_total",
+		Help:      "Counter of failed reloads of a watched file.",
+	}, []string{"file"})
+
+	lastReload = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: middleware.Namespace,
+		Subsystem: "watch",
+		Name:      "last_reload_timestamp_seconds",
+		Help:      "The time of the last successful reload of a watched file.",
+	}, []string{"file"})
+)
+
+func init() {
+	prometheus.MustRegister(eventCount)
+	prometheus.MustRegister(reloadCount)
+	prometheus.MustRegister(reloadFailureCount)
+	prometheus.MustRegister(lastReload)
+}
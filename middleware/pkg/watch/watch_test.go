@@ -0,0 +1,68 @@
+package watch
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watch")
+	if err != nil {
+		t.Fatalf("Could not create a directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "db.example.org")
+	if err := ioutil.WriteFile(name, []byte("1"), 0644); err != nil {
+		t.Fatalf("Could not write the file: %s", err)
+	}
+
+	var reloads int32
+	stop, err := File(name, func() error {
+		atomic.AddInt32(&reloads, 1)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	// Writes in quick succession are one reload.
+	for i := 0; i < 5; i++ {
+		if err := ioutil.WriteFile(name, []byte("2"), 0644); err != nil {
+			t.Fatalf("Could not write the file: %s", err)
+		}
+	}
+	// Other files in the directory are not watched.
+	if err := ioutil.WriteFile(filepath.Join(dir, "other"), []byte("2"), 0644); err != nil {
+		t.Fatalf("Could not write the file: %s", err)
+	}
+	time.Sleep(5 * Debounce)
+	if x := atomic.LoadInt32(&reloads); x != 1 {
+		t.Errorf("Expected 1 reload, got %d", x)
+	}
+
+	stop()
+	if err := ioutil.WriteFile(name, []byte("3"), 0644); err != nil {
+		t.Fatalf("Could not write the file: %s", err)
+	}
+	time.Sleep(5 * Debounce)
+	if x := atomic.LoadInt32(&reloads); x != 1 {
+		t.Errorf("Expected no reload after stop, got %d reloads", x)
+	}
+}
+
+func TestFileNoDirectory(t *testing.T) {
+	if _, err := File("/does/not/exist/db.example.org", func() error { return nil }); err == nil {
+		t.Fatal("Expected an error for a file in a directory that doesn't exist")
+	}
+	// Nothing else is watched, so the fsnotify watcher must not be left open.
+	files.Lock()
+	w := files.w
+	files.Unlock()
+	if w != nil {
+		t.Error("Expected the watcher to be closed after a failed watch")
+	}
+}
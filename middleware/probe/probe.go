@@ -0,0 +1,100 @@
+// Package probe implements a middleware that answers a probe name with the identity of the
+// server, its time and the serials of its zones, for blackbox monitoring.
+package probe
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/miekg/coredns/middleware"
+	"github.com/miekg/coredns/request"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+// Probe answers TXT queries for Name, in any class, with the identity of the server, the
+// current time and, for each zone in Zones, the serial of its SOA record. The SOA records
+// are looked up in the rest of the chain, so a monitor sees how fresh the data of this
+// instance is. Other queries are handed to Next.
+type Probe struct {
+	Next  middleware.Handler
+	Name  string
+	ID    string
+	Zones []string
+
+	now func() time.Time // for the tests
+}
+
+// ServeDNS implements the middleware.Handler interface.
+func (p Probe) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: r}
+	if state.Name() != p.Name || state.QType() != dns.TypeTXT {
+		return p.Next.ServeDNS(ctx, w, r)
+	}
+
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Authoritative = true
+
+	hdr := dns.RR_Header{Name: state.QName(), Rrtype: dns.TypeTXT, Class: state.QClass(), Ttl: 0}
+	txt := func(s string) { m.Answer = append(m.Answer, &dns.TXT{Hdr: hdr, Txt: []string{s}}) }
+
+	now := time.Now
+	if p.now != nil {
+		now = p.now
+	}
+	txt("id=" + p.ID)
+	txt("time=" + strconv.FormatInt(now().Unix(), 10))
+	for _, zone := range p.Zones {
+		txt(p.serial(ctx, w, zone))
+	}
+
+	state.SizeAndDo(m)
+	m, _ = state.Scrub(m)
+	w.WriteMsg(m)
+	return dns.RcodeSuccess, nil
+}
+
+// serial returns the serial of zone, or the rcode of the lookup when there is no SOA record.
+func (p Probe) serial(ctx context.Context, w dns.ResponseWriter, zone string) string {
+	req := new(dns.Msg)
+	req.SetQuestion(zone, dns.TypeSOA)
+	rec := &recorder{ResponseWriter: w}
+	rcode, err := p.Next.ServeDNS(ctx, rec, req)
+	if rec.msg == nil {
+		// The error rcodes are not written by the middleware, but returned.
+		if err != nil || rcode == dns.RcodeSuccess {
+			rcode = dns.RcodeServerFailure
+		}
+		return fmt.Sprintf("zone=%s rcode=%s", zone, dns.RcodeToString[rcode])
+	}
+	for _, rr := range rec.msg.Answer {
+		if soa, ok := rr.(*dns.SOA); ok {
+			return fmt.Sprintf("zone=%s serial=%d", zone, soa.Serial)
+		}
+	}
+	return fmt.Sprintf("zone=%s rcode=%s", zone, dns.RcodeToString[rec.msg.Rcode])
+}
+
+// recorder is a dns.ResponseWriter that keeps the reply to the lookup of a zone's SOA
+// record, instead of writing it to the client.
+type recorder struct {
+	dns.ResponseWriter
+	msg *dns.Msg
+}
+
+func (r *recorder) WriteMsg(m *dns.Msg) error {
+	r.msg = m
+	return nil
+}
+
+func (r *recorder) Write(buf []byte) (int, error) {
+	m := new(dns.Msg)
+	if err := m.Unpack(buf); err != nil {
+		return 0, err
+	}
+	r.msg = m
+	return len(buf), nil
+}
@@ -0,0 +1,58 @@
+package probe
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/coredns/middleware/pkg/dnsrecorder"
+	"github.com/miekg/coredns/middleware/test"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+func TestProbe(t *testing.T) {
+	// The backend serves example.org, but not example.net.
+	backend := test.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		if r.Question[0].Name != "example.org." {
+			return dns.RcodeRefused, nil
+		}
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = append(m.Answer, test.SOA("example.org. 3600 IN SOA ns1.example.org. hostmaster.example.org. 2017042745 7200 3600 1209600 3600"))
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+
+	p := Probe{
+		Next:  backend,
+		Name:  "_probe.example.org.",
+		ID:    "ns1-ams",
+		Zones: []string{"example.org.", "example.net."},
+		now:   func() time.Time { return time.Unix(1500000000, 0) },
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion("_probe.example.org.", dns.TypeTXT)
+	rec := dnsrecorder.New(&test.ResponseWriter{})
+	if _, err := p.ServeDNS(context.TODO(), rec, m); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	expected := []string{"id=ns1-ams", "time=1500000000", "zone=example.org. serial=2017042745", "zone=example.net. rcode=REFUSED"}
+	if len(rec.Msg.Answer) != len(expected) {
+		t.Fatalf("Expected %d answers, got %d", len(expected), len(rec.Msg.Answer))
+	}
+	for i, rr := range rec.Msg.Answer {
+		if txt := rr.(*dns.TXT).Txt[0]; txt != expected[i] {
+			t.Errorf("Answer %d: expected %q, got %q", i, expected[i], txt)
+		}
+	}
+
+	// Other queries go to the next middleware.
+	m.SetQuestion("_probe.example.org.", dns.TypeA)
+	rec = dnsrecorder.New(&test.ResponseWriter{})
+	if rcode, _ := p.ServeDNS(context.TODO(), rec, m); rcode != dns.RcodeRefused {
+		t.Errorf("Expected the rcode of the next middleware, got %s", dns.RcodeToString[rcode])
+	}
+}
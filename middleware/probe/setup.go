@@ -0,0 +1,104 @@
+package probe
+
+import (
+	"os"
+	"strings"
+
+	"github.com/miekg/coredns/core/dnsserver"
+	"github.com/miekg/coredns/middleware"
+
+	"github.com/mholt/caddy"
+)
+
+func init() {
+	caddy.RegisterPlugin("probe", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	p, err := probeParse(c)
+	if err != nil {
+		return middleware.Error("probe", err)
+	}
+
+	dnsserver.GetConfig(c).AddMiddleware(func(next middleware.Handler) middleware.Handler {
+		p.Next = next
+		return p
+	})
+
+	return nil
+}
+
+// probeParse parses:
+//
+//	probe [NAME] {
+//	    id IDENTITY
+//	    zones ZONE...
+//	}
+func probeParse(c *caddy.Controller) (Probe, error) {
+	p := Probe{}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "localhost"
+	}
+	p.ID = hostname
+
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return p, c.Err("probe can only be given once")
+		}
+		i++
+
+		p.Zones = make([]string, len(c.ServerBlockKeys))
+		for j := range c.ServerBlockKeys {
+			p.Zones[j] = middleware.Host(c.ServerBlockKeys[j]).Normalize()
+		}
+		// The name must be in a zone of this server block, or the queries don't get here.
+		if len(p.Zones) > 0 {
+			p.Name = defaultLabel + "." + strings.TrimPrefix(p.Zones[0], ".")
+		}
+
+		args := c.RemainingArgs()
+		switch len(args) {
+		case 0:
+		case 1:
+			p.Name = middleware.Host(args[0]).Normalize()
+		default:
+			return p, c.ArgErr()
+		}
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "id":
+				if !c.NextArg() {
+					return p, c.ArgErr()
+				}
+				p.ID = c.Val()
+				if c.NextArg() {
+					return p, c.ArgErr()
+				}
+			case "zones":
+				zones := c.RemainingArgs()
+				if len(zones) == 0 {
+					return p, c.ArgErr()
+				}
+				for j := range zones {
+					zones[j] = middleware.Host(zones[j]).Normalize()
+				}
+				p.Zones = zones
+			default:
+				return p, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+	}
+	if p.Name == "" {
+		return p, c.Err("probe needs a name")
+	}
+	return p, nil
+}
+
+const defaultLabel = "_probe"
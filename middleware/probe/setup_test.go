@@ -0,0 +1,63 @@
+package probe
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mholt/caddy"
+)
+
+func TestSetupProbe(t *testing.T) {
+	tests := []struct {
+		input         string
+		shouldErr     bool
+		expectedName  string
+		expectedID    string
+		expectedZones string
+	}{
+		{`probe _probe.example.org`, false, "_probe.example.org.", "", ""},
+		{`probe _probe.example.org {
+			id ns1-ams
+			zones example.org example.net.
+		}`, false, "_probe.example.org.", "ns1-ams", "example.org. example.net."},
+		// fails
+		{`probe`, true, "", "", ""},
+		{`probe a.example.org b.example.org`, true, "", "", ""},
+		{`probe _probe.example.org {
+			id
+		}`, true, "", "", ""},
+		{`probe _probe.example.org {
+			zones
+		}`, true, "", "", ""},
+		{`probe _probe.example.org {
+			serial
+		}`, true, "", "", ""},
+		{`probe _probe.example.org
+		probe _probe.example.net`, true, "", "", ""},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		p, err := probeParse(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %d: Expected error but found nil", i)
+			continue
+		}
+		if !test.shouldErr && err != nil {
+			t.Errorf("Test %d: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr {
+			continue
+		}
+		if p.Name != test.expectedName {
+			t.Errorf("Test %d: Expected name %s, got %s", i, test.expectedName, p.Name)
+		}
+		if test.expectedID != "" && p.ID != test.expectedID {
+			t.Errorf("Test %d: Expected id %s, got %s", i, test.expectedID, p.ID)
+		}
+		if zones := strings.Join(p.Zones, " "); zones != test.expectedZones {
+			t.Errorf("Test %d: Expected zones %q, got %q", i, test.expectedZones, zones)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package proxy
+
+import (
+	"strconv"
+
+	"github.com/miekg/coredns/middleware/pkg/edns"
+
+	"github.com/miekg/dns"
+)
+
+// exchange sends query, the randomized copy of req, to the host of p and checks the reply.
+// Identical queries to the same host that are in flight at the same time are sent once,
+// unless the options disable that; every caller gets its own copy of the reply. The query
+// is only sent when the limiter of the options has a free slot, otherwise errOverloaded
+// is returned.
+func (p ReverseProxy) exchange(req, query *dns.Msg, proto string) (*dns.Msg, error) {
+	fn := func() (*dns.Msg, error) {
+		if !p.Options.limiter.acquire() {
+			return nil, errOverloaded
+		}
+		defer p.Options.limiter.release()
+
+		reply, err := exchange(p.Client, p.Options, query, p.Host, proto)
+		if reply == nil {
+			return nil, err
+		}
+		if err := checkReply(req, query, reply, p.Options.RandomizeCase); err != nil {
+			return nil, err
+		}
+		return reply, err
+	}
+	if p.Options.NoCoalesce || p.Options.inflight == nil {
+		return fn()
+	}
+
+	v, err := p.Options.inflight.Do(coalesceKey(req, p.Host, proto), func() (interface{}, error) {
+		return fn()
+	})
+	reply, _ := v.(*dns.Msg)
+	if reply != nil {
+		reply = reply.Copy()
+	}
+	return reply, err
+}
+
+// coalesceKey returns the key under which queries for the same answer are coalesced. Next
+// to the question it holds everything in req that changes the answer of the upstream: the
+// DO and CD bits and the client subnet. The name is not lowered, the reply has the name
+// the way the first client asked for it.
+func coalesceKey(req *dns.Msg, host, proto string) string {
+	if len(req.Question) == 0 {
+		return host + " " + proto
+	}
+	q := req.Question[0]
+	key := host + " " + proto + " " + q.Name + " " + strconv.Itoa(int(q.Qtype)) + " " + strconv.Itoa(int(q.Qclass))
+	if req.CheckingDisabled {
+		key += " cd"
+	}
+	if opt := req.IsEdns0(); opt != nil && opt.Do() {
+		key += " do"
+	}
+	if s := edns.Subnet(req); s != nil {
+		key += " " + s.String()
+	}
+	return key
+}
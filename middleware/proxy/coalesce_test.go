@@ -0,0 +1,108 @@
+package proxy
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/coredns/middleware/pkg/dnsrecorder"
+	"github.com/miekg/coredns/middleware/pkg/edns"
+	"github.com/miekg/coredns/middleware/pkg/singleflight"
+	"github.com/miekg/coredns/middleware/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestCoalesceKey(t *testing.T) {
+	msg := func(name string, do bool, subnet string) *dns.Msg {
+		m := new(dns.Msg)
+		m.SetQuestion(name, dns.TypeA)
+		if do {
+			m.SetEdns0(4096, true)
+		}
+		if subnet != "" {
+			_, n, _ := net.ParseCIDR(subnet)
+			edns.SetSubnet(m, n)
+		}
+		return m
+	}
+
+	key := coalesceKey(msg("example.org.", false, ""), "10.0.0.1:53", "udp")
+	tests := []struct {
+		m     *dns.Msg
+		host  string
+		proto string
+		same  bool
+	}{
+		{msg("example.org.", false, ""), "10.0.0.1:53", "udp", true},
+		{msg("example.org.", false, ""), "10.0.0.2:53", "udp", false},
+		{msg("example.org.", false, ""), "10.0.0.1:53", "tcp", false},
+		{msg("Example.org.", false, ""), "10.0.0.1:53", "udp", false},
+		{msg("example.org.", true, ""), "10.0.0.1:53", "udp", false},
+		{msg("example.org.", false, "10.1.1.0/24"), "10.0.0.1:53", "udp", false},
+	}
+	for i, tc := range tests {
+		if x := coalesceKey(tc.m, tc.host, tc.proto); (x == key) != tc.same {
+			t.Errorf("Test %d: expected same key to be %t, got %q and %q", i, tc.same, key, x)
+		}
+	}
+
+	if coalesceKey(msg("example.org.", false, "10.1.1.0/24"), "10.0.0.1:53", "udp") == coalesceKey(msg("example.org.", false, "10.2.2.0/24"), "10.0.0.1:53", "udp") {
+		t.Errorf("Expected different keys for different client subnets")
+	}
+}
+
+func TestCoalesce(t *testing.T) {
+	var queries int32
+	us, addr := udpServer(t, dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		atomic.AddInt32(&queries, 1)
+		time.Sleep(100 * time.Millisecond)
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = append(m.Answer, test.A("example.org. 3600 IN A 127.0.0.53"))
+		w.WriteMsg(m)
+	}))
+	defer us.Shutdown()
+
+	tests := []struct {
+		noCoalesce bool
+		do         []bool // the DO bit of the queries sent at the same time
+		expected   int32
+	}{
+		{false, []bool{false, false, false}, 1},
+		{false, []bool{false, true, true}, 2},
+		{true, []bool{false, false, false}, 3},
+	}
+	for i, tc := range tests {
+		atomic.StoreInt32(&queries, 0)
+		p := ReverseProxy{Host: addr, Client: Clients(), Options: Options{NoCoalesce: tc.noCoalesce, inflight: new(singleflight.Group)}}
+
+		var wg sync.WaitGroup
+		for _, do := range tc.do {
+			wg.Add(1)
+			go func(do bool) {
+				defer wg.Done()
+				m := new(dns.Msg)
+				m.SetQuestion("example.org.", dns.TypeA)
+				if do {
+					m.SetEdns0(4096, true)
+				}
+				rec := dnsrecorder.New(&test.ResponseWriter{})
+				if err := p.ServeDNS(rec, m, nil); err != nil {
+					t.Errorf("Test %d: expected no error, got %s", i, err)
+					return
+				}
+				if rec.Msg.Id != m.Id || len(rec.Msg.Answer) != 1 {
+					t.Errorf("Test %d: expected the reply to the query, got %v", i, rec.Msg)
+				}
+			}(do)
+		}
+		wg.Wait()
+
+		if x := atomic.LoadInt32(&queries); x != tc.expected {
+			t.Errorf("Test %d: expected %d queries to the upstream, got %d", i, tc.expected, x)
+		}
+	}
+}
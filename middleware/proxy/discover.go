@@ -0,0 +1,149 @@
+package proxy
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/coredns/middleware/pkg/watch"
+
+	"github.com/miekg/dns"
+)
+
+// Next to addresses, the hosts of an upstream can be given as a source the addresses are
+// discovered from: a resolv.conf file, whose nameservers are the hosts, or srv://NAME,
+// whose SRV records point to them. The file is read again when it changes, the SRV
+// records are looked up every refresh interval.
+
+const srvPrefix = "srv://"
+
+// isSource returns true if host is a source of hosts, instead of an address.
+func isSource(host string) bool {
+	if strings.HasPrefix(host, srvPrefix) {
+		return true
+	}
+	if net.ParseIP(strings.Trim(host, "[]")) != nil {
+		return false
+	}
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return false
+	}
+	fi, err := os.Stat(host)
+	return err == nil && !fi.IsDir()
+}
+
+// discover returns the addresses of the hosts in source.
+func discover(source string) ([]string, error) {
+	if strings.HasPrefix(source, srvPrefix) {
+		return lookupSRV(source[len(srvPrefix):])
+	}
+	return resolvConf(source)
+}
+
+// resolvConf returns the nameservers in the resolv.conf file.
+func resolvConf(file string) ([]string, error) {
+	cc, err := dns.ClientConfigFromFile(file)
+	if err != nil {
+		return nil, err
+	}
+	hosts := make([]string, len(cc.Servers))
+	for i, s := range cc.Servers {
+		hosts[i] = net.JoinHostPort(s, cc.Port)
+	}
+	return hosts, nil
+}
+
+// lookupSRV returns the addresses of the targets of the SRV records of name, with the port
+// of the record. The priority and weight of the records are not used, the policy of the
+// upstream picks a host.
+func lookupSRV(name string) ([]string, error) {
+	_, srvs, err := net.LookupSRV("", "", name)
+	if err != nil {
+		return nil, err
+	}
+	hosts := []string{}
+	for _, srv := range srvs {
+		addrs, err := net.LookupHost(srv.Target)
+		if err != nil {
+			log.Printf("[WARNING] Failed to look up SRV target %s of %s: %s", srv.Target, name, err)
+			continue
+		}
+		for _, a := range addrs {
+			hosts = append(hosts, net.JoinHostPort(a, strconv.Itoa(int(srv.Port))))
+		}
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("no addresses for the SRV records of %s", name)
+	}
+	return hosts, nil
+}
+
+// discoverHosts sets the hosts of u to its static hosts and the hosts discovered from its
+// sources. The hosts of a source that fails are kept as they were.
+func (u *staticUpstream) discoverHosts() {
+	names := append([]string{}, u.static...)
+	for _, source := range u.sources {
+		hosts, err := discover(source)
+		if err != nil {
+			log.Printf("[WARNING] Failed to discover the upstreams in %s: %s", source, err)
+			hosts = u.discovered[source]
+		}
+		u.discovered[source] = hosts
+		names = append(names, hosts...)
+	}
+	u.setHosts(names)
+}
+
+// startDiscovery keeps the hosts of u up to date with its sources, until u is stopped.
+// It is called on startup, not when the upstream is parsed, so a configuration that is
+// only checked, or that fails to load, doesn't leave watches behind.
+func (u *staticUpstream) startDiscovery() error {
+	if len(u.sources) == 0 {
+		return nil
+	}
+	srv := false
+	for _, source := range u.sources {
+		if strings.HasPrefix(source, srvPrefix) {
+			srv = true
+			continue
+		}
+		stop, err := watch.File(source, func() error {
+			u.discoverMu.Lock()
+			defer u.discoverMu.Unlock()
+			u.discoverHosts()
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		go func() {
+			<-u.stop
+			stop()
+		}()
+	}
+	if !srv {
+		return nil
+	}
+
+	go func() {
+		ticker := time.NewTicker(u.Refresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				u.discoverMu.Lock()
+				u.discoverHosts()
+				u.discoverMu.Unlock()
+			case <-u.stop:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+const defaultRefresh = 30 * time.Second
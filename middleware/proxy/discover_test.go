@@ -0,0 +1,146 @@
+package proxy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mholt/caddy"
+)
+
+func TestResolvConf(t *testing.T) {
+	dir, err := ioutil.TempDir("", "proxy-discover")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "resolv.conf")
+	if err := ioutil.WriteFile(file, []byte("nameserver 10.0.0.1\nnameserver 2001:db8::1\nsearch example.org\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	hosts, err := resolvConf(file)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	expected := []string{"10.0.0.1:53", "[2001:db8::1]:53"}
+	if !reflect.DeepEqual(hosts, expected) {
+		t.Errorf("Expected hosts %v, got %v", expected, hosts)
+	}
+
+	tests := []struct {
+		host     string
+		expected bool
+	}{
+		{file, true},
+		{dir, false},
+		{"srv://_dns._udp.example.org", true},
+		{"10.0.0.1", false},
+		{"10.0.0.1:53", false},
+		{"[2001:db8::1]:53", false},
+		{filepath.Join(dir, "missing"), false},
+	}
+	for i, tc := range tests {
+		if x := isSource(tc.host); x != tc.expected {
+			t.Errorf("Test %d: expected isSource(%s) to be %t, got %t", i, tc.host, tc.expected, x)
+		}
+	}
+
+	c := caddy.NewTestController("dns", "proxy . 10.0.0.2 "+file)
+	upstreams, err := NewStaticUpstreams(&c.Dispenser)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	u := upstreams[0].(*staticUpstream)
+	defer u.Stop()
+	names := []string{}
+	for _, h := range u.pool() {
+		names = append(names, h.Name)
+	}
+	expected = []string{"10.0.0.2:53", "10.0.0.1:53", "[2001:db8::1]:53"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected hosts %v, got %v", expected, names)
+	}
+
+	c = caddy.NewTestController("dns", "proxy . "+filepath.Join(dir, "missing"))
+	if _, err := NewStaticUpstreams(&c.Dispenser); err == nil || !strings.Contains(err.Error(), "not an IP address or file") {
+		t.Errorf("Expected an error for a missing file, got %v", err)
+	}
+}
+
+func TestSetHosts(t *testing.T) {
+	u := &staticUpstream{MaxFails: 1}
+	u.setHosts([]string{"10.0.0.1", "10.0.0.2:53"})
+	if len(u.Hosts) != 2 {
+		t.Fatalf("Expected 2 hosts, got %d", len(u.Hosts))
+	}
+	kept := u.Hosts[1]
+	kept.Unhealthy = true
+
+	u.setHosts([]string{"10.0.0.2", "10.0.0.3", "10.0.0.3:53"})
+	if len(u.Hosts) != 2 {
+		t.Fatalf("Expected 2 hosts, got %d", len(u.Hosts))
+	}
+	if u.Hosts[0] != kept || !u.Hosts[0].Down() {
+		t.Errorf("Expected 10.0.0.2:53 to be kept with its state")
+	}
+	if u.Hosts[1].Name != "10.0.0.3:53" || u.Hosts[1].Down() {
+		t.Errorf("Expected a new healthy host 10.0.0.3:53, got %s", u.Hosts[1].Name)
+	}
+}
+
+func TestStartDiscovery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "proxy-discover")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "resolv.conf")
+	if err := ioutil.WriteFile(file, []byte("nameserver 10.0.0.1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := caddy.NewTestController("dns", "proxy . "+file)
+	upstreams, err := NewStaticUpstreams(&c.Dispenser)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	u := upstreams[0].(*staticUpstream)
+	defer u.Stop()
+
+	hosts := func() string {
+		pool := u.pool()
+		if len(pool) != 1 {
+			return ""
+		}
+		return pool[0].Name
+	}
+
+	// Parsing doesn't start the discovery.
+	if err := ioutil.WriteFile(file, []byte("nameserver 10.0.0.2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	if h := hosts(); h != "10.0.0.1:53" {
+		t.Fatalf("Expected host 10.0.0.1:53 before startup, got %q", h)
+	}
+
+	if err := u.startDiscovery(); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if err := ioutil.WriteFile(file, []byte("nameserver 10.0.0.3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50 && hosts() != "10.0.0.3:53"; i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if h := hosts(); h != "10.0.0.3:53" {
+		t.Errorf("Expected host 10.0.0.3:53 after startup, got %q", h)
+	}
+}
@@ -0,0 +1,210 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/http2"
+)
+
+// httpsPrefix marks an upstream host that is reached over DNS-over-HTTPS.
+const httpsPrefix = "https://"
+
+// dohPath is the path of the DoH endpoint when the upstream host does not have one.
+const dohPath = "/dns-query"
+
+const (
+	mimeWire = "application/dns-message"
+	mimeJSON = "application/dns-json"
+)
+
+// newDoHClient returns the HTTP client for the upstream hosts reached over DNS-over-HTTPS.
+// It speaks HTTP/2 when the host does, so all queries to a host share one connection;
+// over HTTP/1.1 up to idle connections per host are kept.
+func newDoHClient(config *tls.Config, idle int) *http.Client {
+	tr := &http.Transport{TLSClientConfig: dohTLSConfig(config), MaxIdleConnsPerHost: idle}
+	if err := http2.ConfigureTransport(tr); err != nil {
+		log.Printf("[WARNING] Failed to enable HTTP/2 for DNS-over-HTTPS: %s", err)
+	}
+	return &http.Client{Transport: tr, Timeout: defaultTimeout}
+}
+
+// dohTLSConfig returns a new TLS config with the settings of config, the HTTP/2 transport
+// changes the config it is given and config is shared with the other protocols.
+func dohTLSConfig(config *tls.Config) *tls.Config {
+	if config == nil {
+		return &tls.Config{}
+	}
+	return &tls.Config{
+		Certificates:       config.Certificates,
+		RootCAs:            config.RootCAs,
+		ServerName:         config.ServerName,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+
+		VerifyPeerCertificate: config.VerifyPeerCertificate,
+	}
+}
+
+// exchangeDoH sends m to the DoH endpoint u and returns the reply. The query is POSTed in
+// wire format (RFC 8484), or with useJSON, sent to the JSON API of Google and Cloudflare.
+func exchangeDoH(client *http.Client, useJSON bool, config *tls.Config, m *dns.Msg, u string) (*dns.Msg, error) {
+	var (
+		req *http.Request
+		err error
+	)
+	if useJSON {
+		req, err = dohJSONRequest(m, u)
+	} else {
+		req, err = dohWireRequest(m, u)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if config != nil && config.ServerName != "" {
+		req.Host = config.ServerName
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("got HTTP status %d from %s", resp.StatusCode, u)
+	}
+	buf, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if useJSON {
+		return dohJSONReply(m, buf)
+	}
+	r := new(dns.Msg)
+	if err := r.Unpack(buf); err != nil {
+		return nil, err
+	}
+	r.Id = m.Id
+	return r, nil
+}
+
+// dohWireRequest returns the POST request for m. The message ID is zero, as RFC 8484
+// recommends, the ID of the reply is set by exchangeDoH.
+func dohWireRequest(m *dns.Msg, u string) (*http.Request, error) {
+	m1 := m.Copy()
+	m1.Id = 0
+	buf, err := m1.Pack()
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", u, bytes.NewReader(buf))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", mimeWire)
+	req.Header.Set("Accept", mimeWire)
+	return req, nil
+}
+
+// dohJSONRequest returns the GET request of the JSON API for m.
+func dohJSONRequest(m *dns.Msg, u string) (*http.Request, error) {
+	if len(m.Question) != 1 {
+		return nil, fmt.Errorf("can only send queries with one question to the JSON API")
+	}
+	q := url.Values{}
+	q.Set("name", m.Question[0].Name)
+	q.Set("type", fmt.Sprintf("%d", m.Question[0].Qtype))
+	if m.CheckingDisabled {
+		q.Set("cd", "1")
+	}
+	if opt := m.IsEdns0(); opt != nil && opt.Do() {
+		q.Set("do", "1")
+	}
+	req, err := http.NewRequest("GET", u+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", mimeJSON)
+	return req, nil
+}
+
+// jsonMsg is a reply of the JSON API.
+type jsonMsg struct {
+	Status     int
+	TC         bool
+	RA         bool
+	AD         bool
+	CD         bool
+	Answer     []jsonRR
+	Authority  []jsonRR
+	Additional []jsonRR
+}
+
+type jsonRR struct {
+	Name string `json:"name"`
+	Type uint16 `json:"type"`
+	TTL  uint32
+	Data string `json:"data"`
+}
+
+// dohJSONReply converts the JSON reply buf to the reply to m.
+func dohJSONReply(m *dns.Msg, buf []byte) (*dns.Msg, error) {
+	j := jsonMsg{}
+	if err := json.Unmarshal(buf, &j); err != nil {
+		return nil, err
+	}
+
+	r := new(dns.Msg)
+	r.SetReply(m)
+	r.Rcode = j.Status
+	r.Truncated, r.RecursionAvailable, r.AuthenticatedData, r.CheckingDisabled = j.TC, j.RA, j.AD, j.CD
+
+	var err error
+	if r.Answer, err = jsonRRs(j.Answer); err != nil {
+		return nil, err
+	}
+	if r.Ns, err = jsonRRs(j.Authority); err != nil {
+		return nil, err
+	}
+	if r.Extra, err = jsonRRs(j.Additional); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func jsonRRs(rrs []jsonRR) ([]dns.RR, error) {
+	if len(rrs) == 0 {
+		return nil, nil
+	}
+	out := make([]dns.RR, 0, len(rrs))
+	for _, j := range rrs {
+		t, ok := dns.TypeToString[j.Type]
+		if !ok {
+			t = fmt.Sprintf("TYPE%d", j.Type)
+		}
+		rr, err := dns.NewRR(fmt.Sprintf("%s %d IN %s %s", dns.Fqdn(j.Name), j.TTL, t, j.Data))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rr)
+	}
+	return out, nil
+}
+
+// dohAddr returns the address of the DoH upstream host s: its URL without the scheme and
+// path.
+func dohAddr(s string) string {
+	s = strings.TrimPrefix(s, httpsPrefix)
+	if i := strings.Index(s, "/"); i >= 0 {
+		s = s[:i]
+	}
+	return s
+}
@@ -0,0 +1,75 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestExchangeDoH(t *testing.T) {
+	hs := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("Accept") {
+		case mimeWire:
+			buf, _ := ioutil.ReadAll(r.Body)
+			m := new(dns.Msg)
+			if r.Method != "POST" || m.Unpack(buf) != nil || m.Id != 0 {
+				http.Error(w, "bad query", http.StatusBadRequest)
+				return
+			}
+			m.SetReply(m)
+			rr, _ := dns.NewRR("example.org. 3600 IN A 127.0.0.53")
+			m.Answer = append(m.Answer, rr)
+			buf, _ = m.Pack()
+			w.Header().Set("Content-Type", mimeWire)
+			w.Write(buf)
+		case mimeJSON:
+			if r.URL.Query().Get("name") != "example.org." || r.URL.Query().Get("type") != "1" {
+				http.Error(w, "bad query", http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", mimeJSON)
+			fmt.Fprint(w, `{"Status":0,"TC":false,"RD":true,"RA":true,"AD":false,"CD":false,`+
+				`"Question":[{"name":"example.org.","type":1}],`+
+				`"Answer":[{"name":"example.org.","type":1,"TTL":3600,"data":"127.0.0.53"}]}`)
+		default:
+			http.Error(w, "bad accept", http.StatusNotAcceptable)
+		}
+	}))
+	defer hs.Close()
+	leaf, err := x509.ParseCertificate(hs.TLS.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatalf("Could not parse test certificate: %s", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(leaf)
+	config := &tls.Config{RootCAs: roots}
+	client := newDoHClient(config, defaultMaxIdleConns)
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	u := hs.URL + dohPath
+
+	for _, json := range []bool{false, true} {
+		r, err := exchangeDoH(client, json, config, m, u)
+		if err != nil {
+			t.Fatalf("JSON %t: expected no error, got %s", json, err)
+		}
+		if r.Id != m.Id {
+			t.Errorf("JSON %t: expected ID %d, got %d", json, m.Id, r.Id)
+		}
+		if len(r.Answer) != 1 || r.Answer[0].(*dns.A).A.String() != "127.0.0.53" {
+			t.Errorf("JSON %t: expected the answer 127.0.0.53, got %v", json, r.Answer)
+		}
+	}
+
+	// Without our roots the certificate can't be verified.
+	if _, err := exchangeDoH(newDoHClient(nil, defaultMaxIdleConns), false, nil, m, u); err == nil {
+		t.Fatal("Expected an error for an unknown certificate")
+	}
+}
@@ -0,0 +1,97 @@
+package proxy
+
+import (
+	"net"
+
+	"github.com/miekg/coredns/middleware/pkg/edns"
+	"github.com/miekg/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// ECS policies, what the proxy does with the EDNS0 CLIENT SUBNET option of a query.
+const (
+	// EcsForward sends the option upstream as it was received.
+	EcsForward = iota
+	// EcsStrip removes the option.
+	EcsStrip
+	// EcsOverride replaces the option with the network from Options.Ecs of the same
+	// family as the client.
+	EcsOverride
+	// EcsClient adds the network of the client, if the query has no option.
+	EcsClient
+)
+
+// ecs applies the ECS policy to the query of state. If the query needs to be changed
+// a copy is returned, otherwise the query itself.
+func (o Options) ecs(state request.Request) (*dns.Msg, bool) {
+	r := state.Req
+	switch o.EcsPolicy {
+	case EcsStrip:
+		if edns.Subnet(r) == nil {
+			return r, false
+		}
+		r = r.Copy()
+		edns.StripSubnet(r)
+		return r, true
+	case EcsOverride:
+		n := o.ecsNet(state.Family())
+		if n == nil {
+			return r, false
+		}
+		r = r.Copy()
+		edns.SetSubnet(r, n)
+		return r, true
+	case EcsClient:
+		if edns.Subnet(r) != nil {
+			return r, false
+		}
+		ip := net.ParseIP(state.IP())
+		if ip == nil {
+			return r, false
+		}
+		n := &net.IPNet{IP: ip.Mask(net.CIDRMask(ecsIPv4Prefix, 32)), Mask: net.CIDRMask(ecsIPv4Prefix, 32)}
+		if ip.To4() == nil {
+			n = &net.IPNet{IP: ip.Mask(net.CIDRMask(ecsIPv6Prefix, 128)), Mask: net.CIDRMask(ecsIPv6Prefix, 128)}
+		}
+		r = r.Copy()
+		edns.SetSubnet(r, n)
+		return r, true
+	}
+	return r, false
+}
+
+// ecsNet returns the network from o.Ecs for family (1 for IPv4, 2 for IPv6), or nil.
+func (o Options) ecsNet(family int) *net.IPNet {
+	for _, n := range o.Ecs {
+		if (n.IP.To4() != nil) == (family == 1) {
+			return n
+		}
+	}
+	return nil
+}
+
+// ecsReply makes reply match the original query req, after the query sent upstream
+// was changed: a client that didn't send an OPT record must not get one back, and a
+// client that didn't send a client subnet must not get that option back.
+func ecsReply(req, reply *dns.Msg) {
+	if req.IsEdns0() == nil {
+		extra := reply.Extra[:0]
+		for _, rr := range reply.Extra {
+			if rr.Header().Rrtype != dns.TypeOPT {
+				extra = append(extra, rr)
+			}
+		}
+		reply.Extra = extra
+		return
+	}
+	if edns.Subnet(req) == nil {
+		edns.StripSubnet(reply)
+	}
+}
+
+// Prefix lengths used for the network of the client, as recommended by RFC 7871.
+const (
+	ecsIPv4Prefix = 24
+	ecsIPv6Prefix = 56
+)
@@ -0,0 +1,84 @@
+package proxy
+
+import (
+	"net"
+	"testing"
+
+	"github.com/miekg/coredns/middleware/pkg/edns"
+	"github.com/miekg/coredns/middleware/test"
+	"github.com/miekg/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+func TestEcs(t *testing.T) {
+	_, override, _ := net.ParseCIDR("192.0.2.0/24")
+	_, sent, _ := net.ParseCIDR("198.51.100.0/24")
+
+	tests := []struct {
+		policy   int
+		subnet   *net.IPNet // subnet in the query
+		expected string     // subnet sent upstream, "" for none
+		changed  bool
+	}{
+		{EcsForward, nil, "", false},
+		{EcsForward, sent, "198.51.100.0/24", false},
+		{EcsStrip, sent, "", true},
+		{EcsStrip, nil, "", false},
+		{EcsOverride, sent, "192.0.2.0/24", true},
+		{EcsOverride, nil, "192.0.2.0/24", true},
+		{EcsClient, nil, "10.240.0.0/24", true}, // test.ResponseWriter's address
+		{EcsClient, sent, "198.51.100.0/24", false},
+	}
+
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		if tc.subnet != nil {
+			edns.SetSubnet(m, tc.subnet)
+		}
+		o := Options{Ecs: []*net.IPNet{override}, EcsPolicy: tc.policy}
+
+		req, changed := o.ecs(request.Request{W: &test.ResponseWriter{}, Req: m})
+		if changed != tc.changed {
+			t.Errorf("Test %d: expected changed to be %t", i, tc.changed)
+		}
+		if changed && req == m {
+			t.Errorf("Test %d: expected a copy of the query", i)
+		}
+		s := edns.Subnet(req)
+		switch {
+		case tc.expected == "" && s != nil:
+			t.Errorf("Test %d: expected no subnet, got %s", i, edns.SubnetNet(s))
+		case tc.expected != "" && s == nil:
+			t.Errorf("Test %d: expected subnet %s, got none", i, tc.expected)
+		case tc.expected != "" && edns.SubnetNet(s).String() != tc.expected:
+			t.Errorf("Test %d: expected subnet %s, got %s", i, tc.expected, edns.SubnetNet(s))
+		}
+	}
+}
+
+func TestEcsReply(t *testing.T) {
+	_, n, _ := net.ParseCIDR("192.0.2.0/24")
+
+	// The client didn't send an OPT record, it should not get one back.
+	req := new(dns.Msg)
+	req.SetQuestion("example.org.", dns.TypeA)
+	reply := new(dns.Msg)
+	reply.SetReply(req)
+	edns.SetSubnet(reply, n)
+	ecsReply(req, reply)
+	if reply.IsEdns0() != nil {
+		t.Errorf("Expected no OPT record in the reply")
+	}
+
+	// The client sent an OPT record without a subnet.
+	req.SetEdns0(4096, false)
+	reply = new(dns.Msg)
+	reply.SetReply(req)
+	edns.SetSubnet(reply, n)
+	ecsReply(req, reply)
+	if reply.IsEdns0() == nil || edns.Subnet(reply) != nil {
+		t.Errorf("Expected OPT record without subnet in the reply")
+	}
+}
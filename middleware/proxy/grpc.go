@@ -0,0 +1,88 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"sync"
+
+	"github.com/miekg/coredns/pb"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// grpcPrefix marks an upstream host that is reached over gRPC.
+const grpcPrefix = "grpc://"
+
+// grpcClients holds the gRPC connections to the upstream hosts. There is one connection
+// per host, the queries are multiplexed over it.
+type grpcClients struct {
+	sync.Mutex
+	conns   map[string]*grpc.ClientConn
+	clients map[string]pb.DnsServiceClient
+}
+
+func newGRPCClients() *grpcClients {
+	return &grpcClients{conns: make(map[string]*grpc.ClientConn), clients: make(map[string]pb.DnsServiceClient)}
+}
+
+// client returns the client for addr, it connects to addr if it has not done so yet.
+// Without config the connection is not encrypted.
+func (g *grpcClients) client(addr string, config *tls.Config) (pb.DnsServiceClient, error) {
+	g.Lock()
+	defer g.Unlock()
+	if c, ok := g.clients[addr]; ok {
+		return c, nil
+	}
+	opt := grpc.WithInsecure()
+	if config != nil {
+		opt = grpc.WithTransportCredentials(credentials.NewTLS(config))
+	}
+	conn, err := grpc.Dial(addr, opt)
+	if err != nil {
+		return nil, err
+	}
+	g.conns[addr] = conn
+	g.clients[addr] = pb.NewDnsServiceClient(conn)
+	return g.clients[addr], nil
+}
+
+// close closes all connections.
+func (g *grpcClients) close() {
+	g.Lock()
+	defer g.Unlock()
+	for addr, conn := range g.conns {
+		conn.Close()
+		delete(g.conns, addr)
+		delete(g.clients, addr)
+	}
+}
+
+// exchangeGRPC sends m to addr over gRPC and returns the reply.
+func exchangeGRPC(g *grpcClients, config *tls.Config, m *dns.Msg, addr string) (*dns.Msg, error) {
+	c, err := g.client(addr, config)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := m.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	reply, err := c.Query(ctx, &pb.DnsPacket{Msg: buf})
+	if err != nil {
+		return nil, err
+	}
+
+	r := new(dns.Msg)
+	if err := r.Unpack(reply.Msg); err != nil {
+		return nil, err
+	}
+	if r.Id != m.Id {
+		return nil, dns.ErrId
+	}
+	return r, nil
+}
@@ -0,0 +1,61 @@
+package proxy
+
+import (
+	"net"
+	"testing"
+
+	"github.com/miekg/coredns/middleware/test"
+	"github.com/miekg/coredns/pb"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// grpcUpstream answers every query with an A record.
+type grpcUpstream struct{}
+
+func (grpcUpstream) Query(ctx context.Context, in *pb.DnsPacket) (*pb.DnsPacket, error) {
+	r := new(dns.Msg)
+	if err := r.Unpack(in.Msg); err != nil {
+		return nil, err
+	}
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Answer = append(m.Answer, test.A("example.org. 3600 IN A 127.0.0.53"))
+	buf, err := m.Pack()
+	if err != nil {
+		return nil, err
+	}
+	return &pb.DnsPacket{Msg: buf}, nil
+}
+
+func TestExchangeGRPC(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen: %s", err)
+	}
+	s := grpc.NewServer()
+	pb.RegisterDnsServiceServer(s, grpcUpstream{})
+	go s.Serve(l)
+	defer s.Stop()
+
+	g := newGRPCClients()
+	defer g.close()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	for i := 0; i < 2; i++ {
+		r, err := exchangeGRPC(g, nil, m, l.Addr().String())
+		if err != nil {
+			t.Fatalf("Query %d: expected no error, got %s", i, err)
+		}
+		if len(r.Answer) != 1 {
+			t.Errorf("Query %d: expected 1 answer, got %d", i, len(r.Answer))
+		}
+	}
+	// The connection is shared by the queries.
+	if len(g.conns) != 1 {
+		t.Errorf("Expected 1 connection, got %d", len(g.conns))
+	}
+}
@@ -0,0 +1,61 @@
+package proxy
+
+import (
+	"errors"
+	"time"
+)
+
+var errOverloaded = errors.New("too many queries to the upstreams")
+
+// limiter bounds the number of queries that are sent to the hosts of an upstream at the
+// same time. A query that finds all slots taken waits for one to come free, at most for
+// wait; coalesced queries share the slot of the query that is sent.
+type limiter struct {
+	from  string
+	slots chan struct{}
+	wait  time.Duration
+}
+
+func newLimiter(from string, n int, wait time.Duration) *limiter {
+	return &limiter{from: from, slots: make(chan struct{}, n), wait: wait}
+}
+
+// acquire takes a slot and returns true, or returns false when none came free in time. A
+// nil limiter always has a free slot.
+func (l *limiter) acquire() bool {
+	if l == nil {
+		return true
+	}
+	select {
+	case l.slots <- struct{}{}:
+		l.report()
+		return true
+	default:
+	}
+
+	if l.wait > 0 {
+		t := time.NewTimer(l.wait)
+		defer t.Stop()
+		select {
+		case l.slots <- struct{}{}:
+			l.report()
+			return true
+		case <-t.C:
+		}
+	}
+	overloadCount.WithLabelValues(l.from).Inc()
+	return false
+}
+
+// release gives back a slot taken by acquire.
+func (l *limiter) release() {
+	if l == nil {
+		return
+	}
+	<-l.slots
+	l.report()
+}
+
+func (l *limiter) report() {
+	saturation.WithLabelValues(l.from).Set(float64(len(l.slots)) / float64(cap(l.slots)))
+}
@@ -0,0 +1,62 @@
+package proxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/coredns/middleware/pkg/dnsrecorder"
+	"github.com/miekg/coredns/middleware/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestLimiter(t *testing.T) {
+	var l *limiter
+	if !l.acquire() {
+		t.Fatalf("Expected a nil limiter to have a free slot")
+	}
+	l.release()
+
+	l = newLimiter(".", 1, 0)
+	if !l.acquire() {
+		t.Fatalf("Expected a free slot")
+	}
+	if l.acquire() {
+		t.Fatalf("Expected no free slot")
+	}
+
+	// A query that waits gets the slot that is released in the mean time.
+	l.wait = time.Second
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		l.release()
+	}()
+	if !l.acquire() {
+		t.Fatalf("Expected the released slot")
+	}
+	l.release()
+}
+
+func TestReverseProxyOverloaded(t *testing.T) {
+	us, addr := udpServer(t, dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		w.WriteMsg(m)
+	}))
+	defer us.Shutdown()
+
+	l := newLimiter(".", 1, 0)
+	p := ReverseProxy{Host: addr, Client: Clients(), Options: Options{limiter: l}}
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	if err := p.ServeDNS(dnsrecorder.New(&test.ResponseWriter{}), m, nil); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	l.acquire()
+	if err := p.ServeDNS(dnsrecorder.New(&test.ResponseWriter{}), m, nil); err != errOverloaded {
+		t.Errorf("Expected %s, got %v", errOverloaded, err)
+	}
+	l.release()
+}
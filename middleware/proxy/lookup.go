@@ -85,15 +85,16 @@ func (p Proxy) lookup(state request.Request, r *dns.Msg) (*dns.Msg, error) {
 				return nil, errUnreachable
 			}
 
+			req, changed := upstream.Options().ecs(request.Request{W: state.W, Req: r})
+
 			atomic.AddInt64(&host.Conns, 1)
-			if state.Proto() == "tcp" {
-				reply, _, err = p.Client.TCP.Exchange(r, host.Name)
-			} else {
-				reply, _, err = p.Client.UDP.Exchange(r, host.Name)
-			}
+			reply, err = exchange(p.Client, upstream.Options(), req, host.Name, state.Proto())
 			atomic.AddInt64(&host.Conns, -1)
 
 			if err == nil {
+				if changed {
+					ecsReply(r, reply)
+				}
 				return reply, nil
 			}
 			timeout := host.FailTimeout
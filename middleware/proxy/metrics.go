@@ -0,0 +1,61 @@
+package proxy
+
+import (
+	"github.com/miekg/coredns/middleware"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	requestCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: middleware.Namespace,
+		Subsystem: "proxy",
+		Name:      "request_count_total",
+		Help:      "Counter of queries sent per upstream and protocol.",
+	}, []string{"to", "proto"})
+
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: middleware.Namespace,
+		Subsystem: "proxy",
+		Name:      "request_duration_milliseconds",
+		Buckets:   append(prometheus.DefBuckets, []float64{50, 100, 200, 500, 1000, 2000, 3000, 4000, 5000}...),
+		Help:      "Histogram of the time (in milliseconds) each query to an upstream took, per upstream and protocol.",
+	}, []string{"to", "proto"})
+
+	failureCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: middleware.Namespace,
+		Subsystem: "proxy",
+		Name:      "failures_total",
+		Help:      "Counter of queries to an upstream that failed, per upstream and protocol.",
+	}, []string{"to", "proto"})
+
+	healthy = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: middleware.Namespace,
+		Subsystem: "proxy",
+		Name:      "upstream_healthy",
+		Help:      "Gauge that is 1 when an upstream passes its health check and 0 when it does not.",
+	}, []string{"to"})
+
+	saturation = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: middleware.Namespace,
+		Subsystem: "proxy",
+		Name:      "concurrent_saturation",
+		Help:      "Gauge of the share of the max_concurrent slots of an upstream that are in use, from 0 to 1.",
+	}, []string{"from"})
+
+	overloadCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: middleware.Namespace,
+		Subsystem: "proxy",
+		Name:      "overloaded_queries_total",
+		Help:      "Counter of queries that were not sent because all max_concurrent slots of an upstream were in use.",
+	}, []string{"from"})
+)
+
+func init() {
+	prometheus.MustRegister(requestCount)
+	prometheus.MustRegister(requestDuration)
+	prometheus.MustRegister(failureCount)
+	prometheus.MustRegister(healthy)
+	prometheus.MustRegister(saturation)
+	prometheus.MustRegister(overloadCount)
+}
@@ -20,6 +20,7 @@ func init() {
 	RegisterPolicy("random", func() Policy { return &Random{} })
 	RegisterPolicy("least_conn", func() Policy { return &LeastConn{} })
 	RegisterPolicy("round_robin", func() Policy { return &RoundRobin{} })
+	RegisterPolicy("first", func() Policy { return &First{} })
 }
 
 // Random is a policy that selects up hosts from a pool at random.
@@ -111,3 +112,17 @@ func (r *RoundRobin) Select(pool HostPool) *UpstreamHost {
 	}
 	return host
 }
+
+// First is a policy that selects the first up host, in the order they are configured. The
+// other hosts are only used when the ones before them are down.
+type First struct{}
+
+// Select selects the first up host from the pool.
+func (r *First) Select(pool HostPool) *UpstreamHost {
+	for _, host := range pool {
+		if !host.Down() {
+			return host
+		}
+	}
+	return nil
+}
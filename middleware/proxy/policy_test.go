@@ -77,6 +77,23 @@ func TestLeastConnPolicy(t *testing.T) {
 	}
 }
 
+func TestFirstPolicy(t *testing.T) {
+	pool := testPool()
+	firstPolicy := &First{}
+	if h := firstPolicy.Select(pool); h != pool[0] {
+		t.Error("Expected first host to be the first host in the pool.")
+	}
+	pool[0].Unhealthy = true
+	if h := firstPolicy.Select(pool); h != pool[1] {
+		t.Error("Expected first host to be the second host in the pool, when the first is down.")
+	}
+	pool[1].Unhealthy = true
+	pool[2].Unhealthy = true
+	if h := firstPolicy.Select(pool); h != nil {
+		t.Error("Expected no host when all are down.")
+	}
+}
+
 func TestCustomPolicy(t *testing.T) {
 	pool := testPool()
 	customPolicy := &customPolicy{}
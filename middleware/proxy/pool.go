@@ -0,0 +1,164 @@
+package proxy
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// connPool keeps idle connections to upstream hosts, so not every query needs to set up a
+// connection. Connections are dialed on demand: when the pool has no idle connection to a
+// host, or the one it had turns out to be broken. A nil pool keeps nothing, every query
+// gets its own connection.
+//
+// Only connection oriented protocols (TCP and TLS) are pooled and each has its own pool.
+// UDP queries use a socket of their own, so a late reply to an earlier query is never
+// mistaken for the reply to the next one.
+//
+// Connections can also be recycled, closed instead of returned to the pool, after they
+// carried a number of queries or have been open for some time. Middleboxes and upstreams
+// that silently drop long-lived flows then never see one.
+type connPool struct {
+	sync.Mutex
+	idle       map[string][]idleConn
+	size       int           // maximum number of idle connections per host
+	expire     time.Duration // idle connections older than this are closed
+	maxAge     time.Duration // connections open longer than this are recycled, 0 is no limit
+	maxQueries int           // connections that carried this many queries are recycled, 0 is no limit
+}
+
+// pooledConn is a connection to an upstream host, with what is needed to recycle it.
+type pooledConn struct {
+	*dns.Conn
+	created time.Time
+	queries int
+}
+
+type idleConn struct {
+	co   *pooledConn
+	used time.Time
+}
+
+func newConnPool(size int, expire, maxAge time.Duration, maxQueries int) *connPool {
+	return &connPool{idle: make(map[string][]idleConn), size: size, expire: expire, maxAge: maxAge, maxQueries: maxQueries}
+}
+
+// recycle returns true if co should be closed instead of used again.
+func (p *connPool) recycle(co *pooledConn) bool {
+	if p.maxQueries > 0 && co.queries >= p.maxQueries {
+		return true
+	}
+	return p.maxAge > 0 && time.Since(co.created) >= p.maxAge
+}
+
+// get returns an idle connection to addr, or nil if there is none. Expired connections,
+// and the ones that are due to be recycled, are closed.
+func (p *connPool) get(addr string) *pooledConn {
+	if p == nil {
+		return nil
+	}
+	p.Lock()
+	defer p.Unlock()
+	conns := p.idle[addr]
+	for len(conns) > 0 {
+		c := conns[len(conns)-1]
+		conns = conns[:len(conns)-1]
+		if p.expire > 0 && time.Since(c.used) > p.expire || p.recycle(c.co) {
+			c.co.Close()
+			continue
+		}
+		p.idle[addr] = conns
+		return c.co
+	}
+	delete(p.idle, addr)
+	return nil
+}
+
+// put returns co to the pool, it is closed if there are enough idle connections to addr
+// or if it is due to be recycled.
+func (p *connPool) put(addr string, co *pooledConn) {
+	if p == nil {
+		co.Close()
+		return
+	}
+	p.Lock()
+	defer p.Unlock()
+	if len(p.idle[addr]) >= p.size || p.recycle(co) {
+		co.Close()
+		return
+	}
+	p.idle[addr] = append(p.idle[addr], idleConn{co: co, used: time.Now()})
+}
+
+// close closes all idle connections.
+func (p *connPool) close() {
+	if p == nil {
+		return
+	}
+	p.Lock()
+	defer p.Unlock()
+	for addr, conns := range p.idle {
+		for _, c := range conns {
+			c.co.Close()
+		}
+		delete(p.idle, addr)
+	}
+}
+
+// exchangePooled sends m to addr over a connection from pool and returns the reply. When
+// the pool has no connection, or sending over it fails, most likely because the upstream
+// closed it, m is sent over a new connection from dial. A connection that worked is
+// returned to the pool.
+func exchangePooled(pool *connPool, m *dns.Msg, addr string, dial func() (net.Conn, error)) (*dns.Msg, error) {
+	if co := pool.get(addr); co != nil {
+		if r, err := exchangeConn(co.Conn, m); err == nil {
+			co.queries++
+			pool.put(addr, co)
+			return r, nil
+		}
+		co.Close()
+	}
+
+	conn, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	co := &pooledConn{Conn: &dns.Conn{Conn: conn}, created: time.Now()}
+	r, err := exchangeConn(co.Conn, m)
+	if err != nil {
+		co.Close()
+		return nil, err
+	}
+	co.queries++
+	pool.put(addr, co)
+	return r, nil
+}
+
+// exchangeTCP sends m to addr over TCP and returns the reply.
+func exchangeTCP(pool *connPool, m *dns.Msg, addr string) (*dns.Msg, error) {
+	return exchangePooled(pool, m, addr, func() (net.Conn, error) {
+		return net.DialTimeout("tcp", addr, defaultTimeout)
+	})
+}
+
+func exchangeConn(co *dns.Conn, m *dns.Msg) (*dns.Msg, error) {
+	co.SetDeadline(time.Now().Add(defaultTimeout))
+	if err := co.WriteMsg(m); err != nil {
+		return nil, err
+	}
+	r, err := co.ReadMsg()
+	if err != nil {
+		return nil, err
+	}
+	if r.Id != m.Id {
+		return nil, dns.ErrId
+	}
+	return r, nil
+}
+
+const (
+	defaultMaxIdleConns = 4
+	defaultIdleTimeout  = 10 * time.Second
+)
@@ -0,0 +1,222 @@
+package proxy
+
+import (
+	"encoding/binary"
+	"io"
+	"math/rand"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/coredns/middleware/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestExchangeTCP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen: %s", err)
+	}
+	started := make(chan struct{})
+	s := &dns.Server{
+		Listener:          l,
+		NotifyStartedFunc: func() { close(started) },
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+			m := new(dns.Msg)
+			m.SetReply(r)
+			m.Answer = append(m.Answer, test.A("example.org. 3600 IN A 127.0.0.53"))
+			w.WriteMsg(m)
+		}),
+	}
+	go s.ActivateAndServe()
+	<-started
+	defer s.Shutdown()
+	addr := l.Addr().String()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+
+	// Without a pool every query gets its own connection.
+	if _, err := exchangeTCP(nil, m, addr); err != nil {
+		t.Fatalf("Expected no error without a pool, got %s", err)
+	}
+
+	pool := newConnPool(1, time.Minute, 0, 0)
+	for i := 0; i < 2; i++ {
+		r, err := exchangeTCP(pool, m, addr)
+		if err != nil {
+			t.Fatalf("Query %d: expected no error, got %s", i, err)
+		}
+		if len(r.Answer) != 1 {
+			t.Errorf("Query %d: expected 1 answer, got %d", i, len(r.Answer))
+		}
+		if n := len(pool.idle[addr]); n != 1 {
+			t.Errorf("Query %d: expected 1 idle connection, got %d", i, n)
+		}
+	}
+
+	// A broken connection is replaced by a new one.
+	pool.idle[addr][0].co.Close()
+	if _, err := exchangeTCP(pool, m, addr); err != nil {
+		t.Fatalf("Expected no error after the connection was closed, got %s", err)
+	}
+
+	pool.close()
+	if n := len(pool.idle[addr]); n != 0 {
+		t.Errorf("Expected no idle connections after close, got %d", n)
+	}
+}
+
+func TestConnPool(t *testing.T) {
+	pool := newConnPool(2, time.Minute, 0, 0)
+	conns := make([]*pooledConn, 3)
+	for i := range conns {
+		c1, c2 := net.Pipe()
+		defer c2.Close()
+		conns[i] = &pooledConn{Conn: &dns.Conn{Conn: c1}, created: time.Now()}
+		pool.put("10.0.0.1:53", conns[i])
+	}
+	// The third connection did not fit.
+	if n := len(pool.idle["10.0.0.1:53"]); n != 2 {
+		t.Fatalf("Expected 2 idle connections, got %d", n)
+	}
+	if co := pool.get("10.0.0.1:53"); co != conns[1] {
+		t.Errorf("Expected the most recently used connection")
+	}
+	if co := pool.get("10.0.0.2:53"); co != nil {
+		t.Errorf("Expected no connection for another host")
+	}
+
+	// Expired connections are closed and not returned.
+	pool.idle["10.0.0.1:53"][0].used = time.Now().Add(-2 * time.Minute)
+	if co := pool.get("10.0.0.1:53"); co != nil {
+		t.Errorf("Expected no connection after expiry, got one")
+	}
+
+	// A pool of size 0 keeps nothing.
+	pool = newConnPool(0, time.Minute, 0, 0)
+	c1, c2 := net.Pipe()
+	defer c2.Close()
+	pool.put("10.0.0.1:53", &pooledConn{Conn: &dns.Conn{Conn: c1}, created: time.Now()})
+	if co := pool.get("10.0.0.1:53"); co != nil {
+		t.Errorf("Expected no connection from a pool of size 0")
+	}
+}
+
+func TestConnPoolRecycle(t *testing.T) {
+	pool := newConnPool(2, time.Minute, time.Minute, 2)
+	newConn := func() *pooledConn {
+		c1, c2 := net.Pipe()
+		c2.Close()
+		return &pooledConn{Conn: &dns.Conn{Conn: c1}, created: time.Now()}
+	}
+
+	// A connection that carried the maximum number of queries is not kept.
+	co := newConn()
+	co.queries = 2
+	pool.put("10.0.0.1:53", co)
+	if n := len(pool.idle["10.0.0.1:53"]); n != 0 {
+		t.Errorf("Expected no idle connections after max queries, got %d", n)
+	}
+
+	// Neither is one that is too old.
+	co = newConn()
+	co.created = time.Now().Add(-2 * time.Minute)
+	pool.put("10.0.0.1:53", co)
+	if n := len(pool.idle["10.0.0.1:53"]); n != 0 {
+		t.Errorf("Expected no idle connections after max age, got %d", n)
+	}
+
+	// One that becomes too old while it is idle is not handed out.
+	co = newConn()
+	pool.put("10.0.0.1:53", co)
+	co.created = time.Now().Add(-2 * time.Minute)
+	if co := pool.get("10.0.0.1:53"); co != nil {
+		t.Errorf("Expected no connection after max age, got one")
+	}
+
+	co = newConn()
+	co.queries = 1
+	pool.put("10.0.0.1:53", co)
+	if x := pool.get("10.0.0.1:53"); x != co {
+		t.Errorf("Expected the connection to be reused")
+	}
+}
+
+// TestExchangeConnFraming sends queries with names in random case over one connection to
+// a server that writes its replies, compressed or not, one byte at a time, so the length
+// prefix and the messages arrive in pieces.
+func TestExchangeConnFraming(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen: %s", err)
+	}
+	defer l.Close()
+
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		for i := 0; ; i++ {
+			var n uint16
+			if err := binary.Read(c, binary.BigEndian, &n); err != nil {
+				return
+			}
+			buf := make([]byte, n)
+			if _, err := io.ReadFull(c, buf); err != nil {
+				return
+			}
+			r := new(dns.Msg)
+			if err := r.Unpack(buf); err != nil {
+				return
+			}
+			m := new(dns.Msg)
+			m.SetReply(r)
+			m.Compress = i%2 == 0
+			m.Answer = append(m.Answer, test.CNAME(r.Question[0].Name+" 3600 IN CNAME a."+r.Question[0].Name))
+			out, err := m.Pack()
+			if err != nil {
+				return
+			}
+			out = append([]byte{byte(len(out) >> 8), byte(len(out))}, out...)
+			for j := range out {
+				if _, err := c.Write(out[j : j+1]); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Could not dial: %s", err)
+	}
+	co := &dns.Conn{Conn: conn}
+	defer co.Close()
+
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 20; i++ {
+		name := []byte("www.example.org.")
+		for j := range name {
+			if name[j] >= 'a' && name[j] <= 'z' && rnd.Intn(2) == 0 {
+				name[j] -= 'a' - 'A'
+			}
+		}
+		m := new(dns.Msg)
+		m.SetQuestion(string(name), dns.TypeA)
+
+		r, err := exchangeConn(co, m)
+		if err != nil {
+			t.Fatalf("Query %d: expected no error, got %s", i, err)
+		}
+		if r.Question[0].Name != string(name) {
+			t.Errorf("Query %d: expected question %s, got %s", i, name, r.Question[0].Name)
+		}
+		if len(r.Answer) != 1 || r.Answer[0].Header().Name != string(name) {
+			t.Errorf("Query %d: expected an answer for %s, got %v", i, name, r.Answer)
+		}
+	}
+}
@@ -7,12 +7,17 @@ import (
 	"time"
 
 	"github.com/miekg/coredns/middleware"
+	"github.com/miekg/coredns/middleware/pkg/audit"
+	"github.com/miekg/coredns/request"
 
 	"github.com/miekg/dns"
 	"golang.org/x/net/context"
 )
 
-var errUnreachable = errors.New("unreachable backend")
+var (
+	errUnreachable = errors.New("unreachable backend")
+	errFormErr     = errors.New("backend returned FORMERR")
+)
 
 // Proxy represents a middleware instance that can proxy requests.
 type Proxy struct {
@@ -52,6 +57,8 @@ type UpstreamHost struct {
 	Unhealthy         bool
 	CheckDown         UpstreamHostDownFunc
 	WithoutPathPrefix string
+
+	checkFails int // failed health checks in a row, only used by the health check worker
 }
 
 // Down checks whether the upstream host is down or not.
@@ -72,24 +79,47 @@ var tryDuration = 60 * time.Second
 
 // ServeDNS satisfies the middleware.Handler interface.
 func (p Proxy) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
-	for _, upstream := range p.Upstreams {
+	if upstream := p.match(request.Request{W: w, Req: r}); upstream != nil {
 		start := time.Now()
 
 		// Since Select() should give us "up" hosts, keep retrying
-		// hosts until timeout (or until we get a nil host).
-		for time.Now().Sub(start) < tryDuration {
+		// hosts until timeout (or until we get a nil host), or until
+		// we have tried the configured number of hosts.
+		tries := upstream.Options().Tries
+		for try := 0; time.Now().Sub(start) < tryDuration; try++ {
+			if tries > 0 && try >= tries {
+				audit.Add(ctx, "proxy", "giving up after %d tries", try)
+				break
+			}
+			if err := ctx.Err(); err != nil {
+				// The client has given up.
+				audit.Add(ctx, "proxy", "query cancelled: %s", err)
+				return dns.RcodeServerFailure, err
+			}
 			host := upstream.Select()
 			if host == nil {
+				audit.Add(ctx, "proxy", "no healthy upstream for %s", upstream.From())
 				return dns.RcodeServerFailure, errUnreachable
 			}
 			reverseproxy := ReverseProxy{Host: host.Name, Client: p.Client, Options: upstream.Options()}
 
 			atomic.AddInt64(&host.Conns, 1)
+			reqTime := time.Now()
 			backendErr := reverseproxy.ServeDNS(w, r, nil)
 			atomic.AddInt64(&host.Conns, -1)
 			if backendErr == nil {
+				audit.Add(ctx, "proxy", "answered by %s in %s", host.Name, time.Since(reqTime))
 				return 0, nil
 			}
+			if backendErr == errOverloaded {
+				// Not the fault of the host, and the others are just as busy.
+				audit.Add(ctx, "proxy", "too many queries to the upstreams of %s", upstream.From())
+				if upstream.Options().OverloadDrop {
+					return dns.RcodeSuccess, nil
+				}
+				return dns.RcodeServerFailure, errOverloaded
+			}
+			audit.Add(ctx, "proxy", "error from %s: %s", host.Name, backendErr)
 			timeout := host.FailTimeout
 			if timeout == 0 {
 				timeout = 10 * time.Second
@@ -105,6 +135,34 @@ func (p Proxy) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (
 	return p.Next.ServeDNS(ctx, w, r)
 }
 
+// match returns the upstream for the query in state: the one with the longest From that
+// contains the query name and does not except it. When more than one has that From, an
+// upstream that only takes the type of the query is preferred over one that takes all
+// types. It returns nil if no upstream matches.
+func (p Proxy) match(state request.Request) Upstream {
+	var (
+		best      Upstream
+		bestLen   = -1
+		bestTyped bool
+	)
+	qname, qtype := state.Name(), state.QType()
+	for _, u := range p.Upstreams {
+		from := middleware.Host(u.From()).Normalize()
+		if !middleware.Name(from).Matches(qname) || !u.IsAllowedPath(qname) {
+			continue
+		}
+		types := u.Options().Types
+		typed := len(types) > 0
+		if typed && !types[qtype] {
+			continue
+		}
+		if l := dns.CountLabel(from); l > bestLen || (l == bestLen && typed && !bestTyped) {
+			best, bestLen, bestTyped = u, l, typed
+		}
+	}
+	return best
+}
+
 // Clients returns the new client for proxy requests.
 func Clients() Client {
 	udp := newClient("udp", defaultTimeout)
@@ -117,7 +175,8 @@ func newClient(net string, timeout time.Duration) *dns.Client {
 	if timeout == 0 {
 		timeout = defaultTimeout
 	}
-	return &dns.Client{Net: net, ReadTimeout: timeout, WriteTimeout: timeout, SingleInflight: true}
+	// Identical queries are coalesced by the proxy, on more than the question.
+	return &dns.Client{Net: net, ReadTimeout: timeout, WriteTimeout: timeout}
 }
 
 const defaultTimeout = 5 * time.Second
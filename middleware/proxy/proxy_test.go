@@ -1,5 +1,20 @@
 package proxy
 
+import (
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/coredns/middleware/pkg/dnsrecorder"
+	"github.com/miekg/coredns/middleware/test"
+	"github.com/miekg/coredns/request"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
 // Also test these inputs:
 //.:1053 {
 //proxy . ::1 2001:4860:4860::8844 8.8.8.8:54 [2001:4860:4860::8845]:53
@@ -301,3 +316,206 @@ func (c *fakeConn) Close() error                       { return nil }
 func (c *fakeConn) Read(b []byte) (int, error)         { return c.readBuf.Read(b) }
 func (c *fakeConn) Write(b []byte) (int, error)        { return c.writeBuf.Write(b) }
 */
+
+func TestProxyFailover(t *testing.T) {
+	formerr := dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetRcode(r, dns.RcodeFormatError)
+		w.WriteMsg(m)
+	})
+	answer := dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = append(m.Answer, test.A("example.org. 3600 IN A 127.0.0.53"))
+		w.WriteMsg(m)
+	})
+	broken, brokenAddr := udpServer(t, formerr)
+	defer broken.Shutdown()
+	good, goodAddr := udpServer(t, answer)
+	defer good.Shutdown()
+
+	tests := []struct {
+		tries         int
+		expectedRcode int
+	}{
+		{0, dns.RcodeSuccess},
+		{2, dns.RcodeSuccess},
+		{1, dns.RcodeServerFailure}, // only the broken upstream is tried
+	}
+	for i, tc := range tests {
+		upstream := &staticUpstream{
+			from:        ".",
+			Hosts:       HostPool{{Name: brokenAddr, FailTimeout: time.Second}, {Name: goodAddr, FailTimeout: time.Second}},
+			Policy:      &First{},
+			FailTimeout: time.Second,
+			MaxFails:    1,
+			options:     Options{Tries: tc.tries},
+		}
+		p := Proxy{Client: Clients(), Upstreams: []Upstream{upstream}}
+
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		rec := dnsrecorder.New(&test.ResponseWriter{})
+		rcode, _ := p.ServeDNS(context.TODO(), rec, m)
+		if rcode == 0 {
+			rcode = rec.Rcode
+		}
+		if rcode != tc.expectedRcode {
+			t.Errorf("Test %d: expected rcode %s, got %s", i, dns.RcodeToString[tc.expectedRcode], dns.RcodeToString[rcode])
+		}
+	}
+}
+
+func TestExchangeTruncated(t *testing.T) {
+	var mu sync.Mutex
+	protos := []string{}
+	// Over UDP the reply is truncated, over TCP it is complete.
+	h := dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		proto := "udp"
+		if _, ok := w.RemoteAddr().(*net.TCPAddr); ok {
+			proto = "tcp"
+		}
+		mu.Lock()
+		protos = append(protos, proto)
+		mu.Unlock()
+
+		m := new(dns.Msg)
+		m.SetReply(r)
+		if proto == "udp" {
+			m.Truncated = true
+		} else {
+			m.Answer = append(m.Answer, test.A("example.org. 3600 IN A 127.0.0.53"))
+		}
+		w.WriteMsg(m)
+	})
+	us, addr := udpServer(t, h)
+	defer us.Shutdown()
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("Could not listen: %s", err)
+	}
+	started := make(chan struct{})
+	ts := &dns.Server{Listener: l, Handler: h, NotifyStartedFunc: func() { close(started) }}
+	go ts.ActivateAndServe()
+	<-started
+	defer ts.Shutdown()
+
+	tests := []struct {
+		proto    string
+		options  Options
+		expected string
+	}{
+		{"udp", Options{}, "udp tcp"}, // fall back to TCP
+		{"tcp", Options{}, "tcp"},
+		{"udp", Options{ForceTCP: true}, "tcp"},
+		{"tcp", Options{PreferUDP: true}, "udp tcp"},
+	}
+	for i, tc := range tests {
+		mu.Lock()
+		protos = []string{}
+		mu.Unlock()
+
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		r, err := exchange(Clients(), tc.options, m, addr, tc.proto)
+		if err != nil {
+			t.Fatalf("Test %d: expected no error, got %s", i, err)
+		}
+		if r.Truncated || len(r.Answer) != 1 {
+			t.Errorf("Test %d: expected the complete reply, got %v", i, r)
+		}
+		mu.Lock()
+		if got := strings.Join(protos, " "); got != tc.expected {
+			t.Errorf("Test %d: expected queries over %q, got %q", i, tc.expected, got)
+		}
+		mu.Unlock()
+	}
+}
+
+// udpServer starts a DNS server on a random UDP port of localhost that hands all queries to h.
+func udpServer(t *testing.T, h dns.Handler) (*dns.Server, string) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen: %s", err)
+	}
+	started := make(chan struct{})
+	s := &dns.Server{PacketConn: pc, Handler: h, NotifyStartedFunc: func() { close(started) }}
+	go s.ActivateAndServe()
+	<-started
+	return s, pc.LocalAddr().String()
+}
+
+func TestProxyMatch(t *testing.T) {
+	all := &staticUpstream{from: "."}
+	ptr := &staticUpstream{from: ".", options: Options{Types: map[uint16]bool{dns.TypePTR: true}}}
+	corp := &staticUpstream{from: "corp.example.org"}
+	p := Proxy{Upstreams: []Upstream{all, ptr, corp}}
+
+	tests := []struct {
+		qname    string
+		qtype    uint16
+		expected Upstream
+	}{
+		{"example.org.", dns.TypeA, all},
+		{"1.0.0.10.in-addr.arpa.", dns.TypePTR, ptr},
+		{"www.corp.example.org.", dns.TypeA, corp},
+		{"www.corp.example.org.", dns.TypePTR, corp}, // the longest match wins
+	}
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, tc.qtype)
+		if u := p.match(request.Request{W: &test.ResponseWriter{}, Req: m}); u != tc.expected {
+			t.Errorf("Test %d: expected upstream %v for %s %s, got %v", i, tc.expected, tc.qname, dns.TypeToString[tc.qtype], u)
+		}
+	}
+
+	// An excepted name is given to the next best upstream.
+	all.IgnoredSubDomains = []string{"example.org."}
+	m := new(dns.Msg)
+	m.SetQuestion("www.example.org.", dns.TypeA)
+	if u := p.match(request.Request{W: &test.ResponseWriter{}, Req: m}); u != nil {
+		t.Errorf("Expected no upstream for an excepted name, got %s", u.From())
+	}
+	if u := p.match(request.Request{W: &test.ResponseWriter{}, Req: m.SetQuestion("www.corp.example.org.", dns.TypeA)}); u != corp {
+		t.Errorf("Expected upstream %v for an excepted name, got %v", corp, u)
+	}
+
+	// Without a catch all upstream, other types fall through.
+	p = Proxy{Upstreams: []Upstream{ptr}}
+	m.SetQuestion("example.org.", dns.TypeA)
+	if u := p.match(request.Request{W: &test.ResponseWriter{}, Req: m}); u != nil {
+		t.Errorf("Expected no upstream for an A query, got %s", u.From())
+	}
+}
+
+func TestClampTTL(t *testing.T) {
+	tests := []struct {
+		min, max uint32
+		expected []uint32 // the TTLs of the answer, authority and additional record
+	}{
+		{0, 0, []uint32{0, 604800, 300}},
+		{30, 0, []uint32{30, 604800, 300}},
+		{0, 3600, []uint32{0, 3600, 300}},
+		{30, 3600, []uint32{30, 3600, 300}},
+	}
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		m.Answer = []dns.RR{test.A("example.org. 0 IN A 127.0.0.53")}
+		m.Ns = []dns.RR{test.NS("example.org. 604800 IN NS ns.example.org.")}
+		m.Extra = []dns.RR{test.A("ns.example.org. 300 IN A 127.0.0.54")}
+		m.SetEdns0(4096, true)
+
+		clampTTL(m, tc.min, tc.max)
+		ttls := []uint32{m.Answer[0].Header().Ttl, m.Ns[0].Header().Ttl, m.Extra[0].Header().Ttl}
+		for j := range ttls {
+			if ttls[j] != tc.expected[j] {
+				t.Errorf("Test %d: expected TTLs %v, got %v", i, tc.expected, ttls)
+				break
+			}
+		}
+		if opt := m.IsEdns0(); opt == nil || !opt.Do() {
+			t.Errorf("Test %d: expected the OPT record to be left alone", i)
+		}
+	}
+}
@@ -2,6 +2,9 @@
 package proxy
 
 import (
+	"strings"
+	"time"
+
 	"github.com/miekg/coredns/request"
 
 	"github.com/miekg/dns"
@@ -16,17 +19,11 @@ type ReverseProxy struct {
 
 // ServeDNS implements the middleware.Handler interface.
 func (p ReverseProxy) ServeDNS(w dns.ResponseWriter, r *dns.Msg, extra []dns.RR) error {
-	var (
-		reply *dns.Msg
-		err   error
-	)
+	state := request.Request{W: w, Req: r}
+	req, changed := p.Options.ecs(state)
+	query := randomize(req, p.Options.RandomizeCase)
 
-	switch {
-	case request.Proto(w) == "tcp": // TODO(miek): keep this in request
-		reply, _, err = p.Client.TCP.Exchange(r, p.Host)
-	default:
-		reply, _, err = p.Client.UDP.Exchange(r, p.Host)
-	}
+	reply, err := p.exchange(req, query, state.Proto())
 
 	if reply != nil && reply.Truncated {
 		// Suppress proxy error for truncated responses
@@ -36,9 +33,107 @@ func (p ReverseProxy) ServeDNS(w dns.ResponseWriter, r *dns.Msg, extra []dns.RR)
 	if err != nil {
 		return err
 	}
+	// A FORMERR is most likely a broken upstream, as the query was fine for us; let
+	// the next one try.
+	if reply.Rcode == dns.RcodeFormatError {
+		return errFormErr
+	}
 
+	if changed {
+		ecsReply(r, reply)
+	}
+	clampTTL(reply, p.Options.MinTTL, p.Options.MaxTTL)
 	reply.Compress = true
 	reply.Id = r.Id
+	// A reply we got over TCP may not fit the buffer of a client that asked over UDP.
+	reply, _ = state.Scrub(reply)
 	w.WriteMsg(reply)
 	return nil
 }
+
+// clampTTL raises the TTLs of the records in reply that are below min to min, and lowers
+// the ones above max to max. A min or max of 0 is not applied. The OPT record has no TTL.
+func clampTTL(reply *dns.Msg, min, max uint32) {
+	if min == 0 && max == 0 {
+		return
+	}
+	for _, section := range [][]dns.RR{reply.Answer, reply.Ns, reply.Extra} {
+		for _, rr := range section {
+			h := rr.Header()
+			if h.Rrtype == dns.TypeOPT {
+				continue
+			}
+			if h.Ttl < min {
+				h.Ttl = min
+			}
+			if max > 0 && h.Ttl > max {
+				h.Ttl = max
+			}
+		}
+	}
+}
+
+// measure calls fn, that sends a query to host over proto, and updates the metrics of host.
+func measure(host, proto string, fn func() (*dns.Msg, error)) (*dns.Msg, error) {
+	start := time.Now()
+	reply, err := fn()
+	requestCount.WithLabelValues(host, proto).Inc()
+	requestDuration.WithLabelValues(host, proto).Observe(float64(time.Since(start) / time.Millisecond))
+	if err != nil && (reply == nil || !reply.Truncated) {
+		failureCount.WithLabelValues(host, proto).Inc()
+	}
+	return reply, err
+}
+
+// exchange sends req to host and returns the reply. A tls:// host is sent the query over
+// TLS, a grpc:// host over gRPC, an https:// host over DNS-over-HTTPS, other hosts over
+// the protocol of the client, proto, unless the options force TCP or prefer UDP. When a
+// reply over UDP is truncated, the query is sent again over TCP.
+func exchange(c Client, o Options, req *dns.Msg, host, proto string) (*dns.Msg, error) {
+	var (
+		reply *dns.Msg
+		err   error
+	)
+	switch {
+	case o.ForceTCP:
+		proto = "tcp"
+	case o.PreferUDP:
+		proto = "udp"
+	}
+	tcp := func() (*dns.Msg, error) {
+		return measure(host, "tcp", func() (*dns.Msg, error) {
+			if o.tcpPool != nil {
+				return exchangeTCP(o.tcpPool, req, host)
+			}
+			r, _, err := c.TCP.Exchange(req, host)
+			return r, err
+		})
+	}
+
+	switch {
+	case strings.HasPrefix(host, tlsPrefix):
+		reply, err = measure(host, "tls", func() (*dns.Msg, error) {
+			return exchangeTLS(o.tlsPool, o.TLSConfig, req, host[len(tlsPrefix):])
+		})
+	case strings.HasPrefix(host, grpcPrefix):
+		reply, err = measure(host, "grpc", func() (*dns.Msg, error) {
+			return exchangeGRPC(o.grpcClients, o.TLSConfig, req, host[len(grpcPrefix):])
+		})
+	case strings.HasPrefix(host, httpsPrefix):
+		reply, err = measure(host, "https", func() (*dns.Msg, error) {
+			return exchangeDoH(o.dohClient, o.DoHJSON, o.TLSConfig, req, host)
+		})
+	case proto == "tcp": // TODO(miek): keep this in request
+		reply, err = tcp()
+	default:
+		reply, err = measure(host, "udp", func() (*dns.Msg, error) {
+			r, _, err := c.UDP.Exchange(req, host)
+			return r, err
+		})
+		// The error may be about the truncation, the reply is still usable.
+		if reply != nil && reply.Truncated {
+			reply, err = tcp()
+		}
+	}
+	return reply, err
+}
@@ -19,9 +19,31 @@ func setup(c *caddy.Controller) error {
 	if err != nil {
 		return middleware.Error("proxy", err)
 	}
-	dnsserver.GetConfig(c).AddMiddleware(func(next middleware.Handler) middleware.Handler {
+	config := dnsserver.GetConfig(c)
+	config.Recursion = true
+	config.AddMiddleware(func(next middleware.Handler) middleware.Handler {
 		return Proxy{Next: next, Client: Clients(), Upstreams: upstreams}
 	})
 
+	c.OnStartup(func() error {
+		for _, u := range upstreams {
+			if s, ok := u.(*staticUpstream); ok {
+				if err := s.startDiscovery(); err != nil {
+					return middleware.Error("proxy", err)
+				}
+			}
+		}
+		return nil
+	})
+
+	c.OnShutdown(func() error {
+		for _, u := range upstreams {
+			if s, ok := u.(*staticUpstream); ok {
+				s.Stop()
+			}
+		}
+		return nil
+	})
+
 	return nil
 }
@@ -0,0 +1,91 @@
+package proxy
+
+import (
+	"errors"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Protection against spoofed replies. An attacker that wants to poison the cache has to
+// guess the source port and the ID of the query to the upstream: every UDP exchange uses
+// a new socket, so the kernel picks a random source port, and the ID of the client is
+// replaced by a random one. With RandomizeCase the letters of the query name are in random
+// case as well (DNS 0x20), a reply must echo them exactly.
+
+// randomize returns a copy of req with a random ID and, if mixCase is set, a query name
+// in random case. req itself is not changed.
+func randomize(req *dns.Msg, mixCase bool) *dns.Msg {
+	m := *req
+	m.Id = dns.Id()
+	if mixCase && len(req.Question) > 0 {
+		m.Question = make([]dns.Question, len(req.Question))
+		copy(m.Question, req.Question)
+		m.Question[0].Name = mixedCase(req.Question[0].Name)
+	}
+	return &m
+}
+
+// mixedCase returns name with each letter randomly in upper or lower case.
+func mixedCase(name string) string {
+	b := []byte(name)
+	caseRand.Lock()
+	defer caseRand.Unlock()
+	for i, c := range b {
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' {
+			if caseRand.Intn(2) == 0 {
+				b[i] = c | 0x20 // lower
+			} else {
+				b[i] = c &^ 0x20 // upper
+			}
+		}
+	}
+	return string(b)
+}
+
+// checkReply returns an error if the question of reply is not the question of query, the
+// randomized copy of req that was sent to the upstream. When the name was sent in random
+// case it must be the same, byte for byte. The names in reply are changed back to the name
+// in req.
+func checkReply(req, query, reply *dns.Msg, mixCase bool) error {
+	if len(reply.Question) != len(query.Question) {
+		return errQuestion
+	}
+	if len(query.Question) == 0 {
+		return nil
+	}
+	q, rq := query.Question[0], reply.Question[0]
+	if q.Qtype != rq.Qtype || q.Qclass != rq.Qclass {
+		return errQuestion
+	}
+	if !mixCase {
+		if !strings.EqualFold(q.Name, rq.Name) {
+			return errQuestion
+		}
+		return nil
+	}
+	if q.Name != rq.Name {
+		return errQuestion
+	}
+
+	name := req.Question[0].Name
+	reply.Question[0].Name = name
+	for _, rrs := range [][]dns.RR{reply.Answer, reply.Ns, reply.Extra} {
+		for _, rr := range rrs {
+			if rr.Header().Name == q.Name {
+				rr.Header().Name = name
+			}
+		}
+	}
+	return nil
+}
+
+var errQuestion = errors.New("question in reply does not match the query")
+
+var caseRand = struct {
+	sync.Mutex
+	*rand.Rand
+}{Rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
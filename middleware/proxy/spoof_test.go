@@ -0,0 +1,89 @@
+package proxy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/miekg/coredns/middleware/pkg/dnsrecorder"
+	"github.com/miekg/coredns/middleware/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestMixedCase(t *testing.T) {
+	for _, name := range []string{"example.org.", "www-1.Example.ORG.", "."} {
+		m := mixedCase(name)
+		if !strings.EqualFold(m, name) {
+			t.Errorf("Expected %s in mixed case, got %s", name, m)
+		}
+	}
+}
+
+func TestRandomize(t *testing.T) {
+	req := new(dns.Msg)
+	req.SetQuestion("example.org.", dns.TypeA)
+	req.Id = 1
+
+	q := randomize(req, true)
+	if req.Id != 1 || req.Question[0].Name != "example.org." {
+		t.Errorf("Expected the request to be unchanged, got %d %s", req.Id, req.Question[0].Name)
+	}
+	if !strings.EqualFold(q.Question[0].Name, "example.org.") {
+		t.Errorf("Expected the query for example.org., got %s", q.Question[0].Name)
+	}
+}
+
+func TestReverseProxySpoof(t *testing.T) {
+	// The upstream echoes the name, except for lower.example.org, which it returns in lower
+	// case, and spoof.example.org, for which it answers another name.
+	us, addr := udpServer(t, dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		if strings.HasPrefix(strings.ToLower(r.Question[0].Name), "lower.") {
+			m.Question[0].Name = strings.ToLower(m.Question[0].Name)
+		}
+		if strings.HasPrefix(strings.ToLower(r.Question[0].Name), "spoof.") {
+			m.Question[0].Name = "evil.example.org."
+		}
+		m.Answer = append(m.Answer, test.A(m.Question[0].Name+" 3600 IN A 127.0.0.53"))
+		w.WriteMsg(m)
+	}))
+	defer us.Shutdown()
+
+	tests := []struct {
+		qname         string
+		randomizeCase bool
+		shouldErr     bool
+	}{
+		{"www.example.org.", false, false},
+		{"www.example.org.", true, false},
+		{"spoof.example.org.", false, true},
+		{"spoof.example.org.", true, true},
+		{"lower.example.org.", false, false},
+		{"lower.example.org.", true, true},
+	}
+	for i, tc := range tests {
+		p := ReverseProxy{Host: addr, Client: Clients(), Options: Options{RandomizeCase: tc.randomizeCase}}
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, dns.TypeA)
+		rec := dnsrecorder.New(&test.ResponseWriter{})
+
+		err := p.ServeDNS(rec, m, nil)
+		if tc.shouldErr {
+			if err != errQuestion {
+				t.Errorf("Test %d: expected error %q, got %v", i, errQuestion, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if rec.Msg.Id != m.Id {
+			t.Errorf("Test %d: expected ID %d, got %d", i, m.Id, rec.Msg.Id)
+		}
+		if tc.randomizeCase && (rec.Msg.Question[0].Name != tc.qname || rec.Msg.Answer[0].Header().Name != tc.qname) {
+			t.Errorf("Test %d: expected the names in the reply to be %s, got %s", i, tc.qname, rec.Msg.Answer[0].Header().Name)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+// tlsPrefix marks an upstream host that is reached over TLS.
+const tlsPrefix = "tls://"
+
+// exchangeTLS sends m to addr over TLS and returns the reply. The connections are kept in
+// pool, so not every query needs a handshake.
+func exchangeTLS(pool *connPool, config *tls.Config, m *dns.Msg, addr string) (*dns.Msg, error) {
+	return exchangePooled(pool, m, addr, func() (net.Conn, error) {
+		return tls.DialWithDialer(&net.Dialer{Timeout: defaultTimeout}, "tcp", addr, config)
+	})
+}
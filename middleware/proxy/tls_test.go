@@ -0,0 +1,98 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/miekg/coredns/middleware/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestExchangeTLS(t *testing.T) {
+	// Borrow the certificate of httptest, it is valid for 127.0.0.1.
+	hs := httptest.NewTLSServer(http.NotFoundHandler())
+	defer hs.Close()
+	cert := hs.TLS.Certificates[0]
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatalf("Could not parse test certificate: %s", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(leaf)
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatalf("Could not listen: %s", err)
+	}
+	started := make(chan struct{})
+	s := &dns.Server{
+		Listener:          l,
+		NotifyStartedFunc: func() { close(started) },
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+			m := new(dns.Msg)
+			m.SetReply(r)
+			m.Answer = append(m.Answer, test.A("example.org. 3600 IN A 127.0.0.53"))
+			w.WriteMsg(m)
+		}),
+	}
+	go s.ActivateAndServe()
+	<-started
+	defer s.Shutdown()
+	addr := l.Addr().String()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+
+	// Without our roots the certificate can't be verified.
+	if _, err := exchangeTLS(nil, nil, m, addr); err == nil {
+		t.Fatal("Expected an error for an unknown certificate")
+	}
+
+	pool := newConnPool(defaultMaxIdleConns, defaultIdleTimeout, 0, 0)
+	config := &tls.Config{RootCAs: roots}
+	for i := 0; i < 2; i++ {
+		r, err := exchangeTLS(pool, config, m, addr)
+		if err != nil {
+			t.Fatalf("Query %d: expected no error, got %s", i, err)
+		}
+		if len(r.Answer) != 1 {
+			t.Errorf("Query %d: expected 1 answer, got %d", i, len(r.Answer))
+		}
+		// The connection is kept for the next query.
+		if n := len(pool.idle[addr]); n != 1 {
+			t.Errorf("Query %d: expected 1 idle connection, got %d", i, n)
+		}
+	}
+
+	// A connection closed by the upstream is replaced.
+	pool.idle[addr][0].co.Close()
+	if _, err := exchangeTLS(pool, config, m, addr); err != nil {
+		t.Fatalf("Expected no error after the connection was closed, got %s", err)
+	}
+}
+
+func TestDefaultHostPort(t *testing.T) {
+	tests := []struct {
+		host     string
+		expected string
+	}{
+		{"8.8.8.8", "8.8.8.8:53"},
+		{"8.8.8.8:5353", "8.8.8.8:5353"},
+		{"tls://9.9.9.9", "tls://9.9.9.9:853"},
+		{"tls://9.9.9.9:8853", "tls://9.9.9.9:8853"},
+		{"tls://2620:fe::fe", "tls://[2620:fe::fe]:853"},
+		{"grpc://10.0.0.1", "grpc://10.0.0.1:443"},
+		{"https://1.1.1.1", "https://1.1.1.1:443/dns-query"},
+		{"https://8.8.8.8:8443/resolve", "https://8.8.8.8:8443/resolve"},
+		{"https://[2606:4700:4700::1111]", "https://[2606:4700:4700::1111]:443/dns-query"},
+	}
+	for i, tc := range tests {
+		if got := defaultHostPort(tc.host); got != tc.expected {
+			t.Errorf("Test %d: expected %s, got %s", i, tc.expected, got)
+		}
+	}
+}
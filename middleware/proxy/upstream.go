@@ -1,17 +1,22 @@
 package proxy
 
 import (
+	"crypto/tls"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"log"
 	"net"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/miekg/coredns/middleware"
+	"github.com/miekg/coredns/middleware/pkg/singleflight"
+	mwtls "github.com/miekg/coredns/middleware/pkg/tls"
 
 	"github.com/mholt/caddy/caddyfile"
 	"github.com/miekg/dns"
@@ -30,18 +35,63 @@ type staticUpstream struct {
 	FailTimeout time.Duration
 	MaxFails    int32
 	HealthCheck struct {
+		Probe    string // http, dns or tcp
 		Path     string
 		Port     string
+		Query    dns.Question // the query of the dns probe
+		Fails    int          // number of failed checks in a row after which a host is down
 		Interval time.Duration
 	}
+	stop              chan struct{} // stops the health checks and the discovery
 	WithoutPathPrefix string
 	IgnoredSubDomains []string
 	options           Options
+	tlsVerify         mwtls.Verification // how the certificates of the endpoints are verified, applied to options.TLSConfig
+
+	// Refresh is the interval in which the SRV records of the sources are looked up again.
+	Refresh time.Duration
+
+	hostsMu    sync.RWMutex        // protects Hosts, when it is changed by the discovery
+	static     []string            // the hosts given as an address
+	sources    []string            // the sources the other hosts are discovered from
+	discovered map[string][]string // the hosts last discovered from each source
+	discoverMu sync.Mutex          // held while discovering
 }
 
 // Options ...
 type Options struct {
-	Ecs []*net.IPNet // EDNS0 CLIENT SUBNET address (v4/v6) to add in CIDR notaton.
+	Ecs       []*net.IPNet    // EDNS0 CLIENT SUBNET address (v4/v6) to add in CIDR notaton.
+	EcsPolicy int             // what to do with the EDNS0 CLIENT SUBNET option, see EcsForward and friends
+	Tries     int             // number of upstream hosts tried for a query, 0 tries until tryDuration is over
+	TLSConfig *tls.Config     // for the upstream hosts reached over TLS
+	Types     map[uint16]bool // only proxy queries of these types, empty is all types
+	DoHJSON   bool            // use the JSON API for the upstream hosts reached over DNS-over-HTTPS
+	ForceTCP  bool            // send the queries over TCP, also when the client used UDP
+	PreferUDP bool            // send the queries over UDP, also when the client used TCP
+
+	RandomizeCase bool // send the query name in random case (DNS 0x20), replies must echo it
+	NoCoalesce    bool // send identical queries that are in flight at the same time each to the upstream
+
+	MinTTL uint32 // TTLs in replies are raised to at least this, 0 leaves them
+	MaxTTL uint32 // TTLs in replies are lowered to at most this, 0 leaves them
+
+	MaxIdleConns int           // maximum number of idle TCP and TLS connections kept per upstream host
+	IdleTimeout  time.Duration // idle TCP and TLS connections are closed after this
+
+	MaxConnAge     time.Duration // TCP and TLS connections are not reused after they are open this long, 0 is no limit
+	MaxConnQueries int           // TCP and TLS connections are not reused after this many queries, 0 is no limit
+
+	MaxConcurrent int           // maximum number of queries sent to the upstream hosts at the same time, 0 is unlimited
+	OverloadDrop  bool          // drop the queries over MaxConcurrent, instead of SERVFAIL
+	QueueTimeout  time.Duration // how long a query over MaxConcurrent waits for a free slot
+
+	tcpPool     *connPool    // idle connections to the upstream hosts reached over TCP
+	tlsPool     *connPool    // idle connections to the upstream hosts reached over TLS
+	grpcClients *grpcClients // connections to the upstream hosts reached over gRPC
+	dohClient   *http.Client // for the upstream hosts reached over DNS-over-HTTPS
+
+	inflight *singleflight.Group // coalesces identical queries, see NoCoalesce
+	limiter  *limiter            // enforces MaxConcurrent, nil when it is unlimited
 }
 
 // NewStaticUpstreams parses the configuration input and sets up
@@ -56,7 +106,12 @@ func NewStaticUpstreams(c *caddyfile.Dispenser) ([]Upstream, error) {
 			Spray:       nil,
 			FailTimeout: 10 * time.Second,
 			MaxFails:    1,
+			Refresh:     defaultRefresh,
+			stop:        make(chan struct{}),
+			options:     Options{Tries: defaultTries, MaxIdleConns: defaultMaxIdleConns, IdleTimeout: defaultIdleTimeout},
 		}
+		upstream.HealthCheck.Query = dns.Question{Name: ".", Qtype: dns.TypeNS, Qclass: dns.ClassINET}
+		upstream.HealthCheck.Fails = 1
 
 		if !c.Args(&upstream.from) {
 			return upstreams, c.ArgErr()
@@ -66,13 +121,18 @@ func NewStaticUpstreams(c *caddyfile.Dispenser) ([]Upstream, error) {
 			return upstreams, c.ArgErr()
 		}
 		for _, host := range to {
-			h, _, err := net.SplitHostPort(host)
+			if isSource(host) {
+				upstream.sources = append(upstream.sources, host)
+				continue
+			}
+			h, _, err := net.SplitHostPort(trimPrefix(host))
 			if err != nil {
-				h = host
+				h = strings.Trim(trimPrefix(host), "[]")
 			}
 			if x := net.ParseIP(h); x == nil {
-				return upstreams, fmt.Errorf("not an IP address: `%s'", h)
+				return upstreams, fmt.Errorf("not an IP address or file: `%s'", h)
 			}
+			upstream.static = append(upstream.static, host)
 		}
 
 		for c.NextBlock() {
@@ -80,41 +140,118 @@ func NewStaticUpstreams(c *caddyfile.Dispenser) ([]Upstream, error) {
 				return upstreams, err
 			}
 		}
+		if upstream.tlsVerify.IsSet() {
+			if upstream.options.TLSConfig == nil {
+				upstream.options.TLSConfig = &tls.Config{}
+			}
+			if err := upstream.tlsVerify.Apply(upstream.options.TLSConfig); err != nil {
+				return upstreams, err
+			}
+		}
+		if o := upstream.options; o.MaxTTL > 0 && o.MinTTL > o.MaxTTL {
+			return upstreams, fmt.Errorf("min_ttl %d is larger than max_ttl %d", o.MinTTL, o.MaxTTL)
+		}
+
+		o := upstream.options
+		upstream.options.tcpPool = newConnPool(o.MaxIdleConns, o.IdleTimeout, o.MaxConnAge, o.MaxConnQueries)
+		upstream.options.tlsPool = newConnPool(o.MaxIdleConns, o.IdleTimeout, o.MaxConnAge, o.MaxConnQueries)
+		upstream.options.grpcClients = newGRPCClients()
+		upstream.options.inflight = new(singleflight.Group)
+		upstream.options.dohClient = newDoHClient(upstream.options.TLSConfig, upstream.options.MaxIdleConns)
+		if upstream.options.MaxConcurrent > 0 {
+			upstream.options.limiter = newLimiter(upstream.from, upstream.options.MaxConcurrent, upstream.options.QueueTimeout)
+		}
 
-		upstream.Hosts = make([]*UpstreamHost, len(to))
-		for i, host := range to {
-			uh := &UpstreamHost{
-				Name:        defaultHostPort(host),
-				Conns:       0,
-				Fails:       0,
-				FailTimeout: upstream.FailTimeout,
-				Unhealthy:   false,
-				CheckDown: func(upstream *staticUpstream) UpstreamHostDownFunc {
-					return func(uh *UpstreamHost) bool {
-						if uh.Unhealthy {
-							return true
-						}
-
-						fails := atomic.LoadInt32(&uh.Fails)
-						if fails >= upstream.MaxFails && upstream.MaxFails != 0 {
-							return true
-						}
-						return false
-					}
-				}(upstream),
-				WithoutPathPrefix: upstream.WithoutPathPrefix,
+		// A resolv.conf must be readable now, an SRV lookup that fails is tried again later.
+		for _, source := range upstream.sources {
+			if !strings.HasPrefix(source, srvPrefix) {
+				if _, err := resolvConf(source); err != nil {
+					return upstreams, err
+				}
 			}
-			upstream.Hosts[i] = uh
 		}
+		// The hosts are discovered once now, keeping them up to date is started with the
+		// server, see startDiscovery.
+		upstream.discovered = make(map[string][]string)
+		upstream.discoverHosts()
 
-		if upstream.HealthCheck.Path != "" {
-			go upstream.HealthCheckWorker(nil)
+		if upstream.HealthCheck.Probe != "" {
+			go upstream.HealthCheckWorker(upstream.stop)
 		}
 		upstreams = append(upstreams, upstream)
 	}
 	return upstreams, nil
 }
 
+// newHost returns a new host of u for the address name.
+func (u *staticUpstream) newHost(name string) *UpstreamHost {
+	return &UpstreamHost{
+		Name:        defaultHostPort(name),
+		Conns:       0,
+		Fails:       0,
+		FailTimeout: u.FailTimeout,
+		Unhealthy:   false,
+		CheckDown: func(upstream *staticUpstream) UpstreamHostDownFunc {
+			return func(uh *UpstreamHost) bool {
+				if uh.Unhealthy {
+					return true
+				}
+
+				fails := atomic.LoadInt32(&uh.Fails)
+				if fails >= upstream.MaxFails && upstream.MaxFails != 0 {
+					return true
+				}
+				return false
+			}
+		}(u),
+		WithoutPathPrefix: u.WithoutPathPrefix,
+	}
+}
+
+// setHosts replaces the hosts of u with names. Hosts that u already has are kept, with
+// their state.
+func (u *staticUpstream) setHosts(names []string) {
+	u.hostsMu.Lock()
+	defer u.hostsMu.Unlock()
+	old := make(map[string]*UpstreamHost, len(u.Hosts))
+	for _, h := range u.Hosts {
+		old[h.Name] = h
+	}
+	hosts := make(HostPool, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, n := range names {
+		n = defaultHostPort(n)
+		if seen[n] {
+			continue
+		}
+		seen[n] = true
+		if h, ok := old[n]; ok {
+			hosts = append(hosts, h)
+			continue
+		}
+		hosts = append(hosts, u.newHost(n))
+	}
+	u.Hosts = hosts
+}
+
+// pool returns the hosts of u.
+func (u *staticUpstream) pool() HostPool {
+	u.hostsMu.RLock()
+	defer u.hostsMu.RUnlock()
+	return u.Hosts
+}
+
+// Stop stops the health checks and the discovery of the upstream and closes its idle and
+// gRPC connections.
+func (u *staticUpstream) Stop() {
+	close(u.stop)
+	u.options.tcpPool.close()
+	u.options.tlsPool.close()
+	if u.options.grpcClients != nil {
+		u.options.grpcClients.close()
+	}
+}
+
 // RegisterPolicy adds a custom policy to the proxy.
 func RegisterPolicy(name string, policy func() Policy) {
 	supportedPolicies[name] = policy
@@ -161,10 +298,16 @@ func parseBlock(c *caddyfile.Dispenser, u *staticUpstream) error {
 		if !c.NextArg() {
 			return c.ArgErr()
 		}
-		var err error
-		u.HealthCheck.Path, u.HealthCheck.Port, err = net.SplitHostPort(c.Val())
-		if err != nil {
-			return err
+		switch c.Val() {
+		case "dns", "tcp":
+			u.HealthCheck.Probe = c.Val()
+		default:
+			var err error
+			u.HealthCheck.Path, u.HealthCheck.Port, err = net.SplitHostPort(c.Val())
+			if err != nil {
+				return err
+			}
+			u.HealthCheck.Probe = "http"
 		}
 		u.HealthCheck.Interval = 30 * time.Second
 		if c.NextArg() {
@@ -174,6 +317,238 @@ func parseBlock(c *caddyfile.Dispenser, u *staticUpstream) error {
 			}
 			u.HealthCheck.Interval = dur
 		}
+	case "tries":
+		if !c.NextArg() {
+			return c.ArgErr()
+		}
+		n, err := strconv.Atoi(c.Val())
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			return c.Errf("tries can't be negative: %d", n)
+		}
+		u.options.Tries = n
+	case "min_ttl", "max_ttl":
+		what := c.Val()
+		if !c.NextArg() {
+			return c.ArgErr()
+		}
+		n, err := strconv.ParseUint(c.Val(), 10, 32)
+		if err != nil {
+			return err
+		}
+		if what == "min_ttl" {
+			u.options.MinTTL = uint32(n)
+		} else {
+			u.options.MaxTTL = uint32(n)
+		}
+	case "qtype":
+		args := c.RemainingArgs()
+		if len(args) == 0 {
+			return c.ArgErr()
+		}
+		u.options.Types = make(map[uint16]bool)
+		for _, a := range args {
+			t, ok := dns.StringToType[strings.ToUpper(a)]
+			if !ok {
+				return c.Errf("unknown query type '%s'", a)
+			}
+			u.options.Types[t] = true
+		}
+	case "tls": // [cert key] [cacertfile]
+		tlsConfig, err := mwtls.NewTLSConfigFromArgs(c.RemainingArgs()...)
+		if err != nil {
+			return err
+		}
+		if u.options.TLSConfig != nil {
+			tlsConfig.ServerName = u.options.TLSConfig.ServerName
+		}
+		u.options.TLSConfig = tlsConfig
+	case "tls_servername":
+		if !c.NextArg() {
+			return c.ArgErr()
+		}
+		if u.options.TLSConfig == nil {
+			u.options.TLSConfig = &tls.Config{}
+		}
+		u.options.TLSConfig.ServerName = c.Val()
+	case "tls_ca":
+		cas := c.RemainingArgs()
+		if len(cas) == 0 {
+			return c.ArgErr()
+		}
+		u.tlsVerify.CAs = append(u.tlsVerify.CAs, cas...)
+	case "tls_pin":
+		pins := c.RemainingArgs()
+		if len(pins) == 0 {
+			return c.ArgErr()
+		}
+		u.tlsVerify.Pins = append(u.tlsVerify.Pins, pins...)
+	case "tls_insecure":
+		if c.NextArg() {
+			return c.ArgErr()
+		}
+		u.tlsVerify.Insecure = true
+	case "force_tcp":
+		if c.NextArg() {
+			return c.ArgErr()
+		}
+		if u.options.PreferUDP {
+			return c.Err("force_tcp and prefer_udp can't be used together")
+		}
+		u.options.ForceTCP = true
+	case "prefer_udp":
+		if c.NextArg() {
+			return c.ArgErr()
+		}
+		if u.options.ForceTCP {
+			return c.Err("force_tcp and prefer_udp can't be used together")
+		}
+		u.options.PreferUDP = true
+	case "randomize_case":
+		if c.NextArg() {
+			return c.ArgErr()
+		}
+		u.options.RandomizeCase = true
+	case "no_coalesce":
+		if c.NextArg() {
+			return c.ArgErr()
+		}
+		u.options.NoCoalesce = true
+	case "max_idle_conns":
+		if !c.NextArg() {
+			return c.ArgErr()
+		}
+		n, err := strconv.Atoi(c.Val())
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			return c.Errf("max_idle_conns can't be negative: %d", n)
+		}
+		u.options.MaxIdleConns = n
+	case "idle_timeout":
+		if !c.NextArg() {
+			return c.ArgErr()
+		}
+		dur, err := time.ParseDuration(c.Val())
+		if err != nil {
+			return err
+		}
+		if dur <= 0 {
+			return c.Errf("idle_timeout must be larger than zero: %s", dur)
+		}
+		u.options.IdleTimeout = dur
+	case "max_conn_age":
+		if !c.NextArg() {
+			return c.ArgErr()
+		}
+		dur, err := time.ParseDuration(c.Val())
+		if err != nil {
+			return err
+		}
+		if dur <= 0 {
+			return c.Errf("max_conn_age must be larger than zero: %s", dur)
+		}
+		u.options.MaxConnAge = dur
+	case "max_conn_queries":
+		if !c.NextArg() {
+			return c.ArgErr()
+		}
+		n, err := strconv.Atoi(c.Val())
+		if err != nil {
+			return err
+		}
+		if n <= 0 {
+			return c.Errf("max_conn_queries must be larger than zero: %d", n)
+		}
+		u.options.MaxConnQueries = n
+	case "max_concurrent":
+		args := c.RemainingArgs()
+		if len(args) == 0 || len(args) > 2 {
+			return c.ArgErr()
+		}
+		n, err := strconv.Atoi(args[0])
+		if err != nil {
+			return err
+		}
+		if n <= 0 {
+			return c.Errf("max_concurrent must be larger than zero: %d", n)
+		}
+		u.options.MaxConcurrent = n
+		if len(args) == 2 {
+			switch args[1] {
+			case "servfail":
+				u.options.OverloadDrop = false
+			case "drop":
+				u.options.OverloadDrop = true
+			default:
+				return c.Errf("unknown overload action '%s'", args[1])
+			}
+		}
+	case "queue_timeout":
+		if !c.NextArg() {
+			return c.ArgErr()
+		}
+		dur, err := time.ParseDuration(c.Val())
+		if err != nil {
+			return err
+		}
+		if dur < 0 {
+			return c.Errf("queue_timeout can't be negative: %s", dur)
+		}
+		u.options.QueueTimeout = dur
+	case "refresh":
+		if !c.NextArg() {
+			return c.ArgErr()
+		}
+		dur, err := time.ParseDuration(c.Val())
+		if err != nil {
+			return err
+		}
+		if dur <= 0 {
+			return c.Errf("refresh must be larger than zero: %s", dur)
+		}
+		u.Refresh = dur
+	case "doh":
+		if !c.NextArg() {
+			return c.ArgErr()
+		}
+		switch c.Val() {
+		case "wire":
+			u.options.DoHJSON = false
+		case "json":
+			u.options.DoHJSON = true
+		default:
+			return c.Errf("unknown DNS-over-HTTPS format '%s'", c.Val())
+		}
+	case "health_query":
+		args := c.RemainingArgs()
+		if len(args) == 0 || len(args) > 2 {
+			return c.ArgErr()
+		}
+		qtype := dns.TypeNS
+		if len(args) == 2 {
+			t, ok := dns.StringToType[strings.ToUpper(args[1])]
+			if !ok {
+				return c.Errf("unknown query type '%s'", args[1])
+			}
+			qtype = t
+		}
+		u.HealthCheck.Query = dns.Question{Name: dns.Fqdn(args[0]), Qtype: qtype, Qclass: dns.ClassINET}
+	case "health_fails":
+		if !c.NextArg() {
+			return c.ArgErr()
+		}
+		n, err := strconv.Atoi(c.Val())
+		if err != nil {
+			return err
+		}
+		if n <= 0 {
+			return c.Errf("health_fails must be larger than zero: %d", n)
+		}
+		u.HealthCheck.Fails = n
 	case "without":
 		if !c.NextArg() {
 			return c.ArgErr()
@@ -190,6 +565,38 @@ func parseBlock(c *caddyfile.Dispenser, u *staticUpstream) error {
 		u.IgnoredSubDomains = ignoredDomains
 	case "spray":
 		u.Spray = &Spray{}
+	case "ecs":
+		if !c.NextArg() {
+			return c.ArgErr()
+		}
+		switch c.Val() {
+		case "forward":
+			u.options.EcsPolicy = EcsForward
+		case "strip":
+			u.options.EcsPolicy = EcsStrip
+		case "client":
+			u.options.EcsPolicy = EcsClient
+		case "override":
+			u.options.EcsPolicy = EcsOverride
+			nets := c.RemainingArgs()
+			if len(nets) == 0 || len(nets) > 2 {
+				return c.ArgErr()
+			}
+			u.options.Ecs = nil
+			for _, s := range nets {
+				_, n, err := net.ParseCIDR(s)
+				if err != nil {
+					return err
+				}
+				u.options.Ecs = append(u.options.Ecs, n)
+			}
+			return nil
+		default:
+			return c.Errf("unknown ecs policy '%s'", c.Val())
+		}
+		if c.NextArg() {
+			return c.ArgErr()
+		}
 
 	default:
 		return c.Errf("unknown property '%s'", c.Val())
@@ -197,21 +604,72 @@ func parseBlock(c *caddyfile.Dispenser, u *staticUpstream) error {
 	return nil
 }
 
+// healthCheck checks all hosts. A host is marked unhealthy after HealthCheck.Fails
+// failed checks in a row, and healthy again after the first check that succeeds.
 func (u *staticUpstream) healthCheck() {
-	for _, host := range u.Hosts {
-		port := ""
-		if u.HealthCheck.Port != "" {
-			port = ":" + u.HealthCheck.Port
-		}
-		hostURL := host.Name + port + u.HealthCheck.Path
-		if r, err := http.Get(hostURL); err == nil {
-			io.Copy(ioutil.Discard, r.Body)
-			r.Body.Close()
-			host.Unhealthy = r.StatusCode < 200 || r.StatusCode >= 400
-		} else {
+	fails := u.HealthCheck.Fails
+	if fails == 0 {
+		fails = 1
+	}
+	for _, host := range u.pool() {
+		err := u.probe(host)
+		if err == nil {
+			if host.Unhealthy {
+				log.Printf("[INFO] Upstream %s is healthy again", host.Name)
+			}
+			host.checkFails = 0
+			host.Unhealthy = false
+			healthy.WithLabelValues(host.Name).Set(1)
+			continue
+		}
+		host.checkFails++
+		if host.checkFails >= fails && !host.Unhealthy {
+			log.Printf("[WARNING] Upstream %s failed %d health checks, marking it down: %s", host.Name, host.checkFails, err)
 			host.Unhealthy = true
 		}
+		if host.Unhealthy {
+			healthy.WithLabelValues(host.Name).Set(0)
+		}
+	}
+}
+
+// probe checks the health of host with the probe of the upstream.
+func (u *staticUpstream) probe(host *UpstreamHost) error {
+	switch u.HealthCheck.Probe {
+	case "dns":
+		m := new(dns.Msg)
+		m.SetQuestion(u.HealthCheck.Query.Name, u.HealthCheck.Query.Qtype)
+		c := Client{UDP: newClient("udp", probeTimeout), TCP: newClient("tcp", probeTimeout)}
+		r, err := exchange(c, u.options, m, host.Name, "udp")
+		if err != nil {
+			return err
+		}
+		if r.Rcode == dns.RcodeServerFailure {
+			return fmt.Errorf("got %s for %s", dns.RcodeToString[r.Rcode], u.HealthCheck.Query.Name)
+		}
+		return nil
+	case "tcp":
+		conn, err := net.DialTimeout("tcp", trimPrefix(host.Name), probeTimeout)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	port := ""
+	if u.HealthCheck.Port != "" {
+		port = ":" + u.HealthCheck.Port
+	}
+	r, err := http.Get(host.Name + port + u.HealthCheck.Path)
+	if err != nil {
+		return err
+	}
+	io.Copy(ioutil.Discard, r.Body)
+	r.Body.Close()
+	if r.StatusCode < 200 || r.StatusCode >= 400 {
+		return fmt.Errorf("got HTTP status %d", r.StatusCode)
 	}
+	return nil
 }
 
 func (u *staticUpstream) HealthCheckWorker(stop chan struct{}) {
@@ -222,15 +680,14 @@ func (u *staticUpstream) HealthCheckWorker(stop chan struct{}) {
 		case <-ticker.C:
 			u.healthCheck()
 		case <-stop:
-			// TODO: the library should provide a stop channel and global
-			// waitgroup to allow goroutines started by plugins a chance
-			// to clean themselves up.
+			ticker.Stop()
+			return
 		}
 	}
 }
 
 func (u *staticUpstream) Select() *UpstreamHost {
-	pool := u.Hosts
+	pool := u.pool()
 	if len(pool) == 1 {
 		if pool[0].Down() && u.Spray == nil {
 			return nil
@@ -271,21 +728,59 @@ func (u *staticUpstream) Select() *UpstreamHost {
 }
 
 func (u *staticUpstream) IsAllowedPath(name string) bool {
-	for _, ignoredSubDomain := range u.IgnoredSubDomains {
-		if dns.Name(name) == dns.Name(u.From()) {
-			return true
+	from := middleware.Host(u.From()).Normalize()
+	for _, ignored := range u.IgnoredSubDomains {
+		// An ignored name below from is absolute, others are relative to from.
+		if !dns.IsSubDomain(from, ignored) {
+			ignored += from
 		}
-		if middleware.Name(name).Matches(ignoredSubDomain + u.From()) {
+		if middleware.Name(ignored).Matches(name) {
 			return false
 		}
 	}
 	return true
 }
 
+// defaultTries is the default number of upstream hosts tried for a query.
+const defaultTries = 3
+
+// probeTimeout is how long a dns or tcp health check waits for the upstream.
+const probeTimeout = 2 * time.Second
+
 func defaultHostPort(s string) string {
+	if strings.HasPrefix(s, httpsPrefix) {
+		// https://address[:port][/path], the path defaults to /dns-query.
+		addr, path := dohAddr(s), dohPath
+		if i := strings.Index(s[len(httpsPrefix):], "/"); i >= 0 {
+			path = s[len(httpsPrefix)+i:]
+		}
+		if _, _, e := net.SplitHostPort(addr); e != nil {
+			addr = net.JoinHostPort(strings.Trim(addr, "[]"), "443")
+		}
+		return httpsPrefix + addr + path
+	}
+	for prefix, port := range map[string]string{tlsPrefix: "853", grpcPrefix: "443"} {
+		if !strings.HasPrefix(s, prefix) {
+			continue
+		}
+		addr := s[len(prefix):]
+		if _, _, e := net.SplitHostPort(addr); e == nil {
+			return s
+		}
+		return prefix + net.JoinHostPort(addr, port)
+	}
 	_, _, e := net.SplitHostPort(s)
 	if e == nil {
 		return s
 	}
 	return net.JoinHostPort(s, "53")
 }
+
+// trimPrefix returns the address of the upstream host s, without the tls://, grpc:// or
+// https:// prefix (and the path of the latter).
+func trimPrefix(s string) string {
+	if strings.HasPrefix(s, httpsPrefix) {
+		return dohAddr(s)
+	}
+	return strings.TrimPrefix(strings.TrimPrefix(s, tlsPrefix), grpcPrefix)
+}
@@ -4,7 +4,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/miekg/coredns/middleware/test"
+
 	"github.com/mholt/caddy"
+	"github.com/miekg/dns"
 )
 
 func TestHealthCheck(t *testing.T) {
@@ -25,6 +28,62 @@ func TestHealthCheck(t *testing.T) {
 	}
 }
 
+func TestHealthCheckProbe(t *testing.T) {
+	dns.HandleFunc("probe.example.org.", func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		w.WriteMsg(m)
+	})
+	defer dns.HandleRemove("probe.example.org.")
+
+	udp, udpAddr, err := test.UDPServer(t, "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not start UDP server: %s", err)
+	}
+	defer udp.Shutdown()
+	tcp, tcpAddr, err := test.TCPServer(t, "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not start TCP server: %s", err)
+	}
+	defer tcp.Shutdown()
+
+	tests := []struct {
+		probe string
+		addr  string
+	}{
+		{"dns", udpAddr},
+		{"tcp", tcpAddr},
+	}
+	for i, tc := range tests {
+		upstream := &staticUpstream{
+			Hosts: HostPool{{Name: tc.addr}, {Name: "127.0.0.1:1"}},
+		}
+		upstream.HealthCheck.Probe = tc.probe
+		upstream.HealthCheck.Query = dns.Question{Name: "probe.example.org.", Qtype: dns.TypeA, Qclass: dns.ClassINET}
+		upstream.HealthCheck.Fails = 2
+
+		// A single failed check is not enough to mark a host down.
+		upstream.healthCheck()
+		if upstream.Hosts[0].Down() || upstream.Hosts[1].Down() {
+			t.Errorf("Test %d: expected both hosts to be up after one check", i)
+		}
+		upstream.healthCheck()
+		if upstream.Hosts[0].Down() {
+			t.Errorf("Test %d: expected %s to be up", i, tc.addr)
+		}
+		if !upstream.Hosts[1].Down() {
+			t.Errorf("Test %d: expected 127.0.0.1:1 to be down after two failed checks", i)
+		}
+
+		// A host recovers after one good check.
+		upstream.Hosts[1].Name = tc.addr
+		upstream.healthCheck()
+		if upstream.Hosts[1].Down() {
+			t.Errorf("Test %d: expected host to be up again", i)
+		}
+	}
+}
+
 func TestSelect(t *testing.T) {
 	upstream := &staticUpstream{
 		from:        "",
@@ -68,6 +127,28 @@ func TestAllowedPaths(t *testing.T) {
 		{"download.miek.nl.", false},
 		{"static.miek.nl.", false},
 		{"blaat.miek.nl.", true},
+		{"www.download.miek.nl.", false},
+	}
+
+	for i, test := range tests {
+		isAllowed := upstream.IsAllowedPath(test.name)
+		if test.expected != isAllowed {
+			t.Errorf("Test %d: expected %v found %v for %s", i+1, test.expected, isAllowed, test.name)
+		}
+	}
+
+	// Names below from are absolute.
+	upstream = &staticUpstream{
+		from:              ".",
+		IgnoredSubDomains: []string{"miek.nl.", "example.org."},
+	}
+	tests = []struct {
+		name     string
+		expected bool
+	}{
+		{"example.com.", true},
+		{"miek.nl.", false},
+		{"www.example.org.", false},
 	}
 
 	for i, test := range tests {
@@ -96,6 +177,13 @@ proxy . 8.8.8.8:53 {
 		},
 		{
 			`
+proxy . 8.8.8.8:53 8.8.4.4:53 {
+    policy first
+}`,
+			false,
+		},
+		{
+			`
 proxy . 8.8.8.8:53 {
     fail_timeout 5s
 }`,
@@ -110,6 +198,194 @@ proxy . 8.8.8.8:53 {
 		},
 		{
 			`
+proxy . 8.8.8.8:53 8.8.4.4:53 {
+    tries 2
+}`,
+			false,
+		},
+		{
+			`
+proxy . 8.8.8.8:53 {
+    tries -1
+}`,
+			true,
+		},
+		{
+			`
+proxy . tls://9.9.9.9 tls://149.112.112.112:853 {
+    tls_servername dns.quad9.net
+}`,
+			false,
+		},
+		{
+			`
+proxy . tls://dns.quad9.net`,
+			true,
+		},
+		{
+			`
+proxy . grpc://10.0.0.1 {
+    tls /etc/coredns/cert.pem /etc/coredns/key.pem
+}`,
+			true, // the files don't exist
+		},
+		{
+			`
+proxy . grpc://10.0.0.1:8053`,
+			false,
+		},
+		{
+			`
+proxy . https://1.1.1.1 https://[2606:4700:4700::1111]/dns-query {
+    tls_servername cloudflare-dns.com
+}`,
+			false,
+		},
+		{
+			`
+proxy . https://10.0.0.53 {
+    tls_servername dns.example.org
+    tls_pin sha256/AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=
+}`,
+			false,
+		},
+		{
+			`
+proxy . tls://10.0.0.53 {
+    tls_insecure
+}`,
+			false,
+		},
+		{
+			`
+proxy . tls://10.0.0.53 {
+    tls_insecure
+    tls_pin AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=
+}`,
+			true,
+		},
+		{
+			`
+proxy . tls://10.0.0.53 {
+    tls_pin not-a-pin
+}`,
+			true,
+		},
+		{
+			`
+proxy . tls://10.0.0.53 {
+    tls_ca /etc/coredns/does-not-exist.pem
+}`,
+			true,
+		},
+		{
+			`
+proxy . 8.8.8.8:53 {
+    force_tcp
+}`,
+			false,
+		},
+		{
+			`
+proxy . 8.8.8.8:53 {
+    prefer_udp
+}`,
+			false,
+		},
+		{
+			`
+proxy . 8.8.8.8:53 {
+    force_tcp
+    prefer_udp
+}`,
+			true,
+		},
+		{
+			`
+proxy . 8.8.8.8:53 {
+    force_tcp yes
+}`,
+			true,
+		},
+		{
+			`
+proxy . 8.8.8.8:53 {
+    max_idle_conns 16
+    idle_timeout 30s
+}`,
+			false,
+		},
+		{
+			`
+proxy . 8.8.8.8:53 {
+    max_idle_conns -1
+}`,
+			true,
+		},
+		{
+			`
+proxy . 8.8.8.8:53 {
+    idle_timeout 0s
+}`,
+			true,
+		},
+		{
+			`
+proxy . 8.8.8.8:53 {
+    max_conn_age 5m
+    max_conn_queries 1000
+}`,
+			false,
+		},
+		{
+			`
+proxy . 8.8.8.8:53 {
+    max_conn_age 0s
+}`,
+			true,
+		},
+		{
+			`
+proxy . 8.8.8.8:53 {
+    max_conn_queries 0
+}`,
+			true,
+		},
+		{
+			`
+proxy . https://8.8.8.8/resolve {
+    doh json
+}`,
+			false,
+		},
+		{
+			`
+proxy . https://8.8.8.8 {
+    doh xml
+}`,
+			true,
+		},
+		{
+			`
+proxy . https://dns.google.com/resolve`,
+			true,
+		},
+		{
+			`
+proxy . 10.0.0.53:53 {
+    qtype PTR SRV
+}`,
+			false,
+		},
+		{
+			`
+proxy . 10.0.0.53:53 {
+    qtype BLAAT
+}`,
+			true,
+		},
+		{
+			`
 proxy . 8.8.8.8:53 {
     health_check /health:8080
 }`,
@@ -117,6 +393,36 @@ proxy . 8.8.8.8:53 {
 		},
 		{
 			`
+proxy . 8.8.8.8:53 {
+    health_check dns 5s
+    health_query example.org SOA
+    health_fails 3
+}`,
+			false,
+		},
+		{
+			`
+proxy . 8.8.8.8:53 {
+    health_check tcp
+}`,
+			false,
+		},
+		{
+			`
+proxy . 8.8.8.8:53 {
+    health_query example.org BLAAT
+}`,
+			true,
+		},
+		{
+			`
+proxy . 8.8.8.8:53 {
+    health_fails 0
+}`,
+			true,
+		},
+		{
+			`
 proxy . 8.8.8.8:53 {
     without without
 }`,
@@ -138,6 +444,86 @@ proxy . 8.8.8.8:53 {
 		},
 		{
 			`
+proxy . 8.8.8.8:53 {
+    ecs client
+}`,
+			false,
+		},
+		{
+			`
+proxy . 8.8.8.8:53 {
+    ecs override 192.0.2.0/24 2001:db8::/56
+}`,
+			false,
+		},
+		{
+			`
+proxy . 8.8.8.8:53 {
+    ecs override
+}`,
+			true,
+		},
+		{
+			`
+proxy . 8.8.8.8:53 {
+    ecs strip now
+}`,
+			true,
+		},
+		{
+			`
+proxy . 8.8.8.8:53 {
+    ecs blaat
+}`,
+			true,
+		},
+		{
+			`
+proxy . 8.8.8.8:53 {
+    max_concurrent 100 drop
+    queue_timeout 50ms
+}`,
+			false,
+		},
+		{
+			`
+proxy . 8.8.8.8:53 {
+    min_ttl 30
+    max_ttl 3600
+}`,
+			false,
+		},
+		{
+			`
+proxy . 8.8.8.8:53 {
+    min_ttl 3600
+    max_ttl 30
+}`,
+			true,
+		},
+		{
+			`
+proxy . 8.8.8.8:53 {
+    max_ttl -1
+}`,
+			true,
+		},
+		{
+			`
+proxy . 8.8.8.8:53 {
+    max_concurrent 0
+}`,
+			true,
+		},
+		{
+			`
+proxy . 8.8.8.8:53 {
+    max_concurrent 100 reject
+}`,
+			true,
+		},
+		{
+			`
 proxy . 8.8.8.8:53 {
     error_option
 }`,
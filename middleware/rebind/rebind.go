@@ -0,0 +1,100 @@
+// Package rebind implements a middleware that removes private addresses from
+// answers, to protect clients against DNS rebinding attacks.
+package rebind
+
+import (
+	"net"
+
+	"github.com/miekg/coredns/middleware"
+	"github.com/miekg/coredns/request"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/context"
+)
+
+// Rebind removes A and AAAA records that point into Networks from the answers
+// for Zones. Names in Except may resolve to such addresses.
+type Rebind struct {
+	Next     middleware.Handler
+	Zones    []string
+	Except   []string
+	Networks []*net.IPNet
+}
+
+// ServeDNS implements the middleware.Handler interface.
+func (rb Rebind) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: r}
+
+	zone := middleware.Zones(rb.Zones).Matches(state.Name())
+	if zone == "" || middleware.Zones(rb.Except).Matches(state.Name()) != "" {
+		return rb.Next.ServeDNS(ctx, w, r)
+	}
+
+	rw := &ResponseWriter{ResponseWriter: w, rebind: rb, zone: zone}
+	return rb.Next.ServeDNS(ctx, rw, r)
+}
+
+// ResponseWriter is a response writer that filters the answer section of the
+// reply before it is written.
+type ResponseWriter struct {
+	dns.ResponseWriter
+	rebind Rebind
+	zone   string
+}
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (r *ResponseWriter) WriteMsg(res *dns.Msg) error {
+	res.Answer = r.rebind.filter(res.Answer, r.zone)
+	return r.ResponseWriter.WriteMsg(res)
+}
+
+// Write implements the dns.ResponseWriter interface.
+func (r *ResponseWriter) Write(buf []byte) (int, error) {
+	m := new(dns.Msg)
+	if err := m.Unpack(buf); err != nil {
+		return 0, err
+	}
+	return len(buf), r.WriteMsg(m)
+}
+
+// filter returns rrs without the address records that point into rb.Networks.
+func (rb Rebind) filter(rrs []dns.RR, zone string) []dns.RR {
+	j := 0
+	for _, rr := range rrs {
+		var ip net.IP
+		switch x := rr.(type) {
+		case *dns.A:
+			ip = x.A
+		case *dns.AAAA:
+			ip = x.AAAA
+		}
+		if ip != nil && rb.private(ip) {
+			strippedCount.WithLabelValues(zone).Inc()
+			continue
+		}
+		rrs[j] = rr
+		j++
+	}
+	return rrs[:j]
+}
+
+func (rb Rebind) private(ip net.IP) bool {
+	for _, n := range rb.Networks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+var strippedCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: middleware.Namespace,
+	Subsystem: "rebind",
+	Name:      "stripped_records_total",
+	Help:      "Counter of address records removed from answers.",
+}, []string{"zone"})
+
+func init() {
+	prometheus.MustRegister(strippedCount)
+}
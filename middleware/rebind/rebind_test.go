@@ -0,0 +1,61 @@
+package rebind
+
+import (
+	"testing"
+
+	"github.com/miekg/coredns/middleware"
+	"github.com/miekg/coredns/middleware/pkg/dnsrecorder"
+	"github.com/miekg/coredns/middleware/test"
+
+	"github.com/mholt/caddy"
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+func TestRebind(t *testing.T) {
+	rb, err := rebindParse(caddy.NewTestController("dns", `rebind example.org {
+		except intranet.example.org
+	}`))
+	if err != nil {
+		t.Fatalf("Failed to parse: %s", err)
+	}
+	rb.Next = middleware.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = []dns.RR{
+			test.CNAME(r.Question[0].Name + "	300	IN	CNAME	target.example.net."),
+			test.A("target.example.net.	300	IN	A	192.168.1.1"),
+			test.A("target.example.net.	300	IN	A	93.184.216.34"),
+			test.AAAA("target.example.net.	300	IN	AAAA	::ffff:10.0.0.1"),
+			test.AAAA("target.example.net.	300	IN	AAAA	2001:db8::1"),
+		}
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+
+	tests := []struct {
+		qname          string
+		expectedAnswer int
+	}{
+		{"example.org.", 3},
+		{"www.example.org.", 3},
+		{"intranet.example.org.", 5},
+		{"example.net.", 5},
+	}
+
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, dns.TypeA)
+		rec := dnsrecorder.New(&test.ResponseWriter{})
+		rb.ServeDNS(context.TODO(), rec, m)
+
+		if len(rec.Msg.Answer) != tc.expectedAnswer {
+			t.Errorf("Test %d: expected %d answers, got %d: %v", i, tc.expectedAnswer, len(rec.Msg.Answer), rec.Msg.Answer)
+		}
+		for _, rr := range rec.Msg.Answer {
+			if a, ok := rr.(*dns.A); ok && tc.expectedAnswer == 3 && a.A.String() == "192.168.1.1" {
+				t.Errorf("Test %d: expected private address to be removed", i)
+			}
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package rebind
+
+import (
+	"net"
+
+	"github.com/miekg/coredns/core/dnsserver"
+	"github.com/miekg/coredns/middleware"
+
+	"github.com/mholt/caddy"
+)
+
+func init() {
+	caddy.RegisterPlugin("rebind", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	rb, err := rebindParse(c)
+	if err != nil {
+		return middleware.Error("rebind", err)
+	}
+
+	dnsserver.GetConfig(c).AddMiddleware(func(next middleware.Handler) middleware.Handler {
+		rb.Next = next
+		return rb
+	})
+
+	return nil
+}
+
+func rebindParse(c *caddy.Controller) (Rebind, error) {
+	rb := Rebind{}
+
+	for c.Next() {
+		origins := make([]string, len(c.ServerBlockKeys))
+		copy(origins, c.ServerBlockKeys)
+		if args := c.RemainingArgs(); len(args) > 0 {
+			origins = args
+		}
+		rb.Zones = middleware.Zones(origins).NormalizeExact()
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "except":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return rb, c.ArgErr()
+				}
+				rb.Except = append(rb.Except, middleware.Zones(args).NormalizeExact()...)
+			case "networks":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return rb, c.ArgErr()
+				}
+				for _, a := range args {
+					_, n, err := net.ParseCIDR(a)
+					if err != nil {
+						return rb, c.Errf("not a network: %s", a)
+					}
+					rb.Networks = append(rb.Networks, n)
+				}
+			default:
+				return rb, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+	}
+
+	if rb.Networks == nil {
+		for _, s := range defaultNetworks {
+			_, n, _ := net.ParseCIDR(s)
+			rb.Networks = append(rb.Networks, n)
+		}
+	}
+	return rb, nil
+}
+
+// defaultNetworks are the private, link-local, loopback and unspecified ranges.
+var defaultNetworks = []string{
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"::/128",
+	"::1/128",
+	"fc00::/7",
+	"fe80::/10",
+}
@@ -0,0 +1,70 @@
+package rebind
+
+import (
+	"testing"
+
+	"github.com/mholt/caddy"
+)
+
+func TestSetupRebind(t *testing.T) {
+	tests := []struct {
+		input            string
+		shouldErr        bool
+		expectedZones    []string
+		expectedExcept   []string
+		expectedNetworks int
+	}{
+		{`rebind`, false, []string{}, nil, len(defaultNetworks)},
+		{`rebind example.org {
+			except intranet.example.org corp.example.org
+		}`, false, []string{"example.org."}, []string{"intranet.example.org.", "corp.example.org."}, len(defaultNetworks)},
+		{`rebind . {
+			networks 10.0.0.0/8 fd00::/8
+		}`, false, []string{"."}, nil, 2},
+		// fails
+		{`rebind {
+			except
+		}`, true, nil, nil, 0},
+		{`rebind {
+			networks 10.0.0.1
+		}`, true, nil, nil, 0},
+		{`rebind {
+			blaat
+		}`, true, nil, nil, 0},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		rb, err := rebindParse(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %d: Expected error but found nil", i)
+			continue
+		}
+		if !test.shouldErr && err != nil {
+			t.Errorf("Test %d: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr {
+			continue
+		}
+		if len(rb.Zones) != len(test.expectedZones) {
+			t.Errorf("Test %d: Expected zones %v, got %v", i, test.expectedZones, rb.Zones)
+		}
+		for j := range rb.Zones {
+			if j < len(test.expectedZones) && rb.Zones[j] != test.expectedZones[j] {
+				t.Errorf("Test %d: Expected zones %v, got %v", i, test.expectedZones, rb.Zones)
+			}
+		}
+		if len(rb.Except) != len(test.expectedExcept) {
+			t.Errorf("Test %d: Expected except %v, got %v", i, test.expectedExcept, rb.Except)
+		}
+		for j := range rb.Except {
+			if j < len(test.expectedExcept) && rb.Except[j] != test.expectedExcept[j] {
+				t.Errorf("Test %d: Expected except %v, got %v", i, test.expectedExcept, rb.Except)
+			}
+		}
+		if len(rb.Networks) != test.expectedNetworks {
+			t.Errorf("Test %d: Expected %d networks, got %d", i, test.expectedNetworks, len(rb.Networks))
+		}
+	}
+}
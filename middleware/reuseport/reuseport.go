@@ -0,0 +1,44 @@
+// Package reuseport implements the reuseport directive that opens multiple UDP
+// sockets, each with its own read loop, on the same address.
+package reuseport
+
+import (
+	"runtime"
+	"strconv"
+
+	"github.com/miekg/coredns/core/dnsserver"
+	"github.com/miekg/coredns/middleware"
+
+	"github.com/mholt/caddy"
+)
+
+func init() {
+	caddy.RegisterPlugin("reuseport", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setupReusePort,
+	})
+}
+
+func setupReusePort(c *caddy.Controller) error {
+	config := dnsserver.GetConfig(c)
+	for c.Next() {
+		n := runtime.NumCPU()
+		args := c.RemainingArgs()
+		switch len(args) {
+		case 0:
+		case 1:
+			var err error
+			n, err = strconv.Atoi(args[0])
+			if err != nil {
+				return middleware.Error("reuseport", err)
+			}
+			if n <= 0 {
+				return middleware.Error("reuseport", c.Errf("number of sockets must be larger than zero: %d", n))
+			}
+		default:
+			return middleware.Error("reuseport", c.ArgErr())
+		}
+		config.ReusePort = n
+	}
+	return nil
+}
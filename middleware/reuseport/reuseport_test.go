@@ -0,0 +1,44 @@
+package reuseport
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/miekg/coredns/core/dnsserver"
+
+	"github.com/mholt/caddy"
+)
+
+func TestSetupReusePort(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		expected  int
+	}{
+		{`reuseport`, false, runtime.NumCPU()},
+		{`reuseport 4`, false, 4},
+		// fails
+		{`reuseport 0`, true, 0},
+		{`reuseport blaat`, true, 0},
+		{`reuseport 4 5`, true, 0},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		err := setupReusePort(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %d: Expected error but found nil", i)
+			continue
+		}
+		if !test.shouldErr && err != nil {
+			t.Errorf("Test %d: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr {
+			continue
+		}
+		if cfg := dnsserver.GetConfig(c); cfg.ReusePort != test.expected {
+			t.Errorf("Test %d: Expected ReusePort to be %d, got %d", i, test.expected, cfg.ReusePort)
+		}
+	}
+}
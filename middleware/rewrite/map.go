@@ -0,0 +1,129 @@
+package rewrite
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/miekg/coredns/middleware"
+	"github.com/miekg/coredns/middleware/pkg/watch"
+
+	"github.com/miekg/dns"
+)
+
+// MapRule rewrites query names using a name to name mapping that is loaded from a
+// file. Lookups are done in a hash table, so the mapping can be very large. When
+// the file changes on disk the mapping is reloaded.
+type MapRule struct {
+	file     string
+	NoReload bool
+
+	sync.RWMutex
+	names map[string]string
+}
+
+// NewMapRule returns a new MapRule with the mapping read from file.
+func NewMapRule(file string) (*MapRule, error) {
+	m := &MapRule{file: path.Clean(file)}
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Rewrite implements the Rule interface.
+func (m *MapRule) Rewrite(r *dns.Msg) Result {
+	name := strings.ToLower(r.Question[0].Name)
+
+	m.RLock()
+	to, ok := m.names[name]
+	m.RUnlock()
+
+	if !ok {
+		return RewriteIgnored
+	}
+	r.Question[0].Name = to
+	return RewriteDone
+}
+
+// Len returns the number of mappings in m.
+func (m *MapRule) Len() int {
+	m.RLock()
+	defer m.RUnlock()
+	return len(m.names)
+}
+
+func (m *MapRule) load() error {
+	f, err := os.Open(m.file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	names, err := parseMap(f)
+	if err != nil {
+		return fmt.Errorf("%s: %s", m.file, err)
+	}
+
+	m.Lock()
+	m.names = names
+	m.Unlock()
+	return nil
+}
+
+// Reload reloads the mapping when the file is changed on disk. If m.NoReload is true,
+// no reloading will be done. Closing shutdown stops the watcher.
+func (m *MapRule) Reload(shutdown chan bool) error {
+	if m.NoReload {
+		return nil
+	}
+	stop, err := watch.File(m.file, m.reload)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-shutdown
+		stop()
+	}()
+	return nil
+}
+
+func (m *MapRule) reload() error {
+	if err := m.load(); err != nil {
+		log.Printf("[ERROR] Failed to reload rewrite map: %s", err)
+		return err
+	}
+	log.Printf("[INFO] Successfully reloaded rewrite map `%s'", m.file)
+	return nil
+}
+
+// parseMap parses the map file in r. Each line contains a name and its replacement
+// separated by whitespace. Empty lines and lines starting with '#' are ignored. If
+// a name is listed twice the first mapping is used.
+func parseMap(r io.Reader) (map[string]string, error) {
+	names := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected two names, got %d fields", line, len(fields))
+		}
+		from := middleware.Name(fields[0]).Normalize()
+		if _, ok := names[from]; ok {
+			continue
+		}
+		names[from] = middleware.Name(fields[1]).Normalize()
+	}
+	return names, scanner.Err()
+}
@@ -0,0 +1,101 @@
+package rewrite
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/coredns/middleware/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestMapRule(t *testing.T) {
+	fileName, rm, err := test.TempFile(t, ".", mapTest)
+	if err != nil {
+		t.Fatalf("failed to create map file: %s", err)
+	}
+	defer rm()
+
+	m, err := NewMapRule(fileName)
+	if err != nil {
+		t.Fatalf("failed to load map: %s", err)
+	}
+	if m.Len() != 2 {
+		t.Fatalf("expected 2 mappings, got %d", m.Len())
+	}
+
+	tests := []struct {
+		from   string
+		to     string
+		result Result
+	}{
+		{"a.miek.nl.", "b.miek.nl.", RewriteDone},
+		{"A.Miek.NL.", "b.miek.nl.", RewriteDone},
+		{"www.example.org.", "example.org.", RewriteDone},
+		{"c.miek.nl.", "c.miek.nl.", RewriteIgnored},
+	}
+	for i, tc := range tests {
+		r := new(dns.Msg)
+		r.SetQuestion(tc.from, dns.TypeA)
+		if x := m.Rewrite(r); x != tc.result {
+			t.Errorf("Test %d: expected result %d, got %d", i, tc.result, x)
+		}
+		if x := r.Question[0].Name; x != tc.to {
+			t.Errorf("Test %d: expected name %s, got %s", i, tc.to, x)
+		}
+	}
+
+	shutdown := make(chan bool)
+	defer close(shutdown)
+	m.Reload(shutdown)
+
+	if err := ioutil.WriteFile(fileName, []byte(mapTest2), 0644); err != nil {
+		t.Fatalf("failed to write new map data: %s", err)
+	}
+	// Could still be racy, but we need to wait a bit for the event to be seen
+	time.Sleep(1 * time.Second)
+
+	if m.Len() != 1 {
+		t.Fatalf("expected 1 mapping after reload, got %d", m.Len())
+	}
+}
+
+func TestParseMap(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		expected  int
+	}{
+		{mapTest, false, 2},
+		{"a.nl. b.nl. c.nl.", true, 0},
+		{"a.nl.", true, 0},
+		{"# only comments\n\n", false, 0},
+		// duplicates are ignored
+		{"a.nl b.nl\na.nl c.nl", false, 1},
+	}
+	for i, tc := range tests {
+		names, err := parseMap(strings.NewReader(tc.input))
+		if tc.shouldErr && err == nil {
+			t.Errorf("Test %d: expected error, got none", i)
+			continue
+		}
+		if !tc.shouldErr && err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if len(names) != tc.expected {
+			t.Errorf("Test %d: expected %d names, got %d", i, tc.expected, len(names))
+		}
+	}
+}
+
+const mapTest = `# rewrite map
+a.miek.nl.	b.miek.nl.
+
+www.example.org example.org
+`
+
+const mapTest2 = `a.miek.nl.	c.miek.nl.
+`
@@ -0,0 +1,219 @@
+package rewrite
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/coredns/middleware"
+
+	"github.com/miekg/dns"
+)
+
+// Name match types.
+const (
+	ExactMatch     = "exact"
+	PrefixMatch    = "prefix"
+	SuffixMatch    = "suffix"
+	SubstringMatch = "substring"
+	RegexMatch     = "regex"
+)
+
+// nameRule is a rule that rewrites the qname of a message.
+type nameRule interface {
+	Rule
+	// rewriteName returns the rewritten name and true, or false if name did not match.
+	rewriteName(name string) (string, bool)
+}
+
+// NewNameRule returns a rule that rewrites the query name using the match type
+// matchType, from is matched against the (lowercased) query name, to is the
+// replacement. For regex rules to may contain {1}, {2}, ... placeholders that
+// will be replaced by the respective capture group.
+func NewNameRule(matchType, from, to string) (Rule, error) {
+	switch matchType {
+	case ExactMatch:
+		return exactNameRule{from: middleware.Name(from).Normalize(), to: middleware.Name(to).Normalize()}, nil
+	case PrefixMatch:
+		return prefixNameRule{prefix: strings.ToLower(from), replacement: strings.ToLower(to)}, nil
+	case SuffixMatch:
+		return suffixNameRule{suffix: middleware.Name(from).Normalize(), replacement: middleware.Name(to).Normalize()}, nil
+	case SubstringMatch:
+		return substringNameRule{substring: strings.ToLower(from), replacement: strings.ToLower(to)}, nil
+	case RegexMatch:
+		re, err := regexp.Compile(from)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex pattern in name rule: %s", err)
+		}
+		return regexNameRule{pattern: re, replacement: to}, nil
+	}
+	return nil, fmt.Errorf("unknown name match type: %s", matchType)
+}
+
+type exactNameRule struct {
+	from, to string
+}
+
+func (r exactNameRule) rewriteName(name string) (string, bool) {
+	if name == r.from {
+		return r.to, true
+	}
+	return "", false
+}
+
+// Rewrite implements the Rule interface.
+func (r exactNameRule) Rewrite(m *dns.Msg) Result { return rewriteName(r, m) }
+
+type prefixNameRule struct {
+	prefix, replacement string
+}
+
+func (r prefixNameRule) rewriteName(name string) (string, bool) {
+	if strings.HasPrefix(name, r.prefix) {
+		return r.replacement + name[len(r.prefix):], true
+	}
+	return "", false
+}
+
+// Rewrite implements the Rule interface.
+func (r prefixNameRule) Rewrite(m *dns.Msg) Result { return rewriteName(r, m) }
+
+type suffixNameRule struct {
+	suffix, replacement string
+}
+
+func (r suffixNameRule) rewriteName(name string) (string, bool) {
+	if strings.HasSuffix(name, r.suffix) {
+		return name[:len(name)-len(r.suffix)] + r.replacement, true
+	}
+	return "", false
+}
+
+// Rewrite implements the Rule interface.
+func (r suffixNameRule) Rewrite(m *dns.Msg) Result { return rewriteName(r, m) }
+
+type substringNameRule struct {
+	substring, replacement string
+}
+
+func (r substringNameRule) rewriteName(name string) (string, bool) {
+	if strings.Contains(name, r.substring) {
+		return strings.Replace(name, r.substring, r.replacement, 1), true
+	}
+	return "", false
+}
+
+// Rewrite implements the Rule interface.
+func (r substringNameRule) Rewrite(m *dns.Msg) Result { return rewriteName(r, m) }
+
+type regexNameRule struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+func (r regexNameRule) rewriteName(name string) (string, bool) {
+	groups := r.pattern.FindStringSubmatch(name)
+	if len(groups) == 0 {
+		return "", false
+	}
+	s := r.replacement
+	for i := len(groups) - 1; i > 0; i-- {
+		s = strings.Replace(s, "{"+strconv.Itoa(i)+"}", groups[i], -1)
+	}
+	return dns.Fqdn(s), true
+}
+
+// Rewrite implements the Rule interface.
+func (r regexNameRule) Rewrite(m *dns.Msg) Result { return rewriteName(r, m) }
+
+func rewriteName(r nameRule, m *dns.Msg) Result {
+	name, ok := r.rewriteName(strings.ToLower(m.Question[0].Name))
+	if !ok {
+		return RewriteIgnored
+	}
+	m.Question[0].Name = name
+	return RewriteDone
+}
+
+// nameRuleSet is a compiled set of name rules. Exact matches are kept in a map, so
+// large numbers of them don't result in a linear scan for each query. Suffix rules that
+// start at a label, like .example.org., are kept in a map as well, it is looked up for
+// each label of the query name. The other rules are tried in the order they were
+// specified, before an indexed suffix rule that was specified after them.
+type nameRuleSet struct {
+	exact  map[string]string
+	suffix map[string]indexedSuffix
+	rules  []nameRule
+	pos    []int // the position of each of rules among all rules
+	n      int
+}
+
+// indexedSuffix is a suffix rule with its position among all rules.
+type indexedSuffix struct {
+	rule suffixNameRule
+	pos  int
+}
+
+func newNameRuleSet() *nameRuleSet {
+	return &nameRuleSet{exact: make(map[string]string), suffix: make(map[string]indexedSuffix)}
+}
+
+// add adds r to the set. If an exact match, or a suffix rule, for the same name already
+// exists it is not overwritten, the first rule wins.
+func (s *nameRuleSet) add(r nameRule) {
+	s.n++
+	switch x := r.(type) {
+	case exactNameRule:
+		if _, dup := s.exact[x.from]; !dup {
+			s.exact[x.from] = x.to
+		}
+		return
+	case suffixNameRule:
+		if strings.HasPrefix(x.suffix, ".") {
+			if _, dup := s.suffix[x.suffix]; !dup {
+				s.suffix[x.suffix] = indexedSuffix{rule: x, pos: s.n}
+			}
+			return
+		}
+	}
+	s.rules = append(s.rules, r)
+	s.pos = append(s.pos, s.n)
+}
+
+func (s *nameRuleSet) len() int { return len(s.exact) + len(s.suffix) + len(s.rules) }
+
+// Rewrite implements the Rule interface.
+func (s *nameRuleSet) Rewrite(m *dns.Msg) Result {
+	name := strings.ToLower(m.Question[0].Name)
+	if to, ok := s.exact[name]; ok {
+		m.Question[0].Name = to
+		return RewriteDone
+	}
+
+	// Of the indexed suffix rules that match, the one specified first wins.
+	suffix := indexedSuffix{pos: -1}
+	if len(s.suffix) > 0 {
+		for off, end := 0, false; !end; {
+			off, end = dns.NextLabel(name, off)
+			if x, ok := s.suffix[name[off-1:]]; ok && (suffix.pos < 0 || x.pos < suffix.pos) {
+				suffix = x
+			}
+		}
+	}
+
+	for i, r := range s.rules {
+		if suffix.pos >= 0 && s.pos[i] > suffix.pos {
+			break
+		}
+		if to, ok := r.rewriteName(name); ok {
+			m.Question[0].Name = to
+			return RewriteDone
+		}
+	}
+	if suffix.pos >= 0 {
+		m.Question[0].Name, _ = suffix.rule.rewriteName(name)
+		return RewriteDone
+	}
+	return RewriteIgnored
+}
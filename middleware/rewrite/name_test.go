@@ -0,0 +1,121 @@
+package rewrite
+
+import (
+	"testing"
+
+	"github.com/miekg/coredns/middleware"
+	"github.com/miekg/coredns/middleware/pkg/dnsrecorder"
+	"github.com/miekg/coredns/middleware/test"
+
+	"github.com/mholt/caddy"
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+func TestNameRules(t *testing.T) {
+	c := caddy.NewTestController("dns", `rewrite name exact from.nl. to.nl.
+rewrite name prefix www. web.
+rewrite name suffix .example.org .example.net
+rewrite name substring staging prod
+rewrite name regex ^(.*)\.svc\.local\.$ {1}.svc.cluster.local
+rewrite name exact www.example.org. first.example.org.`)
+	rules, err := rewriteParse(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	rw := Rewrite{Next: middleware.HandlerFunc(msgPrinter), Rules: rules, noRevert: true}
+
+	tests := []struct {
+		from string
+		to   string
+	}{
+		{"from.nl.", "to.nl."},
+		{"FROM.nl.", "to.nl."},
+		{"www.miek.nl.", "web.miek.nl."},
+		{"a.example.org.", "a.example.net."},
+		{"db.staging.miek.nl.", "db.prod.miek.nl."},
+		{"kube-dns.svc.local.", "kube-dns.svc.cluster.local."},
+		// exact matches take precedence
+		{"www.example.org.", "first.example.org."},
+		{"nomatch.nl.", "nomatch.nl."},
+	}
+
+	ctx := context.TODO()
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion(tc.from, dns.TypeA)
+
+		rec := dnsrecorder.New(&test.ResponseWriter{})
+		rw.ServeDNS(ctx, rec, m)
+
+		if x := rec.Msg.Question[0].Name; x != tc.to {
+			t.Errorf("Test %d: Expected Name to be '%s' but was '%s'", i, tc.to, x)
+		}
+	}
+}
+
+func TestNameRuleSetSuffix(t *testing.T) {
+	c := caddy.NewTestController("dns", `rewrite name prefix www. web.
+rewrite name suffix .example.org .example.net
+rewrite name suffix .org .nl
+rewrite name suffix le.com ple.net
+rewrite name suffix .a.example.org .b.example.org`)
+	rules, err := rewriteParse(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	rw := Rewrite{Next: middleware.HandlerFunc(msgPrinter), Rules: rules, noRevert: true}
+
+	tests := []struct {
+		from string
+		to   string
+	}{
+		// the prefix rule comes before the suffix rules
+		{"www.example.org.", "web.example.org."},
+		{"a.example.org.", "a.example.net."},
+		// the first suffix rule that matches wins, not the longest
+		{"x.a.example.org.", "x.a.example.net."},
+		{"miek.org.", "miek.nl."},
+		{"badexample.org.", "badexample.nl."},
+		// a suffix that doesn't start at a label is still matched
+		{"example.com.", "exampple.net."},
+		{"nomatch.nl.", "nomatch.nl."},
+	}
+
+	ctx := context.TODO()
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion(tc.from, dns.TypeA)
+
+		rec := dnsrecorder.New(&test.ResponseWriter{})
+		rw.ServeDNS(ctx, rec, m)
+
+		if x := rec.Msg.Question[0].Name; x != tc.to {
+			t.Errorf("Test %d: Expected Name to be '%s' but was '%s'", i, tc.to, x)
+		}
+	}
+}
+
+func TestNameRuleParse(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+	}{
+		{`rewrite name from.nl. to.nl.`, false},
+		{`rewrite name exact from.nl. to.nl.`, false},
+		{`rewrite name regex (.*)\.nl {1}.org`, false},
+		{`rewrite name from.nl.`, true},
+		{`rewrite name blaat from.nl. to.nl.`, true},
+		{`rewrite name regex ([a-z from.nl.`, true},
+	}
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		_, err := rewriteParse(c)
+		if tc.shouldErr && err == nil {
+			t.Errorf("Test %d: Expected error, but got none", i)
+		}
+		if !tc.shouldErr && err != nil {
+			t.Errorf("Test %d: Expected no error, but got %s", i, err)
+		}
+	}
+}
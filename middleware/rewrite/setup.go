@@ -22,6 +22,19 @@ func setup(c *caddy.Controller) error {
 		return middleware.Error("rewrite", err)
 	}
 
+	for _, r := range rewrites {
+		m, ok := r.(*MapRule)
+		if !ok {
+			continue
+		}
+		shutdown := make(chan bool)
+		c.OnStartup(func() error { return m.Reload(shutdown) })
+		c.OnShutdown(func() error {
+			close(shutdown)
+			return nil
+		})
+	}
+
 	dnsserver.GetConfig(c).AddMiddleware(func(next middleware.Handler) middleware.Handler {
 		return Rewrite{Next: next, Rules: rewrites}
 	})
@@ -32,6 +45,7 @@ func setup(c *caddy.Controller) error {
 func rewriteParse(c *caddy.Controller) ([]Rule, error) {
 	var simpleRules []Rule
 	var regexpRules []Rule
+	names := newNameRuleSet()
 
 	for c.Next() {
 		var rule Rule
@@ -46,6 +60,23 @@ func rewriteParse(c *caddy.Controller) ([]Rule, error) {
 
 		args := c.RemainingArgs()
 
+		if len(args) > 0 && args[0] == "name" {
+			r, err := nameParse(c, args[1:])
+			if err != nil {
+				return nil, err
+			}
+			names.add(r)
+			continue
+		}
+		if len(args) > 0 && args[0] == "map" {
+			r, err := mapParse(c, args[1:])
+			if err != nil {
+				return nil, err
+			}
+			simpleRules = append(simpleRules, r)
+			continue
+		}
+
 		switch len(args) {
 		case 1:
 			/*
@@ -113,6 +144,48 @@ func rewriteParse(c *caddy.Controller) ([]Rule, error) {
 		}
 	}
 
+	if names.len() > 0 {
+		simpleRules = append(simpleRules, names)
+	}
+
 	// put simple rules in front to avoid regexp computation for them
 	return append(simpleRules, regexpRules...), nil
 }
+
+// nameParse parses: name [exact|prefix|suffix|substring|regex] FROM TO.
+func nameParse(c *caddy.Controller, args []string) (nameRule, error) {
+	matchType := ExactMatch
+	switch len(args) {
+	case 2:
+	case 3:
+		matchType = args[0]
+		args = args[1:]
+	default:
+		return nil, c.ArgErr()
+	}
+	r, err := NewNameRule(matchType, args[0], args[1])
+	if err != nil {
+		return nil, c.Err(err.Error())
+	}
+	return r.(nameRule), nil
+}
+
+// mapParse parses: map FILE [no_reload].
+func mapParse(c *caddy.Controller, args []string) (*MapRule, error) {
+	if len(args) == 0 || len(args) > 2 {
+		return nil, c.ArgErr()
+	}
+	noReload := false
+	if len(args) == 2 {
+		if args[1] != "no_reload" {
+			return nil, c.Errf("unknown property '%s'", args[1])
+		}
+		noReload = true
+	}
+	m, err := NewMapRule(args[0])
+	if err != nil {
+		return nil, err
+	}
+	m.NoReload = noReload
+	return m, nil
+}
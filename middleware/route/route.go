@@ -0,0 +1,84 @@
+// Package route implements a middleware that sends queries of some types to another part
+// of the middleware chain, or answers them with a fixed rcode.
+package route
+
+import (
+	"fmt"
+
+	"github.com/miekg/coredns/middleware"
+	"github.com/miekg/coredns/request"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/context"
+)
+
+// Route sends queries with a type in Rules to the target of the rule. Other queries are
+// handed to Next.
+type Route struct {
+	Next  middleware.Handler
+	Rules map[uint16]Target
+
+	// Handler returns the middleware chain that starts at the middleware with the
+	// given name, or nil.
+	Handler func(name string) middleware.Handler
+}
+
+// Target is where a query is routed to: the chain starting at Middleware, or, if that is
+// empty, a reply with Rcode.
+type Target struct {
+	Middleware string
+	Rcode      int
+}
+
+// String returns the name of the middleware or the rcode of t.
+func (t Target) String() string {
+	if t.Middleware != "" {
+		return t.Middleware
+	}
+	return dns.RcodeToString[t.Rcode]
+}
+
+// ServeDNS implements the middleware.Handler interface.
+func (rt Route) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: r}
+
+	t, ok := rt.Rules[state.QType()]
+	// A query that was routed once is not routed again, that would loop when the target
+	// comes before us in the chain.
+	if !ok || ctx.Value(routedKey{}) != nil {
+		return rt.Next.ServeDNS(ctx, w, r)
+	}
+	routedCount.WithLabelValues(state.Type(), t.String()).Inc()
+
+	if t.Middleware == "" {
+		// For the error rcodes the server writes the reply, the others we write ourselves.
+		if !middleware.ClientWrite(t.Rcode) {
+			return t.Rcode, nil
+		}
+		m := new(dns.Msg)
+		m.SetRcode(r, t.Rcode)
+		state.SizeAndDo(m)
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	}
+
+	h := rt.Handler(t.Middleware)
+	if h == nil {
+		return dns.RcodeServerFailure, middleware.Error("route", fmt.Errorf("no middleware %s in this server block", t.Middleware))
+	}
+	return h.ServeDNS(context.WithValue(ctx, routedKey{}, true), w, r)
+}
+
+type routedKey struct{}
+
+var routedCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: middleware.Namespace,
+	Subsystem: "route",
+	Name:      "queries_total",
+	Help:      "Counter of queries routed, by query type and target.",
+}, []string{"type", "to"})
+
+func init() {
+	prometheus.MustRegister(routedCount)
+}
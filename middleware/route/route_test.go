@@ -0,0 +1,107 @@
+package route
+
+import (
+	"testing"
+
+	"github.com/miekg/coredns/middleware"
+	"github.com/miekg/coredns/middleware/pkg/dnsrecorder"
+	"github.com/miekg/coredns/middleware/test"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+// answer returns a handler that answers with a TXT record holding name.
+func answer(name string) middleware.Handler {
+	return middleware.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = []dns.RR{test.TXT(r.Question[0].Name + `	300	IN	TXT	"` + name + `"`)}
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+}
+
+func TestRoute(t *testing.T) {
+	rt := Route{
+		Next: answer("next"),
+		Rules: map[uint16]Target{
+			dns.TypeTXT:  {Middleware: "proxy"},
+			dns.TypeANY:  {Rcode: dns.RcodeRefused},
+			dns.TypeMX:   {Rcode: dns.RcodeNameError},
+			dns.TypeAAAA: {Middleware: "nosuch"},
+		},
+	}
+	rt.Handler = func(name string) middleware.Handler {
+		if name == "proxy" {
+			return answer("proxy")
+		}
+		return nil
+	}
+
+	tests := []struct {
+		qtype         uint16
+		expectedRcode int
+		expectedErr   bool
+		expectedTxt   string // empty if no answer should be written
+		expectedReply int    // rcode of the written reply, -1 if none should be written
+	}{
+		{dns.TypeA, dns.RcodeSuccess, false, "next", dns.RcodeSuccess},
+		{dns.TypeTXT, dns.RcodeSuccess, false, "proxy", dns.RcodeSuccess},
+		{dns.TypeANY, dns.RcodeRefused, false, "", -1},
+		{dns.TypeMX, dns.RcodeSuccess, false, "", dns.RcodeNameError},
+		{dns.TypeAAAA, dns.RcodeServerFailure, true, "", -1},
+	}
+
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", tc.qtype)
+		rec := dnsrecorder.New(&test.ResponseWriter{})
+
+		rcode, err := rt.ServeDNS(context.TODO(), rec, m)
+		if rcode != tc.expectedRcode {
+			t.Errorf("Test %d: expected rcode %d, got %d", i, tc.expectedRcode, rcode)
+		}
+		if (err != nil) != tc.expectedErr {
+			t.Errorf("Test %d: expected error %t, got %v", i, tc.expectedErr, err)
+		}
+		if tc.expectedReply == -1 {
+			if rec.Msg != nil {
+				t.Errorf("Test %d: expected no reply to be written, got %v", i, rec.Msg)
+			}
+			continue
+		}
+		if rec.Msg == nil || rec.Msg.Rcode != tc.expectedReply {
+			t.Errorf("Test %d: expected a reply with rcode %d, got %v", i, tc.expectedReply, rec.Msg)
+			continue
+		}
+		if tc.expectedTxt == "" {
+			if rec.Msg != nil && len(rec.Msg.Answer) > 0 {
+				t.Errorf("Test %d: expected no answer, got %v", i, rec.Msg.Answer)
+			}
+			continue
+		}
+		if rec.Msg == nil || len(rec.Msg.Answer) != 1 {
+			t.Errorf("Test %d: expected one answer, got %v", i, rec.Msg)
+			continue
+		}
+		if txt := rec.Msg.Answer[0].(*dns.TXT).Txt[0]; txt != tc.expectedTxt {
+			t.Errorf("Test %d: expected answer from %s, got %s", i, tc.expectedTxt, txt)
+		}
+	}
+}
+
+func TestRouteLoop(t *testing.T) {
+	rt := Route{Next: answer("next"), Rules: map[uint16]Target{dns.TypeTXT: {Middleware: "log"}}}
+	// The target comes before route in the chain, so the query passes route again.
+	rt.Handler = func(name string) middleware.Handler { return rt }
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeTXT)
+	rec := dnsrecorder.New(&test.ResponseWriter{})
+	rt.ServeDNS(context.TODO(), rec, m)
+
+	if rec.Msg == nil || rec.Msg.Answer[0].(*dns.TXT).Txt[0] != "next" {
+		t.Errorf("Expected the routed query to be handed to the next middleware, got %v", rec.Msg)
+	}
+}
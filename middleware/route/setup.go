@@ -0,0 +1,78 @@
+package route
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/miekg/coredns/core/dnsserver"
+	"github.com/miekg/coredns/middleware"
+
+	"github.com/mholt/caddy"
+	"github.com/miekg/dns"
+)
+
+func init() {
+	caddy.RegisterPlugin("route", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	rules, err := routeParse(c)
+	if err != nil {
+		return middleware.Error("route", err)
+	}
+
+	config := dnsserver.GetConfig(c)
+	rt := Route{Rules: rules, Handler: config.Handler}
+
+	// The chain is only compiled when the servers are made, check the targets then.
+	c.OnStartup(func() error {
+		for _, t := range rules {
+			if t.Middleware != "" && config.Handler(t.Middleware) == nil {
+				return middleware.Error("route", fmt.Errorf("no middleware %s in this server block", t.Middleware))
+			}
+		}
+		return nil
+	})
+
+	config.AddMiddleware(func(next middleware.Handler) middleware.Handler {
+		rt.Next = next
+		return rt
+	})
+
+	return nil
+}
+
+func routeParse(c *caddy.Controller) (map[uint16]Target, error) {
+	rules := make(map[uint16]Target)
+
+	for c.Next() {
+		args := c.RemainingArgs()
+		if len(args) < 2 {
+			return nil, c.ArgErr()
+		}
+
+		to := args[len(args)-1]
+		t := Target{Middleware: strings.ToLower(to)}
+		if rcode, ok := dns.StringToRcode[strings.ToUpper(to)]; ok {
+			t = Target{Rcode: rcode}
+		}
+		if t.Middleware == "route" {
+			return nil, c.Errf("can not route to route")
+		}
+
+		for _, a := range args[:len(args)-1] {
+			qtype, ok := dns.StringToType[strings.ToUpper(a)]
+			if !ok {
+				return nil, c.Errf("unknown type '%s'", a)
+			}
+			if _, ok := rules[qtype]; ok {
+				return nil, c.Errf("type %s is routed more than once", a)
+			}
+			rules[qtype] = t
+		}
+	}
+	return rules, nil
+}
@@ -0,0 +1,52 @@
+package route
+
+import (
+	"testing"
+
+	"github.com/mholt/caddy"
+	"github.com/miekg/dns"
+)
+
+func TestSetupRoute(t *testing.T) {
+	tests := []struct {
+		input         string
+		shouldErr     bool
+		expectedRules map[uint16]Target
+	}{
+		{`route ANY refused`, false, map[uint16]Target{dns.TypeANY: {Rcode: dns.RcodeRefused}}},
+		{`route txt mx proxy`, false, map[uint16]Target{dns.TypeTXT: {Middleware: "proxy"}, dns.TypeMX: {Middleware: "proxy"}}},
+		{`route ANY NOTIMP
+		route AAAA file`, false, map[uint16]Target{dns.TypeANY: {Rcode: dns.RcodeNotImplemented}, dns.TypeAAAA: {Middleware: "file"}}},
+		// fails
+		{`route`, true, nil},
+		{`route ANY`, true, nil},
+		{`route BLAAT proxy`, true, nil},
+		{`route A route`, true, nil},
+		{`route A proxy
+		route A file`, true, nil},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		rules, err := routeParse(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %d: Expected error but found nil", i)
+			continue
+		}
+		if !test.shouldErr && err != nil {
+			t.Errorf("Test %d: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr {
+			continue
+		}
+		if len(rules) != len(test.expectedRules) {
+			t.Errorf("Test %d: Expected %d rules, got %d", i, len(test.expectedRules), len(rules))
+		}
+		for qtype, target := range test.expectedRules {
+			if rules[qtype] != target {
+				t.Errorf("Test %d: Expected %s to be routed to %s, got %s", i, dns.Type(qtype), target, rules[qtype])
+			}
+		}
+	}
+}
@@ -0,0 +1,136 @@
+// Package rrl implements Response Rate Limiting, as done by BIND.
+package rrl
+
+import (
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/miekg/coredns/middleware"
+	"github.com/miekg/coredns/middleware/pkg/response"
+	"github.com/miekg/coredns/request"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/context"
+)
+
+// RRL limits the rate of identical responses sent to a client network. Responses
+// are grouped in flows by the prefix of the client address and the name and type
+// that were asked, NXDOMAIN responses and errors are grouped per zone. Only UDP is
+// limited; over TCP the client address can't be spoofed.
+type RRL struct {
+	Next  middleware.Handler
+	Zones []string
+
+	rate    float64 // responses per second, 0 is unlimited
+	nxRate  float64 // NXDOMAIN responses per second
+	errRate float64 // error responses per second
+
+	window     time.Duration // how long a flow is remembered
+	ipv4Prefix int
+	ipv6Prefix int
+	slip       int // every slip'th limited response is sent truncated, 0 drops them all
+
+	table *table
+}
+
+// ServeDNS implements the middleware.Handler interface.
+func (rl RRL) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: r}
+
+	zone := middleware.Zones(rl.Zones).Matches(state.Name())
+	if zone == "" || state.Proto() != "udp" {
+		return rl.Next.ServeDNS(ctx, w, r)
+	}
+
+	rw := &ResponseWriter{ResponseWriter: w, rrl: rl, zone: zone, state: state}
+	return rl.Next.ServeDNS(ctx, rw, r)
+}
+
+// ResponseWriter applies the rate limit to the responses written to it.
+type ResponseWriter struct {
+	dns.ResponseWriter
+	rrl   RRL
+	zone  string
+	state request.Request
+}
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (w *ResponseWriter) WriteMsg(res *dns.Msg) error {
+	rate, key := w.rrl.flow(w.state, w.zone, res)
+	if rate == 0 {
+		return w.ResponseWriter.WriteMsg(res)
+	}
+
+	ok, n := w.rrl.table.debit(key, rate, w.rrl.window, time.Now())
+	if ok {
+		return w.ResponseWriter.WriteMsg(res)
+	}
+
+	if w.rrl.slip > 0 && n%w.rrl.slip == 0 {
+		// Slip: send an empty, truncated, response so a real client retries over TCP.
+		m := new(dns.Msg)
+		m.SetReply(w.state.Req)
+		m.Truncated = true
+		w.state.SizeAndDo(m)
+		slipCount.WithLabelValues(w.zone).Inc()
+		return w.ResponseWriter.WriteMsg(m)
+	}
+	dropCount.WithLabelValues(w.zone).Inc()
+	return nil
+}
+
+// Write implements the dns.ResponseWriter interface.
+func (w *ResponseWriter) Write(buf []byte) (int, error) {
+	return w.ResponseWriter.Write(buf)
+}
+
+// flow returns the rate that applies to res and the key of the flow it belongs to.
+func (rl RRL) flow(state request.Request, zone string, res *dns.Msg) (float64, string) {
+	prefix := rl.prefix(state)
+
+	t, _ := response.Classify(res)
+	switch t {
+	case response.NameError:
+		return rl.nxRate, prefix + "/nxdomain/" + zone
+	case response.OtherError:
+		return rl.errRate, prefix + "/error/" + zone
+	}
+	return rl.rate, prefix + "/" + state.Name() + "/" + strconv.Itoa(int(state.QType()))
+}
+
+// prefix returns the network of the client, using the configured prefix lengths.
+func (rl RRL) prefix(state request.Request) string {
+	ip := net.ParseIP(state.IP())
+	if ip == nil {
+		return state.IP()
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(rl.ipv4Prefix, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(rl.ipv6Prefix, 128)).String()
+}
+
+var (
+	dropCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: middleware.Namespace,
+		Subsystem: subsystem,
+		Name:      "dropped_responses_total",
+		Help:      "Counter of responses that were dropped because of the rate limit.",
+	}, []string{"zone"})
+
+	slipCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: middleware.Namespace,
+		Subsystem: subsystem,
+		Name:      "slipped_responses_total",
+		Help:      "Counter of truncated responses sent instead of rate limited responses.",
+	}, []string{"zone"})
+)
+
+const subsystem = "rrl"
+
+func init() {
+	prometheus.MustRegister(dropCount)
+	prometheus.MustRegister(slipCount)
+}
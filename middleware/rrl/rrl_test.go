@@ -0,0 +1,114 @@
+package rrl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/coredns/middleware"
+	"github.com/miekg/coredns/middleware/pkg/dnsrecorder"
+	"github.com/miekg/coredns/middleware/test"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+func TestTableDebit(t *testing.T) {
+	tb := newTable(10)
+	now := time.Now()
+
+	// A rate of 2 allows two responses in the same second.
+	for i := 0; i < 2; i++ {
+		if ok, _ := tb.debit("a", 2, 5*time.Second, now); !ok {
+			t.Fatalf("Expected response %d to be allowed", i)
+		}
+	}
+	if ok, n := tb.debit("a", 2, 5*time.Second, now); ok || n != 1 {
+		t.Errorf("Expected third response to be limited as number 1, got %t and %d", ok, n)
+	}
+	// Other flows are not affected.
+	if ok, _ := tb.debit("b", 2, 5*time.Second, now); !ok {
+		t.Errorf("Expected other flow to be allowed")
+	}
+	// After a second the bucket is credited again.
+	if ok, _ := tb.debit("a", 2, 5*time.Second, now.Add(time.Second)); !ok {
+		t.Errorf("Expected response to be allowed after a second")
+	}
+}
+
+func TestTableEvict(t *testing.T) {
+	tb := newTable(2)
+	now := time.Now()
+
+	tb.debit("a", 1, time.Second, now)
+	tb.debit("b", 1, time.Second, now)
+	// Table is full, "c" is not tracked.
+	tb.debit("c", 1, time.Second, now)
+	if tb.len() != 2 {
+		t.Errorf("Expected 2 buckets, got %d", tb.len())
+	}
+	// After the window the old buckets are evicted to make room.
+	tb.debit("c", 1, time.Second, now.Add(2*time.Second))
+	if tb.len() != 1 {
+		t.Errorf("Expected 1 bucket, got %d", tb.len())
+	}
+}
+
+func TestRRL(t *testing.T) {
+	rl := RRL{
+		Next:       answerHandler(dns.RcodeSuccess),
+		Zones:      []string{"example.org."},
+		rate:       1,
+		nxRate:     1,
+		errRate:    1,
+		window:     defaultWindow,
+		ipv4Prefix: 24,
+		ipv6Prefix: 56,
+		slip:       2,
+		table:      newTable(100),
+	}
+	ctx := context.TODO()
+
+	expected := []struct {
+		written   bool
+		truncated bool
+	}{
+		{true, false},  // allowed
+		{false, false}, // dropped
+		{true, true},   // slipped
+		{false, false}, // dropped
+	}
+	for i, e := range expected {
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		rec := dnsrecorder.New(&test.ResponseWriter{})
+		rl.ServeDNS(ctx, rec, m)
+
+		if e.written != (rec.Msg != nil) {
+			t.Fatalf("Test %d: Expected written to be %t", i, e.written)
+		}
+		if rec.Msg != nil && rec.Msg.Truncated != e.truncated {
+			t.Errorf("Test %d: Expected truncated to be %t", i, e.truncated)
+		}
+	}
+
+	// Names outside of the zones are not limited.
+	for i := 0; i < 3; i++ {
+		m := new(dns.Msg)
+		m.SetQuestion("example.net.", dns.TypeA)
+		rec := dnsrecorder.New(&test.ResponseWriter{})
+		rl.ServeDNS(ctx, rec, m)
+		if rec.Msg == nil {
+			t.Errorf("Expected response for example.net. to be written")
+		}
+	}
+}
+
+func answerHandler(rcode int) middleware.Handler {
+	return middleware.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := new(dns.Msg)
+		m.SetRcode(r, rcode)
+		m.Answer = []dns.RR{test.A(r.Question[0].Name + "	300	IN	A	127.0.0.1")}
+		w.WriteMsg(m)
+		return rcode, nil
+	})
+}
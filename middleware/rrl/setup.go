@@ -0,0 +1,124 @@
+package rrl
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/miekg/coredns/core/dnsserver"
+	"github.com/miekg/coredns/middleware"
+
+	"github.com/mholt/caddy"
+)
+
+func init() {
+	caddy.RegisterPlugin("rrl", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	rl, err := rrlParse(c)
+	if err != nil {
+		return middleware.Error("rrl", err)
+	}
+
+	dnsserver.GetConfig(c).AddMiddleware(func(next middleware.Handler) middleware.Handler {
+		rl.Next = next
+		return rl
+	})
+
+	return nil
+}
+
+func rrlParse(c *caddy.Controller) (RRL, error) {
+	rl := RRL{
+		window:     defaultWindow,
+		ipv4Prefix: defaultIPv4Prefix,
+		ipv6Prefix: defaultIPv6Prefix,
+		slip:       defaultSlip,
+	}
+	max := defaultMaxTableSize
+	nxRate, errRate := -1.0, -1.0
+
+	for c.Next() {
+		origins := make([]string, len(c.ServerBlockKeys))
+		copy(origins, c.ServerBlockKeys)
+		if args := c.RemainingArgs(); len(args) > 0 {
+			origins = args
+		}
+		rl.Zones = middleware.Zones(origins).NormalizeExact()
+
+		for c.NextBlock() {
+			what := c.Val()
+			if !c.NextArg() {
+				return rl, c.ArgErr()
+			}
+			n, err := strconv.Atoi(c.Val())
+			if err != nil {
+				return rl, c.Errf("%s needs a number: %s", what, c.Val())
+			}
+			if n < 0 {
+				return rl, c.Errf("%s can not be negative: %d", what, n)
+			}
+			switch what {
+			case "responses-per-second":
+				rl.rate = float64(n)
+			case "nxdomains-per-second":
+				nxRate = float64(n)
+			case "errors-per-second":
+				errRate = float64(n)
+			case "window":
+				if n == 0 {
+					return rl, c.Errf("window must be positive: %d", n)
+				}
+				rl.window = time.Duration(n) * time.Second
+			case "ipv4-prefix-length":
+				if n == 0 || n > 32 {
+					return rl, c.Errf("invalid ipv4-prefix-length: %d", n)
+				}
+				rl.ipv4Prefix = n
+			case "ipv6-prefix-length":
+				if n == 0 || n > 128 {
+					return rl, c.Errf("invalid ipv6-prefix-length: %d", n)
+				}
+				rl.ipv6Prefix = n
+			case "slip":
+				rl.slip = n
+			case "max-table-size":
+				if n == 0 {
+					return rl, c.Errf("max-table-size must be positive: %d", n)
+				}
+				max = n
+			default:
+				return rl, c.Errf("unknown property '%s'", what)
+			}
+			if c.NextArg() {
+				return rl, c.ArgErr()
+			}
+		}
+	}
+
+	// The NXDOMAIN and error rates default to the response rate.
+	rl.nxRate, rl.errRate = rl.rate, rl.rate
+	if nxRate >= 0 {
+		rl.nxRate = nxRate
+	}
+	if errRate >= 0 {
+		rl.errRate = errRate
+	}
+	if rl.rate == 0 && rl.nxRate == 0 && rl.errRate == 0 {
+		return rl, c.Err("no rate limit set")
+	}
+
+	rl.table = newTable(max)
+	return rl, nil
+}
+
+const (
+	defaultWindow       = 15 * time.Second
+	defaultIPv4Prefix   = 24
+	defaultIPv6Prefix   = 56
+	defaultSlip         = 2
+	defaultMaxTableSize = 100000
+)
@@ -0,0 +1,87 @@
+package rrl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mholt/caddy"
+)
+
+func TestSetupRRL(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		rate      float64
+		nxRate    float64
+		window    time.Duration
+		slip      int
+		zones     []string
+	}{
+		{`rrl {
+			responses-per-second 10
+		}`, false, 10, 10, defaultWindow, defaultSlip, []string{"."}},
+		{`rrl example.org {
+			responses-per-second 10
+			nxdomains-per-second 5
+			window 5
+			slip 0
+		}`, false, 10, 5, 5 * time.Second, 0, []string{"example.org."}},
+		{`rrl {
+			nxdomains-per-second 5
+		}`, false, 0, 5, defaultWindow, defaultSlip, []string{"."}},
+		// fails
+		{`rrl`, true, 0, 0, 0, 0, nil},
+		{`rrl {
+			responses-per-second
+		}`, true, 0, 0, 0, 0, nil},
+		{`rrl {
+			responses-per-second -1
+		}`, true, 0, 0, 0, 0, nil},
+		{`rrl {
+			responses-per-second 10 20
+		}`, true, 0, 0, 0, 0, nil},
+		{`rrl {
+			responses-per-second 10
+			ipv4-prefix-length 33
+		}`, true, 0, 0, 0, 0, nil},
+		{`rrl {
+			responses-per-second 10
+			window 0
+		}`, true, 0, 0, 0, 0, nil},
+		{`rrl {
+			blaat 10
+		}`, true, 0, 0, 0, 0, nil},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		c.ServerBlockKeys = []string{"."}
+		rl, err := rrlParse(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %d: Expected error but found nil", i)
+			continue
+		}
+		if !test.shouldErr && err != nil {
+			t.Errorf("Test %d: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr {
+			continue
+		}
+		if rl.rate != test.rate {
+			t.Errorf("Test %d: Expected rate %f, got %f", i, test.rate, rl.rate)
+		}
+		if rl.nxRate != test.nxRate {
+			t.Errorf("Test %d: Expected nxdomain rate %f, got %f", i, test.nxRate, rl.nxRate)
+		}
+		if rl.window != test.window {
+			t.Errorf("Test %d: Expected window %s, got %s", i, test.window, rl.window)
+		}
+		if rl.slip != test.slip {
+			t.Errorf("Test %d: Expected slip %d, got %d", i, test.slip, rl.slip)
+		}
+		if len(rl.Zones) != len(test.zones) || rl.Zones[0] != test.zones[0] {
+			t.Errorf("Test %d: Expected zones %v, got %v", i, test.zones, rl.Zones)
+		}
+	}
+}
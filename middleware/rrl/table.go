@@ -0,0 +1,79 @@
+package rrl
+
+import (
+	"sync"
+	"time"
+)
+
+// bucket is a token bucket for a single response "flow". It is credited with rate
+// tokens per second, up to rate, and debited for every response. It can go down to
+// -rate*window, so a flow has to be quiet for a while before it is allowed again.
+type bucket struct {
+	balance float64
+	last    time.Time
+	slip    int // count of limited responses, used for slip
+}
+
+// table keeps the buckets for all flows.
+type table struct {
+	sync.Mutex
+	buckets map[string]*bucket
+	max     int
+}
+
+func newTable(max int) *table {
+	return &table{buckets: make(map[string]*bucket), max: max}
+}
+
+// debit accounts a response for key. It returns true if the response is allowed,
+// otherwise it returns false and the number of limited responses for key so far.
+func (t *table) debit(key string, rate float64, window time.Duration, now time.Time) (bool, int) {
+	t.Lock()
+	defer t.Unlock()
+
+	b, ok := t.buckets[key]
+	if !ok {
+		if len(t.buckets) >= t.max {
+			t.evict(window, now)
+			if len(t.buckets) >= t.max {
+				// Still full, don't track this flow.
+				return true, 0
+			}
+		}
+		b = &bucket{balance: rate, last: now}
+		t.buckets[key] = b
+	}
+
+	b.balance += now.Sub(b.last).Seconds() * rate
+	if b.balance > rate {
+		b.balance = rate
+	}
+	b.last = now
+
+	b.balance--
+	if min := -rate * window.Seconds(); b.balance < min {
+		b.balance = min
+	}
+	if b.balance >= 0 {
+		b.slip = 0
+		return true, 0
+	}
+	b.slip++
+	return false, b.slip
+}
+
+// evict removes the buckets that haven't been used for window.
+func (t *table) evict(window time.Duration, now time.Time) {
+	for k, b := range t.buckets {
+		if now.Sub(b.last) > window {
+			delete(t.buckets, k)
+		}
+	}
+}
+
+// len returns the number of buckets in t.
+func (t *table) len() int {
+	t.Lock()
+	defer t.Unlock()
+	return len(t.buckets)
+}
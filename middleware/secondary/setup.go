@@ -4,6 +4,7 @@ import (
 	"github.com/miekg/coredns/core/dnsserver"
 	"github.com/miekg/coredns/middleware"
 	"github.com/miekg/coredns/middleware/file"
+	mwtls "github.com/miekg/coredns/middleware/pkg/tls"
 
 	"github.com/mholt/caddy"
 )
@@ -21,10 +22,13 @@ func setup(c *caddy.Controller) error {
 		return middleware.Error("secondary", err)
 	}
 
+	config := dnsserver.GetConfig(c)
+
 	// Add startup functions to retrieve the zone and keep it up to date.
 	for _, n := range zones.Names {
+		n := n
 		if len(zones.Z[n].TransferFrom) > 0 {
-			c.OnStartup(func() error {
+			config.OnStartupAfter("secondary", nil, func() error {
 				zones.Z[n].StartupOnce.Do(func() {
 					zones.Z[n].TransferIn()
 					go func() {
@@ -36,7 +40,7 @@ func setup(c *caddy.Controller) error {
 		}
 	}
 
-	dnsserver.GetConfig(c).AddMiddleware(func(next middleware.Handler) middleware.Handler {
+	config.AddMiddleware(func(next middleware.Handler) middleware.Handler {
 		return Secondary{file.File{Next: next, Zones: zones}}
 	})
 
@@ -62,7 +66,33 @@ func secondaryParse(c *caddy.Controller) (file.Zones, error) {
 				names = append(names, origins[i])
 			}
 
+			var (
+				tlsArgs    []string
+				useTLS     bool
+				serverName string
+			)
 			for c.NextBlock() {
+				switch c.Val() {
+				case "tls": // [cert key] [cacertfile]
+					tlsArgs = c.RemainingArgs()
+					useTLS = true
+					continue
+				case "tls_servername":
+					if !c.NextArg() {
+						return file.Zones{}, c.ArgErr()
+					}
+					serverName = c.Val()
+					useTLS = true
+					continue
+				case "notify":
+					if err := file.NotifyParse(c); err != nil {
+						return file.Zones{}, err
+					}
+					for _, origin := range origins {
+						z[origin].NotifyNS = true
+					}
+					continue
+				}
 				t, f, e := file.TransferParse(c)
 				if e != nil {
 					return file.Zones{}, e
@@ -76,6 +106,16 @@ func secondaryParse(c *caddy.Controller) (file.Zones, error) {
 					}
 				}
 			}
+			if useTLS {
+				tlsConfig, err := mwtls.NewTLSConfigFromArgs(tlsArgs...)
+				if err != nil {
+					return file.Zones{}, err
+				}
+				tlsConfig.ServerName = serverName
+				for _, origin := range origins {
+					z[origin].TransferTLS = tlsConfig
+				}
+			}
 		}
 	}
 	return file.Zones{Z: z, Names: names}, nil
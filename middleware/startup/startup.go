@@ -0,0 +1,67 @@
+// Package startup implements the startup directive that sets what is done when the startup
+// of a middleware fails.
+package startup
+
+import (
+	"time"
+
+	"github.com/miekg/coredns/core/dnsserver"
+	"github.com/miekg/coredns/middleware"
+
+	"github.com/mholt/caddy"
+)
+
+func init() {
+	caddy.RegisterPlugin("startup", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setupStartup,
+	})
+}
+
+func setupStartup(c *caddy.Controller) error {
+	config := dnsserver.GetConfig(c)
+	for c.Next() {
+		args := c.RemainingArgs()
+		if len(args) < 2 {
+			return middleware.Error("startup", c.ArgErr())
+		}
+		policy := dnsserver.StartupPolicy{}
+		switch args[1] {
+		case "degrade":
+			policy.Action = dnsserver.StartupDegrade
+		case "fail":
+			policy.Action = dnsserver.StartupFail
+		case "retry":
+			policy.Action = dnsserver.StartupRetry
+		default:
+			return middleware.Error("startup", c.Errf("expected 'fail', 'degrade' or 'retry', got '%s'", args[1]))
+		}
+		backoff := args[2:]
+		if len(backoff) > 2 || len(backoff) > 0 && policy.Action != dnsserver.StartupRetry {
+			return middleware.Error("startup", c.ArgErr())
+		}
+		for i, arg := range backoff {
+			d, err := time.ParseDuration(arg)
+			if err != nil {
+				return middleware.Error("startup", err)
+			}
+			if d <= 0 {
+				return middleware.Error("startup", c.Errf("backoff must be larger than zero: %s", d))
+			}
+			if i == 0 {
+				policy.Backoff = d
+			} else {
+				policy.MaxBackoff = d
+			}
+		}
+		if policy.MaxBackoff > 0 && policy.Backoff > policy.MaxBackoff {
+			return middleware.Error("startup", c.Errf("backoff %s is larger than the maximum %s", policy.Backoff, policy.MaxBackoff))
+		}
+
+		if config.StartupPolicies == nil {
+			config.StartupPolicies = make(map[string]dnsserver.StartupPolicy)
+		}
+		config.StartupPolicies[args[0]] = policy
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package startup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/coredns/core/dnsserver"
+
+	"github.com/mholt/caddy"
+)
+
+func TestSetupStartup(t *testing.T) {
+	tests := []struct {
+		input      string
+		shouldErr  bool
+		middleware string
+		expected   dnsserver.StartupPolicy
+	}{
+		{`startup kubernetes fail`, false, "kubernetes", dnsserver.StartupPolicy{Action: dnsserver.StartupFail}},
+		{`startup etcd degrade`, false, "etcd", dnsserver.StartupPolicy{Action: dnsserver.StartupDegrade}},
+		{`startup etcd retry`, false, "etcd", dnsserver.StartupPolicy{Action: dnsserver.StartupRetry}},
+		{`startup etcd retry 2s`, false, "etcd", dnsserver.StartupPolicy{Action: dnsserver.StartupRetry, Backoff: 2 * time.Second}},
+		{`startup etcd retry 2s 5m`, false, "etcd", dnsserver.StartupPolicy{Action: dnsserver.StartupRetry, Backoff: 2 * time.Second, MaxBackoff: 5 * time.Minute}},
+		// fails
+		{`startup`, true, "", dnsserver.StartupPolicy{}},
+		{`startup etcd`, true, "", dnsserver.StartupPolicy{}},
+		{`startup etcd blaat`, true, "", dnsserver.StartupPolicy{}},
+		{`startup etcd fail 2s`, true, "", dnsserver.StartupPolicy{}},
+		{`startup etcd retry 2s 5m 10m`, true, "", dnsserver.StartupPolicy{}},
+		{`startup etcd retry soon`, true, "", dnsserver.StartupPolicy{}},
+		{`startup etcd retry -1s`, true, "", dnsserver.StartupPolicy{}},
+		{`startup etcd retry 5m 2s`, true, "", dnsserver.StartupPolicy{}},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		err := setupStartup(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %d: Expected error but found nil", i)
+			continue
+		}
+		if !test.shouldErr && err != nil {
+			t.Errorf("Test %d: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr {
+			continue
+		}
+		policy, ok := dnsserver.GetConfig(c).StartupPolicies[test.middleware]
+		if !ok {
+			t.Errorf("Test %d: Expected a policy for %s", i, test.middleware)
+			continue
+		}
+		if policy != test.expected {
+			t.Errorf("Test %d: Expected policy %v, got %v", i, test.expected, policy)
+		}
+	}
+}
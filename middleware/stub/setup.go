@@ -0,0 +1,66 @@
+package stub
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/miekg/coredns/core/dnsserver"
+	"github.com/miekg/coredns/middleware"
+	"github.com/miekg/coredns/middleware/proxy"
+
+	"github.com/mholt/caddy"
+)
+
+func init() {
+	caddy.RegisterPlugin("stub", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	servers, err := stubParse(c)
+	if err != nil {
+		return middleware.Error("stub", err)
+	}
+
+	s := Stub{Zones: make(map[string]proxy.Proxy)}
+	for zone, to := range servers {
+		s.Zones[zone] = proxy.New(to)
+		s.Names = append(s.Names, zone)
+	}
+
+	dnsserver.GetConfig(c).AddMiddleware(func(next middleware.Handler) middleware.Handler {
+		s.Next = next
+		return s
+	})
+
+	return nil
+}
+
+// stubParse parses 'stub ZONE ADDRESS...' lines and returns the addresses per zone.
+func stubParse(c *caddy.Controller) (map[string][]string, error) {
+	servers := make(map[string][]string)
+
+	for c.Next() {
+		args := c.RemainingArgs()
+		if len(args) < 2 {
+			return nil, c.ArgErr()
+		}
+		zone := middleware.Host(args[0]).Normalize()
+		if _, ok := servers[zone]; ok {
+			return nil, fmt.Errorf("zone %s has already been given", zone)
+		}
+		for _, to := range args[1:] {
+			h, _, err := net.SplitHostPort(to)
+			if err != nil {
+				h = to
+			}
+			if net.ParseIP(h) == nil {
+				return nil, fmt.Errorf("not an IP address: `%s'", h)
+			}
+			servers[zone] = append(servers[zone], middleware.Addr(to).Normalize())
+		}
+	}
+	return servers, nil
+}
@@ -0,0 +1,53 @@
+package stub
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mholt/caddy"
+)
+
+func TestSetupStub(t *testing.T) {
+	tests := []struct {
+		input           string
+		shouldErr       bool
+		expectedServers map[string]string
+	}{
+		{`stub corp.example.org 10.0.0.1`, false, map[string]string{"corp.example.org.": "10.0.0.1:53"}},
+		{`stub corp.example.org 10.0.0.1 10.0.0.2:5353
+		stub 10.in-addr.arpa [::1]:53`, false, map[string]string{
+			"corp.example.org.": "10.0.0.1:53 10.0.0.2:5353",
+			"10.in-addr.arpa.":  "[::1]:53",
+		}},
+		// fails
+		{`stub`, true, nil},
+		{`stub corp.example.org`, true, nil},
+		{`stub corp.example.org ns1.example.org`, true, nil},
+		{`stub corp.example.org 10.0.0.1
+		stub corp.example.org 10.0.0.2`, true, nil},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		servers, err := stubParse(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %d: Expected error but found nil", i)
+			continue
+		}
+		if !test.shouldErr && err != nil {
+			t.Errorf("Test %d: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr {
+			continue
+		}
+		if len(servers) != len(test.expectedServers) {
+			t.Errorf("Test %d: Expected %d zones, got %d", i, len(test.expectedServers), len(servers))
+		}
+		for zone, to := range test.expectedServers {
+			if got := strings.Join(servers[zone], " "); got != to {
+				t.Errorf("Test %d: Expected servers %q for %s, got %q", i, to, zone, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,43 @@
+// Package stub implements a middleware that sends the queries for some zones directly to the
+// authoritative servers of those zones.
+package stub
+
+import (
+	"github.com/miekg/coredns/middleware"
+	"github.com/miekg/coredns/middleware/proxy"
+	"github.com/miekg/coredns/request"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+// Stub sends the queries for the zones in Zones to the servers of that zone, the other
+// queries are handed to Next.
+type Stub struct {
+	Next  middleware.Handler
+	Zones map[string]proxy.Proxy
+	Names []string
+}
+
+// ServeDNS implements the middleware.Handler interface.
+func (s Stub) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: r}
+	zone := middleware.Zones(s.Names).Matches(state.Name())
+	if zone == "" {
+		return s.Next.ServeDNS(ctx, w, r)
+	}
+
+	// The servers are authoritative for the zone, so don't ask them to recurse.
+	req := r.Copy()
+	req.RecursionDesired = false
+	reply, err := s.Zones[zone].Forward(request.Request{W: w, Req: req})
+	if err != nil {
+		return dns.RcodeServerFailure, middleware.Error("stub", err)
+	}
+
+	reply.Id = r.Id
+	reply.RecursionDesired = r.RecursionDesired
+	reply.Compress = true
+	w.WriteMsg(reply)
+	return 0, nil
+}
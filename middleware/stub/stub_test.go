@@ -0,0 +1,80 @@
+package stub
+
+import (
+	"testing"
+
+	"github.com/miekg/coredns/middleware/pkg/dnsrecorder"
+	"github.com/miekg/coredns/middleware/proxy"
+	"github.com/miekg/coredns/middleware/test"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+func TestStub(t *testing.T) {
+	dns.HandleFunc("corp.example.org.", func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Authoritative = true
+		// An authoritative server doesn't get asked to recurse.
+		if r.RecursionDesired {
+			m.Rcode = dns.RcodeRefused
+		} else {
+			m.Answer = append(m.Answer, test.A("www.corp.example.org. 3600 IN A 10.0.0.80"))
+		}
+		w.WriteMsg(m)
+	})
+	defer dns.HandleRemove("corp.example.org.")
+
+	s, addr, err := test.UDPServer(t, "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not start UDP server: %s", err)
+	}
+	defer s.Shutdown()
+
+	st := Stub{
+		Next:  test.NextHandler(dns.RcodeNameError, nil),
+		Zones: map[string]proxy.Proxy{"corp.example.org.": proxy.New([]string{addr})},
+		Names: []string{"corp.example.org."},
+	}
+
+	tests := []struct {
+		qname         string
+		expectedRcode int
+		expectedAns   int
+	}{
+		{"www.corp.example.org.", dns.RcodeSuccess, 1},
+		{"www.example.org.", dns.RcodeNameError, 0}, // handled by next
+	}
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, dns.TypeA)
+		m.RecursionDesired = true
+
+		rec := dnsrecorder.New(&test.ResponseWriter{})
+		rcode, err := st.ServeDNS(context.TODO(), rec, m)
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if rcode != 0 {
+			if rcode != tc.expectedRcode {
+				t.Errorf("Test %d: expected rcode %d, got %d", i, tc.expectedRcode, rcode)
+			}
+			continue
+		}
+		if rec.Msg == nil {
+			t.Errorf("Test %d: expected a reply", i)
+			continue
+		}
+		if rec.Msg.Rcode != tc.expectedRcode {
+			t.Errorf("Test %d: expected rcode %d, got %d", i, tc.expectedRcode, rec.Msg.Rcode)
+		}
+		if len(rec.Msg.Answer) != tc.expectedAns {
+			t.Errorf("Test %d: expected %d answers, got %d", i, tc.expectedAns, len(rec.Msg.Answer))
+		}
+		if !rec.Msg.RecursionDesired {
+			t.Errorf("Test %d: expected the RD bit of the query in the reply", i)
+		}
+	}
+}
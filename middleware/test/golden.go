@@ -0,0 +1,177 @@
+package test
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+var update = flag.Bool("update", false, "update the golden files instead of comparing against them")
+
+// Golden sends each query in qs to h and compares the responses, as rendered by Render, with
+// the contents of the golden file (usually testdata/<name>.golden). Run the tests with -update
+// to (re)write the golden file, the result should be checked in.
+func Golden(t *testing.T, file string, h Handler, qs []*dns.Msg) {
+	buf := &bytes.Buffer{}
+	for i, q := range qs {
+		rec := &recorder{ResponseWriter: &ResponseWriter{}}
+		// Handlers may alter the question, so render a copy of the original.
+		question := q.Question[0]
+		if _, err := h.ServeDNS(context.TODO(), rec, q.Copy()); err != nil {
+			t.Errorf("Query %d, %s: expected no error, got %s", i, q.Question[0].Name, err)
+			continue
+		}
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(buf, ";; QUERY: %s\t%s\t%s\n", question.Name, dns.ClassToString[question.Qclass], dns.TypeToString[question.Qtype])
+		if rec.msg == nil {
+			buf.WriteString(";; no response\n")
+			continue
+		}
+		s, err := Render(rec.msg)
+		if err != nil {
+			t.Errorf("Query %d, %s: failed to render response: %s", i, question.Name, err)
+			continue
+		}
+		buf.WriteString(s)
+	}
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatalf("Failed to create directory for %s: %s", file, err)
+		}
+		if err := ioutil.WriteFile(file, buf.Bytes(), 0644); err != nil {
+			t.Fatalf("Failed to update %s: %s", file, err)
+		}
+		return
+	}
+
+	expected, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatalf("Failed to read golden file: %s (run with -update to create it)", err)
+	}
+	if d := diff(string(expected), buf.String()); d != "" {
+		t.Errorf("Responses differ from %s (run with -update if this is expected):\n%s", file, d)
+	}
+}
+
+// Render returns a textual representation of the message m as it would be seen by a client:
+// m is packed to wire format and unpacked again. Everything, except the message ID, is
+// rendered: opcode, rcode, flags, all sections and the EDNS0 options. The records within a
+// section are sorted, so the order in which a middleware adds them does not matter.
+func Render(m *dns.Msg) (string, error) {
+	buf, err := m.Pack()
+	if err != nil {
+		return "", err
+	}
+	w := new(dns.Msg)
+	if err := w.Unpack(buf); err != nil {
+		return "", err
+	}
+
+	b := &bytes.Buffer{}
+	fmt.Fprintf(b, ";; opcode: %s, rcode: %s, flags:%s\n", dns.OpcodeToString[w.Opcode], dns.RcodeToString[w.Rcode], flags(w))
+
+	opt := w.IsEdns0()
+	extra := []dns.RR{}
+	for _, r := range w.Extra {
+		if r.Header().Rrtype != dns.TypeOPT {
+			extra = append(extra, r)
+		}
+	}
+	if opt != nil {
+		do := ""
+		if opt.Do() {
+			do = " do"
+		}
+		fmt.Fprintf(b, ";; EDNS: version: %d, flags:%s, udp: %d\n", opt.Version(), do, opt.UDPSize())
+		for _, o := range opt.Option {
+			fmt.Fprintf(b, ";; EDNS option %d: %s\n", o.Option(), o.String())
+		}
+	}
+
+	section(b, "QUESTION", nil)
+	for _, q := range w.Question {
+		fmt.Fprintf(b, "%s\t%s\t%s\n", q.Name, dns.ClassToString[q.Qclass], dns.TypeToString[q.Qtype])
+	}
+	section(b, "ANSWER", w.Answer)
+	section(b, "AUTHORITY", w.Ns)
+	section(b, "ADDITIONAL", extra)
+	return b.String(), nil
+}
+
+func section(b *bytes.Buffer, name string, rrs []dns.RR) {
+	fmt.Fprintf(b, ";; %s:\n", name)
+	lines := make([]string, len(rrs))
+	for i, r := range rrs {
+		lines[i] = r.String()
+	}
+	sort.Strings(lines)
+	for _, l := range lines {
+		b.WriteString(l + "\n")
+	}
+}
+
+func flags(m *dns.Msg) string {
+	s := ""
+	for _, f := range []struct {
+		set  bool
+		name string
+	}{
+		{m.Response, "qr"}, {m.Authoritative, "aa"}, {m.Truncated, "tc"}, {m.RecursionDesired, "rd"},
+		{m.RecursionAvailable, "ra"}, {m.Zero, "z"}, {m.AuthenticatedData, "ad"}, {m.CheckingDisabled, "cd"},
+	} {
+		if f.set {
+			s += " " + f.name
+		}
+	}
+	return s
+}
+
+// diff returns the lines that differ between expected and got, or the empty string if they
+// are the same.
+func diff(expected, got string) string {
+	if expected == got {
+		return ""
+	}
+	e := strings.Split(expected, "\n")
+	g := strings.Split(got, "\n")
+	b := &bytes.Buffer{}
+	for i := 0; i < len(e) || i < len(g); i++ {
+		switch {
+		case i >= len(e):
+			fmt.Fprintf(b, "%d: +%s\n", i+1, g[i])
+		case i >= len(g):
+			fmt.Fprintf(b, "%d: -%s\n", i+1, e[i])
+		case e[i] != g[i]:
+			fmt.Fprintf(b, "%d: -%s\n%d: +%s\n", i+1, e[i], i+1, g[i])
+		}
+	}
+	return b.String()
+}
+
+// recorder is a dns.ResponseWriter that holds on to the message written.
+type recorder struct {
+	*ResponseWriter
+	msg *dns.Msg
+}
+
+func (r *recorder) WriteMsg(m *dns.Msg) error {
+	r.msg = m
+	return nil
+}
+
+func (r *recorder) Write(buf []byte) (int, error) {
+	r.msg = new(dns.Msg)
+	return len(buf), r.msg.Unpack(buf)
+}
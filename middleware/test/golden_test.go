@@ -0,0 +1,42 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestRender(t *testing.T) {
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	m.Response, m.Authoritative = true, true
+	m.Answer = []dns.RR{A("example.org. 300 IN A 127.0.0.2"), A("example.org. 300 IN A 127.0.0.1")}
+	m.Extra = []dns.RR{OPT(4096, true)}
+
+	s, err := Render(m)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	expected := `;; opcode: QUERY, rcode: NOERROR, flags: qr aa rd
+;; EDNS: version: 0, flags: do, udp: 4096
+;; QUESTION:
+example.org.	IN	A
+;; ANSWER:
+example.org.	300	IN	A	127.0.0.1
+example.org.	300	IN	A	127.0.0.2
+;; AUTHORITY:
+;; ADDITIONAL:
+`
+	if s != expected {
+		t.Errorf("Expected rendered message to be\n%s, got\n%s\ndiff:\n%s", expected, s, diff(expected, s))
+	}
+}
+
+func TestDiff(t *testing.T) {
+	if d := diff("a\nb\n", "a\nb\n"); d != "" {
+		t.Errorf("Expected no diff, got %q", d)
+	}
+	if d := diff("a\nb\n", "a\nc\n"); d != "2: -b\n2: +c\n" {
+		t.Errorf("Expected diff on line 2, got %q", d)
+	}
+}
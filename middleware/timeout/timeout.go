@@ -0,0 +1,38 @@
+// Package timeout implements the timeout directive that limits the time the middleware
+// of a zone get to answer a query.
+package timeout
+
+import (
+	"time"
+
+	"github.com/miekg/coredns/core/dnsserver"
+	"github.com/miekg/coredns/middleware"
+
+	"github.com/mholt/caddy"
+)
+
+func init() {
+	caddy.RegisterPlugin("timeout", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setupTimeout,
+	})
+}
+
+func setupTimeout(c *caddy.Controller) error {
+	config := dnsserver.GetConfig(c)
+	for c.Next() {
+		args := c.RemainingArgs()
+		if len(args) != 1 {
+			return middleware.Error("timeout", c.ArgErr())
+		}
+		d, err := time.ParseDuration(args[0])
+		if err != nil {
+			return middleware.Error("timeout", err)
+		}
+		if d <= 0 {
+			return middleware.Error("timeout", c.Errf("timeout must be positive: %s", d))
+		}
+		config.Timeout = d
+	}
+	return nil
+}
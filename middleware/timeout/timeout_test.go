@@ -0,0 +1,45 @@
+package timeout
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/coredns/core/dnsserver"
+
+	"github.com/mholt/caddy"
+)
+
+func TestSetupTimeout(t *testing.T) {
+	tests := []struct {
+		input           string
+		shouldErr       bool
+		expectedTimeout time.Duration
+	}{
+		{`timeout 500ms`, false, 500 * time.Millisecond},
+		{`timeout 2s`, false, 2 * time.Second},
+		// fails
+		{`timeout`, true, 0},
+		{`timeout 500`, true, 0},
+		{`timeout 0s`, true, 0},
+		{`timeout 1s 2s`, true, 0},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		err := setupTimeout(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %d: Expected error but found nil", i)
+			continue
+		}
+		if !test.shouldErr && err != nil {
+			t.Errorf("Test %d: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr {
+			continue
+		}
+		if cfg := dnsserver.GetConfig(c); cfg.Timeout != test.expectedTimeout {
+			t.Errorf("Test %d: Expected timeout %s, got %s", i, test.expectedTimeout, cfg.Timeout)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package tls
+
+import (
+	"crypto/tls"
+	"log"
+	"sync"
+)
+
+// keyPair is the certificate of the server. It is loaded again when the certificate or
+// the key is changed on disk, so a renewed certificate is used without a restart.
+type keyPair struct {
+	certFile string
+	keyFile  string
+
+	sync.RWMutex
+	cert *tls.Certificate
+}
+
+// newKeyPair moves the certificate of config to a keyPair that config gets its
+// certificate from.
+func newKeyPair(certFile, keyFile string, config *tls.Config) *keyPair {
+	k := &keyPair{certFile: certFile, keyFile: keyFile, cert: &config.Certificates[0]}
+	config.Certificates = nil
+	config.GetCertificate = k.GetCertificate
+	return k
+}
+
+// GetCertificate implements the GetCertificate callback of tls.Config.
+func (k *keyPair) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	k.RLock()
+	defer k.RUnlock()
+	return k.cert, nil
+}
+
+func (k *keyPair) reload() error {
+	cert, err := tls.LoadX509KeyPair(k.certFile, k.keyFile)
+	if err != nil {
+		// The certificate and the key are written one after the other, this is
+		// expected until both are.
+		log.Printf("[ERROR] Failed to reload TLS certificate `%s': %s", k.certFile, err)
+		return err
+	}
+	k.Lock()
+	k.cert = &cert
+	k.Unlock()
+	log.Printf("[INFO] Successfully reloaded TLS certificate `%s'", k.certFile)
+	return nil
+}
@@ -0,0 +1,60 @@
+// Package tls implements the tls directive, it sets the TLS configuration that is
+// used by servers that use encrypted transports, such as DNS-over-HTTPS.
+package tls
+
+import (
+	"github.com/miekg/coredns/core/dnsserver"
+	"github.com/miekg/coredns/middleware"
+	"github.com/miekg/coredns/middleware/pkg/tls"
+	"github.com/miekg/coredns/middleware/pkg/watch"
+
+	"github.com/mholt/caddy"
+)
+
+func init() {
+	caddy.RegisterPlugin("tls", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	config := dnsserver.GetConfig(c)
+
+	if config.TLSConfig != nil {
+		return middleware.Error("tls", c.Errf("TLS already configured for this server instance"))
+	}
+
+	for c.Next() {
+		args := c.RemainingArgs()
+		if len(args) < 2 || len(args) > 3 {
+			return middleware.Error("tls", c.ArgErr())
+		}
+		tls, err := tls.NewTLSConfigFromArgs(args...)
+		if err != nil {
+			return middleware.Error("tls", err)
+		}
+		config.TLSConfig = tls
+
+		// Use a renewed certificate without a restart.
+		kp := newKeyPair(args[0], args[1], tls)
+		var stops []func()
+		c.OnStartup(func() error {
+			for _, file := range []string{kp.certFile, kp.keyFile} {
+				stop, err := watch.File(file, kp.reload)
+				if err != nil {
+					return middleware.Error("tls", err)
+				}
+				stops = append(stops, stop)
+			}
+			return nil
+		})
+		c.OnShutdown(func() error {
+			for _, stop := range stops {
+				stop()
+			}
+			return nil
+		})
+	}
+	return nil
+}
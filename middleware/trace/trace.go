@@ -0,0 +1,113 @@
+// Package trace implements the trace directive, it traces the queries through the
+// middleware and sends the spans to Zipkin.
+package trace
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/coredns/core/dnsserver"
+	"github.com/miekg/coredns/middleware"
+
+	"github.com/mholt/caddy"
+	zipkin "github.com/openzipkin/zipkin-go-opentracing"
+)
+
+func init() {
+	caddy.RegisterPlugin("trace", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+type trace struct {
+	endpointType string
+	endpoint     string
+	service      string
+	every        uint32
+}
+
+func setup(c *caddy.Controller) error {
+	t, err := traceParse(c)
+	if err != nil {
+		return middleware.Error("trace", err)
+	}
+
+	config := dnsserver.GetConfig(c)
+	collector, err := zipkin.NewHTTPCollector(t.endpoint)
+	if err != nil {
+		return middleware.Error("trace", err)
+	}
+	recorder := zipkin.NewRecorder(collector, false, "0.0.0.0:"+config.Port, t.service)
+	tracer, err := zipkin.NewTracer(recorder, zipkin.ClientServerSameSpan(false))
+	if err != nil {
+		collector.Close()
+		return middleware.Error("trace", err)
+	}
+
+	config.Tracer = tracer
+	config.TraceEvery = t.every
+	// Flush the spans that are still queued.
+	config.OnShutdown(collector.Close)
+
+	return nil
+}
+
+func traceParse(c *caddy.Controller) (trace, error) {
+	t := trace{endpointType: defEpType, endpoint: defEndpoint, service: defService, every: 1}
+
+	for c.Next() {
+		args := c.RemainingArgs()
+		switch len(args) {
+		case 0:
+		case 1:
+			t.endpoint = args[0]
+		case 2:
+			t.endpointType, t.endpoint = strings.ToLower(args[0]), args[1]
+		default:
+			return t, c.ArgErr()
+		}
+
+		for c.NextBlock() {
+			what := c.Val()
+			args := c.RemainingArgs()
+			if len(args) != 1 {
+				return t, c.ArgErr()
+			}
+			switch what {
+			case "every":
+				n, err := strconv.ParseUint(args[0], 10, 32)
+				if err != nil || n == 0 {
+					return t, c.Errf("every needs a positive number: %s", args[0])
+				}
+				t.every = uint32(n)
+			case "service":
+				t.service = args[0]
+			default:
+				return t, c.Errf("unknown property '%s'", what)
+			}
+		}
+	}
+
+	if t.endpointType != defEpType {
+		return t, fmt.Errorf("tracing endpoint type '%s' is not supported", t.endpointType)
+	}
+	t.endpoint = zipkinURL(t.endpoint)
+	return t, nil
+}
+
+// zipkinURL returns the URL of the span API of the Zipkin collector at endpoint, which
+// is either an URL or a host:port.
+func zipkinURL(endpoint string) string {
+	if strings.Contains(endpoint, "://") {
+		return endpoint
+	}
+	return "http://" + endpoint + "/api/v1/spans"
+}
+
+const (
+	defEpType   = "zipkin"
+	defEndpoint = "localhost:9411"
+	defService  = "coredns"
+)
@@ -0,0 +1,61 @@
+package trace
+
+import (
+	"testing"
+
+	"github.com/mholt/caddy"
+)
+
+func TestTraceParse(t *testing.T) {
+	tests := []struct {
+		input            string
+		shouldErr        bool
+		expectedEndpoint string
+		expectedService  string
+		expectedEvery    uint32
+	}{
+		{`trace`, false, "http://localhost:9411/api/v1/spans", "coredns", 1},
+		{`trace zipkin.example.org:9411`, false, "http://zipkin.example.org:9411/api/v1/spans", "coredns", 1},
+		{`trace zipkin https://zipkin.example.org/api/v1/spans {
+			every 100
+			service dns-edge
+		}`, false, "https://zipkin.example.org/api/v1/spans", "dns-edge", 100},
+		// fails
+		{`trace jaeger localhost:6831`, true, "", "", 0},
+		{`trace zipkin localhost:9411 extra`, true, "", "", 0},
+		{`trace {
+			every 0
+		}`, true, "", "", 0},
+		{`trace {
+			every
+		}`, true, "", "", 0},
+		{`trace {
+			blaat 1
+		}`, true, "", "", 0},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		tr, err := traceParse(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %d: Expected error but found nil", i)
+			continue
+		}
+		if !test.shouldErr && err != nil {
+			t.Errorf("Test %d: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr {
+			continue
+		}
+		if tr.endpoint != test.expectedEndpoint {
+			t.Errorf("Test %d: Expected endpoint %s, got %s", i, test.expectedEndpoint, tr.endpoint)
+		}
+		if tr.service != test.expectedService {
+			t.Errorf("Test %d: Expected service %s, got %s", i, test.expectedService, tr.service)
+		}
+		if tr.every != test.expectedEvery {
+			t.Errorf("Test %d: Expected every %d, got %d", i, test.expectedEvery, tr.every)
+		}
+	}
+}
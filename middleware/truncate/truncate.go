@@ -0,0 +1,55 @@
+// Package truncate implements the truncate directive that controls how UDP responses
+// that are too large for the client are handled.
+package truncate
+
+import (
+	"strconv"
+
+	"github.com/miekg/coredns/core/dnsserver"
+	"github.com/miekg/coredns/middleware"
+
+	"github.com/mholt/caddy"
+	"github.com/miekg/dns"
+)
+
+func init() {
+	caddy.RegisterPlugin("truncate", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setupTruncate,
+	})
+}
+
+func setupTruncate(c *caddy.Controller) error {
+	config := dnsserver.GetConfig(c)
+	for c.Next() {
+		if len(c.RemainingArgs()) != 0 {
+			return middleware.Error("truncate", c.ArgErr())
+		}
+		for c.NextBlock() {
+			what := c.Val()
+			args := c.RemainingArgs()
+			switch what {
+			case "minimal_responses":
+				if len(args) != 0 {
+					return middleware.Error("truncate", c.ArgErr())
+				}
+				config.MinimalResponses = true
+			case "max_udp_size":
+				if len(args) != 1 {
+					return middleware.Error("truncate", c.ArgErr())
+				}
+				n, err := strconv.Atoi(args[0])
+				if err != nil {
+					return middleware.Error("truncate", c.Errf("max_udp_size needs a number: %s", args[0]))
+				}
+				if n < dns.MinMsgSize || n > dns.MaxMsgSize {
+					return middleware.Error("truncate", c.Errf("max_udp_size must be between %d and %d: %d", dns.MinMsgSize, dns.MaxMsgSize, n))
+				}
+				config.MaxUDPSize = n
+			default:
+				return middleware.Error("truncate", c.Errf("unknown property '%s'", what))
+			}
+		}
+	}
+	return nil
+}
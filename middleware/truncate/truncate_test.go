@@ -0,0 +1,63 @@
+package truncate
+
+import (
+	"testing"
+
+	"github.com/miekg/coredns/core/dnsserver"
+
+	"github.com/mholt/caddy"
+)
+
+func TestSetupTruncate(t *testing.T) {
+	tests := []struct {
+		input           string
+		shouldErr       bool
+		expectedMinimal bool
+		expectedMaxSize int
+	}{
+		{`truncate`, false, false, 0},
+		{`truncate {
+			minimal_responses
+		}`, false, true, 0},
+		{`truncate {
+			max_udp_size 1232
+		}`, false, false, 1232},
+		// fails
+		{`truncate 512`, true, false, 0},
+		{`truncate {
+			minimal_responses yes
+		}`, true, false, 0},
+		{`truncate {
+			max_udp_size 100
+		}`, true, false, 0},
+		{`truncate {
+			max_udp_size large
+		}`, true, false, 0},
+		{`truncate {
+			blaat
+		}`, true, false, 0},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		err := setupTruncate(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %d: Expected error but found nil", i)
+			continue
+		}
+		if !test.shouldErr && err != nil {
+			t.Errorf("Test %d: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr {
+			continue
+		}
+		cfg := dnsserver.GetConfig(c)
+		if cfg.MinimalResponses != test.expectedMinimal {
+			t.Errorf("Test %d: Expected MinimalResponses to be %t", i, test.expectedMinimal)
+		}
+		if cfg.MaxUDPSize != test.expectedMaxSize {
+			t.Errorf("Test %d: Expected MaxUDPSize %d, got %d", i, test.expectedMaxSize, cfg.MaxUDPSize)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package unix
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/miekg/coredns/core/dnsserver"
+	"github.com/miekg/coredns/middleware"
+
+	"github.com/mholt/caddy"
+)
+
+func setupUnix(c *caddy.Controller) error {
+	config := dnsserver.GetConfig(c)
+	for c.Next() {
+		if config.UnixSocket != "" {
+			return middleware.Error("unix", c.Err("unix can only be given once"))
+		}
+		args := c.RemainingArgs()
+		if len(args) == 0 || len(args) > 2 {
+			return middleware.Error("unix", c.ArgErr())
+		}
+		if config.Transport != "" && config.Transport != dnsserver.TransportDNS {
+			return middleware.Error("unix", fmt.Errorf("can't be used with the %s transport", config.Transport))
+		}
+		if len(config.ListenHosts) > 0 {
+			return middleware.Error("unix", fmt.Errorf("can't be used together with bind"))
+		}
+		path, err := filepath.Abs(args[0])
+		if err != nil {
+			return middleware.Error("unix", err)
+		}
+		config.UnixSocket = path
+		if len(args) == 2 {
+			mode, err := strconv.ParseUint(args[1], 8, 32)
+			if err != nil || mode == 0 || mode > 0777 {
+				return middleware.Error("unix", fmt.Errorf("not a valid file mode: %s", args[1]))
+			}
+			config.UnixMode = os.FileMode(mode)
+		}
+	}
+	return nil
+}
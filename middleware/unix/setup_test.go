@@ -0,0 +1,68 @@
+package unix
+
+import (
+	"os"
+	"testing"
+
+	"github.com/miekg/coredns/core/dnsserver"
+
+	"github.com/mholt/caddy"
+)
+
+func TestSetupUnix(t *testing.T) {
+	tests := []struct {
+		input        string
+		shouldErr    bool
+		expectedPath string
+		expectedMode os.FileMode
+	}{
+		{`unix /run/coredns.sock`, false, "/run/coredns.sock", 0},
+		{`unix /run/coredns.sock 0660`, false, "/run/coredns.sock", 0660},
+		{`unix /run/coredns.sock 600`, false, "/run/coredns.sock", 0600},
+		// fails
+		{`unix`, true, "", 0},
+		{`unix /run/coredns.sock 0660 extra`, true, "", 0},
+		{`unix /run/coredns.sock 0999`, true, "", 0},
+		{`unix /run/coredns.sock 01777`, true, "", 0},
+		{`unix /run/coredns.sock rw`, true, "", 0},
+		{`unix /run/a.sock
+		unix /run/b.sock`, true, "", 0},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		err := setupUnix(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %d: Expected error but found nil", i)
+			continue
+		}
+		if !test.shouldErr && err != nil {
+			t.Errorf("Test %d: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr {
+			continue
+		}
+		config := dnsserver.GetConfig(c)
+		if config.UnixSocket != test.expectedPath {
+			t.Errorf("Test %d: Expected socket %s, got %s", i, test.expectedPath, config.UnixSocket)
+		}
+		if config.UnixMode != test.expectedMode {
+			t.Errorf("Test %d: Expected mode %o, got %o", i, test.expectedMode, config.UnixMode)
+		}
+	}
+}
+
+func TestSetupUnixTransport(t *testing.T) {
+	c := caddy.NewTestController("dns", `unix /run/coredns.sock`)
+	dnsserver.GetConfig(c).ListenHosts = []string{"127.0.0.1"}
+	if err := setupUnix(c); err == nil {
+		t.Errorf("Expected error for unix with bind, got none")
+	}
+
+	c = caddy.NewTestController("dns", `unix /run/coredns.sock`)
+	dnsserver.GetConfig(c).Transport = dnsserver.TransportHTTPS
+	if err := setupUnix(c); err == nil {
+		t.Errorf("Expected error for unix with https, got none")
+	}
+}
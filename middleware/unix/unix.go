@@ -0,0 +1,10 @@
+package unix
+
+import "github.com/mholt/caddy"
+
+func init() {
+	caddy.RegisterPlugin("unix", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setupUnix,
+	})
+}
@@ -0,0 +1,72 @@
+package request
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/idna"
+)
+
+// ToASCII returns name with the labels that hold UTF-8 converted to their ASCII
+// (punycode) form, these labels are lowercased as well. Go DNS presents non-ASCII
+// bytes in a name as \DDD escapes, so bücher.example. comes in as
+// b\195\188cher.example. and is returned as xn--bcher-kva.example.
+//
+// Names without escapes, or with escapes that are not valid UTF-8, are returned
+// as is.
+func ToASCII(name string) string {
+	if !strings.Contains(name, `\`) {
+		return name
+	}
+
+	labels := dns.SplitDomainName(name)
+	changed := false
+	for i, l := range labels {
+		u, ok := unescape(l)
+		if !ok || !utf8.ValidString(u) {
+			continue
+		}
+		a, err := idna.ToASCII(strings.ToLower(u))
+		if err != nil {
+			continue
+		}
+		labels[i] = a
+		changed = true
+	}
+	if !changed {
+		return name
+	}
+	return dns.Fqdn(strings.Join(labels, "."))
+}
+
+// unescape returns label with the \DDD escapes replaced by the bytes they stand
+// for. It returns false if label is pure ASCII or has other escapes, those are
+// left alone.
+func unescape(label string) (string, bool) {
+	b := make([]byte, 0, len(label))
+	high := false
+	for i := 0; i < len(label); i++ {
+		if label[i] != '\\' {
+			b = append(b, label[i])
+			continue
+		}
+		if i+4 > len(label) {
+			return "", false
+		}
+		d := label[i+1 : i+4]
+		if !isDigit(d[0]) || !isDigit(d[1]) || !isDigit(d[2]) {
+			return "", false
+		}
+		v := int(d[0]-'0')*100 + int(d[1]-'0')*10 + int(d[2]-'0')
+		if v < 0x80 || v > 0xff {
+			return "", false
+		}
+		b = append(b, byte(v))
+		high = true
+		i += 3
+	}
+	return string(b), high
+}
+
+func isDigit(b byte) bool { return b >= '0' && b <= '9' }
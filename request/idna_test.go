@@ -0,0 +1,25 @@
+package request
+
+import "testing"
+
+func TestToASCII(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected string
+	}{
+		{"example.org.", "example.org."},
+		{"Example.ORG.", "Example.ORG."}, // no escapes, left alone
+		{`b\195\188cher.example.`, "xn--bcher-kva.example."},
+		{`B\195\156CHER.example.`, "xn--bcher-kva.example."},
+		{`www.b\195\188cher.example.`, "www.xn--bcher-kva.example."},
+		{`a\.b.example.`, `a\.b.example.`},         // not a high byte
+		{`\255\255.example.`, `\255\255.example.`}, // not UTF-8
+		{".", "."},
+	}
+
+	for i, tc := range tests {
+		if x := ToASCII(tc.in); x != tc.expected {
+			t.Errorf("Test %d: expected %s, got %s", i, tc.expected, x)
+		}
+	}
+}
@@ -150,6 +150,16 @@ func (r *Request) SizeAndDo(m *dns.Msg) bool {
 	return true
 }
 
+// Subnet returns the client subnet from the EDNS0 CLIENT SUBNET option in the request,
+// or nil if the request has none.
+func (r *Request) Subnet() *net.IPNet {
+	s := edns.Subnet(r.Req)
+	if s == nil {
+		return nil
+	}
+	return edns.SubnetNet(s)
+}
+
 // Result is the result of Scrub.
 type Result int
 
@@ -161,27 +171,34 @@ const (
 )
 
 // Scrub scrubs the reply message so that it will fit the client's buffer. If even after dropping
-// the additional section, it still does not fit the TC bit will be set on the message. Note,
-// the TC bit will be set regardless of protocol, even TCP message will get the bit, the client
-// should then retry with pigeons.
+// the additional section, it still does not fit, the TC bit is set and the answer and authority
+// sections are emptied, so the client retries over TCP.
 // TODO(referral).
 func (r *Request) Scrub(reply *dns.Msg) (*dns.Msg, Result) {
-	size := r.Size()
-	l := reply.Len()
-	if size >= l {
+	return r.ScrubSize(reply, r.Size(), false)
+}
+
+// ScrubSize is like Scrub, but fits reply in size bytes. If minimal is true, the authority
+// and additional sections are both dropped before the reply is truncated.
+func (r *Request) ScrubSize(reply *dns.Msg, size int, minimal bool) (*dns.Msg, Result) {
+	if size >= reply.Len() {
 		return reply, ScrubIgnored
 	}
-	// TODO(miek): check for delegation
 
 	// If not delegation, drop additional section.
 	reply.Extra = nil
+	if minimal {
+		reply.Ns = nil
+	}
 	r.SizeAndDo(reply)
-	l = reply.Len()
-	if size >= l {
+	if size >= reply.Len() {
 		return reply, ScrubDone
 	}
-	// Still?!! does not fit.
+	// Still?!! does not fit. Send nothing rather than a partial answer, a client that
+	// does not retry over TCP could take that for the complete one.
 	reply.Truncated = true
+	reply.Answer = nil
+	reply.Ns = nil
 	return reply, ScrubDone
 }
 
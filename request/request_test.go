@@ -1,6 +1,8 @@
 package request
 
 import (
+	"fmt"
+	"net"
 	"testing"
 
 	"github.com/miekg/coredns/middleware/test"
@@ -31,6 +33,59 @@ func TestRequestRemote(t *testing.T) {
 	}
 }
 
+func TestRequestSubnet(t *testing.T) {
+	st := testRequest()
+	if n := st.Subnet(); n != nil {
+		t.Fatalf("Expected no subnet, got %s", n)
+	}
+
+	o := st.Req.IsEdns0()
+	o.Option = append(o.Option, &dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: 1, SourceNetmask: 24, Address: net.ParseIP("10.240.0.1").To4()})
+	if n := st.Subnet(); n == nil || n.String() != "10.240.0.0/24" {
+		t.Errorf("Expected subnet 10.240.0.0/24, got %s", n)
+	}
+}
+
+func TestRequestScrub(t *testing.T) {
+	tests := []struct {
+		size          int
+		minimal       bool
+		expectedTC    bool
+		expectedAns   int
+		expectedNs    int
+		expectedExtra int
+	}{
+		{4096, false, false, 40, 1, 41},
+		{2048, false, false, 40, 1, 1}, // additional section dropped, OPT kept
+		{2048, true, false, 40, 0, 1},
+		{512, false, true, 0, 0, 1},
+	}
+
+	for i, tc := range tests {
+		st := testRequest()
+		reply := new(dns.Msg)
+		reply.SetReply(st.Req)
+		for j := 0; j < 40; j++ {
+			reply.Answer = append(reply.Answer, test.A(fmt.Sprintf("example.com. 3600 IN A 10.0.0.%d", j)))
+			reply.Extra = append(reply.Extra, test.A(fmt.Sprintf("ns%d.example.com. 3600 IN A 10.0.1.%d", j, j)))
+		}
+		reply.Ns = []dns.RR{test.SOA("example.com. 3600 IN SOA ns.example.com. hostmaster.example.com. 1 3600 600 86400 3600")}
+		st.SizeAndDo(reply)
+
+		reply, _ = st.ScrubSize(reply, tc.size, tc.minimal)
+		if reply.Truncated != tc.expectedTC {
+			t.Errorf("Test %d: expected TC to be %t", i, tc.expectedTC)
+		}
+		if len(reply.Answer) != tc.expectedAns || len(reply.Ns) != tc.expectedNs || len(reply.Extra) != tc.expectedExtra {
+			t.Errorf("Test %d: expected %d/%d/%d records, got %d/%d/%d", i, tc.expectedAns, tc.expectedNs, tc.expectedExtra,
+				len(reply.Answer), len(reply.Ns), len(reply.Extra))
+		}
+		if reply.IsEdns0() == nil {
+			t.Errorf("Test %d: expected OPT record to be kept", i)
+		}
+	}
+}
+
 func BenchmarkRequestDo(b *testing.B) {
 	st := testRequest()
 
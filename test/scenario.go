@@ -0,0 +1,162 @@
+package test
+
+import (
+	"io/ioutil"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/coredns/middleware/test"
+
+	"github.com/miekg/dns"
+)
+
+// Scenario is an integration test that is driven by a Corefile. Run starts the stub
+// upstreams, starts CoreDNS with the Corefile and checks the responses to the cases.
+type Scenario struct {
+	// Corefile to run. The first server block must listen on port 0. {NAME} is replaced
+	// by the address of the upstream with that name.
+	Corefile string
+	// Upstreams are the stub nameservers the middleware can forward to.
+	Upstreams []Upstream
+	// Cases are the queries and the expected responses, see test.Case.
+	Cases []test.Case
+	// TCP sends the queries over TCP instead of UDP.
+	TCP bool
+}
+
+// Upstream is a stub nameserver for a Scenario, it listens on UDP and TCP.
+type Upstream struct {
+	// Name of the upstream in the Corefile.
+	Name string
+	// Records in zone file format. The records with the name and type of the query are
+	// returned, names that have no records get NXDOMAIN.
+	Records []string
+	// Rcode, if not zero, is the rcode of all responses.
+	Rcode int
+	// Delay is the time to wait before responding.
+	Delay time.Duration
+	// Drop makes the upstream not respond at all.
+	Drop bool
+	// Handler, if not nil, is used instead of the behavior above.
+	Handler dns.Handler
+}
+
+// Run runs the scenario. It fails t if a response is not as expected.
+func (s Scenario) Run(t *testing.T) {
+	corefile := s.Corefile
+	for _, u := range s.Upstreams {
+		addr, stop, err := u.start()
+		if err != nil {
+			t.Fatalf("Could not start upstream %s: %s", u.Name, err)
+		}
+		defer stop()
+		corefile = strings.Replace(corefile, "{"+u.Name+"}", addr, -1)
+	}
+
+	i, err := CoreDNSServer(corefile)
+	if err != nil {
+		t.Fatalf("Could not get CoreDNS serving instance: %s", err)
+	}
+	defer i.Stop()
+
+	udp, tcp := CoreDNSServerPorts(i, 0)
+	if udp == "" {
+		t.Fatalf("Could not get UDP listening port")
+	}
+	log.SetOutput(ioutil.Discard)
+
+	c, addr := new(dns.Client), udp
+	if s.TCP {
+		c.Net, addr = "tcp", tcp
+	}
+	for _, tc := range s.Cases {
+		resp, _, err := c.Exchange(tc.Msg(), addr)
+		if err != nil {
+			t.Errorf("Expected to receive reply for %s %s, but didn't: %s", tc.Qname, dns.TypeToString[tc.Qtype], err)
+			continue
+		}
+		if !test.Header(t, tc, resp) {
+			continue
+		}
+		if !test.Section(t, tc, test.Answer, resp.Answer) {
+			continue
+		}
+		if !test.Section(t, tc, test.Ns, resp.Ns) {
+			continue
+		}
+		test.Section(t, tc, test.Extra, resp.Extra)
+	}
+}
+
+// start starts the upstream on a random port, it returns its address and a function
+// that stops it.
+func (u Upstream) start() (string, func(), error) {
+	h := u.Handler
+	if h == nil {
+		rrs := make([]dns.RR, len(u.Records))
+		for i, r := range u.Records {
+			rr, err := dns.NewRR(r)
+			if err != nil {
+				return "", nil, err
+			}
+			rrs[i] = rr
+		}
+		h = dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) { u.serve(rrs, w, r) })
+	}
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		return "", nil, err
+	}
+	l, err := net.Listen("tcp", pc.LocalAddr().String())
+	if err != nil {
+		pc.Close()
+		return "", nil, err
+	}
+
+	servers := []*dns.Server{{PacketConn: pc, Handler: h}, {Listener: l, Handler: h}}
+	for _, s := range servers {
+		var wg sync.WaitGroup
+		wg.Add(1)
+		s.NotifyStartedFunc = wg.Done
+		go s.ActivateAndServe()
+		wg.Wait()
+	}
+	stop := func() {
+		for _, s := range servers {
+			s.Shutdown()
+		}
+	}
+	return pc.LocalAddr().String(), stop, nil
+}
+
+// serve answers r with the matching records in rrs.
+func (u Upstream) serve(rrs []dns.RR, w dns.ResponseWriter, r *dns.Msg) {
+	if u.Drop {
+		return
+	}
+	time.Sleep(u.Delay)
+
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Authoritative = true
+	q := r.Question[0]
+	m.Rcode = dns.RcodeNameError
+	for _, rr := range rrs {
+		if !strings.EqualFold(rr.Header().Name, q.Name) {
+			continue
+		}
+		m.Rcode = dns.RcodeSuccess
+		if rr.Header().Rrtype == q.Qtype || q.Qtype == dns.TypeANY {
+			m.Answer = append(m.Answer, rr)
+		}
+	}
+	if u.Rcode != 0 {
+		m.Rcode = u.Rcode
+	}
+	w.WriteMsg(m)
+}
@@ -0,0 +1,58 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/miekg/coredns/middleware/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestScenarioProxy(t *testing.T) {
+	Scenario{
+		Corefile: `.:0 {
+    proxy . {upstream}
+}
+`,
+		Upstreams: []Upstream{
+			{Name: "upstream", Records: []string{"example.org. 300 IN A 127.0.0.1", "example.org. 300 IN MX 10 mx.example.org."}},
+		},
+		Cases: []test.Case{
+			{Qname: "example.org.", Qtype: dns.TypeA, Answer: []dns.RR{test.A("example.org. 300 IN A 127.0.0.1")}},
+			{Qname: "example.org.", Qtype: dns.TypeAAAA},
+			{Qname: "www.example.org.", Qtype: dns.TypeA, Rcode: dns.RcodeNameError},
+		},
+	}.Run(t)
+}
+
+func TestScenarioRewriteCache(t *testing.T) {
+	Scenario{
+		Corefile: `example.org:0 {
+    rewrite name www.example.org example.org
+    cache
+    proxy . {upstream}
+}
+`,
+		Upstreams: []Upstream{
+			{Name: "upstream", Records: []string{"example.org. 300 IN A 127.0.0.1"}},
+		},
+		Cases: []test.Case{
+			{Qname: "www.example.org.", Qtype: dns.TypeA, Answer: []dns.RR{test.A("example.org. 303 IN A 127.0.0.1")}},
+			{Qname: "www.example.org.", Qtype: dns.TypeA, Answer: []dns.RR{test.A("example.org. 303 IN A 127.0.0.1")}},
+		},
+		TCP: true,
+	}.Run(t)
+}
+
+func TestScenarioUpstreamFailure(t *testing.T) {
+	Scenario{
+		Corefile: `.:0 {
+    proxy . {upstream}
+}
+`,
+		Upstreams: []Upstream{{Name: "upstream", Rcode: dns.RcodeServerFailure}},
+		Cases: []test.Case{
+			{Qname: "example.org.", Qtype: dns.TypeA, Rcode: dns.RcodeServerFailure},
+		},
+	}.Run(t)
+}